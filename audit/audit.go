@@ -0,0 +1,151 @@
+// Package audit records who changed what, and when, for write operations and admin actions.
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultCapacity is how many entries NewMemoryLog keeps when given a non-positive capacity.
+const DefaultCapacity = 10000
+
+// Entry is a single audited operation.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Actor is the name of the authenticated principal, or "anonymous" when the service runs
+	// without authentication.
+	Actor string `json:"actor"`
+	// Action names the operation, e.g. "recipe.update" or "cache.flush".
+	Action string `json:"action"`
+	// Target identifies what was acted on, e.g. a recipe ID. It may be empty.
+	Target string `json:"target,omitempty"`
+	// Before and After are JSON snapshots of the target around the operation; Before is
+	// absent for creations and After for deletions.
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+	// Changed lists the top-level fields that differ between Before and After.
+	Changed []string `json:"changed,omitempty"`
+}
+
+// NewEntry returns an entry stamped with the current time, with before and after (either may
+// be nil) encoded as JSON and their differing top-level fields listed in Changed.
+func NewEntry(actor, action, target string, before, after interface{}) Entry {
+	e := Entry{Time: time.Now().UTC(), Actor: actor, Action: action, Target: target}
+	if before != nil {
+		e.Before, _ = json.Marshal(before)
+	}
+	if after != nil {
+		e.After, _ = json.Marshal(after)
+	}
+	e.Changed = changedFields(e.Before, e.After)
+	return e
+}
+
+// changedFields compares two JSON objects field by field. It returns nil unless both are
+// objects.
+func changedFields(before, after json.RawMessage) []string {
+	var b, a map[string]json.RawMessage
+	if json.Unmarshal(before, &b) != nil || json.Unmarshal(after, &a) != nil || b == nil || a == nil {
+		return nil
+	}
+	var changed []string
+	for k, v := range b {
+		if w, ok := a[k]; !ok || !bytes.Equal(v, w) {
+			changed = append(changed, k)
+		}
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			changed = append(changed, k)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// Filter selects entries from a Log. Zero fields match everything.
+type Filter struct {
+	Actor  string
+	Action string
+	Target string
+	Since  time.Time
+	// Limit caps the number of entries returned; 0 means no limit.
+	Limit int
+}
+
+func (f Filter) matches(e Entry) bool {
+	return (f.Actor == "" || e.Actor == f.Actor) &&
+		(f.Action == "" || e.Action == f.Action) &&
+		(f.Target == "" || e.Target == f.Target) &&
+		(f.Since.IsZero() || !e.Time.Before(f.Since))
+}
+
+// Log stores audit entries.
+type Log interface {
+	Record(e Entry)
+	// List returns the entries matching f, newest first.
+	List(f Filter) []Entry
+	// DeleteFunc removes the entries for which del returns true and returns how many were
+	// removed. It serves retention policies and erasure requests.
+	DeleteFunc(del func(Entry) bool) int
+}
+
+// MemoryLog is a bounded, concurrency-safe in-memory Log. Once full, the oldest entries are
+// dropped.
+type MemoryLog struct {
+	mu       sync.RWMutex
+	entries  []Entry // oldest first
+	capacity int
+}
+
+// NewMemoryLog returns an empty MemoryLog holding at most capacity entries.
+func NewMemoryLog(capacity int) *MemoryLog {
+	if capacity <= 0 {
+		capacity = DefaultCapacity
+	}
+	return &MemoryLog{capacity: capacity}
+}
+
+// Record implements Log.
+func (l *MemoryLog) Record(e Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, e)
+	if over := len(l.entries) - l.capacity; over > 0 {
+		l.entries = append(l.entries[:0], l.entries[over:]...)
+	}
+}
+
+// List implements Log.
+func (l *MemoryLog) List(f Filter) []Entry {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	out := []Entry{}
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		if f.Limit > 0 && len(out) == f.Limit {
+			break
+		}
+		if f.matches(l.entries[i]) {
+			out = append(out, l.entries[i])
+		}
+	}
+	return out
+}
+
+// DeleteFunc implements Log.
+func (l *MemoryLog) DeleteFunc(del func(Entry) bool) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	kept := l.entries[:0]
+	for _, e := range l.entries {
+		if !del(e) {
+			kept = append(kept, e)
+		}
+	}
+	n := len(l.entries) - len(kept)
+	l.entries = kept
+	return n
+}
@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewEntryChangedFields(t *testing.T) {
+	type recipe struct {
+		Title string   `json:"title"`
+		Steps []string `json:"steps"`
+		Notes string   `json:"notes,omitempty"`
+	}
+	before := recipe{Title: "Chili", Steps: []string{"Brown beef"}}
+	after := recipe{Title: "Chili", Steps: []string{"Brown beef", "Simmer"}, Notes: "spicy"}
+
+	e := NewEntry("ops", "recipe.update", "r1", before, after)
+	if want := []string{"notes", "steps"}; !reflect.DeepEqual(e.Changed, want) {
+		t.Errorf("Expected changed fields %v, got %v", want, e.Changed)
+	}
+	if e.Before == nil || e.After == nil || e.Time.IsZero() {
+		t.Errorf("Expected snapshots and a timestamp, got %+v", e)
+	}
+
+	if created := NewEntry("ops", "recipe.create", "r1", nil, after); created.Before != nil || created.Changed != nil {
+		t.Errorf("Expected a creation to have no before snapshot or diff, got %+v", created)
+	}
+}
+
+func TestMemoryLogList(t *testing.T) {
+	l := NewMemoryLog(3)
+	base := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i, e := range []Entry{
+		{Actor: "a", Action: "cache.flush"},
+		{Actor: "b", Action: "recipe.create", Target: "r1"},
+		{Actor: "a", Action: "recipe.update", Target: "r1"},
+		{Actor: "a", Action: "recipe.delete", Target: "r1"},
+	} {
+		e.Time = base.Add(time.Duration(i) * time.Hour)
+		l.Record(e)
+	}
+
+	all := l.List(Filter{})
+	if len(all) != 3 || all[0].Action != "recipe.delete" || all[2].Action != "recipe.create" {
+		t.Fatalf("Expected the 3 newest entries, newest first, got %+v", all)
+	}
+	if got := l.List(Filter{Actor: "a"}); len(got) != 2 {
+		t.Errorf("Expected 2 entries by actor a, got %+v", got)
+	}
+	if got := l.List(Filter{Since: base.Add(2 * time.Hour), Limit: 1}); len(got) != 1 || got[0].Action != "recipe.delete" {
+		t.Errorf("Expected the latest entry since 02:00, got %+v", got)
+	}
+}
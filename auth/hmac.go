@@ -0,0 +1,191 @@
+// Package auth authenticates callers of the resolver service.
+package auth
+
+import (
+	"bytes"
+	"container/heap"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Headers carrying an HMAC request signature.
+const (
+	HeaderTimestamp = "X-Resolver-Timestamp"
+	HeaderNonce     = "X-Resolver-Nonce"
+	HeaderSignature = "X-Resolver-Signature"
+)
+
+// DefaultWindow is how far a signed request's timestamp may be from the server's clock.
+const DefaultWindow = 5 * time.Minute
+
+// DefaultMaxBody is the largest signed request body read by default: enough for the base64
+// photos the image endpoints accept, up to 8 MiB before encoding, and their JSON envelope.
+const DefaultMaxBody = 12 << 20
+
+// Errors returned by HMACVerifier.Authenticate.
+var (
+	ErrMissingSignature = fmt.Errorf("%w: missing request signature", ErrNoCredentials)
+	ErrStaleTimestamp   = errors.New("auth: request timestamp outside the replay window")
+	ErrBadSignature     = errors.New("auth: invalid request signature")
+	ErrReplayed         = errors.New("auth: request signature already used")
+	ErrBodyTooLarge     = errors.New("auth: signed request body too large")
+)
+
+// Sign signs r with secret at time now. body must be the exact request body (nil if none).
+// The signature is the hex-encoded HMAC-SHA256 of the timestamp, a random nonce, the method,
+// the request URI and the SHA-256 of the body, separated by newlines. The nonce makes every
+// signature unique, so identical requests sent within the same second are not taken for replays.
+func Sign(r *http.Request, body, secret []byte, now time.Time) {
+	var raw [16]byte
+	rand.Read(raw[:])
+	ts, nonce := strconv.FormatInt(now.Unix(), 10), hex.EncodeToString(raw[:])
+	r.Header.Set(HeaderTimestamp, ts)
+	r.Header.Set(HeaderNonce, nonce)
+	r.Header.Set(HeaderSignature, hex.EncodeToString(signature(secret, ts, nonce, r.Method, r.URL.RequestURI(), body)))
+}
+
+func signature(secret []byte, ts, nonce, method, uri string, body []byte) []byte {
+	sum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	io.WriteString(mac, ts+"\n"+nonce+"\n"+method+"\n"+uri+"\n"+hex.EncodeToString(sum[:]))
+	return mac.Sum(nil)
+}
+
+// HMACVerifier authenticates requests signed with Sign using a shared secret. A request is
+// accepted once: its timestamp must be within Window of the current time, and a signature seen
+// before is rejected until it has aged out of the window. It is safe for concurrent use.
+type HMACVerifier struct {
+	// Secrets are the accepted shared secrets. Listing both the old and the new secret allows
+	// rotating it without downtime.
+	Secrets [][]byte
+	Window  time.Duration
+	// Principal is the caller that correctly signed requests are attributed to.
+	Principal Principal
+	// MaxBody is the largest request body read to verify a signature; larger requests fail with
+	// ErrBodyTooLarge. Zero means DefaultMaxBody.
+	MaxBody int64
+	// Now returns the current time; it defaults to time.Now.
+	Now func() time.Time
+
+	mu     sync.Mutex
+	seen   map[string]bool // signatures accepted within the window
+	expiry expiryQueue     // the seen signatures, the first to leave the window first
+}
+
+// NewHMACVerifier returns a verifier accepting any of secrets with the DefaultWindow. Signed
+// requests are attributed to an admin principal named "gateway".
+func NewHMACVerifier(secrets ...[]byte) *HMACVerifier {
+	return &HMACVerifier{
+		Secrets:   secrets,
+		Window:    DefaultWindow,
+		Principal: Principal{Name: "gateway", Role: RoleAdmin},
+		Now:       time.Now,
+	}
+}
+
+// Authenticate verifies the signature of r and returns v.Principal. The request body is read
+// and replaced, so handlers can still consume it.
+func (v *HMACVerifier) Authenticate(r *http.Request) (Principal, error) {
+	if err := v.verify(r); err != nil {
+		return Principal{}, err
+	}
+	return v.Principal, nil
+}
+
+func (v *HMACVerifier) verify(r *http.Request) error {
+	ts, nonce, sig := r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderNonce), r.Header.Get(HeaderSignature)
+	if ts == "" || nonce == "" || sig == "" {
+		return ErrMissingSignature
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrStaleTimestamp
+	}
+	signedAt, now := time.Unix(unix, 0), v.Now()
+	if signedAt.Before(now.Add(-v.Window)) || signedAt.After(now.Add(v.Window)) {
+		return ErrStaleTimestamp
+	}
+	given, err := hex.DecodeString(sig)
+	if err != nil {
+		return ErrBadSignature
+	}
+
+	var body []byte
+	if r.Body != nil {
+		limit := v.MaxBody
+		if limit <= 0 {
+			limit = DefaultMaxBody
+		}
+		body, err = io.ReadAll(io.LimitReader(r.Body, limit+1))
+		r.Body.Close()
+		if err != nil {
+			return err
+		}
+		if int64(len(body)) > limit {
+			return ErrBodyTooLarge
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	valid := false
+	for _, secret := range v.Secrets {
+		if hmac.Equal(given, signature(secret, ts, nonce, r.Method, r.URL.RequestURI(), body)) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return ErrBadSignature
+	}
+	return v.remember(sig, signedAt, now)
+}
+
+// remember records sig as used and forgets signatures that have left the window.
+func (v *HMACVerifier) remember(sig string, signedAt, now time.Time) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.seen == nil {
+		v.seen = make(map[string]bool)
+	}
+	for v.expiry.Len() > 0 && v.expiry[0].signedAt.Before(now.Add(-v.Window)) {
+		delete(v.seen, heap.Pop(&v.expiry).(seenSignature).sig)
+	}
+	if v.seen[sig] {
+		return ErrReplayed
+	}
+	v.seen[sig] = true
+	heap.Push(&v.expiry, seenSignature{sig, signedAt})
+	return nil
+}
+
+// seenSignature is a signature accepted at signedAt.
+type seenSignature struct {
+	sig      string
+	signedAt time.Time
+}
+
+// expiryQueue orders seen signatures by timestamp, the oldest first. Timestamps are only
+// roughly in arrival order, since clients' clocks differ within the window.
+type expiryQueue []seenSignature
+
+func (q expiryQueue) Len() int           { return len(q) }
+func (q expiryQueue) Less(i, j int) bool { return q[i].signedAt.Before(q[j].signedAt) }
+func (q expiryQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *expiryQueue) Push(x any) { *q = append(*q, x.(seenSignature)) }
+
+func (q *expiryQueue) Pop() any {
+	old := *q
+	s := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return s
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func signedRequest(body string, secret []byte, at time.Time) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/resolve?debug=1", strings.NewReader(body))
+	Sign(r, []byte(body), secret, at)
+	return r
+}
+
+func newVerifier(secrets ...[]byte) *HMACVerifier {
+	v := NewHMACVerifier(secrets...)
+	v.Now = func() time.Time { return testNow }
+	return v
+}
+
+func TestHMACVerifierAcceptsValidSignature(t *testing.T) {
+	v := newVerifier([]byte("old"), []byte("new"))
+	r := signedRequest(`{"query":"chili"}`, []byte("new"), testNow.Add(-time.Minute))
+	if _, err := v.Authenticate(r); err != nil {
+		t.Fatalf("Expected a valid signature, got %v", err)
+	}
+	// The handler must still be able to read the body.
+	if body, _ := io.ReadAll(r.Body); string(body) != `{"query":"chili"}` {
+		t.Errorf("Expected the body to be preserved, got %q", body)
+	}
+}
+
+func TestHMACVerifierRejects(t *testing.T) {
+	secret := []byte("secret")
+	tests := []struct {
+		name string
+		req  func() *http.Request
+		want error
+	}{
+		{"unsigned", func() *http.Request {
+			return httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader("{}"))
+		}, ErrMissingSignature},
+		{"wrong secret", func() *http.Request {
+			return signedRequest("{}", []byte("other"), testNow)
+		}, ErrBadSignature},
+		{"tampered body", func() *http.Request {
+			r := signedRequest("{}", secret, testNow)
+			r.Body = io.NopCloser(strings.NewReader(`{"query":"x"}`))
+			return r
+		}, ErrBadSignature},
+		{"tampered path", func() *http.Request {
+			r := signedRequest("{}", secret, testNow)
+			r.URL.Path = "/admin/cache/flush"
+			return r
+		}, ErrBadSignature},
+		{"too old", func() *http.Request {
+			return signedRequest("{}", secret, testNow.Add(-DefaultWindow-time.Second))
+		}, ErrStaleTimestamp},
+		{"from the future", func() *http.Request {
+			return signedRequest("{}", secret, testNow.Add(DefaultWindow+time.Second))
+		}, ErrStaleTimestamp},
+		{"too large", func() *http.Request {
+			return signedRequest(strings.Repeat("x", DefaultMaxBody+1), secret, testNow)
+		}, ErrBodyTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newVerifier(secret).Authenticate(tt.req()); !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestHMACVerifierRejectsReplay(t *testing.T) {
+	secret := []byte("secret")
+	v := newVerifier(secret)
+	first := signedRequest("{}", secret, testNow)
+	replay := httptest.NewRequest(http.MethodPost, "/resolve?debug=1", strings.NewReader("{}"))
+	replay.Header = first.Header.Clone()
+	if _, err := v.Authenticate(first); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Authenticate(replay); !errors.Is(err, ErrReplayed) {
+		t.Errorf("Expected %v, got %v", ErrReplayed, err)
+	}
+	// An identical request signed again in the same second carries a new nonce.
+	if _, err := v.Authenticate(signedRequest("{}", secret, testNow)); err != nil {
+		t.Errorf("Expected a freshly signed identical request to be accepted, got %v", err)
+	}
+}
+
+// TestHMACVerifierForgetsExpiredSignatures verifies that signatures are forgotten once they
+// have left the window, oldest first, whatever order they arrived in.
+func TestHMACVerifierForgetsExpiredSignatures(t *testing.T) {
+	secret := []byte("secret")
+	v := newVerifier(secret)
+	for _, at := range []time.Duration{time.Minute, -4 * time.Minute, 0, -3 * time.Minute} {
+		if _, err := v.Authenticate(signedRequest("{}", secret, testNow.Add(at))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	v.Now = func() time.Time { return testNow.Add(3 * time.Minute) }
+	if _, err := v.Authenticate(signedRequest("{}", secret, testNow.Add(3*time.Minute))); err != nil {
+		t.Fatal(err)
+	}
+	// The signatures from 4 and 3 minutes before testNow have left the window.
+	if len(v.seen) != 3 || len(v.expiry) != 3 || !v.expiry[0].signedAt.Equal(testNow) {
+		t.Errorf("Expected three signatures remembered, the oldest from %v, got %d: %v", testNow, len(v.seen), v.expiry)
+	}
+}
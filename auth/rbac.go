@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Role is a caller's level of access. Each role includes the permissions of the roles
+// before it.
+type Role int
+
+// Roles in increasing order of privilege.
+const (
+	// RoleReader may resolve queries and read recipes and feeds.
+	RoleReader Role = iota + 1
+	// RoleContributor may additionally submit recipes for review.
+	RoleContributor
+	// RoleCurator may additionally modify the shared corpus.
+	RoleCurator
+	// RoleAdmin may additionally use the /admin endpoints.
+	RoleAdmin
+)
+
+var roleNames = map[Role]string{
+	RoleReader:      "reader",
+	RoleContributor: "contributor",
+	RoleCurator:     "curator",
+	RoleAdmin:       "admin",
+}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+// Allows reports whether r grants the permissions of required.
+func (r Role) Allows(required Role) bool {
+	return r >= required
+}
+
+// ParseRole parses a role name such as "curator".
+func ParseRole(s string) (Role, error) {
+	for role, name := range roleNames {
+		if strings.EqualFold(strings.TrimSpace(s), name) {
+			return role, nil
+		}
+	}
+	return 0, fmt.Errorf("auth: unknown role %q", s)
+}
+
+// Principal is an authenticated caller.
+type Principal struct {
+	// Name identifies the caller in logs, e.g. the name of an API key.
+	Name string
+	Role Role
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal stored in ctx by WithPrincipal.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// ErrNoCredentials is returned (possibly wrapped) by an authenticator when a request carries
+// none of the credentials it understands, as opposed to carrying invalid ones.
+var ErrNoCredentials = errors.New("auth: no credentials")
+
+// ErrBadAPIKey is returned by APIKeys.Authenticate for an unknown key.
+var ErrBadAPIKey = errors.New("auth: invalid API key")
+
+// APIKeys authenticates requests bearing a static API key, either as "Authorization: Bearer
+// <key>" or in the X-API-Key header. Each key maps to the principal it authenticates.
+type APIKeys map[string]Principal
+
+// ParseAPIKeys parses a comma-separated list of name:role:key entries, e.g.
+// "ci:reader:k1,editors:curator:k2".
+func ParseAPIKeys(s string) (APIKeys, error) {
+	keys := make(APIKeys)
+	for i, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		// Errors name the entry by position; the entry itself may contain a key.
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("auth: API key entry %d is not name:role:key", i+1)
+		}
+		role, err := ParseRole(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		keys[parts[2]] = Principal{Name: parts[0], Role: role}
+	}
+	return keys, nil
+}
+
+// Authenticate implements the server's Authenticator interface.
+func (k APIKeys) Authenticate(r *http.Request) (Principal, error) {
+	given := r.Header.Get("X-API-Key")
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		given = bearer
+	}
+	if given == "" {
+		return Principal{}, ErrNoCredentials
+	}
+	// Compare against every key in constant time so that timing does not reveal key prefixes.
+	var found Principal
+	match := 0
+	for key, p := range k {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(given)) == 1 {
+			found, match = p, 1
+		}
+	}
+	if match == 0 {
+		return Principal{}, ErrBadAPIKey
+	}
+	return found, nil
+}
+
+// Authenticator is implemented by APIKeys and HMACVerifier.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Principal, error)
+}
+
+// Any combines authenticators: a request is authenticated by the first one whose credentials
+// it carries.
+func Any(authenticators ...Authenticator) Authenticator {
+	return anyAuthenticator(authenticators)
+}
+
+type anyAuthenticator []Authenticator
+
+func (a anyAuthenticator) Authenticate(r *http.Request) (Principal, error) {
+	for _, auth := range a {
+		p, err := auth.Authenticate(r)
+		if !errors.Is(err, ErrNoCredentials) {
+			return p, err
+		}
+	}
+	return Principal{}, ErrNoCredentials
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseAPIKeys(t *testing.T) {
+	keys, err := ParseAPIKeys("ci:reader:k1, editors:Curator:k2:with:colons")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := keys["k1"]; got != (Principal{Name: "ci", Role: RoleReader}) {
+		t.Errorf("Expected ci/reader for k1, got %+v", got)
+	}
+	if got := keys["k2:with:colons"]; got != (Principal{Name: "editors", Role: RoleCurator}) {
+		t.Errorf("Expected editors/curator for k2, got %+v", got)
+	}
+
+	for _, bad := range []string{"justakey", "ci:owner:k1", ":reader:k1"} {
+		if _, err := ParseAPIKeys(bad); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestRoleAllows(t *testing.T) {
+	if !RoleAdmin.Allows(RoleCurator) || !RoleCurator.Allows(RoleCurator) {
+		t.Error("Expected higher or equal roles to be allowed")
+	}
+	if RoleContributor.Allows(RoleCurator) {
+		t.Error("Expected contributor not to have curator permissions")
+	}
+}
+
+func TestAnyAuthenticator(t *testing.T) {
+	keys := APIKeys{"k1": {Name: "ci", Role: RoleReader}}
+	a := Any(NewHMACVerifier([]byte("secret")), keys)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-API-Key", "k1")
+	if p, err := a.Authenticate(r); err != nil || p.Name != "ci" {
+		t.Errorf("Expected the API key to authenticate ci, got %+v, %v", p, err)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-API-Key", "wrong")
+	if _, err := a.Authenticate(r); !errors.Is(err, ErrBadAPIKey) {
+		t.Errorf("Expected %v, got %v", ErrBadAPIKey, err)
+	}
+
+	if _, err := a.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil)); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("Expected %v, got %v", ErrNoCredentials, err)
+	}
+}
@@ -0,0 +1,289 @@
+// Package cache provides the bounded in-process cache shared by the service's caches: entries
+// are limited by count and approximate size in bytes, evicted least recently or least
+// frequently used first, optionally expire, and are counted in Stats.
+package cache
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Policy selects which entry is evicted when a cache is full.
+type Policy int
+
+const (
+	// LRU evicts the least recently used entry.
+	LRU Policy = iota
+	// LFU evicts the least frequently used entry, the least recently used among equals.
+	LFU
+)
+
+// ParsePolicy parses "lru" or "lfu".
+func ParsePolicy(s string) (Policy, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "lru":
+		return LRU, nil
+	case "lfu":
+		return LFU, nil
+	}
+	return LRU, fmt.Errorf("unknown cache policy %q (want lru or lfu)", s)
+}
+
+// String returns "lru" or "lfu".
+func (p Policy) String() string {
+	if p == LFU {
+		return "lfu"
+	}
+	return "lru"
+}
+
+// entryOverhead approximates the bytes an entry takes besides its key and value.
+const entryOverhead = 96
+
+// Options bounds a Cache. Zero bounds are unlimited.
+type Options struct {
+	// MaxBytes bounds the approximate size of the entries, as measured by the Cache's size
+	// function plus the keys and a fixed overhead per entry.
+	MaxBytes int64
+	// MaxEntries bounds the number of entries.
+	MaxEntries int
+	Policy     Policy
+	// TTL, if positive, is how long an entry is served after it was set.
+	TTL time.Duration
+	// Now returns the current time; it defaults to time.Now.
+	Now func() time.Time
+}
+
+// Stats reports the state and activity of a Cache.
+type Stats struct {
+	Entries  int    `json:"entries"`
+	Bytes    int64  `json:"bytes"`
+	MaxBytes int64  `json:"max_bytes,omitempty"`
+	Policy   string `json:"policy"`
+	Hits     uint64 `json:"hits"`
+	Misses   uint64 `json:"misses"`
+	// Evictions counts entries removed to respect the bounds, Expirations those removed
+	// because their TTL passed.
+	Evictions   uint64 `json:"evictions"`
+	Expirations uint64 `json:"expirations"`
+}
+
+type entry[V any] struct {
+	key     string
+	value   V
+	size    int64
+	expires time.Time
+	uses    uint64
+	tick    uint64 // when the entry was last used, in operations
+	index   int    // position in the eviction heap
+}
+
+// Cache is a bounded map from string keys to values of type V. It is safe for concurrent use.
+type Cache[V any] struct {
+	opts Options
+	size func(V) int64
+
+	mu      sync.Mutex
+	entries map[string]*entry[V]
+	order   evictionHeap[V]
+	tick    uint64
+	bytes   int64
+	stats   Stats
+}
+
+// New returns an empty cache bounded by opts. size returns the approximate size of a value in
+// bytes; if nil, values count as zero bytes and only keys and overhead are measured.
+func New[V any](opts Options, size func(V) int64) *Cache[V] {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	c := &Cache[V]{opts: opts, size: size, entries: make(map[string]*entry[V])}
+	c.order.policy = opts.Policy
+	return c
+}
+
+// Get returns the value stored under key, if any and not expired, and marks it used.
+func (c *Cache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if ok && c.expired(e) {
+		c.remove(e)
+		c.stats.Expirations++
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		var zero V
+		return zero, false
+	}
+	c.stats.Hits++
+	c.touch(e)
+	return e.value, true
+}
+
+// Miss counts a lookup that found nothing without calling Get, such as a search with Range.
+func (c *Cache[V]) Miss() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.Misses++
+}
+
+// Set stores value under key, replacing any previous value, and evicts entries as needed to
+// respect the bounds. A value larger than MaxBytes on its own is not stored.
+func (c *Cache[V]) Set(key string, value V) {
+	size := int64(len(key)) + entryOverhead
+	if c.size != nil {
+		size += c.size(value)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.remove(e)
+	}
+	if c.opts.MaxBytes > 0 && size > c.opts.MaxBytes {
+		return
+	}
+	e := &entry[V]{key: key, value: value, size: size}
+	if c.opts.TTL > 0 {
+		e.expires = c.opts.Now().Add(c.opts.TTL)
+	}
+	c.entries[key] = e
+	c.bytes += size
+	c.tick++
+	e.tick = c.tick
+	// The new entry joins the eviction order only afterwards, so that it is never evicted
+	// itself, as it would be under LFU with no uses yet.
+	for c.over() && c.order.Len() > 0 {
+		victim := c.order.entries[0]
+		c.remove(victim)
+		if c.expired(victim) {
+			c.stats.Expirations++
+		} else {
+			c.stats.Evictions++
+		}
+	}
+	heap.Push(&c.order, e)
+}
+
+// Delete removes the entry under key and reports whether there was one.
+func (c *Cache[V]) Delete(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if ok {
+		c.remove(e)
+	}
+	return ok
+}
+
+// DeleteFunc removes the entries del returns true for and returns how many were removed.
+func (c *Cache[V]) DeleteFunc(del func(key string, value V) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for key, e := range c.entries {
+		if del(key, e.value) {
+			c.remove(e)
+			n++
+		}
+	}
+	return n
+}
+
+// Range calls f for each entry that has not expired, in no particular order, until f returns
+// false. It does not mark entries used; f must not call other methods of c.
+func (c *Cache[V]) Range(f func(key string, value V) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, e := range c.entries {
+		if !c.expired(e) && !f(key, e.value) {
+			return
+		}
+	}
+}
+
+// Flush removes every entry and returns how many were removed.
+func (c *Cache[V]) Flush() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(c.entries)
+	c.entries = make(map[string]*entry[V])
+	c.order.entries = nil
+	c.bytes = 0
+	return n
+}
+
+// Len returns the number of entries, including any that expired but were not removed yet.
+func (c *Cache[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Stats returns the cache's current size and its activity so far.
+func (c *Cache[V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries, s.Bytes, s.MaxBytes, s.Policy = len(c.entries), c.bytes, c.opts.MaxBytes, c.opts.Policy.String()
+	return s
+}
+
+func (c *Cache[V]) over() bool {
+	return (c.opts.MaxBytes > 0 && c.bytes > c.opts.MaxBytes || c.opts.MaxEntries > 0 && len(c.entries) > c.opts.MaxEntries)
+}
+
+func (c *Cache[V]) expired(e *entry[V]) bool {
+	return !e.expires.IsZero() && !c.opts.Now().Before(e.expires)
+}
+
+func (c *Cache[V]) touch(e *entry[V]) {
+	c.tick++
+	e.tick = c.tick
+	e.uses++
+	heap.Fix(&c.order, e.index)
+}
+
+func (c *Cache[V]) remove(e *entry[V]) {
+	heap.Remove(&c.order, e.index)
+	delete(c.entries, e.key)
+	c.bytes -= e.size
+}
+
+// evictionHeap orders entries with the next to evict first.
+type evictionHeap[V any] struct {
+	policy  Policy
+	entries []*entry[V]
+}
+
+func (h *evictionHeap[V]) Len() int { return len(h.entries) }
+
+func (h *evictionHeap[V]) Less(i, j int) bool {
+	a, b := h.entries[i], h.entries[j]
+	if h.policy == LFU && a.uses != b.uses {
+		return a.uses < b.uses
+	}
+	return a.tick < b.tick
+}
+
+func (h *evictionHeap[V]) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.entries[i].index, h.entries[j].index = i, j
+}
+
+func (h *evictionHeap[V]) Push(x any) {
+	e := x.(*entry[V])
+	e.index = len(h.entries)
+	h.entries = append(h.entries, e)
+}
+
+func (h *evictionHeap[V]) Pop() any {
+	e := h.entries[len(h.entries)-1]
+	h.entries[len(h.entries)-1] = nil
+	h.entries = h.entries[:len(h.entries)-1]
+	return e
+}
@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+// strlen sizes string values by their length.
+func strlen(s string) int64 { return int64(len(s)) }
+
+func TestLRU(t *testing.T) {
+	// Room for three one-letter keys with 100-byte values.
+	c := New(Options{MaxBytes: 3 * (1 + 100 + entryOverhead)}, strlen)
+	value := string(make([]byte, 100))
+	for _, k := range []string{"a", "b", "c"} {
+		c.Set(k, value)
+	}
+	c.Get("a")
+	c.Set("d", value)
+	if _, ok := c.Get("b"); ok {
+		t.Error("Expected the least recently used entry to be evicted")
+	}
+	for _, k := range []string{"a", "c", "d"} {
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("Expected %q to be kept", k)
+		}
+	}
+	s := c.Stats()
+	if s.Entries != 3 || s.Bytes != 3*(1+100+entryOverhead) || s.Evictions != 1 || s.Hits != 4 || s.Misses != 1 || s.Policy != "lru" {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+
+	c.Set("huge", string(make([]byte, 1000)))
+	if _, ok := c.Get("huge"); ok || c.Len() != 3 {
+		t.Error("Expected a value larger than the cache not to be stored or evict others")
+	}
+}
+
+func TestLFU(t *testing.T) {
+	c := New[string](Options{MaxEntries: 2, Policy: LFU}, nil)
+	c.Set("popular", "x")
+	c.Set("rare", "y")
+	c.Get("popular")
+	c.Get("popular")
+	c.Get("rare")
+	c.Set("new", "z")
+	if _, ok := c.Get("rare"); ok {
+		t.Error("Expected the least frequently used entry to be evicted")
+	}
+	if _, ok := c.Get("new"); !ok {
+		t.Error("Expected the new entry to be kept although it has no uses yet")
+	}
+	c.Set("newer", "w")
+	if _, ok := c.Get("popular"); !ok {
+		t.Error("Expected the most frequently used entry to be kept")
+	}
+}
+
+func TestTTL(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := New[string](Options{TTL: time.Hour, Now: func() time.Time { return now }}, nil)
+	c.Set("soup", "tomato")
+	now = now.Add(59 * time.Minute)
+	if _, ok := c.Get("soup"); !ok {
+		t.Fatal("Expected the entry to be served within its TTL")
+	}
+	now = now.Add(time.Minute)
+	if _, ok := c.Get("soup"); ok {
+		t.Error("Expected the entry to expire after its TTL")
+	}
+	if s := c.Stats(); s.Expirations != 1 || s.Entries != 0 {
+		t.Errorf("Expected one expiration and no entries, got %+v", s)
+	}
+}
+
+func TestDeleteFunc(t *testing.T) {
+	c := New[int](Options{}, nil)
+	for i, k := range []string{"a", "b", "c"} {
+		c.Set(k, i)
+	}
+	if n := c.DeleteFunc(func(_ string, v int) bool { return v > 0 }); n != 2 || c.Len() != 1 {
+		t.Errorf("Expected 2 entries deleted and 1 left, got %d and %d", n, c.Len())
+	}
+	if !c.Delete("a") || c.Delete("a") {
+		t.Error("Expected Delete to report whether the entry existed")
+	}
+	c.Set("d", 4)
+	if n := c.Flush(); n != 1 || c.Stats().Bytes != 0 {
+		t.Errorf("Expected Flush to empty the cache, got %d removed and %+v", n, c.Stats())
+	}
+}
@@ -0,0 +1,791 @@
+// Package client is a Go SDK for the recipe resolver microservice. Other services should use
+// it instead of hand-rolling HTTP calls so that retries, timeouts and error decoding behave
+// consistently across the platform.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/audit"
+	"github.com/pageza/recipe-resolver-ms/auth"
+	"github.com/pageza/recipe-resolver-ms/cache"
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/nutrition"
+	"github.com/pageza/recipe-resolver-ms/report"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+	"github.com/pageza/recipe-resolver-ms/schedule"
+	"github.com/pageza/recipe-resolver-ms/shopping"
+)
+
+// Options configures a Client. The zero value is usable.
+type Options struct {
+	// HTTPClient performs the requests. Defaults to a client with a 90 second timeout,
+	// matching the upper bound of an LLM generation on the server side.
+	HTTPClient *http.Client
+	// MaxRetries is the number of additional attempts made after a retryable failure
+	// (network errors, 429 and 5xx responses). Defaults to 2; use a negative value to disable.
+	MaxRetries int
+	// Backoff is the delay before the first retry. It doubles on each subsequent retry.
+	// Defaults to 200ms.
+	Backoff time.Duration
+	// HMACSecret, if set, signs every request with auth.Sign for services that require
+	// HMAC-authenticated calls.
+	HMACSecret []byte
+	// APIKey, if set, is sent as a bearer token on every request.
+	APIKey string
+	// Locale, if set, is sent as the Accept-Language header so that the service formats
+	// numbers and measurements for it, e.g. "de-DE".
+	Locale string
+	// Batch, if set, marks every request as batch traffic with the X-Priority header, so that
+	// a loaded service throttles it before user-facing requests.
+	Batch bool
+}
+
+// Client calls the resolver service over HTTP. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	maxRetries int
+	backoff    time.Duration
+	hmacSecret []byte
+	apiKey     string
+	locale     string
+	batch      bool
+}
+
+// New returns a Client for the service rooted at baseURL (e.g. "http://resolver:3000").
+func New(baseURL string, opts Options) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: opts.HTTPClient,
+		maxRetries: opts.MaxRetries,
+		backoff:    opts.Backoff,
+		hmacSecret: opts.HMACSecret,
+		apiKey:     opts.APIKey,
+		locale:     opts.Locale,
+		batch:      opts.Batch,
+	}
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{Timeout: 90 * time.Second}
+	}
+	if c.maxRetries == 0 {
+		c.maxRetries = 2
+	} else if c.maxRetries < 0 {
+		c.maxRetries = 0
+	}
+	if c.backoff <= 0 {
+		c.backoff = 200 * time.Millisecond
+	}
+	return c
+}
+
+// APIError is returned when the service responds with a non-2xx status.
+type APIError struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is the delay the service asked for with a Retry-After header, if any.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("resolver: HTTP %d: %s", e.StatusCode, e.Message)
+}
+
+// ResolveResult is the decoded response of a resolve call.
+type ResolveResult struct {
+	PrimaryRecipe      model.Recipe   `json:"primary_recipe"`
+	AlternativeRecipes []model.Recipe `json:"alternative_recipes"`
+	// SemanticCache is set when the recipes were generated for a near-identical earlier query.
+	SemanticCache *SemanticCacheHit `json:"semantic_cache,omitempty"`
+	// Experiments lists the experiment variants the request was assigned to, as
+	// "experiment=variant".
+	Experiments []string `json:"experiments,omitempty"`
+	// Degraded is set when the service was in degraded mode and returned its best stored
+	// match instead of generating a recipe.
+	Degraded bool `json:"degraded,omitempty"`
+	// Description is the dish recognized in the photo of a ResolveImage call.
+	Description string `json:"description,omitempty"`
+}
+
+// BatchProgress reports the progress of ResolveBatch after each query.
+type BatchProgress struct {
+	// Done counts the queries resolved or failed so far, out of Total.
+	Done, Total int
+	Query       string
+	// Err is the error of Query, if it failed.
+	Err error
+	// Throttled counts the 429 responses waited out for Query.
+	Throttled int
+}
+
+// ResolveBatch resolves queries one after another, as batch traffic whatever the client's
+// options. Responses asking it to slow down (429) are waited out, for as long as their
+// Retry-After header says or the client's backoff, however many there are, so that a large
+// import slows down under load rather than failing midway; other failures are retried as usual
+// and then recorded. progress, if non-nil, is called after each query. The results are in the
+// order of queries, nil for those that failed; the error is that of the first failure, or
+// ctx.Err() if ctx ends first.
+func (c *Client) ResolveBatch(ctx context.Context, queries []string, progress func(BatchProgress)) ([]*ResolveResult, error) {
+	batch := *c
+	batch.batch = true
+	results := make([]*ResolveResult, len(queries))
+	var firstErr error
+	for i, q := range queries {
+		p := BatchProgress{Done: i + 1, Total: len(queries), Query: q}
+		for {
+			results[i], p.Err = batch.Resolve(ctx, q)
+			var apiErr *APIError
+			if !errors.As(p.Err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+				break
+			}
+			p.Throttled++
+			delay := apiErr.RetryAfter
+			if delay <= 0 {
+				delay = c.backoff
+			}
+			select {
+			case <-ctx.Done():
+				return results, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+		if p.Err != nil && firstErr == nil {
+			firstErr = p.Err
+		}
+		if progress != nil {
+			progress(p)
+		}
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+	}
+	return results, firstErr
+}
+
+// Menu is the decoded response of a menu call.
+type Menu struct {
+	Courses []MenuCourse `json:"courses"`
+	// ShoppingList combines the ingredients of every course.
+	ShoppingList []shopping.Item `json:"shopping_list"`
+	// Degraded is set when the service was in degraded mode and courses without a stored
+	// recipe got placeholders.
+	Degraded bool `json:"degraded,omitempty"`
+}
+
+// MenuCourse is a course of a Menu.
+type MenuCourse struct {
+	Course string       `json:"course"`
+	Recipe model.Recipe `json:"recipe"`
+	// Match is "close" for a stored recipe, "generated" or "semantic" for a generated one, and
+	// "fallback" when generation failed.
+	Match resolver.MatchKind `json:"match"`
+}
+
+// SemanticCacheHit reports how similar a query was to the earlier one whose generation served it.
+type SemanticCacheHit struct {
+	Similarity float64 `json:"similarity"`
+}
+
+// Resolve asks the service for the recipe best matching query.
+func (c *Client) Resolve(ctx context.Context, query string) (*ResolveResult, error) {
+	var res ResolveResult
+	if err := c.do(ctx, http.MethodPost, "/resolve", map[string]string{"query": query}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ResolveStream asks the service for the recipes best matching query as newline-delimited JSON,
+// calling fn with each as it arrives, the primary recipe first, then the alternatives. The
+// request is retried as Resolve's is until the first recipe arrives, but not after. Resolution
+// details such as Degraded are not streamed; use Resolve for them. It returns fn's first error,
+// which stops the stream.
+func (c *Client) ResolveStream(ctx context.Context, query string, fn func(model.Recipe) error) error {
+	body, err := encodeBody(map[string]string{"query": query})
+	if err != nil {
+		return err
+	}
+	var resp *http.Response
+	err = c.retry(ctx, func() (err error) {
+		resp, err = c.send(ctx, http.MethodPost, "/resolve?format=ndjson", body, "application/x-ndjson")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var rec model.Recipe
+		if err := dec.Decode(&rec); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// ResolveImage asks the service for the recipe of the dish in a photo: a JPEG, PNG, GIF or WebP
+// of up to 8 MiB.
+func (c *Client) ResolveImage(ctx context.Context, image []byte) (*ResolveResult, error) {
+	var res ResolveResult
+	if err := c.do(ctx, http.MethodPost, "/resolve/image", map[string][]byte{"image": image}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Menu resolves a menu for an occasion, e.g. "summer garden party". courses lists the courses
+// to serve from model.Courses, all of them if none is given.
+func (c *Client) Menu(ctx context.Context, occasion string, courses ...string) (*Menu, error) {
+	var res Menu
+	in := struct {
+		Query   string   `json:"query"`
+		Courses []string `json:"courses,omitempty"`
+	}{occasion, courses}
+	if err := c.do(ctx, http.MethodPost, "/resolve/menu", in, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// RecipeBySlug fetches the stored recipe with the given slug.
+func (c *Client) RecipeBySlug(ctx context.Context, slug string) (*model.Recipe, error) {
+	var res model.Recipe
+	if err := c.do(ctx, http.MethodGet, "/recipes/slug/"+url.PathEscape(slug), nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// GetRecipe fetches the stored recipe with the given ID, or the one it was merged into.
+func (c *Client) GetRecipe(ctx context.Context, id string) (*model.Recipe, error) {
+	var res model.Recipe
+	if err := c.do(ctx, http.MethodGet, "/recipes/"+url.PathEscape(id), nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// CreateRecipe adds rec to the service's store as a published recipe, under a new ID, and
+// returns it as stored.
+func (c *Client) CreateRecipe(ctx context.Context, rec model.Recipe) (*model.Recipe, error) {
+	var res model.Recipe
+	if err := c.do(ctx, http.MethodPost, "/recipes", rec, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// UpdateRecipe replaces the content of the stored recipe with rec's ID and returns it as stored.
+// Its creation time, review status and archiving are kept.
+func (c *Client) UpdateRecipe(ctx context.Context, rec model.Recipe) (*model.Recipe, error) {
+	var res model.Recipe
+	if err := c.do(ctx, http.MethodPut, "/recipes/"+url.PathEscape(rec.ID), rec, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// DeleteRecipe deletes the stored recipe with the given ID.
+func (c *Client) DeleteRecipe(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/recipes/"+url.PathEscape(id), nil, nil)
+}
+
+// RecipeFilter selects the recipes listed by ListRecipes. Zero fields match everything.
+type RecipeFilter struct {
+	// Appliances and Tags keep the recipes using every appliance and carrying every tag given.
+	Appliances []string
+	Tags       []string
+	// Statuses keeps the recipes in one of these review statuses, and Archived, if set, those
+	// archived or not. Only curators list unpublished and archived recipes; the service refuses
+	// other callers asking for them.
+	Statuses []model.Status
+	Archived *bool
+	// Limit is the page size, 20 by default and up to 100; Offset is the number of recipes
+	// skipped.
+	Limit  int
+	Offset int
+}
+
+// RecipePage is a page of the recipes listed by ListRecipes.
+type RecipePage struct {
+	Recipes []model.Recipe `json:"recipes"`
+	// Total is the number of recipes matching the filter, across all pages.
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// ListRecipes lists a page of the stored recipes matching f, in the order they were added.
+func (c *Client) ListRecipes(ctx context.Context, f RecipeFilter) (*RecipePage, error) {
+	q := url.Values{"appliance": f.Appliances, "tag": f.Tags}
+	for _, st := range f.Statuses {
+		q.Add("status", string(st))
+	}
+	if f.Archived != nil {
+		q.Set("archived", strconv.FormatBool(*f.Archived))
+	}
+	if f.Limit > 0 {
+		q.Set("limit", strconv.Itoa(f.Limit))
+	}
+	if f.Offset > 0 {
+		q.Set("offset", strconv.Itoa(f.Offset))
+	}
+	path := "/recipes"
+	if params := q.Encode(); params != "" {
+		path += "?" + params
+	}
+	var res RecipePage
+	if err := c.do(ctx, http.MethodGet, path, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Search returns up to limit stored recipes matching the words of query, best first. A limit of
+// 0 leaves it to the service. Unless the client authenticates as a curator, only the recipes the
+// service serves are searched.
+func (c *Client) Search(ctx context.Context, query string, limit int) ([]model.Recipe, error) {
+	q := url.Values{"q": {query}}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var res struct {
+		Recipes []model.Recipe `json:"recipes"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/recipes/search?"+q.Encode(), nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Recipes, nil
+}
+
+// Ingredient describes an ingredient from the service's taxonomy.
+type Ingredient struct {
+	Name        string   `json:"name"`
+	Ancestors   []string `json:"ancestors"`
+	Allergens   []string `json:"allergens"`
+	Substitutes []string `json:"substitutes"`
+}
+
+// Ingredient looks up an ingredient by name or alias, suggesting substitutes without any of the
+// avoid allergens.
+func (c *Client) Ingredient(ctx context.Context, name string, avoid ...string) (*Ingredient, error) {
+	path := "/ingredients/" + url.PathEscape(name)
+	if len(avoid) > 0 {
+		path += "?" + url.Values{"avoid": {strings.Join(avoid, ",")}}.Encode()
+	}
+	var res Ingredient
+	if err := c.do(ctx, http.MethodGet, path, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// BatchDeleteResult reports the outcome of BatchDelete.
+type BatchDeleteResult struct {
+	Action   string   `json:"action"`
+	DryRun   bool     `json:"dry_run"`
+	Matched  []string `json:"matched"`
+	Affected int      `json:"affected"`
+}
+
+// BatchDelete deletes, or with archive set archives, every stored recipe matching f, which
+// must have at least one criterion. With dryRun set it only reports the matching recipes.
+func (c *Client) BatchDelete(ctx context.Context, f model.Filter, archive, dryRun bool) (*BatchDeleteResult, error) {
+	req := struct {
+		Filter  model.Filter `json:"filter"`
+		Archive bool         `json:"archive,omitempty"`
+		DryRun  bool         `json:"dry_run,omitempty"`
+	}{f, archive, dryRun}
+	var res BatchDeleteResult
+	if err := c.do(ctx, http.MethodPost, "/recipes/batch-delete", req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// SubmitRecipe adds rec to the service's store pending review and returns it as stored.
+func (c *Client) SubmitRecipe(ctx context.Context, rec model.Recipe) (*model.Recipe, error) {
+	var res model.Recipe
+	if err := c.do(ctx, http.MethodPost, "/recipes/review", rec, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ImportImage transcribes the recipe in a photo of a recipe card or cookbook page and adds it to
+// the service's store pending review, crediting credit. It returns the recipe as stored.
+func (c *Client) ImportImage(ctx context.Context, image []byte, credit model.Attribution) (*model.Recipe, error) {
+	var res model.Recipe
+	in := struct {
+		Image       []byte            `json:"image"`
+		Attribution model.Attribution `json:"attribution"`
+	}{image, credit}
+	if err := c.do(ctx, http.MethodPost, "/recipes/import-image", in, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ReviewQueue lists the recipes pending review, oldest first.
+func (c *Client) ReviewQueue(ctx context.Context) ([]model.Recipe, error) {
+	var res struct {
+		Recipes []model.Recipe `json:"recipes"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/recipes/review", nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Recipes, nil
+}
+
+// Review decides on the recipe pending review with the given ID: decision is "approve",
+// "reject" or "edit". edits, if non-nil, replaces the recipe's content; note is recorded with
+// the decision.
+func (c *Client) Review(ctx context.Context, id, decision string, edits *model.Recipe, note string) (*model.Recipe, error) {
+	req := struct {
+		Decision string        `json:"decision"`
+		Recipe   *model.Recipe `json:"recipe,omitempty"`
+		Note     string        `json:"note,omitempty"`
+	}{decision, edits, note}
+	var res model.Recipe
+	if err := c.do(ctx, http.MethodPost, "/recipes/"+url.PathEscape(id)+"/review", req, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Report flags the recipe with the given ID as bad for reason ("wrong_quantities", "unsafe",
+// "spam" or "other") and returns the number of open reports on it.
+func (c *Client) Report(ctx context.Context, id, reason, comment string) (int, error) {
+	req := struct {
+		Reason  string `json:"reason"`
+		Comment string `json:"comment,omitempty"`
+	}{reason, comment}
+	var res struct {
+		Reports int `json:"reports"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/recipes/"+url.PathEscape(id)+"/report", req, &res); err != nil {
+		return 0, err
+	}
+	return res.Reports, nil
+}
+
+// Reports lists the open reports grouped by recipe, most reported first.
+func (c *Client) Reports(ctx context.Context) ([]report.Summary, error) {
+	var res struct {
+		Recipes []report.Summary `json:"recipes"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/admin/reports", nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Recipes, nil
+}
+
+// TriageReports closes the open reports on the recipe with the given ID: resolution is
+// "dismiss", "unpublish" or "reject", and note is recorded on an unpublished or rejected recipe.
+// It returns the number of reports closed.
+func (c *Client) TriageReports(ctx context.Context, id, resolution, note string) (int, error) {
+	req := struct {
+		Resolution string `json:"resolution"`
+		Note       string `json:"note,omitempty"`
+	}{resolution, note}
+	var res struct {
+		Resolved int `json:"resolved"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/admin/reports/"+url.PathEscape(id), req, &res); err != nil {
+		return 0, err
+	}
+	return res.Resolved, nil
+}
+
+// FlushCache drops every cached generation on the service and returns how many were removed.
+func (c *Client) FlushCache(ctx context.Context) (int, error) {
+	var res struct {
+		Flushed int `json:"flushed"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/admin/cache/flush", nil, &res); err != nil {
+		return 0, err
+	}
+	return res.Flushed, nil
+}
+
+// Reindex rebuilds the search index of the service's recipe store and returns the number of
+// recipes indexed. Stores that do not derive their index themselves answer 501.
+func (c *Client) Reindex(ctx context.Context) (int, error) {
+	var res struct {
+		Reindexed int `json:"reindexed"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/admin/reindex", nil, &res); err != nil {
+		return 0, err
+	}
+	return res.Reindexed, nil
+}
+
+// ExportRecipes writes every stored recipe to w in format, "ndjson" (the default, a recipe per
+// line) or "csv", as the service streams them. The request is retried as others are until the
+// export starts, but not after.
+func (c *Client) ExportRecipes(ctx context.Context, format string, w io.Writer) error {
+	path := "/recipes/export"
+	if format != "" {
+		path += "?" + url.Values{"format": {format}}.Encode()
+	}
+	var resp *http.Response
+	err := c.retry(ctx, func() (err error) {
+		resp, err = c.send(ctx, http.MethodGet, path, nil, "*/*")
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// MatchMetrics reports how the service's queries matched the stored recipes: the distribution
+// of their best similarity scores and the counts by match kind and threshold band.
+type MatchMetrics struct {
+	// Threshold and NearMargin define the bands the queries were counted in.
+	Threshold  float64 `json:"threshold"`
+	NearMargin float64 `json:"near_margin"`
+	resolver.MatchStats
+}
+
+// MatchMetrics fetches the service's match metrics.
+func (c *Client) MatchMetrics(ctx context.Context) (*MatchMetrics, error) {
+	var res MatchMetrics
+	if err := c.do(ctx, http.MethodGet, "/admin/metrics/matches", nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// CacheMetrics fetches the statistics of the service's in-process caches by name, e.g.
+// "results".
+func (c *Client) CacheMetrics(ctx context.Context) (map[string]cache.Stats, error) {
+	var res struct {
+		Caches map[string]cache.Stats `json:"caches"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/admin/metrics/caches", nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Caches, nil
+}
+
+// AuditFilter selects entries returned by Audit. Zero fields match everything.
+type AuditFilter struct {
+	Actor  string
+	Action string
+	Target string
+	Since  time.Time
+	Limit  int
+}
+
+// Audit lists the service's audit entries matching f, newest first.
+func (c *Client) Audit(ctx context.Context, f AuditFilter) ([]audit.Entry, error) {
+	q := url.Values{}
+	for k, v := range map[string]string{"actor": f.Actor, "action": f.Action, "target": f.Target} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	if !f.Since.IsZero() {
+		q.Set("since", f.Since.Format(time.RFC3339))
+	}
+	if f.Limit > 0 {
+		q.Set("limit", strconv.Itoa(f.Limit))
+	}
+	path := "/admin/audit"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	var res struct {
+		Entries []audit.Entry `json:"entries"`
+	}
+	if err := c.do(ctx, http.MethodGet, path, nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Entries, nil
+}
+
+// BackfillNutrition starts a background run on the service that estimates nutrition for every
+// stored recipe lacking structured nutritional info, and returns its initial progress.
+func (c *Client) BackfillNutrition(ctx context.Context) (nutrition.Progress, error) {
+	var res nutrition.Progress
+	err := c.do(ctx, http.MethodPost, "/admin/nutrition/backfill", nil, &res)
+	return res, err
+}
+
+// NutritionBackfillProgress reports the progress of the service's current or most recent
+// nutrition backfill.
+func (c *Client) NutritionBackfillProgress(ctx context.Context) (nutrition.Progress, error) {
+	var res nutrition.Progress
+	err := c.do(ctx, http.MethodGet, "/admin/nutrition/backfill", nil, &res)
+	return res, err
+}
+
+// Degraded reports whether the service is in degraded mode.
+func (c *Client) Degraded(ctx context.Context) (resolver.DegradationStatus, error) {
+	var res resolver.DegradationStatus
+	err := c.do(ctx, http.MethodGet, "/admin/degraded", nil, &res)
+	return res, err
+}
+
+// SetDegraded switches the service's manual degraded mode on or off.
+func (c *Client) SetDegraded(ctx context.Context, on bool) (resolver.DegradationStatus, error) {
+	var res resolver.DegradationStatus
+	err := c.do(ctx, http.MethodPost, "/admin/degraded", map[string]bool{"degraded": on}, &res)
+	return res, err
+}
+
+// Jobs reports the status of the service's scheduled jobs.
+func (c *Client) Jobs(ctx context.Context) ([]schedule.Status, error) {
+	var res struct {
+		Jobs []schedule.Status `json:"jobs"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/admin/jobs", nil, &res); err != nil {
+		return nil, err
+	}
+	return res.Jobs, nil
+}
+
+// RunJob runs the named job on the service now, outside its schedule.
+func (c *Client) RunJob(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodPost, "/admin/jobs/"+url.PathEscape(name)+"/run", nil, nil)
+}
+
+// do sends a JSON request and decodes the JSON response into out, retrying retryable failures
+// with exponential backoff until the context is done.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	body, err := encodeBody(in)
+	if err != nil {
+		return err
+	}
+	return c.retry(ctx, func() error {
+		return c.once(ctx, method, path, body, out)
+	})
+}
+
+// encodeBody returns the JSON encoding of in, or nil if in is nil.
+func encodeBody(in interface{}) ([]byte, error) {
+	if in == nil {
+		return nil, nil
+	}
+	return json.Marshal(in)
+}
+
+// retry calls attempt until it succeeds or fails for good, with exponential backoff between
+// retryable failures, until the context is done.
+func (c *Client) retry(ctx context.Context, attempt func() error) error {
+	delay := c.backoff
+	for n := 0; ; n++ {
+		err := attempt()
+		if err == nil || n >= c.maxRetries || !retryable(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// once performs a single HTTP round-trip.
+func (c *Client) once(ctx context.Context, method, path string, body []byte, out interface{}) error {
+	resp, err := c.send(ctx, method, path, body, "application/json")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// send sends a request accepting the media type accept and returns the response if its status
+// is 2xx, which the caller must close, or the APIError it carries.
+func (c *Client) send(ctx context.Context, method, path string, body []byte, accept string) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", accept)
+	if c.locale != "" {
+		req.Header.Set("Accept-Language", c.locale)
+	}
+	if c.batch {
+		req.Header.Set("X-Priority", "batch")
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	if c.hmacSecret != nil {
+		// Each attempt is signed afresh; the service rejects a reused signature as a replay.
+		auth.Sign(req, body, c.hmacSecret, time.Now())
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, decodeAPIError(resp)
+	}
+	return resp, nil
+}
+
+// decodeAPIError builds an APIError from the service's {"error": "..."} body, falling back
+// to the HTTP status text when the body is not in that shape.
+func decodeAPIError(resp *http.Response) error {
+	var payload struct {
+		Error string `json:"error"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	msg := http.StatusText(resp.StatusCode)
+	if json.Unmarshal(data, &payload) == nil && payload.Error != "" {
+		msg = payload.Error
+	}
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: msg}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		apiErr.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return apiErr
+}
+
+// retryable reports whether a failed attempt may succeed if repeated.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	// Transport-level failures (connection refused, reset, ...) are worth another attempt.
+	return true
+}
@@ -0,0 +1,355 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/auth"
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// TestResolve verifies that Resolve posts the query and decodes the response.
+func TestResolve(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/resolve" {
+			t.Errorf("Expected POST /resolve, got %s %s", r.Method, r.URL.Path)
+		}
+		var req map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req["query"] != "Chicken Salad" {
+			t.Errorf("Expected query 'Chicken Salad', got %v (err %v)", req, err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"primary_recipe":{"id":"r1","title":"Chicken Salad"},"alternative_recipes":[]}`))
+	}))
+	defer srv.Close()
+
+	res, err := New(srv.URL+"/", Options{}).Resolve(context.Background(), "Chicken Salad")
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	if res.PrimaryRecipe.ID != "r1" {
+		t.Errorf("Expected primary recipe ID 'r1', got '%s'", res.PrimaryRecipe.ID)
+	}
+}
+
+// TestResolveStream verifies that ResolveStream retries until the stream starts and calls fn
+// with each recipe, stopping at fn's first error.
+func TestResolveStream(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/resolve" || r.URL.Query().Get("format") != "ndjson" || r.Header.Get("Accept") != "application/x-ndjson" {
+			t.Errorf("Expected an NDJSON POST /resolve, got %s %s (Accept %q)", r.Method, r.URL, r.Header.Get("Accept"))
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte("{\"id\":\"r1\",\"title\":\"Chicken Salad\"}\n{\"id\":\"r2\"}\n{\"id\":\"r3\"}\n"))
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, Options{Backoff: time.Millisecond})
+	var ids []string
+	err := c.ResolveStream(context.Background(), "chicken salad", func(rec model.Recipe) error {
+		ids = append(ids, rec.ID)
+		return nil
+	})
+	if err != nil || strings.Join(ids, ",") != "r1,r2,r3" || calls != 2 {
+		t.Fatalf("Expected the three recipes after a retry, got %v after %d calls (err %v)", ids, calls, err)
+	}
+
+	stop := errors.New("enough")
+	ids = nil
+	err = c.ResolveStream(context.Background(), "chicken salad", func(rec model.Recipe) error {
+		ids = append(ids, rec.ID)
+		return stop
+	})
+	if !errors.Is(err, stop) || len(ids) != 1 {
+		t.Errorf("Expected the stream to stop at fn's error, got %v after %v", err, ids)
+	}
+}
+
+// TestRecipeCRUD verifies the requests of the recipe CRUD and search methods and the decoding of
+// their responses.
+func TestRecipeCRUD(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		var rec model.Recipe
+		switch r.Method {
+		case http.MethodPost, http.MethodPut:
+			if err := json.NewDecoder(r.Body).Decode(&rec); err != nil || rec.Title != "Boiled Eggs" {
+				t.Errorf("Expected the recipe in the body, got %+v (err %v)", rec, err)
+			}
+			rec.ID = "r1"
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case http.MethodGet:
+			if r.URL.Path == "/recipes" {
+				w.Write([]byte(`{"recipes":[{"id":"r1"}],"total":3,"limit":1,"offset":2}`))
+				return
+			}
+			if r.URL.Path == "/recipes/search" {
+				w.Write([]byte(`{"recipes":[{"id":"r1","title":"Boiled Eggs"}]}`))
+				return
+			}
+			rec = model.Recipe{ID: "r1", Title: "Boiled Eggs"}
+		}
+		json.NewEncoder(w).Encode(rec)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := New(srv.URL, Options{})
+	eggs := model.Recipe{Title: "Boiled Eggs", Ingredients: []string{"eggs"}, Steps: []string{"Boil."}}
+	created, err := c.CreateRecipe(ctx, eggs)
+	if err != nil || created.ID != "r1" {
+		t.Fatalf("Expected the created recipe, got %+v (err %v)", created, err)
+	}
+	if got, err := c.GetRecipe(ctx, "r1"); err != nil || got.Title != "Boiled Eggs" {
+		t.Errorf("Expected the recipe, got %+v (err %v)", got, err)
+	}
+	eggs.ID = "r1"
+	if _, err := c.UpdateRecipe(ctx, eggs); err != nil {
+		t.Errorf("UpdateRecipe returned error: %v", err)
+	}
+	if err := c.DeleteRecipe(ctx, "r1"); err != nil {
+		t.Errorf("DeleteRecipe returned error: %v", err)
+	}
+	archived := false
+	page, err := c.ListRecipes(ctx, RecipeFilter{Tags: []string{"breakfast"}, Statuses: []model.Status{model.StatusPendingReview}, Archived: &archived, Limit: 1, Offset: 2})
+	if err != nil || page.Total != 3 || len(page.Recipes) != 1 || page.Offset != 2 {
+		t.Errorf("Expected the second page of one, got %+v (err %v)", page, err)
+	}
+	if found, err := c.Search(ctx, "boiled eggs", 5); err != nil || len(found) != 1 || found[0].Title != "Boiled Eggs" {
+		t.Errorf("Expected the matching recipe, got %+v (err %v)", found, err)
+	}
+
+	want := []string{
+		"POST /recipes", "GET /recipes/r1", "PUT /recipes/r1", "DELETE /recipes/r1",
+		"GET /recipes?archived=false&limit=1&offset=2&status=pending_review&tag=breakfast",
+		"GET /recipes/search?limit=5&q=boiled+eggs",
+	}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected requests %q, got %q", want, requests)
+	}
+}
+
+// TestResolveRetries verifies that 5xx responses are retried and 4xx responses are not.
+func TestResolveRetries(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"primary_recipe":{"id":"r1"}}`))
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, Options{Backoff: time.Millisecond})
+	if _, err := c.Resolve(context.Background(), "soup"); err != nil {
+		t.Fatalf("Expected success after retries, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls)
+	}
+
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"Invalid request."}`))
+	}))
+	defer bad.Close()
+
+	calls = 0
+	_, err := New(bad.URL, Options{Backoff: time.Millisecond}).Resolve(context.Background(), "")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest || apiErr.Message != "Invalid request." {
+		t.Errorf("Expected APIError 400 'Invalid request.', got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected a single attempt for a 400 response, got %d", calls)
+	}
+}
+
+// TestResolveBatch verifies that a batch waits out any number of 429 responses, marks its
+// requests as batch traffic and reports its progress.
+func TestResolveBatch(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("X-Priority") != "batch" {
+			t.Errorf("Expected batch priority, got %q", r.Header.Get("X-Priority"))
+		}
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		switch {
+		case req["query"] == "soup" && calls < 8:
+			w.WriteHeader(http.StatusTooManyRequests)
+		case req["query"] == "":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.Write([]byte(`{"primary_recipe":{"title":"` + req["query"] + `"}}`))
+		}
+	}))
+	defer srv.Close()
+
+	var reports []BatchProgress
+	results, err := New(srv.URL, Options{Backoff: time.Millisecond}).ResolveBatch(context.Background(), []string{"soup", "", "stew"}, func(p BatchProgress) {
+		reports = append(reports, p)
+	})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected the 400 of the empty query, got %v", err)
+	}
+	if len(results) != 3 || results[0].PrimaryRecipe.Title != "soup" || results[1] != nil || results[2].PrimaryRecipe.Title != "stew" {
+		t.Errorf("Unexpected results %+v", results)
+	}
+	if len(reports) != 3 || reports[0].Throttled == 0 || reports[1].Err == nil || reports[2].Done != 3 || reports[2].Total != 3 {
+		t.Errorf("Unexpected progress %+v", reports)
+	}
+}
+
+// TestResolveContextCanceled verifies that a canceled context stops further retries.
+func TestResolveContextCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := New(srv.URL, Options{MaxRetries: 100, Backoff: 50 * time.Millisecond}).Resolve(ctx, "soup")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+// TestFlushCache verifies that FlushCache posts to the admin endpoint and returns the count.
+func TestFlushCache(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/admin/cache/flush" {
+			t.Errorf("Expected POST /admin/cache/flush, got %s %s", r.Method, r.URL.Path)
+		}
+		w.Write([]byte(`{"flushed":4}`))
+	}))
+	defer srv.Close()
+
+	n, err := New(srv.URL, Options{}).FlushCache(context.Background())
+	if err != nil {
+		t.Fatalf("FlushCache returned error: %v", err)
+	}
+	if n != 4 {
+		t.Errorf("Expected 4 flushed entries, got %d", n)
+	}
+}
+
+// TestExportRecipes verifies that ExportRecipes copies the export in the format asked for.
+func TestExportRecipes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/recipes/export" || r.URL.Query().Get("format") != "csv" {
+			t.Errorf("Expected GET /recipes/export?format=csv, got %s %s", r.Method, r.URL)
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Write([]byte("id,title\nr1,Boiled Eggs\n"))
+	}))
+	defer srv.Close()
+
+	var b strings.Builder
+	if err := New(srv.URL, Options{}).ExportRecipes(context.Background(), "csv", &b); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "id,title\nr1,Boiled Eggs\n" {
+		t.Errorf("Unexpected export %q", b.String())
+	}
+}
+
+// TestAdminMetrics verifies the decoding of Reindex, MatchMetrics and CacheMetrics.
+func TestAdminMetrics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /admin/reindex":
+			w.Write([]byte(`{"reindexed":12}`))
+		case "GET /admin/metrics/matches":
+			w.Write([]byte(`{"threshold":0.3,"near_margin":0.1,"requests":5,"by_match":{"close":4,"generated":1}}`))
+		case "GET /admin/metrics/caches":
+			w.Write([]byte(`{"caches":{"results":{"entries":2,"hits":3,"misses":1}}}`))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := New(srv.URL, Options{})
+	if n, err := c.Reindex(ctx); err != nil || n != 12 {
+		t.Errorf("Expected 12 recipes reindexed, got %d (err %v)", n, err)
+	}
+	m, err := c.MatchMetrics(ctx)
+	if err != nil || m.Threshold != 0.3 || m.Requests != 5 || m.ByMatch["close"] != 4 {
+		t.Errorf("Unexpected match metrics %+v (err %v)", m, err)
+	}
+	caches, err := c.CacheMetrics(ctx)
+	if err != nil || caches["results"].Hits != 3 {
+		t.Errorf("Unexpected cache metrics %+v (err %v)", caches, err)
+	}
+}
+
+func TestResolveSignsRetries(t *testing.T) {
+	secret := []byte("secret")
+	verifier := auth.NewHMACVerifier(secret)
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := verifier.Authenticate(r); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		if attempts++; attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(ResolveResult{})
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, Options{HMACSecret: secret, Backoff: time.Millisecond})
+	if _, err := c.Resolve(context.Background(), "chili"); err != nil {
+		t.Fatalf("Expected the signed retry to succeed, got %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 authenticated attempts, got %d", attempts)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/admin/audit" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.URL.Query(); got.Get("actor") != "ops" || got.Get("limit") != "5" || got.Has("action") {
+			t.Errorf("Unexpected query %v", got)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"entries": []map[string]string{{"actor": "ops", "action": "cache.flush"}}})
+	}))
+	defer srv.Close()
+
+	entries, err := New(srv.URL, Options{}).Audit(context.Background(), AuditFilter{Actor: "ops", Limit: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Action != "cache.flush" {
+		t.Errorf("Expected one cache.flush entry, got %+v", entries)
+	}
+}
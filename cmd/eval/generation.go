@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/pageza/recipe-resolver-ms/generation"
+	"github.com/pageza/recipe-resolver-ms/nlp"
+)
+
+//go:embed generation_queries.json
+var defaultQueries []byte
+
+// recording is a provider response saved by -record and parsed again by -replay.
+type recording struct {
+	Query    string `json:"query"`
+	DeepSeek bool   `json:"deepseek"`
+	Body     string `json:"body"`
+}
+
+// generationResult is the evaluation of a single query.
+type generationResult struct {
+	Query    string
+	Err      error
+	Problems []string
+	Quality  float64
+}
+
+// runGeneration evaluates generation for every query and prints a report.
+func runGeneration(queriesPath, replayDir, recordDir, promptPath string) error {
+	data := defaultQueries
+	if queriesPath != "" {
+		var err error
+		if data, err = os.ReadFile(queriesPath); err != nil {
+			return err
+		}
+	}
+	var queries []string
+	if err := json.Unmarshal(data, &queries); err != nil {
+		return fmt.Errorf("parsing queries: %w", err)
+	}
+
+	if promptPath != "" {
+		if replayDir != "" {
+			return fmt.Errorf("-prompt has no effect with -replay; record the template first")
+		}
+		tmpl, err := template.ParseFiles(promptPath)
+		if err != nil {
+			return err
+		}
+		generation.PromptTemplate = tmpl
+	}
+	if recordDir != "" {
+		if err := os.MkdirAll(recordDir, 0755); err != nil {
+			return err
+		}
+	}
+
+	results := make([]generationResult, 0, len(queries))
+	for _, q := range queries {
+		var primary generation.Recipe
+		var alternatives []generation.Recipe
+		var err error
+		if replayDir != "" {
+			primary, alternatives, err = replay(replayDir, q)
+		} else {
+			primary, alternatives, err = generateLive(recordDir, q)
+		}
+		res := generationResult{Query: q, Err: err}
+		if err == nil {
+			res.Problems = generation.Validate(primary)
+			res.Quality = generation.Quality(q, primary, alternatives)
+		}
+		results = append(results, res)
+	}
+	printGenerationReport(os.Stdout, results)
+	return nil
+}
+
+// recordingPath returns the file a query's response is recorded to.
+func recordingPath(dir, query string) string {
+	return filepath.Join(dir, strings.Join(nlp.Tokenize(query), "-")+".json")
+}
+
+func replay(dir, query string) (generation.Recipe, []generation.Recipe, error) {
+	data, err := os.ReadFile(recordingPath(dir, query))
+	if err != nil {
+		return generation.Recipe{}, nil, err
+	}
+	var rec recording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return generation.Recipe{}, nil, err
+	}
+	return generation.ParseResponse(strings.NewReader(rec.Body), rec.DeepSeek)
+}
+
+// generateLive calls the configured provider. When recordDir is set, the raw response body is
+// captured from the transport and saved for later replay.
+func generateLive(recordDir, query string) (generation.Recipe, []generation.Recipe, error) {
+	if recordDir == "" {
+		return generation.GenerateRecipe(query)
+	}
+
+	tee := &teeTransport{base: http.DefaultTransport}
+	prev := generation.HTTPClient
+	generation.HTTPClient = &http.Client{Timeout: prev.Timeout, Transport: tee}
+	defer func() { generation.HTTPClient = prev }()
+
+	primary, alternatives, err := generation.GenerateRecipe(query)
+	if tee.body.Len() > 0 {
+		rec := recording{Query: query, DeepSeek: os.Getenv("DEEPSEEK_API_KEY") != "", Body: tee.body.String()}
+		data, _ := json.MarshalIndent(rec, "", "    ")
+		if werr := os.WriteFile(recordingPath(recordDir, query), data, 0644); werr != nil {
+			return primary, alternatives, werr
+		}
+	}
+	return primary, alternatives, err
+}
+
+// teeTransport copies every response body it reads into body.
+type teeTransport struct {
+	base http.RoundTripper
+	body bytes.Buffer
+}
+
+func (t *teeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.TeeReader(resp.Body, &t.body), resp.Body}
+	return resp, nil
+}
+
+// printGenerationReport writes one line per query followed by aggregate rates.
+func printGenerationReport(out io.Writer, results []generationResult) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "QUERY\tPARSED\tVALID\tQUALITY\tPROBLEMS")
+	parsed, valid, qualitySum := 0, 0, 0.0
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(w, "%s\tno\tno\t-\t%v\n", r.Query, r.Err)
+			continue
+		}
+		parsed++
+		qualitySum += r.Quality
+		ok := len(r.Problems) == 0
+		if ok {
+			valid++
+		}
+		fmt.Fprintf(w, "%s\tyes\t%s\t%.2f\t%s\n", r.Query, yesNo(ok), r.Quality, strings.Join(r.Problems, "; "))
+	}
+	w.Flush()
+
+	n := float64(len(results))
+	if n == 0 {
+		return
+	}
+	meanQuality := 0.0
+	if parsed > 0 {
+		meanQuality = qualitySum / float64(parsed)
+	}
+	fmt.Fprintf(out, "\nparsed %d/%d (%.0f%%), schema-valid %d/%d (%.0f%%), mean quality %.3f\n",
+		parsed, len(results), 100*float64(parsed)/n, valid, len(results), 100*float64(valid)/n, meanQuality)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
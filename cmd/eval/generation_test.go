@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/generation"
+	"github.com/pageza/recipe-resolver-ms/generation/providertest"
+)
+
+// TestRecordAndReplay verifies that a live response recorded with -record parses identically
+// when replayed, and that it is validated and scored.
+func TestRecordAndReplay(t *testing.T) {
+	response := generation.LLMResponse{
+		PrimaryRecipe: generation.Recipe{
+			ID:                "chili",
+			Title:             "Vegan Black Bean Chili",
+			Ingredients:       []string{"black beans", "tomatoes", "onion", "chili powder"},
+			Steps:             []string{"Saute onion", "Simmer everything"},
+			NutritionalInfo:   map[string]int{"calories": 380},
+			AllergyDisclaimer: "None",
+			CreatedAt:         "2025-02-19",
+			UpdatedAt:         "2025-02-19",
+		},
+	}
+	provider := providertest.NewServer(response, true)
+	defer provider.Close()
+	t.Setenv("LLM_ENDPOINT", provider.URL)
+	t.Setenv("DEEPSEEK_API_KEY", "test-key")
+
+	dir := t.TempDir()
+	live, _, err := generateLive(dir, "vegan black bean chili")
+	if err != nil {
+		t.Fatalf("generateLive returned error: %v", err)
+	}
+	replayed, alternatives, err := replay(dir, "vegan black bean chili")
+	if err != nil {
+		t.Fatalf("replay returned error: %v", err)
+	}
+	if replayed.ID != live.ID || replayed.Title != live.Title {
+		t.Errorf("Expected replayed recipe %+v to equal live recipe %+v", replayed, live)
+	}
+	if problems := generation.Validate(replayed); len(problems) != 0 {
+		t.Errorf("Expected no schema problems, got %v", problems)
+	}
+	// Every check passes except the missing alternatives.
+	if q := generation.Quality("vegan black bean chili", replayed, alternatives); q != 0.8 {
+		t.Errorf("Expected quality 0.8, got %f", q)
+	}
+}
@@ -0,0 +1,143 @@
+// Command eval runs offline quality evaluations of the resolver.
+//
+// The default matching mode runs a labeled query→expected-recipe dataset through the resolver
+// for several scorer configurations and reports precision, recall and mean reciprocal rank for
+// each, so changes to the nlp algorithms can be justified by numbers:
+//
+//	eval [-dataset file] [-corpus file] [-threshold 0.3] [-config "jaccard=1" -config "jaccard=1,overlap=1" ...]
+//
+// Without -dataset the built-in dataset over the sample recipes is used. No LLM calls are made.
+//
+// The generation mode runs a fixed query set through generation, validates every output
+// against the recipe schema, scores its quality and prints a report, so prompt-template
+// changes can be compared before rollout:
+//
+//	eval -mode generation [-queries file] (-replay dir | [-prompt file] [-record dir])
+//
+// With -replay, provider responses previously saved with -record are parsed instead of calling
+// the provider, so reports for two recorded templates can be compared without network access.
+// Otherwise the provider configured through LLM_ENDPOINT/DEEPSEEK_API_KEY is called live.
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pageza/recipe-resolver-ms/resolver"
+	"github.com/pageza/recipe-resolver-ms/taxonomy"
+)
+
+//go:embed dataset.json
+var defaultDataset []byte
+
+// scorers lists every scorer a configuration may reference.
+var scorers = map[string]resolver.Scorer{
+	"jaccard":  resolver.JaccardScorer{},
+	"overlap":  resolver.OverlapScorer{},
+	"taxonomy": taxonomy.Default(),
+}
+
+// configFlags collects repeated -config flags.
+type configFlags []string
+
+func (c *configFlags) String() string     { return strings.Join(*c, " ") }
+func (c *configFlags) Set(v string) error { *c = append(*c, v); return nil }
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("eval: ")
+
+	mode := flag.String("mode", "matching", "evaluation to run: matching or generation")
+	queriesPath := flag.String("queries", "", "generation mode: JSON file with an array of queries")
+	replayDir := flag.String("replay", "", "generation mode: parse responses recorded in this directory instead of calling the provider")
+	recordDir := flag.String("record", "", "generation mode: save live provider responses to this directory")
+	promptPath := flag.String("prompt", "", "generation mode: prompt template file to use instead of the built-in one")
+	datasetPath := flag.String("dataset", "", "JSON file with an array of {\"query\", \"expected\"} examples")
+	corpusPath := flag.String("corpus", "", "JSON file with an array of recipes (defaults to the built-in sample recipes)")
+	threshold := flag.Float64("threshold", resolver.DefaultThreshold, "close-match threshold")
+	var configs configFlags
+	flag.Var(&configs, "config", "scorer configuration as name=weight[,name=weight...]; may be repeated")
+	flag.Parse()
+
+	switch *mode {
+	case "matching":
+	case "generation":
+		if err := runGeneration(*queriesPath, *replayDir, *recordDir, *promptPath); err != nil {
+			log.Fatal(err)
+		}
+		return
+	default:
+		log.Fatalf("unknown mode %q", *mode)
+	}
+
+	if len(configs) == 0 {
+		configs = configFlags{"jaccard=1", "overlap=1", "jaccard=1,overlap=1"}
+	}
+
+	data := defaultDataset
+	if *datasetPath != "" {
+		var err error
+		if data, err = os.ReadFile(*datasetPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	var examples []Example
+	if err := json.Unmarshal(data, &examples); err != nil {
+		log.Fatalf("parsing dataset: %v", err)
+	}
+
+	recipes := resolver.SampleRecipes()
+	if *corpusPath != "" {
+		var err error
+		if recipes, err = resolver.LoadRecipesFile(*corpusPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%d examples, %d recipes, threshold %.2f\n\n", len(examples), len(recipes), *threshold)
+	fmt.Fprintln(w, "CONFIG\tPRECISION\tRECALL\tMRR\tFALSE MATCHES")
+	for _, cfg := range configs {
+		weighted, err := parseConfig(cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rs := resolver.New(resolver.NewMemoryStore(recipes), nil)
+		rs.Scorers = weighted
+		rs.Threshold = *threshold
+		rs.Logger = log.New(io.Discard, "", 0)
+
+		m := evaluate(rs, examples)
+		fmt.Fprintf(w, "%s\t%.3f\t%.3f\t%.3f\t%d\n", cfg, m.Precision, m.Recall, m.MRR, m.FalseMatches)
+	}
+	w.Flush()
+}
+
+// parseConfig parses "name=weight[,name=weight...]" into weighted scorers.
+func parseConfig(cfg string) ([]resolver.WeightedScorer, error) {
+	var weighted []resolver.WeightedScorer
+	for _, part := range strings.Split(cfg, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid scorer %q in config %q: want name=weight", part, cfg)
+		}
+		scorer, found := scorers[name]
+		if !found {
+			return nil, fmt.Errorf("unknown scorer %q in config %q", name, cfg)
+		}
+		w, err := strconv.ParseFloat(weight, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight for %s in config %q: %v", name, cfg, err)
+		}
+		weighted = append(weighted, resolver.WeightedScorer{Name: name, Scorer: scorer, Weight: w})
+	}
+	return weighted, nil
+}
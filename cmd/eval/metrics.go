@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+// Example is a labeled query. An empty Expected means no stored recipe should match, so the
+// resolver is expected to fall through to generation.
+type Example struct {
+	Query    string `json:"query"`
+	Expected string `json:"expected"`
+}
+
+// Metrics summarizes how a resolver configuration performs on a dataset.
+type Metrics struct {
+	// Precision is the fraction of predicted matches that were the expected recipe.
+	Precision float64
+	// Recall is the fraction of examples with an expected recipe that were matched to it.
+	Recall float64
+	// MRR is the mean reciprocal rank of the expected recipe among all candidates, over the
+	// examples that have one. A recipe ranked first contributes 1, second 1/2, and so on.
+	MRR float64
+	// FalseMatches counts examples without an expected recipe that were matched anyway.
+	FalseMatches int
+}
+
+// evaluate runs every example through rs.Rank. The top candidate is the prediction if it meets
+// the resolver's threshold, mirroring the close-match stage of Resolve.
+func evaluate(rs *resolver.Resolver, examples []Example) Metrics {
+	var m Metrics
+	predicted, correct, labeled := 0, 0, 0
+	reciprocalRanks := 0.0
+
+	for _, ex := range examples {
+		candidates := rs.Rank(ex.Query)
+		var prediction string
+		if len(candidates) > 0 && candidates[0].Score > 0 && candidates[0].Score >= rs.Threshold {
+			prediction = candidates[0].Recipe.Title
+			predicted++
+		}
+
+		if ex.Expected == "" {
+			if prediction != "" {
+				m.FalseMatches++
+			}
+			continue
+		}
+		labeled++
+		if strings.EqualFold(prediction, ex.Expected) {
+			correct++
+		}
+		for i, c := range candidates {
+			if c.Score > 0 && strings.EqualFold(c.Recipe.Title, ex.Expected) {
+				reciprocalRanks += 1 / float64(i+1)
+				break
+			}
+		}
+	}
+
+	if predicted > 0 {
+		m.Precision = float64(correct) / float64(predicted)
+	}
+	if labeled > 0 {
+		m.Recall = float64(correct) / float64(labeled)
+		m.MRR = reciprocalRanks / float64(labeled)
+	}
+	return m
+}
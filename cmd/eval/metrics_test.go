@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io"
+	"log"
+	"math"
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+// TestEvaluate verifies precision, recall, MRR and false matches on a small dataset.
+func TestEvaluate(t *testing.T) {
+	rs := resolver.New(resolver.NewMemoryStore(resolver.SampleRecipes()), nil)
+	rs.Logger = log.New(io.Discard, "", 0)
+
+	examples := []Example{
+		{Query: "chicken salad", Expected: "Chicken Salad"},          // correct match
+		{Query: "spaghetti", Expected: "Spaghetti Bolognese"},        // correct match (0.5)
+		{Query: "beef ragu", Expected: "Spaghetti Bolognese"},        // no match, never ranked
+		{Query: "chicken noodle soup", Expected: ""},                 // correctly unmatched
+		{Query: "chicken salad wrap with extra salad", Expected: ""}, // false match
+	}
+	m := evaluate(rs, examples)
+
+	if want := 2.0 / 3.0; math.Abs(m.Precision-want) > 1e-9 {
+		t.Errorf("Expected precision %f, got %f", want, m.Precision)
+	}
+	if want := 2.0 / 3.0; math.Abs(m.Recall-want) > 1e-9 {
+		t.Errorf("Expected recall %f, got %f", want, m.Recall)
+	}
+	if want := 2.0 / 3.0; math.Abs(m.MRR-want) > 1e-9 {
+		t.Errorf("Expected MRR %f, got %f", want, m.MRR)
+	}
+	if m.FalseMatches != 1 {
+		t.Errorf("Expected 1 false match, got %d", m.FalseMatches)
+	}
+}
+
+// TestParseConfig verifies scorer configuration parsing.
+func TestParseConfig(t *testing.T) {
+	weighted, err := parseConfig("jaccard=1, overlap=0.5")
+	if err != nil {
+		t.Fatalf("parseConfig returned error: %v", err)
+	}
+	if len(weighted) != 2 || weighted[1].Name != "overlap" || weighted[1].Weight != 0.5 {
+		t.Errorf("Unexpected configuration: %+v", weighted)
+	}
+	if _, err := parseConfig("cosine=1"); err == nil {
+		t.Errorf("Expected an error for an unknown scorer")
+	}
+}
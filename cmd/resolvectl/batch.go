@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pageza/recipe-resolver-ms/generation"
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+// batchProgress is a line of a batch generation's progress file, recording a finished query.
+type batchProgress struct {
+	Query   string           `json:"query"`
+	Recipes int              `json:"recipes"`
+	Usage   generation.Usage `json:"usage"`
+}
+
+// runBatchGenerate generates recipes for every query in file, one per line, and appends them to
+// output as each query finishes. output is a corpus file: a JSON array of recipes, as read by
+// resolver.LoadRecipesFile. Each finished query is logged in output+".progress", so that a rerun
+// after an interruption, a failure or an exhausted budget resumes with the remaining queries.
+// Once budget tokens (zero for no limit) have been spent no further queries are started. price,
+// in dollars per million tokens, is used to report the cost. The run is not bounded by the
+// default -timeout, only by one given explicitly.
+func runBatchGenerate(ctx context.Context, file, output string, concurrency, budget int, price float64) error {
+	queries, err := readQueries(file)
+	if err != nil {
+		return err
+	}
+	recipes, err := resolver.LoadRecipesFile(output)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	done, earlier, err := readProgress(output + ".progress")
+	if err != nil {
+		return err
+	}
+	var pending []string
+	for _, q := range queries {
+		if !done[q] {
+			pending = append(pending, q)
+		}
+	}
+	fmt.Printf("%d queries, %d already done; generating %d with concurrency %d\n", len(queries), len(queries)-len(pending), len(pending), concurrency)
+
+	corpus, err := openCorpus(output, len(recipes))
+	if err != nil {
+		return err
+	}
+	defer corpus.Close()
+	progress, err := os.OpenFile(output+".progress", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer progress.Close()
+	titles := make(map[string]bool)
+	for _, r := range recipes {
+		titles[strings.ToLower(r.Title)] = true
+	}
+
+	var (
+		mu                      sync.Mutex
+		wg                      sync.WaitGroup
+		spent                   generation.Usage
+		finished, failed, added int
+		writeErr                error
+	)
+	slots := make(chan struct{}, concurrency)
+	started := 0
+launch:
+	for _, q := range pending {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			break launch
+		}
+		mu.Lock()
+		over := budget > 0 && spent.Total() >= budget || writeErr != nil
+		mu.Unlock()
+		if over {
+			<-slots
+			break
+		}
+		started++
+		wg.Add(1)
+		go func(q string) {
+			defer func() { <-slots; wg.Done() }()
+			var meter generation.Meter
+			var source generation.Source
+			primary, alternatives, err := generation.GenerateRecipeContext(generation.WithSource(generation.WithMeter(ctx, &meter), &source), q)
+			used := meter.Usage()
+
+			mu.Lock()
+			defer mu.Unlock()
+			spent = spent.Add(used)
+			finished++
+			if err != nil {
+				failed++
+				fmt.Printf("[%d/%d] %q failed: %v\n", finished, len(pending), q, err)
+				return
+			}
+			var fresh []model.Recipe
+			for _, g := range append([]generation.Recipe{primary}, alternatives...) {
+				if key := strings.ToLower(g.Title); g.Title != "" && !titles[key] {
+					titles[key] = true
+					fresh = append(fresh, fromGenerated(g, source))
+				}
+			}
+			if err := corpus.Append(fresh); err != nil {
+				writeErr = err
+				return
+			}
+			n := len(fresh)
+			added += n
+			line, _ := json.Marshal(batchProgress{Query: q, Recipes: n, Usage: used})
+			if _, err := progress.Write(append(line, '\n')); err != nil {
+				writeErr = err
+				return
+			}
+			fmt.Printf("[%d/%d] %q: %d new recipes, %d tokens (%d this run)\n", finished, len(pending), q, n, used.Total(), spent.Total())
+		}(q)
+	}
+	wg.Wait()
+
+	fmt.Printf("Generated %d recipes for %d queries (%d failed) in %s\n", added, finished-failed, failed, output)
+	fmt.Printf("This run: %s\n", describeUsage(spent, price))
+	if earlier.Calls > 0 {
+		fmt.Printf("All runs: %s\n", describeUsage(spent.Add(earlier), price))
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if remaining := len(pending) - started + failed; remaining > 0 {
+		fmt.Printf("%d queries remain; rerun the same command to resume\n", remaining)
+	}
+	return ctx.Err()
+}
+
+// readQueries returns the distinct queries in path, one per line, skipping blank lines and
+// lines starting with "#".
+func readQueries(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var queries []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		q := strings.TrimSpace(scanner.Text())
+		if q == "" || strings.HasPrefix(q, "#") || seen[q] {
+			continue
+		}
+		seen[q] = true
+		queries = append(queries, q)
+	}
+	return queries, scanner.Err()
+}
+
+// readProgress returns the queries recorded in a progress file and the usage they took. A
+// missing file records nothing.
+func readProgress(path string) (map[string]bool, generation.Usage, error) {
+	done := make(map[string]bool)
+	var total generation.Usage
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return done, total, nil
+	}
+	if err != nil {
+		return nil, total, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		var p batchProgress
+		if err := json.Unmarshal(scanner.Bytes(), &p); err != nil {
+			return nil, total, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		done[p.Query] = true
+		total = total.Add(p.Usage)
+	}
+	return done, total, scanner.Err()
+}
+
+// fromGenerated converts a recipe generated by source for the corpus, with a fresh ID since
+// providers' IDs are not unique.
+func fromGenerated(g generation.Recipe, source generation.Source) model.Recipe {
+	r := model.NewRecipe(g.Title, g.Ingredients, g.Steps, g.NutritionalInfo, g.AllergyDisclaimer, g.Appliances)
+	r.Provenance = model.Generated(source.Model, source.PromptVersion, r.CreatedAt)
+	r.Difficulty = model.Difficulty(strings.ToLower(g.Difficulty))
+	r.TotalTime = g.TotalTime
+	return r
+}
+
+// corpusFile appends recipes to a corpus file in place, so that each append writes only the new
+// recipes rather than the whole corpus. The file stays a JSON array between appends: each one
+// overwrites the closing bracket and writes it again after the new recipes.
+type corpusFile struct {
+	*os.File
+	end   int64 // offset just after the last recipe, or the opening bracket
+	empty bool
+}
+
+// openCorpus opens the corpus file at path, which holds n recipes, creating it if needed.
+func openCorpus(path string, n int) (*corpusFile, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	c := &corpusFile{File: f, empty: n == 0}
+	if err := c.findEnd(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// findEnd sets c.end from the closing bracket of the array in the file, writing an empty array
+// to an empty file.
+func (c *corpusFile) findEnd() error {
+	size, err := c.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if size == 0 {
+		c.end = 1
+		_, err := c.WriteAt([]byte("[\n]\n"), 0)
+		return err
+	}
+	start := max(size-4096, 0)
+	tail := make([]byte, size-start)
+	if _, err := c.ReadAt(tail, start); err != nil {
+		return err
+	}
+	tail = bytes.TrimRight(tail, " \t\r\n")
+	if !bytes.HasSuffix(tail, []byte("]")) {
+		return errors.New("not a JSON array of recipes")
+	}
+	c.end = start + int64(len(bytes.TrimRight(tail[:len(tail)-1], " \t\r\n")))
+	return nil
+}
+
+// Append adds recipes to the end of the corpus.
+func (c *corpusFile) Append(recipes []model.Recipe) error {
+	if len(recipes) == 0 {
+		return nil
+	}
+	var b bytes.Buffer
+	for _, r := range recipes {
+		data, err := json.MarshalIndent(r, "    ", "    ")
+		if err != nil {
+			return err
+		}
+		if !c.empty {
+			b.WriteByte(',')
+		}
+		b.WriteString("\n    ")
+		b.Write(data)
+		c.empty = false
+	}
+	n := int64(b.Len())
+	b.WriteString("\n]\n")
+	if _, err := c.WriteAt(b.Bytes(), c.end); err != nil {
+		return err
+	}
+	if err := c.Truncate(c.end + int64(b.Len())); err != nil {
+		return err
+	}
+	c.end += n
+	return nil
+}
+
+// describeUsage summarises u, with its cost at price dollars per million tokens if set.
+func describeUsage(u generation.Usage, price float64) string {
+	s := fmt.Sprintf("%d provider calls, %d prompt + %d completion = %d tokens", u.Calls, u.PromptTokens, u.CompletionTokens, u.Total())
+	if price > 0 {
+		s += fmt.Sprintf(", about $%.2f", float64(u.Total())*price/1e6)
+	}
+	return s
+}
+
+// parseTokens parses a token budget such as "100000", "100000tokens" or "100k".
+func parseTokens(s string) (int, error) {
+	v := strings.ToLower(strings.TrimSpace(s))
+	v = strings.TrimSpace(strings.TrimSuffix(strings.TrimSuffix(v, "tokens"), "token"))
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(v, "k"):
+		v, multiplier = strings.TrimSuffix(v, "k"), 1000
+	case strings.HasSuffix(v, "m"):
+		v, multiplier = strings.TrimSuffix(v, "m"), 1000000
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid token budget %q: expected e.g. 100000tokens or 100k", s)
+	}
+	return n * multiplier, nil
+}
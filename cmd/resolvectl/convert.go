@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pageza/recipe-resolver-ms/client"
+	"github.com/pageza/recipe-resolver-ms/interop"
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// runConvert converts a recipe collection between the resolver's corpus format ("native", a JSON
+// array of recipes) and the Mealie and Paprika export formats. Recipes can also be read from
+// schema.org JSON-LD, as recipe websites publish them. It works on files only, so a
+// Mealie or Paprika collection can be turned into a corpus file for the service or the REPL.
+// Imported recipes must be attributed: -license, -author and -source-url credit those the
+// collection does not.
+func runConvert(_ context.Context, _ *client.Client, args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	from := fs.String("from", "native", "input format: native, mealie, paprika or jsonld")
+	to := fs.String("to", "native", "output format: native, mealie or paprika")
+	output := fs.String("o", "", "write the result to this file instead of stdout")
+	var credit model.Attribution
+	fs.StringVar(&credit.License, "license", "", "license of imported recipes that do not name one, e.g. CC-BY-4.0")
+	fs.StringVar(&credit.Author, "author", "", "author of imported recipes that do not name one")
+	fs.StringVar(&credit.SourceURL, "source-url", "", "source URL of imported recipes that do not name one")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: resolvectl convert -from format -to format [-o file] <input>")
+	}
+
+	recipes, err := readRecipes(fs.Arg(0), *from, credit)
+	if err != nil {
+		return err
+	}
+
+	var write func(io.Writer) error
+	switch *to {
+	case "native":
+		write = func(w io.Writer) error { return printJSON(w, recipes) }
+	case "mealie":
+		write = func(w io.Writer) error { return interop.ExportMealie(w, recipes) }
+	case "paprika":
+		if *output == "" {
+			return fmt.Errorf("paprika output is a zip archive; use -o to name the file")
+		}
+		write = func(w io.Writer) error { return interop.ExportPaprika(w, recipes) }
+	default:
+		return fmt.Errorf("unknown output format %q", *to)
+	}
+
+	if *output == "" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Converted %d recipes to %s\n", len(recipes), *output)
+	return nil
+}
+
+// readRecipes reads the recipe collection in path, in format "native", "mealie", "paprika" or
+// "jsonld", crediting credit for the recipes that are not attributed.
+func readRecipes(path, format string, credit model.Attribution) ([]model.Recipe, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var recipes []model.Recipe
+	switch format {
+	case "native":
+		err = json.Unmarshal(data, &recipes)
+	case "mealie":
+		recipes, err = interop.ImportMealie(bytes.NewReader(data))
+	case "paprika":
+		recipes, err = interop.ImportPaprika(data)
+	case "jsonld":
+		recipes, err = interop.ImportJSONLD(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("unknown input format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if err := interop.Attribute(recipes, credit); err != nil {
+		if errors.Is(err, model.ErrMissingAttribution) {
+			return nil, fmt.Errorf("%w; use -license with -author or -source-url to credit the collection", err)
+		}
+		return nil, err
+	}
+	return recipes, nil
+}
@@ -0,0 +1,487 @@
+// Command resolvectl is the administrative CLI for the recipe resolver microservice.
+//
+// Usage:
+//
+//	resolvectl [-addr URL] <command> [arguments]
+//
+// Commands:
+//
+//	resolve <query>            resolve a query against the running service
+//	flush-cache                drop every cached generation on the service
+//	audit [-actor name] ...    list the service's audit trail
+//	audit-tail [-action a] ... follow the service's audit trail as entries are recorded
+//	import [-from f] <file>    add the recipes of a collection file to the service's store
+//	export [-format f] [-o f]  write every stored recipe as NDJSON or CSV
+//	reindex                    rebuild the search index of the service's recipe store
+//	metrics-summary            summarize the service's match and cache metrics
+//	backfill-nutrition [-wait] estimate missing nutrition for stored recipes
+//	degraded [on|off]          show or switch the service's degraded mode
+//	jobs [run <name>]          list the service's scheduled jobs, or run one now
+//	batch-delete [-n] ...      delete or archive the stored recipes matching a filter
+//	generate [-o file] <query> call the configured LLM provider directly (no service needed)
+//	generate -file f -o corpus batch-generate a corpus file for a list of queries (no service needed)
+//	repl [-corpus file]        tune scorer weights and the threshold interactively (no service needed)
+//	convert -from f -to f <in> convert recipes between native, Mealie and Paprika formats, or from JSON-LD (no service needed)
+//
+// The service address defaults to $RESOLVER_ADDR, or http://localhost:3000 if unset. If
+// $RESOLVER_API_KEY is set, it is sent as a bearer token; if $RESOLVER_HMAC_SECRET is set,
+// requests to the service are signed with it.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pageza/recipe-resolver-ms/client"
+	"github.com/pageza/recipe-resolver-ms/generation"
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+// command is a resolvectl subcommand. run receives the arguments following the command name.
+// Interactive commands are not subject to the -timeout flag. Those for which untimed, if set,
+// reports true given their arguments, such as long batches, are only subject to a -timeout
+// given explicitly, not to the default.
+type command struct {
+	name        string
+	usage       string
+	summary     string
+	interactive bool
+	untimed     func(args []string) bool
+	run         func(ctx context.Context, c *client.Client, args []string) error
+}
+
+var commands = []command{
+	{name: "resolve", usage: "resolve <query>", summary: "resolve a query against the running service", run: runResolve},
+	{name: "flush-cache", usage: "flush-cache", summary: "drop every cached generation on the service", run: runFlushCache},
+	{name: "audit", usage: "audit [-actor a] [-n 20]", summary: "list the service's audit trail", run: runAudit},
+	{name: "audit-tail", usage: "audit-tail [-action a]", summary: "follow the service's audit trail as entries are recorded", interactive: true, run: runAuditTail},
+	{name: "import", usage: "import [-from f] <file>", summary: "add the recipes of a collection file to the service's store", run: runImport},
+	{name: "export", usage: "export [-format f] [-o file]", summary: "write every stored recipe as ndjson or csv", run: runExport},
+	{name: "reindex", usage: "reindex", summary: "rebuild the search index of the service's recipe store", run: runReindex},
+	{name: "metrics-summary", usage: "metrics-summary", summary: "summarize the service's match and cache metrics", run: runMetricsSummary},
+	{name: "backfill-nutrition", usage: "backfill-nutrition [-wait]", summary: "estimate missing nutrition for stored recipes", run: runBackfillNutrition},
+	{name: "degraded", usage: "degraded [on|off]", summary: "show or switch the service's degraded mode", run: runDegraded},
+	{name: "jobs", usage: "jobs [run <name>]", summary: "list the service's scheduled jobs, or run one now", run: runJobs},
+	{name: "batch-delete", usage: "batch-delete [-n] [-archive] ...", summary: "delete or archive the stored recipes matching a filter", run: runBatchDelete},
+	{name: "generate", usage: "generate [-o file] <query>", summary: "call the configured LLM provider directly; -file batch-generates a corpus", untimed: hasFlag("file"), run: runGenerate},
+	{name: "repl", usage: "repl [-corpus file] [-top n]", summary: "tune scorer weights and the threshold interactively", interactive: true, run: runREPL},
+	{name: "convert", usage: "convert -from f -to f <in>", summary: "convert recipes between native, mealie and paprika formats, or from jsonld", run: runConvert},
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("resolvectl: ")
+
+	defaultAddr := os.Getenv("RESOLVER_ADDR")
+	if defaultAddr == "" {
+		defaultAddr = "http://localhost:3000"
+	}
+	addr := flag.String("addr", defaultAddr, "base URL of the resolver service")
+	timeout := flag.Duration("timeout", 2*time.Minute, "overall timeout of the command")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	name, args := flag.Arg(0), flag.Args()[1:]
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		ctx, cancel := context.Background(), context.CancelFunc(func() {})
+		if !cmd.interactive && (cmd.untimed == nil || !cmd.untimed(args) || flagSet("timeout")) {
+			ctx, cancel = context.WithTimeout(ctx, *timeout)
+		}
+		err := cmd.run(ctx, client.New(*addr, client.Options{APIKey: os.Getenv("RESOLVER_API_KEY"), HMACSecret: hmacSecret()}), args)
+		cancel()
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	log.Printf("unknown command %q", name)
+	usage()
+	os.Exit(2)
+}
+
+// flagSet reports whether the global flag with the given name was set on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) { set = set || f.Name == name })
+	return set
+}
+
+// hasFlag returns a function reporting whether a command's arguments set the flag with the
+// given name, before any "--".
+func hasFlag(name string) func(args []string) bool {
+	return func(args []string) bool {
+		for _, a := range args {
+			if a == "--" {
+				return false
+			}
+			if !strings.HasPrefix(a, "-") {
+				continue
+			}
+			if a = strings.TrimPrefix(a[1:], "-"); a == name || strings.HasPrefix(a, name+"=") {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// hmacSecret returns the request signing secret from the environment, or nil if unset.
+func hmacSecret() []byte {
+	if s := os.Getenv("RESOLVER_HMAC_SECRET"); s != "" {
+		return []byte(s)
+	}
+	return nil
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "Usage: resolvectl [flags] <command> [arguments]")
+	fmt.Fprintln(out, "\nCommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-28s %s\n", cmd.usage, cmd.summary)
+	}
+	fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+// printJSON writes v to w as indented JSON.
+func printJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(v)
+}
+
+func runResolve(ctx context.Context, c *client.Client, args []string) error {
+	query := strings.TrimSpace(strings.Join(args, " "))
+	if query == "" {
+		return fmt.Errorf("usage: resolvectl resolve <query>")
+	}
+	res, err := c.Resolve(ctx, query)
+	if err != nil {
+		return err
+	}
+	return printJSON(os.Stdout, res)
+}
+
+func runFlushCache(ctx context.Context, c *client.Client, args []string) error {
+	n, err := c.FlushCache(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Flushed %d cached generations\n", n)
+	return nil
+}
+
+func runDegraded(ctx context.Context, c *client.Client, args []string) error {
+	var status resolver.DegradationStatus
+	var err error
+	switch {
+	case len(args) == 0:
+		status, err = c.Degraded(ctx)
+	case len(args) == 1 && (args[0] == "on" || args[0] == "off"):
+		status, err = c.SetDegraded(ctx, args[0] == "on")
+	default:
+		return errors.New("usage: degraded [on|off]")
+	}
+	if err != nil {
+		return err
+	}
+	return printJSON(os.Stdout, status)
+}
+
+func runJobs(ctx context.Context, c *client.Client, args []string) error {
+	switch {
+	case len(args) == 0:
+	case len(args) == 2 && args[0] == "run":
+		if err := c.RunJob(ctx, args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Started job %s\n", args[1])
+		return nil
+	default:
+		return errors.New("usage: jobs [run <name>]")
+	}
+	jobs, err := c.Jobs(ctx)
+	if err != nil {
+		return err
+	}
+	return printJSON(os.Stdout, jobs)
+}
+
+func runBatchDelete(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("batch-delete", flag.ContinueOnError)
+	var f model.Filter
+	ids := fs.String("ids", "", "comma-separated IDs of the recipes")
+	fs.StringVar(&f.TitleContains, "title", "", "only recipes whose title contains this text")
+	fs.StringVar(&f.Tag, "tag", "", "only recipes with this tag")
+	olderThan := fs.Duration("older-than", 0, "only recipes created longer ago than this, e.g. 720h")
+	archive := fs.Bool("archive", false, "archive the recipes instead of deleting them")
+	dryRun := fs.Bool("n", false, "only list the recipes that would be affected")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *ids != "" {
+		f.IDs = strings.Split(*ids, ",")
+	}
+	if *olderThan > 0 {
+		f.CreatedBefore = time.Now().Add(-*olderThan)
+	}
+	if f.IsZero() {
+		return errors.New("batch-delete needs at least one of -ids, -title, -tag and -older-than")
+	}
+	res, err := c.BatchDelete(ctx, f, *archive, *dryRun)
+	if err != nil {
+		return err
+	}
+	return printJSON(os.Stdout, res)
+}
+
+func runAudit(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	var f client.AuditFilter
+	fs.StringVar(&f.Actor, "actor", "", "only show entries by this actor")
+	fs.StringVar(&f.Action, "action", "", "only show entries for this action, e.g. cache.flush")
+	fs.StringVar(&f.Target, "target", "", "only show entries for this target")
+	since := fs.Duration("since", 0, "only show entries newer than this, e.g. 24h")
+	fs.IntVar(&f.Limit, "n", 20, "maximum number of entries")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *since > 0 {
+		f.Since = time.Now().Add(-*since)
+	}
+	entries, err := c.Audit(ctx, f)
+	if err != nil {
+		return err
+	}
+	return printJSON(os.Stdout, entries)
+}
+
+// runAuditTail prints the service's new audit entries matching the filter flags as they are
+// recorded, a JSON entry per line, polling every -interval until interrupted. -since also
+// prints the entries recorded that long ago.
+func runAuditTail(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("audit-tail", flag.ContinueOnError)
+	var f client.AuditFilter
+	fs.StringVar(&f.Actor, "actor", "", "only show entries by this actor")
+	fs.StringVar(&f.Action, "action", "", "only show entries for this action, e.g. recipe.import")
+	fs.StringVar(&f.Target, "target", "", "only show entries for this target")
+	since := fs.Duration("since", 0, "also show the entries recorded that long ago, e.g. 1h")
+	interval := fs.Duration("interval", 2*time.Second, "how often to poll for new entries")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	// The service filters by whole seconds, so entries of the last second seen come again.
+	last, seen := time.Now().Add(-*since), map[string]bool{}
+	for {
+		f.Since = last
+		entries, err := c.Audit(ctx, f)
+		if err != nil {
+			return err
+		}
+		for i := len(entries) - 1; i >= 0; i-- {
+			e := entries[i]
+			key := e.Time.Format(time.RFC3339Nano) + " " + e.Actor + " " + e.Action + " " + e.Target
+			if e.Time.Before(last) || seen[key] {
+				continue
+			}
+			if e.Time.After(last) {
+				last, seen = e.Time, map[string]bool{}
+			}
+			seen[key] = true
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(*interval):
+		}
+	}
+}
+
+func runReindex(ctx context.Context, c *client.Client, args []string) error {
+	n, err := c.Reindex(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Reindexed %d recipes\n", n)
+	return nil
+}
+
+// runMetricsSummary prints the service's match metrics, unless they are not enabled, and the
+// hit rates of its caches.
+func runMetricsSummary(ctx context.Context, c *client.Client, args []string) error {
+	m, err := c.MatchMetrics(ctx)
+	var apiErr *client.APIError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotImplemented:
+		fmt.Println("Match metrics are not enabled")
+	case err != nil:
+		return err
+	default:
+		fmt.Printf("Queries: %d (threshold %.2f, near margin %.2f)\n", m.Requests, m.Threshold, m.NearMargin)
+		if m.Similarity.Count > 0 {
+			fmt.Printf("Mean best similarity: %.2f\n", m.Similarity.Sum/float64(m.Similarity.Count))
+		}
+		printCounts("By match", m.ByMatch)
+		printCounts("By band", m.ByBand)
+	}
+
+	caches, err := c.CacheMetrics(ctx)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(caches))
+	for name := range caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		st := caches[name]
+		rate := 0.0
+		if lookups := st.Hits + st.Misses; lookups > 0 {
+			rate = float64(st.Hits) / float64(lookups) * 100
+		}
+		fmt.Printf("Cache %s: %d entries, %.1f%% hits (%d of %d), %d evictions\n", name, st.Entries, rate, st.Hits, st.Hits+st.Misses, st.Evictions)
+	}
+	return nil
+}
+
+// printCounts prints counts by key on one line, in key order, unless there are none.
+func printCounts[K ~string](label string, counts map[K]int) {
+	if len(counts) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, string(k))
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s %d", k, counts[K(k)])
+	}
+	fmt.Printf("%s: %s\n", label, strings.Join(parts, ", "))
+}
+
+// runBackfillNutrition starts a nutrition backfill on the service, or attaches to the one
+// already running. With -wait it reports progress until the run finishes; raise -timeout for
+// large corpora.
+func runBackfillNutrition(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("backfill-nutrition", flag.ContinueOnError)
+	wait := fs.Bool("wait", false, "report progress until the run finishes")
+	interval := fs.Duration("interval", 2*time.Second, "how often to poll for progress with -wait")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	progress, err := c.BackfillNutrition(ctx)
+	var apiErr *client.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict {
+		fmt.Println("A backfill is already running")
+		progress, err = c.NutritionBackfillProgress(ctx)
+	}
+	for ; err == nil; progress, err = c.NutritionBackfillProgress(ctx) {
+		fmt.Printf("Processed %d of %d recipes (%d updated, %d failed)\n", progress.Processed, progress.Total, progress.Updated, progress.Failed)
+		if !*wait || !progress.Running {
+			if progress.LastError != "" {
+				fmt.Printf("Last error: %s\n", progress.LastError)
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(*interval):
+		}
+	}
+	return err
+}
+
+// runGenerate calls the LLM provider configured through the environment (or a .env file)
+// and writes the result as JSON, which is handy when checking provider credentials. With -file
+// it batch-generates a corpus instead, e.g. to seed a new tenant (see runBatchGenerate).
+func runGenerate(ctx context.Context, _ *client.Client, args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	output := fs.String("o", "", "write the JSON result to this file instead of stdout")
+	file := fs.String("file", "", "generate for every query in this file, one per line, into the -o corpus file")
+	concurrency := fs.Int("concurrency", 4, "provider calls in flight at once with -file")
+	budget := fs.String("budget", "", "stop starting queries once this many tokens are spent with -file, e.g. 100000tokens")
+	price := fs.Float64("price", 0, "provider price in dollars per million tokens, to report the cost with -file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	query := strings.TrimSpace(strings.Join(fs.Args(), " "))
+	if (query == "") == (*file == "") {
+		return fmt.Errorf("usage: resolvectl generate [-o file] <query>, or generate -file queries.txt -o corpus.json")
+	}
+
+	// Load environment variables from .env file.
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found or error loading it, ensure environment variables are set.")
+	}
+
+	if *file != "" {
+		if *output == "" {
+			return errors.New("generate -file needs -o to name the corpus file")
+		}
+		if *concurrency < 1 {
+			return errors.New("-concurrency must be at least 1")
+		}
+		tokens := 0
+		if *budget != "" {
+			var err error
+			if tokens, err = parseTokens(*budget); err != nil {
+				return err
+			}
+		}
+		return runBatchGenerate(ctx, *file, *output, *concurrency, tokens, *price)
+	}
+
+	primary, alternatives, err := generation.GenerateRecipeContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("generating recipe: %w", err)
+	}
+	response := generation.LLMResponse{
+		PrimaryRecipe:      primary,
+		AlternativeRecipes: alternatives,
+	}
+
+	if *output == "" {
+		return printJSON(os.Stdout, response)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := printJSON(f, response); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Output JSON written to %s\n", *output)
+	return nil
+}
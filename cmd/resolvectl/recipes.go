@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/pageza/recipe-resolver-ms/client"
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// runImport adds the recipes of a collection file to the service's store as published recipes,
+// one request each, under new IDs. A recipe the service rejects is reported and skipped; the
+// command fails if any was. Raise -timeout for large collections.
+func runImport(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	from := fs.String("from", "native", "input format: native, mealie, paprika or jsonld")
+	var credit model.Attribution
+	fs.StringVar(&credit.License, "license", "", "license of imported recipes that do not name one, e.g. CC-BY-4.0")
+	fs.StringVar(&credit.Author, "author", "", "author of imported recipes that do not name one")
+	fs.StringVar(&credit.SourceURL, "source-url", "", "source URL of imported recipes that do not name one")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: resolvectl import [-from format] <file>")
+	}
+	recipes, err := readRecipes(fs.Arg(0), *from, credit)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, rec := range recipes {
+		if _, err := c.CreateRecipe(ctx, rec); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			fmt.Fprintf(os.Stderr, "Skipped %q: %v\n", rec.Title, err)
+			failed++
+		}
+	}
+	fmt.Printf("Imported %d of %d recipes\n", len(recipes)-failed, len(recipes))
+	if failed > 0 {
+		return fmt.Errorf("%d of %d recipes were not imported", failed, len(recipes))
+	}
+	return nil
+}
+
+// runExport writes every stored recipe, archived and unpublished ones included, as the service
+// exports them: newline-delimited JSON or CSV.
+func runExport(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "ndjson", "output format: ndjson or csv")
+	output := fs.String("o", "", "write the export to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("usage: resolvectl export [-format ndjson|csv] [-o file]")
+	}
+	if *output == "" {
+		return c.ExportRecipes(ctx, *format, os.Stdout)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := c.ExportRecipes(ctx, *format, f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Export written to %s\n", *output)
+	return nil
+}
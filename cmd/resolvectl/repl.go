@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pageza/recipe-resolver-ms/client"
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+	"github.com/pageza/recipe-resolver-ms/seed"
+)
+
+const replHelp = `Type a query to see how every stored recipe scores against it.
+Commands:
+  :threshold <value>        set the close-match threshold
+  :weight <scorer> <value>  set a scorer's weight (0 disables it)
+  :generate on|off          run the full pipeline, including LLM generation, for each query
+  :config                   show the current threshold and weights
+  :help                     show this help
+  :quit                     leave the REPL`
+
+// runREPL loads the corpus locally and lets a developer experiment with queries, the threshold
+// and scorer weights without a running service.
+func runREPL(ctx context.Context, _ *client.Client, args []string) error {
+	fs := flag.NewFlagSet("repl", flag.ContinueOnError)
+	corpus := fs.String("corpus", "", "JSON file with an array of recipes (defaults to the embedded seed corpus)")
+	top := fs.Int("top", 5, "number of candidates to show per query")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var recipes []model.Recipe
+	var err error
+	if *corpus != "" {
+		recipes, err = resolver.LoadRecipesFile(*corpus)
+	} else {
+		recipes, err = seed.Recipes()
+	}
+	if err != nil {
+		return err
+	}
+
+	rs := resolver.New(resolver.NewMemoryStore(recipes), resolver.LLMGenerator{})
+	rs.Scorers = []resolver.WeightedScorer{
+		{Name: "jaccard", Scorer: resolver.JaccardScorer{}, Weight: 1},
+		{Name: "overlap", Scorer: resolver.OverlapScorer{}, Weight: 0},
+	}
+	rs.Cache = nil
+	rs.Logger = log.New(io.Discard, "", 0)
+
+	r := &repl{rs: rs, out: os.Stdout, top: *top}
+	fmt.Fprintf(r.out, "Loaded %d recipes. Type :help for commands.\n", len(recipes))
+	return r.run(ctx, os.Stdin)
+}
+
+// repl holds the state of an interactive tuning session.
+type repl struct {
+	rs       *resolver.Resolver
+	out      io.Writer
+	top      int
+	generate bool
+}
+
+func (r *repl) run(ctx context.Context, in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(r.out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(r.out)
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case line == ":quit" || line == ":q":
+			return nil
+		case strings.HasPrefix(line, ":"):
+			if err := r.command(strings.Fields(line)); err != nil {
+				fmt.Fprintln(r.out, "error:", err)
+			}
+		default:
+			r.query(ctx, line)
+		}
+	}
+}
+
+// command applies a ":" command.
+func (r *repl) command(fields []string) error {
+	switch fields[0] {
+	case ":help":
+		fmt.Fprintln(r.out, replHelp)
+	case ":config":
+		r.printConfig()
+	case ":threshold":
+		if len(fields) != 2 {
+			return fmt.Errorf("usage: :threshold <value>")
+		}
+		v, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return err
+		}
+		r.rs.Threshold = v
+		r.printConfig()
+	case ":weight":
+		if len(fields) != 3 {
+			return fmt.Errorf("usage: :weight <scorer> <value>")
+		}
+		v, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return err
+		}
+		for i := range r.rs.Scorers {
+			if r.rs.Scorers[i].Name == fields[1] {
+				r.rs.Scorers[i].Weight = v
+				r.printConfig()
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown scorer %q", fields[1])
+	case ":generate":
+		if len(fields) != 2 || (fields[1] != "on" && fields[1] != "off") {
+			return fmt.Errorf("usage: :generate on|off")
+		}
+		r.generate = fields[1] == "on"
+		r.printConfig()
+	default:
+		return fmt.Errorf("unknown command %s (try :help)", fields[0])
+	}
+	return nil
+}
+
+func (r *repl) printConfig() {
+	fmt.Fprintf(r.out, "threshold=%.3f generate=%t", r.rs.Threshold, r.generate)
+	for _, s := range r.rs.Scorers {
+		fmt.Fprintf(r.out, " %s=%.3f", s.Name, s.Weight)
+	}
+	fmt.Fprintln(r.out)
+}
+
+// query prints the ranked candidates for q and, if enabled, the outcome of the full pipeline.
+func (r *repl) query(ctx context.Context, q string) {
+	candidates := r.rs.Rank(q)
+	if len(candidates) > r.top {
+		candidates = candidates[:r.top]
+	}
+	for _, c := range candidates {
+		marker := " "
+		if c.Score >= r.rs.Threshold {
+			marker = "*"
+		}
+		fmt.Fprintf(r.out, "%s %.3f  %-40s", marker, c.Score, c.Recipe.Title)
+		for _, s := range r.rs.Scorers {
+			fmt.Fprintf(r.out, " %s=%.3f", s.Name, c.Scores[s.Name])
+		}
+		for _, boost := range []string{"season", "occasion", "recency"} {
+			if v, ok := c.Scores[boost]; ok {
+				fmt.Fprintf(r.out, " +%s=%.3f", boost, v)
+			}
+		}
+		fmt.Fprintln(r.out)
+	}
+
+	if !r.generate {
+		return
+	}
+	res, err := r.rs.Resolve(ctx, resolver.Query{Text: q})
+	if err != nil {
+		fmt.Fprintln(r.out, "error:", err)
+		return
+	}
+	fmt.Fprintf(r.out, "=> %s match: %s\n", res.Match, res.Primary.Title)
+}
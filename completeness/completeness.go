@@ -0,0 +1,79 @@
+// Package completeness rates how fully a recipe is described: structured nutrition, a total
+// time, measured ingredients, an image and tags. Scores find the gaps worth filling in the
+// corpus and let demanding callers leave sparse recipes out of their matches.
+package completeness
+
+import (
+	"strings"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/units"
+)
+
+// Aspects of a complete recipe.
+const (
+	// Nutrition is numeric nutritional info for every one of model.MacroNutrients.
+	Nutrition = "nutrition"
+	// Time is a known total time.
+	Time = "total_time"
+	// Ingredients is an amount on every ingredient line, e.g. "200 g flour" or "2 eggs";
+	// seasoning "to taste" needs none.
+	Ingredients = "measured_ingredients"
+	// Image is a photo of the dish.
+	Image = "image"
+	// Tags is at least one tag, e.g. a cuisine or course.
+	Tags = "tags"
+)
+
+// Aspects lists the aspects in the order they are reported. Each weighs the same in a Score.
+var Aspects = []string{Nutrition, Time, Ingredients, Image, Tags}
+
+// Score is the completeness of a recipe.
+type Score struct {
+	// Value is the share of Aspects the recipe has, from 0 to 1.
+	Value float64 `json:"value"`
+	// Missing lists the aspects it lacks, in the order of Aspects.
+	Missing []string `json:"missing"`
+}
+
+// Of returns the completeness of r.
+func Of(r model.Recipe) Score {
+	has := map[string]bool{
+		Nutrition:   structured(r.NutritionalInfo),
+		Time:        r.TotalTime > 0,
+		Ingredients: Measured(r.Ingredients),
+		Image:       strings.TrimSpace(r.ImageURL) != "",
+		Tags:        len(r.Tags) > 0,
+	}
+	s := Score{Missing: []string{}}
+	for _, a := range Aspects {
+		if !has[a] {
+			s.Missing = append(s.Missing, a)
+		}
+	}
+	s.Value = float64(len(Aspects)-len(s.Missing)) / float64(len(Aspects))
+	return s
+}
+
+// structured reports whether info gives every one of model.MacroNutrients as a number. It
+// matches nutrition.Structured, which this package cannot import without a cycle through the
+// resolver.
+func structured(info interface{}) bool {
+	for _, n := range model.MacroNutrients {
+		if _, ok := model.NutrientValue(info, n); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Measured reports whether every ingredient line starts with an amount, other than those
+// added to taste, and there is at least one.
+func Measured(ingredients []string) bool {
+	for _, line := range ingredients {
+		if _, ok := units.ParseQuantity(line); !ok && !strings.HasSuffix(strings.ToLower(strings.TrimSpace(line)), "to taste") {
+			return false
+		}
+	}
+	return len(ingredients) > 0
+}
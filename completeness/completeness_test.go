@@ -0,0 +1,52 @@
+package completeness
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+func TestOf(t *testing.T) {
+	tests := []struct {
+		name    string
+		recipe  model.Recipe
+		value   float64
+		missing []string
+	}{
+		{
+			name:    "empty",
+			recipe:  model.Recipe{},
+			value:   0,
+			missing: Aspects,
+		},
+		{
+			name: "complete",
+			recipe: model.Recipe{
+				NutritionalInfo: map[string]int{"calories": 420, "protein": 30, "carbohydrates": 12, "fat": 25},
+				TotalTime:       25,
+				Ingredients:     []string{"2 chicken breasts", "1 tbsp olive oil", "Salt to taste"},
+				ImageURL:        "https://cdn.example.com/chicken.jpg",
+				Tags:            []string{model.CuisineTag("french")},
+			},
+			value:   1,
+			missing: []string{},
+		},
+		{
+			name: "unmeasured",
+			recipe: model.Recipe{
+				NutritionalInfo: map[string]int{"calories": 420},
+				TotalTime:       25,
+				Ingredients:     []string{"2 chicken breasts", "olive oil"},
+			},
+			value:   0.2,
+			missing: []string{Nutrition, Ingredients, Image, Tags},
+		},
+	}
+	for _, tt := range tests {
+		got := Of(tt.recipe)
+		if got.Value != tt.value || fmt.Sprint(got.Missing) != fmt.Sprint(tt.missing) {
+			t.Errorf("%s: expected %v missing %v, got %+v", tt.name, tt.value, tt.missing, got)
+		}
+	}
+}
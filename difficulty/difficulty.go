@@ -0,0 +1,113 @@
+// Package difficulty estimates how demanding a recipe is from its ingredient count, step
+// count, the techniques its steps call for and its total time.
+package difficulty
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// techniques maps technique keywords found in steps to the points they add. Keywords are
+// matched as word prefixes, so "temper" also matches "tempered".
+var techniques = map[string]int{
+	// Demanding techniques that are easy to get wrong.
+	"temper": 2, "laminat": 2, "flambé": 2, "flambe": 2, "sous vide": 2, "soufflé": 2,
+	"souffle": 2, "emulsif": 2, "clarif": 2, "debone": 2, "butcher": 2, "proof": 1,
+	// Techniques that need attention but not much experience.
+	"caramelize": 1, "caramelise": 1, "deglaze": 1, "braise": 1, "knead": 1, "fold in": 1,
+	"julienne": 1, "blanch": 1, "reduce": 1, "whisk until stiff": 1, "poach": 1, "sear": 1,
+}
+
+// durationPattern matches durations such as "20 minutes", "1 hr" or "1-2 hours".
+var durationPattern = regexp.MustCompile(`(?i)(\d+)(?:\s*(?:-|–|to)\s*(\d+))?\s*(hours?|hrs?|minutes?|mins?)\b`)
+
+// TotalMinutes estimates a recipe's total time by adding up the durations mentioned in its
+// steps, taking the upper end of ranges.
+func TotalMinutes(r model.Recipe) int {
+	total := 0
+	for _, step := range r.Steps {
+		for _, m := range durationPattern.FindAllStringSubmatch(step, -1) {
+			n, _ := strconv.Atoi(m[1])
+			if m[2] != "" {
+				n, _ = strconv.Atoi(m[2])
+			}
+			if strings.HasPrefix(strings.ToLower(m[3]), "h") {
+				n *= 60
+			}
+			total += n
+		}
+	}
+	return total
+}
+
+// Points returns the raw difficulty score behind Estimate.
+func Points(r model.Recipe) int {
+	points := 0
+	switch n := len(r.Ingredients); {
+	case n > 12:
+		points += 2
+	case n > 7:
+		points++
+	}
+	switch n := len(r.Steps); {
+	case n > 10:
+		points += 2
+	case n > 5:
+		points++
+	}
+	text := strings.ToLower(strings.Join(r.Steps, "\n"))
+	for keyword, p := range techniques {
+		if containsWordPrefix(text, keyword) {
+			points += p
+		}
+	}
+	switch minutes := TotalMinutes(r); {
+	case minutes > 120:
+		points += 2
+	case minutes > 45:
+		points++
+	}
+	return points
+}
+
+// Estimate rates a recipe easy, medium or hard.
+func Estimate(r model.Recipe) model.Difficulty {
+	switch p := Points(r); {
+	case p <= 1:
+		return model.DifficultyEasy
+	case p <= 4:
+		return model.DifficultyMedium
+	default:
+		return model.DifficultyHard
+	}
+}
+
+// Fill returns r with its difficulty estimated if it has none (or an unknown one).
+func Fill(r model.Recipe) model.Recipe {
+	if r.Difficulty.Level() == 0 {
+		r.Difficulty = Estimate(r)
+	}
+	return r
+}
+
+// containsWordPrefix reports whether word occurs in text at the start of a word.
+func containsWordPrefix(text, word string) bool {
+	for i := 0; ; {
+		j := strings.Index(text[i:], word)
+		if j < 0 {
+			return false
+		}
+		j += i
+		if j == 0 || !isLetter(text[j-1]) {
+			return true
+		}
+		i = j + 1
+	}
+}
+
+func isLetter(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 0x80
+}
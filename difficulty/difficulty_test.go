@@ -0,0 +1,62 @@
+package difficulty
+
+import (
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		name   string
+		recipe model.Recipe
+		want   model.Difficulty
+	}{
+		{
+			name: "toast",
+			recipe: model.Recipe{
+				Ingredients: []string{"bread", "butter"},
+				Steps:       []string{"Toast the bread for 2 minutes.", "Spread with butter."},
+			},
+			want: model.DifficultyEasy,
+		},
+		{
+			name: "braised short ribs",
+			recipe: model.Recipe{
+				Ingredients: []string{"short ribs", "red wine", "stock", "onion", "carrot", "celery", "garlic", "thyme"},
+				Steps:       []string{"Sear the ribs.", "Deglaze with wine.", "Braise for 3 hours."},
+			},
+			want: model.DifficultyHard,
+		},
+		{
+			name: "chocolate soufflé",
+			recipe: model.Recipe{
+				Ingredients: []string{"chocolate", "eggs", "sugar", "butter"},
+				Steps:       []string{"Melt the chocolate.", "Whisk the whites.", "Fold in the whites gently.", "Bake the soufflés for 12-14 minutes."},
+			},
+			want: model.DifficultyMedium,
+		},
+	}
+	for _, tt := range tests {
+		if got := Estimate(tt.recipe); got != tt.want {
+			t.Errorf("%s: Estimate = %s (%d points), want %s", tt.name, got, Points(tt.recipe), tt.want)
+		}
+	}
+}
+
+func TestTotalMinutes(t *testing.T) {
+	r := model.Recipe{Steps: []string{"Simmer for 1 hr.", "Rest 10-15 minutes.", "Serve."}}
+	if got := TotalMinutes(r); got != 75 {
+		t.Errorf("TotalMinutes = %d, want 75", got)
+	}
+}
+
+func TestFillKeepsExistingDifficulty(t *testing.T) {
+	r := Fill(model.Recipe{Difficulty: model.DifficultyHard})
+	if r.Difficulty != model.DifficultyHard {
+		t.Errorf("Expected the existing difficulty to be kept, got %s", r.Difficulty)
+	}
+	if r := Fill(model.Recipe{Difficulty: "tricky"}); r.Difficulty != model.DifficultyEasy {
+		t.Errorf("Expected an unknown difficulty to be re-estimated, got %s", r.Difficulty)
+	}
+}
@@ -0,0 +1,162 @@
+// Package experiment assigns requests to the variants of A/B experiments on ranking and
+// prompts. Assignment hashes a stable unit, such as a session or user ID, so a caller sees the
+// same variant on every request while the rollout percentages are unchanged.
+package experiment
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Variant is one arm of an experiment. Its zero-valued fields leave the deployment's setting
+// in force.
+type Variant struct {
+	Name string `json:"name"`
+	// Percent is the share of units, out of 100, assigned to the variant.
+	Percent int `json:"percent"`
+	// Threshold overrides the similarity threshold for a close match.
+	Threshold float64 `json:"threshold,omitempty"`
+	// Weights overrides the weights of the named scorers, e.g. {"jaccard": 0.5}.
+	Weights map[string]float64 `json:"weights,omitempty"`
+	// PromptTemplate replaces the generation prompt template (see generation.PromptTemplate).
+	PromptTemplate string `json:"prompt_template,omitempty"`
+
+	prompt *template.Template
+}
+
+// Prompt returns the parsed PromptTemplate, or nil if the variant keeps the default prompt.
+func (v Variant) Prompt() *template.Template {
+	return v.prompt
+}
+
+// Experiment splits units between its variants. Units outside every variant's share form the
+// control group and are not assigned.
+type Experiment struct {
+	Name     string    `json:"name"`
+	Variants []Variant `json:"variants"`
+}
+
+// bucket maps unit to one of 100 buckets, independently for each experiment.
+func (e Experiment) bucket(unit string) int {
+	h := fnv.New32a()
+	h.Write([]byte(e.Name))
+	h.Write([]byte{0})
+	h.Write([]byte(unit))
+	return int(h.Sum32() % 100)
+}
+
+// Assign returns the variant unit belongs to, or false if it is in the control group.
+func (e Experiment) Assign(unit string) (Variant, bool) {
+	b := e.bucket(unit)
+	for _, v := range e.Variants {
+		if b < v.Percent {
+			return v, true
+		}
+		b -= v.Percent
+	}
+	return Variant{}, false
+}
+
+// Assignment is the variant of one experiment a request was assigned to.
+type Assignment struct {
+	Experiment string
+	Variant    Variant
+}
+
+// String returns the assignment as "experiment=variant".
+func (a Assignment) String() string {
+	return a.Experiment + "=" + a.Variant.Name
+}
+
+// Tags returns assignments as "experiment=variant" pairs joined by commas, for tagging
+// responses, cache keys and metrics; it is empty for no assignments.
+func Tags(assignments []Assignment) string {
+	tags := make([]string, len(assignments))
+	for i, a := range assignments {
+		tags[i] = a.String()
+	}
+	return strings.Join(tags, ",")
+}
+
+// Config is the set of running experiments.
+type Config struct {
+	Experiments []Experiment `json:"experiments"`
+}
+
+// Assign returns the assignments of unit in every experiment it is not a control in, sorted by
+// experiment name. An empty unit is never assigned.
+func (c *Config) Assign(unit string) []Assignment {
+	if c == nil || unit == "" {
+		return nil
+	}
+	var out []Assignment
+	for _, e := range c.Experiments {
+		if v, ok := e.Assign(unit); ok {
+			out = append(out, Assignment{Experiment: e.Name, Variant: v})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Experiment < out[j].Experiment })
+	return out
+}
+
+// Validate checks that experiments and their variants are named uniquely, that the variants of
+// an experiment share at most 100 percent, and parses their prompt templates.
+func (c *Config) Validate() error {
+	experiments := make(map[string]bool)
+	for i, e := range c.Experiments {
+		if e.Name == "" {
+			return fmt.Errorf("experiment %d needs a name", i)
+		}
+		if experiments[e.Name] {
+			return fmt.Errorf("duplicate experiment %q", e.Name)
+		}
+		experiments[e.Name] = true
+		variants, total := make(map[string]bool), 0
+		for j := range e.Variants {
+			v := &e.Variants[j]
+			if v.Name == "" || variants[v.Name] {
+				return fmt.Errorf("experiment %q: variant %d needs a unique name", e.Name, j)
+			}
+			variants[v.Name] = true
+			if v.Percent < 0 {
+				return fmt.Errorf("experiment %q: variant %q has a negative percent", e.Name, v.Name)
+			}
+			total += v.Percent
+			if v.Threshold < 0 || v.Threshold > 1 {
+				return fmt.Errorf("experiment %q: variant %q threshold must be between 0 and 1", e.Name, v.Name)
+			}
+			if v.PromptTemplate != "" {
+				t, err := template.New(v.Name).Parse(v.PromptTemplate)
+				if err != nil {
+					return fmt.Errorf("experiment %q: variant %q: %w", e.Name, v.Name, err)
+				}
+				v.prompt = t
+			}
+		}
+		if total > 100 {
+			return fmt.Errorf("experiment %q: variants add up to %d percent", e.Name, total)
+		}
+	}
+	return nil
+}
+
+// Load reads and validates a JSON Config file.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parsing experiments file %s: %w", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("parsing experiments file %s: %w", path, err)
+	}
+	return &c, nil
+}
@@ -0,0 +1,70 @@
+package experiment
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAssign(t *testing.T) {
+	e := Experiment{Name: "threshold", Variants: []Variant{{Name: "low", Percent: 20}, {Name: "high", Percent: 30}}}
+	counts := make(map[string]int)
+	for i := 0; i < 10000; i++ {
+		unit := fmt.Sprintf("session-%d", i)
+		v, ok := e.Assign(unit)
+		if again, _ := e.Assign(unit); again.Name != v.Name {
+			t.Fatalf("Assignment of %s is not stable: %q then %q", unit, v.Name, again.Name)
+		}
+		if !ok {
+			v.Name = "control"
+		}
+		counts[v.Name]++
+	}
+	for name, want := range map[string]int{"low": 2000, "high": 3000, "control": 5000} {
+		if got := counts[name]; got < want*9/10 || got > want*11/10 {
+			t.Errorf("Expected about %d units in %s, got %d", want, name, got)
+		}
+	}
+}
+
+func TestConfigAssign(t *testing.T) {
+	c := &Config{Experiments: []Experiment{
+		{Name: "weights", Variants: []Variant{{Name: "all", Percent: 100}}},
+		{Name: "off", Variants: []Variant{{Name: "none", Percent: 0}}},
+	}}
+	got := c.Assign("user-1")
+	if Tags(got) != "weights=all" {
+		t.Errorf("Expected assignment to weights=all only, got %q", Tags(got))
+	}
+	if c.Assign("") != nil || (*Config)(nil).Assign("user-1") != nil {
+		t.Error("Expected no assignments without a unit or config")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "experiments.json")
+	os.WriteFile(path, []byte(`{"experiments": [{"name": "prompt", "variants": [
+		{"name": "terse", "percent": 50, "prompt_template": "Recipe for {{.Query}} as JSON."}
+	]}]}`), 0o644)
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Experiments[0].Variants[0].Prompt() == nil {
+		t.Error("Expected the prompt template to be parsed")
+	}
+
+	for name, bad := range map[string]string{
+		"over 100 percent":  `{"experiments": [{"name": "e", "variants": [{"name": "a", "percent": 60}, {"name": "b", "percent": 50}]}]}`,
+		"duplicate variant": `{"experiments": [{"name": "e", "variants": [{"name": "a"}, {"name": "a"}]}]}`,
+		"bad template":      `{"experiments": [{"name": "e", "variants": [{"name": "a", "prompt_template": "{{.Query"}]}]}`,
+		"bad threshold":     `{"experiments": [{"name": "e", "variants": [{"name": "a", "threshold": 2}]}]}`,
+	} {
+		os.WriteFile(path, []byte(bad), 0o644)
+		if _, err := Load(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
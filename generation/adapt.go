@@ -0,0 +1,191 @@
+package generation
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// stepNumber matches the numbering of a step given as a line of text, e.g. "1. ", "2) " or
+// "Step 3: ".
+var stepNumber = regexp.MustCompile(`(?i)^(step\s*)?\d+\s*[.):-]\s*`)
+
+// looseResponse is the recipe JSON of a reply as models actually write it: besides
+// primary_recipe and alternative_recipes, some answer with a single alternative_recipe or with
+// a recipes array whose first entry is the primary recipe.
+type looseResponse struct {
+	PrimaryRecipe      *looseRecipe    `json:"primary_recipe"`
+	AlternativeRecipes json.RawMessage `json:"alternative_recipes"`
+	AlternativeRecipe  json.RawMessage `json:"alternative_recipe"`
+	Recipes            json.RawMessage `json:"recipes"`
+}
+
+// looseRecipe is a Recipe whose ingredients and steps may also be given as one newline-joined
+// string or as objects, e.g. {"quantity": 200, "unit": "g", "name": "flour"}.
+type looseRecipe struct {
+	Recipe
+	Ingredients json.RawMessage `json:"ingredients"`
+	Steps       json.RawMessage `json:"steps"`
+}
+
+// decodeLLMResponse decodes the recipe JSON of a reply into an LLMResponse, adapting the
+// deviations from its schema common among models (see looseResponse and looseRecipe).
+func decodeLLMResponse(data []byte) (LLMResponse, error) {
+	var loose looseResponse
+	if err := json.Unmarshal(data, &loose); err != nil {
+		return LLMResponse{}, decodeError(err, data)
+	}
+	var resp LLMResponse
+	var recipes []looseRecipe
+	for _, raw := range []json.RawMessage{loose.Recipes, loose.AlternativeRecipes, loose.AlternativeRecipe} {
+		list, err := recipeList(raw)
+		if err != nil {
+			return LLMResponse{}, decodeError(err, nil)
+		}
+		recipes = append(recipes, list...)
+	}
+	if loose.PrimaryRecipe != nil {
+		recipes = append([]looseRecipe{*loose.PrimaryRecipe}, recipes...)
+	}
+	for i, lr := range recipes {
+		r, err := lr.adapt()
+		if err != nil {
+			return LLMResponse{}, decodeError(err, nil)
+		}
+		if i == 0 {
+			resp.PrimaryRecipe = r
+		} else {
+			resp.AlternativeRecipes = append(resp.AlternativeRecipes, r)
+		}
+	}
+	return resp, nil
+}
+
+// decodeRecipe decodes the JSON of a single recipe, adapting its ingredients and steps like
+// decodeLLMResponse.
+func decodeRecipe(data []byte) (Recipe, error) {
+	var lr looseRecipe
+	if err := json.Unmarshal(data, &lr); err != nil {
+		return Recipe{}, decodeError(err, data)
+	}
+	r, err := lr.adapt()
+	if err != nil {
+		return Recipe{}, decodeError(err, nil)
+	}
+	return r, nil
+}
+
+// recipeList decodes raw as an array of recipes or a single one. It returns nothing for a
+// missing or null value.
+func recipeList(raw json.RawMessage) ([]looseRecipe, error) {
+	switch v := strings.TrimSpace(string(raw)); {
+	case v == "" || v == "null":
+		return nil, nil
+	case strings.HasPrefix(v, "{"):
+		var r looseRecipe
+		err := json.Unmarshal(raw, &r)
+		return []looseRecipe{r}, err
+	}
+	var list []looseRecipe
+	err := json.Unmarshal(raw, &list)
+	return list, err
+}
+
+// adapt returns lr as a Recipe.
+func (lr looseRecipe) adapt() (Recipe, error) {
+	r := lr.Recipe
+	var err error
+	if r.Ingredients, err = lines(lr.Ingredients, ingredientLine, false); err != nil {
+		return Recipe{}, fmt.Errorf("ingredients of %q: %w", r.Title, err)
+	}
+	if r.Steps, err = lines(lr.Steps, stepLine, true); err != nil {
+		return Recipe{}, fmt.Errorf("steps of %q: %w", r.Title, err)
+	}
+	return r, nil
+}
+
+// lines decodes raw as a list of lines: an array of strings or of objects turned into text by
+// object, or a single newline-joined string. numbered strips the numbering of lines split from
+// a string.
+func lines(raw json.RawMessage, object func(map[string]interface{}) string, numbered bool) ([]string, error) {
+	var v interface{}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+	}
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		var out []string
+		for _, line := range strings.Split(v, "\n") {
+			line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-*•"))
+			if numbered {
+				line = stepNumber.ReplaceAllString(line, "")
+			}
+			if line != "" {
+				out = append(out, line)
+			}
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item := item.(type) {
+			case string:
+				out = append(out, item)
+			case map[string]interface{}:
+				if line := object(item); line != "" {
+					out = append(out, line)
+				}
+			default:
+				return nil, fmt.Errorf("unexpected list entry %v", item)
+			}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("expected a list, got %s", raw)
+}
+
+// ingredientLine writes an ingredient object as a line, e.g. "200 g flour, sifted" for
+// {"quantity": 200, "unit": "g", "name": "flour", "notes": "sifted"}.
+func ingredientLine(obj map[string]interface{}) string {
+	var parts []string
+	for _, keys := range [][]string{{"quantity", "amount", "qty"}, {"unit", "units"}, {"name", "ingredient", "item"}} {
+		if s := field(obj, keys...); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	line := strings.Join(parts, " ")
+	if notes := field(obj, "notes", "note", "preparation"); notes != "" && line != "" {
+		line += ", " + notes
+	}
+	return line
+}
+
+// stepLine returns the instruction of a step object, e.g. {"step": 1, "instruction": "Boil."}.
+func stepLine(obj map[string]interface{}) string {
+	for _, k := range []string{"instruction", "text", "description", "step"} {
+		if s, ok := obj[k].(string); ok && strings.TrimSpace(s) != "" {
+			return strings.TrimSpace(s)
+		}
+	}
+	return ""
+}
+
+// field returns the first of keys that obj gives as a string or number, trimmed.
+func field(obj map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		switch v := obj[k].(type) {
+		case string:
+			if s := strings.TrimSpace(v); s != "" {
+				return s
+			}
+		case float64:
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
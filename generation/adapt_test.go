@@ -0,0 +1,81 @@
+package generation
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// TestDecodeLLMResponse covers the deviations from LLMResponse that decodeLLMResponse adapts.
+func TestDecodeLLMResponse(t *testing.T) {
+	tests := []struct {
+		name         string
+		json         string
+		title        string
+		ingredients  []string
+		steps        []string
+		alternatives []string
+	}{
+		{
+			name:         "standard",
+			json:         `{"primary_recipe": {"title": "Soup", "ingredients": ["water"], "steps": ["Boil."]}, "alternative_recipes": [{"title": "Broth"}]}`,
+			title:        "Soup",
+			ingredients:  []string{"water"},
+			steps:        []string{"Boil."},
+			alternatives: []string{"Broth"},
+		},
+		{
+			name:         "singular alternative",
+			json:         `{"primary_recipe": {"title": "Soup"}, "alternative_recipe": {"title": "Broth"}}`,
+			title:        "Soup",
+			alternatives: []string{"Broth"},
+		},
+		{
+			name:         "recipes array",
+			json:         `{"recipes": [{"title": "Soup"}, {"title": "Broth"}, {"title": "Stock"}]}`,
+			title:        "Soup",
+			alternatives: []string{"Broth", "Stock"},
+		},
+		{
+			name:        "ingredient objects",
+			json:        `{"primary_recipe": {"title": "Soup", "ingredients": [{"quantity": 1.5, "unit": "l", "name": "water"}, {"item": "salt", "note": "to taste"}, {}, "1 onion"]}}`,
+			title:       "Soup",
+			ingredients: []string{"1.5 l water", "salt, to taste", "1 onion"},
+		},
+		{
+			name:  "steps string",
+			json:  `{"primary_recipe": {"title": "Soup", "steps": "1. Chop the onion.\n\n2) Boil for 10 minutes.\n- Season."}}`,
+			title: "Soup",
+			steps: []string{"Chop the onion.", "Boil for 10 minutes.", "Season."},
+		},
+		{
+			name:  "step objects",
+			json:  `{"primary_recipe": {"title": "Soup", "steps": [{"step": 1, "instruction": "Boil."}, {"text": "Season."}]}}`,
+			title: "Soup",
+			steps: []string{"Boil.", "Season."},
+		},
+	}
+	for _, tt := range tests {
+		resp, err := decodeLLMResponse([]byte(tt.json))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var alternatives []string
+		for _, alt := range resp.AlternativeRecipes {
+			alternatives = append(alternatives, alt.Title)
+		}
+		if p := resp.PrimaryRecipe; p.Title != tt.title || fmt.Sprint(p.Ingredients) != fmt.Sprint(tt.ingredients) || fmt.Sprint(p.Steps) != fmt.Sprint(tt.steps) || fmt.Sprint(alternatives) != fmt.Sprint(tt.alternatives) {
+			t.Errorf("%s: got %+v with alternatives %v", tt.name, p, alternatives)
+		}
+	}
+
+	for _, bad := range []string{`{"primary_recipe": {"title": "Soup", "steps": 3}}`, `{"recipes": "Soup"}`, `{"primary_recipe": {"title": "Soup", "ingredients": [["water"]]}}`} {
+		if _, err := decodeLLMResponse([]byte(bad)); !errors.Is(err, ErrUnparseableResponse) {
+			t.Errorf("%s: expected ErrUnparseableResponse, got %v", bad, err)
+		}
+	}
+	if _, err := decodeLLMResponse([]byte(`{"primary_recipe": {"title": "So`)); !errors.Is(err, ErrTruncated) {
+		t.Errorf("Expected ErrTruncated for a cut-off reply, got %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package generation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Defaults of the prompt budget.
+const (
+	// DefaultContextWindow is the context window, in tokens, of models whose Route does not set
+	// one.
+	DefaultContextWindow = 8192
+	// DefaultReplyTokens is the part of the context window kept free for the reply.
+	DefaultReplyTokens = 2048
+)
+
+// ContextWindow and ReplyTokens budget the prompt of every generation: it may take up the
+// context window of the model (Route.ContextWindow, or else ContextWindow) less ReplyTokens.
+// Grounding examples (see WithExamples) that do not fit are dropped, and a prompt that does not
+// fit on its own fails with ErrPromptTooLong rather than being truncated by the provider.
+var (
+	ContextWindow = DefaultContextWindow
+	ReplyTokens   = DefaultReplyTokens
+)
+
+// ErrPromptTooLong is returned by GenerateRecipeContext when the prompt, without grounding
+// examples, exceeds the budget.
+var ErrPromptTooLong = errors.New("prompt exceeds the model's context window")
+
+// EstimateTokens estimates the number of tokens text takes up for a tiktoken byte-pair encoding
+// such as cl100k_base. It splits text as the encoding's pre-tokenizer does: a word with its
+// leading space, a number in groups of up to three digits, a run of punctuation with the line
+// breaks after it, a run of other whitespace. Short words count as one token and longer ones as
+// one per five letters, while letters outside ASCII, which the encoding rarely merges, count one
+// each. The estimate errs on the high side for ordinary English prose.
+func EstimateTokens(text string) int {
+	tokens := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		start := i
+		run := func(in func(rune) bool) int {
+			n := 0
+			for i < len(text) {
+				r, size := utf8.DecodeRuneInString(text[i:])
+				if !in(r) {
+					break
+				}
+				i += size
+				n++
+			}
+			return n
+		}
+		switch {
+		case r == ' ' && i+size < len(text) && !unicode.IsSpace(rune(text[i+size])):
+			// A single space belongs to the piece after it.
+			i += size
+		case unicode.IsLetter(r):
+			ascii := run(func(r rune) bool { return r < utf8.RuneSelf && unicode.IsLetter(r) })
+			if ascii > 0 {
+				tokens += (ascii + 4) / 5
+			} else {
+				tokens += run(func(r rune) bool { return r >= utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsMark(r)) })
+			}
+		case unicode.IsDigit(r):
+			tokens += (run(unicode.IsDigit) + 2) / 3
+		case unicode.IsSpace(r):
+			run(unicode.IsSpace)
+			tokens++
+		default:
+			tokens += (run(func(r rune) bool { return !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsDigit(r) }) + 1) / 2
+			run(func(r rune) bool { return r == '\r' || r == '\n' }) // line breaks join the punctuation
+		}
+		if i == start {
+			i += size
+		}
+	}
+	return tokens
+}
+
+type examplesKey struct{}
+
+// WithExamples returns a copy of ctx carrying grounding examples, most relevant first, which
+// GenerateRecipeContext appends to the prompt as far as the budget allows.
+func WithExamples(ctx context.Context, examples []Recipe) context.Context {
+	return context.WithValue(ctx, examplesKey{}, examples)
+}
+
+// ExamplesFrom returns the grounding examples carried by ctx.
+func ExamplesFrom(ctx context.Context) []Recipe {
+	examples, _ := ctx.Value(examplesKey{}).([]Recipe)
+	return examples
+}
+
+// promptBudget returns the number of prompt tokens available on route.
+func promptBudget(route Route) int {
+	window := route.ContextWindow
+	if window <= 0 {
+		window = ContextWindow
+	}
+	return window - ReplyTokens
+}
+
+// fitPrompt appends to prompt as many of examples, in order, as fit within budget tokens, and
+// returns the result with the number of examples dropped. It fails with ErrPromptTooLong if
+// prompt alone does not fit.
+func fitPrompt(prompt string, examples []Recipe, budget int) (string, int, error) {
+	used := EstimateTokens(prompt)
+	if used > budget {
+		return "", 0, fmt.Errorf("%w: about %d tokens for a budget of %d", ErrPromptTooLong, used, budget)
+	}
+	if len(examples) == 0 {
+		return prompt, 0, nil
+	}
+	const intro = " Existing recipes to follow in format and level of detail:"
+	var b strings.Builder
+	b.WriteString(prompt)
+	kept := 0
+	for _, e := range examples {
+		// Only the fields that show the expected form are sent.
+		example, err := json.Marshal(struct {
+			Title       string   `json:"title"`
+			Ingredients []string `json:"ingredients"`
+			Steps       []string `json:"steps"`
+		}{e.Title, e.Ingredients, e.Steps})
+		if err != nil {
+			return "", 0, err
+		}
+		piece := " " + string(example)
+		if kept == 0 {
+			piece = intro + piece
+		}
+		cost := EstimateTokens(piece)
+		if used+cost > budget {
+			break
+		}
+		b.WriteString(piece)
+		used += cost
+		kept++
+	}
+	if dropped := len(examples) - kept; dropped > 0 {
+		log.Printf("Generation: Dropped %d of %d grounding examples to fit the prompt budget of %d tokens", dropped, len(examples), budget)
+	}
+	return b.String(), len(examples) - kept, nil
+}
@@ -0,0 +1,54 @@
+package generation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Errors returned by the package, alongside ErrPromptTooLong, so that callers can tell failures
+// apart with errors.Is and errors.As.
+var (
+	// ErrNoEndpoint is returned when neither the route nor LLM_ENDPOINT names a provider.
+	ErrNoEndpoint = errors.New("LLM_ENDPOINT environment variable not set")
+	// ErrUnparseableResponse is returned, wrapping the decoding error, when the provider's reply
+	// is not in the expected format.
+	ErrUnparseableResponse = errors.New("unparseable provider response")
+	// ErrTruncated is returned when the provider's reply was cut off, e.g. at its output token
+	// limit, before the recipe JSON was complete.
+	ErrTruncated = errors.New("provider response truncated")
+	// ErrNoDish is returned by DescribeImage when the provider recognizes no dish in the image.
+	ErrNoDish = errors.New("no dish recognized in the image")
+	// ErrNoRecipe is returned by ExtractRecipe when the image holds no complete recipe.
+	ErrNoRecipe = errors.New("no recipe found in the image")
+)
+
+// ErrProviderStatus is returned when the provider answers with a status other than 200 OK.
+type ErrProviderStatus struct {
+	// Code is the HTTP status code, e.g. 429.
+	Code int
+}
+
+// Error implements error.
+func (e *ErrProviderStatus) Error() string {
+	return fmt.Sprintf("LLM endpoint returned non-200 status: %d %s", e.Code, http.StatusText(e.Code))
+}
+
+// Temporary reports whether the request may succeed if retried later: the provider was rate
+// limiting or failing (429 or 5xx), rather than rejecting the request itself.
+func (e *ErrProviderStatus) Temporary() bool {
+	return e.Code == http.StatusTooManyRequests || e.Code >= 500
+}
+
+// decodeError classifies an error decoding data, the provider's reply or the part of it holding
+// the recipe JSON, as ErrTruncated if the JSON ended early and ErrUnparseableResponse otherwise.
+// data is nil when the reply was decoded as a stream.
+func decodeError(err error, data []byte) error {
+	var syntax *json.SyntaxError
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &syntax) && data != nil && syntax.Offset >= int64(len(data)) {
+		return fmt.Errorf("%w: %v", ErrTruncated, err)
+	}
+	return fmt.Errorf("%w: %v", ErrUnparseableResponse, err)
+}
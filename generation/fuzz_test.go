@@ -0,0 +1,75 @@
+package generation
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// fuzzSeeds are representative model replies, including nested and unterminated fences.
+var fuzzSeeds = []string{
+	"{\"primary_recipe\": {\"title\": \"Soup\"}}",
+	"```json\n{\"primary_recipe\": {\"title\": \"Soup\"}}\n```",
+	"```\n{\"title\": \"```nested```\"}\n```",
+	"```json```",
+	"```",
+	"Here is your recipe: {\"title\": \"Crème {brûlée}\"} Enjoy!",
+	"{\"title\": \"unterminated",
+	"no json at all",
+	"",
+}
+
+// FuzzStripCodeFences verifies that stripCodeFences never panics and never leaves
+// surrounding whitespace or grows its input.
+func FuzzStripCodeFences(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		out := stripCodeFences(s)
+		if out != strings.TrimSpace(out) {
+			t.Fatalf("stripCodeFences(%q) = %q has surrounding whitespace", s, out)
+		}
+		if len(out) > len(s) {
+			t.Fatalf("stripCodeFences(%q) = %q is longer than its input", s, out)
+		}
+	})
+}
+
+// FuzzExtractJSON verifies that a valid JSON object is always returned unchanged, and that
+// whenever extraction yields a brace-delimited value it is a substring of the input.
+func FuzzExtractJSON(f *testing.F) {
+	for _, seed := range fuzzSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		out := extractJSON(s)
+		trimmed := strings.TrimSpace(s)
+		var obj map[string]interface{}
+		if !strings.HasPrefix(trimmed, "```") && json.Unmarshal([]byte(trimmed), &obj) == nil && strings.HasPrefix(trimmed, "{") {
+			if out != trimmed {
+				t.Fatalf("extractJSON(%q) = %q, want the object unchanged", s, out)
+			}
+		}
+		if strings.HasPrefix(out, "{") && strings.HasSuffix(out, "}") && !strings.Contains(s, out) {
+			t.Fatalf("extractJSON(%q) = %q is not a substring of its input", s, out)
+		}
+	})
+}
+
+// TestExtractJSON covers the reply shapes that motivated extractJSON.
+func TestExtractJSON(t *testing.T) {
+	cases := map[string]string{
+		"{\"a\": 1}":                              "{\"a\": 1}",
+		"```json\n{\"a\": 1}\n```":                "{\"a\": 1}",
+		"Sure! {\"a\": \"}{\"} Hope it helps {x}": "{\"a\": \"}{\"}",
+		"```\n{\"a\": \"```\"}\n```":              "{\"a\": \"```\"}",
+		"{\"a\": \"esc \\\" } quote\"} trailing":  "{\"a\": \"esc \\\" } quote\"}",
+		"no object here":                          "no object here",
+	}
+	for in, want := range cases {
+		if got := extractJSON(in); got != want {
+			t.Errorf("extractJSON(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
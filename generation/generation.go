@@ -4,12 +4,18 @@ package generation
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
-	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"strings"
+	"text/template"
 	"time"
 )
 
@@ -24,6 +30,8 @@ type Recipe struct {
 	NutritionalInfo   interface{} `json:"nutritional_info"`
 	AllergyDisclaimer string      `json:"allergy_disclaimer"`
 	Appliances        []string    `json:"appliances"`
+	Difficulty        string      `json:"difficulty,omitempty"`
+	TotalTime         int         `json:"total_time,omitempty"`
 	CreatedAt         string      `json:"created_at"`
 	UpdatedAt         string      `json:"updated_at"`
 }
@@ -32,6 +40,10 @@ type Recipe struct {
 // For providers compatible with our simple prompt model.
 type llmRequest struct {
 	Prompt string `json:"prompt"`
+	// Model is only sent when a route selects one.
+	Model string `json:"model,omitempty"`
+	// Images holds the base64-encoded images attached to the prompt, if any.
+	Images []string `json:"images,omitempty"`
 }
 
 // LLMResponse defines the structure of the expected response from the LLM endpoint.
@@ -66,6 +78,134 @@ type DeepSeekResponse struct {
 // HTTPClient is a package-level HTTP client which can be overridden in tests.
 var HTTPClient = &http.Client{Timeout: 90 * time.Second}
 
+// DefaultPromptTemplate is the prompt sent to the provider. {{.Query}} is replaced by the
+// user's recipe query; {{.Tone}}, {{.Measurement}} and {{.Verbosity}} hold the requested style
+// (see Options) and are empty when unset.
+const DefaultPromptTemplate = "Generate a recipe based on the following query: \"{{.Query}}\". " +
+	"Return a JSON object with two keys: 'primary_recipe' and 'alternative_recipes'. " +
+	"The 'primary_recipe' should be a JSON object representing the main recipe with keys: " +
+	"id, title, ingredients, steps, nutritional_info, allergy_disclaimer, appliances, created_at, and updated_at. " +
+	"The 'alternative_recipes' should be an array of recipe objects following the same structure." +
+	`{{if eq .Tone "concise"}} Keep the wording concise, without commentary or pleasantries.` +
+	`{{else if eq .Tone "chatty"}} Write in a warm, conversational tone.{{end}}` +
+	`{{if eq .Measurement "metric"}} Use metric measurements: grams, millilitres and degrees Celsius.` +
+	`{{else if eq .Measurement "us"}} Use US customary measurements: cups, ounces and degrees Fahrenheit.{{end}}` +
+	`{{if eq .Verbosity "brief"}} Keep each step to one short sentence.` +
+	`{{else if eq .Verbosity "detailed"}} Describe each step in detail, including timings and visual cues.{{end}}`
+
+// PromptTemplate is the package-level prompt template which can be overridden, e.g. by the
+// prompt evaluation suite when comparing a candidate template against the current one.
+var PromptTemplate = template.Must(template.New("prompt").Parse(DefaultPromptTemplate))
+
+// promptData is the data PromptTemplate is executed with.
+type promptData struct {
+	Query       string
+	Tone        Tone
+	Measurement Measurement
+	Verbosity   Verbosity
+}
+
+// BuildPrompt renders PromptTemplate for query.
+func BuildPrompt(query string) (string, error) {
+	return BuildPromptOptions(query, Options{})
+}
+
+// BuildPromptOptions renders PromptTemplate for query in the style requested by opts.
+func BuildPromptOptions(query string, opts Options) (string, error) {
+	return buildPrompt(PromptTemplate, query, opts)
+}
+
+// buildPrompt renders t for query in the style requested by opts.
+func buildPrompt(t *template.Template, query string, opts Options) (string, error) {
+	var b strings.Builder
+	data := promptData{Query: query, Tone: opts.Tone, Measurement: opts.Measurement, Verbosity: opts.Verbosity}
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// templateProbe is the query CheckTemplate renders templates for.
+const templateProbe = "template probe query"
+
+// CheckTemplate checks that t renders in every tone, measurement system and verbosity and puts
+// the query in the prompt, e.g. before a prompt template supplied from outside the deployment
+// is used.
+func CheckTemplate(t *template.Template) error {
+	for _, opts := range []Options{{}, {Tone: ToneConcise, Measurement: MeasurementMetric, Verbosity: VerbosityBrief}} {
+		prompt, err := buildPrompt(t, templateProbe, opts)
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(prompt, templateProbe) {
+			return fmt.Errorf("prompt template does not include the query ({{.Query}})")
+		}
+	}
+	return nil
+}
+
+type templateKey struct{}
+
+// WithTemplate returns a copy of ctx carrying t, which GenerateRecipeContext renders instead of
+// PromptTemplate, e.g. for a request in a prompt experiment. t is executed with the same data.
+func WithTemplate(ctx context.Context, t *template.Template) context.Context {
+	return context.WithValue(ctx, templateKey{}, t)
+}
+
+// templateFrom returns the prompt template carried by ctx, or PromptTemplate.
+func templateFrom(ctx context.Context) *template.Template {
+	if t, ok := ctx.Value(templateKey{}).(*template.Template); ok && t != nil {
+		return t
+	}
+	return PromptTemplate
+}
+
+// TemplateVersion identifies the text of a prompt template, e.g. "3f9a1c0b7d2e": it changes
+// whenever the template does, so generations can be traced to the prompt that produced them.
+func TemplateVersion(t *template.Template) string {
+	if t == nil || t.Tree == nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(t.Tree.Root.String()))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Source identifies what produced a generation: the provider model, if known, the version of
+// the prompt template (see TemplateVersion), and the prompt sent and raw response received.
+type Source struct {
+	Model         string
+	PromptVersion string
+	Prompt        string
+	Response      []byte
+}
+
+// TraceID identifies a generation by what went into and came out of the model: the same
+// model, prompt and response always give the same ID, e.g. "5c0e7a9d21f4b386". It links a
+// generated recipe to the audit entry recording the exchange.
+func (s Source) TraceID() string {
+	h := sha256.New()
+	for _, part := range []string{s.Model, s.Prompt} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(s.Response)
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+type sourceKey struct{}
+
+// WithSource returns a copy of ctx carrying s, which GenerateRecipeContext fills in once the
+// provider has answered and, with the response, once it has been read.
+func WithSource(ctx context.Context, s *Source) context.Context {
+	return context.WithValue(ctx, sourceKey{}, s)
+}
+
+// sourceFrom returns the Source carried by ctx, or nil.
+func sourceFrom(ctx context.Context) *Source {
+	s, _ := ctx.Value(sourceKey{}).(*Source)
+	return s
+}
+
 // stripCodeFences removes markdown code fence markers from a string if present.
 func stripCodeFences(s string) string {
 	s = strings.TrimSpace(s)
@@ -82,54 +222,171 @@ func stripCodeFences(s string) string {
 	return strings.TrimSpace(s)
 }
 
+// extractJSON returns the first balanced JSON object found in s after removing code fences,
+// so that replies wrapping the object in prose ("Here is your recipe: {...} Enjoy!") still
+// parse. Braces inside JSON strings are ignored. If no complete object is found, the
+// fence-stripped input is returned unchanged and left for the JSON decoder to reject.
+func extractJSON(s string) string {
+	s = stripCodeFences(s)
+	start := strings.IndexByte(s, '{')
+	if start == -1 {
+		return s
+	}
+	depth := 0
+	inString, escaped := false, false
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return s[start : i+1]
+			}
+		}
+	}
+	return s
+}
+
 // GenerateRecipe calls the configured LLM provider endpoint with a structured prompt based
 // on the user's recipe query. If the DEEPEEK_API_KEY environment variable is set, it uses DeepSeek's
 // API format. Otherwise, it falls back to a default format. It logs the request headers for debugging.
 func GenerateRecipe(query string) (Recipe, []Recipe, error) {
-	// Retrieve the LLM endpoint URL from environment variables.
-	llmEndpoint := os.Getenv("LLM_ENDPOINT")
-	if llmEndpoint == "" {
-		return Recipe{}, nil, errors.New("LLM_ENDPOINT environment variable not set")
+	return GenerateRecipeContext(context.Background(), query)
+}
+
+// GenerateRecipeContext is like GenerateRecipe but aborts the provider call when ctx is done.
+// The model is chosen by Routing unless forced through WithRoute, and the prompt template may be
+// replaced through WithTemplate.
+func GenerateRecipeContext(ctx context.Context, query string) (primary Recipe, alternatives []Recipe, err error) {
+	// Construct the prompt, adjusted for the audience requested through WithOptions.
+	opts := OptionsFrom(ctx)
+	tmpl := templateFrom(ctx)
+	prompt, err := buildPrompt(tmpl, query, opts)
+	if err != nil {
+		return Recipe{}, nil, err
+	}
+	if guidance := opts.Guidance(); guidance != "" {
+		prompt += " " + guidance
 	}
 
-	// Construct the prompt.
-	prompt := "Generate a recipe based on the following query: \"" + query + "\". " +
-		"Return a JSON object with two keys: 'primary_recipe' and 'alternative_recipes'. " +
-		"The 'primary_recipe' should be a JSON object representing the main recipe with keys: " +
-		"id, title, ingredients, steps, nutritional_info, allergy_disclaimer, appliances, created_at, and updated_at. " +
-		"The 'alternative_recipes' should be an array of recipe objects following the same structure."
+	route := Routing.selectFor(ctx, TaskRecipe, query, opts)
+	// Grounding examples are dropped, least relevant first, until the prompt fits the model.
+	if prompt, _, err = fitPrompt(prompt, ExamplesFrom(ctx), promptBudget(route)); err != nil {
+		return Recipe{}, nil, err
+	}
+	defer func(start time.Time) { Routing.observe(route, time.Since(start), err) }(time.Now())
+	// The outcome is recorded by template version in Templates, whether or not it succeeds.
+	version := TemplateVersion(tmpl)
+	var reply *bytes.Buffer
+	defer func(start time.Time) {
+		var usage Usage
+		if reply != nil {
+			if s := sourceFrom(ctx); s != nil {
+				s.Response = reply.Bytes()
+			}
+			usage = usageOf(prompt, reply.Bytes())
+			if m := meterFrom(ctx); m != nil {
+				m.add(usage)
+			}
+		}
+		quality := 0.0
+		if err == nil {
+			quality = Quality(query, primary, alternatives)
+		}
+		Templates.observe(version, time.Since(start), usage, reply != nil, err == nil, quality)
+	}(time.Now())
+	resp, deepSeek, err := send(ctx, prompt, route)
+	if err != nil {
+		return Recipe{}, nil, err
+	}
+	defer resp.Body.Close()
+	if s := sourceFrom(ctx); s != nil {
+		s.Model, s.PromptVersion, s.Prompt = requestModel(route, deepSeek), version, prompt
+	}
+	reply = new(bytes.Buffer)
+	if primary, alternatives, err = ParseResponse(io.TeeReader(resp.Body, reply), deepSeek); err != nil {
+		return Recipe{}, nil, err
+	}
+	primary = Normalize(primary, opts)
+	primary.NutritionalInfo = NutritionFormat.Normalize(primary.NutritionalInfo)
+	for i := range alternatives {
+		alternatives[i] = Normalize(alternatives[i], opts)
+		alternatives[i].NutritionalInfo = NutritionFormat.Normalize(alternatives[i].NutritionalInfo)
+	}
+	return primary, alternatives, nil
+}
+
+// requestModel returns the model a request on route asks for: the route's, or for DeepSeek the
+// DEEPSEEK_MODEL environment variable or deepseek-chat. It is "" when the provider chooses.
+func requestModel(route Route, deepSeek bool) string {
+	model := route.Model
+	if model == "" && deepSeek {
+		model = os.Getenv("DEEPSEEK_MODEL")
+		if model == "" {
+			model = "deepseek-chat"
+		}
+	}
+	return model
+}
+
+// send posts prompt, with any images attached, to the LLM provider endpoint of route and returns
+// its successful response, whose body the caller must close. deepSeek reports whether the
+// DeepSeek chat format was used, i.e. whether the DEEPSEEK_API_KEY environment variable is set.
+func send(ctx context.Context, prompt string, route Route, images ...Image) (resp *http.Response, deepSeek bool, err error) {
+	// Retrieve the LLM endpoint URL from the route or the environment.
+	llmEndpoint := route.Endpoint
+	if llmEndpoint == "" {
+		llmEndpoint = os.Getenv("LLM_ENDPOINT")
+	}
+	if llmEndpoint == "" {
+		return nil, false, ErrNoEndpoint
+	}
 
 	var reqBody []byte
-	var err error
 	var req *http.Request
 
 	// Check if DEEPEEK_API_KEY is provided to use DeepSeek API.
 	deepseekKey := os.Getenv("DEEPSEEK_API_KEY")
 	if deepseekKey != "" {
 		// Use DeepSeek's expected payload format.
-		model := os.Getenv("DEEPSEEK_MODEL")
-		if model == "" {
-			model = "deepseek-chat"
+		model := requestModel(route, true)
+		// Images go along with the prompt as content parts of the user message.
+		var content interface{} = prompt
+		if len(images) > 0 {
+			parts := []map[string]interface{}{{"type": "text", "text": prompt}}
+			for _, img := range images {
+				parts = append(parts, map[string]interface{}{"type": "image_url", "image_url": map[string]string{"url": img.dataURL()}})
+			}
+			content = parts
 		}
 		payload := struct {
-			Model    string              `json:"model"`
-			Messages []map[string]string `json:"messages"`
-			Stream   bool                `json:"stream"`
+			Model    string                   `json:"model"`
+			Messages []map[string]interface{} `json:"messages"`
+			Stream   bool                     `json:"stream"`
 		}{
 			Model: model,
-			Messages: []map[string]string{
+			Messages: []map[string]interface{}{
 				{"role": "system", "content": "You are a helpful assistant."},
-				{"role": "user", "content": prompt},
+				{"role": "user", "content": content},
 			},
 			Stream: false,
 		}
 		reqBody, err = json.Marshal(payload)
 		if err != nil {
-			return Recipe{}, nil, err
+			return nil, false, err
 		}
-		req, err = http.NewRequest(http.MethodPost, llmEndpoint, bytes.NewReader(reqBody))
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, llmEndpoint, bytes.NewReader(reqBody))
 		if err != nil {
-			return Recipe{}, nil, err
+			return nil, false, err
 		}
 		req.Header.Set("Content-Type", "application/json")
 		req.Header.Set("Authorization", "Bearer "+deepseekKey)
@@ -139,57 +396,121 @@ func GenerateRecipe(query string) (Recipe, []Recipe, error) {
 		// Default API call structure.
 		reqPayload := llmRequest{
 			Prompt: prompt,
+			Model:  route.Model,
+		}
+		for _, img := range images {
+			reqPayload.Images = append(reqPayload.Images, base64.StdEncoding.EncodeToString(img.Data))
 		}
 		reqBody, err = json.Marshal(reqPayload)
 		if err != nil {
-			return Recipe{}, nil, err
+			return nil, false, err
 		}
-		req, err = http.NewRequest(http.MethodPost, llmEndpoint, bytes.NewReader(reqBody))
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, llmEndpoint, bytes.NewReader(reqBody))
 		if err != nil {
-			return Recipe{}, nil, err
+			return nil, false, err
 		}
 		req.Header.Set("Content-Type", "application/json")
 	}
 
 	start := time.Now()
-	resp, err := HTTPClient.Do(req)
+	resp, err = HTTPClient.Do(req)
 	elapsed := time.Since(start)
 	log.Printf("DeepSeek API call took %v", elapsed)
 
 	if err != nil {
-		return Recipe{}, nil, err
+		return nil, false, err
 	}
-	defer resp.Body.Close()
 
 	// Check if response status is 200 OK.
 	if resp.StatusCode != http.StatusOK {
-		return Recipe{}, nil, errors.New("LLM endpoint returned non-200 status: " + resp.Status)
+		resp.Body.Close()
+		return nil, false, &ErrProviderStatus{Code: resp.StatusCode}
 	}
+	return resp, deepseekKey != "", nil
+}
 
-	// If using DeepSeek, its response is nested inside a "choices" array.
-	if deepseekKey != "" {
-		var dsResp DeepSeekResponse
-		if err := json.NewDecoder(resp.Body).Decode(&dsResp); err != nil {
-			return Recipe{}, nil, err
+// Ping checks that the LLM provider endpoints, LLM_ENDPOINT and those of the Routing routes,
+// are reachable, leaving connections open in HTTPClient for the first generations to reuse.
+// Any HTTP response counts as reachable: the request carries no prompt, so nothing is billed.
+func Ping(ctx context.Context) error {
+	endpoints := []string{os.Getenv("LLM_ENDPOINT")}
+	if Routing != nil {
+		for _, r := range Routing.Routes {
+			endpoints = append(endpoints, r.Endpoint)
 		}
-		if len(dsResp.Choices) == 0 {
-			return Recipe{}, nil, errors.New("no choices in DeepSeek response")
+	}
+	seen := make(map[string]bool)
+	for _, endpoint := range endpoints {
+		if endpoint == "" || seen[endpoint] {
+			continue
 		}
-		content := dsResp.Choices[0].Message.Content
-		cleanContent := stripCodeFences(content)
-		log.Printf("Extracted content: %s", cleanContent)
+		seen[endpoint] = true
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := HTTPClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("pinging %s: %w", endpoint, err)
+		}
+		// Drain the body so that the connection can be reused.
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	if len(seen) == 0 {
+		return ErrNoEndpoint
+	}
+	return nil
+}
+
+// deepSeekContent decodes a DeepSeek chat completion and returns the content of its first
+// choice. A choice that stopped at the output token limit fails with ErrTruncated.
+func deepSeekContent(body io.Reader) (string, error) {
+	var dsResp DeepSeekResponse
+	if err := json.NewDecoder(body).Decode(&dsResp); err != nil {
+		return "", decodeError(err, nil)
+	}
+	if len(dsResp.Choices) == 0 {
+		return "", fmt.Errorf("%w: no choices in DeepSeek response", ErrUnparseableResponse)
+	}
+	if dsResp.Choices[0].FinishReason == "length" {
+		return "", fmt.Errorf("%w: the reply reached the output token limit", ErrTruncated)
+	}
+	return dsResp.Choices[0].Message.Content, nil
+}
 
-		var llmResp LLMResponse
-		if err := json.Unmarshal([]byte(cleanContent), &llmResp); err != nil {
+// ParseResponse decodes a provider response body into the primary and alternative recipes.
+// When deepSeek is true the body is a DeepSeek chat completion whose first choice carries the
+// recipe JSON, possibly wrapped in code fences or prose; otherwise the body is the recipe JSON.
+// Common deviations from LLMResponse, such as a recipes array or steps in one string, are
+// adapted (see decodeLLMResponse).
+// A reply that was cut off fails with ErrTruncated, any other malformed one with
+// ErrUnparseableResponse.
+func ParseResponse(body io.Reader, deepSeek bool) (Recipe, []Recipe, error) {
+	// If using DeepSeek, its response is nested inside a "choices" array.
+	if deepSeek {
+		content, err := deepSeekContent(body)
+		if err != nil {
 			return Recipe{}, nil, err
 		}
-		return llmResp.PrimaryRecipe, llmResp.AlternativeRecipes, nil
-	} else {
-		// Decode the response.
-		var llmResp LLMResponse
-		if err := json.NewDecoder(resp.Body).Decode(&llmResp); err != nil {
+		cleanContent := extractJSON(content)
+		log.Printf("Extracted content: %s", cleanContent)
+
+		llmResp, err := decodeLLMResponse([]byte(cleanContent))
+		if err != nil {
 			return Recipe{}, nil, err
 		}
 		return llmResp.PrimaryRecipe, llmResp.AlternativeRecipes, nil
 	}
+
+	// Decode the response.
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return Recipe{}, nil, err
+	}
+	llmResp, err := decodeLLMResponse(data)
+	if err != nil {
+		return Recipe{}, nil, err
+	}
+	return llmResp.PrimaryRecipe, llmResp.AlternativeRecipes, nil
 }
@@ -3,11 +3,16 @@
 package generation
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"text/template"
 	"time"
 )
 
@@ -86,3 +91,365 @@ func TestGenerateRecipe(t *testing.T) {
 		t.Errorf("Expected %d alternative recipes, got %d", len(mockResponse.AlternativeRecipes), len(alternatives))
 	}
 }
+
+// TestGenerateRecipeOptions verifies that options carried by the context adjust the prompt.
+func TestGenerateRecipeOptions(t *testing.T) {
+	var prompt string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqPayload map[string]string
+		json.NewDecoder(r.Body).Decode(&reqPayload)
+		prompt = reqPayload["prompt"]
+		json.NewEncoder(w).Encode(mockLLMResponse())
+	}))
+	defer mockServer.Close()
+	os.Setenv("LLM_ENDPOINT", mockServer.URL)
+
+	ctx := WithOptions(context.Background(), Options{SkillLevel: SkillBeginner, KidFriendly: true})
+	if _, _, err := GenerateRecipeContext(ctx, "pancakes"); err != nil {
+		t.Fatalf("GenerateRecipeContext returned error: %v", err)
+	}
+	if !strings.Contains(prompt, "beginner cook") || !strings.Contains(prompt, "kid-friendly") {
+		t.Errorf("Expected beginner and kid-friendly guidance in the prompt, got %q", prompt)
+	}
+}
+
+// TestGenerateRecipeTemplate verifies that a template carried by the context replaces the prompt.
+func TestGenerateRecipeTemplate(t *testing.T) {
+	var prompt string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqPayload map[string]string
+		json.NewDecoder(r.Body).Decode(&reqPayload)
+		prompt = reqPayload["prompt"]
+		json.NewEncoder(w).Encode(mockLLMResponse())
+	}))
+	defer mockServer.Close()
+	t.Setenv("LLM_ENDPOINT", mockServer.URL)
+
+	ctx := WithTemplate(context.Background(), template.Must(template.New("terse").Parse("Recipe for {{.Query}} as JSON.")))
+	if _, _, err := GenerateRecipeContext(ctx, "pancakes"); err != nil {
+		t.Fatalf("GenerateRecipeContext returned error: %v", err)
+	}
+	if prompt != "Recipe for pancakes as JSON." {
+		t.Errorf("Expected the variant prompt, got %q", prompt)
+	}
+}
+
+func TestGenerateRecipeSource(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(mockLLMResponse())
+	}))
+	defer mockServer.Close()
+	t.Setenv("LLM_ENDPOINT", mockServer.URL)
+	Routing = NewRouter(Route{Name: "default", Model: "chef-large"})
+	defer func() { Routing = nil }()
+
+	var source Source
+	if _, _, err := GenerateRecipeContext(WithSource(context.Background(), &source), "pancakes"); err != nil {
+		t.Fatal(err)
+	}
+	if source.Model != "chef-large" || source.PromptVersion != TemplateVersion(PromptTemplate) || len(source.PromptVersion) != 12 {
+		t.Errorf("Expected the route's model and the default template's version, got %+v", source)
+	}
+	terse := template.Must(template.New("terse").Parse("Recipe for {{.Query}} as JSON."))
+	if TemplateVersion(terse) == source.PromptVersion {
+		t.Error("Expected different templates to have different versions")
+	}
+	if !strings.Contains(source.Prompt, "pancakes") || !strings.Contains(string(source.Response), "primary_recipe") {
+		t.Errorf("Expected the prompt and raw response, got %q and %q", source.Prompt, source.Response)
+	}
+
+	var again Source
+	GenerateRecipeContext(WithSource(context.Background(), &again), "pancakes")
+	if id := source.TraceID(); len(id) != 16 || again.TraceID() != id {
+		t.Errorf("Expected the same exchange to have the same trace ID, got %q and %q", id, again.TraceID())
+	}
+	again.Response = append(again.Response, ' ')
+	if again.TraceID() == source.TraceID() {
+		t.Error("Expected a different response to have a different trace ID")
+	}
+}
+
+// TestGenerateRecipeErrors verifies that failures are reported with the package's typed errors.
+func TestGenerateRecipeErrors(t *testing.T) {
+	var reply func(w http.ResponseWriter)
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { reply(w) }))
+	defer mockServer.Close()
+	t.Setenv("DEEPSEEK_API_KEY", "")
+
+	t.Setenv("LLM_ENDPOINT", "")
+	if _, _, err := GenerateRecipe("pancakes"); !errors.Is(err, ErrNoEndpoint) {
+		t.Errorf("Expected ErrNoEndpoint, got %v", err)
+	}
+	t.Setenv("LLM_ENDPOINT", mockServer.URL)
+
+	reply = func(w http.ResponseWriter) { w.WriteHeader(http.StatusTooManyRequests) }
+	var status *ErrProviderStatus
+	if _, _, err := GenerateRecipe("pancakes"); !errors.As(err, &status) || status.Code != http.StatusTooManyRequests || !status.Temporary() {
+		t.Errorf("Expected a temporary ErrProviderStatus with code 429, got %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		deepSeek bool
+		body     string
+		want     error
+	}{
+		{"truncated", false, `{"primary_recipe": {"title": "Panc`, ErrTruncated},
+		{"garbage", false, `Sorry, I cannot help with that.`, ErrUnparseableResponse},
+		{"deepseek length", true, `{"choices": [{"message": {"content": "{\"primary_recipe\": {"}, "finish_reason": "length"}]}`, ErrTruncated},
+		{"deepseek truncated content", true, `{"choices": [{"message": {"content": "{\"primary_recipe\": {"}, "finish_reason": "stop"}]}`, ErrTruncated},
+		{"deepseek no choices", true, `{"choices": []}`, ErrUnparseableResponse},
+	}
+	for _, tt := range tests {
+		if tt.deepSeek {
+			t.Setenv("DEEPSEEK_API_KEY", "test-key")
+		} else {
+			t.Setenv("DEEPSEEK_API_KEY", "")
+		}
+		reply = func(w http.ResponseWriter) { w.Write([]byte(tt.body)) }
+		if _, _, err := GenerateRecipe("pancakes"); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+// TestPing verifies that every distinct provider endpoint is pinged and that any HTTP response
+// counts as reachable.
+func TestPing(t *testing.T) {
+	pings := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pings++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer mockServer.Close()
+	t.Setenv("LLM_ENDPOINT", mockServer.URL)
+	Routing = NewRouter(Route{Name: "same", Model: "m", Endpoint: mockServer.URL}, Route{Name: "default", Model: "m"})
+	defer func() { Routing = nil }()
+
+	if err := Ping(context.Background()); err != nil || pings != 1 {
+		t.Errorf("Expected one successful ping, got %d and %v", pings, err)
+	}
+	mockServer.Close()
+	if err := Ping(context.Background()); err == nil {
+		t.Error("Expected an error for an unreachable provider")
+	}
+}
+
+// TestEstimateNutrition verifies that nutrition estimates are parsed from a DeepSeek reply,
+// including values given as strings with units, and rounded by NutritionFormat.
+func TestEstimateNutrition(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(DeepSeekResponse{Choices: []DeepSeekChoice{{Message: DeepSeekMessage{
+			Content: "```json\n{\"calories\": \"420.4 kcal\", \"protein\": 18.4999, \"carbohydrates\": \"51g\", \"fat\": 14}\n```",
+		}}}})
+	}))
+	defer mockServer.Close()
+	t.Setenv("LLM_ENDPOINT", mockServer.URL)
+	t.Setenv("DEEPSEEK_API_KEY", "test-key")
+
+	got, err := EstimateNutrition(context.Background(), "Pancakes", []string{"flour", "milk", "eggs"})
+	if err != nil {
+		t.Fatalf("EstimateNutrition returned error: %v", err)
+	}
+	if got["calories"] != 420 || got["protein"] != 18.5 || got["carbohydrates"] != 51 {
+		t.Errorf("Unexpected estimates %v", got)
+	}
+}
+
+// TestDescribeImage verifies that the photo is attached in both request formats and the dish
+// description read back.
+func TestDescribeImage(t *testing.T) {
+	jpeg := []byte("\xff\xd8\xff\xe0 not really a jpeg")
+	var got map[string]interface{}
+	reply := "The dish is {\"description\": \"  mushroom   risotto with parmesan \"}"
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		if os.Getenv("DEEPSEEK_API_KEY") == "" {
+			w.Write([]byte(reply))
+			return
+		}
+		json.NewEncoder(w).Encode(DeepSeekResponse{Choices: []DeepSeekChoice{{Message: DeepSeekMessage{Content: reply}}}})
+	}))
+	defer mockServer.Close()
+	t.Setenv("LLM_ENDPOINT", mockServer.URL)
+
+	t.Setenv("DEEPSEEK_API_KEY", "")
+	description, err := DescribeImage(context.Background(), Image{Data: jpeg})
+	if err != nil || description != "mushroom risotto with parmesan" {
+		t.Errorf("DescribeImage() = %q, %v", description, err)
+	}
+	if images, _ := got["images"].([]interface{}); len(images) != 1 || images[0] != base64.StdEncoding.EncodeToString(jpeg) {
+		t.Errorf("Expected the image in the request, got %v", got["images"])
+	}
+
+	t.Setenv("DEEPSEEK_API_KEY", "test-key")
+	if _, err := DescribeImage(context.Background(), Image{Data: jpeg}); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(got["messages"])
+	if !strings.Contains(string(data), `"url":"data:image/jpeg;base64,`) {
+		t.Errorf("Expected the image as a data URL content part, got %s", data)
+	}
+
+	reply = `{"description": ""}`
+	if _, err := DescribeImage(context.Background(), Image{Data: jpeg}); !errors.Is(err, ErrNoDish) {
+		t.Errorf("Expected ErrNoDish, got %v", err)
+	}
+}
+
+// TestExtractRecipe verifies that a transcribed recipe is cleaned up and that a photo without a
+// complete recipe is refused.
+func TestExtractRecipe(t *testing.T) {
+	reply := `{"id": "x", "title": " Grandma's Scones ", "ingredients": ["2 cups flour", " ", "1 egg"], ` +
+		`"steps": ["Mix.", "Bake 15 minutes."], "nutritional_info": {"Calories": "310 kcal"}, "total_time": 25}`
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(DeepSeekResponse{Choices: []DeepSeekChoice{{Message: DeepSeekMessage{Content: "```json\n" + reply + "\n```"}}}})
+	}))
+	defer mockServer.Close()
+	t.Setenv("LLM_ENDPOINT", mockServer.URL)
+	t.Setenv("DEEPSEEK_API_KEY", "test-key")
+
+	var source Source
+	r, err := ExtractRecipe(WithSource(context.Background(), &source), Image{Data: []byte("\xff\xd8\xff")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ID != "" || r.Title != "Grandma's Scones" || len(r.Ingredients) != 2 || r.TotalTime != 25 {
+		t.Errorf("Unexpected recipe %+v", r)
+	}
+	if info, _ := r.NutritionalInfo.(map[string]interface{}); info["calories"] != 310 {
+		t.Errorf("Expected normalized nutrition, got %v", r.NutritionalInfo)
+	}
+	if source.Model != "deepseek-chat" {
+		t.Errorf("Expected the model to be reported, got %q", source.Model)
+	}
+
+	reply = `{"title": "Scones", "ingredients": ["flour"], "steps": []}`
+	if _, err := ExtractRecipe(context.Background(), Image{Data: []byte("\xff\xd8\xff")}); !errors.Is(err, ErrNoRecipe) || !strings.Contains(err.Error(), "no steps") {
+		t.Errorf("Expected ErrNoRecipe for missing steps, got %v", err)
+	}
+}
+
+// TestMeter verifies that provider-reported usage is recorded, and estimated when missing.
+func TestMeter(t *testing.T) {
+	reported := true
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reply := map[string]interface{}{"primary_recipe": mockLLMResponse().PrimaryRecipe}
+		if reported {
+			reply["usage"] = map[string]int{"prompt_tokens": 120, "completion_tokens": 480}
+		}
+		json.NewEncoder(w).Encode(reply)
+	}))
+	defer mockServer.Close()
+	t.Setenv("LLM_ENDPOINT", mockServer.URL)
+
+	var m Meter
+	ctx := WithMeter(context.Background(), &m)
+	if _, _, err := GenerateRecipeContext(ctx, "pancakes"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Usage(), (Usage{Calls: 1, PromptTokens: 120, CompletionTokens: 480}); got != want {
+		t.Errorf("Expected the reported usage %+v, got %+v", want, got)
+	}
+	reported = false
+	if _, _, err := GenerateRecipeContext(ctx, "pancakes"); err != nil {
+		t.Fatal(err)
+	}
+	if u := m.Usage(); u.Calls != 2 || u.PromptTokens <= 120 || u.CompletionTokens <= 480 {
+		t.Errorf("Expected an estimate to be added for the second call, got %+v", u)
+	}
+}
+
+// TestTemplateMetrics verifies that generation outcomes are recorded by template version.
+func TestTemplateMetrics(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]string
+		json.NewDecoder(r.Body).Decode(&payload)
+		if strings.Contains(payload["prompt"], "broken") {
+			w.Write([]byte("not json"))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"primary_recipe": mockLLMResponse().PrimaryRecipe,
+			"usage":          map[string]int{"prompt_tokens": 100, "completion_tokens": 400},
+		})
+	}))
+	defer mockServer.Close()
+	t.Setenv("LLM_ENDPOINT", mockServer.URL)
+	defer func(m *TemplateMetrics) { Templates = m }(Templates)
+	Templates = new(TemplateMetrics)
+
+	canary := template.Must(template.New("canary").Parse("Recipe for {{.Query}} as JSON."))
+	GenerateRecipeContext(context.Background(), "pancakes")
+	GenerateRecipeContext(WithTemplate(context.Background(), canary), "pancakes")
+	GenerateRecipeContext(WithTemplate(context.Background(), canary), "broken pancakes")
+
+	stats := map[string]TemplateStats{}
+	for _, s := range Templates.Stats() {
+		stats[s.Version] = s
+	}
+	current, next := stats[TemplateVersion(PromptTemplate)], stats[TemplateVersion(canary)]
+	if current.Requests != 1 || current.ParseFailures != 0 || current.PromptTokens != 100 || current.MeanQuality <= 0 {
+		t.Errorf("Unexpected stats for the current template: %+v", current)
+	}
+	if next.Requests != 2 || next.ParseFailures != 1 || next.ParseFailureRate != 0.5 || next.CompletionTokens <= 400 || next.MeanQuality != current.MeanQuality {
+		t.Errorf("Unexpected stats for the canary template: %+v", next)
+	}
+}
+
+// TestEstimateTokens verifies the estimate against cl100k_base counts.
+func TestEstimateTokens(t *testing.T) {
+	for text, want := range map[string]int{
+		"":            0,
+		"hello world": 2,
+		"12345":       2,
+		"The quick brown fox jumps over the lazy dog.": 10,
+		"Simmer, then serve.\n\nEnjoy!":                8,
+	} {
+		if got := EstimateTokens(text); got != want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+// TestGenerateRecipeBudget verifies that grounding examples are dropped, least relevant first,
+// to fit the context window, and that a prompt too long on its own is not sent.
+func TestGenerateRecipeBudget(t *testing.T) {
+	var prompt string
+	calls := 0
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqPayload map[string]string
+		json.NewDecoder(r.Body).Decode(&reqPayload)
+		prompt = reqPayload["prompt"]
+		calls++
+		json.NewEncoder(w).Encode(mockLLMResponse())
+	}))
+	defer mockServer.Close()
+	t.Setenv("LLM_ENDPOINT", mockServer.URL)
+
+	base, err := BuildPrompt("pancakes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	example := Recipe{Title: "Buttermilk Pancakes", Ingredients: []string{"flour", "buttermilk", "eggs"}, Steps: []string{"Whisk.", "Fry."}}
+	examples := []Recipe{example, example, example, example}
+	examples[0].Title, examples[3].Title = "Closest Pancakes", "Furthest Pancakes"
+	defer func(window, reply int) { ContextWindow, ReplyTokens = window, reply }(ContextWindow, ReplyTokens)
+	ContextWindow, ReplyTokens = EstimateTokens(base)+60, 0
+
+	ctx := WithExamples(context.Background(), examples)
+	if _, _, err := GenerateRecipeContext(ctx, "pancakes"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(prompt, "Closest Pancakes") || strings.Contains(prompt, "Furthest Pancakes") {
+		t.Errorf("Expected only the most relevant examples in the prompt, got %q", prompt)
+	}
+	if n := EstimateTokens(prompt); n > ContextWindow {
+		t.Errorf("Expected the prompt to fit %d tokens, got %d", ContextWindow, n)
+	}
+
+	ContextWindow = 10
+	if _, _, err := GenerateRecipeContext(ctx, "pancakes"); !errors.Is(err, ErrPromptTooLong) || calls != 1 {
+		t.Errorf("Expected ErrPromptTooLong without a provider call, got %v after %d calls", err, calls)
+	}
+}
@@ -0,0 +1,139 @@
+package generation
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// update rewrites the golden request payloads instead of comparing against them:
+//
+//	go test ./generation -run TestProviderRequestGolden -update
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// goldenQuery is the query used for every golden request payload.
+const goldenQuery = "lemon herb chicken"
+
+// providerEnv configures the environment for a provider under test.
+type providerEnv struct {
+	name string
+	env  map[string]string
+}
+
+var providers = []providerEnv{
+	{name: "deepseek", env: map[string]string{"DEEPSEEK_API_KEY": "test-key", "DEEPSEEK_MODEL": ""}},
+	{name: "default", env: map[string]string{"DEEPSEEK_API_KEY": "", "DEEPSEEK_MODEL": ""}},
+}
+
+// setProvider points the generation package at url using the given provider configuration.
+func setProvider(t *testing.T, p providerEnv, url string) {
+	t.Helper()
+	t.Setenv("LLM_ENDPOINT", url)
+	for k, v := range p.env {
+		t.Setenv(k, v)
+	}
+}
+
+// TestProviderRequestGolden verifies the request payload sent to each provider against
+// testdata/requests/<provider>.golden.json.
+func TestProviderRequestGolden(t *testing.T) {
+	for _, p := range providers {
+		t.Run(p.name, func(t *testing.T) {
+			var captured []byte
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				captured, _ = io.ReadAll(r.Body)
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer srv.Close()
+			setProvider(t, p, srv.URL)
+
+			GenerateRecipe(goldenQuery)
+
+			var got bytes.Buffer
+			if err := json.Indent(&got, captured, "", "  "); err != nil {
+				t.Fatalf("Request payload is not valid JSON: %v\n%s", err, captured)
+			}
+			got.WriteByte('\n')
+
+			golden := filepath.Join("testdata", "requests", p.name+".golden.json")
+			if *update {
+				if err := os.WriteFile(golden, got.Bytes(), 0644); err != nil {
+					t.Fatalf("Failed to update golden file: %v", err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("Failed to read golden file (run with -update to create it): %v", err)
+			}
+			if !bytes.Equal(got.Bytes(), want) {
+				t.Errorf("Request payload for %s differs from %s:\ngot:\n%s\nwant:\n%s", p.name, golden, got.Bytes(), want)
+			}
+		})
+	}
+}
+
+// TestProviderResponseParsing replays captured provider responses from testdata/responses
+// and verifies which of them parse and which are rejected.
+func TestProviderResponseParsing(t *testing.T) {
+	cases := []struct {
+		provider     string
+		fixture      string
+		wantErr      bool
+		wantID       string
+		alternatives int
+	}{
+		{provider: "deepseek", fixture: "success.json", wantID: "lemon-herb-chicken", alternatives: 1},
+		{provider: "deepseek", fixture: "fenced.json", wantID: "miso-soup", alternatives: 0},
+		{provider: "deepseek", fixture: "variant.json", wantID: "dal", alternatives: 1},
+		{provider: "deepseek", fixture: "truncated.json", wantErr: true},
+		{provider: "deepseek", fixture: "prose.json", wantErr: true},
+		{provider: "deepseek", fixture: "empty_choices.json", wantErr: true},
+		{provider: "default", fixture: "success.json", wantID: "pancakes", alternatives: 1},
+		{provider: "default", fixture: "recipes_array.json", wantID: "shakshuka", alternatives: 1},
+		{provider: "default", fixture: "truncated.json", wantErr: true},
+		{provider: "default", fixture: "prose.json", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.provider+"/"+tc.fixture, func(t *testing.T) {
+			body, err := os.ReadFile(filepath.Join("testdata", "responses", tc.provider, tc.fixture))
+			if err != nil {
+				t.Fatalf("Failed to read fixture: %v", err)
+			}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write(body)
+			}))
+			defer srv.Close()
+			for _, p := range providers {
+				if p.name == tc.provider {
+					setProvider(t, p, srv.URL)
+				}
+			}
+
+			primary, alternatives, err := GenerateRecipe(goldenQuery)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got primary recipe %+v", primary)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateRecipe returned error: %v", err)
+			}
+			if primary.ID != tc.wantID {
+				t.Errorf("Expected primary recipe ID %s, got %s", tc.wantID, primary.ID)
+			}
+			if len(alternatives) != tc.alternatives {
+				t.Errorf("Expected %d alternative recipes, got %d", tc.alternatives, len(alternatives))
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package generation
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pageza/recipe-resolver-ms/units"
+)
+
+var (
+	// sentence matches one sentence with its terminating punctuation and trailing space.
+	sentence = regexp.MustCompile(`[^.!?]+[.!?]*\s*`)
+	// filler matches sentences that carry no instruction.
+	filler = regexp.MustCompile(`(?i)^\s*(enjoy|bon app[eé]tit|happy (cooking|baking)|have fun|yum|delicious|voil[aà])\b`)
+	// aside matches parenthetical remarks.
+	aside = regexp.MustCompile(`\s*\([^()]*\)`)
+	// exclamations matches runs of exclamation marks.
+	exclamations = regexp.MustCompile(`!+`)
+)
+
+// Normalize enforces the style requested by opts on a generated recipe, since providers do not
+// always follow the prompt. Concise tone and brief verbosity drop filler sentences ("Enjoy!")
+// and steps left empty, concise tone also tones down exclamation marks, and brief verbosity
+// drops parenthetical asides. A measurement system has temperatures in steps annotated in that
+// system (see units.AnnotateTemperatures).
+func Normalize(r Recipe, opts Options) Recipe {
+	if opts.Tone == "" && opts.Measurement == "" && opts.Verbosity == "" {
+		return r
+	}
+	steps := make([]string, 0, len(r.Steps))
+	for _, step := range r.Steps {
+		if opts.Tone == ToneConcise || opts.Verbosity == VerbosityBrief {
+			var kept strings.Builder
+			for _, s := range sentence.FindAllString(step, -1) {
+				if !filler.MatchString(s) {
+					kept.WriteString(s)
+				}
+			}
+			step = kept.String()
+		}
+		if opts.Tone == ToneConcise {
+			step = exclamations.ReplaceAllString(step, ".")
+		}
+		if opts.Verbosity == VerbosityBrief {
+			step = aside.ReplaceAllString(step, "")
+		}
+		switch opts.Measurement {
+		case MeasurementMetric:
+			step = units.AnnotateTemperatures(step, units.Metric)
+		case MeasurementUS:
+			step = units.AnnotateTemperatures(step, units.USCustomary)
+		}
+		if step = strings.TrimSpace(step); step != "" {
+			steps = append(steps, step)
+		}
+	}
+	r.Steps = steps
+	return r
+}
@@ -0,0 +1,45 @@
+package generation
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	r := Recipe{Steps: []string{
+		"Preheat the oven to 400°F (a hot oven works best)!",
+		"Bake for 20 minutes. Enjoy!",
+		"Bon appétit!",
+	}}
+	tests := []struct {
+		opts Options
+		want []string
+	}{
+		{Options{}, r.Steps},
+		{Options{Tone: ToneConcise}, []string{"Preheat the oven to 400°F (a hot oven works best).", "Bake for 20 minutes."}},
+		{Options{Verbosity: VerbosityBrief}, []string{"Preheat the oven to 400°F!", "Bake for 20 minutes."}},
+		{Options{Measurement: MeasurementMetric}, []string{"Preheat the oven to 200°C/400°F (a hot oven works best)!", "Bake for 20 minutes. Enjoy!", "Bon appétit!"}},
+	}
+	for _, tt := range tests {
+		if got := Normalize(r, tt.opts).Steps; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Normalize(%v) = %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestBuildPromptOptions(t *testing.T) {
+	plain, err := BuildPrompt("pancakes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	styled, err := BuildPromptOptions("pancakes", Options{Tone: ToneChatty, Measurement: MeasurementMetric, Verbosity: VerbosityDetailed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"conversational", "grams, millilitres", "visual cues"} {
+		if strings.Contains(plain, want) || !strings.Contains(styled, want) {
+			t.Errorf("Expected %q only in the styled prompt:\nplain: %s\nstyled: %s", want, plain, styled)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package generation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// NutritionFormat rounds the nutritional info of generated recipes and nutrition estimates, whose
+// values providers return as strings ("450 kcal") or with spurious precision.
+var NutritionFormat = model.DefaultNutritionFormat
+
+// NutritionPrompt asks the provider for per-serving nutrition estimates. The %q verbs receive
+// the recipe title and its comma-separated ingredients.
+const NutritionPrompt = "Estimate the nutritional information per serving of the recipe %q made with: %q. " +
+	"Return only a JSON object with numeric values for the keys calories (kcal), protein, " +
+	"carbohydrates and fat (grams)."
+
+// EstimateNutrition asks the configured LLM provider to estimate the nutritional information of
+// a recipe from its title and ingredients. It returns the estimates keyed by nutrient. The model
+// is chosen by Routing, with the title as the query.
+func EstimateNutrition(ctx context.Context, title string, ingredients []string) (estimates map[string]float64, err error) {
+	route := Routing.selectFor(ctx, TaskNutrition, title, Options{})
+	defer func(start time.Time) { Routing.observe(route, time.Since(start), err) }(time.Now())
+	resp, deepSeek, err := send(ctx, fmt.Sprintf(NutritionPrompt, title, strings.Join(ingredients, ", ")), route)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return parseNutrition(resp.Body, deepSeek)
+}
+
+// parseNutrition decodes a provider response to NutritionPrompt, in the same envelope formats as
+// ParseResponse.
+func parseNutrition(body io.Reader, deepSeek bool) (map[string]float64, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	content := string(data)
+	if deepSeek {
+		if content, err = deepSeekContent(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+	}
+
+	var raw map[string]interface{}
+	estimate := extractJSON(content)
+	if err := json.Unmarshal([]byte(estimate), &raw); err != nil {
+		return nil, decodeError(err, []byte(estimate))
+	}
+	estimates := make(map[string]float64, len(raw))
+	for k, v := range NutritionFormat.Normalize(raw).(map[string]interface{}) {
+		if n, ok := model.ParseNutrient(v); ok {
+			estimates[k] = n
+		}
+	}
+	if _, ok := estimates["calories"]; !ok {
+		return nil, fmt.Errorf("%w: nutrition estimate has no calories", ErrUnparseableResponse)
+	}
+	return estimates, nil
+}
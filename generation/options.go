@@ -0,0 +1,266 @@
+package generation
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/occasion"
+)
+
+// SkillLevel is the cooking experience a generated recipe is written for.
+type SkillLevel string
+
+// Skill levels. The zero value leaves the prompt unchanged.
+const (
+	SkillBeginner SkillLevel = "beginner"
+	SkillAdvanced SkillLevel = "advanced"
+)
+
+// ParseSkillLevel parses "beginner" or "advanced".
+func ParseSkillLevel(s string) (SkillLevel, error) {
+	switch l := SkillLevel(strings.ToLower(strings.TrimSpace(s))); l {
+	case SkillBeginner, SkillAdvanced:
+		return l, nil
+	}
+	return "", fmt.Errorf("unknown skill level %q (want beginner or advanced)", s)
+}
+
+// Tone is the register of a generated recipe's wording.
+type Tone string
+
+// Tones. The zero value leaves the tone to the provider.
+const (
+	ToneConcise Tone = "concise"
+	ToneChatty  Tone = "chatty"
+)
+
+// Measurement is the system of measurement a generated recipe uses.
+type Measurement string
+
+// Measurement systems. The zero value leaves the choice to the provider.
+const (
+	MeasurementMetric Measurement = "metric"
+	MeasurementUS     Measurement = "us"
+)
+
+// Verbosity is how much detail a generated recipe's steps carry.
+type Verbosity string
+
+// Verbosities. The zero value leaves the level of detail to the provider.
+const (
+	VerbosityBrief    Verbosity = "brief"
+	VerbosityDetailed Verbosity = "detailed"
+)
+
+// ParseTone parses "concise" or "chatty".
+func ParseTone(s string) (Tone, error) {
+	switch t := Tone(strings.ToLower(strings.TrimSpace(s))); t {
+	case ToneConcise, ToneChatty:
+		return t, nil
+	}
+	return "", fmt.Errorf("unknown tone %q (want concise or chatty)", s)
+}
+
+// ParseMeasurement parses "metric" or "us".
+func ParseMeasurement(s string) (Measurement, error) {
+	switch m := Measurement(strings.ToLower(strings.TrimSpace(s))); m {
+	case MeasurementMetric, MeasurementUS:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown measurement system %q (want metric or us)", s)
+}
+
+// ParseVerbosity parses "brief" or "detailed".
+func ParseVerbosity(s string) (Verbosity, error) {
+	switch v := Verbosity(strings.ToLower(strings.TrimSpace(s))); v {
+	case VerbosityBrief, VerbosityDetailed:
+		return v, nil
+	}
+	return "", fmt.Errorf("unknown verbosity %q (want brief or detailed)", s)
+}
+
+// Options adjusts the generation prompt for a particular audience and style. The zero value
+// requests a general-purpose recipe.
+type Options struct {
+	SkillLevel  SkillLevel
+	KidFriendly bool
+	// Tone, Measurement and Verbosity are passed to PromptTemplate and enforced by Normalize.
+	Tone        Tone
+	Measurement Measurement
+	Verbosity   Verbosity
+	// Exclude lists ingredients the recipe must not contain, including every member of an
+	// excluded group (see taxonomy.Taxonomy.Descendants).
+	Exclude []string
+	// Nutrition bounds the recipe's calories and macronutrients per serving.
+	Nutrition []model.NutritionConstraint
+	// Fusion lists cuisines to blend in one dish, e.g. ["korean", "mexican"].
+	Fusion []string
+	// MaxTotalTime limits the recipe's preparation and cooking time, in minutes.
+	MaxTotalTime int
+	// Count, if above one, asks for that many distinct recipes: the best as the primary recipe
+	// and the others as alternatives.
+	Count int
+	// Course, if set, is the course of a menu the recipe is for, e.g. "starter" (see
+	// model.Courses), and Companions are the titles of the menu's other dishes.
+	Course     string
+	Companions []string
+	// Occasions are the holidays or festivals the recipe is for; their guidance is added to
+	// the prompt.
+	Occasions []occasion.Occasion
+}
+
+// String describes the options that are set, e.g.
+// "skill=beginner,tone=concise,kid-friendly,exclude=nut+almond".
+func (o Options) String() string {
+	var parts []string
+	for _, kv := range [][2]string{
+		{"skill", string(o.SkillLevel)}, {"tone", string(o.Tone)},
+		{"measurement", string(o.Measurement)}, {"verbosity", string(o.Verbosity)},
+	} {
+		if kv[1] != "" {
+			parts = append(parts, kv[0]+"="+kv[1])
+		}
+	}
+	if o.KidFriendly {
+		parts = append(parts, "kid-friendly")
+	}
+	if len(o.Exclude) > 0 {
+		parts = append(parts, "exclude="+strings.Join(o.Exclude, "+"))
+	}
+	if len(o.Nutrition) > 0 {
+		bounds := make([]string, len(o.Nutrition))
+		for i, c := range o.Nutrition {
+			bounds[i] = c.String()
+		}
+		parts = append(parts, "nutrition="+strings.Join(bounds, "+"))
+	}
+	if len(o.Fusion) > 0 {
+		parts = append(parts, "fusion="+strings.Join(o.Fusion, "+"))
+	}
+	if o.MaxTotalTime > 0 {
+		parts = append(parts, "max-time="+strconv.Itoa(o.MaxTotalTime))
+	}
+	if o.Count > 1 {
+		parts = append(parts, "count="+strconv.Itoa(o.Count))
+	}
+	if o.Course != "" {
+		parts = append(parts, "course="+o.Course)
+	}
+	if len(o.Companions) > 0 {
+		parts = append(parts, "with="+strings.Join(o.Companions, "+"))
+	}
+	if len(o.Occasions) > 0 {
+		names := make([]string, len(o.Occasions))
+		for i, oc := range o.Occasions {
+			names[i] = oc.Name
+		}
+		parts = append(parts, "occasion="+strings.Join(names, "+"))
+	}
+	return strings.Join(parts, ",")
+}
+
+// WithDefaults returns o with its unset style fields (tone, measurement and verbosity) taken
+// from defaults.
+func (o Options) WithDefaults(defaults Options) Options {
+	if o.Tone == "" {
+		o.Tone = defaults.Tone
+	}
+	if o.Measurement == "" {
+		o.Measurement = defaults.Measurement
+	}
+	if o.Verbosity == "" {
+		o.Verbosity = defaults.Verbosity
+	}
+	return o
+}
+
+// Guidance returns the instructions appended to the prompt for o, or "" for the zero value.
+func (o Options) Guidance() string {
+	var parts []string
+	switch o.SkillLevel {
+	case SkillBeginner:
+		parts = append(parts, "Write for a beginner cook: use few, short steps, common equipment and basic techniques, and explain any term a novice may not know.")
+	case SkillAdvanced:
+		parts = append(parts, "Write for an experienced cook: advanced techniques and precise temperatures and timings are welcome.")
+	}
+	if o.KidFriendly {
+		parts = append(parts, "Make the recipe kid-friendly: mild flavors with no hot spice, no alcohol, and minimal knife work or hot-oil steps, with steps simple enough for children to help with.")
+	}
+	if len(o.Exclude) > 0 {
+		parts = append(parts, "Do not use any of these ingredients, or anything made from them: "+strings.Join(o.Exclude, ", ")+".")
+	}
+	if len(o.Nutrition) > 0 {
+		bounds := make([]string, len(o.Nutrition))
+		for i, c := range o.Nutrition {
+			bounds[i] = describeBound(c)
+		}
+		parts = append(parts, "Per serving, keep "+strings.Join(bounds, " and ")+
+			", and give calories, protein, carbohydrates and fat per serving as numbers in nutritional_info.")
+	}
+	if len(o.Fusion) > 0 {
+		parts = append(parts, "Fuse "+listing(o.Fusion)+" cuisines in one dish: take techniques and ingredients from each so that all of them "+
+			"are recognisable, rather than adapting the dish to a single cuisine.")
+	}
+	if o.MaxTotalTime > 0 {
+		parts = append(parts, "The recipe must be ready in at most "+strconv.Itoa(o.MaxTotalTime)+
+			" minutes in total, preparation included; give that total in minutes as a number in total_time.")
+	}
+	if o.Count > 1 {
+		parts = append(parts, "Return exactly "+strconv.Itoa(o.Count)+" distinct recipes: the one that best fits the query as "+
+			"primary_recipe and the other "+strconv.Itoa(o.Count-1)+" in alternative_recipes, each a different dish rather than a variation of another.")
+	}
+	if o.Course != "" {
+		guidance := "Write the " + o.Course + " course of a multi-course menu for the occasion in the query, with portions sized for that course"
+		if len(o.Companions) > 0 {
+			guidance += "; it is served with " + listing(o.Companions) + ", so complement them and do not repeat their main ingredients"
+		}
+		parts = append(parts, guidance+".")
+	}
+	for _, oc := range o.Occasions {
+		if oc.Guidance != "" {
+			parts = append(parts, oc.Guidance)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// describeBound phrases a nutrition constraint for the prompt, e.g. "calories at most 500 kcal".
+func describeBound(c model.NutritionConstraint) string {
+	unit := " g"
+	if c.Nutrient == "calories" {
+		unit = " kcal"
+	}
+	amount := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) + unit }
+	switch {
+	case c.Min > 0 && c.Max > 0:
+		return c.Nutrient + " between " + amount(c.Min) + " and " + amount(c.Max)
+	case c.Min > 0:
+		return c.Nutrient + " at least " + amount(c.Min)
+	}
+	return c.Nutrient + " at most " + amount(c.Max)
+}
+
+// listing joins items as "a, b and c".
+func listing(items []string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
+
+type optionsKey struct{}
+
+// WithOptions returns a copy of ctx carrying o, which GenerateRecipeContext applies to the prompt.
+// Options travel in the context so that Generator implementations need not change.
+func WithOptions(ctx context.Context, o Options) context.Context {
+	return context.WithValue(ctx, optionsKey{}, o)
+}
+
+// OptionsFrom returns the Options carried by ctx, or the zero value.
+func OptionsFrom(ctx context.Context) Options {
+	o, _ := ctx.Value(optionsKey{}).(Options)
+	return o
+}
@@ -0,0 +1,145 @@
+// Package providertest provides a mock LLM provider for tests. The mock speaks both the
+// default and the DeepSeek response formats and can inject faults (latency, connection
+// resets, malformed or partial bodies, 429 bursts) so integration tests can exercise the
+// generation error paths and the resolver's fallback behavior end to end.
+//
+// Point the generation package at it by setting LLM_ENDPOINT to Server.URL.
+package providertest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/generation"
+)
+
+// Faults configures the failures injected by a Server. The zero value injects nothing.
+type Faults struct {
+	// Latency delays every response. The delay is abandoned if the client gives up first.
+	Latency time.Duration
+	// ResetConnection closes the TCP connection without writing a response.
+	ResetConnection bool
+	// MalformedJSON replies 200 with a body that is not valid JSON.
+	MalformedJSON bool
+	// PartialBody replies 200 with the first half of a valid body, then closes the connection.
+	PartialBody bool
+	// RateLimitBurst answers the next N requests with 429 Too Many Requests before
+	// recovering. Each rate-limited request decrements the remaining burst.
+	RateLimitBurst int
+}
+
+// Server is a mock LLM provider backed by httptest.Server.
+type Server struct {
+	// URL is the base URL of the mock, suitable for LLM_ENDPOINT.
+	URL string
+
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	response generation.LLMResponse
+	deepSeek bool
+	faults   Faults
+	requests int
+}
+
+// NewServer starts a mock provider replying with response. When deepSeek is true the reply is
+// wrapped in a DeepSeek chat completion, as returned when DEEPSEEK_API_KEY is set.
+func NewServer(response generation.LLMResponse, deepSeek bool) *Server {
+	s := &Server{response: response, deepSeek: deepSeek}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts the mock down.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// SetFaults replaces the faults injected into subsequent requests.
+func (s *Server) SetFaults(f Faults) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = f
+}
+
+// Requests returns the number of requests received so far, including failed ones.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	f := s.faults
+	if s.faults.RateLimitBurst > 0 {
+		s.faults.RateLimitBurst--
+	}
+	body := s.body()
+	s.mu.Unlock()
+
+	if f.Latency > 0 {
+		select {
+		case <-time.After(f.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	switch {
+	case f.RateLimitBurst > 0:
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+	case f.ResetConnection:
+		closeConnection(w)
+	case f.MalformedJSON:
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"primary_recipe": {"title": "Broken",, }`))
+	case f.PartialBody:
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body[:len(body)/2])
+		closeConnection(w)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}
+}
+
+// body renders the configured response in the provider's wire format. Callers hold s.mu.
+func (s *Server) body() []byte {
+	content, _ := json.Marshal(s.response)
+	if !s.deepSeek {
+		return content
+	}
+	wrapped, _ := json.Marshal(generation.DeepSeekResponse{
+		ID:     "chatcmpl-providertest",
+		Object: "chat.completion",
+		Choices: []generation.DeepSeekChoice{{
+			Message:      generation.DeepSeekMessage{Role: "assistant", Content: string(content)},
+			FinishReason: "stop",
+		}},
+	})
+	return wrapped
+}
+
+// closeConnection hijacks and closes the underlying connection so the client sees a reset
+// or an unexpected EOF rather than a well-formed HTTP response.
+func closeConnection(w http.ResponseWriter) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		panic("providertest: response writer does not support hijacking")
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	conn.Close()
+}
@@ -0,0 +1,251 @@
+package generation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tasks a Route can be restricted to.
+const (
+	TaskRecipe    = "recipe"
+	TaskNutrition = "nutrition"
+	TaskVision    = "vision"
+)
+
+// DefaultRoute is the name of the route taken when no configured route matches. It uses the
+// model from the DEEPSEEK_MODEL environment variable and the LLM_ENDPOINT endpoint.
+const DefaultRoute = "default"
+
+// Route sends the provider calls it matches to a particular model. Every condition that is set
+// must hold for a call to match; a route without conditions matches everything.
+type Route struct {
+	Name string `json:"name"`
+	// Task, if set, restricts the route to TaskRecipe, TaskNutrition or TaskVision calls. Calls
+	// with images (TaskVision) need a route to a vision-capable model.
+	Task string `json:"task,omitempty"`
+	// MinWords and MaxWords, if positive, bound the number of words in the query.
+	MinWords int `json:"min_words,omitempty"`
+	MaxWords int `json:"max_words,omitempty"`
+	// Constrained restricts the route to constrained queries: those requesting an audience
+	// through Options or naming a dietary or time restriction ("gluten-free", "under 30 minutes").
+	Constrained bool `json:"constrained,omitempty"`
+	// Keywords, if set, restricts the route to queries containing at least one of them.
+	Keywords []string `json:"keywords,omitempty"`
+
+	// Model is the provider model to use, e.g. "deepseek-reasoner".
+	Model string `json:"model"`
+	// Endpoint, if set, replaces LLM_ENDPOINT for the route, e.g. to reach a second provider.
+	Endpoint string `json:"endpoint,omitempty"`
+	// ContextWindow, if positive, is the model's context window in tokens, replacing
+	// ContextWindow for the prompt budget.
+	ContextWindow int `json:"context_window,omitempty"`
+	// Provider names the provider serving Endpoint, e.g. "deepseek", for callers choosing a
+	// route through Override.
+	Provider string `json:"provider,omitempty"`
+	// Selectable routes are on the allow-list of Override: trusted callers may force them for a
+	// request, whatever the conditions above.
+	Selectable bool `json:"selectable,omitempty"`
+
+	overridden bool // forced through WithRoute rather than selected
+}
+
+// constraintMarkers are words that make a query constrained.
+var constraintMarkers = []string{
+	"without", "no", "free", "vegan", "vegetarian", "keto", "paleo", "halal", "kosher",
+	"low", "high", "under", "less", "quick", "allergy", "allergic", "diabetic",
+}
+
+// matches reports whether r applies to a call for task with the given query and options.
+func (r Route) matches(task, query string, opts Options) bool {
+	words := strings.FieldsFunc(strings.ToLower(query), func(c rune) bool {
+		return c == ' ' || c == ',' || c == '-' || c == '.' || c == '\t'
+	})
+	if r.Task != "" && r.Task != task {
+		return false
+	}
+	if r.MinWords > 0 && len(words) < r.MinWords || r.MaxWords > 0 && len(words) > r.MaxWords {
+		return false
+	}
+	if r.Constrained && opts.SkillLevel == "" && !opts.KidFriendly && !containsAny(words, constraintMarkers) {
+		return false
+	}
+	if len(r.Keywords) > 0 {
+		lower := strings.ToLower(query)
+		found := false
+		for _, k := range r.Keywords {
+			if strings.Contains(lower, strings.ToLower(k)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func containsAny(words, set []string) bool {
+	for _, w := range words {
+		for _, s := range set {
+			if w == s {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RouteStats are the metrics of one route.
+type RouteStats struct {
+	Route    string `json:"route"`
+	Model    string `json:"model,omitempty"`
+	Requests int    `json:"requests"`
+	// Failures counts calls that returned an error, including unparseable responses.
+	Failures int `json:"failures"`
+	// TotalLatency is the summed duration of all calls; divide by Requests for the mean.
+	TotalLatency time.Duration `json:"total_latency_ns"`
+	// Overrides counts the calls, among Requests, that a caller forced onto the route (see
+	// WithRoute).
+	Overrides int `json:"overrides,omitempty"`
+}
+
+// Router selects a Route for each provider call, first match wins, and keeps per-route metrics.
+// It is safe for concurrent use.
+type Router struct {
+	Routes []Route
+
+	mu    sync.Mutex
+	stats map[string]*RouteStats
+}
+
+// NewRouter returns a Router over routes, which are tried in order.
+func NewRouter(routes ...Route) *Router {
+	return &Router{Routes: routes}
+}
+
+// LoadRouter reads routes from a JSON file holding an array of Route objects.
+func LoadRouter(path string) (*Router, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var routes []Route
+	if err := json.Unmarshal(data, &routes); err != nil {
+		return nil, fmt.Errorf("parsing routes file %s: %w", path, err)
+	}
+	for i, r := range routes {
+		if r.Name == "" || r.Model == "" {
+			return nil, fmt.Errorf("parsing routes file %s: route %d needs a name and a model", path, i)
+		}
+		if r.Task != "" && r.Task != TaskRecipe && r.Task != TaskNutrition && r.Task != TaskVision {
+			return nil, fmt.Errorf("parsing routes file %s: route %q has unknown task %q", path, r.Name, r.Task)
+		}
+	}
+	return NewRouter(routes...), nil
+}
+
+// Routing, if non-nil, selects the model of every provider call. If nil, every call takes the
+// default route.
+var Routing *Router
+
+// Select returns the first route matching a call, or a route named DefaultRoute with no model
+// override. A nil Router always returns the default route.
+func (rt *Router) Select(task, query string, opts Options) Route {
+	if rt != nil {
+		for _, r := range rt.Routes {
+			if r.matches(task, query, opts) {
+				return r
+			}
+		}
+	}
+	return Route{Name: DefaultRoute}
+}
+
+// ErrRouteNotAllowed is returned by Override when no selectable route matches the request.
+var ErrRouteNotAllowed = errors.New("provider and model are not on the allow-list")
+
+// Override returns the first Selectable route served by provider with model, for a trusted
+// caller choosing them for a request; an empty provider or model matches any. Providers are
+// compared regardless of case. It fails with ErrRouteNotAllowed if no selectable route matches,
+// and always for a nil Router.
+func (rt *Router) Override(provider, model string) (Route, error) {
+	if rt != nil {
+		for _, r := range rt.Routes {
+			if r.Selectable && (provider == "" || strings.EqualFold(r.Provider, provider)) && (model == "" || r.Model == model) {
+				return r, nil
+			}
+		}
+	}
+	return Route{}, fmt.Errorf("%w: provider %q, model %q", ErrRouteNotAllowed, provider, model)
+}
+
+type routeKey struct{}
+
+// WithRoute returns a copy of ctx that forces the provider calls made with it onto the route
+// named name, e.g. one returned by Override, instead of the one Select would choose. The call
+// counts toward the route's stats, and its prompt budget is the route's. If Routing has no such
+// route, e.g. after a reload, routes are selected as usual.
+func WithRoute(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, routeKey{}, name)
+}
+
+// selectFor returns the route forced by ctx through WithRoute, or else the route Select chooses.
+func (rt *Router) selectFor(ctx context.Context, task, query string, opts Options) Route {
+	if name, _ := ctx.Value(routeKey{}).(string); name != "" && rt != nil {
+		for _, r := range rt.Routes {
+			if r.Name == name {
+				r.overridden = true
+				return r
+			}
+		}
+	}
+	return rt.Select(task, query, opts)
+}
+
+// observe records a call taken through route r.
+func (rt *Router) observe(r Route, elapsed time.Duration, err error) {
+	if rt == nil {
+		return
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.stats == nil {
+		rt.stats = make(map[string]*RouteStats)
+	}
+	s, ok := rt.stats[r.Name]
+	if !ok {
+		s = &RouteStats{Route: r.Name, Model: r.Model}
+		rt.stats[r.Name] = s
+	}
+	s.Requests++
+	s.TotalLatency += elapsed
+	if r.overridden {
+		s.Overrides++
+	}
+	if err != nil {
+		s.Failures++
+	}
+}
+
+// Stats returns the metrics of every route that has served a call, sorted by route name.
+func (rt *Router) Stats() []RouteStats {
+	if rt == nil {
+		return nil
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	out := make([]RouteStats, 0, len(rt.stats))
+	for _, s := range rt.stats {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Route < out[j].Route })
+	return out
+}
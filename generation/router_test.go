@@ -0,0 +1,130 @@
+package generation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRouterSelect(t *testing.T) {
+	rt := NewRouter(
+		Route{Name: "nutrition", Task: TaskNutrition, Model: "small"},
+		Route{Name: "cheap", MaxWords: 3, Model: "cheap"},
+		Route{Name: "premium", Constrained: true, Model: "premium"},
+		Route{Name: "asian", Keywords: []string{"miso", "kimchi"}, Model: "specialist"},
+	)
+	tests := []struct {
+		task, query string
+		opts        Options
+		want        string
+	}{
+		{TaskNutrition, "a very long recipe title for nutrition", Options{}, "nutrition"},
+		{TaskRecipe, "pancakes", Options{}, "cheap"},
+		{TaskRecipe, "creamy pasta bake without dairy for a weeknight", Options{}, "premium"},
+		{TaskRecipe, "creamy pasta bake for a weeknight dinner", Options{KidFriendly: true}, "premium"},
+		{TaskRecipe, "spicy kimchi fried rice with egg", Options{}, "asian"},
+		{TaskRecipe, "slow roasted tomato and garlic soup", Options{}, DefaultRoute},
+	}
+	for _, tt := range tests {
+		if got := rt.Select(tt.task, tt.query, tt.opts).Name; got != tt.want {
+			t.Errorf("Select(%q, %q) = %q, want %q", tt.task, tt.query, got, tt.want)
+		}
+	}
+	if got := (*Router)(nil).Select(TaskRecipe, "pancakes", Options{}).Name; got != DefaultRoute {
+		t.Errorf("Expected a nil router to take the default route, got %q", got)
+	}
+}
+
+// TestRoutingModelAndStats verifies that the selected route's model is sent to the provider
+// and that calls are counted per route.
+func TestRoutingModelAndStats(t *testing.T) {
+	var model string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload llmRequest
+		json.NewDecoder(r.Body).Decode(&payload)
+		model = payload.Model
+		if model == "broken" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode(mockLLMResponse())
+	}))
+	defer srv.Close()
+	t.Setenv("LLM_ENDPOINT", srv.URL)
+	t.Setenv("DEEPSEEK_API_KEY", "")
+
+	Routing = NewRouter(Route{Name: "short", MaxWords: 2, Model: "cheap"}, Route{Name: "long", Model: "broken"})
+	defer func() { Routing = nil }()
+
+	if _, _, err := GenerateRecipeContext(context.Background(), "pancakes"); err != nil || model != "cheap" {
+		t.Errorf("Expected the cheap model, got %q (error %v)", model, err)
+	}
+	if _, _, err := GenerateRecipeContext(context.Background(), "slow cooker beef stew"); err == nil || model != "broken" {
+		t.Errorf("Expected the long route to fail, got model %q (error %v)", model, err)
+	}
+	stats := Routing.Stats()
+	if len(stats) != 2 || stats[0].Route != "long" || stats[0].Failures != 1 || stats[1].Route != "short" || stats[1].Requests != 1 || stats[1].Failures != 0 {
+		t.Errorf("Unexpected route stats %+v", stats)
+	}
+}
+
+// TestRouterOverride verifies that only selectable routes can be chosen and that forced calls
+// are taken through, and counted toward, the chosen route.
+func TestRouterOverride(t *testing.T) {
+	var model string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload llmRequest
+		json.NewDecoder(r.Body).Decode(&payload)
+		model = payload.Model
+		json.NewEncoder(w).Encode(mockLLMResponse())
+	}))
+	defer srv.Close()
+	t.Setenv("LLM_ENDPOINT", srv.URL)
+	t.Setenv("DEEPSEEK_API_KEY", "")
+
+	Routing = NewRouter(
+		Route{Name: "cheap", Model: "small", Provider: "acme"},
+		Route{Name: "premium", Model: "large", Provider: "acme", Selectable: true},
+	)
+	defer func() { Routing = nil }()
+
+	if _, err := Routing.Override("acme", "small"); !errors.Is(err, ErrRouteNotAllowed) {
+		t.Errorf("Expected a route off the allow-list to be refused, got %v", err)
+	}
+	if _, err := (*Router)(nil).Override("", "large"); !errors.Is(err, ErrRouteNotAllowed) {
+		t.Errorf("Expected a nil router to refuse overrides, got %v", err)
+	}
+	route, err := Routing.Override("ACME", "")
+	if err != nil || route.Name != "premium" {
+		t.Fatalf("Override() = %+v, %v", route, err)
+	}
+
+	if _, _, err := GenerateRecipeContext(WithRoute(context.Background(), route.Name), "pancakes"); err != nil || model != "large" {
+		t.Errorf("Expected the forced premium model, got %q (error %v)", model, err)
+	}
+	if _, _, err := GenerateRecipeContext(WithRoute(context.Background(), "removed"), "pancakes"); err != nil || model != "small" {
+		t.Errorf("Expected an unknown route to fall back to selection, got %q (error %v)", model, err)
+	}
+	stats := Routing.Stats()
+	if len(stats) != 2 || stats[1].Route != "premium" || stats[1].Requests != 1 || stats[1].Overrides != 1 || stats[0].Overrides != 0 {
+		t.Errorf("Unexpected route stats %+v", stats)
+	}
+}
+
+func TestLoadRouter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routes.json")
+	os.WriteFile(path, []byte(`[{"name": "cheap", "max_words": 3, "model": "deepseek-chat"}]`), 0o644)
+	rt, err := LoadRouter(path)
+	if err != nil || len(rt.Routes) != 1 || rt.Routes[0].MaxWords != 3 {
+		t.Fatalf("LoadRouter = %+v, %v", rt, err)
+	}
+	os.WriteFile(path, []byte(`[{"name": "x", "task": "translate", "model": "m"}]`), 0o644)
+	if _, err := LoadRouter(path); err == nil {
+		t.Error("Expected an unknown task to be rejected")
+	}
+}
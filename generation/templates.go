@@ -0,0 +1,108 @@
+package generation
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/nlp"
+)
+
+// TemplateStats are the outcome metrics of the recipe generations made with one prompt template
+// version (see TemplateVersion), so that a new template served to part of the traffic can be
+// compared with the current one before it replaces it.
+type TemplateStats struct {
+	Version  string `json:"version"`
+	Requests int    `json:"requests"`
+	// Failures counts calls that got no response from the provider.
+	Failures int `json:"failures"`
+	// ParseFailures counts responses that could not be parsed as recipes.
+	ParseFailures int `json:"parse_failures"`
+	// ParseFailureRate is ParseFailures over the responses received.
+	ParseFailureRate float64 `json:"parse_failure_rate"`
+	// TotalQuality sums the Quality of the parsed recipes; MeanQuality is its mean.
+	TotalQuality float64 `json:"total_quality"`
+	MeanQuality  float64 `json:"mean_quality"`
+	// TotalLatency is the summed duration of all calls; divide by Requests for the mean.
+	TotalLatency time.Duration `json:"total_latency_ns"`
+	// PromptTokens and CompletionTokens sum the token usage of the responses received.
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// TemplateMetrics keeps TemplateStats by template version. It is safe for concurrent use.
+type TemplateMetrics struct {
+	mu    sync.Mutex
+	stats map[string]*TemplateStats
+}
+
+// Templates records the outcome of every recipe generation by prompt template version.
+var Templates = new(TemplateMetrics)
+
+// observe records a generation made with the template of the given version. usage is that of
+// the response, if one was received; parsed reports whether it was parsed, and quality is the
+// Quality of the parsed recipes.
+func (m *TemplateMetrics) observe(version string, elapsed time.Duration, usage Usage, received, parsed bool, quality float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stats == nil {
+		m.stats = make(map[string]*TemplateStats)
+	}
+	s, ok := m.stats[version]
+	if !ok {
+		s = &TemplateStats{Version: version}
+		m.stats[version] = s
+	}
+	s.Requests++
+	s.TotalLatency += elapsed
+	switch {
+	case !received:
+		s.Failures++
+	case !parsed:
+		s.ParseFailures++
+	default:
+		s.TotalQuality += quality
+	}
+	s.PromptTokens += usage.PromptTokens
+	s.CompletionTokens += usage.CompletionTokens
+}
+
+// Stats returns the metrics of every template version that has served a generation, sorted by
+// version.
+func (m *TemplateMetrics) Stats() []TemplateStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]TemplateStats, 0, len(m.stats))
+	for _, s := range m.stats {
+		st := *s
+		if received := st.Requests - st.Failures; received > 0 {
+			st.ParseFailureRate = float64(st.ParseFailures) / float64(received)
+			if parsed := received - st.ParseFailures; parsed > 0 {
+				st.MeanQuality = st.TotalQuality / float64(parsed)
+			}
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out
+}
+
+// Quality scores a generated recipe between 0 and 1 as the mean of simple checks: relevance of
+// the title and ingredients to the query, enough ingredients and steps, an allergy disclaimer,
+// and at least one alternative.
+func Quality(query string, primary Recipe, alternatives []Recipe) float64 {
+	checks := []bool{
+		len(primary.Ingredients) >= 3,
+		len(primary.Steps) >= 2,
+		strings.TrimSpace(primary.AllergyDisclaimer) != "",
+		len(alternatives) > 0,
+	}
+	total := nlp.OverlapCoefficient(query, primary.Title+" "+strings.Join(primary.Ingredients, " "))
+	for _, c := range checks {
+		if c {
+			total++
+		}
+	}
+	return total / float64(len(checks)+1)
+}
@@ -0,0 +1,74 @@
+package generation
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// Usage counts the tokens spent on provider calls.
+type Usage struct {
+	Calls            int `json:"calls"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// Total returns the prompt and completion tokens together.
+func (u Usage) Total() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// Add returns the sum of u and v.
+func (u Usage) Add(v Usage) Usage {
+	return Usage{Calls: u.Calls + v.Calls, PromptTokens: u.PromptTokens + v.PromptTokens, CompletionTokens: u.CompletionTokens + v.CompletionTokens}
+}
+
+// Meter accumulates the Usage of the generations whose context carries it (see WithMeter). It is
+// safe for concurrent use.
+type Meter struct {
+	mu    sync.Mutex
+	usage Usage
+}
+
+// Usage returns the usage recorded so far.
+func (m *Meter) Usage() Usage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.usage
+}
+
+func (m *Meter) add(u Usage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.usage = m.usage.Add(u)
+}
+
+type meterKey struct{}
+
+// WithMeter returns a copy of ctx carrying m, to which GenerateRecipeContext adds the tokens of
+// each provider call, including calls whose reply fails to parse.
+func WithMeter(ctx context.Context, m *Meter) context.Context {
+	return context.WithValue(ctx, meterKey{}, m)
+}
+
+// meterFrom returns the Meter carried by ctx, or nil.
+func meterFrom(ctx context.Context) *Meter {
+	m, _ := ctx.Value(meterKey{}).(*Meter)
+	return m
+}
+
+// usageOf returns the usage of one call with prompt answered by reply: the provider's own count
+// when reply carries an OpenAI-style "usage" object, as DeepSeek's does, or else an estimate (see
+// EstimateTokens).
+func usageOf(prompt string, reply []byte) Usage {
+	var counted struct {
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if json.Unmarshal(reply, &counted) == nil && counted.Usage.PromptTokens+counted.Usage.CompletionTokens > 0 {
+		return Usage{Calls: 1, PromptTokens: counted.Usage.PromptTokens, CompletionTokens: counted.Usage.CompletionTokens}
+	}
+	return Usage{Calls: 1, PromptTokens: EstimateTokens(prompt), CompletionTokens: EstimateTokens(string(reply))}
+}
@@ -0,0 +1,56 @@
+package generation
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// Validate checks a generated recipe against the schema requested in the prompt and returns
+// a description of every problem found. An empty result means the recipe is valid.
+func Validate(r Recipe) []string {
+	var problems []string
+	if strings.TrimSpace(r.ID) == "" {
+		problems = append(problems, "id is empty")
+	}
+	if strings.TrimSpace(r.Title) == "" {
+		problems = append(problems, "title is empty")
+	}
+	if len(r.Ingredients) == 0 {
+		problems = append(problems, "ingredients are empty")
+	}
+	for i, ing := range r.Ingredients {
+		if strings.TrimSpace(ing) == "" {
+			problems = append(problems, "ingredient "+strconv.Itoa(i)+" is empty")
+		}
+	}
+	if len(r.Steps) == 0 {
+		problems = append(problems, "steps are empty")
+	}
+	for i, step := range r.Steps {
+		if strings.TrimSpace(step) == "" {
+			problems = append(problems, "step "+strconv.Itoa(i)+" is empty")
+		}
+	}
+	if r.NutritionalInfo == nil {
+		problems = append(problems, "nutritional_info is missing")
+	}
+	if r.TotalTime < 0 {
+		problems = append(problems, "total_time is negative")
+	}
+	if !validTimestamp(r.CreatedAt) {
+		problems = append(problems, "created_at is not an RFC 3339 timestamp, date or epoch time")
+	}
+	if !validTimestamp(r.UpdatedAt) {
+		problems = append(problems, "updated_at is not an RFC 3339 timestamp, date or epoch time")
+	}
+	return problems
+}
+
+// validTimestamp reports whether s is in one of the formats the resolver accepts (see
+// model.ParseTimestamp).
+func validTimestamp(s string) bool {
+	_, err := model.ParseTimestamp(s)
+	return err == nil
+}
@@ -0,0 +1,32 @@
+package generation
+
+import (
+	"testing"
+)
+
+// TestValidate verifies that a complete recipe passes and that each schema problem is reported.
+func TestValidate(t *testing.T) {
+	valid := mockLLMResponse().PrimaryRecipe
+	if problems := Validate(valid); len(problems) != 0 {
+		t.Errorf("Expected no problems for a complete recipe, got %v", problems)
+	}
+
+	invalid := Recipe{Title: "Soup", Ingredients: []string{"water", " "}, TotalTime: -5, CreatedAt: "yesterday", UpdatedAt: "2025-02-19"}
+	problems := Validate(invalid)
+	want := []string{
+		"id is empty",
+		"ingredient 1 is empty",
+		"steps are empty",
+		"nutritional_info is missing",
+		"total_time is negative",
+		"created_at is not an RFC 3339 timestamp, date or epoch time",
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("Expected problems %v, got %v", want, problems)
+	}
+	for i := range want {
+		if problems[i] != want[i] {
+			t.Errorf("Expected problem %q, got %q", want[i], problems[i])
+		}
+	}
+}
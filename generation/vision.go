@@ -0,0 +1,163 @@
+package generation
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Image is a picture sent to a vision-capable model along with a prompt.
+type Image struct {
+	// MIMEType is the image format, e.g. "image/jpeg". If empty, it is sniffed from Data.
+	MIMEType string
+	Data     []byte
+}
+
+// mimeType returns the image's MIME type, sniffed from its data when unset.
+func (img Image) mimeType() string {
+	if img.MIMEType != "" {
+		return img.MIMEType
+	}
+	return http.DetectContentType(img.Data)
+}
+
+// dataURL returns the image as a data URL, the form chat APIs accept inline images in.
+func (img Image) dataURL() string {
+	return "data:" + img.mimeType() + ";base64," + base64.StdEncoding.EncodeToString(img.Data)
+}
+
+// DescribePrompt asks a vision model to name the dish in a photo in words fit for a recipe query.
+const DescribePrompt = "Identify the dish in this photo. Return only a JSON object with the key " +
+	"description: the dish's name and its main visible ingredients in a few words, e.g. " +
+	"\"spaghetti bolognese with beef and tomato sauce\". If the photo shows no dish, " +
+	"return an empty description."
+
+// DescribeImage asks the configured LLM provider to describe the dish in a food photo, so that
+// the description can be resolved as a query. It fails with ErrNoDish if the provider sees no
+// dish. The model is chosen by Routing for TaskVision and must accept images.
+func DescribeImage(ctx context.Context, img Image) (description string, err error) {
+	route := Routing.selectFor(ctx, TaskVision, "", Options{})
+	defer func(start time.Time) { Routing.observe(route, time.Since(start), err) }(time.Now())
+	resp, deepSeek, err := send(ctx, DescribePrompt, route, img)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	return parseDescription(resp.Body, deepSeek)
+}
+
+// parseDescription decodes a provider response to DescribePrompt, in the same envelope formats
+// as ParseResponse.
+func parseDescription(body io.Reader, deepSeek bool) (string, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	content := string(data)
+	if deepSeek {
+		if content, err = deepSeekContent(bytes.NewReader(data)); err != nil {
+			return "", err
+		}
+	}
+
+	var reply struct {
+		Description string `json:"description"`
+	}
+	raw := extractJSON(content)
+	if err := json.Unmarshal([]byte(raw), &reply); err != nil {
+		return "", decodeError(err, []byte(raw))
+	}
+	description := strings.Join(strings.Fields(reply.Description), " ")
+	if description == "" {
+		return "", fmt.Errorf("%w: empty description", ErrNoDish)
+	}
+	return description, nil
+}
+
+// ExtractPrompt asks a vision model to transcribe the recipe on a photographed recipe card or
+// cookbook page into the JSON recipe schema.
+const ExtractPrompt = "Transcribe the recipe in this photo of a recipe card or cookbook page. " +
+	"Return only a JSON object with the keys title, ingredients (an array of strings, one per " +
+	"ingredient with its quantity), steps (an array of strings, in order), nutritional_info " +
+	"(an object, only if the page gives it), allergy_disclaimer, appliances (an array of strings), " +
+	"difficulty (easy, medium or hard) and total_time (in minutes, 0 if not given). Copy the text " +
+	"as written without adding anything. If the photo shows no recipe, return an empty title."
+
+// ExtractRecipe asks the configured LLM provider to transcribe the recipe in a photo of a recipe
+// card or cookbook page. Blank ingredients and steps are dropped and the nutritional info is
+// formatted with NutritionFormat; a photo without a title, ingredients and steps fails with
+// ErrNoRecipe. The recipe has no ID or timestamps. The model is chosen by Routing for TaskVision
+// and reported through WithSource.
+func ExtractRecipe(ctx context.Context, img Image) (recipe Recipe, err error) {
+	route := Routing.selectFor(ctx, TaskVision, "", Options{})
+	defer func(start time.Time) { Routing.observe(route, time.Since(start), err) }(time.Now())
+	resp, deepSeek, err := send(ctx, ExtractPrompt, route, img)
+	if err != nil {
+		return Recipe{}, err
+	}
+	defer resp.Body.Close()
+	if s := sourceFrom(ctx); s != nil {
+		s.Model = requestModel(route, deepSeek)
+	}
+	return parseExtraction(resp.Body, deepSeek)
+}
+
+// parseExtraction decodes and cleans up a provider response to ExtractPrompt, in the same
+// envelope formats as ParseResponse.
+func parseExtraction(body io.Reader, deepSeek bool) (Recipe, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return Recipe{}, err
+	}
+	content := string(data)
+	if deepSeek {
+		if content, err = deepSeekContent(bytes.NewReader(data)); err != nil {
+			return Recipe{}, err
+		}
+	}
+
+	r, err := decodeRecipe([]byte(extractJSON(content)))
+	if err != nil {
+		return Recipe{}, err
+	}
+	r.ID, r.CreatedAt, r.UpdatedAt = "", "", ""
+	r.Title = strings.TrimSpace(r.Title)
+	r.Ingredients, r.Steps, r.Appliances = nonBlank(r.Ingredients), nonBlank(r.Steps), nonBlank(r.Appliances)
+	r.AllergyDisclaimer = strings.TrimSpace(r.AllergyDisclaimer)
+	r.NutritionalInfo = NutritionFormat.Normalize(r.NutritionalInfo)
+	if r.TotalTime < 0 {
+		r.TotalTime = 0
+	}
+
+	var missing []string
+	if r.Title == "" {
+		missing = append(missing, "title")
+	}
+	if len(r.Ingredients) == 0 {
+		missing = append(missing, "ingredients")
+	}
+	if len(r.Steps) == 0 {
+		missing = append(missing, "steps")
+	}
+	if len(missing) > 0 {
+		return Recipe{}, fmt.Errorf("%w: no %s", ErrNoRecipe, strings.Join(missing, ", "))
+	}
+	return r, nil
+}
+
+// nonBlank returns list with its entries trimmed and the blank ones dropped.
+func nonBlank(list []string) []string {
+	var out []string
+	for _, s := range list {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,155 @@
+// Package glossary detects cooking technique terms in recipe steps and explains them, for
+// clients that show inline help to beginners.
+package glossary
+
+import (
+	_ "embed"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:embed glossary.json
+var bundled []byte
+
+// Annotation marks a glossary term in a step.
+type Annotation struct {
+	// Step is the index of the step in the recipe.
+	Step int `json:"step"`
+	// Start and End are the byte offsets of Text within the step.
+	Start int `json:"start"`
+	End   int `json:"end"`
+	// Text is the term as written in the step, e.g. "Deglazed".
+	Text string `json:"text"`
+	// Term is the glossary entry, e.g. "deglaze".
+	Term       string `json:"term"`
+	Definition string `json:"definition"`
+}
+
+// Glossary maps technique terms to their definitions.
+type Glossary struct {
+	definitions map[string]string
+	pattern     *regexp.Regexp
+}
+
+// Default returns the bundled glossary.
+func Default() *Glossary {
+	var defs map[string]string
+	if err := json.Unmarshal(bundled, &defs); err != nil {
+		panic("glossary: bundled dataset is invalid: " + err.Error())
+	}
+	return New(defs)
+}
+
+// New returns a glossary of the given term definitions. Terms are matched case-insensitively
+// together with their common inflections ("blanched", "folding", "sears").
+func New(definitions map[string]string) *Glossary {
+	g := &Glossary{definitions: make(map[string]string, len(definitions))}
+	terms := make([]string, 0, len(definitions))
+	for term, def := range definitions {
+		term = strings.ToLower(term)
+		g.definitions[term] = def
+		terms = append(terms, term)
+	}
+	// Longer terms first, so "al dente" is preferred over any shorter overlapping term.
+	sort.Slice(terms, func(i, j int) bool {
+		if len(terms[i]) != len(terms[j]) {
+			return len(terms[i]) > len(terms[j])
+		}
+		return terms[i] < terms[j]
+	})
+	alternatives := make([]string, len(terms))
+	for i, term := range terms {
+		alternatives[i] = inflections(term)
+	}
+	// Word boundaries are checked in Annotate: \b only understands ASCII, and terms such as
+	// "sauté" end in a non-ASCII letter.
+	g.pattern = regexp.MustCompile(`(?i)(?:` + strings.Join(alternatives, "|") + `)`)
+	return g
+}
+
+// inflections returns a pattern matching term and its regular verb and plural forms:
+// "braise" -> braises/braised/braising, "clarify" -> clarifies/clarified, "whip" -> whipped.
+// Multi-word terms are matched as written.
+func inflections(term string) string {
+	if strings.Contains(term, " ") {
+		return regexp.QuoteMeta(term)
+	}
+	if stem, ok := strings.CutSuffix(term, "e"); ok {
+		return regexp.QuoteMeta(stem) + `(?:es|ed|ing|e)`
+	}
+	if stem, ok := strings.CutSuffix(term, "y"); ok && !isVowel(stem[len(stem)-1]) {
+		return regexp.QuoteMeta(stem) + `(?:ies|ied|ying|y)`
+	}
+	// Alternatives are tried in order, so longer endings come first.
+	forms := `ing|es|ed|s`
+	if doublesConsonant(term) {
+		last := term[len(term)-1:]
+		forms = last + `ing|` + last + `ed|` + forms
+	}
+	return regexp.QuoteMeta(term) + `(?:` + forms + `)?`
+}
+
+// doublesConsonant reports whether a short verb doubles its final consonant before -ed and
+// -ing, as "whip" does: it ends in a single vowel followed by a consonant other than w, x or y.
+func doublesConsonant(term string) bool {
+	n := len(term)
+	if n < 3 {
+		return false
+	}
+	last := term[n-1]
+	return last >= 'a' && last <= 'z' && !isVowel(last) && !strings.ContainsRune("wxy", rune(last)) &&
+		isVowel(term[n-2]) && !isVowel(term[n-3])
+}
+
+func isVowel(b byte) bool {
+	return strings.IndexByte("aeiou", b) >= 0
+}
+
+// Annotate returns the glossary terms found in steps, in order of appearance.
+func (g *Glossary) Annotate(steps []string) []Annotation {
+	var out []Annotation
+	for i, step := range steps {
+		for _, loc := range g.pattern.FindAllStringIndex(step, -1) {
+			before, _ := utf8.DecodeLastRuneInString(step[:loc[0]])
+			after, _ := utf8.DecodeRuneInString(step[loc[1]:])
+			if unicode.IsLetter(before) || unicode.IsLetter(after) {
+				continue
+			}
+			text := step[loc[0]:loc[1]]
+			term, ok := g.term(text)
+			if !ok {
+				continue
+			}
+			out = append(out, Annotation{Step: i, Start: loc[0], End: loc[1], Text: text, Term: term, Definition: g.definitions[term]})
+		}
+	}
+	return out
+}
+
+// term maps an inflected word back to its glossary entry.
+func (g *Glossary) term(text string) (string, bool) {
+	lower := strings.ToLower(text)
+	if _, ok := g.definitions[lower]; ok {
+		return lower, true
+	}
+	for _, suffix := range []string{"ying", "ied", "ies", "ing", "ed", "es", "s"} {
+		stem, ok := strings.CutSuffix(lower, suffix)
+		if !ok {
+			continue
+		}
+		candidates := []string{stem, stem + "e", stem + "y"}
+		if n := len(stem); n > 1 && stem[n-1] == stem[n-2] {
+			candidates = append(candidates, stem[:n-1])
+		}
+		for _, candidate := range candidates {
+			if _, ok := g.definitions[candidate]; ok {
+				return candidate, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,52 @@
+package glossary
+
+import "testing"
+
+func TestAnnotate(t *testing.T) {
+	steps := []string{
+		"Sear the pork, then deglaze with cider.",
+		"Meanwhile, blanched beans: cook al dente.",
+		"Folding gently, add the whipped cream.",
+		"Serve.",
+	}
+	got := Default().Annotate(steps)
+	want := []struct {
+		step       int
+		text, term string
+	}{
+		{0, "Sear", "sear"},
+		{0, "deglaze", "deglaze"},
+		{1, "blanched", "blanch"},
+		{1, "al dente", "al dente"},
+		{2, "Folding", "fold"},
+		{2, "whipped", "whip"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d annotations, got %d: %+v", len(want), len(got), got)
+	}
+	for i, w := range want {
+		a := got[i]
+		if a.Step != w.step || a.Text != w.text || a.Term != w.term || a.Definition == "" {
+			t.Errorf("Annotation %d: expected %+v, got %+v", i, w, a)
+		}
+		if steps[a.Step][a.Start:a.End] != a.Text {
+			t.Errorf("Annotation %d: offsets %d:%d do not match %q", i, a.Start, a.End, a.Text)
+		}
+	}
+}
+
+func TestAnnotateIgnoresEmbeddedWords(t *testing.T) {
+	g := New(map[string]string{"sear": "Brown quickly.", "zest": "Citrus peel."})
+	if got := g.Annotate([]string{"Research shows zesty dishes are popular."}); len(got) != 0 {
+		t.Errorf("Expected no annotations inside other words, got %+v", got)
+	}
+}
+
+func TestInflections(t *testing.T) {
+	g := Default()
+	for _, word := range []string{"emulsified", "clarifies", "braising", "sautéed", "kneads", "simmering", "whipping", "sauté", "reduces", "poaches"} {
+		if got := g.Annotate([]string{word}); len(got) != 1 {
+			t.Errorf("Expected %q to be annotated, got %+v", word, got)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Package intent extracts structured constraints from free-text recipe queries, such as the
+// calorie limit in "high protein lunch under 500 kcal" or the time limit in "pasta ready in 20
+// minutes", so that they can be checked against recipe data rather than matched against titles.
+package intent
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// Bounds implied by qualitative phrases, per serving.
+const (
+	// HighProteinGrams is the minimum protein of a "high protein" recipe.
+	HighProteinGrams = 25
+	// LowCarbGrams is the maximum carbohydrates of a "low carb" recipe.
+	LowCarbGrams = 20
+	// LowFatGrams is the maximum fat of a "low fat" recipe.
+	LowFatGrams = 10
+	// LowCalories is the maximum calories of a "low calorie" recipe.
+	LowCalories = 400
+)
+
+// Intent is what a query asks for beyond its text.
+type Intent struct {
+	// Text is the query without its numeric constraints, e.g. "high protein lunch" for "high
+	// protein lunch under 500 kcal". It is the whole query if nothing else is left.
+	Text string
+	// Nutrition bounds calories and macronutrients per serving.
+	Nutrition []model.NutritionConstraint
+	// MaxTotalTime limits preparation and cooking time, in minutes; zero means no limit.
+	MaxTotalTime int
+	// Count is the number of distinct recipes asked for, e.g. 3 for "3 dinner ideas with
+	// salmon"; zero means one.
+	Count int
+}
+
+const comparator = `(under|below|less than|fewer than|no more than|at most|max(?:imum)?|up to|over|above|more than|at least|min(?:imum)?)`
+
+var (
+	caloriePattern = regexp.MustCompile(`(?i)\b` + comparator + `\s+(\d+(?:\.\d+)?)\s*(?:kcal|k?cals?|calories)\b`)
+	gramPattern    = regexp.MustCompile(`(?i)\b` + comparator + `\s+(\d+(?:\.\d+)?)\s*(?:g|grams?)\s+(?:of\s+)?(protein|carbs?|carbohydrates?|fat)\b`)
+	// timePattern matches limits such as "ready in 20 minutes", "under an hour" and "within
+	// 1.5 hrs"; durationPattern matches adjectives such as "30-minute".
+	timePattern     = regexp.MustCompile(`(?i)\b(?:(?:ready|done|made|cooked)\s+)?(?:in|under|below|within|less than|no more than|at most|max(?:imum)?|up to)\s+(\d+(?:\.\d+)?|an?|one|half an)\s*(minutes?|mins?|hours?|hrs?|h)\b`)
+	durationPattern = regexp.MustCompile(`(?i)\b(\d+)[\s-](minute|min|hour)\b`)
+	// countPattern matches requests for several recipes such as "3 dinner ideas", "give me
+	// five options for" and "two ways to cook"; the words between the number and the noun stay
+	// in the text.
+	countPattern = regexp.MustCompile(`(?i)\b(?:(?:give|show|suggest|send)(?:\s+me)?\s+)?(\d+|two|three|four|five|six|seven|eight|nine|ten)\s+((?:[a-z]+\s+){0,2}?)` +
+		`(?:ideas|options|recipes|suggestions|ways|variations|dishes|meals)\b(?:\s+(?:for|of|using|to\s+(?:cook|make|use))\b)?`)
+	numbers = map[string]int{"two": 2, "three": 3, "four": 4, "five": 5, "six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10}
+	// qualitativePattern matches phrases such as "high protein" and "low-carb", which stay in
+	// the text since they also describe the dish.
+	qualitativePattern = regexp.MustCompile(`(?i)\b(high|low)[\s-]+(protein|carbs?|carbohydrates?|fat|calories?|cal)\b`)
+	// connectives are words left dangling at either end once constraints are removed.
+	connectives = map[string]bool{"and": true, "with": true, "in": true, "of": true, "at": true, "that": true, "is": true}
+)
+
+// nutrient maps the spellings in queries to the keys of recipes' nutritional info.
+func nutrient(s string) string {
+	s = strings.ToLower(s)
+	switch {
+	case strings.HasPrefix(s, "carb"):
+		return "carbohydrates"
+	case strings.HasPrefix(s, "cal"):
+		return "calories"
+	}
+	return s
+}
+
+// Extract parses the constraints in query. Numeric constraints ("under 500 kcal", "at least
+// 30 g protein") override qualitative ones ("high protein") on the same nutrient. Of several
+// time limits, the shortest applies, and of several counts, the first.
+func Extract(query string) Intent {
+	bounds := make(map[string]*model.NutritionConstraint)
+	var order []string
+	var maxTime, count int
+	bound := func(n string) *model.NutritionConstraint {
+		if c, ok := bounds[n]; ok {
+			return c
+		}
+		order = append(order, n)
+		bounds[n] = &model.NutritionConstraint{Nutrient: n}
+		return bounds[n]
+	}
+	set := func(n, cmp string, v float64) {
+		c := bound(n)
+		switch strings.ToLower(cmp) {
+		case "over", "above", "more than", "at least", "min", "minimum":
+			c.Min = v
+		default:
+			c.Max = v
+		}
+	}
+
+	for _, m := range qualitativePattern.FindAllStringSubmatch(query, -1) {
+		switch n := nutrient(m[2]); {
+		case strings.EqualFold(m[1], "high") && n == "protein":
+			bound(n).Min = HighProteinGrams
+		case strings.EqualFold(m[1], "low") && n == "carbohydrates":
+			bound(n).Max = LowCarbGrams
+		case strings.EqualFold(m[1], "low") && n == "fat":
+			bound(n).Max = LowFatGrams
+		case strings.EqualFold(m[1], "low") && n == "calories":
+			bound(n).Max = LowCalories
+		}
+	}
+	text := caloriePattern.ReplaceAllStringFunc(query, func(s string) string {
+		m := caloriePattern.FindStringSubmatch(s)
+		v, _ := strconv.ParseFloat(m[2], 64)
+		set("calories", m[1], v)
+		return " "
+	})
+	text = gramPattern.ReplaceAllStringFunc(text, func(s string) string {
+		m := gramPattern.FindStringSubmatch(s)
+		v, _ := strconv.ParseFloat(m[2], 64)
+		set(nutrient(m[3]), m[1], v)
+		return " "
+	})
+
+	limit := func(amount, unit string) {
+		var v float64
+		switch strings.ToLower(amount) {
+		case "a", "an", "one":
+			v = 1
+		case "half an":
+			v = 0.5
+		default:
+			v, _ = strconv.ParseFloat(amount, 64)
+		}
+		if strings.HasPrefix(strings.ToLower(unit), "h") {
+			v *= 60
+		}
+		if m := int(math.Round(v)); m > 0 && (maxTime == 0 || m < maxTime) {
+			maxTime = m
+		}
+	}
+	text = timePattern.ReplaceAllStringFunc(text, func(s string) string {
+		m := timePattern.FindStringSubmatch(s)
+		limit(m[1], m[2])
+		return " "
+	})
+	text = durationPattern.ReplaceAllStringFunc(text, func(s string) string {
+		m := durationPattern.FindStringSubmatch(s)
+		limit(m[1], m[2])
+		return " "
+	})
+
+	text = countPattern.ReplaceAllStringFunc(text, func(s string) string {
+		m := countPattern.FindStringSubmatch(s)
+		n, ok := numbers[strings.ToLower(m[1])]
+		if !ok {
+			n, _ = strconv.Atoi(m[1])
+		}
+		if count == 0 && n > 1 {
+			count = n
+		}
+		return " " + m[2]
+	})
+
+	in := Intent{Text: clean(text), MaxTotalTime: maxTime, Count: count}
+	if in.Text == "" {
+		in.Text = strings.TrimSpace(query)
+	}
+	for _, n := range order {
+		in.Nutrition = append(in.Nutrition, *bounds[n])
+	}
+	return in
+}
+
+// clean collapses whitespace in text and drops connectives left at either end.
+func clean(text string) string {
+	words := strings.Fields(text)
+	for len(words) > 0 && connectives[strings.ToLower(words[len(words)-1])] {
+		words = words[:len(words)-1]
+	}
+	for len(words) > 0 && connectives[strings.ToLower(words[0])] {
+		words = words[1:]
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,60 @@
+package intent
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		query string
+		want  Intent
+	}{
+		{"chicken salad", Intent{Text: "chicken salad"}},
+		{"high protein lunch under 500 kcal", Intent{
+			Text: "high protein lunch",
+			Nutrition: []model.NutritionConstraint{
+				{Nutrient: "protein", Min: HighProteinGrams},
+				{Nutrient: "calories", Max: 500},
+			},
+		}},
+		{"Low-carb dinner with at least 40g of protein and less than 15 grams fat", Intent{
+			Text: "Low-carb dinner",
+			Nutrition: []model.NutritionConstraint{
+				{Nutrient: "carbohydrates", Max: LowCarbGrams},
+				{Nutrient: "protein", Min: 40},
+				{Nutrient: "fat", Max: 15},
+			},
+		}},
+		{"high protein snack over 30 g protein", Intent{
+			Text:      "high protein snack",
+			Nutrition: []model.NutritionConstraint{{Nutrient: "protein", Min: 30}},
+		}},
+		{"under 300 calories", Intent{
+			Text:      "under 300 calories",
+			Nutrition: []model.NutritionConstraint{{Nutrient: "calories", Max: 300}},
+		}},
+		{"pasta for under 10 people", Intent{Text: "pasta for under 10 people"}},
+		{"pasta ready in 20 minutes", Intent{Text: "pasta", MaxTotalTime: 20}},
+		{"chicken dinner under an hour", Intent{Text: "chicken dinner", MaxTotalTime: 60}},
+		{"30-minute weeknight curry", Intent{Text: "weeknight curry", MaxTotalTime: 30}},
+		{"beef stew within 1.5 hrs", Intent{Text: "beef stew", MaxTotalTime: 90}},
+		{"lunch under 400 calories in 15 mins", Intent{
+			Text:         "lunch",
+			Nutrition:    []model.NutritionConstraint{{Nutrient: "calories", Max: 400}},
+			MaxTotalTime: 15,
+		}},
+		{"rest the dough for 30 minutes", Intent{Text: "rest the dough for 30 minutes"}},
+		{"3 dinner ideas with salmon", Intent{Text: "dinner with salmon", Count: 3}},
+		{"Give me five options for chicken thighs", Intent{Text: "chicken thighs", Count: 5}},
+		{"two ways to cook tofu in 20 minutes", Intent{Text: "tofu", MaxTotalTime: 20, Count: 2}},
+		{"2 eggs on toast", Intent{Text: "2 eggs on toast"}},
+	}
+	for _, tt := range tests {
+		if got := Extract(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Extract(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
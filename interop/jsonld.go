@@ -0,0 +1,286 @@
+package interop
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// jsonLDNutrients maps the properties of a schema.org NutritionInformation to the resolver's
+// nutrient names. Other properties, such as servingSize, are dropped.
+var jsonLDNutrients = map[string]string{
+	"calories":            "calories",
+	"proteinContent":      "protein",
+	"carbohydrateContent": "carbohydrates",
+	"fatContent":          "fat",
+	"saturatedFatContent": "saturated_fat",
+	"fiberContent":        "fiber",
+	"sugarContent":        "sugar",
+	"sodiumContent":       "sodium",
+	"cholesterolContent":  "cholesterol",
+}
+
+// ImportJSONLD reads schema.org Recipe objects in JSON-LD, as recipe websites embed them in
+// their pages. The document may be a single Recipe, an array of objects or a @graph; objects of
+// other types, such as the WebPage or Organization a site describes alongside the recipe, are
+// skipped. It is an error if the document holds no recipe at all.
+func ImportJSONLD(r io.Reader) ([]model.Recipe, error) {
+	var doc interface{}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parsing JSON-LD: %w", err)
+	}
+	var nodes []map[string]interface{}
+	collectRecipes(doc, &nodes)
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("JSON-LD document has no schema.org Recipe")
+	}
+	recipes := make([]model.Recipe, 0, len(nodes))
+	for i, n := range nodes {
+		if jsonLDText(n["name"]) == "" {
+			return nil, fmt.Errorf("JSON-LD recipe %d has no name", i)
+		}
+		recipes = append(recipes, fromJSONLD(n))
+	}
+	return recipes, nil
+}
+
+// collectRecipes appends the Recipe objects in v to nodes, looking through arrays and @graph.
+func collectRecipes(v interface{}, nodes *[]map[string]interface{}) {
+	switch typed := v.(type) {
+	case []interface{}:
+		for _, item := range typed {
+			collectRecipes(item, nodes)
+		}
+	case map[string]interface{}:
+		for _, t := range jsonLDList(typed["@type"]) {
+			if s, _ := t.(string); s == "Recipe" || strings.HasSuffix(s, "/Recipe") {
+				*nodes = append(*nodes, typed)
+				return
+			}
+		}
+		collectRecipes(typed["@graph"], nodes)
+	}
+}
+
+func fromJSONLD(n map[string]interface{}) model.Recipe {
+	r := model.NewRecipe(jsonLDText(n["name"]), []string{}, []string{}, jsonLDNutrition(n["nutrition"]), "", []string{})
+	r.Provenance = &model.Provenance{Origin: model.OriginImported, Source: "jsonld"}
+	url := jsonLDText(n["url"])
+	if id := jsonLDText(n["@id"]); id != "" {
+		r.ID = stableID("jsonld", id)
+	} else if url != "" {
+		r.ID = stableID("jsonld", url)
+	}
+
+	ingredients := n["recipeIngredient"]
+	if ingredients == nil {
+		// "ingredients" is the property's older name, still found on some sites.
+		ingredients = n["ingredients"]
+	}
+	for _, ing := range jsonLDList(ingredients) {
+		if text := jsonLDText(ing); text != "" {
+			r.Ingredients = append(r.Ingredients, text)
+		}
+	}
+	r.Steps = append(r.Steps, jsonLDSteps(n["recipeInstructions"])...)
+
+	if total, _ := parseISODuration(jsonLDText(n["totalTime"])); total > 0 {
+		r.TotalTime = total
+	} else {
+		prep, _ := parseISODuration(jsonLDText(n["prepTime"]))
+		cook, _ := parseISODuration(jsonLDText(n["cookTime"]))
+		r.TotalTime = prep + cook
+	}
+	r.ImageURL = jsonLDImage(n["image"])
+
+	seen := make(map[string]bool)
+	tag := func(t string) {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" && !seen[t] {
+			seen[t] = true
+			r.Tags = append(r.Tags, t)
+		}
+	}
+	for _, k := range jsonLDList(n["keywords"]) {
+		// Keywords are usually one comma-separated string.
+		for _, t := range strings.Split(jsonLDText(k), ",") {
+			tag(t)
+		}
+	}
+	for _, c := range jsonLDList(n["recipeCategory"]) {
+		tag(jsonLDText(c))
+	}
+	for _, c := range jsonLDList(n["recipeCuisine"]) {
+		if c := jsonLDText(c); c != "" {
+			tag(model.CuisineTag(c))
+		}
+	}
+	for _, tool := range jsonLDList(n["tool"]) {
+		if name := jsonLDName(tool); name != "" {
+			r.Appliances = append(r.Appliances, strings.ToLower(name))
+		}
+	}
+
+	var a model.Attribution
+	var authors []string
+	for _, author := range jsonLDList(n["author"]) {
+		if name := jsonLDName(author); name != "" {
+			authors = append(authors, name)
+		}
+	}
+	a.Author = strings.Join(authors, ", ")
+	a.SourceURL = url
+	if a.SourceURL == "" {
+		a.SourceURL = jsonLDText(n["isBasedOn"])
+	}
+	a.License = jsonLDText(n["license"])
+	if a != (model.Attribution{}) {
+		r.Attribution = &a
+	}
+	if t, ok := parseTime(jsonLDText(n["datePublished"])); ok {
+		r.CreatedAt = t
+	}
+	if t, ok := parseTime(jsonLDText(n["dateModified"])); ok {
+		r.UpdatedAt = t
+	} else {
+		r.UpdatedAt = r.CreatedAt
+	}
+	return r
+}
+
+// jsonLDSteps flattens recipeInstructions into steps. Sites give the instructions as one
+// string with a step per line, a list of strings, a list of HowToStep objects, or HowToSection
+// objects grouping steps in their itemListElement.
+func jsonLDSteps(v interface{}) []string {
+	var steps []string
+	for _, item := range jsonLDList(v) {
+		switch typed := item.(type) {
+		case string:
+			for _, line := range strings.Split(typed, "\n") {
+				if text := jsonLDText(line); text != "" {
+					steps = append(steps, text)
+				}
+			}
+		case map[string]interface{}:
+			if list, ok := typed["itemListElement"]; ok {
+				steps = append(steps, jsonLDSteps(list)...)
+				continue
+			}
+			text := jsonLDText(typed["text"])
+			if text == "" {
+				text = jsonLDText(typed["name"])
+			}
+			if text != "" {
+				steps = append(steps, text)
+			}
+		}
+	}
+	return steps
+}
+
+// jsonLDNutrition converts a NutritionInformation object into the resolver's nutritional info.
+func jsonLDNutrition(v interface{}) map[string]interface{} {
+	obj, _ := v.(map[string]interface{})
+	values := make(map[string]string, len(obj))
+	for prop, value := range obj {
+		if name, ok := jsonLDNutrients[prop]; ok {
+			values[name] = jsonLDText(value)
+		}
+	}
+	return nutritionFromStrings(values)
+}
+
+// jsonLDImage returns the URL of the first image: a URL, an ImageObject or a list of either.
+func jsonLDImage(v interface{}) string {
+	for _, img := range jsonLDList(v) {
+		if obj, ok := img.(map[string]interface{}); ok {
+			img = obj["url"]
+		}
+		if url := jsonLDText(img); url != "" {
+			return url
+		}
+	}
+	return ""
+}
+
+// jsonLDName returns the name of a Person, Organization or HowToTool given either as an object
+// or as a plain string.
+func jsonLDName(v interface{}) string {
+	if obj, ok := v.(map[string]interface{}); ok {
+		return jsonLDText(obj["name"])
+	}
+	return jsonLDText(v)
+}
+
+// jsonLDList returns v as a list: JSON-LD allows a single value wherever a list is expected.
+func jsonLDList(v interface{}) []interface{} {
+	switch typed := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return typed
+	}
+	return []interface{}{v}
+}
+
+// jsonLDText returns a string or number value as text, with HTML entities decoded and
+// whitespace collapsed, since sites often copy their values from the page markup.
+func jsonLDText(v interface{}) string {
+	var s string
+	switch typed := v.(type) {
+	case string:
+		s = typed
+	case float64:
+		s = strconv.FormatFloat(typed, 'f', -1, 64)
+	default:
+		return ""
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
+
+// parseISODuration parses an ISO 8601 duration such as "PT1H30M" or "P0DT45M" into whole
+// minutes, rounding seconds up.
+func parseISODuration(s string) (int, bool) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, false
+	}
+	seconds := 0.0
+	inTime := false
+	num := ""
+	for _, c := range s[1:] {
+		switch {
+		case c == 'T':
+			inTime = true
+			continue
+		case c >= '0' && c <= '9' || c == '.':
+			num += string(c)
+			continue
+		}
+		n, err := strconv.ParseFloat(num, 64)
+		if err != nil {
+			return 0, false
+		}
+		num = ""
+		switch {
+		case c == 'D' && !inTime:
+			seconds += n * 86400
+		case c == 'H' && inTime:
+			seconds += n * 3600
+		case c == 'M' && inTime:
+			seconds += n * 60
+		case c == 'S' && inTime:
+			seconds += n
+		default:
+			return 0, false
+		}
+	}
+	if num != "" {
+		return 0, false
+	}
+	return int((seconds + 59) / 60), true
+}
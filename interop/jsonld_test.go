@@ -0,0 +1,102 @@
+package interop
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// TestImportJSONLD verifies that a schema.org Recipe embedded in a @graph, with sectioned
+// instructions, ISO 8601 times and NutritionInformation, is mapped onto model.Recipe.
+func TestImportJSONLD(t *testing.T) {
+	f, err := os.Open("testdata/jsonld_recipe.json")
+	if err != nil {
+		t.Fatalf("Failed to open fixture: %v", err)
+	}
+	defer f.Close()
+
+	recipes, err := ImportJSONLD(f)
+	if err != nil {
+		t.Fatalf("ImportJSONLD returned error: %v", err)
+	}
+	if len(recipes) != 1 {
+		t.Fatalf("Expected 1 recipe, got %d", len(recipes))
+	}
+	r := recipes[0]
+	if r.Title != "Lemon & Pea Risotto" {
+		t.Errorf("Expected the title with its entity decoded, got %q", r.Title)
+	}
+	if r.ID != stableID("jsonld", "https://example.com/recipes/lemon-risotto/#recipe") {
+		t.Errorf("Expected an ID derived from @id, got %s", r.ID)
+	}
+	if len(r.Ingredients) != 4 || r.Ingredients[0] != "300 g arborio rice" {
+		t.Errorf("Unexpected ingredients %v", r.Ingredients)
+	}
+	wantSteps := []string{"Toast the rice in the saucepan.", "Add the stock a ladle at a time, stirring.", "Stir in the peas and lemon zest."}
+	if strings.Join(r.Steps, "|") != strings.Join(wantSteps, "|") {
+		t.Errorf("Expected steps %v, got %v", wantSteps, r.Steps)
+	}
+	if r.TotalTime != 35 {
+		t.Errorf("Expected prep and cook time to add up to 35 minutes, got %d", r.TotalTime)
+	}
+	nutrition := r.NutritionalInfo.(map[string]interface{})
+	if nutrition["calories"] != 510 || nutrition["protein"] != 13.0 || nutrition["saturated_fat"] != 4.5 || nutrition["sodium"] != 620.0 {
+		t.Errorf("Unexpected nutrition %v", nutrition)
+	}
+	if _, ok := nutrition["servingsize"]; ok {
+		t.Errorf("Expected servingSize to be dropped, got %v", nutrition)
+	}
+	wantTags := []string{"risotto", "vegetarian", "weeknight", "main course", "cuisine:italian"}
+	if strings.Join(r.Tags, "|") != strings.Join(wantTags, "|") {
+		t.Errorf("Expected tags %v, got %v", wantTags, r.Tags)
+	}
+	if r.ImageURL != "https://example.com/img/risotto.jpg" {
+		t.Errorf("Unexpected image URL %q", r.ImageURL)
+	}
+	if len(r.Appliances) != 1 || r.Appliances[0] != "saucepan" {
+		t.Errorf("Expected appliance 'saucepan', got %v", r.Appliances)
+	}
+	want := model.Attribution{SourceURL: "https://example.com/recipes/lemon-risotto/", Author: "Ada Rossi", License: "CC-BY-4.0"}
+	if r.Attribution == nil || *r.Attribution != want {
+		t.Errorf("Expected attribution %+v, got %+v", want, r.Attribution)
+	}
+	if r.CreatedAt.Format("2006-01-02") != "2024-03-10" {
+		t.Errorf("Unexpected creation date %v", r.CreatedAt)
+	}
+	if p := r.Provenance; p == nil || p.Origin != model.OriginImported || p.Source != "jsonld" {
+		t.Errorf("Expected the recipe labeled as imported from JSON-LD, got %+v", p)
+	}
+}
+
+// TestImportJSONLDInstructionString verifies that instructions given as one string are split
+// into a step per line, and that a document without a Recipe is rejected.
+func TestImportJSONLDInstructionString(t *testing.T) {
+	doc := `[{"@type": "Recipe", "name": "Toast", "totalTime": "PT5M", "recipeInstructions": "Slice the bread.\n\nToast it.\n"}]`
+	recipes, err := ImportJSONLD(strings.NewReader(doc))
+	if err != nil {
+		t.Fatalf("ImportJSONLD returned error: %v", err)
+	}
+	if r := recipes[0]; len(r.Steps) != 2 || r.Steps[1] != "Toast it." || r.TotalTime != 5 {
+		t.Errorf("Unexpected steps %v or total time %d", r.Steps, r.TotalTime)
+	}
+
+	if _, err := ImportJSONLD(strings.NewReader(`{"@type": "WebPage", "name": "About us"}`)); err == nil {
+		t.Error("Expected an error for a document without a recipe")
+	}
+}
+
+// TestParseISODuration verifies ISO 8601 durations are converted into minutes.
+func TestParseISODuration(t *testing.T) {
+	for in, want := range map[string]int{"PT1H30M": 90, "P0DT45M": 45, "PT90S": 2, "P1D": 1440, "pt20m": 20} {
+		if got, ok := parseISODuration(in); !ok || got != want {
+			t.Errorf("parseISODuration(%q) = %d, %v; want %d", in, got, ok, want)
+		}
+	}
+	for _, in := range []string{"", "45 minutes", "PT", "PT1X", "PT5"} {
+		if _, ok := parseISODuration(in); ok {
+			t.Errorf("parseISODuration(%q) succeeded, want failure", in)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+// Package interop converts between the resolver's recipe model and the formats of popular
+// self-hosted recipe managers, so users can bring existing collections into the corpus and
+// take generated recipes back out.
+package interop
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// mealieRecipe is the subset of Mealie's recipe schema that maps onto model.Recipe.
+// Mealie has used both plain strings and structured objects for ingredients over time;
+// mealieIngredient accepts either.
+type mealieRecipe struct {
+	ID                 string              `json:"id,omitempty"`
+	Name               string              `json:"name"`
+	Slug               string              `json:"slug,omitempty"`
+	Description        string              `json:"description,omitempty"`
+	RecipeIngredient   []mealieIngredient  `json:"recipeIngredient"`
+	RecipeInstructions []mealieInstruction `json:"recipeInstructions"`
+	Nutrition          map[string]string   `json:"nutrition,omitempty"`
+	Tools              []mealieTool        `json:"tools,omitempty"`
+	Notes              []mealieNote        `json:"notes,omitempty"`
+	OrgURL             string              `json:"orgURL,omitempty"`
+	DateAdded          string              `json:"dateAdded,omitempty"`
+	DateUpdated        string              `json:"dateUpdated,omitempty"`
+}
+
+type mealieIngredient struct {
+	Note         string `json:"note,omitempty"`
+	Display      string `json:"display,omitempty"`
+	OriginalText string `json:"originalText,omitempty"`
+}
+
+// UnmarshalJSON accepts both the legacy string form and the structured object form.
+func (i *mealieIngredient) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		i.Note = s
+		return nil
+	}
+	type plain mealieIngredient
+	return json.Unmarshal(data, (*plain)(i))
+}
+
+// text returns the most complete human-readable form of the ingredient.
+func (i mealieIngredient) text() string {
+	for _, s := range []string{i.Display, i.OriginalText, i.Note} {
+		if s = strings.TrimSpace(s); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+type mealieInstruction struct {
+	Title string `json:"title,omitempty"`
+	Text  string `json:"text"`
+}
+
+type mealieTool struct {
+	Name string `json:"name"`
+}
+
+type mealieNote struct {
+	Title string `json:"title"`
+	Text  string `json:"text"`
+}
+
+// Mealie note titles used to carry fields Mealie has no dedicated field for: the allergy
+// disclaimer and the author and license of the attribution.
+const (
+	allergyNoteTitle = "Allergy disclaimer"
+	authorNoteTitle  = "Author"
+	licenseNoteTitle = "License"
+)
+
+// ImportMealie reads Mealie recipe JSON, either a single recipe object or an array of them.
+func ImportMealie(r io.Reader) ([]model.Recipe, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+
+	var mealie []mealieRecipe
+	if len(data) > 0 && data[0] == '[' {
+		err = json.Unmarshal(data, &mealie)
+	} else {
+		var single mealieRecipe
+		err = json.Unmarshal(data, &single)
+		mealie = []mealieRecipe{single}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing Mealie JSON: %w", err)
+	}
+
+	recipes := make([]model.Recipe, 0, len(mealie))
+	for i, m := range mealie {
+		if strings.TrimSpace(m.Name) == "" {
+			return nil, fmt.Errorf("Mealie recipe %d has no name", i)
+		}
+		recipes = append(recipes, fromMealie(m))
+	}
+	return recipes, nil
+}
+
+func fromMealie(m mealieRecipe) model.Recipe {
+	r := model.NewRecipe(m.Name, []string{}, []string{}, nutritionFromStrings(m.Nutrition), "", []string{})
+	r.Provenance = &model.Provenance{Origin: model.OriginImported, Source: "mealie"}
+	if m.ID != "" {
+		r.ID = m.ID
+	}
+	for _, ing := range m.RecipeIngredient {
+		if text := ing.text(); text != "" {
+			r.Ingredients = append(r.Ingredients, text)
+		}
+	}
+	for _, step := range m.RecipeInstructions {
+		if text := strings.TrimSpace(step.Text); text != "" {
+			r.Steps = append(r.Steps, text)
+		}
+	}
+	for _, tool := range m.Tools {
+		if tool.Name != "" {
+			r.Appliances = append(r.Appliances, strings.ToLower(tool.Name))
+		}
+	}
+	var a model.Attribution
+	for _, note := range m.Notes {
+		switch {
+		case strings.EqualFold(note.Title, allergyNoteTitle):
+			r.AllergyDisclaimer = note.Text
+		case strings.EqualFold(note.Title, authorNoteTitle):
+			a.Author = strings.TrimSpace(note.Text)
+		case strings.EqualFold(note.Title, licenseNoteTitle):
+			a.License = strings.TrimSpace(note.Text)
+		}
+	}
+	a.SourceURL = strings.TrimSpace(m.OrgURL)
+	if a != (model.Attribution{}) {
+		r.Attribution = &a
+	}
+	if t, ok := parseTime(m.DateAdded); ok {
+		r.CreatedAt = t
+	}
+	if t, ok := parseTime(m.DateUpdated); ok {
+		r.UpdatedAt = t
+	} else {
+		r.UpdatedAt = r.CreatedAt
+	}
+	return r
+}
+
+// ExportMealie writes recipes as a JSON array of Mealie recipe objects.
+func ExportMealie(w io.Writer, recipes []model.Recipe) error {
+	mealie := make([]mealieRecipe, 0, len(recipes))
+	for _, r := range recipes {
+		m := mealieRecipe{
+			ID:                 r.ID,
+			Name:               r.Title,
+			Slug:               slugify(r.Title),
+			RecipeIngredient:   make([]mealieIngredient, 0, len(r.Ingredients)),
+			RecipeInstructions: make([]mealieInstruction, 0, len(r.Steps)),
+			Nutrition:          nutritionToStrings(r.NutritionalInfo),
+			DateAdded:          r.CreatedAt.Format("2006-01-02"),
+			DateUpdated:        r.UpdatedAt.Format(time.RFC3339),
+		}
+		for _, ing := range r.Ingredients {
+			m.RecipeIngredient = append(m.RecipeIngredient, mealieIngredient{Note: ing, Display: ing})
+		}
+		for _, step := range r.Steps {
+			m.RecipeInstructions = append(m.RecipeInstructions, mealieInstruction{Text: step})
+		}
+		for _, a := range r.Appliances {
+			m.Tools = append(m.Tools, mealieTool{Name: a})
+		}
+		if r.AllergyDisclaimer != "" {
+			m.Notes = append(m.Notes, mealieNote{Title: allergyNoteTitle, Text: r.AllergyDisclaimer})
+		}
+		if a := r.Attribution; a != nil {
+			m.OrgURL = a.SourceURL
+			if a.Author != "" {
+				m.Notes = append(m.Notes, mealieNote{Title: authorNoteTitle, Text: a.Author})
+			}
+			if a.License != "" {
+				m.Notes = append(m.Notes, mealieNote{Title: licenseNoteTitle, Text: a.License})
+			}
+		}
+		mealie = append(mealie, m)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(mealie)
+}
+
+// slugify lower-cases title and joins its words with hyphens, as Mealie does for slugs.
+func slugify(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// parseTime accepts the timestamp layouts used by Mealie and Paprika.
+func parseTime(s string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// stableID derives a deterministic recipe ID from an external identifier, so importing the same
+// collection twice yields the same IDs.
+func stableID(source, externalID string) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(source+":"+externalID)).String()
+}
+
+// Attribute fills in the attribution imported recipes lack from defaults, such as a license
+// granted for a whole collection, and then checks that every recipe may be served (see
+// model.Recipe.CheckAttribution). Fields a recipe already has are kept.
+func Attribute(recipes []model.Recipe, defaults model.Attribution) error {
+	for i, r := range recipes {
+		if r.Provenance != nil && r.Provenance.Origin == model.OriginImported && defaults != (model.Attribution{}) {
+			a := defaults
+			if r.Attribution != nil {
+				a = *r.Attribution
+				if a.SourceURL == "" {
+					a.SourceURL = defaults.SourceURL
+				}
+				if a.Author == "" {
+					a.Author = defaults.Author
+				}
+				if a.License == "" {
+					a.License = defaults.License
+				}
+			}
+			recipes[i].Attribution = &a
+		}
+		if err := recipes[i].CheckAttribution(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package interop
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// TestImportMealie verifies that a Mealie export, including legacy string ingredients,
+// structured ingredients and string nutrition values, is mapped onto model.Recipe.
+func TestImportMealie(t *testing.T) {
+	f, err := os.Open("testdata/mealie_recipe.json")
+	if err != nil {
+		t.Fatalf("Failed to open fixture: %v", err)
+	}
+	defer f.Close()
+
+	recipes, err := ImportMealie(f)
+	if err != nil {
+		t.Fatalf("ImportMealie returned error: %v", err)
+	}
+	if len(recipes) != 1 {
+		t.Fatalf("Expected 1 recipe, got %d", len(recipes))
+	}
+	r := recipes[0]
+	if r.ID != "5c1e4b1e-8d9a-4b44-9a3e-0c8a1d6f2b10" || r.Title != "Weeknight Chickpea Curry" {
+		t.Errorf("Unexpected ID/title: %s / %s", r.ID, r.Title)
+	}
+	wantIngredients := []string{"2 cans chickpeas", "1 can coconut milk", "1 tbsp curry powder"}
+	if strings.Join(r.Ingredients, "|") != strings.Join(wantIngredients, "|") {
+		t.Errorf("Expected ingredients %v, got %v", wantIngredients, r.Ingredients)
+	}
+	if len(r.Steps) != 2 {
+		t.Errorf("Expected 2 steps, got %d", len(r.Steps))
+	}
+	nutrition := r.NutritionalInfo.(map[string]interface{})
+	if nutrition["calories"] != 420 || nutrition["protein"] != 14.0 {
+		t.Errorf("Expected calories 420 and protein 14, got %v", nutrition)
+	}
+	if _, ok := nutrition["sodium"]; ok {
+		t.Errorf("Expected empty nutrition values to be dropped, got %v", nutrition)
+	}
+	if len(r.Appliances) != 1 || r.Appliances[0] != "dutch oven" {
+		t.Errorf("Expected appliance 'dutch oven', got %v", r.Appliances)
+	}
+	if r.AllergyDisclaimer != "None" {
+		t.Errorf("Expected allergy disclaimer 'None', got %q", r.AllergyDisclaimer)
+	}
+	if r.CreatedAt.Format("2006-01-02") != "2024-11-02" || r.UpdatedAt.Format("2006-01-02") != "2024-11-05" {
+		t.Errorf("Unexpected timestamps: %v / %v", r.CreatedAt, r.UpdatedAt)
+	}
+	if p := r.Provenance; p == nil || p.Origin != model.OriginImported || p.Source != "mealie" {
+		t.Errorf("Expected the recipe labeled as imported from Mealie, got %+v", p)
+	}
+}
+
+// TestMealieRoundTrip verifies that exported recipes import back unchanged.
+func TestMealieRoundTrip(t *testing.T) {
+	original := model.NewRecipe("Crème Brûlée", []string{"cream", "egg yolks", "sugar"}, []string{"Bake", "Torch"},
+		map[string]int{"calories": 390}, "Contains dairy, egg", []string{"oven"})
+	original.Attribution = &model.Attribution{SourceURL: "https://example.com/creme-brulee", Author: "Jane Doe", License: "CC-BY-4.0"}
+
+	var buf bytes.Buffer
+	if err := ExportMealie(&buf, []model.Recipe{original}); err != nil {
+		t.Fatalf("ExportMealie returned error: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"slug": "crème-brûlée"`) {
+		t.Errorf("Expected a slug in the export, got %s", buf.String())
+	}
+	recipes, err := ImportMealie(&buf)
+	if err != nil {
+		t.Fatalf("ImportMealie returned error: %v", err)
+	}
+	r := recipes[0]
+	if r.ID != original.ID || r.Title != original.Title || len(r.Ingredients) != 3 || len(r.Steps) != 2 {
+		t.Errorf("Round trip changed the recipe: %+v", r)
+	}
+	if r.AllergyDisclaimer != original.AllergyDisclaimer || r.Appliances[0] != "oven" {
+		t.Errorf("Round trip lost the disclaimer or appliances: %+v", r)
+	}
+	if r.NutritionalInfo.(map[string]interface{})["calories"] != 390 {
+		t.Errorf("Round trip lost calories: %v", r.NutritionalInfo)
+	}
+	if r.Attribution == nil || *r.Attribution != *original.Attribution {
+		t.Errorf("Round trip lost the attribution: %+v", r.Attribution)
+	}
+}
+
+// TestAttribute verifies that collection-wide credit fills the gaps of imported recipes and that
+// imported recipes without a license are refused.
+func TestAttribute(t *testing.T) {
+	imported := func(a *model.Attribution) model.Recipe {
+		r := model.NewRecipe("Stew", nil, nil, nil, "", nil)
+		r.Provenance, r.Attribution = &model.Provenance{Origin: model.OriginImported, Source: "mealie"}, a
+		return r
+	}
+	recipes := []model.Recipe{imported(nil), imported(&model.Attribution{Author: "Jane Doe"}), model.NewRecipe("Soup", nil, nil, nil, "", nil)}
+	if err := Attribute(recipes, model.Attribution{}); !errors.Is(err, model.ErrMissingAttribution) {
+		t.Fatalf("Expected ErrMissingAttribution without credit, got %v", err)
+	}
+	if err := Attribute(recipes, model.Attribution{Author: "Family Cookbook", License: "CC-BY-4.0"}); err != nil {
+		t.Fatal(err)
+	}
+	if a := recipes[0].Attribution; a == nil || a.Author != "Family Cookbook" || a.License != "CC-BY-4.0" {
+		t.Errorf("Expected the default credit, got %+v", a)
+	}
+	if a := recipes[1].Attribution; a.Author != "Jane Doe" || a.License != "CC-BY-4.0" {
+		t.Errorf("Expected the recipe's own author kept, got %+v", a)
+	}
+	if recipes[2].Attribution != nil {
+		t.Errorf("Expected a curated recipe left alone, got %+v", recipes[2].Attribution)
+	}
+}
+
+// TestSlugify verifies Mealie-style slugs.
+func TestSlugify(t *testing.T) {
+	if got := slugify("General Tso's  Chicken!"); got != "general-tso-s-chicken" {
+		t.Errorf("Expected 'general-tso-s-chicken', got %q", got)
+	}
+}
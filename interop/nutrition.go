@@ -0,0 +1,72 @@
+package interop
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// nutritionFromStrings converts free-text nutrition values ("320 kcal", "12 g") into the
+// resolver's nutritional info map. Values with a leading number are stored as numbers in the
+// default format (see model.NutritionFormat), so that {"calories": "320 kcal"} becomes
+// {"calories": 320}; anything else is kept verbatim.
+func nutritionFromStrings(in map[string]string) map[string]interface{} {
+	info := make(map[string]interface{}, len(in))
+	for k, v := range in {
+		info[strings.TrimSuffix(strings.ToLower(k), "content")] = v
+	}
+	return model.DefaultNutritionFormat.Normalize(info).(map[string]interface{})
+}
+
+// nutritionToStrings flattens the resolver's nutritional info into string values.
+func nutritionToStrings(info interface{}) map[string]string {
+	m, ok := info.(map[string]interface{})
+	if !ok {
+		// Recipes built in Go code may carry a map[string]int; go through fmt for those.
+		switch typed := info.(type) {
+		case map[string]int:
+			m = make(map[string]interface{}, len(typed))
+			for k, v := range typed {
+				m[k] = v
+			}
+		default:
+			return nil
+		}
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = fmt.Sprint(v)
+	}
+	return out
+}
+
+// nutritionSummary renders nutritional info as "calories: 320, protein: 12" with sorted keys.
+func nutritionSummary(info interface{}) string {
+	m := nutritionToStrings(info)
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+": "+m[k])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseNutritionSummary parses the "key: value" pairs written by nutritionSummary, separated by
+// commas or newlines as Paprika users commonly type them.
+func parseNutritionSummary(s string) map[string]interface{} {
+	pairs := make(map[string]string)
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' }) {
+		k, v, ok := strings.Cut(part, ":")
+		if !ok {
+			continue
+		}
+		pairs[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return nutritionFromStrings(pairs)
+}
@@ -0,0 +1,188 @@
+package interop
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// paprikaRecipe is the subset of Paprika's recipe schema that maps onto model.Recipe.
+// Ingredients and directions are newline-separated text.
+type paprikaRecipe struct {
+	UID             string   `json:"uid"`
+	Name            string   `json:"name"`
+	Ingredients     string   `json:"ingredients"`
+	Directions      string   `json:"directions"`
+	NutritionalInfo string   `json:"nutritional_info"`
+	Notes           string   `json:"notes"`
+	Created         string   `json:"created"`
+	Categories      []string `json:"categories"`
+	Hash            string   `json:"hash"`
+	Source          string   `json:"source"`
+	SourceURL       string   `json:"source_url"`
+}
+
+// Prefixes of the lines inside Paprika's free-text notes that carry the allergy disclaimer and
+// the license of the attribution.
+const (
+	paprikaAllergyPrefix = "Allergy disclaimer: "
+	paprikaLicensePrefix = "License: "
+)
+
+// paprikaSource is the source name of exported recipes without an author.
+const paprikaSource = "recipe-resolver"
+
+// ImportPaprika reads a .paprikarecipes archive: a zip file whose entries are gzip-compressed
+// JSON recipes (.paprikarecipe).
+func ImportPaprika(data []byte) ([]model.Recipe, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("opening Paprika archive: %w", err)
+	}
+
+	var recipes []model.Recipe
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || path.Ext(f.Name) != ".paprikarecipe" {
+			continue
+		}
+		p, err := readPaprikaEntry(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", f.Name, err)
+		}
+		recipes = append(recipes, fromPaprika(p))
+	}
+	return recipes, nil
+}
+
+func readPaprikaEntry(f *zip.File) (paprikaRecipe, error) {
+	var p paprikaRecipe
+	rc, err := f.Open()
+	if err != nil {
+		return p, err
+	}
+	defer rc.Close()
+	gz, err := gzip.NewReader(rc)
+	if err != nil {
+		return p, err
+	}
+	defer gz.Close()
+	if err := json.NewDecoder(gz).Decode(&p); err != nil {
+		return p, err
+	}
+	if strings.TrimSpace(p.Name) == "" {
+		return p, fmt.Errorf("recipe has no name")
+	}
+	return p, nil
+}
+
+func fromPaprika(p paprikaRecipe) model.Recipe {
+	r := model.NewRecipe(p.Name, splitLines(p.Ingredients), splitLines(p.Directions), parseNutritionSummary(p.NutritionalInfo), "", []string{})
+	r.Provenance = &model.Provenance{Origin: model.OriginImported, Source: "paprika"}
+	if id, err := uuid.Parse(p.UID); err == nil {
+		// Recipes exported by the resolver keep their ID across a round trip.
+		r.ID = id.String()
+	} else if p.UID != "" {
+		r.ID = stableID("paprika", p.UID)
+	}
+	a := model.Attribution{SourceURL: strings.TrimSpace(p.SourceURL)}
+	if source := strings.TrimSpace(p.Source); source != paprikaSource {
+		a.Author = source
+	}
+	for _, line := range strings.Split(p.Notes, "\n") {
+		switch {
+		case strings.HasPrefix(line, paprikaAllergyPrefix):
+			r.AllergyDisclaimer = strings.TrimSpace(strings.TrimPrefix(line, paprikaAllergyPrefix))
+		case strings.HasPrefix(line, paprikaLicensePrefix):
+			a.License = strings.TrimSpace(strings.TrimPrefix(line, paprikaLicensePrefix))
+		}
+	}
+	if a != (model.Attribution{}) {
+		r.Attribution = &a
+	}
+	if t, ok := parseTime(p.Created); ok {
+		r.CreatedAt, r.UpdatedAt = t, t
+	}
+	return r
+}
+
+// ExportPaprika writes recipes as a .paprikarecipes archive.
+func ExportPaprika(w io.Writer, recipes []model.Recipe) error {
+	zw := zip.NewWriter(w)
+	used := make(map[string]int)
+	for _, r := range recipes {
+		p := paprikaRecipe{
+			UID:             strings.ToUpper(r.ID),
+			Name:            r.Title,
+			Ingredients:     strings.Join(r.Ingredients, "\n"),
+			Directions:      strings.Join(r.Steps, "\n"),
+			NutritionalInfo: nutritionSummary(r.NutritionalInfo),
+			Created:         r.CreatedAt.Format("2006-01-02 15:04:05"),
+			Categories:      []string{},
+			Source:          paprikaSource,
+		}
+		var notes []string
+		if r.AllergyDisclaimer != "" {
+			notes = append(notes, paprikaAllergyPrefix+r.AllergyDisclaimer)
+		}
+		if a := r.Attribution; a != nil {
+			p.SourceURL = a.SourceURL
+			if a.Author != "" {
+				p.Source = a.Author
+			}
+			if a.License != "" {
+				notes = append(notes, paprikaLicensePrefix+a.License)
+			}
+		}
+		p.Notes = strings.Join(notes, "\n")
+		body, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(body)
+		p.Hash = strings.ToUpper(hex.EncodeToString(sum[:]))
+		if body, err = json.Marshal(p); err != nil {
+			return err
+		}
+
+		// Entry names must be unique within the archive.
+		name := r.Title
+		if n := used[name]; n > 0 {
+			name = fmt.Sprintf("%s (%d)", name, n+1)
+		}
+		used[r.Title]++
+
+		fw, err := zw.Create(name + ".paprikarecipe")
+		if err != nil {
+			return err
+		}
+		gz := gzip.NewWriter(fw)
+		if _, err := gz.Write(body); err != nil {
+			return err
+		}
+		if err := gz.Close(); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// splitLines splits newline-separated text into trimmed, non-empty lines.
+func splitLines(s string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,87 @@
+package interop
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// TestImportPaprika verifies parsing of a hand-built .paprikarecipes archive.
+func TestImportPaprika(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	fw, _ := zw.Create("Buttermilk Biscuits.paprikarecipe")
+	gz := gzip.NewWriter(fw)
+	json.NewEncoder(gz).Encode(map[string]interface{}{
+		"uid":              "0F6C2B0E-PAPRIKA-LOCAL-UID",
+		"name":             "Buttermilk Biscuits",
+		"ingredients":      "2 cups flour\n\n1 cup buttermilk\n6 tbsp cold butter\n",
+		"directions":       "Cut butter into flour.\nStir in buttermilk.\nBake at 450°F for 12 minutes.",
+		"nutritional_info": "Calories: 210\nFat: 11 g",
+		"notes":            "Family favorite.\nAllergy disclaimer: Contains gluten, dairy",
+		"created":          "2023-05-14 09:30:00",
+		"photo_data":       "iVBORw0KGgo=",
+	})
+	gz.Close()
+	zw.Create("images/") // directories are ignored
+	zw.Close()
+
+	recipes, err := ImportPaprika(buf.Bytes())
+	if err != nil {
+		t.Fatalf("ImportPaprika returned error: %v", err)
+	}
+	if len(recipes) != 1 {
+		t.Fatalf("Expected 1 recipe, got %d", len(recipes))
+	}
+	r := recipes[0]
+	if r.Title != "Buttermilk Biscuits" || len(r.Ingredients) != 3 || len(r.Steps) != 3 {
+		t.Errorf("Unexpected recipe: %+v", r)
+	}
+	if r.ID != stableID("paprika", "0F6C2B0E-PAPRIKA-LOCAL-UID") {
+		t.Errorf("Expected a stable ID derived from the Paprika UID, got %s", r.ID)
+	}
+	if r.AllergyDisclaimer != "Contains gluten, dairy" {
+		t.Errorf("Expected allergy disclaimer from notes, got %q", r.AllergyDisclaimer)
+	}
+	if n := r.NutritionalInfo.(map[string]interface{}); n["calories"] != 210 || n["fat"] != 11.0 {
+		t.Errorf("Unexpected nutrition: %v", n)
+	}
+	if r.CreatedAt.Format("2006-01-02 15:04") != "2023-05-14 09:30" {
+		t.Errorf("Unexpected created_at: %v", r.CreatedAt)
+	}
+}
+
+// TestPaprikaRoundTrip verifies that exported archives import back, including duplicate titles.
+func TestPaprikaRoundTrip(t *testing.T) {
+	a := model.NewRecipe("Pancakes", []string{"flour", "milk"}, []string{"Mix", "Cook"}, map[string]int{"calories": 350}, "Contains gluten", []string{"griddle"})
+	b := model.NewRecipe("Pancakes", []string{"oat flour", "oat milk"}, []string{"Mix", "Cook"}, nil, "", nil)
+	b.Attribution = &model.Attribution{SourceURL: "https://example.com/oat-pancakes", Author: "Oat Kitchen", License: "CC0-1.0"}
+
+	var buf bytes.Buffer
+	if err := ExportPaprika(&buf, []model.Recipe{a, b}); err != nil {
+		t.Fatalf("ExportPaprika returned error: %v", err)
+	}
+	recipes, err := ImportPaprika(buf.Bytes())
+	if err != nil {
+		t.Fatalf("ImportPaprika returned error: %v", err)
+	}
+	if len(recipes) != 2 {
+		t.Fatalf("Expected 2 recipes, got %d", len(recipes))
+	}
+	if recipes[0].ID != a.ID || recipes[1].ID != b.ID {
+		t.Errorf("Expected IDs to survive the round trip, got %s / %s", recipes[0].ID, recipes[1].ID)
+	}
+	if recipes[0].Ingredients[0] != "flour" || recipes[1].Ingredients[0] != "oat flour" {
+		t.Errorf("Unexpected ingredients after round trip: %v / %v", recipes[0].Ingredients, recipes[1].Ingredients)
+	}
+	if recipes[0].AllergyDisclaimer != "Contains gluten" {
+		t.Errorf("Expected allergy disclaimer to survive the round trip, got %q", recipes[0].AllergyDisclaimer)
+	}
+	if recipes[0].Attribution != nil || recipes[1].Attribution == nil || *recipes[1].Attribution != *b.Attribution {
+		t.Errorf("Expected the attribution to survive the round trip, got %+v / %+v", recipes[0].Attribution, recipes[1].Attribution)
+	}
+}
@@ -0,0 +1,95 @@
+// Package lease grants named, expiring leases shared by the service's replicas, so that work
+// such as a scheduled job runs on one replica only.
+package lease
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Errors returned by leases.
+var (
+	// ErrHeld is returned by Acquire when another holder has the lease.
+	ErrHeld = errors.New("lease is held elsewhere")
+	// ErrLost is returned by Extend when the lease expired and may have been taken by another
+	// holder.
+	ErrLost = errors.New("lease was lost")
+)
+
+// Locker grants leases.
+type Locker interface {
+	// Acquire takes the lease name for ttl, or returns ErrHeld if another holder has it.
+	Acquire(ctx context.Context, name string, ttl time.Duration) (Lease, error)
+}
+
+// Lease is a lease taken from a Locker.
+type Lease interface {
+	// Extend keeps the lease for ttl from now, or returns ErrLost if it has expired.
+	Extend(ctx context.Context, ttl time.Duration) error
+	// Release gives the lease up before it expires.
+	Release(ctx context.Context) error
+}
+
+// newToken returns a random token identifying a holder.
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Memory is a Locker within a single process, for deployments with one replica and for tests.
+// It is safe for concurrent use.
+type Memory struct {
+	mu     sync.Mutex
+	leases map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	token   string
+	expires time.Time
+}
+
+// NewMemory returns an empty Memory locker.
+func NewMemory() *Memory {
+	return &Memory{leases: make(map[string]memoryEntry)}
+}
+
+// Acquire implements Locker.
+func (m *Memory) Acquire(ctx context.Context, name string, ttl time.Duration) (Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.leases[name]; ok && time.Now().Before(e.expires) {
+		return nil, ErrHeld
+	}
+	token := newToken()
+	m.leases[name] = memoryEntry{token: token, expires: time.Now().Add(ttl)}
+	return &memoryLease{m: m, name: name, token: token}, nil
+}
+
+type memoryLease struct {
+	m           *Memory
+	name, token string
+}
+
+func (l *memoryLease) Extend(ctx context.Context, ttl time.Duration) error {
+	l.m.mu.Lock()
+	defer l.m.mu.Unlock()
+	if e, ok := l.m.leases[l.name]; !ok || e.token != l.token || !time.Now().Before(e.expires) {
+		return ErrLost
+	}
+	l.m.leases[l.name] = memoryEntry{token: l.token, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (l *memoryLease) Release(ctx context.Context) error {
+	l.m.mu.Lock()
+	defer l.m.mu.Unlock()
+	if e, ok := l.m.leases[l.name]; ok && e.token == l.token {
+		delete(l.m.leases, l.name)
+	}
+	return nil
+}
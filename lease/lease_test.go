@@ -0,0 +1,75 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/redis/redistest"
+)
+
+// testLocker checks the lease semantics shared by every Locker.
+func testLocker(t *testing.T, l Locker) {
+	ctx := context.Background()
+	first, err := l.Acquire(ctx, "job", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Acquire(ctx, "job", time.Minute); !errors.Is(err, ErrHeld) {
+		t.Errorf("Expected ErrHeld for a held lease, got %v", err)
+	}
+	if _, err := l.Acquire(ctx, "other", time.Minute); err != nil {
+		t.Errorf("Expected another name to be free, got %v", err)
+	}
+	if err := first.Extend(ctx, time.Minute); err != nil {
+		t.Errorf("Expected the holder to extend its lease, got %v", err)
+	}
+	if err := first.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	second, err := l.Acquire(ctx, "job", 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Expected a released lease to be free, got %v", err)
+	}
+	if err := first.Extend(ctx, time.Minute); !errors.Is(err, ErrLost) {
+		t.Errorf("Expected ErrLost extending a lease taken over, got %v", err)
+	}
+	first.Release(ctx) // must not release the new holder's lease
+	if _, err := l.Acquire(ctx, "job", time.Minute); !errors.Is(err, ErrHeld) {
+		t.Errorf("Expected the new holder to keep its lease, got %v", err)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if _, err := l.Acquire(ctx, "job", time.Minute); err != nil {
+		t.Errorf("Expected an expired lease to be free, got %v", err)
+	}
+	if err := second.Extend(ctx, time.Minute); !errors.Is(err, ErrLost) {
+		t.Errorf("Expected ErrLost extending an expired lease, got %v", err)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testLocker(t, NewMemory())
+}
+
+func TestRedis(t *testing.T) {
+	srv := redistest.NewServer(t, "secret")
+	l, err := ParseRedisURL(srv.URL() + "/3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Client.DB != 3 || l.Client.Password != "secret" {
+		t.Errorf("Unexpected settings parsed from the URL: %+v", l.Client)
+	}
+	testLocker(t, l)
+
+	l.Client.Password = "wrong"
+	if _, err := l.Acquire(context.Background(), "job", time.Minute); err == nil || errors.Is(err, ErrHeld) {
+		t.Errorf("Expected an authentication error, got %v", err)
+	}
+	for _, bad := range []string{"http://cache:6379", "redis://", "redis://cache/db"} {
+		if _, err := ParseRedisURL(bad); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
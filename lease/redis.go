@@ -0,0 +1,70 @@
+package lease
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/redis"
+)
+
+// Redis is a Locker backed by a Redis server, shared by every replica that points at it. A lease
+// is a key set with NX and an expiry, holding a random token so that only its holder extends or
+// deletes it.
+type Redis struct {
+	Client *redis.Client
+	// Prefix is prepended to lease names to form keys.
+	Prefix string
+}
+
+// DefaultRedisPrefix is the key prefix of leases created by ParseRedisURL.
+const DefaultRedisPrefix = "recipe-resolver:lease:"
+
+// ParseRedisURL returns a Redis locker for a URL such as "redis://:secret@cache:6379/2".
+func ParseRedisURL(rawURL string) (*Redis, error) {
+	c, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return &Redis{Client: c, Prefix: DefaultRedisPrefix}, nil
+}
+
+// Scripts that act on a lease key only while it still holds the caller's token.
+const (
+	extendScript  = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+	releaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+)
+
+// Acquire implements Locker.
+func (r *Redis) Acquire(ctx context.Context, name string, ttl time.Duration) (Lease, error) {
+	l := &redisLease{r: r, key: r.Prefix + name, token: newToken()}
+	reply, err := r.Client.Do(ctx, "SET", l.key, l.token, "NX", "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrHeld
+	}
+	return l, nil
+}
+
+type redisLease struct {
+	r          *Redis
+	key, token string
+}
+
+func (l *redisLease) Extend(ctx context.Context, ttl time.Duration) error {
+	reply, err := l.r.Client.Do(ctx, "EVAL", extendScript, "1", l.key, l.token, strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return err
+	}
+	if reply != int64(1) {
+		return ErrLost
+	}
+	return nil
+}
+
+func (l *redisLease) Release(ctx context.Context) error {
+	_, err := l.r.Client.Do(ctx, "EVAL", releaseScript, "1", l.key, l.token)
+	return err
+}
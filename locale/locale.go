@@ -0,0 +1,162 @@
+// Package locale selects how numbers and measurements are presented to a caller, based on an
+// explicit locale option or the Accept-Language header.
+package locale
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/units"
+)
+
+// Locale describes how responses are formatted for a caller.
+type Locale struct {
+	// Tag is the BCP 47 language tag the locale was parsed from, e.g. "de-DE".
+	Tag string
+	// Decimal is the decimal separator, '.' or ','.
+	Decimal byte
+	System  units.System
+}
+
+// Default is used when a caller expresses no usable preference. It matches the service's
+// historical output.
+var Default = Locale{Tag: "en-US", Decimal: '.', System: units.USCustomary}
+
+// commaLanguages write decimals with a comma.
+var commaLanguages = map[string]bool{
+	"bg": true, "cs": true, "da": true, "de": true, "el": true, "es": true, "fi": true, "fr": true,
+	"hr": true, "hu": true, "id": true, "it": true, "nb": true, "nl": true, "nn": true, "no": true,
+	"pl": true, "pt": true, "ro": true, "ru": true, "sk": true, "sl": true, "sr": true, "sv": true,
+	"tr": true, "uk": true, "vi": true,
+}
+
+// customaryRegions use US customary units; everywhere else cooks in metric.
+var customaryRegions = map[string]bool{"US": true, "LR": true, "MM": true}
+
+// Parse parses a language tag such as "de-DE", "en_GB" or "fr". A bare "en" is taken to
+// mean American English. It reports false for tags it cannot interpret.
+func Parse(tag string) (Locale, bool) {
+	parts := strings.FieldsFunc(strings.TrimSpace(tag), func(r rune) bool { return r == '-' || r == '_' })
+	if len(parts) == 0 || len(parts[0]) < 2 || len(parts[0]) > 3 {
+		return Locale{}, false
+	}
+	for _, p := range parts {
+		for _, c := range p {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+				return Locale{}, false
+			}
+		}
+	}
+	lang := strings.ToLower(parts[0])
+	if strings.ContainsAny(lang, "0123456789") {
+		return Locale{}, false
+	}
+	region := ""
+	for _, p := range parts[1:] {
+		if len(p) == 2 {
+			region = strings.ToUpper(p)
+			break
+		}
+	}
+	if lang == "en" && region == "" {
+		region = "US"
+	}
+
+	l := Locale{Tag: lang, Decimal: '.', System: units.Metric}
+	if region != "" {
+		l.Tag += "-" + region
+	}
+	if commaLanguages[lang] {
+		l.Decimal = ','
+	}
+	if customaryRegions[region] {
+		l.System = units.USCustomary
+	}
+	return l, true
+}
+
+// FromAcceptLanguage returns the caller's most preferred locale from an Accept-Language header
+// value, or Default if none can be interpreted.
+func FromAcceptLanguage(header string) Locale {
+	type choice struct {
+		tag string
+		q   float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if tag != "" && tag != "*" && q > 0 {
+			choices = append(choices, choice{tag, q})
+		}
+	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+	for _, c := range choices {
+		if l, ok := Parse(c.tag); ok {
+			return l
+		}
+	}
+	return Default
+}
+
+// FormatNumber formats f with at most prec decimals, dropping trailing zeros, using the
+// locale's decimal separator.
+func (l Locale) FormatNumber(f float64, prec int) string {
+	s := strconv.FormatFloat(f, 'f', prec, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if l.Decimal != '.' {
+		s = strings.Replace(s, ".", string(l.Decimal), 1)
+	}
+	return s
+}
+
+// FormatTemperature formats a temperature given in degrees Celsius in the locale's system,
+// rounded to a whole degree, e.g. "200 °C" or "392 °F".
+func (l Locale) FormatTemperature(celsius float64) string {
+	if l.System == units.USCustomary {
+		return l.FormatNumber(units.CelsiusToFahrenheit(celsius), 0) + " °F"
+	}
+	return l.FormatNumber(celsius, 0) + " °C"
+}
+
+// decimalPattern matches decimal numbers written with a point, e.g. "1.5" in "1.5 cups".
+var decimalPattern = regexp.MustCompile(`\b(\d+)\.(\d+)\b`)
+
+// Text rewrites decimal numbers in free text with the locale's separator.
+func (l Locale) Text(s string) string {
+	if l.Decimal == '.' {
+		return s
+	}
+	return decimalPattern.ReplaceAllString(s, "${1}"+string(l.Decimal)+"${2}")
+}
+
+// Recipe returns a copy of r with the free text of its ingredients and steps formatted for
+// the locale. Temperatures in steps are annotated in the locale's system of measurement.
+func (l Locale) Recipe(r model.Recipe) model.Recipe {
+	r.Ingredients = mapLines(r.Ingredients, l.Text)
+	r.Steps = mapLines(r.Steps, func(s string) string {
+		return l.Text(units.AnnotateTemperatures(s, l.System))
+	})
+	return r
+}
+
+func mapLines(in []string, f func(string) string) []string {
+	if in == nil {
+		return nil
+	}
+	out := make([]string, len(in))
+	for i, s := range in {
+		out[i] = f(s)
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package locale
+
+import (
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/units"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		tag     string
+		want    Locale
+		wantErr bool
+	}{
+		{tag: "de-DE", want: Locale{Tag: "de-DE", Decimal: ',', System: units.Metric}},
+		{tag: "en_gb", want: Locale{Tag: "en-GB", Decimal: '.', System: units.Metric}},
+		{tag: "en", want: Locale{Tag: "en-US", Decimal: '.', System: units.USCustomary}},
+		{tag: "fr", want: Locale{Tag: "fr", Decimal: ',', System: units.Metric}},
+		{tag: "zh-Hant-TW", want: Locale{Tag: "zh-TW", Decimal: '.', System: units.Metric}},
+		{tag: "", wantErr: true},
+		{tag: "1234", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, ok := Parse(tt.tag)
+		if ok == tt.wantErr || (ok && got != tt.want) {
+			t.Errorf("Parse(%q) = %+v, %t; want %+v", tt.tag, got, ok, tt.want)
+		}
+	}
+}
+
+func TestFromAcceptLanguage(t *testing.T) {
+	tests := map[string]string{
+		"fr-CH, fr;q=0.9, en;q=0.8":   "fr-CH",
+		"en;q=0.5, de-AT;q=0.9":       "de-AT",
+		"*, xx-!!;q=0.9, en-AU;q=0.1": "en-AU",
+		"":                            "en-US",
+		"de;q=0":                      "en-US",
+	}
+	for header, want := range tests {
+		if got := FromAcceptLanguage(header).Tag; got != want {
+			t.Errorf("FromAcceptLanguage(%q) = %s, want %s", header, got, want)
+		}
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	de, _ := Parse("de-DE")
+	if got := de.FormatNumber(1.50, 2); got != "1,5" {
+		t.Errorf("FormatNumber = %q, want 1,5", got)
+	}
+	if got := de.FormatTemperature(200); got != "200 °C" {
+		t.Errorf("FormatTemperature = %q, want 200 °C", got)
+	}
+	if got := Default.FormatTemperature(200); got != "392 °F" {
+		t.Errorf("FormatTemperature = %q, want 392 °F", got)
+	}
+	if got := de.Text("Add 1.5 cups of flour, then 2 eggs."); got != "Add 1,5 cups of flour, then 2 eggs." {
+		t.Errorf("Text = %q", got)
+	}
+}
+
+func TestRecipe(t *testing.T) {
+	de, _ := Parse("de-DE")
+	r := model.NewRecipe("Scones", []string{"2.5 cups flour"}, []string{"Bake at 425°F for 12.5 minutes."}, nil, "", nil)
+	got := de.Recipe(r)
+	if got.Ingredients[0] != "2,5 cups flour" {
+		t.Errorf("Expected a decimal comma in ingredients, got %q", got.Ingredients[0])
+	}
+	if got.Steps[0] != "Bake at 220°C/425°F for 12,5 minutes." {
+		t.Errorf("Expected a converted temperature and decimal comma in steps, got %q", got.Steps[0])
+	}
+	if r.Steps[0] != "Bake at 425°F for 12.5 minutes." {
+		t.Errorf("Expected the original recipe to be unchanged, got %q", r.Steps[0])
+	}
+}
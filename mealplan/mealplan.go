@@ -0,0 +1,161 @@
+// Package mealplan models a plan of recipes laid out over days and meals, and encodes it for
+// calendar apps (iCalendar) and for API clients (JSON keyed by day and meal).
+package mealplan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// Meal is a slot within a day.
+type Meal string
+
+// Meals in the order they are served.
+const (
+	Breakfast Meal = "breakfast"
+	Lunch     Meal = "lunch"
+	Dinner    Meal = "dinner"
+)
+
+// label returns the meal name as shown in calendar event titles, e.g. "Dinner".
+func (m Meal) label() string {
+	if m == "" {
+		return ""
+	}
+	return strings.ToUpper(string(m[:1])) + string(m[1:])
+}
+
+// mealTimes gives the start of each meal's calendar event as an offset from midnight.
+var mealTimes = map[Meal]time.Duration{
+	Breakfast: 8 * time.Hour,
+	Lunch:     12*time.Hour + 30*time.Minute,
+	Dinner:    18*time.Hour + 30*time.Minute,
+}
+
+// mealDuration is the length of every calendar event.
+const mealDuration = time.Hour
+
+// dayLayout formats the days of a plan.
+const dayLayout = "2006-01-02"
+
+// Entry assigns a recipe to a meal on a day. Only the date part of Day is used.
+type Entry struct {
+	Day    time.Time
+	Meal   Meal
+	Recipe model.Recipe
+}
+
+// Plan is a meal plan. CreatedAt stamps the calendar events so that exporting the same plan
+// twice yields the same feed.
+type Plan struct {
+	Name      string
+	Entries   []Entry
+	CreatedAt time.Time
+}
+
+// sorted returns the entries ordered by day, then meal.
+func (p Plan) sorted() []Entry {
+	entries := append([]Entry(nil), p.Entries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		di, dj := entries[i].Day.Format(dayLayout), entries[j].Day.Format(dayLayout)
+		if di != dj {
+			return di < dj
+		}
+		return mealTimes[entries[i].Meal] < mealTimes[entries[j].Meal]
+	})
+	return entries
+}
+
+// ByDay returns the plan keyed by day ("2006-01-02") and meal, the JSON shape served to clients.
+func (p Plan) ByDay() map[string]map[Meal]model.Recipe {
+	days := make(map[string]map[Meal]model.Recipe)
+	for _, e := range p.Entries {
+		day := e.Day.Format(dayLayout)
+		if days[day] == nil {
+			days[day] = make(map[Meal]model.Recipe)
+		}
+		days[day][e.Meal] = e.Recipe
+	}
+	return days
+}
+
+// WriteJSON writes the plan as {"name": ..., "days": {"2006-01-02": {"dinner": recipe}}}.
+func WriteJSON(w io.Writer, p Plan) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Name string                           `json:"name"`
+		Days map[string]map[Meal]model.Recipe `json:"days"`
+	}{p.Name, p.ByDay()})
+}
+
+// WriteICal writes the plan as an iCalendar feed with one event per meal. recipeURL returns the
+// link attached to each event; it may be nil to omit links. Event times are floating (no time
+// zone), so calendar apps show each meal at the same wall-clock time wherever the user is.
+func WriteICal(w io.Writer, p Plan, recipeURL func(model.Recipe) string) error {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//recipe-resolver//meal plan//EN",
+		"CALSCALE:GREGORIAN",
+	}
+	if p.Name != "" {
+		lines = append(lines, "X-WR-CALNAME:"+escapeText(p.Name))
+	}
+	stamp := p.CreatedAt.UTC().Format("20060102T150405Z")
+	for _, e := range p.sorted() {
+		day := time.Date(e.Day.Year(), e.Day.Month(), e.Day.Day(), 0, 0, 0, 0, time.UTC)
+		start := day.Add(mealTimes[e.Meal])
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			fmt.Sprintf("UID:%s-%s-%s@recipe-resolver", e.Recipe.ID, day.Format("20060102"), e.Meal),
+			"DTSTAMP:"+stamp,
+			"DTSTART:"+start.Format("20060102T150405"),
+			"DTEND:"+start.Add(mealDuration).Format("20060102T150405"),
+			"SUMMARY:"+escapeText(e.Meal.label()+": "+e.Recipe.Title),
+			"DESCRIPTION:"+escapeText(strings.Join(e.Recipe.Ingredients, "\n")),
+		)
+		if recipeURL != nil {
+			lines = append(lines, "URL:"+recipeURL(e.Recipe))
+		}
+		lines = append(lines, "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(fold(line))
+		b.WriteString("\r\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeText escapes an iCalendar TEXT value (RFC 5545 section 3.3.11).
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// fold splits a content line into 75-octet chunks joined by CRLF and a space, without breaking
+// a UTF-8 sequence (RFC 5545 section 3.1).
+func fold(line string) string {
+	const limit = 75
+	var b strings.Builder
+	n := 0
+	for _, r := range line {
+		size := len(string(r))
+		if n+size > limit {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	return b.String()
+}
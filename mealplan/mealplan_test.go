@@ -0,0 +1,99 @@
+package mealplan
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+func testPlan() Plan {
+	curry := model.NewRecipe("Chickpea Curry, Quick", []string{"chickpeas", "coconut milk"}, []string{"Simmer."}, nil, "", nil)
+	curry.ID = "curry"
+	oats := model.NewRecipe("Overnight Oats", []string{"oats", "milk"}, []string{"Soak."}, nil, "", nil)
+	oats.ID = "oats"
+	tue := time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC)
+	mon := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	return Plan{
+		Name:      "Week 10",
+		CreatedAt: time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC),
+		Entries: []Entry{
+			{Day: tue, Meal: Dinner, Recipe: curry},
+			{Day: mon, Meal: Dinner, Recipe: curry},
+			{Day: mon, Meal: Breakfast, Recipe: oats},
+		},
+	}
+}
+
+func TestWriteICal(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteICal(&buf, testPlan(), func(r model.Recipe) string { return "https://recipes.example/r/" + r.ID })
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	if !strings.HasPrefix(out, "BEGIN:VCALENDAR\r\n") || !strings.HasSuffix(out, "END:VCALENDAR\r\n") {
+		t.Errorf("Expected a CRLF-terminated VCALENDAR, got:\n%s", out)
+	}
+	if n := strings.Count(out, "BEGIN:VEVENT"); n != 3 {
+		t.Errorf("Expected 3 events, got %d", n)
+	}
+	for _, want := range []string{
+		"UID:oats-20250303-breakfast@recipe-resolver\r\n",
+		"DTSTART:20250303T080000\r\n",
+		"DTEND:20250303T090000\r\n",
+		"SUMMARY:Dinner: Chickpea Curry\\, Quick\r\n",
+		"DESCRIPTION:chickpeas\\ncoconut milk\r\n",
+		"URL:https://recipes.example/r/curry\r\n",
+		"DTSTAMP:20250301T090000Z\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected feed to contain %q, got:\n%s", want, out)
+		}
+	}
+	// Events are ordered by day, then meal.
+	if strings.Index(out, "20250303T080000") > strings.Index(out, "20250303T183000") ||
+		strings.Index(out, "20250303T183000") > strings.Index(out, "20250304T183000") {
+		t.Errorf("Expected events in chronological order, got:\n%s", out)
+	}
+}
+
+func TestFold(t *testing.T) {
+	line := "DESCRIPTION:" + strings.Repeat("é", 80)
+	folded := fold(line)
+	for _, part := range strings.Split(folded, "\r\n") {
+		if len(part) > 75 {
+			t.Errorf("Expected folded lines of at most 75 octets, got %d", len(part))
+		}
+	}
+	if unfolded := strings.ReplaceAll(folded, "\r\n ", ""); unfolded != line {
+		t.Errorf("Expected unfolding to restore the line, got %q", unfolded)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, testPlan()); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Name string                             `json:"name"`
+		Days map[string]map[string]model.Recipe `json:"days"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Week 10" || len(got.Days) != 2 {
+		t.Fatalf("Expected 2 days in plan %q, got %+v", "Week 10", got)
+	}
+	if got.Days["2025-03-03"]["breakfast"].Title != "Overnight Oats" {
+		t.Errorf("Expected oats for Monday breakfast, got %+v", got.Days["2025-03-03"])
+	}
+	if got.Days["2025-03-04"]["dinner"].ID != "curry" {
+		t.Errorf("Expected curry for Tuesday dinner, got %+v", got.Days["2025-03-04"])
+	}
+}
@@ -0,0 +1,155 @@
+package model
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// NutritionConstraint bounds one nutrient per serving: calories in kcal, protein,
+// carbohydrates and fat in grams. A zero Min or Max leaves that side unbounded.
+type NutritionConstraint struct {
+	Nutrient string  `json:"nutrient"`
+	Min      float64 `json:"min,omitempty"`
+	Max      float64 `json:"max,omitempty"`
+}
+
+// String returns the constraint as "calories<=500", "protein>=25" or both joined by "&".
+func (c NutritionConstraint) String() string {
+	var parts []string
+	if c.Min > 0 {
+		parts = append(parts, c.Nutrient+">="+strconv.FormatFloat(c.Min, 'f', -1, 64))
+	}
+	if c.Max > 0 {
+		parts = append(parts, c.Nutrient+"<="+strconv.FormatFloat(c.Max, 'f', -1, 64))
+	}
+	return strings.Join(parts, "&")
+}
+
+// Allows reports whether value, the amount of c.Nutrient, is within the bounds.
+func (c NutritionConstraint) Allows(value float64) bool {
+	return (c.Min <= 0 || value >= c.Min) && (c.Max <= 0 || value <= c.Max)
+}
+
+// MacroNutrients are the nutrients structured nutritional info gives as numbers: calories in
+// kcal, the rest in grams per serving.
+var MacroNutrients = []string{"calories", "protein", "carbohydrates", "fat"}
+
+// NutrientValue returns the amount of nutrient in a recipe's nutritional info, which may be
+// decoded JSON or a map[string]int. Non-numeric values such as "20 g" do not count.
+func NutrientValue(info interface{}, nutrient string) (float64, bool) {
+	switch m := info.(type) {
+	case map[string]interface{}:
+		switch v := m[nutrient].(type) {
+		case float64:
+			return v, true
+		case int:
+			return float64(v), true
+		}
+	case map[string]int:
+		v, ok := m[nutrient]
+		return float64(v), ok
+	}
+	return 0, false
+}
+
+// UnmetNutrition returns the constraints that a recipe's nutritional info does not satisfy. A
+// nutrient missing from info fails its constraint.
+func UnmetNutrition(info interface{}, constraints []NutritionConstraint) []NutritionConstraint {
+	var unmet []NutritionConstraint
+	for _, c := range constraints {
+		if v, ok := NutrientValue(info, c.Nutrient); !ok || !c.Allows(v) {
+			unmet = append(unmet, c)
+		}
+	}
+	return unmet
+}
+
+// NutritionFormat sets how nutrient values are stored and serialized: the number of decimals
+// kept for calories and for the other nutrients, which are in grams. Zero decimals stores whole
+// numbers, so that they are written as 450 rather than 450.0 or 4.5e+02.
+type NutritionFormat struct {
+	CalorieDecimals int `json:"calorie_decimals"`
+	GramDecimals    int `json:"gram_decimals"`
+}
+
+// DefaultNutritionFormat keeps whole calories and grams to one decimal.
+var DefaultNutritionFormat = NutritionFormat{CalorieDecimals: 0, GramDecimals: 1}
+
+// Normalize returns nutritional info with every numeric value, including strings such as
+// "450 kcal" or "12.5 g", stored as a number rounded by f. Keys are lower-cased. Values without a
+// leading number ("trace") are kept verbatim, as is info that is not a map, e.g. free text.
+func (f NutritionFormat) Normalize(info interface{}) interface{} {
+	var m map[string]interface{}
+	switch typed := info.(type) {
+	case map[string]interface{}:
+		m = typed
+	case map[string]int:
+		m = make(map[string]interface{}, len(typed))
+		for k, v := range typed {
+			m[k] = v
+		}
+	case map[string]float64:
+		m = make(map[string]interface{}, len(typed))
+		for k, v := range typed {
+			m[k] = v
+		}
+	case map[string]string:
+		m = make(map[string]interface{}, len(typed))
+		for k, v := range typed {
+			m[k] = v
+		}
+	default:
+		return info
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		k = strings.ToLower(strings.TrimSpace(k))
+		if s, ok := v.(string); ok && strings.TrimSpace(s) == "" {
+			continue
+		}
+		n, ok := ParseNutrient(v)
+		if !ok {
+			out[k] = v
+			continue
+		}
+		if k == "calories" {
+			out[k] = round(n, f.CalorieDecimals)
+		} else {
+			out[k] = round(n, f.GramDecimals)
+		}
+	}
+	return out
+}
+
+// round rounds v to the given number of decimals, returning an int for zero decimals.
+func round(v float64, decimals int) interface{} {
+	if decimals <= 0 {
+		return int(math.Round(v))
+	}
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(v*scale) / scale
+}
+
+// ParseNutrient returns the amount in a nutrient value: a number, or a string starting with one
+// as LLM providers and recipe sites often write them, e.g. "450 kcal", "1,200" or "12.5g".
+func ParseNutrient(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case string:
+		s := strings.TrimSpace(n)
+		end := 0
+		for end < len(s) && (s[end] == '.' || s[end] == ',' || (s[end] >= '0' && s[end] <= '9')) {
+			end++
+		}
+		if end == 0 {
+			return 0, false
+		}
+		f, err := strconv.ParseFloat(strings.ReplaceAll(s[:end], ",", ""), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
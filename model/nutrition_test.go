@@ -0,0 +1,52 @@
+package model
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNutritionFormat(t *testing.T) {
+	info := map[string]interface{}{
+		"Calories": "1,234.6 kcal",
+		"protein":  12.345,
+		"fat":      "7g",
+		"sodium":   "trace",
+		"fiber":    " ",
+	}
+	data, err := json.Marshal(DefaultNutritionFormat.Normalize(info))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"calories":1235,"fat":7,"protein":12.3,"sodium":"trace"}`; string(data) != want {
+		t.Errorf("Normalize = %s, want %s", data, want)
+	}
+
+	precise := NutritionFormat{CalorieDecimals: 1, GramDecimals: 2}.Normalize(map[string]int{"calories": 450})
+	if v, _ := NutrientValue(precise, "calories"); v != 450 {
+		t.Errorf("Expected calories to survive normalization, got %v", precise)
+	}
+	if text := DefaultNutritionFormat.Normalize("About 450 kcal"); text != "About 450 kcal" {
+		t.Errorf("Expected free-text nutrition to be kept, got %v", text)
+	}
+}
+
+func TestParseNutrient(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want float64
+		ok   bool
+	}{
+		{450.0, 450, true},
+		{12, 12, true},
+		{"450 kcal", 450, true},
+		{" 12.5g", 12.5, true},
+		{"1,200", 1200, true},
+		{"about 3 g", 0, false},
+		{nil, 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := ParseNutrient(tt.in); got != tt.want || ok != tt.ok {
+			t.Errorf("ParseNutrient(%#v) = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
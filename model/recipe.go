@@ -0,0 +1,403 @@
+// Package model holds the recipe types shared by the resolver, its HTTP server and
+// any Go code that embeds the resolution pipeline as a library.
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Recipe defines the structure for a recipe including basic attributes and metadata.
+// This structure models the recipes used for matching and is returned in the API response.
+type Recipe struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	// Slug is a URL-friendly name derived from Title (see Slugify), unique within a store.
+	Slug              string      `json:"slug,omitempty"`
+	Ingredients       []string    `json:"ingredients"`
+	Steps             []string    `json:"steps"`
+	NutritionalInfo   interface{} `json:"nutritional_info"`
+	AllergyDisclaimer string      `json:"allergy_disclaimer"`
+	Appliances        []string    `json:"appliances"`
+	Difficulty        Difficulty  `json:"difficulty,omitempty"`
+	// TotalTime is the preparation and cooking time in minutes; zero means unknown.
+	TotalTime int `json:"total_time,omitempty"`
+	// Tags are free-form labels such as TagKidFriendly.
+	Tags []string `json:"tags,omitempty"`
+	// ImageURL links to a photo of the dish, e.g. "https://cdn.example.com/curry.jpg".
+	ImageURL string `json:"image_url,omitempty"`
+	// SafetyWarnings lists food-safety concerns found in the recipe's guidance.
+	SafetyWarnings []SafetyWarning `json:"safety_warnings,omitempty"`
+	// Allergens lists the major allergens detected in the ingredients, e.g. "milk" or "gluten".
+	Allergens []string `json:"allergens,omitempty"`
+	// Archived recipes stay in the store but are no longer matched against queries.
+	Archived bool `json:"archived,omitempty"`
+	// Status is the recipe's place in the review workflow; empty means published.
+	Status Status `json:"status,omitempty"`
+	// ReviewNote is the curator's comment on the last review decision, e.g. why it was rejected.
+	ReviewNote string `json:"review_note,omitempty"`
+	// Provenance records where the recipe came from. Responses always carry it; stored recipes
+	// without one are curated.
+	Provenance *Provenance `json:"provenance,omitempty"`
+	// Attribution credits the source of a recipe that was not written for the service. Imported
+	// recipes must have one (see CheckAttribution).
+	Attribution *Attribution `json:"attribution,omitempty"`
+	// MergedFrom records the recipes merged into this one, e.g. near-duplicates a curator
+	// combined, so that their IDs and slugs still lead here and their provenance is kept.
+	MergedFrom []MergedRecipe `json:"merged_from,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// MergedRecipe is the record a recipe leaves in the one it was merged into.
+type MergedRecipe struct {
+	ID         string      `json:"id"`
+	Slug       string      `json:"slug,omitempty"`
+	Title      string      `json:"title"`
+	Provenance *Provenance `json:"provenance,omitempty"`
+	MergedAt   time.Time   `json:"merged_at"`
+}
+
+// Supersedes reports whether a recipe with the given ID or, if id is empty, slug was merged
+// into r.
+func (r Recipe) Supersedes(id, slug string) bool {
+	for _, m := range r.MergedFrom {
+		if id != "" && m.ID == id || id == "" && slug != "" && m.Slug == slug {
+			return true
+		}
+	}
+	return false
+}
+
+// Attribution is the licensing information of a recipe taken from elsewhere.
+type Attribution struct {
+	// SourceURL is where the recipe was published, e.g. "https://example.com/curry".
+	SourceURL string `json:"source_url,omitempty"`
+	// Author is the person or publication credited, e.g. "Serious Eats".
+	Author string `json:"author,omitempty"`
+	// License is the license the recipe may be served under, e.g. "CC-BY-4.0", or a note on
+	// the permission obtained.
+	License string `json:"license,omitempty"`
+}
+
+// ErrMissingAttribution is returned by CheckAttribution for an imported recipe without the
+// attribution needed to serve it.
+var ErrMissingAttribution = errors.New("imported recipe requires a license and a source URL or author")
+
+// CheckAttribution reports whether r can be served as far as licensing goes: imported recipes
+// need a license and a source URL or author, and a source URL must be an absolute http(s) URL.
+func (r Recipe) CheckAttribution() error {
+	a := r.Attribution
+	if r.Provenance != nil && r.Provenance.Origin == OriginImported {
+		if a == nil || strings.TrimSpace(a.License) == "" || strings.TrimSpace(a.SourceURL) == "" && strings.TrimSpace(a.Author) == "" {
+			return fmt.Errorf("%q: %w", r.Title, ErrMissingAttribution)
+		}
+	}
+	if a != nil && a.SourceURL != "" {
+		if u, err := url.Parse(a.SourceURL); err != nil || u.Host == "" || u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("%q: invalid source URL %q", r.Title, a.SourceURL)
+		}
+	}
+	return nil
+}
+
+// Origin is where a recipe came from.
+type Origin string
+
+const (
+	// OriginCurated recipes were written or edited by people running the service.
+	OriginCurated Origin = "curated"
+	// OriginImported recipes were imported from another application, e.g. Mealie.
+	OriginImported Origin = "imported"
+	// OriginGenerated recipes were written by a language model.
+	OriginGenerated Origin = "generated"
+	// OriginPlaceholder recipes are the empty stand-ins returned when generation fails.
+	OriginPlaceholder Origin = "placeholder"
+)
+
+// Provenance describes the origin of a recipe, so that clients can label AI-generated content.
+type Provenance struct {
+	Origin Origin `json:"origin"`
+	// Source names the application an imported recipe came from, e.g. "paprika".
+	Source string `json:"source,omitempty"`
+	// Model is the provider model that generated the recipe, e.g. "deepseek-chat", and
+	// PromptVersion identifies the prompt template it was given. Both are empty when unknown.
+	Model         string `json:"model,omitempty"`
+	PromptVersion string `json:"prompt_version,omitempty"`
+	// GeneratedAt is when the recipe was generated.
+	GeneratedAt *time.Time `json:"generated_at,omitempty"`
+	// TraceID identifies the generation that produced the recipe: the audit entry with this
+	// target records the prompt, model and raw response (see generation.Source.TraceID).
+	TraceID string `json:"trace_id,omitempty"`
+	// Query is the query a generated recipe was the primary answer to, when the generation was
+	// persisted to the store (see resolver.Resolver.PersistGenerations).
+	Query string `json:"query,omitempty"`
+}
+
+// Generated returns the provenance of a recipe generated at t by model from the prompt
+// template with version prompt.
+func Generated(model, prompt string, t time.Time) *Provenance {
+	t = t.UTC()
+	return &Provenance{Origin: OriginGenerated, Model: model, PromptVersion: prompt, GeneratedAt: &t}
+}
+
+// ProvenanceOrCurated returns r's provenance, or curated provenance if it has none.
+func (r Recipe) ProvenanceOrCurated() *Provenance {
+	if r.Provenance != nil {
+		return r.Provenance
+	}
+	return &Provenance{Origin: OriginCurated}
+}
+
+// NewRecipe creates a new Recipe object with the provided details.
+// It sets a unique ID (via uuid) and the current UTC timestamps for both creation and update.
+func NewRecipe(title string, ingredients, steps []string, nutritionalInfo interface{}, allergyDisclaimer string, appliances []string) Recipe {
+	now := time.Now().UTC()
+	return Recipe{
+		ID:                uuid.New().String(),
+		Title:             title,
+		Ingredients:       ingredients,
+		Steps:             steps,
+		NutritionalInfo:   nutritionalInfo,
+		AllergyDisclaimer: allergyDisclaimer,
+		Appliances:        appliances,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+}
+
+// TagKidFriendly marks recipes suitable for cooking with and for children.
+const TagKidFriendly = "kid-friendly"
+
+// CuisineTag returns the tag marking a recipe as belonging to cuisine, e.g. "cuisine:korean".
+func CuisineTag(cuisine string) string {
+	return "cuisine:" + strings.ToLower(strings.TrimSpace(cuisine))
+}
+
+// Courses of a menu, in the order they are served.
+const (
+	CourseStarter = "starter"
+	CourseMain    = "main"
+	CourseDessert = "dessert"
+)
+
+// Courses lists the courses of a menu, in the order they are served.
+var Courses = []string{CourseStarter, CourseMain, CourseDessert}
+
+// CourseTag returns the tag marking a recipe as suited to course, e.g. "course:dessert".
+func CourseTag(course string) string {
+	return "course:" + strings.ToLower(strings.TrimSpace(course))
+}
+
+// OccasionTag returns the tag marking a recipe as suited to an occasion, e.g.
+// "occasion:thanksgiving" (see package occasion).
+func OccasionTag(name string) string {
+	return "occasion:" + strings.ToLower(strings.TrimSpace(name))
+}
+
+// HasTag reports whether r carries tag, ignoring case.
+func (r Recipe) HasTag(tag string) bool {
+	for _, t := range r.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// UsesAppliance reports whether r lists appliance among its appliances, ignoring case.
+func (r Recipe) UsesAppliance(appliance string) bool {
+	for _, a := range r.Appliances {
+		if strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(appliance)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Status is the review status of a recipe.
+type Status string
+
+// Review statuses. The zero value is equivalent to StatusPublished.
+const (
+	StatusPublished     Status = "published"
+	StatusPendingReview Status = "pending_review"
+	StatusRejected      Status = "rejected"
+)
+
+// GeneratedFor reports whether r was generated as the primary answer to query, ignoring case.
+func (r Recipe) GeneratedFor(query string) bool {
+	return r.Provenance != nil && r.Provenance.Query != "" && strings.EqualFold(r.Provenance.Query, query)
+}
+
+// Published reports whether r has passed review, or never needed it.
+func (r Recipe) Published() bool {
+	return r.Status == "" || r.Status == StatusPublished
+}
+
+// ContentHash returns a hex digest of the recipe's content: its title, ingredients, steps,
+// nutritional information, allergy disclaimer, appliances, difficulty, total time and tags,
+// with surrounding whitespace trimmed. Recipes with equal content have equal hashes whatever
+// their ID, slug, status or timestamps, e.g. the same recipe generated twice for a retried
+// request.
+func (r Recipe) ContentHash() string {
+	trim := func(list []string) []string {
+		out := make([]string, len(list))
+		for i, s := range list {
+			out[i] = strings.TrimSpace(s)
+		}
+		return out
+	}
+	content := []interface{}{
+		strings.TrimSpace(r.Title), trim(r.Ingredients), trim(r.Steps), r.NutritionalInfo,
+		strings.TrimSpace(r.AllergyDisclaimer), trim(r.Appliances), r.Difficulty, r.TotalTime, trim(r.Tags),
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		// Nutritional information that does not encode still leaves the rest to compare.
+		content[3] = fmt.Sprint(r.NutritionalInfo)
+		data, _ = json.Marshal(content)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Difficulty is how demanding a recipe is to cook.
+type Difficulty string
+
+// Difficulty levels, from least to most demanding.
+const (
+	DifficultyEasy   Difficulty = "easy"
+	DifficultyMedium Difficulty = "medium"
+	DifficultyHard   Difficulty = "hard"
+)
+
+// Level returns the position of d in the order easy < medium < hard, starting at 1, or 0 if d
+// is not a known difficulty.
+func (d Difficulty) Level() int {
+	switch d {
+	case DifficultyEasy:
+		return 1
+	case DifficultyMedium:
+		return 2
+	case DifficultyHard:
+		return 3
+	}
+	return 0
+}
+
+// ParseDifficulty parses a difficulty level such as "Medium".
+func ParseDifficulty(s string) (Difficulty, error) {
+	d := Difficulty(strings.ToLower(strings.TrimSpace(s)))
+	if d.Level() == 0 {
+		return "", fmt.Errorf("unknown difficulty %q (want easy, medium or hard)", s)
+	}
+	return d, nil
+}
+
+// SafetyWarning is a machine-readable food-safety concern about a recipe.
+type SafetyWarning struct {
+	// Code identifies the kind of concern, e.g. "poultry_undercooked".
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Step is the index of the step the concern applies to, or -1 for the whole recipe.
+	Step int `json:"step"`
+}
+
+// slugFolds spells common accented Latin letters in ASCII for Slugify.
+var slugFolds = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'æ': "ae",
+	'ç': "c", 'è': "e", 'é': "e", 'ê': "e", 'ë': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ñ': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'œ': "oe",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ý': "y", 'ÿ': "y", 'ß': "ss",
+}
+
+// Slugify derives a URL-friendly slug from a title: lowercase ASCII letters and digits, with
+// accents dropped and every other run of characters replaced by a single hyphen, e.g.
+// "Crème Brûlée (Classic)" becomes "creme-brulee-classic". It returns "recipe" for titles
+// without any letters or digits.
+func Slugify(title string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(title) {
+		var s string
+		switch {
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9':
+			s = string(r)
+		case slugFolds[r] != "":
+			s = slugFolds[r]
+		default:
+			hyphen = b.Len() > 0
+			continue
+		}
+		if hyphen {
+			b.WriteByte('-')
+			hyphen = false
+		}
+		b.WriteString(s)
+	}
+	if b.Len() == 0 {
+		return "recipe"
+	}
+	return b.String()
+}
+
+// Filter selects recipes for bulk operations. A recipe matches if it satisfies every set
+// field; the zero Filter matches everything.
+type Filter struct {
+	// IDs, if non-empty, restricts the match to these recipe IDs.
+	IDs []string `json:"ids,omitempty"`
+	// TitleContains matches titles containing the text, ignoring case.
+	TitleContains string `json:"title_contains,omitempty"`
+	// Tag matches recipes carrying the tag.
+	Tag string `json:"tag,omitempty"`
+	// CreatedBefore and CreatedAfter bound the creation time.
+	CreatedBefore time.Time `json:"created_before,omitempty"`
+	CreatedAfter  time.Time `json:"created_after,omitempty"`
+	// SafetyWarnings, if set, matches recipes with (true) or without (false) safety warnings.
+	SafetyWarnings *bool `json:"safety_warnings,omitempty"`
+}
+
+// IsZero reports whether f has no criteria and so matches every recipe.
+func (f Filter) IsZero() bool {
+	return len(f.IDs) == 0 && f.TitleContains == "" && f.Tag == "" && f.CreatedBefore.IsZero() &&
+		f.CreatedAfter.IsZero() && f.SafetyWarnings == nil
+}
+
+// Match reports whether r satisfies f.
+func (f Filter) Match(r Recipe) bool {
+	if len(f.IDs) > 0 {
+		found := false
+		for _, id := range f.IDs {
+			if id == r.ID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	switch {
+	case f.TitleContains != "" && !strings.Contains(strings.ToLower(r.Title), strings.ToLower(f.TitleContains)):
+		return false
+	case f.Tag != "" && !r.HasTag(f.Tag):
+		return false
+	case !f.CreatedBefore.IsZero() && !r.CreatedAt.Before(f.CreatedBefore):
+		return false
+	case !f.CreatedAfter.IsZero() && !r.CreatedAt.After(f.CreatedAfter):
+		return false
+	case f.SafetyWarnings != nil && *f.SafetyWarnings != (len(r.SafetyWarnings) > 0):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct{ title, want string }{
+		{"Chicken Salad", "chicken-salad"},
+		{"  Crème Brûlée (Classic)!  ", "creme-brulee-classic"},
+		{"Mom's 3-Bean Chili", "mom-s-3-bean-chili"},
+		{"Käsespätzle", "kasespatzle"},
+		{"串焼き", "recipe"},
+	}
+	for _, tt := range tests {
+		if got := Slugify(tt.title); got != tt.want {
+			t.Errorf("Slugify(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	r := NewRecipe("Garlic Butter Shrimp", nil, nil, nil, "", nil)
+	r.Tags = []string{"seafood"}
+	warned := true
+	tests := []struct {
+		name string
+		f    Filter
+		want bool
+	}{
+		{"zero", Filter{}, true},
+		{"id", Filter{IDs: []string{"other", r.ID}}, true},
+		{"other id", Filter{IDs: []string{"other"}}, false},
+		{"title", Filter{TitleContains: "butter"}, true},
+		{"title and tag", Filter{TitleContains: "butter", Tag: "vegan"}, false},
+		{"created before", Filter{CreatedBefore: r.CreatedAt.Add(time.Hour)}, true},
+		{"created after", Filter{CreatedAfter: r.CreatedAt.Add(time.Hour)}, false},
+		{"safety warnings", Filter{SafetyWarnings: &warned}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.f.Match(r); got != tt.want {
+			t.Errorf("%s: Match() = %t, want %t", tt.name, got, tt.want)
+		}
+		if tt.f.IsZero() != (tt.name == "zero") {
+			t.Errorf("%s: unexpected IsZero() = %t", tt.name, tt.f.IsZero())
+		}
+	}
+}
+
+func TestCheckAttribution(t *testing.T) {
+	imported := &Provenance{Origin: OriginImported}
+	for _, tc := range []struct {
+		name string
+		r    Recipe
+		ok   bool
+	}{
+		{"curated without attribution", Recipe{Title: "Stew"}, true},
+		{"imported without attribution", Recipe{Title: "Stew", Provenance: imported}, false},
+		{"imported without license", Recipe{Title: "Stew", Provenance: imported, Attribution: &Attribution{Author: "Jane Doe"}}, false},
+		{"imported with author and license", Recipe{Title: "Stew", Provenance: imported, Attribution: &Attribution{Author: "Jane Doe", License: "CC-BY-4.0"}}, true},
+		{"invalid source URL", Recipe{Title: "Stew", Attribution: &Attribution{SourceURL: "example.com/stew"}}, false},
+		{"valid source URL", Recipe{Title: "Stew", Provenance: imported, Attribution: &Attribution{SourceURL: "https://example.com/stew", License: "CC0-1.0"}}, true},
+	} {
+		if err := tc.r.CheckAttribution(); (err == nil) != tc.ok {
+			t.Errorf("%s: CheckAttribution() = %v", tc.name, err)
+		}
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	want := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for _, s := range []string{"2024-05-01T02:00:00+02:00", "2024-05-01", "1714521600", "1714521600000", " 2024-05-01 "} {
+		if got, err := ParseTimestamp(s); err != nil || !got.Equal(want) {
+			t.Errorf("ParseTimestamp(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "yesterday", "-5", "05/01/2024"} {
+		if _, err := ParseTimestamp(s); err == nil {
+			t.Errorf("Expected ParseTimestamp(%q) to fail", s)
+		}
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	r := NewRecipe("Pancakes", []string{"flour", "eggs"}, []string{"Mix", "Fry"}, map[string]interface{}{"calories": 350}, "Contains eggs", nil)
+	retried := NewRecipe(" Pancakes ", []string{"flour ", "eggs"}, []string{"Mix", "Fry"}, map[string]interface{}{"calories": 350}, "Contains eggs", nil)
+	retried.Slug, retried.Status = "pancakes-2", StatusPendingReview
+	if r.ID == retried.ID || r.ContentHash() != retried.ContentHash() {
+		t.Errorf("Expected equal content to hash equally regardless of ID and metadata")
+	}
+	changed := r
+	changed.Steps = []string{"Mix", "Bake"}
+	if r.ContentHash() == changed.ContentHash() {
+		t.Errorf("Expected different steps to change the hash")
+	}
+}
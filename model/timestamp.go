@@ -0,0 +1,28 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseTimestamp parses a timestamp in one of the formats recipes arrive with: RFC 3339, a plain
+// date ("2024-05-01"), or Unix epoch seconds or milliseconds ("1714521600"). The result is in UTC.
+func ParseTimestamp(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && n > 0 {
+		// Epoch seconds stay below 1e12 until the year 33658; larger values are milliseconds.
+		if n >= 1e12 {
+			return time.UnixMilli(n).UTC(), nil
+		}
+		return time.Unix(n, 0).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q: want RFC 3339, a date or epoch seconds", s)
+}
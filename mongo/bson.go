@@ -0,0 +1,355 @@
+package mongo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// D is a BSON document whose keys keep their order, as commands require: the command name
+// comes first.
+type D []E
+
+// E is an element of a D. It is an unnamed struct type so that documents can be written
+// compactly, e.g. D{{"find", "recipes"}}, in other packages too.
+type E = struct {
+	Key   string
+	Value interface{}
+}
+
+// Get returns the value of key, or nil.
+func (d D) Get(key string) interface{} {
+	for _, e := range d {
+		if e.Key == key {
+			return e.Value
+		}
+	}
+	return nil
+}
+
+// Map returns the document as a map, converting nested documents too, e.g. to encode it as
+// JSON.
+func (d D) Map() map[string]interface{} {
+	m := make(map[string]interface{}, len(d))
+	for _, e := range d {
+		m[e.Key] = plain(e.Value)
+	}
+	return m
+}
+
+// plain returns v with its documents converted to maps.
+func plain(v interface{}) interface{} {
+	switch v := v.(type) {
+	case D:
+		return v.Map()
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = plain(item)
+		}
+		return out
+	}
+	return v
+}
+
+// Int returns a BSON number as an int64, or 0 for other values.
+func Int(v interface{}) int64 {
+	switch v := v.(type) {
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+// ObjectID is a BSON object ID, the default type of the _id field.
+type ObjectID [12]byte
+
+// Binary is BSON binary data of the generic subtype.
+type Binary []byte
+
+// BSON element types.
+const (
+	typeDouble   = 0x01
+	typeString   = 0x02
+	typeDocument = 0x03
+	typeArray    = 0x04
+	typeBinary   = 0x05
+	typeObjectID = 0x07
+	typeBool     = 0x08
+	typeDateTime = 0x09
+	typeNull     = 0x0A
+	typeInt32    = 0x10
+	typeInt64    = 0x12
+)
+
+// Marshal encodes a document. Values may be D, map[string]interface{}, []interface{},
+// []string, []D, string, bool, int, int32, int64, float64, time.Time, ObjectID, Binary or nil;
+// map keys are sorted.
+func Marshal(doc interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeDocument(&buf, doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeDocument(buf *bytes.Buffer, doc interface{}) error {
+	var elems D
+	switch doc := doc.(type) {
+	case D:
+		elems = doc
+	case map[string]interface{}:
+		keys := make([]string, 0, len(doc))
+		for k := range doc {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			elems = append(elems, E{k, doc[k]})
+		}
+	default:
+		return fmt.Errorf("bson: cannot encode %T as a document", doc)
+	}
+	start := buf.Len()
+	buf.Write(make([]byte, 4))
+	for _, e := range elems {
+		if err := writeElement(buf, e.Key, e.Value); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte(0)
+	binary.LittleEndian.PutUint32(buf.Bytes()[start:], uint32(buf.Len()-start))
+	return nil
+}
+
+func writeElement(buf *bytes.Buffer, key string, v interface{}) error {
+	typ := func(t byte) {
+		buf.WriteByte(t)
+		buf.WriteString(key)
+		buf.WriteByte(0)
+	}
+	switch v := v.(type) {
+	case nil:
+		typ(typeNull)
+	case float64:
+		typ(typeDouble)
+		binary.Write(buf, binary.LittleEndian, math.Float64bits(v))
+	case string:
+		typ(typeString)
+		binary.Write(buf, binary.LittleEndian, uint32(len(v)+1))
+		buf.WriteString(v)
+		buf.WriteByte(0)
+	case D, map[string]interface{}:
+		typ(typeDocument)
+		return writeDocument(buf, v)
+	case []interface{}:
+		typ(typeArray)
+		return writeArray(buf, v)
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, s := range v {
+			items[i] = s
+		}
+		typ(typeArray)
+		return writeArray(buf, items)
+	case []D:
+		items := make([]interface{}, len(v))
+		for i, d := range v {
+			items[i] = d
+		}
+		typ(typeArray)
+		return writeArray(buf, items)
+	case Binary:
+		typ(typeBinary)
+		binary.Write(buf, binary.LittleEndian, uint32(len(v)))
+		buf.WriteByte(0)
+		buf.Write(v)
+	case ObjectID:
+		typ(typeObjectID)
+		buf.Write(v[:])
+	case bool:
+		typ(typeBool)
+		if v {
+			buf.WriteByte(1)
+		} else {
+			buf.WriteByte(0)
+		}
+	case time.Time:
+		typ(typeDateTime)
+		binary.Write(buf, binary.LittleEndian, v.UnixMilli())
+	case int32:
+		typ(typeInt32)
+		binary.Write(buf, binary.LittleEndian, v)
+	case int:
+		typ(typeInt64)
+		binary.Write(buf, binary.LittleEndian, int64(v))
+	case int64:
+		typ(typeInt64)
+		binary.Write(buf, binary.LittleEndian, v)
+	default:
+		return fmt.Errorf("bson: cannot encode %T", v)
+	}
+	return nil
+}
+
+func writeArray(buf *bytes.Buffer, items []interface{}) error {
+	doc := make(D, len(items))
+	for i, item := range items {
+		doc[i] = E{fmt.Sprint(i), item}
+	}
+	return writeDocument(buf, doc)
+}
+
+var errTruncated = errors.New("bson: truncated document")
+
+// Unmarshal decodes a document. Nested documents decode as D, arrays as []interface{}, numbers
+// as int32, int64 or float64, dates as time.Time in UTC, and object IDs and binary data as
+// ObjectID and Binary.
+func Unmarshal(data []byte) (D, error) {
+	d := &decoder{data: data}
+	doc, err := d.document()
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(data) {
+		return nil, errors.New("bson: trailing data after document")
+	}
+	return doc, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) next(n int) ([]byte, error) {
+	if n < 0 || d.pos+n > len(d.data) {
+		return nil, errTruncated
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *decoder) uint32() (uint32, error) {
+	b, err := d.next(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+func (d *decoder) uint64() (uint64, error) {
+	b, err := d.next(8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+func (d *decoder) cstring() (string, error) {
+	end := bytes.IndexByte(d.data[d.pos:], 0)
+	if end < 0 {
+		return "", errTruncated
+	}
+	s := string(d.data[d.pos : d.pos+end])
+	d.pos += end + 1
+	return s, nil
+}
+
+func (d *decoder) document() (D, error) {
+	size, err := d.uint32()
+	if err != nil {
+		return nil, err
+	}
+	end := d.pos - 4 + int(size)
+	if size < 5 || end > len(d.data) {
+		return nil, errTruncated
+	}
+	doc := D{}
+	for d.pos < end-1 {
+		b, _ := d.next(1)
+		key, err := d.cstring()
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.value(b[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w in %q", err, key)
+		}
+		doc = append(doc, E{key, v})
+	}
+	if d.pos != end-1 || d.data[d.pos] != 0 {
+		return nil, errors.New("bson: malformed document")
+	}
+	d.pos = end
+	return doc, nil
+}
+
+func (d *decoder) value(t byte) (interface{}, error) {
+	switch t {
+	case typeDouble:
+		bits, err := d.uint64()
+		return math.Float64frombits(bits), err
+	case typeString:
+		n, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(int(n))
+		if err != nil || n == 0 {
+			return nil, errTruncated
+		}
+		return string(b[:n-1]), nil
+	case typeDocument:
+		return d.document()
+	case typeArray:
+		doc, err := d.document()
+		if err != nil {
+			return nil, err
+		}
+		items := make([]interface{}, len(doc))
+		for i, e := range doc {
+			items[i] = e.Value
+		}
+		return items, nil
+	case typeBinary:
+		n, err := d.uint32()
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.next(int(n) + 1)
+		if err != nil {
+			return nil, err
+		}
+		return Binary(append([]byte(nil), b[1:]...)), nil
+	case typeObjectID:
+		b, err := d.next(12)
+		var id ObjectID
+		copy(id[:], b)
+		return id, err
+	case typeBool:
+		b, err := d.next(1)
+		return err == nil && b[0] == 1, err
+	case typeDateTime:
+		ms, err := d.uint64()
+		return time.UnixMilli(int64(ms)).UTC(), err
+	case typeNull:
+		return nil, nil
+	case typeInt32:
+		n, err := d.uint32()
+		return int32(n), err
+	case typeInt64:
+		n, err := d.uint64()
+		return int64(n), err
+	}
+	return nil, fmt.Errorf("bson: unsupported type 0x%02x", t)
+}
@@ -0,0 +1,106 @@
+package mongo
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// authenticate runs a SCRAM-SHA-256 conversation (RFC 7677) on cn. Passwords are used as
+// given, without SASLprep, which only matters for non-ASCII passwords.
+func (c *Client) authenticate(cn *conn) error {
+	source := c.AuthSource
+	if source == "" {
+		source = "admin"
+	}
+	var nonce [24]byte
+	rand.Read(nonce[:])
+	clientNonce := base64.StdEncoding.EncodeToString(nonce[:])
+	user := strings.NewReplacer("=", "=3D", ",", "=2C").Replace(c.Username)
+	clientFirst := "n=" + user + ",r=" + clientNonce
+
+	reply, err := cn.run(source, D{
+		{"saslStart", int32(1)},
+		{"mechanism", "SCRAM-SHA-256"},
+		{"payload", Binary("n,," + clientFirst)},
+		{"options", D{{"skipEmptyExchange", true}}},
+	})
+	if err != nil {
+		return fmt.Errorf("mongo: authenticating %s: %w", c.Username, err)
+	}
+	serverFirst, _ := reply.Get("payload").(Binary)
+	fields := scramFields(string(serverFirst))
+	iterations, err := strconv.Atoi(fields["i"])
+	salt, saltErr := base64.StdEncoding.DecodeString(fields["s"])
+	if err != nil || saltErr != nil || !strings.HasPrefix(fields["r"], clientNonce) {
+		return fmt.Errorf("mongo: authenticating %s: invalid server challenge", c.Username)
+	}
+
+	salted, err := c.saltedPassword(salt, iterations)
+	if err != nil {
+		return fmt.Errorf("mongo: authenticating %s: %w", c.Username, err)
+	}
+	clientFinal := "c=biws,r=" + fields["r"]
+	authMessage := clientFirst + "," + string(serverFirst) + "," + clientFinal
+	clientKey := hmacSHA256(salted, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	proof := hmacSHA256(storedKey[:], authMessage)
+	for i := range proof {
+		proof[i] ^= clientKey[i]
+	}
+	reply, err = cn.run(source, D{
+		{"saslContinue", int32(1)},
+		{"conversationId", reply.Get("conversationId")},
+		{"payload", Binary(clientFinal + ",p=" + base64.StdEncoding.EncodeToString(proof))},
+	})
+	if err != nil {
+		return fmt.Errorf("mongo: authenticating %s: %w", c.Username, err)
+	}
+	serverFinal, _ := reply.Get("payload").(Binary)
+	signature := hmacSHA256(hmacSHA256(salted, "Server Key"), authMessage)
+	if scramFields(string(serverFinal))["v"] != base64.StdEncoding.EncodeToString(signature) {
+		return errors.New("mongo: authenticating: the server's signature does not match")
+	}
+	if done, _ := reply.Get("done").(bool); !done {
+		return errors.New("mongo: authenticating: the server expects more steps")
+	}
+	return nil
+}
+
+// saltedPassword returns the client's password salted by salt over iterations, caching it:
+// the derivation is deliberately slow and each connection authenticates anew.
+func (c *Client) saltedPassword(salt []byte, iterations int) ([]byte, error) {
+	key := strconv.Itoa(iterations) + ":" + string(salt) + ":" + c.Password
+	if salted, ok := c.keys.Load(key); ok {
+		return salted.([]byte), nil
+	}
+	salted, err := pbkdf2.Key(sha256.New, c.Password, salt, iterations, sha256.Size)
+	if err != nil {
+		return nil, err
+	}
+	c.keys.Store(key, salted)
+	return salted, nil
+}
+
+// scramFields splits a SCRAM message such as "r=abc,s=c2FsdA==,i=4096" into its attributes.
+func scramFields(msg string) map[string]string {
+	fields := make(map[string]string)
+	for _, f := range strings.Split(msg, ",") {
+		if k, v, ok := strings.Cut(f, "="); ok {
+			fields[k] = v
+		}
+	}
+	return fields
+}
+
+func hmacSHA256(key []byte, msg string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(msg))
+	return h.Sum(nil)
+}
@@ -0,0 +1,353 @@
+package mongostore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/mongo"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+// fakeDB is an in-memory stand-in for the database, understanding only the commands Store
+// runs. Documents go through BSON on the way in and out.
+type fakeDB struct {
+	mu      sync.Mutex
+	docs    []mongo.D
+	indexes int
+	// dropped lists the indexes dropped, and language is the default language of the text
+	// index created.
+	dropped  []string
+	language string
+	// fail, if set, fails every command.
+	fail error
+}
+
+func (db *fakeDB) Run(ctx context.Context, cmd mongo.D) (mongo.D, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.fail != nil {
+		return nil, db.fail
+	}
+	data, err := mongo.Marshal(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if cmd, err = mongo.Unmarshal(data); err != nil {
+		return nil, err
+	}
+	ok := mongo.D{{"ok", 1.0}}
+	filter, _ := cmd.Get("filter").(mongo.D)
+	switch cmd[0].Key {
+	case "dropIndexes":
+		db.dropped = append(db.dropped, fmt.Sprint(cmd.Get("index")))
+		return nil, &mongo.Error{Code: mongo.CodeIndexNotFound, Message: "index not found"}
+	case "createIndexes":
+		indexes, _ := cmd.Get("indexes").([]interface{})
+		db.indexes = len(indexes)
+		for _, index := range indexes {
+			if language, ok := index.(mongo.D).Get("default_language").(string); ok {
+				db.language = language
+			}
+		}
+		return ok, nil
+	case "find":
+		docs := db.find(filter)
+		if sortBy, _ := cmd.Get("sort").(mongo.D); len(sortBy) > 0 {
+			sort.SliceStable(docs, func(i, j int) bool {
+				for _, key := range sortBy {
+					a, b := float64(mongo.Int(docs[i].Get(key.Key))), float64(mongo.Int(docs[j].Get(key.Key)))
+					if key.Key == "score" {
+						a, b = -score(docs[i], filter), -score(docs[j], filter)
+					} else if mongo.Int(key.Value) < 0 {
+						a, b = -a, -b
+					}
+					if a != b {
+						return a < b
+					}
+				}
+				return false
+			})
+		}
+		if limit := int(mongo.Int(cmd.Get("limit"))); limit > 0 && len(docs) > limit {
+			docs = docs[:limit]
+		}
+		batch := make([]interface{}, len(docs))
+		for i, d := range docs {
+			batch[i] = d
+		}
+		return append(ok, mongo.E{"cursor", mongo.D{{"firstBatch", batch}, {"id", int64(0)}}}), nil
+	case "insert":
+		docs, _ := cmd.Get("documents").([]interface{})
+		doc := docs[0].(mongo.D)
+		for _, d := range db.docs {
+			for _, key := range []string{"_id", "slug"} {
+				if d.Get(key) == doc.Get(key) {
+					return append(ok, mongo.E{"writeErrors", []interface{}{mongo.D{
+						{"code", int32(mongo.CodeDuplicateKey)}, {"errmsg", "E11000 duplicate key error dup key: " + key},
+					}}}), nil
+				}
+			}
+		}
+		db.docs = append(db.docs, doc)
+		return append(ok, mongo.E{"n", int32(1)}), nil
+	case "update", "delete":
+		ops, _ := cmd.Get(cmd[0].Key + "s").([]interface{})
+		op := ops[0].(mongo.D)
+		q, _ := op.Get("q").(mongo.D)
+		for i, d := range db.docs {
+			if d.Get("_id") == q.Get("_id") {
+				if cmd[0].Key == "update" {
+					db.docs[i] = op.Get("u").(mongo.D)
+				} else {
+					db.docs = append(db.docs[:i], db.docs[i+1:]...)
+				}
+				return append(ok, mongo.E{"n", int32(1)}), nil
+			}
+		}
+		return append(ok, mongo.E{"n", int32(0)}), nil
+	}
+	return nil, fmt.Errorf("unexpected command %v", cmd)
+}
+
+// find returns the documents matching filter: everything, an _id or slug, a field of the
+// documents in an array such as merged_from.id, or a $text search, possibly narrowed by $in and
+// $ne conditions.
+func (db *fakeDB) find(filter mongo.D) []mongo.D {
+	var docs []mongo.D
+	for _, d := range db.docs {
+		match := true
+		for _, e := range filter {
+			cond, _ := e.Value.(mongo.D)
+			switch {
+			case e.Key == "$text":
+				match = match && score(d, filter) > 0
+			case len(cond) > 0 && cond[0].Key == "$in":
+				values, _ := cond[0].Value.([]interface{})
+				match = match && slices.Contains(values, d.Get(e.Key))
+			case len(cond) > 0 && cond[0].Key == "$ne":
+				match = match && d.Get(e.Key) != cond[0].Value
+			case strings.Contains(e.Key, "."):
+				array, field, _ := strings.Cut(e.Key, ".")
+				elems, _ := d.Get(array).([]interface{})
+				match = match && slices.ContainsFunc(elems, func(elem interface{}) bool {
+					sub, _ := elem.(mongo.D)
+					return sub.Get(field) == e.Value
+				})
+			default:
+				match = match && d.Get(e.Key) == e.Value
+			}
+		}
+		if match {
+			docs = append(docs, d)
+		}
+	}
+	return docs
+}
+
+// score counts the words of a $text search found in the title, generation query or ingredients
+// of doc, counting title and query words five times like the index's weights.
+func score(doc, filter mongo.D) float64 {
+	text, _ := filter.Get("$text").(mongo.D)
+	search, _ := text.Get("$search").(string)
+	title, _ := doc.Get("title").(string)
+	if provenance, ok := doc.Get("provenance").(mongo.D); ok {
+		query, _ := provenance.Get("query").(string)
+		title += " " + query
+	}
+	ingredients := fmt.Sprint(doc.Get("ingredients"))
+	n := 0.0
+	for _, w := range strings.Fields(strings.ToLower(search)) {
+		w = strings.Trim(w, "!?.,")
+		if strings.Contains(strings.ToLower(title), w) {
+			n += 5
+		}
+		if strings.Contains(strings.ToLower(ingredients), w) {
+			n++
+		}
+	}
+	return n
+}
+
+// newTestStore returns a Store over an empty fake database, with a silent logger.
+func newTestStore(t *testing.T) (*Store, *fakeDB) {
+	db := &fakeDB{}
+	s := New(db)
+	s.Logger = log.New(io.Discard, "", 0)
+	if err := s.EnsureIndexes(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return s, db
+}
+
+// TestStore verifies that recipes round-trip through the collection with unique, stable slugs
+// and nested nutrition, and that updates, archiving and deletions are reported to OnChange.
+func TestStore(t *testing.T) {
+	s, db := newTestStore(t)
+	if db.indexes != 5 || db.language != "none" || fmt.Sprint(db.dropped) != "[title_ingredients_text search_text]" {
+		t.Errorf("Expected five indexes, the text index unstemmed, replacing the earlier ones, got %d (%q), dropping %v", db.indexes, db.language, db.dropped)
+	}
+	var changed []string
+	s.OnChange = func(ids []string) { changed = append(changed, ids...) }
+	first := model.NewRecipe("Pancakes", []string{"flour"}, []string{"Fry."}, map[string]int{"calories": 350}, "", nil)
+	second := model.NewRecipe("Pancakes!", nil, nil, nil, "", nil)
+	soup := model.NewRecipe("Tomato Soup", nil, nil, nil, "", nil)
+	if err := s.Add(first, second, soup); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(first); !errors.Is(err, resolver.ErrExists) {
+		t.Errorf("Expected adding a stored ID to fail with ErrExists, got %v", err)
+	}
+
+	all := s.All()
+	if len(all) != 3 || all[0].ID != first.ID || all[0].Ingredients[0] != "flour" || !all[0].CreatedAt.Equal(first.CreatedAt) {
+		t.Fatalf("Expected the three recipes in order, got %+v", all)
+	}
+	if calories, ok := model.NutrientValue(all[0].NutritionalInfo, "calories"); !ok || calories != 350 {
+		t.Errorf("Expected the nutrition to round-trip, got %v", all[0].NutritionalInfo)
+	}
+	if got, ok := s.BySlug("pancakes-2"); !ok || got.ID != second.ID {
+		t.Errorf("Expected the second pancakes recipe at pancakes-2, got %+v", got)
+	}
+
+	first.Title, first.Slug = "Fluffy Pancakes", "pancakes-2"
+	if !s.Update(first) {
+		t.Fatal("Expected the update to find the recipe")
+	}
+	if got, _ := s.Get(first.ID); got.Title != "Fluffy Pancakes" || got.Slug != "pancakes" {
+		t.Errorf("Expected the new title under the old slug, got %+v", got)
+	}
+	if all := s.All(); all[0].ID != first.ID {
+		t.Errorf("Expected the updated recipe to keep its place, got %+v", all)
+	}
+	if s.Update(model.NewRecipe("Missing", nil, nil, nil, "", nil)) {
+		t.Error("Expected no update for an unknown recipe")
+	}
+	if n := s.Archive(true, soup.ID, "missing"); n != 1 {
+		t.Errorf("Expected one recipe archived, got %d", n)
+	}
+	if got, _ := s.Get(soup.ID); !got.Archived {
+		t.Error("Expected the soup to be archived")
+	}
+	if n := s.Delete(second.ID, second.ID); n != 1 || len(s.All()) != 2 {
+		t.Errorf("Expected one recipe deleted, got %d", n)
+	}
+	if want := []string{first.ID, soup.ID, second.ID}; fmt.Sprint(changed) != fmt.Sprint(want) {
+		t.Errorf("Expected changes %v, got %v", want, changed)
+	}
+}
+
+// TestStoreListSave verifies that the store is a complete Storage, listing the recipes a filter
+// keeps in order and saving new and stored recipes.
+func TestStoreListSave(t *testing.T) {
+	s, _ := newTestStore(t)
+	var st resolver.Storage = s
+	ctx := context.Background()
+	soup := model.NewRecipe("Tomato Soup", nil, nil, nil, "", nil)
+	pie := model.NewRecipe("Chicken Pie", nil, nil, nil, "", nil)
+	pie.Status = model.StatusPendingReview
+	stew := model.NewRecipe("Beef Stew", nil, nil, nil, "", nil)
+	for _, r := range []model.Recipe{soup, pie, stew} {
+		if err := st.Save(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stew.Archived = true
+	if err := st.Save(ctx, stew); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := st.Get(stew.ID); !ok || !got.Archived || got.Slug != "beef-stew" {
+		t.Errorf("Expected the stew saved over, got %+v", got)
+	}
+
+	titles := func(f resolver.Filter) string {
+		recipes, err := st.List(ctx, f)
+		var out []string
+		for _, r := range recipes {
+			out = append(out, r.Title)
+		}
+		return fmt.Sprint(out, err)
+	}
+	if got := titles(resolver.Filter{}); got != "[Tomato Soup Chicken Pie Beef Stew] <nil>" {
+		t.Errorf("Expected every recipe in order, got %s", got)
+	}
+	if got := titles(resolver.Filter{Statuses: []model.Status{model.StatusPublished}, Unarchived: true}); got != "[Tomato Soup] <nil>" {
+		t.Errorf("Expected the published unarchived recipes, got %s", got)
+	}
+	if got := titles(resolver.Filter{Statuses: []model.Status{model.StatusPendingReview}}); got != "[Chicken Pie] <nil>" {
+		t.Errorf("Expected the recipes pending review, got %s", got)
+	}
+}
+
+// TestStoreMergedInto verifies that the recipe another was merged into is found by the merged
+// recipe's ID or slug.
+func TestStoreMergedInto(t *testing.T) {
+	s, _ := newTestStore(t)
+	soup := model.NewRecipe("Tomato Soup", nil, nil, nil, "", nil)
+	soup.MergedFrom = []model.MergedRecipe{{ID: "r1", Slug: "tomato-bisque"}, {ID: "r2", Slug: "gazpacho"}}
+	s.Add(soup, model.NewRecipe("Pancakes", nil, nil, nil, "", nil))
+	if got, ok := s.MergedInto("r2", ""); !ok || got.ID != soup.ID {
+		t.Errorf("Expected r2 merged into the soup, got %+v", got)
+	}
+	if got, ok := s.MergedInto("", "tomato-bisque"); !ok || got.ID != soup.ID {
+		t.Errorf("Expected tomato-bisque merged into the soup, got %+v", got)
+	}
+	for _, tt := range [][2]string{{"r3", ""}, {"", ""}, {"tomato-bisque", ""}} {
+		if got, ok := s.MergedInto(tt[0], tt[1]); ok {
+			t.Errorf("MergedInto(%q, %q) = %+v, want none", tt[0], tt[1], got)
+		}
+	}
+}
+
+// TestStoreSearch verifies that searches return the best text matches first, and find persisted
+// generations by their query.
+func TestStoreSearch(t *testing.T) {
+	s, db := newTestStore(t)
+	s.Add(
+		model.NewRecipe("Chicken Soup", []string{"chicken", "carrots"}, nil, nil, "", nil),
+		model.NewRecipe("Tomato Soup", []string{"tomatoes"}, nil, nil, "", nil),
+		model.NewRecipe("Chicken Tomato Stew", nil, nil, nil, "", nil),
+		model.NewRecipe("Pancakes", nil, nil, nil, "", nil),
+	)
+	got, err := s.Search(context.Background(), "Tomato soup!", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Title != "Tomato Soup" || got[1].Title != "Chicken Soup" {
+		t.Errorf("Expected Tomato Soup then Chicken Soup, got %+v", got)
+	}
+	if got, err := s.Search(context.Background(), "  ", 2); err != nil || len(got) != 0 {
+		t.Errorf("Expected nothing for an empty query, got %+v, %v", got, err)
+	}
+
+	stew := model.NewRecipe("Beef Stew", []string{"beef"}, nil, nil, "", nil)
+	stew.Provenance = &model.Provenance{Origin: model.OriginGenerated, Query: "something warming"}
+	s.Add(stew)
+	if got, _ := s.Search(context.Background(), "something warming", 0); len(got) != 1 || got[0].ID != stew.ID {
+		t.Errorf("Expected a persisted generation found by its query, got %+v", got)
+	}
+	s.Archive(true, stew.ID)
+	servable := resolver.Filter{Statuses: []model.Status{model.StatusPublished}, Unarchived: true}
+	if got, _ := s.SearchFiltered(context.Background(), "something warming", 0, servable); len(got) != 0 {
+		t.Errorf("Expected an archived recipe filtered out, got %+v", got)
+	}
+	if got, _ := s.SearchFiltered(context.Background(), "soup", 1, servable); len(got) != 1 || got[0].Title != "Chicken Soup" {
+		t.Errorf("Expected recipes without a status kept as published, got %+v", got)
+	}
+
+	db.fail = errors.New("connection refused")
+	if _, err := s.Search(context.Background(), "soup", 2); err == nil {
+		t.Error("Expected search errors to be returned")
+	}
+	if s.All() != nil {
+		t.Error("Expected no recipes when the database fails")
+	}
+}
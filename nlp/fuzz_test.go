@@ -0,0 +1,48 @@
+package nlp
+
+import (
+	"strings"
+	"testing"
+)
+
+// FuzzTokenize verifies that tokens are never empty, never contain whitespace and are
+// already lower-case, for arbitrary (including invalid UTF-8) input.
+func FuzzTokenize(f *testing.F) {
+	for _, seed := range []string{"Hello World", "chicken, rice & peas", "Crème Brûlée", "stir-fry", "\xff\xfe", "  ", "ÇAĞ İstanbul"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		for _, tok := range Tokenize(s) {
+			if tok == "" {
+				t.Fatalf("Tokenize(%q) produced an empty token", s)
+			}
+			if strings.ContainsAny(tok, " \t\n\r") {
+				t.Fatalf("Tokenize(%q) produced token %q containing whitespace", s, tok)
+			}
+			if strings.ToLower(tok) != tok {
+				t.Fatalf("Tokenize(%q) produced non-lower-case token %q", s, tok)
+			}
+		}
+	})
+}
+
+// FuzzJaccardSimilarity verifies that the similarity is within [0, 1], symmetric, and 1 for
+// any string with at least one token compared against itself.
+func FuzzJaccardSimilarity(f *testing.F) {
+	f.Add("chicken salad", "salad with chicken")
+	f.Add("", "")
+	f.Add("Pâtes", "PÂTES")
+	f.Add("a a a", "a")
+	f.Fuzz(func(t *testing.T, a, b string) {
+		sim := JaccardSimilarity(a, b)
+		if sim < 0 || sim > 1 || sim != sim {
+			t.Fatalf("JaccardSimilarity(%q, %q) = %f, want a value in [0, 1]", a, b, sim)
+		}
+		if rev := JaccardSimilarity(b, a); rev != sim {
+			t.Fatalf("JaccardSimilarity is not symmetric for %q, %q: %f vs %f", a, b, sim, rev)
+		}
+		if len(Tokenize(a)) > 0 && JaccardSimilarity(a, a) != 1 {
+			t.Fatalf("JaccardSimilarity(%q, %q) = %f, want 1", a, a, JaccardSimilarity(a, a))
+		}
+	})
+}
@@ -1,13 +1,51 @@
 package nlp
 
 import (
+	"hash/fnv"
+	"math"
 	"strings"
+	"unicode"
 )
 
 // Tokenize converts a string to lowercase and splits it into words.
 // This simple NLP step helps in comparing the similarity between queries and recipe titles.
+// Words are runs of letters, digits and combining marks, so punctuation such as the comma in
+// "chicken, rice" or the hyphen in "stir-fry" separates tokens rather than sticking to them.
 func Tokenize(s string) []string {
-	return strings.Fields(strings.ToLower(s))
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && !unicode.IsMark(r)
+	})
+}
+
+// tokenSet returns the set of distinct tokens in s.
+func tokenSet(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, token := range Tokenize(s) {
+		set[token] = true
+	}
+	return set
+}
+
+// OverlapCoefficient computes the Szymkiewicz–Simpson overlap coefficient between two strings:
+// the size of the intersection of their token sets divided by the size of the smaller set.
+// Unlike Jaccard similarity it is 1 whenever one string's tokens are a subset of the other's.
+func OverlapCoefficient(a, b string) float64 {
+	setA, setB := tokenSet(a), tokenSet(b)
+	smaller := len(setA)
+	if len(setB) < smaller {
+		smaller = len(setB)
+	}
+	if smaller == 0 {
+		return 0.0
+	}
+
+	intersectionCount := 0
+	for token := range setA {
+		if setB[token] {
+			intersectionCount++
+		}
+	}
+	return float64(intersectionCount) / float64(smaller)
 }
 
 // JaccardSimilarity computes the Jaccard similarity coefficient between two strings.
@@ -47,3 +85,53 @@ func JaccardSimilarity(a, b string) float64 {
 	}
 	return float64(intersectionCount) / float64(unionCount)
 }
+
+// TrigramDims is the length of the vectors returned by TrigramVector.
+const TrigramDims = 512
+
+// TrigramVector embeds s as a bag of character trigrams hashed into TrigramDims dimensions and
+// normalized to unit length. Each token is padded with spaces before slicing, so word order
+// does not matter and small spelling differences ("soup" and "soups") only move a few
+// trigrams. It returns nil if s has no tokens.
+func TrigramVector(s string) []float64 {
+	tokens := Tokenize(s)
+	if len(tokens) == 0 {
+		return nil
+	}
+	v := make([]float64, TrigramDims)
+	for _, token := range tokens {
+		runes := []rune("  " + token + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			h := fnv.New32a()
+			h.Write([]byte(string(runes[i : i+3])))
+			v[h.Sum32()%TrigramDims]++
+		}
+	}
+	norm := 0.0
+	for _, x := range v {
+		norm += x * x
+	}
+	norm = math.Sqrt(norm)
+	for i := range v {
+		v[i] /= norm
+	}
+	return v
+}
+
+// CosineSimilarity returns the cosine of the angle between two vectors of equal length, or 0 if
+// either is zero or their lengths differ.
+func CosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	dot, na, nb := 0.0, 0.0, 0.0
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
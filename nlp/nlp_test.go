@@ -31,3 +31,34 @@ func TestJaccardSimilarity(t *testing.T) {
 		t.Errorf("Expected similarity around %f, got %f", expected, sim)
 	}
 }
+
+// TestOverlapCoefficient verifies that a query whose tokens are a subset of the title scores 1.
+func TestOverlapCoefficient(t *testing.T) {
+	if sim := OverlapCoefficient("bolognese", "Spaghetti Bolognese"); sim != 1 {
+		t.Errorf("Expected overlap coefficient 1, got %f", sim)
+	}
+	if sim := OverlapCoefficient("chicken soup", "chicken salad"); sim != 0.5 {
+		t.Errorf("Expected overlap coefficient 0.5, got %f", sim)
+	}
+	if sim := OverlapCoefficient("", "chicken salad"); sim != 0 {
+		t.Errorf("Expected overlap coefficient 0 for an empty string, got %f", sim)
+	}
+}
+
+// TestTrigramVector verifies that near-identical strings embed close together and different
+// dishes do not.
+func TestTrigramVector(t *testing.T) {
+	soup := TrigramVector("chicken noodle soup")
+	if sim := CosineSimilarity(soup, TrigramVector("Soup, chicken noodle")); sim < 0.999 {
+		t.Errorf("Expected word order not to matter, got similarity %f", sim)
+	}
+	if sim := CosineSimilarity(soup, TrigramVector("chicken noodle soups")); sim < 0.9 {
+		t.Errorf("Expected a plural to stay close, got similarity %f", sim)
+	}
+	if sim := CosineSimilarity(soup, TrigramVector("beef noodle soup")); sim > 0.8 {
+		t.Errorf("Expected a different dish to be further away, got similarity %f", sim)
+	}
+	if TrigramVector("  ") != nil {
+		t.Error("Expected no vector for an empty string")
+	}
+}
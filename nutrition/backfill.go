@@ -0,0 +1,153 @@
+package nutrition
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// DefaultInterval is the default minimum delay between two estimator calls of a Backfill, which
+// keeps a run over a large corpus within typical LLM provider rate limits.
+const DefaultInterval = time.Second
+
+// Store is the recipe store a Backfill reads and updates.
+type Store interface {
+	All() []model.Recipe
+	Update(r model.Recipe) bool
+}
+
+// Progress reports the state of a Backfill run.
+type Progress struct {
+	Running bool `json:"running"`
+	// Total is the number of recipes that lacked structured nutrition when the run started.
+	Total     int `json:"total"`
+	Processed int `json:"processed"`
+	Updated   int `json:"updated"`
+	Failed    int `json:"failed"`
+	// LastError describes the most recent failure, if any.
+	LastError  string     `json:"last_error,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Backfill estimates nutrition for every stored recipe whose nutritional info is not
+// Structured. At most one run is in progress at a time. It is safe for concurrent use.
+type Backfill struct {
+	Store     Store
+	Estimator Estimator
+	// Interval is the minimum delay between two estimator calls.
+	Interval time.Duration
+	Logger   *log.Logger
+
+	mu       sync.Mutex
+	progress Progress
+}
+
+// NewBackfill returns a Backfill over store using estimator, with DefaultInterval and the
+// standard logger.
+func NewBackfill(store Store, estimator Estimator) *Backfill {
+	return &Backfill{Store: store, Estimator: estimator, Interval: DefaultInterval, Logger: log.Default()}
+}
+
+// Progress returns the state of the current or most recent run.
+func (b *Backfill) Progress() Progress {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.progress
+}
+
+// Start begins a run in the background and returns its initial progress. It returns false,
+// without starting anything, if a run is already in progress. The run stops early when ctx is
+// done.
+func (b *Backfill) Start(ctx context.Context) (Progress, bool) {
+	pending, ok := b.begin()
+	if !ok {
+		return b.Progress(), false
+	}
+	go b.run(ctx, pending)
+	return b.Progress(), true
+}
+
+// Run performs a run synchronously and returns its final progress, or false if another run is
+// already in progress.
+func (b *Backfill) Run(ctx context.Context) (Progress, bool) {
+	pending, ok := b.begin()
+	if !ok {
+		return b.Progress(), false
+	}
+	b.run(ctx, pending)
+	return b.Progress(), true
+}
+
+// begin marks a run as started and returns the recipes it has to process.
+func (b *Backfill) begin() ([]model.Recipe, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.progress.Running {
+		return nil, false
+	}
+	var pending []model.Recipe
+	for _, r := range b.Store.All() {
+		if !Structured(r.NutritionalInfo) {
+			pending = append(pending, r)
+		}
+	}
+	now := time.Now().UTC()
+	b.progress = Progress{Running: true, Total: len(pending), StartedAt: &now}
+	return pending, true
+}
+
+func (b *Backfill) run(ctx context.Context, pending []model.Recipe) {
+	b.Logger.Printf("Nutrition: Backfilling %d recipes", len(pending))
+	var last time.Time
+	for _, r := range pending {
+		if wait := b.Interval - time.Since(last); wait > 0 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(wait):
+			}
+		}
+		if ctx.Err() != nil {
+			b.record(ctx.Err())
+			break
+		}
+		last = time.Now()
+		estimates, err := b.Estimator.Estimate(ctx, r)
+		if err == nil {
+			r.NutritionalInfo = Merge(r.NutritionalInfo, estimates)
+			r.UpdatedAt = time.Now().UTC()
+			b.Store.Update(r)
+		} else {
+			b.Logger.Printf("Nutrition: Estimating %q failed: %v", r.Title, err)
+		}
+		b.record(err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now().UTC()
+	b.progress.Running = false
+	b.progress.FinishedAt = &now
+	b.Logger.Printf("Nutrition: Backfill finished; %d of %d recipes updated", b.progress.Updated, b.progress.Total)
+}
+
+// record counts one processed recipe, which failed if err is non-nil. Cancellation is noted
+// without counting a recipe.
+func (b *Backfill) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err != nil {
+		b.progress.LastError = err.Error()
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return
+		}
+		b.progress.Failed++
+	} else {
+		b.progress.Updated++
+	}
+	b.progress.Processed++
+}
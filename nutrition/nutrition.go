@@ -0,0 +1,115 @@
+// Package nutrition fills in structured nutritional information for recipes that lack it, such
+// as legacy corpus entries and imported collections.
+package nutrition
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pageza/recipe-resolver-ms/generation"
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// Keys are the nutrients a recipe needs, as numbers, for its nutritional info to count as
+// structured: calories in kcal, the rest in grams per serving.
+var Keys = model.MacroNutrients
+
+// Structured reports whether info holds a numeric value for every one of Keys.
+func Structured(info interface{}) bool {
+	return Completeness(info) == 1
+}
+
+// Completeness returns the fraction of Keys for which info holds a numeric value, from 0 for
+// none to 1 for structured info.
+func Completeness(info interface{}) float64 {
+	m := asMap(info)
+	n := 0
+	for _, k := range Keys {
+		switch m[k].(type) {
+		case float64, int:
+			n++
+		}
+	}
+	return float64(n) / float64(len(Keys))
+}
+
+// asMap returns nutritional info as a map, accepting both decoded JSON and the map[string]int
+// used by recipes built in Go code.
+func asMap(info interface{}) map[string]interface{} {
+	switch typed := info.(type) {
+	case map[string]interface{}:
+		return typed
+	case map[string]int:
+		m := make(map[string]interface{}, len(typed))
+		for k, v := range typed {
+			m[k] = v
+		}
+		return m
+	}
+	return nil
+}
+
+// Merge returns info with the estimates added for the nutrients it lacks. Existing values win,
+// since they usually come from the recipe's author.
+func Merge(info interface{}, estimates map[string]float64) map[string]interface{} {
+	out := make(map[string]interface{})
+	for k, v := range estimates {
+		out[k] = v
+	}
+	for k, v := range asMap(info) {
+		out[k] = v
+	}
+	return out
+}
+
+// Estimator estimates the nutritional information of a recipe, keyed by nutrient.
+type Estimator interface {
+	Estimate(ctx context.Context, r model.Recipe) (map[string]float64, error)
+}
+
+// EstimatorFunc adapts a function to the Estimator interface.
+type EstimatorFunc func(ctx context.Context, r model.Recipe) (map[string]float64, error)
+
+// Estimate implements Estimator.
+func (f EstimatorFunc) Estimate(ctx context.Context, r model.Recipe) (map[string]float64, error) {
+	return f(ctx, r)
+}
+
+// LLMEstimator estimates nutrition with the configured LLM provider (see
+// generation.EstimateNutrition).
+var LLMEstimator Estimator = EstimatorFunc(func(ctx context.Context, r model.Recipe) (map[string]float64, error) {
+	return generation.EstimateNutrition(ctx, r.Title, r.Ingredients)
+})
+
+// Chain returns an Estimator that tries each estimator in turn and returns the first successful
+// estimate, e.g. an ingredient-database engine with LLMEstimator as its fallback.
+func Chain(estimators ...Estimator) Estimator {
+	return EstimatorFunc(func(ctx context.Context, r model.Recipe) (map[string]float64, error) {
+		var errs []error
+		for _, e := range estimators {
+			estimates, err := e.Estimate(ctx, r)
+			if err == nil {
+				return estimates, nil
+			}
+			errs = append(errs, err)
+		}
+		if len(errs) == 0 {
+			return nil, errors.New("no nutrition estimator configured")
+		}
+		return nil, errors.Join(errs...)
+	})
+}
+
+// Complete returns r with the nutrients its nutritional info lacks estimated by e. Recipes with
+// structured nutrition are returned unchanged.
+func Complete(ctx context.Context, e Estimator, r model.Recipe) (model.Recipe, error) {
+	if Structured(r.NutritionalInfo) {
+		return r, nil
+	}
+	estimates, err := e.Estimate(ctx, r)
+	if err != nil {
+		return r, err
+	}
+	r.NutritionalInfo = Merge(r.NutritionalInfo, estimates)
+	return r, nil
+}
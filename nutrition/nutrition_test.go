@@ -0,0 +1,76 @@
+package nutrition
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+func TestStructured(t *testing.T) {
+	tests := []struct {
+		info         interface{}
+		want         bool
+		completeness float64
+	}{
+		{nil, false, 0},
+		{map[string]int{"calories": 400}, false, 0.25},
+		{map[string]int{"calories": 400, "protein": 20, "carbohydrates": 50, "fat": 10}, true, 1},
+		{map[string]interface{}{"calories": 400.0, "protein": "20 g", "carbohydrates": 50.0, "fat": 10.0}, false, 0.75},
+		{map[string]interface{}{"calories": 400.0, "protein": 20.0, "carbohydrates": 50.0, "fat": 10.0}, true, 1},
+	}
+	for _, tt := range tests {
+		if got := Structured(tt.info); got != tt.want {
+			t.Errorf("Structured(%v) = %v, want %v", tt.info, got, tt.want)
+		}
+		if got := Completeness(tt.info); got != tt.completeness {
+			t.Errorf("Completeness(%v) = %v, want %v", tt.info, got, tt.completeness)
+		}
+	}
+}
+
+func TestBackfill(t *testing.T) {
+	complete := model.NewRecipe("Complete", nil, nil, map[string]int{"calories": 1, "protein": 1, "carbohydrates": 1, "fat": 1}, "", nil)
+	legacy := model.NewRecipe("Legacy", nil, nil, map[string]int{"calories": 620}, "", nil)
+	broken := model.NewRecipe("Broken", nil, nil, nil, "", nil)
+	store := resolver.NewMemoryStore([]model.Recipe{complete, legacy, broken})
+
+	calls := 0
+	b := NewBackfill(store, Chain(
+		EstimatorFunc(func(ctx context.Context, r model.Recipe) (map[string]float64, error) {
+			return nil, errors.New("engine has no data")
+		}),
+		EstimatorFunc(func(ctx context.Context, r model.Recipe) (map[string]float64, error) {
+			calls++
+			if r.Title == "Broken" {
+				return nil, errors.New("provider unavailable")
+			}
+			return map[string]float64{"calories": 500, "protein": 20, "carbohydrates": 60, "fat": 25}, nil
+		}),
+	))
+	b.Interval = 0
+	b.Logger = log.New(io.Discard, "", 0)
+
+	p, ok := b.Run(context.Background())
+	if !ok {
+		t.Fatal("Expected the run to start")
+	}
+	if p.Running || p.Total != 2 || p.Processed != 2 || p.Updated != 1 || p.Failed != 1 || calls != 2 {
+		t.Errorf("Unexpected progress %+v after %d estimator calls", p, calls)
+	}
+	for _, r := range store.All() {
+		if r.ID != legacy.ID {
+			continue
+		}
+		if !Structured(r.NutritionalInfo) {
+			t.Errorf("Expected the legacy recipe to be backfilled, got %v", r.NutritionalInfo)
+		}
+		if r.NutritionalInfo.(map[string]interface{})["calories"] != 620 {
+			t.Errorf("Expected the existing calories to be kept, got %v", r.NutritionalInfo)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Package occasion recognises holidays and other occasions in queries, such as Thanksgiving or
+// Lunar New Year, so that recipes for them can be preferred and generated with them in mind.
+package occasion
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/nlp"
+)
+
+// Occasion is an entry of a Set.
+type Occasion struct {
+	// Name identifies the occasion, e.g. "lunar-new-year"; recipes for it carry the tag
+	// model.OccasionTag(Name).
+	Name string `json:"name"`
+	// Aliases are the phrases that mention the occasion in a query, e.g. "chinese new year".
+	Aliases []string `json:"aliases"`
+	// Guidance is the instruction added to generation prompts for the occasion.
+	Guidance string `json:"guidance"`
+}
+
+// Tag returns the tag marking a recipe as suited to o.
+func (o Occasion) Tag() string {
+	return model.OccasionTag(o.Name)
+}
+
+// Set is a list of known occasions.
+type Set []Occasion
+
+//go:embed occasions.json
+var defaultSet []byte
+
+// Default returns the bundled set, which covers major holidays and festivals such as
+// Thanksgiving, Christmas, Lunar New Year, Ramadan, Diwali and Passover.
+func Default() Set {
+	s, err := parse(defaultSet)
+	if err != nil {
+		panic("occasion: bundled set is invalid: " + err.Error())
+	}
+	return s
+}
+
+// Load reads a set from a JSON file of the form
+// [{"name": "diwali", "aliases": ["diwali", "deepavali"], "guidance": "..."}].
+func Load(path string) (Set, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing occasions %s: %w", path, err)
+	}
+	return s, nil
+}
+
+func parse(data []byte) (Set, error) {
+	var s Set
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for i, o := range s {
+		name := strings.ToLower(strings.TrimSpace(o.Name))
+		if name == "" {
+			return nil, fmt.Errorf("occasion %d: missing name", i)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%s: duplicate occasion", name)
+		}
+		if len(o.Aliases) == 0 {
+			return nil, fmt.Errorf("%s: no aliases", name)
+		}
+		seen[name] = true
+		s[i].Name = name
+	}
+	return s, nil
+}
+
+// Detect returns the occasions text mentions, in the order of the set. An alias matches whole
+// words, ignoring case and punctuation, so "valentine's" matches "Valentine's Day" but "tet"
+// does not match "tetrazzini".
+func (s Set) Detect(text string) []Occasion {
+	words := " " + strings.Join(nlp.Tokenize(text), " ") + " "
+	var found []Occasion
+	for _, o := range s {
+		for _, alias := range o.Aliases {
+			phrase := strings.Join(nlp.Tokenize(alias), " ")
+			if phrase != "" && strings.Contains(words, " "+phrase+" ") {
+				found = append(found, o)
+				break
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,57 @@
+package occasion
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	set := Default()
+	for _, tc := range []struct {
+		text string
+		want []string
+	}{
+		{"Chinese New Year dumplings", []string{"lunar-new-year"}},
+		{"dessert for Valentine's Day", []string{"valentines-day"}},
+		{"iftar soup", []string{"ramadan"}},
+		{"christmas or hanukkah dinner", []string{"christmas", "hanukkah"}},
+		{"turkey tetrazzini", nil},
+		{"weeknight pasta", nil},
+	} {
+		got := set.Detect(tc.text)
+		if len(got) != len(tc.want) {
+			t.Errorf("Detect(%q) = %+v, want %v", tc.text, got, tc.want)
+			continue
+		}
+		for i, o := range got {
+			if o.Name != tc.want[i] {
+				t.Errorf("Detect(%q)[%d] = %q, want %q", tc.text, i, o.Name, tc.want[i])
+			}
+		}
+	}
+	if tag := set.Detect("diwali sweets")[0].Tag(); tag != "occasion:diwali" {
+		t.Errorf("Expected the tag occasion:diwali, got %q", tag)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "occasions.json")
+	if err := os.WriteFile(path, []byte(`[{"name": " Nowruz ", "aliases": ["nowruz", "persian new year"]}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	set, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := set.Detect("persian new year feast"); len(got) != 1 || got[0].Name != "nowruz" {
+		t.Errorf("Expected nowruz, got %+v", got)
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"name": "nowruz"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Expected an error for an occasion without aliases")
+	}
+}
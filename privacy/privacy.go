@@ -0,0 +1,32 @@
+// Package privacy removes personal details from free-text queries before they are stored.
+package privacy
+
+import "regexp"
+
+// rule replaces every match of pattern with replacement (which may use $1-style references).
+type rule struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// relations are words after which a capitalized word is taken to be a person's name, as in
+// "my daughter Emma's nut allergy".
+const relations = `daughter|son|kid|child|wife|husband|partner|girlfriend|boyfriend|mom|mum|mother|dad|father|` +
+	`sister|brother|friend|grandma|grandmother|grandpa|grandfather|aunt|uncle|niece|nephew|cousin|boss|colleague|neighbor|neighbour`
+
+var rules = []rule{
+	{regexp.MustCompile(`[\w.+-]+@[\w-]+(\.[\w-]+)+`), "[email]"},
+	{regexp.MustCompile(`\+?\d[\d\s().-]{7,}\d`), "[phone]"},
+	{regexp.MustCompile(`(?i:\b(` + relations + `))(\s+)\p{Lu}\p{Ll}+`), "$1$2[name]"},
+	{regexp.MustCompile(`(?i:\b(my name is|i am|i'm|for))(\s+)\p{Lu}\p{Ll}+(\s+\p{Lu}\p{Ll}+)?\b`), "$1$2[name]"},
+}
+
+// Scrub replaces e-mail addresses, phone numbers and likely personal names in s with
+// placeholders such as "[name]". Names are recognized by context ("my son Max", "for Emma"),
+// so the rest of the query stays useful for analysis.
+func Scrub(s string) string {
+	for _, r := range rules {
+		s = r.pattern.ReplaceAllString(s, r.replacement)
+	}
+	return s
+}
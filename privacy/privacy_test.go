@@ -0,0 +1,21 @@
+package privacy
+
+import "testing"
+
+func TestScrub(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"recipe for my daughter Emma's nut allergy", "recipe for my daughter [name]'s nut allergy"},
+		{"birthday cake for Liam", "birthday cake for [name]"},
+		{"dinner my name is Jane Doe", "dinner my name is [name]"},
+		{"send to jane.doe+food@example.com please", "send to [email] please"},
+		{"call +1 (555) 123-4567 about the cake", "call [phone] about the cake"},
+		// Nothing personal: lower-case words after "for" and quantities are untouched.
+		{"pasta for two with 200 g flour", "pasta for two with 200 g flour"},
+		{"chicken salad", "chicken salad"},
+	}
+	for _, tt := range tests {
+		if got := Scrub(tt.in); got != tt.want {
+			t.Errorf("Scrub(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
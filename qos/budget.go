@@ -0,0 +1,128 @@
+package qos
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Remaining is what is left of a session's budget.
+type Remaining struct {
+	Calls  int `json:"calls"`
+	Tokens int `json:"tokens"`
+}
+
+// SessionBudget bounds the generation spend of each session, e.g. of a user refining a query
+// over and over, with two token buckets per session: one of provider calls and one of tokens.
+// A bucket holds up to its capacity, starts full and refills continuously, from empty to full
+// over Refill. A session may generate while it has a call and a token left. A generation
+// reserves a call and the tokens of a typical generation before it starts, so that concurrent
+// requests of a session cannot all pass on the same remaining budget, and settles for what it
+// spent once done; since tokens are only known then, the token bucket can go into debt,
+// delaying the next generation until it is paid off. Sessions are forgotten once their buckets
+// are full again. It is safe for concurrent use.
+type SessionBudget struct {
+	calls, tokens float64
+	refill        time.Duration
+	now           func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*buckets
+	swept    time.Time
+	// typical is a moving average of the tokens a provider call spends, reserved for each
+	// generation; zero until the first is settled.
+	typical float64
+}
+
+// buckets are the levels of a session's buckets at a time.
+type buckets struct {
+	calls, tokens float64
+	at            time.Time
+}
+
+// Reservation is the budget a generation of a session holds while it runs.
+type Reservation struct {
+	session       string
+	calls, tokens float64
+}
+
+// NewSessionBudget returns a SessionBudget of calls and tokens per session, refilled over
+// refill. All three must be positive.
+func NewSessionBudget(calls, tokens int, refill time.Duration) *SessionBudget {
+	return &SessionBudget{calls: float64(calls), tokens: float64(tokens), refill: refill, now: time.Now, sessions: make(map[string]*buckets)}
+}
+
+// Reserve reports whether session may generate now and, if it may, takes a call and the tokens
+// of a typical generation from its budget until the reservation is settled. left is the
+// remaining budget; if session may not generate, wait is how long until it may.
+func (b *SessionBudget) Reserve(session string) (res Reservation, left Remaining, wait time.Duration, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := b.level(session)
+	if s.calls < 1 || s.tokens < 1 {
+		short := max((1-s.calls)/b.calls, (1-s.tokens)/b.tokens)
+		return Reservation{}, b.remaining(s), time.Duration(math.Ceil(short * float64(b.refill))), false
+	}
+	res = Reservation{session: session, calls: 1, tokens: math.Round(b.typical)}
+	s.calls -= res.calls
+	s.tokens -= res.tokens
+	b.sessions[session] = s
+	return res, b.remaining(s), 0, true
+}
+
+// Settle returns the budget held by res to its session and takes the calls and tokens the
+// generation spent instead, returning what remains.
+func (b *SessionBudget) Settle(res Reservation, calls, tokens int) Remaining {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if calls > 0 {
+		per := float64(tokens) / float64(calls)
+		if b.typical == 0 {
+			b.typical = per
+		} else {
+			b.typical += (per - b.typical) / 8
+		}
+	}
+	s := b.level(res.session)
+	s.calls = min(b.calls, s.calls+res.calls) - float64(calls)
+	s.tokens = min(b.tokens, s.tokens+res.tokens) - float64(tokens)
+	if s.calls < b.calls || s.tokens < b.tokens {
+		b.sessions[res.session] = s
+	} else {
+		delete(b.sessions, res.session)
+	}
+	return b.remaining(s)
+}
+
+// level returns the buckets of session refilled up to now. At most once per Refill, it
+// forgets the sessions whose buckets are full again.
+func (b *SessionBudget) level(session string) *buckets {
+	now := b.now()
+	if now.Sub(b.swept) >= b.refill {
+		for id, s := range b.sessions {
+			if b.refillTo(s, now); s.calls == b.calls && s.tokens == b.tokens {
+				delete(b.sessions, id)
+			}
+		}
+		b.swept = now
+	}
+	s, ok := b.sessions[session]
+	if !ok {
+		return &buckets{calls: b.calls, tokens: b.tokens, at: now}
+	}
+	b.refillTo(s, now)
+	return s
+}
+
+// refillTo refills s for the time elapsed until now.
+func (b *SessionBudget) refillTo(s *buckets, now time.Time) {
+	share := float64(now.Sub(s.at)) / float64(b.refill)
+	s.calls = min(b.calls, s.calls+share*b.calls)
+	s.tokens = min(b.tokens, s.tokens+share*b.tokens)
+	s.at = now
+}
+
+// remaining returns what is left in s, in whole calls and tokens.
+func (b *SessionBudget) remaining(s *buckets) Remaining {
+	return Remaining{Calls: max(0, int(s.calls)), Tokens: max(0, int(s.tokens))}
+}
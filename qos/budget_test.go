@@ -0,0 +1,82 @@
+package qos
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSessionBudget verifies that a session's calls and tokens run out, refill over time and
+// do not affect other sessions.
+func TestSessionBudget(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	b := NewSessionBudget(2, 1000, time.Hour)
+	b.now = func() time.Time { return now }
+
+	res, left, _, ok := b.Reserve("a")
+	if !ok || left != (Remaining{1, 1000}) {
+		t.Fatalf("Expected a call reserved from a full budget, got %+v", left)
+	}
+	if left := b.Settle(res, 1, 300); left != (Remaining{1, 700}) {
+		t.Errorf("Expected one call and 700 tokens left, got %+v", left)
+	}
+	res, left, _, _ = b.Reserve("a")
+	if left != (Remaining{0, 400}) {
+		t.Errorf("Expected a typical generation's tokens reserved, got %+v", left)
+	}
+	b.Settle(res, 1, 300)
+	_, left, wait, ok := b.Reserve("a")
+	if ok || left != (Remaining{0, 400}) || wait != 30*time.Minute {
+		t.Errorf("Expected the calls spent for half an hour, got %+v, wait %v, %v", left, wait, ok)
+	}
+	if _, _, _, ok := b.Reserve("b"); !ok {
+		t.Error("Expected another session to have its own budget")
+	}
+
+	// Tokens overspent by a generation are owed.
+	now = now.Add(30 * time.Minute)
+	res, _, _, _ = b.Reserve("a")
+	if left := b.Settle(res, 1, 1400); left != (Remaining{0, 0}) {
+		t.Errorf("Expected nothing left, got %+v", left)
+	}
+	if _, _, wait, ok := b.Reserve("a"); ok || wait != 30*time.Minute+3600*time.Millisecond {
+		t.Errorf("Expected the token debt to outlast the calls, got wait %v", wait)
+	}
+
+	now = now.Add(2 * time.Hour)
+	res, _, _, _ = b.Reserve("a")
+	if left := b.Settle(res, 0, 0); left != (Remaining{2, 1000}) {
+		t.Errorf("Expected the budget refilled, got %+v", left)
+	}
+	if len(b.sessions) != 0 {
+		t.Errorf("Expected refilled sessions to be forgotten, got %d", len(b.sessions))
+	}
+}
+
+// TestSessionBudgetReserve verifies that generations in flight hold their share of the budget,
+// so that concurrent requests cannot overspend it, and that unspent reservations are returned.
+func TestSessionBudgetReserve(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	b := NewSessionBudget(2, 1000, time.Hour)
+	b.now = func() time.Time { return now }
+
+	first, _, _, ok := b.Reserve("a")
+	second, _, _, ok2 := b.Reserve("a")
+	if !ok || !ok2 {
+		t.Fatal("Expected two calls reserved")
+	}
+	if _, left, _, ok := b.Reserve("a"); ok || left.Calls != 0 {
+		t.Errorf("Expected a third request in flight rejected, got %+v", left)
+	}
+	// A request answered from the store spends nothing.
+	if left := b.Settle(first, 0, 0); left != (Remaining{1, 1000}) {
+		t.Errorf("Expected the unspent call returned, got %+v", left)
+	}
+	b.Settle(second, 1, 400)
+	res, left, _, _ := b.Reserve("a")
+	if left != (Remaining{0, 200}) {
+		t.Errorf("Expected 400 tokens reserved, got %+v", left)
+	}
+	if left := b.Settle(res, 1, 100); left != (Remaining{0, 500}) {
+		t.Errorf("Expected the tokens reserved beyond the spend returned, got %+v", left)
+	}
+}
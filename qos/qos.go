@@ -0,0 +1,272 @@
+// Package qos admits requests by priority class, so that user-facing traffic keeps being served
+// while batch clients such as the importer or the meal planner are running.
+package qos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Priority is the class of a request.
+type Priority int
+
+// Priorities, from most to least urgent. The zero value is Interactive.
+const (
+	Interactive Priority = iota
+	Batch
+)
+
+// String returns "interactive" or "batch".
+func (p Priority) String() string {
+	if p == Batch {
+		return "batch"
+	}
+	return "interactive"
+}
+
+// ParsePriority parses "interactive" or "batch".
+func ParsePriority(s string) (Priority, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "interactive":
+		return Interactive, nil
+	case "batch":
+		return Batch, nil
+	}
+	return 0, fmt.Errorf("unknown priority %q (want interactive or batch)", s)
+}
+
+// DefaultMaxWait is how long Acquire queues a request by default before giving up.
+const DefaultMaxWait = 10 * time.Second
+
+// ErrOverloaded is returned by Acquire when a request could not be admitted within MaxWait.
+var ErrOverloaded = errors.New("qos: too many requests in progress")
+
+// Limiter bounds the number of requests in progress. Queued interactive requests are always
+// admitted before queued batch ones, and batch requests may only occupy BatchLimit of the
+// slots, so that under load batch traffic is throttled first. It is safe for concurrent use.
+type Limiter struct {
+	// Capacity is the maximum number of requests in progress.
+	Capacity int
+	// BatchLimit is the maximum number of batch requests in progress; it is capped by Capacity.
+	// Use Resize to change either once the limiter is in use.
+	BatchLimit int
+	// MaxWait bounds how long a request is queued before Acquire returns ErrOverloaded.
+	MaxWait time.Duration
+	// BatchQueue, if positive, switches batch requests to queue-and-drain: up to BatchQueue
+	// of them wait for a slot for as long as their context allows instead of MaxWait, so that
+	// a large import slows down under load rather than failing midway. Batch requests beyond
+	// a full queue get ErrOverloaded at once.
+	BatchQueue int
+
+	mu          sync.Mutex
+	active      int
+	activeBatch int
+	queues      [2][]chan struct{} // waiters by priority, oldest first
+}
+
+// NewLimiter returns a Limiter with the given capacities and DefaultMaxWait.
+func NewLimiter(capacity, batchLimit int) *Limiter {
+	return &Limiter{Capacity: capacity, BatchLimit: batchLimit, MaxWait: DefaultMaxWait}
+}
+
+// Resize changes the limiter's capacities while it is in use. Requests in progress beyond the
+// new capacities finish normally; queued ones are admitted if there is now room.
+func (l *Limiter) Resize(capacity, batchLimit int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Capacity, l.BatchLimit = capacity, batchLimit
+	l.dispatch()
+}
+
+// admits reports whether a request of priority p may start now. Callers hold l.mu.
+func (l *Limiter) admits(p Priority) bool {
+	if l.active >= l.Capacity {
+		return false
+	}
+	if p == Batch {
+		return l.activeBatch < l.BatchLimit && len(l.queues[Interactive]) == 0
+	}
+	return true
+}
+
+// start records that a request of priority p started. Callers hold l.mu.
+func (l *Limiter) start(p Priority) {
+	l.active++
+	if p == Batch {
+		l.activeBatch++
+	}
+}
+
+// Acquire waits until a request of priority p may start, and returns the function to call when
+// it is done. It returns ErrOverloaded after MaxWait, or at once for a batch request finding the
+// BatchQueue full, or ctx.Err() if ctx is done first.
+func (l *Limiter) Acquire(ctx context.Context, p Priority) (release func(), err error) {
+	l.mu.Lock()
+	if len(l.queues[p]) == 0 && l.admits(p) {
+		l.start(p)
+		l.mu.Unlock()
+		return l.releaser(p), nil
+	}
+	drain := p == Batch && l.BatchQueue > 0
+	if drain && len(l.queues[p]) >= l.BatchQueue {
+		l.mu.Unlock()
+		return nil, ErrOverloaded
+	}
+	ready := make(chan struct{})
+	l.queues[p] = append(l.queues[p], ready)
+	l.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if !drain {
+		timer := time.NewTimer(l.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-ready:
+		return l.releaser(p), nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeout:
+		err = ErrOverloaded
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-ready:
+		// Admitted while giving up: hand the slot on.
+		l.done(p)
+	default:
+		for i, c := range l.queues[p] {
+			if c == ready {
+				l.queues[p] = append(l.queues[p][:i], l.queues[p][i+1:]...)
+				break
+			}
+		}
+		// Batch waiters may have been held back for this one.
+		l.dispatch()
+	}
+	return nil, err
+}
+
+// Queued returns the number of requests of priority p waiting for a slot.
+func (l *Limiter) Queued(p Priority) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.queues[p])
+}
+
+// releaser returns a function that ends a request of priority p once.
+func (l *Limiter) releaser(p Priority) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.done(p)
+		})
+	}
+}
+
+// done records that a request of priority p ended and admits waiters. Callers hold l.mu.
+func (l *Limiter) done(p Priority) {
+	l.active--
+	if p == Batch {
+		l.activeBatch--
+	}
+	l.dispatch()
+}
+
+// dispatch admits queued requests, interactive ones first, while there is room. Callers hold
+// l.mu.
+func (l *Limiter) dispatch() {
+	for _, p := range []Priority{Interactive, Batch} {
+		for len(l.queues[p]) > 0 && l.admits(p) {
+			l.start(p)
+			close(l.queues[p][0])
+			l.queues[p] = l.queues[p][1:]
+		}
+	}
+}
+
+// ErrBusy is returned when a Gate turns an operation away.
+var ErrBusy = errors.New("qos: concurrency limit reached")
+
+// Gate caps the number of operations in progress, e.g. of one endpoint, without queueing:
+// unlike a Limiter, it turns operations beyond its capacity away at once. A nil Gate admits
+// everything. It is safe for concurrent use.
+type Gate struct {
+	capacity int
+
+	mu     sync.Mutex
+	active int
+}
+
+// NewGate returns a Gate admitting up to capacity operations at a time.
+func NewGate(capacity int) *Gate {
+	return &Gate{capacity: capacity}
+}
+
+// TryAcquire starts an operation if there is room, returning the function to call when it is
+// done, or reports false without waiting if the gate is full.
+func (g *Gate) TryAcquire() (release func(), ok bool) {
+	if g == nil {
+		return func() {}, true
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.active >= g.capacity {
+		return nil, false
+	}
+	g.active++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			g.mu.Lock()
+			defer g.mu.Unlock()
+			g.active--
+		})
+	}, true
+}
+
+// Resize changes the gate's capacity while it is in use. Operations in progress beyond the new
+// capacity finish normally.
+func (g *Gate) Resize(capacity int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.capacity = capacity
+}
+
+// InFlight returns the number of operations in progress.
+func (g *Gate) InFlight() int {
+	if g == nil {
+		return 0
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.active
+}
+
+// ParseGates parses comma-separated name=capacity pairs, e.g. "/resolve=100,generate=8", into
+// a Gate per name.
+func ParseGates(s string) (map[string]*Gate, error) {
+	gates := make(map[string]*Gate)
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, v, ok := strings.Cut(pair, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if name = strings.TrimSpace(name); !ok || name == "" || err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid concurrency limit %q (want name=capacity)", pair)
+		}
+		gates[name] = NewGate(n)
+	}
+	return gates, nil
+}
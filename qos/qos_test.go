@@ -0,0 +1,153 @@
+package qos
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestLimiterPriority verifies that queued interactive requests are admitted before batch ones
+// that queued earlier.
+func TestLimiterPriority(t *testing.T) {
+	l := NewLimiter(1, 1)
+	release, err := l.Acquire(context.Background(), Interactive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan Priority, 2)
+	acquire := func(p Priority) {
+		r, err := l.Acquire(context.Background(), p)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		order <- p
+		r()
+	}
+	go acquire(Batch)
+	waitQueued(t, l, Batch, 1)
+	go acquire(Interactive)
+	waitQueued(t, l, Interactive, 1)
+
+	release()
+	if first, second := <-order, <-order; first != Interactive || second != Batch {
+		t.Errorf("Expected interactive then batch, got %s then %s", first, second)
+	}
+}
+
+// TestLimiterBatchLimit verifies that batch requests are throttled at BatchLimit while
+// interactive ones still get in.
+func TestLimiterBatchLimit(t *testing.T) {
+	l := NewLimiter(2, 1)
+	l.MaxWait = 10 * time.Millisecond
+	if _, err := l.Acquire(context.Background(), Batch); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Acquire(context.Background(), Batch); !errors.Is(err, ErrOverloaded) {
+		t.Errorf("Expected ErrOverloaded for a second batch request, got %v", err)
+	}
+	release, err := l.Acquire(context.Background(), Interactive)
+	if err != nil {
+		t.Fatalf("Expected an interactive request to be admitted, got %v", err)
+	}
+	release()
+	release() // Releasing twice is harmless.
+	if l.active != 1 {
+		t.Errorf("Expected one request in progress, got %d", l.active)
+	}
+}
+
+// TestLimiterBatchQueue verifies that in queue-and-drain mode batch requests wait past MaxWait
+// for a slot, and that those beyond the queue are rejected at once.
+func TestLimiterBatchQueue(t *testing.T) {
+	l := NewLimiter(1, 1)
+	l.MaxWait, l.BatchQueue = time.Millisecond, 1
+	release, err := l.Acquire(context.Background(), Batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admitted := make(chan error, 1)
+	go func() {
+		r, err := l.Acquire(context.Background(), Batch)
+		if err == nil {
+			r()
+		}
+		admitted <- err
+	}()
+	waitQueued(t, l, Batch, 1)
+	if _, err := l.Acquire(context.Background(), Batch); !errors.Is(err, ErrOverloaded) {
+		t.Errorf("Expected ErrOverloaded with the batch queue full, got %v", err)
+	}
+	time.Sleep(10 * l.MaxWait)
+	release()
+	if err := <-admitted; err != nil {
+		t.Errorf("Expected the queued batch request to be admitted past MaxWait, got %v", err)
+	}
+	if _, err := l.Acquire(context.Background(), Interactive); err != nil {
+		t.Errorf("Expected interactive requests to be unaffected, got %v", err)
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	if p, err := ParsePriority(" Batch "); err != nil || p != Batch {
+		t.Errorf("ParsePriority(Batch) = %v, %v", p, err)
+	}
+	if _, err := ParsePriority("urgent"); err == nil {
+		t.Error("Expected an error for an unknown priority")
+	}
+}
+
+// waitQueued waits until n requests of priority p are queued.
+func waitQueued(t *testing.T, l *Limiter, p Priority, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if l.Queued(p) == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for %d queued %s requests", n, p)
+}
+
+// TestGate verifies that a full gate turns operations away until one is released.
+func TestGate(t *testing.T) {
+	g := NewGate(2)
+	first, _ := g.TryAcquire()
+	if _, ok := g.TryAcquire(); !ok {
+		t.Fatal("Expected a second operation to be admitted")
+	}
+	if _, ok := g.TryAcquire(); ok {
+		t.Fatal("Expected a third operation to be turned away")
+	}
+	first()
+	first()
+	if g.InFlight() != 1 {
+		t.Errorf("Expected a repeated release to count once, got %d in flight", g.InFlight())
+	}
+	if _, ok := g.TryAcquire(); !ok {
+		t.Error("Expected an operation to be admitted after a release")
+	}
+
+	var none *Gate
+	if _, ok := none.TryAcquire(); !ok {
+		t.Error("Expected a nil gate to admit everything")
+	}
+}
+
+func TestParseGates(t *testing.T) {
+	gates, err := ParseGates(" /resolve=100, generate=8 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gates) != 2 || gates["/resolve"].capacity != 100 || gates["generate"].capacity != 8 {
+		t.Errorf("Unexpected gates %+v", gates)
+	}
+	for _, s := range []string{"generate", "generate=0", "=3", "generate=x"} {
+		if _, err := ParseGates(s); err == nil {
+			t.Errorf("Expected %q to be rejected", s)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+)
+
+// DefaultChannel is the channel a Bus created by NewBus announces changed recipes on.
+const DefaultChannel = "recipe-resolver:invalidate"
+
+// Bus announces the IDs of changed recipes to every replica subscribed to its channel, as a
+// JSON array. It implements resolver.InvalidationBus.
+type Bus struct {
+	Client  *Client
+	Channel string
+}
+
+// NewBus returns a Bus on DefaultChannel.
+func NewBus(c *Client) *Bus {
+	return &Bus{Client: c, Channel: DefaultChannel}
+}
+
+// Publish sends ids to the subscribers.
+func (b *Bus) Publish(ctx context.Context, ids []string) error {
+	message, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	_, err = b.Client.Publish(ctx, b.Channel, string(message))
+	return err
+}
+
+// Subscribe calls handle with the IDs of every message until ctx is done or the connection
+// fails. Malformed messages are logged and skipped.
+func (b *Bus) Subscribe(ctx context.Context, handle func(ids []string)) error {
+	return b.Client.Subscribe(ctx, b.Channel, func(message string) {
+		var ids []string
+		if err := json.Unmarshal([]byte(message), &ids); err != nil {
+			log.Printf("Redis: Ignoring malformed message on %s: %v", b.Channel, err)
+			return
+		}
+		handle(ids)
+	})
+}
@@ -0,0 +1,207 @@
+// Package redis is a minimal Redis client, enough for the leases and invalidation messages the
+// service's replicas share. It speaks the Redis protocol directly, one connection per command.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client sends commands to a Redis server.
+type Client struct {
+	// Addr is the server's host:port.
+	Addr     string
+	Password string
+	DB       int
+	// Timeout bounds each command, connection included.
+	Timeout time.Duration
+}
+
+// ParseURL returns a Client for a URL such as "redis://:secret@cache:6379/2", with a five
+// second timeout.
+func ParseURL(rawURL string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "redis" || u.Host == "" {
+		return nil, fmt.Errorf("invalid Redis URL %q: expected redis://[:password@]host[:port][/db]", rawURL)
+	}
+	c := &Client{Addr: u.Host, Timeout: 5 * time.Second}
+	if u.Port() == "" {
+		c.Addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		c.Password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if c.DB, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid Redis URL %q: database %q is not a number", rawURL, db)
+		}
+	}
+	return c, nil
+}
+
+// Do sends one command and returns its reply: a string, an int64, nil, or a []interface{} of
+// those. Error replies are returned as errors.
+func (c *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	conn, rd, err := c.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if err := write(conn, args); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	reply, err := ReadReply(rd)
+	if err != nil {
+		return nil, fmt.Errorf("redis %s: %w", args[0], err)
+	}
+	return reply, nil
+}
+
+// Subscribe listens on channel and calls handle with each message until ctx is done or the
+// connection fails. It returns ctx's error, or the failure.
+func (c *Client) Subscribe(ctx context.Context, channel string, handle func(message string)) error {
+	dialCtx := ctx
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	conn, rd, err := c.dial(dialCtx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := write(conn, []string{"SUBSCRIBE", channel}); err != nil {
+		return fmt.Errorf("redis: %w", err)
+	}
+	for {
+		reply, err := ReadReply(rd)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("redis SUBSCRIBE: %w", err)
+		}
+		if push, ok := reply.([]interface{}); ok && len(push) == 3 && push[0] == "message" {
+			if message, ok := push[2].(string); ok {
+				handle(message)
+			}
+		}
+	}
+}
+
+// Publish sends message to the subscribers of channel and returns how many received it.
+func (c *Client) Publish(ctx context.Context, channel, message string) (int64, error) {
+	reply, err := c.Do(ctx, "PUBLISH", channel, message)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := reply.(int64)
+	return n, nil
+}
+
+// dial connects to the server, authenticating and selecting the database as configured.
+func (c *Client) dial(ctx context.Context) (net.Conn, *bufio.Reader, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("redis: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	rd := bufio.NewReader(conn)
+	var setup [][]string
+	if c.Password != "" {
+		setup = append(setup, []string{"AUTH", c.Password})
+	}
+	if c.DB != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.DB)})
+	}
+	for _, cmd := range setup {
+		err := write(conn, cmd)
+		if err == nil {
+			_, err = ReadReply(rd)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, nil, fmt.Errorf("redis %s: %w", cmd[0], err)
+		}
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, rd, nil
+}
+
+// write sends a command as an array of bulk strings.
+func write(w io.Writer, args []string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ReadReply reads one reply in the Redis protocol: a string, an int64, nil, or a []interface{}
+// of those. Error replies are returned as errors. Commands sent to a server read the same way.
+func ReadReply(rd *bufio.Reader) (interface{}, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty reply")
+	}
+	switch body := line[1:]; line[0] {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, errors.New(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			if items[i], err = ReadReply(rd); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("unexpected reply %q", line)
+}
@@ -0,0 +1,81 @@
+package redis_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/redis"
+	"github.com/pageza/recipe-resolver-ms/redis/redistest"
+)
+
+func TestParseURL(t *testing.T) {
+	c, err := redis.ParseURL("redis://:secret@cache/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Addr != "cache:6379" || c.Password != "secret" || c.DB != 2 {
+		t.Errorf("Unexpected settings parsed from the URL: %+v", c)
+	}
+	for _, bad := range []string{"http://cache:6379", "redis://", "redis://cache/db"} {
+		if _, err := redis.ParseURL(bad); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestBus(t *testing.T) {
+	srv := redistest.NewServer(t, "secret")
+	c, err := redis.ParseURL(srv.URL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := redis.NewBus(c)
+	ctx, cancel := context.WithCancel(context.Background())
+	received := make(chan []string, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- bus.Subscribe(ctx, func(ids []string) { received <- ids })
+	}()
+
+	// Publish until the subscription is in place.
+	for deadline := time.Now().Add(time.Second); ; {
+		if n, err := c.Publish(ctx, redis.DefaultChannel, `["r-0"]`); err != nil {
+			t.Fatal(err)
+		} else if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("The subscription was not established")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	<-received
+	if err := bus.Publish(ctx, []string{"r-1", "r-2"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ids := <-received:
+		if !reflect.DeepEqual(ids, []string{"r-1", "r-2"}) {
+			t.Errorf("Expected the published IDs, got %v", ids)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No message was received")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("Expected Subscribe to return context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Subscribe did not return after its context was canceled")
+	}
+
+	c.Password = "wrong"
+	if err := bus.Publish(context.Background(), []string{"r-3"}); err == nil {
+		t.Error("Expected an authentication error")
+	}
+}
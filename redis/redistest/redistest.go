@@ -0,0 +1,193 @@
+// Package redistest provides an in-process fake Redis server for tests. It serves the subset of
+// Redis the service uses: AUTH, SELECT, SET with NX and PX, the lease scripts sent with EVAL,
+// PUBLISH and SUBSCRIBE.
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/redis"
+)
+
+// Server is a fake Redis server listening on the loopback interface.
+type Server struct {
+	// Addr is the server's host:port.
+	Addr string
+
+	password    string
+	mu          sync.Mutex
+	keys        map[string]entry
+	subscribers map[string][]net.Conn
+}
+
+type entry struct {
+	value   string
+	expires time.Time
+}
+
+// NewServer starts a Server requiring password, if non-empty, and stops it when the test ends.
+func NewServer(t testing.TB, password string) *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{Addr: ln.Addr().String(), password: password, keys: make(map[string]entry), subscribers: make(map[string][]net.Conn)}
+	t.Cleanup(func() {
+		ln.Close()
+		s.mu.Lock()
+		for _, subs := range s.subscribers {
+			for _, c := range subs {
+				c.Close()
+			}
+		}
+		s.mu.Unlock()
+	})
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// URL returns a redis:// URL for the server, with its password.
+func (s *Server) URL() string {
+	if s.password == "" {
+		return "redis://" + s.Addr
+	}
+	return "redis://:" + s.password + "@" + s.Addr
+}
+
+// serve answers the commands sent on conn until it is closed.
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	authed := s.password == ""
+	for {
+		cmd, err := redis.ReadReply(rd)
+		if err != nil {
+			s.unsubscribe(conn)
+			return
+		}
+		var args []string
+		items, _ := cmd.([]interface{})
+		for _, a := range items {
+			if v, ok := a.(string); ok {
+				args = append(args, v)
+			}
+		}
+		if len(args) == 0 {
+			return
+		}
+		var out string
+		switch name := strings.ToUpper(args[0]); {
+		case name == "AUTH":
+			authed = len(args) == 2 && args[1] == s.password
+			out = "+OK\r\n"
+			if !authed {
+				out = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			out = "-NOAUTH Authentication required\r\n"
+		case name == "SELECT":
+			out = "+OK\r\n"
+		case name == "SET" && len(args) == 6:
+			out = s.set(args[1], args[2], args[5])
+		case name == "EVAL" && len(args) >= 5:
+			out = s.eval(args[1], args[3], args[4], args[5:])
+		case name == "PUBLISH" && len(args) == 3:
+			out = fmt.Sprintf(":%d\r\n", s.publish(args[1], args[2]))
+		case name == "SUBSCRIBE" && len(args) == 2:
+			s.mu.Lock()
+			s.subscribers[args[1]] = append(s.subscribers[args[1]], conn)
+			s.mu.Unlock()
+			out = fmt.Sprintf("*3\r\n$9\r\nsubscribe\r\n%s:1\r\n", bulk(args[1]))
+		default:
+			out = "-ERR unknown command\r\n"
+		}
+		s.mu.Lock()
+		conn.Write([]byte(out))
+		s.mu.Unlock()
+	}
+}
+
+// set implements SET key value NX PX ms.
+func (s *Server) set(key, value, ms string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, held := s.get(key); held {
+		return "$-1\r\n"
+	}
+	n, _ := strconv.Atoi(ms)
+	s.keys[key] = entry{value, time.Now().Add(time.Duration(n) * time.Millisecond)}
+	return "+OK\r\n"
+}
+
+// eval runs the lease scripts: with a "pexpire" script it extends key to args[0] milliseconds,
+// otherwise it deletes key, in both cases only if key holds token.
+func (s *Server) eval(script, key, token string, args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.get(key)
+	if !ok || e.value != token {
+		return ":0\r\n"
+	}
+	if strings.Contains(script, "pexpire") && len(args) > 0 {
+		n, _ := strconv.Atoi(args[0])
+		s.keys[key] = entry{e.value, time.Now().Add(time.Duration(n) * time.Millisecond)}
+	} else {
+		delete(s.keys, key)
+	}
+	return ":1\r\n"
+}
+
+// get returns the unexpired entry of key. The caller holds s.mu.
+func (s *Server) get(key string) (entry, bool) {
+	e, ok := s.keys[key]
+	if ok && time.Now().After(e.expires) {
+		delete(s.keys, key)
+		return entry{}, false
+	}
+	return e, ok
+}
+
+// publish sends message to the subscribers of channel and returns how many there are.
+func (s *Server) publish(channel, message string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	push := fmt.Sprintf("*3\r\n$7\r\nmessage\r\n%s%s", bulk(channel), bulk(message))
+	for _, c := range s.subscribers[channel] {
+		c.Write([]byte(push))
+	}
+	return len(s.subscribers[channel])
+}
+
+// unsubscribe forgets conn's subscriptions.
+func (s *Server) unsubscribe(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for channel, subs := range s.subscribers {
+		for i, c := range subs {
+			if c == conn {
+				s.subscribers[channel] = append(subs[:i:i], subs[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// bulk encodes v as a bulk string.
+func bulk(v string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+}
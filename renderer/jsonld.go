@@ -0,0 +1,113 @@
+package renderer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// JSONLD renders recipes as schema.org Recipe objects in JSON-LD, as search engines and recipe
+// managers read them: a single recipe as one object, several as a @graph.
+type JSONLD struct{}
+
+// ContentType returns "application/ld+json".
+func (JSONLD) ContentType() string { return "application/ld+json" }
+
+// Render writes the recipes of doc.
+func (JSONLD) Render(w io.Writer, doc Document) error {
+	enc := json.NewEncoder(w)
+	if len(doc.Recipes) == 1 {
+		obj := schemaRecipe(doc.Recipes[0])
+		obj["@context"] = "https://schema.org"
+		return enc.Encode(obj)
+	}
+	graph := make([]map[string]interface{}, len(doc.Recipes))
+	for i, r := range doc.Recipes {
+		graph[i] = schemaRecipe(r)
+	}
+	return enc.Encode(map[string]interface{}{"@context": "https://schema.org", "@graph": graph})
+}
+
+// schemaRecipe returns r as a schema.org Recipe.
+func schemaRecipe(r model.Recipe) map[string]interface{} {
+	obj := map[string]interface{}{
+		"@type":            "Recipe",
+		"name":             r.Title,
+		"recipeIngredient": nonNil(r.Ingredients),
+	}
+	steps := make([]map[string]string, len(r.Steps))
+	for i, s := range r.Steps {
+		steps[i] = map[string]string{"@type": "HowToStep", "text": s}
+	}
+	obj["recipeInstructions"] = steps
+	if r.ID != "" {
+		obj["identifier"] = r.ID
+	}
+	if r.TotalTime > 0 {
+		obj["totalTime"] = fmt.Sprintf("PT%dM", r.TotalTime)
+	}
+	if r.ImageURL != "" {
+		obj["image"] = r.ImageURL
+	}
+	if len(r.Tags) > 0 {
+		obj["keywords"] = r.Tags
+	}
+	if len(r.Appliances) > 0 {
+		tools := make([]map[string]string, len(r.Appliances))
+		for i, a := range r.Appliances {
+			tools[i] = map[string]string{"@type": "HowToTool", "name": a}
+		}
+		obj["tool"] = tools
+	}
+	if facts := nutrients(r.NutritionalInfo); len(facts) > 0 {
+		nutrition := map[string]interface{}{"@type": "NutritionInformation"}
+		for _, f := range facts {
+			if prop, ok := schemaNutrients[f.Name]; ok {
+				nutrition[prop] = f.Amount
+			}
+		}
+		obj["nutrition"] = nutrition
+	}
+	if !r.CreatedAt.IsZero() {
+		obj["datePublished"] = r.CreatedAt.UTC().Format(time.DateOnly)
+	}
+	if !r.UpdatedAt.IsZero() {
+		obj["dateModified"] = r.UpdatedAt.UTC().Format(time.DateOnly)
+	}
+	if a := r.Attribution; a != nil {
+		if a.Author != "" {
+			obj["author"] = map[string]string{"@type": "Person", "name": a.Author}
+		}
+		if a.SourceURL != "" {
+			obj["isBasedOn"] = a.SourceURL
+		}
+		if a.License != "" {
+			obj["license"] = a.License
+		}
+	}
+	return obj
+}
+
+// schemaNutrients maps nutrient names to the properties of a schema.org NutritionInformation.
+var schemaNutrients = map[string]string{
+	"calories":      "calories",
+	"protein":       "proteinContent",
+	"carbohydrates": "carbohydrateContent",
+	"fat":           "fatContent",
+	"saturated_fat": "saturatedFatContent",
+	"fiber":         "fiberContent",
+	"sugar":         "sugarContent",
+	"sodium":        "sodiumContent",
+	"cholesterol":   "cholesterolContent",
+}
+
+// nonNil returns list, or an empty list for nil so that it encodes as [].
+func nonNil(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
@@ -0,0 +1,70 @@
+package renderer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Markdown renders recipes as a Markdown document for chat clients and note-taking apps: each
+// recipe has a title, its difficulty and time, ingredients as a list, numbered steps, the
+// nutrition facts, the appliances needed and the allergy disclaimer. Recipes are separated by
+// horizontal rules.
+type Markdown struct{}
+
+// ContentType returns "text/markdown; charset=utf-8".
+func (Markdown) ContentType() string { return "text/markdown; charset=utf-8" }
+
+// Render writes the recipes of doc.
+func (Markdown) Render(w io.Writer, doc Document) error {
+	bw := bufio.NewWriter(w)
+	for i, r := range doc.Recipes {
+		if i > 0 {
+			bw.WriteString("\n---\n\n")
+		}
+		fmt.Fprintf(bw, "# %s\n", escapeMarkdown(r.Title))
+		if s := summary(r); len(s) > 0 {
+			fmt.Fprintf(bw, "\n*%s*\n", strings.Join(s, " · "))
+		}
+		if r.ImageURL != "" {
+			fmt.Fprintf(bw, "\n![%s](%s)\n", escapeMarkdown(r.Title), r.ImageURL)
+		}
+		if len(r.Ingredients) > 0 {
+			bw.WriteString("\n## Ingredients\n\n")
+			for _, ing := range r.Ingredients {
+				fmt.Fprintf(bw, "- %s\n", escapeMarkdown(ing))
+			}
+		}
+		if len(r.Steps) > 0 {
+			bw.WriteString("\n## Steps\n\n")
+			for n, step := range r.Steps {
+				fmt.Fprintf(bw, "%d. %s\n", n+1, escapeMarkdown(step))
+			}
+		}
+		if facts := nutrients(r.NutritionalInfo); len(facts) > 0 {
+			bw.WriteString("\n## Nutrition\n\n")
+			for _, f := range facts {
+				fmt.Fprintf(bw, "- %s: %s\n", f.Name, escapeMarkdown(f.Amount))
+			}
+		}
+		if len(r.Appliances) > 0 {
+			fmt.Fprintf(bw, "\n**Appliances:** %s\n", escapeMarkdown(strings.Join(r.Appliances, ", ")))
+		}
+		if d := strings.TrimSpace(r.AllergyDisclaimer); d != "" {
+			fmt.Fprintf(bw, "\n> %s\n", escapeMarkdown(d))
+		}
+		if c := credit(r); c != "" {
+			fmt.Fprintf(bw, "\n*%s*\n", escapeMarkdown(c))
+		}
+	}
+	return bw.Flush()
+}
+
+// markdownEscaper escapes the characters that would otherwise format text.
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "#", `\#`, "<", `\<`)
+
+// escapeMarkdown escapes s for use as text in a Markdown line.
+func escapeMarkdown(s string) string {
+	return markdownEscaper.Replace(strings.Join(strings.Fields(s), " "))
+}
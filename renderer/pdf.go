@@ -0,0 +1,207 @@
+package renderer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PDF renders recipes as a printable PDF document on A4 pages, laid out like Markdown: each
+// recipe starts a page with its title, followed by its ingredients, steps, nutrition facts and
+// allergy disclaimer. It uses the standard Helvetica fonts, so text outside the Windows-1252
+// character set is printed as "?".
+type PDF struct{}
+
+// ContentType returns "application/pdf".
+func (PDF) ContentType() string { return "application/pdf" }
+
+// Page layout, in points.
+const (
+	pdfWidth   = 595
+	pdfHeight  = 842
+	pdfMargin  = 56
+	pdfBody    = 11
+	pdfHeading = 13
+	pdfTitle   = 18
+)
+
+// pdfLine is a line of text laid out on a page.
+type pdfLine struct {
+	bold   bool
+	size   float64
+	indent float64
+	text   string
+	// space is the vertical space before the line, beyond its leading.
+	space float64
+	// page starts a new page before the line.
+	page bool
+}
+
+// Render writes the recipes of doc.
+func (PDF) Render(w io.Writer, doc Document) error {
+	var lines []pdfLine
+	// body adds text, the first line starting with prefix, e.g. "1. ", and the others
+	// indented further.
+	body := func(text string, indent float64, prefix string) {
+		for i, l := range wrap(prefix+text, indent, pdfBody) {
+			if i > 0 && prefix != "" {
+				lines = append(lines, pdfLine{size: pdfBody, indent: indent + 12, text: l})
+				continue
+			}
+			lines = append(lines, pdfLine{size: pdfBody, indent: indent, text: l})
+		}
+	}
+	heading := func(text string) {
+		lines = append(lines, pdfLine{bold: true, size: pdfHeading, text: text, space: 8})
+	}
+	for i, r := range doc.Recipes {
+		for j, l := range wrap(r.Title, 0, pdfTitle) {
+			lines = append(lines, pdfLine{bold: true, size: pdfTitle, text: l, page: i > 0 && j == 0})
+		}
+		if s := summary(r); len(s) > 0 {
+			body(strings.Join(s, "   "), 0, "")
+		}
+		if len(r.Ingredients) > 0 {
+			heading("Ingredients")
+			for _, ing := range r.Ingredients {
+				body(ing, 12, "• ")
+			}
+		}
+		if len(r.Steps) > 0 {
+			heading("Steps")
+			for n, step := range r.Steps {
+				body(step, 12, fmt.Sprintf("%d. ", n+1))
+			}
+		}
+		if facts := nutrients(r.NutritionalInfo); len(facts) > 0 {
+			heading("Nutrition")
+			for _, f := range facts {
+				body(f.Name+": "+f.Amount, 12, "")
+			}
+		}
+		if len(r.Appliances) > 0 {
+			heading("Appliances")
+			body(strings.Join(r.Appliances, ", "), 0, "")
+		}
+		if d := strings.TrimSpace(r.AllergyDisclaimer); d != "" {
+			lines = append(lines, pdfLine{size: pdfBody, space: 8})
+			body(d, 0, "")
+		}
+		if c := credit(r); c != "" {
+			lines = append(lines, pdfLine{size: pdfBody, space: 8})
+			body(c, 0, "")
+		}
+	}
+
+	// Lay the lines out on pages, each a content stream.
+	var pages []*bytes.Buffer
+	var page *bytes.Buffer
+	y := 0.0
+	for _, l := range lines {
+		leading := l.size * 1.3
+		if page == nil || l.page || y-leading-l.space < pdfMargin {
+			page = new(bytes.Buffer)
+			pages = append(pages, page)
+			y = pdfHeight - pdfMargin
+		} else {
+			y -= l.space
+		}
+		y -= leading
+		if l.text == "" {
+			continue
+		}
+		font := "F1"
+		if l.bold {
+			font = "F2"
+		}
+		fmt.Fprintf(page, "BT /%s %g Tf 1 0 0 1 %g %.1f Tm (%s) Tj ET\n", font, l.size, pdfMargin+l.indent, y, pdfString(l.text))
+	}
+	if len(pages) == 0 {
+		pages = append(pages, new(bytes.Buffer))
+	}
+
+	// Objects: 1 catalog, 2 page tree, 3 and 4 fonts, then a page and its contents for each
+	// page.
+	var out bytes.Buffer
+	var offsets []int
+	object := func(format string, args ...interface{}) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n", len(offsets))
+		fmt.Fprintf(&out, format, args...)
+		out.WriteString("\nendobj\n")
+	}
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, p := range pages {
+		object("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", pdfWidth, pdfHeight, 6+2*i)
+		object("<< /Length %d >>\nstream\n%sendstream", p.Len(), p.Bytes())
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// wrap splits text into lines fitting the page width less indent at a font size, estimating
+// Helvetica's average character width as half the size.
+func wrap(text string, indent, size float64) []string {
+	width := int((pdfWidth - 2*pdfMargin - indent - 16) / (size * 0.5))
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	return append(lines, line)
+}
+
+// winAnsi maps the characters of Windows-1252 outside Latin-1 to their codes.
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, 'ˆ': 0x88,
+	'‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e, '‘': 0x91, '’': 0x92, '“': 0x93,
+	'”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b,
+	'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// pdfString encodes s in Windows-1252 and escapes it for a PDF string literal.
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20:
+			b.WriteByte(' ')
+		case r < 0x80:
+			b.WriteByte(byte(r))
+		case r >= 0xa0 && r < 0x100:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			if c, ok := winAnsi[r]; ok {
+				fmt.Fprintf(&b, "\\%03o", c)
+			} else {
+				b.WriteByte('?')
+			}
+		}
+	}
+	return b.String()
+}
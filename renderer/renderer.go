@@ -0,0 +1,210 @@
+// Package renderer writes API responses in the output formats clients ask for: JSON,
+// newline-delimited JSON, JSON-LD, Markdown, SSML for voice assistants and PDF. Formats are
+// looked up in a Registry by name, as in a "?format=markdown" parameter, or by media type from
+// an Accept header, so that a format added to the registry, e.g. by a program embedding the
+// server, is served by every endpoint rendering recipes.
+package renderer
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// Document is a response to render.
+type Document struct {
+	// Recipes are the recipes of the response, the main one first, as formats other than JSON
+	// present them.
+	Recipes []model.Recipe
+	// Value is the whole response, as JSON encodes it.
+	Value interface{}
+}
+
+// Renderer writes documents in one format.
+type Renderer interface {
+	// ContentType is the media type of the output, e.g. "text/markdown; charset=utf-8".
+	ContentType() string
+	// Render writes doc to w.
+	Render(w io.Writer, doc Document) error
+}
+
+// Func adapts a function to a Renderer of the media type Type.
+type Func struct {
+	Type string
+	Fn   func(w io.Writer, doc Document) error
+}
+
+// ContentType returns f.Type.
+func (f Func) ContentType() string { return f.Type }
+
+// Render calls f.Fn.
+func (f Func) Render(w io.Writer, doc Document) error { return f.Fn(w, doc) }
+
+// JSON renders a document's Value as JSON.
+type JSON struct{}
+
+// ContentType returns "application/json".
+func (JSON) ContentType() string { return "application/json" }
+
+// Render encodes doc.Value.
+func (JSON) Render(w io.Writer, doc Document) error { return json.NewEncoder(w).Encode(doc.Value) }
+
+// NDJSON renders a document's Recipes as newline-delimited JSON, a recipe per line, the main
+// one first. w is flushed after each recipe if it has a Flush method, as an http.ResponseWriter
+// does, so that a client can use each recipe as it arrives.
+type NDJSON struct{}
+
+// ContentType returns "application/x-ndjson".
+func (NDJSON) ContentType() string { return "application/x-ndjson" }
+
+// Render encodes each of doc.Recipes on its own line.
+func (NDJSON) Render(w io.Writer, doc Document) error {
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(interface{ Flush() })
+	for _, rec := range doc.Recipes {
+		// Encode ends each recipe with a newline.
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	return nil
+}
+
+// Registry maps format names to renderers. It is safe for concurrent use.
+type Registry struct {
+	mu      sync.RWMutex
+	formats map[string]Renderer
+	// fallback names the format served when a request names none and accepts anything.
+	fallback string
+}
+
+// NewRegistry returns an empty registry whose fallback format is fallback.
+func NewRegistry(fallback string) *Registry {
+	return &Registry{formats: make(map[string]Renderer), fallback: fallback}
+}
+
+// Builtin returns a new registry of the built-in formats, falling back to "json": "json",
+// "ndjson" (see NDJSON), "jsonld" (see JSONLD), "markdown" (see Markdown), "voice" (see Voice)
+// and "pdf" (see PDF).
+func Builtin() *Registry {
+	r := NewRegistry("json")
+	r.Register("json", JSON{})
+	r.Register("ndjson", NDJSON{})
+	r.Register("jsonld", JSONLD{})
+	r.Register("markdown", Markdown{})
+	r.Register("voice", Voice{})
+	r.Register("pdf", PDF{})
+	return r
+}
+
+// Default is the registry used by the server unless it is given its own.
+var Default = Builtin()
+
+// Register adds or replaces the renderer of a format in Default.
+func Register(format string, rd Renderer) {
+	Default.Register(format, rd)
+}
+
+// Register adds or replaces the renderer of a format, named case-insensitively.
+func (r *Registry) Register(format string, rd Renderer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.formats[strings.ToLower(strings.TrimSpace(format))] = rd
+}
+
+// Formats returns the names of the registered formats in alphabetical order.
+func (r *Registry) Formats() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.formats))
+	for name := range r.formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Negotiate picks the renderer for a request naming format, if not empty, and sending the
+// Accept header accept. Without either, or when accept allows anything, the fallback format is
+// picked. Among media types accepted equally, the fallback format wins, then the first in
+// alphabetical order. It reports false if no registered format is acceptable.
+func (r *Registry) Negotiate(format, accept string) (string, Renderer, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if format = strings.ToLower(strings.TrimSpace(format)); format != "" {
+		rd, ok := r.formats[format]
+		return format, rd, ok
+	}
+	if strings.TrimSpace(accept) == "" {
+		rd, ok := r.formats[r.fallback]
+		return r.fallback, rd, ok
+	}
+
+	names := make([]string, 0, len(r.formats))
+	for name := range r.formats {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == r.fallback) != (names[j] == r.fallback) {
+			return names[i] == r.fallback
+		}
+		return names[i] < names[j]
+	})
+	best, bestQ := "", 0.0
+	for _, name := range names {
+		if q := quality(accept, r.formats[name].ContentType()); q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+	if best == "" {
+		return "", nil, false
+	}
+	return best, r.formats[best], true
+}
+
+// quality returns the preference accept gives to the media type of contentType, from 0 (not
+// acceptable) to 1, matching the most specific of its media ranges.
+func quality(accept, contentType string) float64 {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return 0
+	}
+	kind, _, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, -1
+	for _, part := range strings.Split(accept, ",") {
+		rng, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		s := -1
+		switch {
+		case rng == mediaType:
+			s = 2
+		case rng == kind+"/*":
+			s = 1
+		case rng == "*/*":
+			s = 0
+		}
+		if s <= specificity {
+			continue
+		}
+		specificity, q = s, 1
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			} else {
+				q = 0
+			}
+		}
+	}
+	return q
+}
@@ -0,0 +1,213 @@
+package renderer
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// testRecipes returns a complete recipe followed by a sparse alternative.
+func testRecipes() []model.Recipe {
+	curry := model.NewRecipe("Chickpea Curry", []string{"400 g chickpeas", "1 can coconut milk"}, []string{"Fry the onion.", "Simmer for 20 minutes (stir often)."},
+		map[string]interface{}{"fat": 18.0, "calories": 450.0, "sodium": 600.0}, "Contains coconut.", []string{"stove"})
+	curry.TotalTime, curry.Difficulty = 90, model.DifficultyEasy
+	curry.Tags = []string{model.CuisineTag("indian")}
+	curry.ImageURL = "https://cdn.example.com/curry.jpg"
+	curry.Attribution = &model.Attribution{Author: "Meera Sodha", License: "CC-BY-4.0"}
+	curry.CreatedAt = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	return []model.Recipe{curry, model.NewRecipe("Dal", nil, nil, nil, "", nil)}
+}
+
+func TestNegotiate(t *testing.T) {
+	r := Builtin()
+	tests := []struct {
+		format, accept string
+		want           string
+	}{
+		{"", "", "json"},
+		{"Markdown", "application/json", "markdown"},
+		{"", "*/*", "json"},
+		{"", "text/markdown", "markdown"},
+		{"", "text/*", "markdown"},
+		{"", "application/ld+json, application/json;q=0.5", "jsonld"},
+		{"", "application/json;q=0.5, application/pdf", "pdf"},
+		{"", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "json"},
+		{"", "application/ssml+xml", "voice"},
+		{"", "image/png", ""},
+		{"", "application/pdf;q=0", ""},
+		{"yaml", "", ""},
+	}
+	for _, tt := range tests {
+		name, _, ok := r.Negotiate(tt.format, tt.accept)
+		if !ok {
+			name = ""
+		}
+		if name != tt.want {
+			t.Errorf("Negotiate(%q, %q) = %q, want %q", tt.format, tt.accept, name, tt.want)
+		}
+	}
+
+	r.Register("Text", Func{Type: "text/plain", Fn: func(w io.Writer, doc Document) error {
+		_, err := io.WriteString(w, doc.Recipes[0].Title)
+		return err
+	}})
+	if name, rd, ok := r.Negotiate("", "text/plain"); !ok || name != "text" || rd.ContentType() != "text/plain" {
+		t.Errorf("Expected the registered format, got %q", name)
+	}
+	if got := strings.Join(r.Formats(), ","); got != "json,jsonld,markdown,ndjson,pdf,text,voice" {
+		t.Errorf("Unexpected formats %s", got)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	var b strings.Builder
+	if err := (Markdown{}).Render(&b, Document{Recipes: testRecipes()}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# Chickpea Curry\n\n*Difficulty: easy · Total time: 1 h 30 min*\n",
+		"## Ingredients\n\n- 400 g chickpeas\n- 1 can coconut milk\n",
+		"## Steps\n\n1. Fry the onion.\n2. Simmer for 20 minutes (stir often).\n",
+		"## Nutrition\n\n- calories: 450 kcal\n- fat: 18 g\n- sodium: 600 mg\n",
+		"> Contains coconut.\n",
+		"*Adapted from Meera Sodha, CC-BY-4.0*\n",
+		"\n---\n\n# Dal\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Expected %q in:\n%s", want, b.String())
+		}
+	}
+}
+
+// flushBuffer is a bytes.Buffer counting its flushes.
+type flushBuffer struct {
+	bytes.Buffer
+	flushes int
+}
+
+func (b *flushBuffer) Flush() { b.flushes++ }
+
+func TestNDJSON(t *testing.T) {
+	var b flushBuffer
+	if err := (NDJSON{}).Render(&b, Document{Recipes: testRecipes(), Value: "ignored"}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != 2 || b.flushes != 2 {
+		t.Fatalf("Expected two lines, each flushed, got %d flushes of %s", b.flushes, b.String())
+	}
+	for i, want := range []string{"Chickpea Curry", "Dal"} {
+		var rec model.Recipe
+		if err := json.Unmarshal([]byte(lines[i]), &rec); err != nil || rec.Title != want {
+			t.Errorf("Expected %s on line %d, got %s", want, i+1, lines[i])
+		}
+	}
+}
+
+func TestJSONLD(t *testing.T) {
+	var b bytes.Buffer
+	if err := (JSONLD{}).Render(&b, Document{Recipes: testRecipes()[:1]}); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Context      string   `json:"@context"`
+		Type         string   `json:"@type"`
+		Name         string   `json:"name"`
+		TotalTime    string   `json:"totalTime"`
+		Ingredients  []string `json:"recipeIngredient"`
+		Instructions []struct {
+			Text string `json:"text"`
+		} `json:"recipeInstructions"`
+		Nutrition map[string]string `json:"nutrition"`
+		Published string            `json:"datePublished"`
+		Author    struct {
+			Name string `json:"name"`
+		} `json:"author"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Context != "https://schema.org" || got.Type != "Recipe" || got.Name != "Chickpea Curry" || got.TotalTime != "PT90M" || len(got.Ingredients) != 2 ||
+		len(got.Instructions) != 2 || got.Nutrition["calories"] != "450 kcal" || got.Nutrition["sodiumContent"] != "600 mg" || got.Published != "2026-03-01" || got.Author.Name != "Meera Sodha" {
+		t.Errorf("Unexpected JSON-LD %s", b.Bytes())
+	}
+
+	b.Reset()
+	(JSONLD{}).Render(&b, Document{Recipes: testRecipes()})
+	var graph struct {
+		Graph []map[string]interface{} `json:"@graph"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &graph); err != nil || len(graph.Graph) != 2 {
+		t.Errorf("Expected a graph of two recipes, got %s", b.Bytes())
+	}
+}
+
+func TestVoice(t *testing.T) {
+	var b bytes.Buffer
+	recipes := testRecipes()
+	recipes[0].Steps[0] = "Fry the onion & garlic <gently>."
+	if err := (Voice{}).Render(&b, Document{Recipes: recipes}); err != nil {
+		t.Fatal(err)
+	}
+	var ssml struct {
+		Paragraphs []string `xml:"p"`
+	}
+	if err := xml.Unmarshal(b.Bytes(), &ssml); err != nil {
+		t.Fatalf("Invalid SSML: %v\n%s", err, b.Bytes())
+	}
+	want := []string{
+		"Chickpea Curry.",
+		"It takes about 1 hour and 30 minutes.",
+		"You will need: 400 g chickpeas, 1 can coconut milk.",
+		"Step 1. Fry the onion & garlic <gently>.",
+		"Step 2. Simmer for 20 minutes (stir often).",
+		"Contains coconut.",
+		"You could also try: Dal.",
+	}
+	if strings.Join(ssml.Paragraphs, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected paragraphs %q, got %q", want, ssml.Paragraphs)
+	}
+}
+
+func TestPDF(t *testing.T) {
+	recipes := testRecipes()
+	for i := 0; i < 60; i++ {
+		recipes[1].Steps = append(recipes[1].Steps, "Stir the lentils with a wooden spoon until they break down into a creamy, golden purée.")
+	}
+	var b bytes.Buffer
+	if err := (PDF{}).Render(&b, Document{Recipes: recipes}); err != nil {
+		t.Fatal(err)
+	}
+	pdf := b.String()
+	if !strings.HasPrefix(pdf, "%PDF-1.4\n") || !strings.HasSuffix(pdf, "%%EOF\n") {
+		t.Fatalf("Not a PDF document:\n%s", pdf)
+	}
+	if !strings.Contains(pdf, "(Chickpea Curry) Tj") || !strings.Contains(pdf, `(\225 400 g chickpeas) Tj`) || !strings.Contains(pdf, `pur\351e.) Tj`) {
+		t.Errorf("Expected the recipe text in:\n%s", pdf)
+	}
+	// The curry fits on one page and the dal's 120 lines of steps start a new one and take
+	// three.
+	if n := strings.Count(pdf, "/Type /Page "); n != 4 {
+		t.Errorf("Expected 4 pages, got %d", n)
+	}
+
+	// Every cross-reference entry points at its object.
+	start, err := strconv.Atoi(regexp.MustCompile(`startxref\n(\d+)`).FindStringSubmatch(pdf)[1])
+	if err != nil || !strings.HasPrefix(pdf[start:], "xref\n") {
+		t.Fatalf("startxref does not point at the cross-reference table")
+	}
+	for i, m := range regexp.MustCompile(`(\d{10}) 00000 n`).FindAllStringSubmatch(pdf[start:], -1) {
+		off, _ := strconv.Atoi(m[1])
+		if !strings.HasPrefix(pdf[off:], strconv.Itoa(i+1)+" 0 obj") {
+			t.Errorf("Object %d is not at offset %d", i+1, off)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package renderer
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// nutrient is a line of nutrition facts.
+type nutrient struct {
+	Name string
+	// Amount is the amount with its unit, e.g. "12 g" or "420 kcal".
+	Amount string
+}
+
+// nutrients returns the nutrition facts of info, model.MacroNutrients first, then the others in
+// alphabetical order. Numbers are given in kilocalories for calories, milligrams for sodium and
+// cholesterol and grams otherwise; text such as "20 g" is kept as it is.
+func nutrients(info interface{}) []nutrient {
+	var values map[string]interface{}
+	switch m := info.(type) {
+	case map[string]interface{}:
+		values = m
+	case map[string]int:
+		values = make(map[string]interface{}, len(m))
+		for k, v := range m {
+			values[k] = v
+		}
+	default:
+		return nil
+	}
+	rank := func(name string) int {
+		for i, n := range model.MacroNutrients {
+			if n == name {
+				return i
+			}
+		}
+		return len(model.MacroNutrients)
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if ri, rj := rank(names[i]), rank(names[j]); ri != rj {
+			return ri < rj
+		}
+		return names[i] < names[j]
+	})
+
+	var out []nutrient
+	for _, name := range names {
+		var amount string
+		switch v := values[name].(type) {
+		case string:
+			amount = strings.TrimSpace(v)
+		case float64:
+			amount = strconv.FormatFloat(v, 'f', -1, 64)
+		case int:
+			amount = strconv.Itoa(v)
+		}
+		if amount == "" {
+			continue
+		}
+		if _, err := strconv.ParseFloat(amount, 64); err == nil {
+			switch name {
+			case "calories":
+				amount += " kcal"
+			case "sodium", "cholesterol":
+				amount += " mg"
+			default:
+				amount += " g"
+			}
+		}
+		out = append(out, nutrient{Name: name, Amount: amount})
+	}
+	return out
+}
+
+// duration writes minutes as e.g. "45 min" or "1 h 30 min".
+func duration(minutes int) string {
+	switch {
+	case minutes < 60:
+		return fmt.Sprintf("%d min", minutes)
+	case minutes%60 == 0:
+		return fmt.Sprintf("%d h", minutes/60)
+	}
+	return fmt.Sprintf("%d h %d min", minutes/60, minutes%60)
+}
+
+// summary returns the difficulty and total time of r, e.g. ["Difficulty: easy", "Total time:
+// 45 min"], leaving out those it does not give.
+func summary(r model.Recipe) []string {
+	var out []string
+	if r.Difficulty != "" {
+		out = append(out, "Difficulty: "+string(r.Difficulty))
+	}
+	if r.TotalTime > 0 {
+		out = append(out, "Total time: "+duration(r.TotalTime))
+	}
+	return out
+}
+
+// credit returns the attribution of r as a line, e.g. "Adapted from Serious Eats
+// (https://example.com/curry), CC-BY-4.0", or "" without one.
+func credit(r model.Recipe) string {
+	a := r.Attribution
+	if a == nil {
+		return ""
+	}
+	var parts []string
+	switch {
+	case a.Author != "" && a.SourceURL != "":
+		parts = append(parts, "Adapted from "+a.Author+" ("+a.SourceURL+")")
+	case a.Author != "":
+		parts = append(parts, "Adapted from "+a.Author)
+	case a.SourceURL != "":
+		parts = append(parts, "Adapted from "+a.SourceURL)
+	}
+	if a.License != "" {
+		parts = append(parts, a.License)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,71 @@
+package renderer
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Voice renders recipes as SSML for voice assistants to read aloud: the title, how long the
+// recipe takes, the ingredients, then each step as its own paragraph with a pause before it so
+// that listeners can keep up. Only the first recipe is read; the others are offered by title.
+type Voice struct{}
+
+// ContentType returns "application/ssml+xml".
+func (Voice) ContentType() string { return "application/ssml+xml" }
+
+// Render writes the recipes of doc.
+func (Voice) Render(w io.Writer, doc Document) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header + "<speak>\n")
+	say := func(format string, args ...interface{}) {
+		bw.WriteString("<p>")
+		xml.EscapeText(bw, []byte(fmt.Sprintf(format, args...)))
+		bw.WriteString("</p>\n")
+	}
+	if len(doc.Recipes) > 0 {
+		r := doc.Recipes[0]
+		say("%s.", r.Title)
+		if r.TotalTime > 0 {
+			say("It takes about %s.", spokenDuration(r.TotalTime))
+		}
+		if len(r.Ingredients) > 0 {
+			say("You will need: %s.", strings.Join(r.Ingredients, ", "))
+		}
+		for i, step := range r.Steps {
+			bw.WriteString(`<break time="1s"/>` + "\n")
+			say("Step %d. %s", i+1, step)
+		}
+		if d := strings.TrimSpace(r.AllergyDisclaimer); d != "" {
+			say("%s", d)
+		}
+		if len(doc.Recipes) > 1 {
+			titles := make([]string, len(doc.Recipes)-1)
+			for i, alt := range doc.Recipes[1:] {
+				titles[i] = alt.Title
+			}
+			say("You could also try: %s.", strings.Join(titles, ", or "))
+		}
+	}
+	bw.WriteString("</speak>\n")
+	return bw.Flush()
+}
+
+// spokenDuration writes minutes as they are said, e.g. "45 minutes" or "1 hour and 30 minutes".
+func spokenDuration(minutes int) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return "1 " + unit
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+	switch {
+	case minutes < 60:
+		return plural(minutes, "minute")
+	case minutes%60 == 0:
+		return plural(minutes/60, "hour")
+	}
+	return plural(minutes/60, "hour") + " and " + plural(minutes%60, "minute")
+}
@@ -0,0 +1,112 @@
+// Package report collects user reports of bad recipes, such as wrong quantities or unsafe
+// instructions, for triage by administrators.
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultThreshold is the number of open reports after which a recipe is unpublished.
+const DefaultThreshold = 3
+
+// Reason is why a recipe was reported.
+type Reason string
+
+// Reasons a recipe can be reported for.
+const (
+	ReasonWrongQuantities Reason = "wrong_quantities"
+	ReasonUnsafe          Reason = "unsafe"
+	ReasonSpam            Reason = "spam"
+	ReasonOther           Reason = "other"
+)
+
+// ParseReason parses one of the Reason constants.
+func ParseReason(s string) (Reason, error) {
+	switch r := Reason(strings.ToLower(strings.TrimSpace(s))); r {
+	case ReasonWrongQuantities, ReasonUnsafe, ReasonSpam, ReasonOther:
+		return r, nil
+	}
+	return "", fmt.Errorf("unknown reason %q (want wrong_quantities, unsafe, spam or other)", s)
+}
+
+// Report is one user's flag on a recipe.
+type Report struct {
+	RecipeID string    `json:"recipe_id"`
+	Reason   Reason    `json:"reason"`
+	Comment  string    `json:"comment,omitempty"`
+	Reporter string    `json:"reporter"`
+	Time     time.Time `json:"time"`
+}
+
+// Summary groups the open reports on a recipe.
+type Summary struct {
+	RecipeID string         `json:"recipe_id"`
+	Count    int            `json:"count"`
+	ByReason map[Reason]int `json:"by_reason"`
+	// Reports are the individual reports, oldest first.
+	Reports []Report `json:"reports"`
+}
+
+// Log holds the open reports on recipes until they are triaged. A reporter counts once per
+// recipe, except "anonymous", which stands for every caller when the service runs without
+// authentication. It is safe for concurrent use.
+type Log struct {
+	mu      sync.Mutex
+	reports map[string][]Report // by recipe ID, oldest first
+}
+
+// NewLog returns an empty Log.
+func NewLog() *Log {
+	return &Log{reports: make(map[string][]Report)}
+}
+
+// Add records rep, stamping it with the current time, and returns the number of open reports on
+// its recipe. A repeated report by the same reporter replaces the earlier one.
+func (l *Log) Add(rep Report) int {
+	rep.Time = time.Now().UTC()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	open := l.reports[rep.RecipeID]
+	for i, r := range open {
+		if r.Reporter == rep.Reporter && rep.Reporter != "anonymous" {
+			open = append(open[:i], open[i+1:]...)
+			break
+		}
+	}
+	l.reports[rep.RecipeID] = append(open, rep)
+	return len(l.reports[rep.RecipeID])
+}
+
+// Open returns the open reports grouped by recipe, most reported first.
+func (l *Log) Open() []Summary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]Summary, 0, len(l.reports))
+	for id, reports := range l.reports {
+		s := Summary{RecipeID: id, Count: len(reports), ByReason: make(map[Reason]int), Reports: append([]Report{}, reports...)}
+		for _, r := range reports {
+			s.ByReason[r.Reason]++
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].RecipeID < out[j].RecipeID
+	})
+	return out
+}
+
+// Resolve closes the open reports on a recipe and returns how many there were.
+func (l *Log) Resolve(recipeID string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := len(l.reports[recipeID])
+	delete(l.reports, recipeID)
+	return n
+}
@@ -0,0 +1,51 @@
+package report
+
+import "testing"
+
+// TestLog verifies that reports are counted once per reporter, grouped by recipe and closed by
+// Resolve.
+func TestLog(t *testing.T) {
+	l := NewLog()
+	l.Add(Report{RecipeID: "a", Reason: ReasonSpam, Reporter: "alice"})
+	if n := l.Add(Report{RecipeID: "a", Reason: ReasonUnsafe, Reporter: "alice"}); n != 1 {
+		t.Errorf("Expected a repeated report to replace the earlier one, got %d open", n)
+	}
+	l.Add(Report{RecipeID: "a", Reason: ReasonUnsafe, Reporter: "anonymous"})
+	if n := l.Add(Report{RecipeID: "a", Reason: ReasonWrongQuantities, Reporter: "anonymous"}); n != 3 {
+		t.Errorf("Expected anonymous reports to count separately, got %d open", n)
+	}
+	l.Add(Report{RecipeID: "b", Reason: ReasonSpam, Reporter: "bob"})
+
+	open := l.Open()
+	if len(open) != 2 || open[0].RecipeID != "a" || open[1].RecipeID != "b" {
+		t.Fatalf("Expected recipes a then b, got %+v", open)
+	}
+	if got := open[0].ByReason; got[ReasonUnsafe] != 2 || got[ReasonWrongQuantities] != 1 || got[ReasonSpam] != 0 {
+		t.Errorf("Unexpected reasons for a: %v", got)
+	}
+	if n := l.Resolve("a"); n != 3 {
+		t.Errorf("Expected 3 reports resolved, got %d", n)
+	}
+	if open := l.Open(); len(open) != 1 || open[0].RecipeID != "b" {
+		t.Errorf("Expected only b open, got %+v", open)
+	}
+}
+
+func TestParseReason(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Reason
+		wantErr bool
+	}{
+		{"unsafe", ReasonUnsafe, false},
+		{" Wrong_Quantities ", ReasonWrongQuantities, false},
+		{"boring", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseReason(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseReason(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
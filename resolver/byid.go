@@ -0,0 +1,86 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/pageza/recipe-resolver-ms/difficulty"
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// ErrRecipeNotFound is returned by Resolve for a Query.RecipeID that names no servable recipe.
+var ErrRecipeNotFound = errors.New("recipe not found")
+
+// DefaultSimilarRecipes is the number of alternatives returned with a recipe looked up by ID
+// when the query does not set Count.
+const DefaultSimilarRecipes = 3
+
+// get returns the stored recipe with the given ID, looked up directly if the store is a Getter.
+func (rs *Resolver) get(id string) (model.Recipe, bool) {
+	if g, ok := rs.Store.(Getter); ok {
+		return g.Get(id)
+	}
+	for _, r := range rs.Store.All() {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return model.Recipe{}, false
+}
+
+// MergedInto returns the stored recipe the recipe with the given ID or, if id is empty, slug was
+// merged into (see model.Recipe.Supersedes), asking store if it is a MergeFinder and scanning
+// its recipes otherwise.
+func MergedInto(store RecipeStore, id, slug string) (model.Recipe, bool) {
+	if f, ok := store.(MergeFinder); ok {
+		return f.MergedInto(id, slug)
+	}
+	for _, r := range store.All() {
+		if r.Supersedes(id, slug) {
+			return r, true
+		}
+	}
+	return model.Recipe{}, false
+}
+
+// resolveID answers a query for q.RecipeID without matching: the stored recipe, or the one it
+// was merged into if it is no longer stored, is Primary, an exact match, and the stored recipes
+// most like it (see recipeSimilarity) that meet q's constraints are its Alternatives, up to
+// q.Count-1 or DefaultSimilarRecipes.
+func (rs *Resolver) resolveID(ctx context.Context, q Query) (Result, error) {
+	r, ok := rs.get(q.RecipeID)
+	if !ok {
+		r, ok = MergedInto(rs.Store, q.RecipeID, "")
+	}
+	if !ok || !rs.Servable(r) {
+		return Result{}, ErrRecipeNotFound
+	}
+	rs.Logger.Printf("Resolver: Returning recipe %s requested by ID", r.ID)
+	n := DefaultSimilarRecipes
+	if q.Count > 1 {
+		n = q.Count - 1
+	}
+
+	q.Text = r.Title
+	type similar struct {
+		recipe model.Recipe
+		score  float64
+	}
+	var found []similar
+	for _, c := range rs.candidates(ctx, q) {
+		if c.ID == r.ID {
+			continue
+		}
+		if sim := recipeSimilarity(r, c); sim > 0 {
+			found = append(found, similar{c, sim})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].score > found[j].score })
+	alternatives := []model.Recipe{}
+	for _, s := range found[:min(n, len(found))] {
+		alternatives = append(alternatives, difficulty.Fill(s.recipe))
+	}
+	res := Result{Primary: difficulty.Fill(r), Alternatives: alternatives, Match: MatchExact, Score: 1}
+	return rs.limitAlternatives(res, q), ctx.Err()
+}
@@ -0,0 +1,133 @@
+package resolver
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/generation"
+)
+
+// DefaultDegradedCooldown is how long an automatic degradation lasts before the generator is
+// tried again.
+const DefaultDegradedCooldown = time.Minute
+
+// Degradation tracks whether the resolver runs in degraded mode, in which it never calls the
+// generator and answers with the best stored match instead. Degraded mode is either switched on
+// by an operator, or entered automatically after FailureThreshold consecutive generator
+// failures and left after Cooldown. Failures that show the provider is up, such as a malformed
+// or truncated reply (see outage), do not count. It is safe for concurrent use.
+type Degradation struct {
+	// FailureThreshold is the number of consecutive generator failures that trigger degraded
+	// mode; 0 disables automatic degradation.
+	FailureThreshold int
+	// Cooldown is how long an automatic degradation lasts. When it ends, the next generator
+	// failure re-enters degraded mode.
+	Cooldown time.Duration
+
+	mu       sync.Mutex
+	manual   bool
+	failures int
+	until    time.Time // end of the automatic degradation, if in the future
+	since    time.Time
+}
+
+// NewDegradation returns a Degradation that is off and only switched on manually.
+func NewDegradation() *Degradation {
+	return &Degradation{Cooldown: DefaultDegradedCooldown}
+}
+
+// DegradationStatus reports the state of a Degradation.
+type DegradationStatus struct {
+	Degraded bool `json:"degraded"`
+	// Manual is set when an operator switched degraded mode on.
+	Manual bool `json:"manual"`
+	// Until is the end of an automatic degradation.
+	Until *time.Time `json:"until,omitempty"`
+	// Since is when degraded mode was last entered.
+	Since *time.Time `json:"since,omitempty"`
+	// ConsecutiveFailures counts generator failures since the last success.
+	ConsecutiveFailures int `json:"consecutive_failures"`
+}
+
+// Active reports whether degraded mode is on. It is false for a nil Degradation.
+func (d *Degradation) Active() bool {
+	if d == nil {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.active(time.Now())
+}
+
+// active implements Active. Callers hold d.mu.
+func (d *Degradation) active(now time.Time) bool {
+	return d.manual || now.Before(d.until)
+}
+
+// Set switches manual degraded mode on or off. Switching it off also ends an automatic
+// degradation.
+func (d *Degradation) Set(on bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	if on && !d.active(now) {
+		d.since = now
+	}
+	d.manual = on
+	if !on {
+		d.until, d.failures = time.Time{}, 0
+	}
+}
+
+// observe records the outcome of a generator call. It does nothing on a nil Degradation.
+func (d *Degradation) observe(err error) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err == nil {
+		d.failures = 0
+		return
+	}
+	if !outage(err) {
+		return
+	}
+	d.failures++
+	now := time.Now()
+	if d.FailureThreshold > 0 && d.failures >= d.FailureThreshold && !d.active(now) {
+		d.until, d.since = now.Add(d.Cooldown), now
+	}
+}
+
+// Status returns the current state of d.
+func (d *Degradation) Status() DegradationStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	s := DegradationStatus{Degraded: d.active(now), Manual: d.manual, ConsecutiveFailures: d.failures}
+	if now.Before(d.until) {
+		until := d.until
+		s.Until = &until
+	}
+	if s.Degraded {
+		since := d.since
+		s.Since = &since
+	}
+	return s
+}
+
+// outage reports whether a generator error suggests the provider is unavailable, as opposed to
+// the provider answering with a reply that could not be used or rejecting this one request.
+func outage(err error) bool {
+	var status *generation.ErrProviderStatus
+	switch {
+	case errors.As(err, &status):
+		return status.Temporary()
+	case errors.Is(err, generation.ErrUnparseableResponse), errors.Is(err, generation.ErrTruncated),
+		errors.Is(err, generation.ErrPromptTooLong):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,553 @@
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pageza/recipe-resolver-ms/cache"
+	"github.com/pageza/recipe-resolver-ms/generation"
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/nlp"
+)
+
+// RecipeStore provides the recipes the resolver matches queries against. It is all a storage
+// backend must implement; backends may also implement Getter, Lister, Searcher and MergeFinder,
+// which the resolver and server use when available, Writer, which the write endpoints require,
+// Saver, and Transactor for atomic units of work. Storage combines those of a complete backend.
+type RecipeStore interface {
+	All() []model.Recipe
+}
+
+// Storage is a complete recipe storage backend: it gets, lists, searches and saves recipes.
+// MemoryStore, the default, and the SQL and MongoDB stores implement it, so that the service
+// swaps one for another without the resolver noticing.
+type Storage interface {
+	RecipeStore
+	Getter
+	Lister
+	Searcher
+	Saver
+}
+
+// Getter is implemented by recipe stores that look up a recipe by ID without listing them all,
+// e.g. by primary key.
+type Getter interface {
+	Get(id string) (model.Recipe, bool)
+}
+
+// Lister is implemented by recipe stores that select the recipes a Filter keeps themselves,
+// e.g. in a query, rather than listing them all. List returns them in the order they were
+// added.
+type Lister interface {
+	List(ctx context.Context, f Filter) ([]model.Recipe, error)
+}
+
+// Saver is implemented by recipe stores that store a recipe whether or not its ID is stored
+// yet, reporting why they could not: Save adds r as Writer.Add does, or replaces the stored
+// recipe as Writer.Update does.
+type Saver interface {
+	Save(ctx context.Context, r model.Recipe) error
+}
+
+// MergeFinder is implemented by recipe stores that find the recipe another was merged into
+// without scanning every recipe, e.g. with an index of model.Recipe.MergedFrom. MergedInto
+// returns the stored recipe the recipe with the given ID or, if id is empty, slug was merged
+// into (see model.Recipe.Supersedes).
+type MergeFinder interface {
+	MergedInto(id, slug string) (model.Recipe, bool)
+}
+
+// Searcher is implemented by recipe stores that select the candidates for a query themselves,
+// e.g. with a full-text index, so that the resolver does not load every recipe to score it.
+// Search returns up to limit recipes that share words with query, or all of them if limit is 0,
+// best first; ties in the resolver's own ranking go to the earlier recipe. The resolver asks for
+// a few times its SearchLimit and applies its filters (servability, constraints) before that
+// limit.
+type Searcher interface {
+	Search(ctx context.Context, query string, limit int) ([]model.Recipe, error)
+}
+
+// FilteredSearcher is implemented by Searcher stores that also apply a Filter in their search,
+// so that the recipes it leaves out do not count towards the limit. The resolver passes the
+// filter of the recipes it serves.
+type FilteredSearcher interface {
+	SearchFiltered(ctx context.Context, query string, limit int, f Filter) ([]model.Recipe, error)
+}
+
+// Filter selects recipes by review status and archiving.
+type Filter struct {
+	// Statuses, if not empty, keeps the recipes in one of these review statuses. A recipe
+	// without a status is published.
+	Statuses []model.Status
+	// Unarchived leaves out archived recipes.
+	Unarchived bool
+}
+
+// Match reports whether f keeps r.
+func (f Filter) Match(r model.Recipe) bool {
+	if f.Unarchived && r.Archived {
+		return false
+	}
+	if len(f.Statuses) == 0 {
+		return true
+	}
+	status := r.Status
+	if r.Published() {
+		status = model.StatusPublished
+	}
+	for _, st := range f.Statuses {
+		if st == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Reindexer is implemented by recipe stores that derive their search index themselves, such as
+// SQL stores, so that it can be rebuilt from the stored recipes, e.g. after an upgrade changed
+// how text is tokenized. Reindex returns the number of recipes indexed.
+type Reindexer interface {
+	Reindex(ctx context.Context) (int, error)
+}
+
+// Scorer rates how similar a query is to a recipe title, from 0 (unrelated) to 1 (identical).
+type Scorer interface {
+	Score(query, title string) float64
+}
+
+// Generator produces a new recipe (and optional alternatives) for a query that has no
+// match in the store. The default implementation calls the configured LLM provider.
+type Generator interface {
+	Generate(ctx context.Context, query string) (generation.Recipe, []generation.Recipe, error)
+}
+
+// Cache stores resolution results keyed by normalized query so that repeated queries
+// do not trigger another generation round-trip.
+type Cache interface {
+	Get(key string) (Result, bool)
+	Set(key string, result Result)
+	// Flush removes every entry and returns how many were removed.
+	Flush() int
+}
+
+// MemoryStore is a RecipeStore backed by an in-memory slice of recipes. It is safe for
+// concurrent use: readers get immutable snapshots and never wait for writers, which replace the
+// snapshot atomically with an updated copy.
+type MemoryStore struct {
+	// OnChange, if non-nil, is called with the IDs of the recipes changed by Update, Delete or
+	// Archive, after the change, e.g. to invalidate cached results (see Resolver.Invalidate).
+	OnChange func(ids []string)
+
+	mu       sync.Mutex // serializes writers
+	snapshot atomic.Pointer[storeSnapshot]
+}
+
+// storeSnapshot is an immutable version of a MemoryStore's contents.
+type storeSnapshot struct {
+	recipes []model.Recipe
+	version uint64
+
+	indexOnce sync.Once
+	tokens    tokenIndex // see index
+
+	mergedOnce  sync.Once
+	mergedIDs   map[string]int // merged recipe ID -> index of the recipe it was merged into
+	mergedSlugs map[string]int // likewise by merged recipe slug
+}
+
+// NewMemoryStore returns a MemoryStore serving the given recipes, with slugs assigned to those
+// lacking one as by Add.
+func NewMemoryStore(recipes []model.Recipe) *MemoryStore {
+	s := &MemoryStore{}
+	s.snapshot.Store(&storeSnapshot{recipes: withSlugs(nil, recipes)})
+	return s
+}
+
+// All returns every recipe in the store. The returned slice must not be modified; later writes
+// to the store do not affect it.
+func (s *MemoryStore) All() []model.Recipe {
+	return s.load().recipes
+}
+
+// Version returns a number that increases with every write to the store, e.g. for callers that
+// cache data derived from All.
+func (s *MemoryStore) Version() uint64 {
+	return s.load().version
+}
+
+// load returns the current snapshot; the zero MemoryStore is empty.
+func (s *MemoryStore) load() *storeSnapshot {
+	if snap := s.snapshot.Load(); snap != nil {
+		return snap
+	}
+	return &storeSnapshot{}
+}
+
+// write replaces the store's recipes with the result of applying f to a copy of them. f reports
+// whether it changed anything; if not, the snapshot is kept. write returns f's report.
+func (s *MemoryStore) write(f func(recipes []model.Recipe) ([]model.Recipe, bool)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old := s.load()
+	recipes, changed := f(append([]model.Recipe(nil), old.recipes...))
+	if changed {
+		s.snapshot.Store(&storeSnapshot{recipes: recipes, version: old.version + 1})
+	}
+	return changed
+}
+
+// Add appends recipes to the store. Recipes without a slug get one derived from their title,
+// and slugs already taken get a numeric suffix, e.g. "pancakes-2". Recipes whose ID is already
+// stored are skipped and reported with ErrExists.
+func (s *MemoryStore) Add(recipes ...model.Recipe) error {
+	var err error
+	s.write(func(all []model.Recipe) ([]model.Recipe, bool) {
+		stored := make(map[string]bool, len(all)+len(recipes))
+		for _, r := range all {
+			stored[r.ID] = true
+		}
+		var added []model.Recipe
+		err = nil
+		for _, r := range recipes {
+			if r.ID != "" && stored[r.ID] {
+				err = errors.Join(err, fmt.Errorf("adding recipe %s: %w", r.ID, ErrExists))
+				continue
+			}
+			stored[r.ID] = true
+			added = append(added, r)
+		}
+		return withSlugs(all, added), len(added) > 0
+	})
+	return err
+}
+
+// Update replaces the stored recipe with the same ID as r and reports whether one was found.
+// The recipe keeps its slug unless r sets a different one that is not taken, so that links to
+// it stay valid when its title changes.
+func (s *MemoryStore) Update(r model.Recipe) bool {
+	updated := s.write(func(all []model.Recipe) ([]model.Recipe, bool) {
+		for i := range all {
+			if all[i].ID != r.ID {
+				continue
+			}
+			if r.Slug != all[i].Slug && (r.Slug == "" || slugTaken(all, r.Slug)) {
+				r.Slug = all[i].Slug
+			}
+			all[i] = r
+			return all, true
+		}
+		return all, false
+	})
+	if updated {
+		s.changed([]string{r.ID})
+	}
+	return updated
+}
+
+// Delete removes the recipes with the given IDs and returns how many were removed.
+func (s *MemoryStore) Delete(ids ...string) int {
+	del := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		del[id] = true
+	}
+	var removed []string
+	s.write(func(all []model.Recipe) ([]model.Recipe, bool) {
+		kept := all[:0]
+		removed = nil
+		for _, r := range all {
+			if del[r.ID] {
+				removed = append(removed, r.ID)
+			} else {
+				kept = append(kept, r)
+			}
+		}
+		return kept, len(removed) > 0
+	})
+	s.changed(removed)
+	return len(removed)
+}
+
+// Archive sets the Archived flag of the recipes with the given IDs and returns how many
+// changed.
+func (s *MemoryStore) Archive(archived bool, ids ...string) int {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	var changed []string
+	s.write(func(all []model.Recipe) ([]model.Recipe, bool) {
+		for i := range all {
+			if set[all[i].ID] && all[i].Archived != archived {
+				all[i].Archived = archived
+				changed = append(changed, all[i].ID)
+			}
+		}
+		return all, len(changed) > 0
+	})
+	s.changed(changed)
+	return len(changed)
+}
+
+// changed reports the changed recipes ids to OnChange.
+func (s *MemoryStore) changed(ids []string) {
+	if len(ids) > 0 && s.OnChange != nil {
+		s.OnChange(ids)
+	}
+}
+
+// Get returns the recipe with the given ID.
+func (s *MemoryStore) Get(id string) (model.Recipe, bool) {
+	for _, r := range s.All() {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return model.Recipe{}, false
+}
+
+// BySlug returns the recipe with the given slug.
+func (s *MemoryStore) BySlug(slug string) (model.Recipe, bool) {
+	for _, r := range s.All() {
+		if r.Slug == slug {
+			return r, true
+		}
+	}
+	return model.Recipe{}, false
+}
+
+// MergedInto implements MergeFinder with an index of the recipes' MergedFrom, built on first
+// use for each version of the store.
+func (s *MemoryStore) MergedInto(id, slug string) (model.Recipe, bool) {
+	snap := s.load()
+	snap.mergedOnce.Do(func() {
+		snap.mergedIDs, snap.mergedSlugs = make(map[string]int), make(map[string]int)
+		for i, r := range snap.recipes {
+			for _, m := range r.MergedFrom {
+				if _, ok := snap.mergedIDs[m.ID]; !ok {
+					snap.mergedIDs[m.ID] = i
+				}
+				if _, ok := snap.mergedSlugs[m.Slug]; !ok && m.Slug != "" {
+					snap.mergedSlugs[m.Slug] = i
+				}
+			}
+		}
+	})
+	i, ok := snap.mergedIDs[id]
+	if id == "" {
+		i, ok = snap.mergedSlugs[slug]
+	}
+	if !ok {
+		return model.Recipe{}, false
+	}
+	return snap.recipes[i], true
+}
+
+// List implements Lister.
+func (s *MemoryStore) List(ctx context.Context, f Filter) ([]model.Recipe, error) {
+	var recipes []model.Recipe
+	for _, r := range s.All() {
+		if f.Match(r) {
+			recipes = append(recipes, r)
+		}
+	}
+	return recipes, ctx.Err()
+}
+
+// Save implements Saver.
+func (s *MemoryStore) Save(ctx context.Context, r model.Recipe) error {
+	if s.Update(r) {
+		return nil
+	}
+	return s.Add(r)
+}
+
+// withSlugs appends added to recipes, giving each added recipe a slug not used by any other.
+func withSlugs(recipes, added []model.Recipe) []model.Recipe {
+	taken := make(map[string]bool, len(recipes)+len(added))
+	for _, r := range recipes {
+		taken[r.Slug] = true
+	}
+	for _, r := range added {
+		base := r.Slug
+		if base == "" {
+			base = model.Slugify(r.Title)
+		}
+		r.Slug = base
+		for n := 2; taken[r.Slug]; n++ {
+			r.Slug = fmt.Sprintf("%s-%d", base, n)
+		}
+		taken[r.Slug] = true
+		recipes = append(recipes, r)
+	}
+	return recipes
+}
+
+// slugTaken reports whether a recipe in recipes has the given slug.
+func slugTaken(recipes []model.Recipe, slug string) bool {
+	for _, r := range recipes {
+		if r.Slug == slug {
+			return true
+		}
+	}
+	return false
+}
+
+// SampleRecipes returns the sample database of recipes the service ships with.
+// It is used to perform matching based on the incoming query when no other store is configured.
+func SampleRecipes() []model.Recipe {
+	return []model.Recipe{
+		model.NewRecipe(
+			"Spaghetti Bolognese",
+			[]string{"spaghetti", "tomato sauce", "ground beef", "onion", "garlic"},
+			[]string{"Boil pasta", "Cook sauce", "Mix and serve"},
+			map[string]int{"calories": 400},
+			"Contains gluten",
+			[]string{"stove"},
+		),
+		model.NewRecipe(
+			"Chicken Salad",
+			[]string{"chicken", "lettuce", "tomatoes", "cucumber", "dressing"},
+			[]string{"Grill chicken", "Mix vegetables", "Add dressing"},
+			map[string]int{"calories": 300},
+			"None",
+			[]string{"grill"},
+		),
+	}
+}
+
+// LoadRecipesFile reads a JSON array of recipes from path, for use with NewMemoryStore.
+func LoadRecipesFile(path string) ([]model.Recipe, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var recipes []model.Recipe
+	if err := json.Unmarshal(data, &recipes); err != nil {
+		return nil, fmt.Errorf("parsing recipes file %s: %w", path, err)
+	}
+	return recipes, nil
+}
+
+// SaveRecipesFile writes recipes to path as a JSON array, as read by LoadRecipesFile. The file
+// is replaced atomically, so that readers never see a partial write.
+func SaveRecipesFile(path string, recipes []model.Recipe) error {
+	data, err := json.MarshalIndent(recipes, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// JaccardScorer scores titles using token-level Jaccard similarity.
+type JaccardScorer struct{}
+
+// Score implements Scorer.
+func (JaccardScorer) Score(query, title string) float64 {
+	return nlp.JaccardSimilarity(query, title)
+}
+
+// OverlapScorer scores titles using the token overlap coefficient, which rewards queries
+// whose words all appear in the title regardless of how long the title is.
+type OverlapScorer struct{}
+
+// Score implements Scorer.
+func (OverlapScorer) Score(query, title string) float64 {
+	return nlp.OverlapCoefficient(query, title)
+}
+
+// LLMGenerator is a Generator that delegates to generation.GenerateRecipeContext.
+type LLMGenerator struct{}
+
+// Generate implements Generator.
+func (LLMGenerator) Generate(ctx context.Context, query string) (generation.Recipe, []generation.Recipe, error) {
+	return generation.GenerateRecipeContext(ctx, query)
+}
+
+// DefaultCacheBytes bounds the approximate size of a MemoryCache or SemanticCache created with
+// default options.
+const DefaultCacheBytes = 64 << 20
+
+// MemoryCache is a concurrency-safe in-memory Cache, bounded in size (see cache.Cache).
+type MemoryCache struct {
+	entries *cache.Cache[Result]
+}
+
+// NewMemoryCache returns an empty MemoryCache of at most DefaultCacheBytes that evicts the least
+// recently used results first.
+func NewMemoryCache() *MemoryCache {
+	return NewBoundedMemoryCache(cache.Options{MaxBytes: DefaultCacheBytes})
+}
+
+// NewBoundedMemoryCache returns an empty MemoryCache bounded by opts.
+func NewBoundedMemoryCache(opts cache.Options) *MemoryCache {
+	return &MemoryCache{entries: cache.New(opts, resultSize)}
+}
+
+// resultSize approximates the memory a cached result takes by the size of its JSON encoding.
+func resultSize(res Result) int64 {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return 0
+	}
+	return int64(len(data))
+}
+
+// Get implements Cache.
+func (c *MemoryCache) Get(key string) (Result, bool) {
+	return c.entries.Get(key)
+}
+
+// Set implements Cache.
+func (c *MemoryCache) Set(key string, result Result) {
+	c.entries.Set(key, result)
+}
+
+// Flush implements Cache.
+func (c *MemoryCache) Flush() int {
+	return c.entries.Flush()
+}
+
+// Stats returns the cache's size and hit, miss and eviction counts.
+func (c *MemoryCache) Stats() cache.Stats {
+	return c.entries.Stats()
+}
+
+// DeleteFunc removes the entries whose key del returns true for and returns how many were
+// removed.
+func (c *MemoryCache) DeleteFunc(del func(key string) bool) int {
+	return c.entries.DeleteFunc(func(key string, _ Result) bool { return del(key) })
+}
+
+// DeleteResultFunc removes the entries whose result del returns true for and returns how many
+// were removed.
+func (c *MemoryCache) DeleteResultFunc(del func(Result) bool) int {
+	return c.entries.DeleteFunc(func(_ string, res Result) bool { return del(res) })
+}
+
+// CacheStats returns the statistics of the resolver's caches that keep them, by name: "results"
+// for Cache and "semantic" for Semantic.
+func (rs *Resolver) CacheStats() map[string]cache.Stats {
+	stats := make(map[string]cache.Stats)
+	if c, ok := rs.Cache.(interface{ Stats() cache.Stats }); ok {
+		stats["results"] = c.Stats()
+	}
+	if rs.Semantic != nil {
+		stats["semantic"] = rs.Semantic.Stats()
+	}
+	return stats
+}
+
+// cacheKey normalizes a query so that trivially different spellings share a cache entry.
+func cacheKey(query string) string {
+	return strings.Join(nlp.Tokenize(query), " ")
+}
@@ -0,0 +1,67 @@
+package resolver
+
+import (
+	"strings"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/nlp"
+)
+
+// diversify reorders alternatives by maximal marginal relevance: each pick maximizes
+//
+//	(1-diversity)*relevance - diversity*redundancy
+//
+// where relevance is the similarity of the alternative's title to query and redundancy its
+// highest similarity to primary or an earlier pick (see recipeSimilarity). Alternatives whose
+// redundancy exceeds 1-diversity are near-identical variants and are dropped. A diversity of 0
+// keeps alternatives as they are.
+func diversify(scorers []WeightedScorer, query string, primary model.Recipe, alternatives []model.Recipe, diversity float64) []model.Recipe {
+	if diversity <= 0 || len(alternatives) == 0 {
+		return alternatives
+	}
+	diversity = min(diversity, 1)
+	relevance := make([]float64, len(alternatives))
+	redundancy := make([]float64, len(alternatives))
+	for i, alt := range alternatives {
+		relevance[i] = score(scorers, query, alt.Title)
+		redundancy[i] = recipeSimilarity(primary, alt)
+	}
+	left := make([]bool, len(alternatives))
+	for i := range left {
+		left[i] = true
+	}
+	picked := make([]model.Recipe, 0, len(alternatives))
+	for {
+		best, bestMMR := -1, 0.0
+		for i := range alternatives {
+			if !left[i] {
+				continue
+			}
+			if redundancy[i] > 1-diversity {
+				left[i] = false
+				continue
+			}
+			if mmr := (1-diversity)*relevance[i] - diversity*redundancy[i]; best < 0 || mmr > bestMMR {
+				best, bestMMR = i, mmr
+			}
+		}
+		if best < 0 {
+			return picked
+		}
+		left[best] = false
+		picked = append(picked, alternatives[best])
+		for i, alt := range alternatives {
+			if left[i] {
+				redundancy[i] = max(redundancy[i], recipeSimilarity(alternatives[best], alt))
+			}
+		}
+	}
+}
+
+// recipeSimilarity rates how alike two recipes are, from 0 to 1: the mean of the token Jaccard
+// similarity of their titles and of their ingredient lists.
+func recipeSimilarity(a, b model.Recipe) float64 {
+	titles := nlp.JaccardSimilarity(a.Title, b.Title)
+	ingredients := nlp.JaccardSimilarity(strings.Join(a.Ingredients, " "), strings.Join(b.Ingredients, " "))
+	return (titles + ingredients) / 2
+}
@@ -0,0 +1,104 @@
+package resolver
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/nlp"
+)
+
+// tokenIndex is an inverted index over a snapshot's recipes, mapping each token of their
+// titles, ingredients and, for persisted generations, the query they were generated for (see
+// model.Recipe.GeneratedFor) to the recipes containing it, in order.
+type tokenIndex map[string][]posting
+
+// posting records a recipe containing a token, by position in the snapshot.
+type posting struct {
+	recipe int
+	// title is whether the token is in the recipe's title or generation query, which weighs
+	// more than an ingredient when ranking search results.
+	title bool
+}
+
+// buildIndex indexes recipes.
+func buildIndex(recipes []model.Recipe) tokenIndex {
+	idx := make(tokenIndex)
+	for i, r := range recipes {
+		add := func(text string, title bool) {
+			for _, token := range nlp.Tokenize(text) {
+				list := idx[token]
+				if n := len(list); n > 0 && list[n-1].recipe == i {
+					list[n-1].title = list[n-1].title || title
+					continue
+				}
+				idx[token] = append(list, posting{recipe: i, title: title})
+			}
+		}
+		add(r.Title, true)
+		if r.Provenance != nil {
+			add(r.Provenance.Query, true)
+		}
+		for _, ing := range r.Ingredients {
+			add(ing, false)
+		}
+	}
+	return idx
+}
+
+// index returns the snapshot's inverted index, building it on first use: writes replace the
+// snapshot, so each version of the store is indexed once.
+func (snap *storeSnapshot) index() tokenIndex {
+	snap.indexOnce.Do(func() { snap.tokens = buildIndex(snap.recipes) })
+	return snap.tokens
+}
+
+// Search implements Searcher with the store's inverted index: it returns up to limit recipes
+// sharing at least one token with query, so that matching scores only those instead of every
+// recipe. Recipes sharing the most tokens come first, a token in the title counting twice as
+// much as one in the ingredients; ties keep store order.
+func (s *MemoryStore) Search(ctx context.Context, query string, limit int) ([]model.Recipe, error) {
+	return s.SearchFiltered(ctx, query, limit, Filter{})
+}
+
+// SearchFiltered implements FilteredSearcher like Search, leaving out the recipes f does not
+// keep before the limit.
+func (s *MemoryStore) SearchFiltered(ctx context.Context, query string, limit int, f Filter) ([]model.Recipe, error) {
+	snap := s.load()
+	idx := snap.index()
+	hits := make(map[int]int)
+	seen := make(map[string]bool)
+	for _, token := range nlp.Tokenize(query) {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+		for _, p := range idx[token] {
+			if !f.Match(snap.recipes[p.recipe]) {
+				continue
+			}
+			hits[p.recipe]++
+			if p.title {
+				hits[p.recipe]++
+			}
+		}
+	}
+	positions := make([]int, 0, len(hits))
+	for i := range hits {
+		positions = append(positions, i)
+	}
+	sort.Slice(positions, func(a, b int) bool {
+		if ha, hb := hits[positions[a]], hits[positions[b]]; ha != hb {
+			return ha > hb
+		}
+		return positions[a] < positions[b]
+	})
+	if limit > 0 && len(positions) > limit {
+		positions = positions[:limit]
+	}
+	recipes := make([]model.Recipe, len(positions))
+	for n, i := range positions {
+		recipes[n] = snap.recipes[i]
+	}
+	return recipes, ctx.Err()
+}
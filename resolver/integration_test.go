@@ -0,0 +1,89 @@
+package resolver
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/generation"
+	"github.com/pageza/recipe-resolver-ms/generation/providertest"
+)
+
+// providerResponse is the reply of the mock provider in the integration tests.
+var providerResponse = generation.LLMResponse{
+	PrimaryRecipe: generation.Recipe{ID: "gen-soup", Title: "Chicken Noodle Soup"},
+}
+
+// TestResolveWithFaultyProvider runs the resolver against the real LLM generator talking to a
+// mock provider, verifying that every injected fault degrades to the fallback recipe and that
+// a healthy provider yields the generated recipe.
+func TestResolveWithFaultyProvider(t *testing.T) {
+	prevClient := generation.HTTPClient
+	generation.HTTPClient = &http.Client{Timeout: 100 * time.Millisecond}
+	defer func() { generation.HTTPClient = prevClient }()
+
+	cases := []struct {
+		name      string
+		deepSeek  bool
+		faults    providertest.Faults
+		wantMatch MatchKind
+	}{
+		{name: "healthy", wantMatch: MatchGenerated},
+		{name: "healthy deepseek", deepSeek: true, wantMatch: MatchGenerated},
+		{name: "slow", faults: providertest.Faults{Latency: 300 * time.Millisecond}, wantMatch: MatchFallback},
+		{name: "connection reset", faults: providertest.Faults{ResetConnection: true}, wantMatch: MatchFallback},
+		{name: "malformed json", faults: providertest.Faults{MalformedJSON: true}, wantMatch: MatchFallback},
+		{name: "partial body", faults: providertest.Faults{PartialBody: true}, wantMatch: MatchFallback},
+		{name: "partial body deepseek", deepSeek: true, faults: providertest.Faults{PartialBody: true}, wantMatch: MatchFallback},
+		{name: "rate limited", faults: providertest.Faults{RateLimitBurst: 3}, wantMatch: MatchFallback},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			provider := providertest.NewServer(providerResponse, tc.deepSeek)
+			defer provider.Close()
+			provider.SetFaults(tc.faults)
+			t.Setenv("LLM_ENDPOINT", provider.URL)
+			if tc.deepSeek {
+				t.Setenv("DEEPSEEK_API_KEY", "test-key")
+			} else {
+				t.Setenv("DEEPSEEK_API_KEY", "")
+			}
+
+			res, err := newTestResolver(LLMGenerator{}).Resolve(context.Background(), Query{Text: "chicken noodle soup"})
+			if err != nil {
+				t.Fatalf("Resolve returned error: %v", err)
+			}
+			if res.Match != tc.wantMatch {
+				t.Errorf("Expected match %q, got %q", tc.wantMatch, res.Match)
+			}
+			if provider.Requests() != 1 {
+				t.Errorf("Expected 1 provider request, got %d", provider.Requests())
+			}
+		})
+	}
+}
+
+// TestProviderRateLimitBurstRecovers verifies that the mock stops rate limiting once the burst
+// is exhausted, so callers that retry eventually succeed.
+func TestProviderRateLimitBurstRecovers(t *testing.T) {
+	provider := providertest.NewServer(providerResponse, false)
+	defer provider.Close()
+	provider.SetFaults(providertest.Faults{RateLimitBurst: 2})
+	t.Setenv("LLM_ENDPOINT", provider.URL)
+	t.Setenv("DEEPSEEK_API_KEY", "")
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := generation.GenerateRecipe("soup"); err == nil {
+			t.Fatalf("Expected request %d to be rate limited", i+1)
+		}
+	}
+	primary, _, err := generation.GenerateRecipe("soup")
+	if err != nil {
+		t.Fatalf("Expected provider to recover after the burst, got %v", err)
+	}
+	if primary.ID != "gen-soup" {
+		t.Errorf("Expected primary recipe ID 'gen-soup', got '%s'", primary.ID)
+	}
+}
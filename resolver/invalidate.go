@@ -0,0 +1,69 @@
+package resolver
+
+import (
+	"context"
+	"slices"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// InvalidationBus carries the IDs of changed recipes between replicas, so that each drops the
+// cached results that served them.
+type InvalidationBus interface {
+	// Publish announces that the recipes ids changed.
+	Publish(ctx context.Context, ids []string) error
+	// Subscribe calls handle with the IDs of every announcement, including this replica's own,
+	// until ctx is done or the subscription fails.
+	Subscribe(ctx context.Context, handle func(ids []string)) error
+}
+
+// Invalidate drops every cached result whose primary recipe or alternatives include one of the
+// recipes ids, from both the exact and the semantic cache along with its query embedding, and
+// announces the IDs on rs.Bus for the other replicas. Call it after updating or deleting stored
+// recipes. It returns the number of entries dropped here.
+func (rs *Resolver) Invalidate(ctx context.Context, ids ...string) int {
+	if len(ids) == 0 {
+		return 0
+	}
+	n := rs.drop(ids)
+	if rs.Bus != nil {
+		if err := rs.Bus.Publish(ctx, ids); err != nil {
+			rs.Logger.Printf("Resolver: Could not announce the invalidation of %d recipes: %v", len(ids), err)
+		}
+	}
+	return n
+}
+
+// Listen applies the invalidations announced on rs.Bus by other replicas until ctx is done or
+// the subscription fails, and returns why it stopped. Without a Bus it returns nil at once.
+func (rs *Resolver) Listen(ctx context.Context) error {
+	if rs.Bus == nil {
+		return nil
+	}
+	return rs.Bus.Subscribe(ctx, func(ids []string) {
+		if n := rs.drop(ids); n > 0 {
+			rs.Logger.Printf("Resolver: Dropped %d cached results for %d changed recipes", n, len(ids))
+		}
+	})
+}
+
+// drop removes the cached results that include one of the recipes ids and returns how many
+// were removed.
+func (rs *Resolver) drop(ids []string) int {
+	stale := func(res Result) bool {
+		if slices.Contains(ids, res.Primary.ID) {
+			return true
+		}
+		return slices.ContainsFunc(res.Alternatives, func(r model.Recipe) bool { return slices.Contains(ids, r.ID) })
+	}
+	n := 0
+	if c, ok := rs.Cache.(interface {
+		DeleteResultFunc(func(Result) bool) int
+	}); ok {
+		n += c.DeleteResultFunc(stale)
+	}
+	if rs.Semantic != nil {
+		n += rs.Semantic.DeleteResultFunc(stale)
+	}
+	return n
+}
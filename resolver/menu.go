@@ -0,0 +1,97 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pageza/recipe-resolver-ms/difficulty"
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/qos"
+)
+
+// MenuCourse is a course of a menu with the recipe resolved for it.
+type MenuCourse struct {
+	Course string
+	Result Result
+}
+
+// ResolveMenu resolves a menu of courses, e.g. model.Courses, for the occasion and constraints
+// of q, in order. Each course is a stored recipe tagged for it (see model.CourseTag) that meets
+// q's constraints and resembles its text, if there is one not already on the menu; otherwise it
+// is generated knowing the dishes chosen so far, so that the menu hangs together. A course
+// whose generation fails gets a fallback recipe. ResolveMenu only fails if ctx is done or, with
+// qos.ErrBusy, if a course needs a generation while Generations is full.
+func (rs *Resolver) ResolveMenu(ctx context.Context, q Query, courses []string) ([]MenuCourse, error) {
+	rs.Logger.Printf("Resolver: Resolving a menu of %v for query: %q", courses, q.Text)
+	if q.Occasions == nil {
+		q.Occasions = rs.Occasions.detect(q.Text)
+	}
+	menu := make([]MenuCourse, 0, len(courses))
+	used := make(map[string]bool)
+	var titles []string
+	for _, course := range courses {
+		cq := q
+		cq.Course, cq.Companions, cq.Count = course, titles, 0
+		res, err := rs.resolveCourse(ctx, cq, used)
+		if err != nil {
+			return nil, err
+		}
+		res = rs.withAllergens(res)
+		used[res.Primary.ID] = true
+		titles = append(titles, res.Primary.Title)
+		menu = append(menu, MenuCourse{Course: course, Result: res})
+	}
+	return menu, nil
+}
+
+// resolveCourse resolves the course q.Course of a menu, leaving out the stored recipes used.
+func (rs *Resolver) resolveCourse(ctx context.Context, q Query, used map[string]bool) (Result, error) {
+	scorers := rs.scorers(q)
+	bestSim, bestRank := 0.0, 0.0
+	var best model.Recipe
+	for _, r := range rs.candidates(ctx, q) {
+		if used[r.ID] {
+			continue
+		}
+		sim := score(scorers, q.Text, r.Title)
+		if rank := sim * (1 + rs.boost(r, q)); rank > bestRank {
+			bestSim, bestRank, best = sim, rank, r
+		}
+	}
+	if bestRank > 0 {
+		rs.Logger.Printf("Resolver: Using stored recipe %q as the %s", best.Title, q.Course)
+		return Result{Primary: difficulty.Fill(best), Alternatives: []model.Recipe{}, Match: MatchClose, Score: bestSim}, nil
+	}
+
+	opts := rs.options(q)
+	key := cacheKey(q.Text) + "|" + cacheStyle(q, opts)
+	if rs.Cache != nil {
+		if cached, ok := rs.Cache.Get(key); ok && !used[cached.Primary.ID] {
+			return cached, nil
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	fallback := func() Result {
+		res := rs.fallback(q.Text + " " + q.Course)
+		res.Primary = q.tag(res.Primary)
+		return res
+	}
+	if rs.Degradation.Active() {
+		res := fallback()
+		res.Degraded = true
+		return res, nil
+	}
+	res, err := rs.generate(ctx, key, q, opts)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return Result{}, ctxErr
+		}
+		if errors.Is(err, qos.ErrBusy) {
+			return Result{}, err
+		}
+		return fallback(), nil
+	}
+	return res, nil
+}
@@ -0,0 +1,204 @@
+package resolver
+
+import (
+	"sync"
+
+	"github.com/pageza/recipe-resolver-ms/experiment"
+)
+
+// DefaultNearMargin is how far below the threshold a best similarity still counts as BandNear.
+const DefaultNearMargin = 0.1
+
+// Band places a query's best similarity relative to the similarity threshold in force when it
+// was resolved.
+type Band string
+
+const (
+	// BandAbove means the best similarity met the threshold.
+	BandAbove Band = "above"
+	// BandNear means the best similarity fell short of the threshold by less than the near
+	// margin: lowering the threshold slightly would have turned it into a close match.
+	BandNear Band = "near"
+	// BandBelow means the best similarity fell short by at least the near margin.
+	BandBelow Band = "below"
+)
+
+// similarityBounds are the upper bounds of the similarity histogram buckets.
+var similarityBounds = []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1}
+
+// Bucket is one bucket of a histogram.
+type Bucket struct {
+	// UpperBound is the largest value counted in the bucket; its lower bound is the previous
+	// bucket's UpperBound, exclusive.
+	UpperBound float64 `json:"le"`
+	Count      int     `json:"count"`
+}
+
+// Histogram is a distribution of observed values.
+type Histogram struct {
+	Buckets []Bucket `json:"buckets"`
+	Count   int      `json:"count"`
+	// Sum is the total of all values; divide by Count for the mean.
+	Sum float64 `json:"sum"`
+}
+
+// MatchStats summarizes the match quality of resolved queries.
+type MatchStats struct {
+	Requests int `json:"requests"`
+	// Similarity is the distribution of the best similarity score of each query to the stored
+	// recipes; exact matches count as 1.
+	Similarity Histogram `json:"similarity"`
+	// ByMatch counts queries by the pipeline stage that answered them.
+	ByMatch map[MatchKind]int `json:"by_match"`
+	// ByBand counts queries by where their best similarity fell relative to the threshold.
+	ByBand map[Band]int `json:"by_band"`
+	// Variants holds the stats of the queries assigned to each experiment variant, keyed by
+	// "experiment=variant".
+	Variants map[string]MatchStats `json:"variants,omitempty"`
+	// CoercedTimestamps counts the timestamps of generated recipes that could not be parsed and
+	// were replaced by the server time (see Resolver.StrictTimestamps).
+	CoercedTimestamps int `json:"coerced_timestamps,omitempty"`
+	// DuplicateGenerations counts generated recipes whose content matched a recent generation,
+	// and which were answered with that generation's ID instead of being recorded again.
+	DuplicateGenerations int `json:"duplicate_generations,omitempty"`
+	// NearDuplicateGenerations counts generated recipes that were not persisted because their
+	// title matched a stored recipe's (see Resolver.DuplicateThreshold).
+	NearDuplicateGenerations int `json:"near_duplicate_generations,omitempty"`
+}
+
+// observe adds a query answered by match whose best similarity sim falls in band.
+func (s *MatchStats) observe(match MatchKind, sim float64, band Band) {
+	if s.ByMatch == nil {
+		s.ByMatch = make(map[MatchKind]int)
+		s.ByBand = make(map[Band]int)
+		s.Similarity.Buckets = make([]Bucket, len(similarityBounds))
+		for i, b := range similarityBounds {
+			s.Similarity.Buckets[i].UpperBound = b
+		}
+	}
+	s.Requests++
+	s.ByMatch[match]++
+	s.ByBand[band]++
+	s.Similarity.Count++
+	s.Similarity.Sum += sim
+	for i := range s.Similarity.Buckets {
+		if sim <= s.Similarity.Buckets[i].UpperBound || i == len(s.Similarity.Buckets)-1 {
+			s.Similarity.Buckets[i].Count++
+			break
+		}
+	}
+}
+
+// clone returns a deep copy of s.
+func (s MatchStats) clone() MatchStats {
+	out := s
+	out.ByMatch = make(map[MatchKind]int, len(s.ByMatch))
+	for k, v := range s.ByMatch {
+		out.ByMatch[k] = v
+	}
+	out.ByBand = make(map[Band]int, len(s.ByBand))
+	for k, v := range s.ByBand {
+		out.ByBand[k] = v
+	}
+	out.Similarity.Buckets = append([]Bucket{}, s.Similarity.Buckets...)
+	if s.Variants != nil {
+		out.Variants = make(map[string]MatchStats, len(s.Variants))
+		for k, v := range s.Variants {
+			out.Variants[k] = v.clone()
+		}
+	}
+	return out
+}
+
+// MatchMetrics records the match quality of resolved queries, so that the similarity threshold
+// can be tuned from the observed distribution. It is safe for concurrent use.
+type MatchMetrics struct {
+	// NearMargin is how far below the threshold a best similarity counts as BandNear.
+	NearMargin float64
+
+	mu    sync.Mutex
+	stats MatchStats
+}
+
+// NewMatchMetrics returns empty metrics with DefaultNearMargin.
+func NewMatchMetrics() *MatchMetrics {
+	return &MatchMetrics{NearMargin: DefaultNearMargin}
+}
+
+// band returns the band of sim for threshold.
+func (m *MatchMetrics) band(sim, threshold float64) Band {
+	switch {
+	case sim >= threshold:
+		return BandAbove
+	case sim >= threshold-m.NearMargin:
+		return BandNear
+	}
+	return BandBelow
+}
+
+// observe records a query answered by match whose best similarity was sim, overall and for each
+// of its experiment variants. It does nothing on nil metrics.
+func (m *MatchMetrics) observe(match MatchKind, sim, threshold float64, variants []experiment.Assignment) {
+	if m == nil {
+		return
+	}
+	band := m.band(sim, threshold)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats.observe(match, sim, band)
+	for _, a := range variants {
+		if m.stats.Variants == nil {
+			m.stats.Variants = make(map[string]MatchStats)
+		}
+		v := m.stats.Variants[a.String()]
+		v.observe(match, sim, band)
+		m.stats.Variants[a.String()] = v
+	}
+}
+
+// coerced records a generated timestamp replaced by the server time. It does nothing on nil
+// metrics.
+func (m *MatchMetrics) coerced() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats.CoercedTimestamps++
+}
+
+// nearDuplicates records n generated recipes that were not persisted as near-duplicates. It
+// does nothing on nil metrics.
+func (m *MatchMetrics) nearDuplicates(n int) {
+	if m == nil || n == 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats.NearDuplicateGenerations += n
+}
+
+// duplicate records a generated recipe that duplicated a recent generation. It does nothing on
+// nil metrics.
+func (m *MatchMetrics) duplicate() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats.DuplicateGenerations++
+}
+
+// Stats returns a snapshot of the recorded metrics.
+func (m *MatchMetrics) Stats() MatchStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats.clone()
+}
+
+// Reset discards the recorded metrics, e.g. after changing the threshold.
+func (m *MatchMetrics) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats = MatchStats{}
+}
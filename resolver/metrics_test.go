@@ -0,0 +1,59 @@
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/generation"
+)
+
+// TestMatchMetrics verifies that resolutions are counted by match kind and threshold band, and
+// that best similarities land in the right histogram buckets.
+func TestMatchMetrics(t *testing.T) {
+	rs := newTestResolver(&stubGenerator{primary: generation.Recipe{Title: "Generated"}})
+	for _, q := range []string{"Chicken Salad", "chicken salads", "zzz"} {
+		if _, err := rs.Resolve(context.Background(), Query{Text: q}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats := rs.Metrics.Stats()
+	if stats.Requests != 3 || stats.Similarity.Count != 3 {
+		t.Fatalf("Expected 3 recorded requests, got %+v", stats)
+	}
+	if stats.ByMatch[MatchExact] != 1 || stats.ByMatch[MatchClose] != 1 || stats.ByMatch[MatchGenerated] != 1 {
+		t.Errorf("Unexpected counts by match %v", stats.ByMatch)
+	}
+	if stats.ByBand[BandAbove] != 2 || stats.ByBand[BandBelow] != 1 {
+		t.Errorf("Unexpected counts by band %v", stats.ByBand)
+	}
+	if last := stats.Similarity.Buckets[len(stats.Similarity.Buckets)-1]; last.UpperBound != 1 || last.Count < 1 {
+		t.Errorf("Expected the exact match in the top bucket, got %+v", last)
+	}
+	if first := stats.Similarity.Buckets[0]; first.Count != 1 {
+		t.Errorf("Expected the unmatched query in the bottom bucket, got %+v", first)
+	}
+
+	rs.Metrics.Reset()
+	if stats := rs.Metrics.Stats(); stats.Requests != 0 {
+		t.Errorf("Expected no requests after Reset, got %d", stats.Requests)
+	}
+}
+
+func TestMatchMetricsBand(t *testing.T) {
+	m := NewMatchMetrics()
+	tests := []struct {
+		sim  float64
+		want Band
+	}{
+		{0.3, BandAbove},
+		{0.25, BandNear},
+		{0.2, BandNear},
+		{0.19, BandBelow},
+	}
+	for _, tt := range tests {
+		if got := m.band(tt.sim, 0.3); got != tt.want {
+			t.Errorf("band(%v, 0.3) = %s, want %s", tt.sim, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package resolver
+
+import (
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/occasion"
+)
+
+// Occasions configures occasion awareness. Occasions mentioned in a query (see
+// occasion.Set.Detect) multiply the similarity score of recipes tagged for any of them by
+// 1 + Boost, and generation is given each occasion's guidance.
+type Occasions struct {
+	Set   occasion.Set
+	Boost float64
+}
+
+// NewOccasions returns a configuration using the bundled set with the given boost.
+func NewOccasions(boost float64) *Occasions {
+	return &Occasions{Set: occasion.Default(), Boost: boost}
+}
+
+// detect returns the occasions text mentions; it is nil when o is nil.
+func (o *Occasions) detect(text string) []occasion.Occasion {
+	if o == nil {
+		return nil
+	}
+	return o.Set.Detect(text)
+}
+
+// boost returns the ranking boost for r among the occasions of a query; it is 0 when o is nil.
+func (o *Occasions) boost(r model.Recipe, occasions []occasion.Occasion) float64 {
+	if o == nil || o.Boost <= 0 {
+		return 0
+	}
+	for _, oc := range occasions {
+		if r.HasTag(oc.Tag()) {
+			return o.Boost
+		}
+	}
+	return 0
+}
+
+// boost returns the combined seasonal, occasion and recency ranking boost for r under q.
+func (rs *Resolver) boost(r model.Recipe, q Query) float64 {
+	return rs.Seasonality.boost(r) + rs.Occasions.boost(r, q.Occasions) + rs.Recency.boost(r)
+}
@@ -0,0 +1,220 @@
+package resolver
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/completeness"
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/nlp"
+)
+
+// DefaultDuplicateThreshold is the title similarity at which a generated recipe duplicates a
+// stored one when Resolver.DuplicateThreshold is not set.
+const DefaultDuplicateThreshold = 0.8
+
+// titleFiller lists the words of recipe titles that do not tell dishes apart, e.g. the
+// "recipe" in "chicken salad recipe" or the "easy" in "easy chicken salad".
+var titleFiller = map[string]bool{
+	"a": true, "the": true, "my": true, "recipe": true, "recipes": true, "easy": true,
+	"simple": true, "quick": true, "classic": true, "homemade": true, "best": true,
+	"perfect": true, "ultimate": true, "delicious": true,
+}
+
+// dishSimilarity returns the Jaccard similarity of two recipe titles, leaving out titleFiller
+// words, so that "Chicken Salad" and "chicken salad recipe" are the same dish.
+func dishSimilarity(a, b string) float64 {
+	words := func(title string) map[string]bool {
+		set := make(map[string]bool)
+		for _, w := range nlp.Tokenize(title) {
+			if !titleFiller[w] {
+				set[w] = true
+			}
+		}
+		return set
+	}
+	wa, wb := words(a), words(b)
+	if len(wa) == 0 || len(wb) == 0 {
+		return 0
+	}
+	shared := 0
+	for w := range wa {
+		if wb[w] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(wa)+len(wb)-shared)
+}
+
+// duplicateThreshold returns rs.DuplicateThreshold, or DefaultDuplicateThreshold if unset.
+func (rs *Resolver) duplicateThreshold() float64 {
+	if rs.DuplicateThreshold > 0 {
+		return rs.DuplicateThreshold
+	}
+	return DefaultDuplicateThreshold
+}
+
+// persist adds the recipes of a generation for query to the store as one unit of work (see
+// Atomically), the primary recipe remembering query. query is recorded as it is in Recent, so
+// with ScrubQuery a query carrying personal details is persisted scrubbed and only matches
+// queries that read the same after scrubbing. A recipe already stored under the same ID, such
+// as a repeated generation, is updated rather than added twice.
+//
+// A recipe whose title is as similar as the duplicate threshold to that of a stored recipe, or
+// of one persisted before it from the same generation, is a near-duplicate and not added. The
+// stored recipes compared are those storedLike returns. If
+// the recipe it duplicates was generated too, it is merged into it: the stored recipe gains the
+// nutrition, total time, image and tags it lacked. Curated and imported recipes are left as
+// they are. With ReviewGenerations, added recipes are pending review, while updated ones keep
+// their status. Failures are logged: the generation is still served.
+func (rs *Resolver) persist(ctx context.Context, query string, result Result) {
+	// The generation has been paid for: keep it even if the caller has gone away meanwhile.
+	ctx = context.WithoutCancel(ctx)
+	primary := result.Primary
+	if primary.Provenance != nil {
+		p := *primary.Provenance
+		p.Query = query
+		primary.Provenance = &p
+	}
+
+	// Writes are planned first, since a Writer cannot be read. A near-duplicate of a recipe
+	// already planned is merged into the planned version.
+	type write struct {
+		recipe model.Recipe
+		update bool
+	}
+	var plan []write
+	skipped := 0
+	duplicate := func(r, s model.Recipe) bool {
+		if dishSimilarity(r.Title, s.Title) < rs.duplicateThreshold() {
+			return false
+		}
+		rs.Logger.Printf("Resolver: Generated recipe %q duplicates %q (%s); not persisting it", r.Title, s.Title, s.ID)
+		skipped++
+		return true
+	}
+recipes:
+	for _, r := range append([]model.Recipe{primary}, result.Alternatives...) {
+		for i, w := range plan {
+			if duplicate(r, w.recipe) {
+				plan[i].recipe, _ = mergeGenerated(w.recipe, r)
+				continue recipes
+			}
+		}
+		stored := rs.storedLike(ctx, r)
+		for _, s := range stored {
+			if s.ID == r.ID {
+				r.Status = s.Status
+				plan = append(plan, write{recipe: r, update: true})
+				continue recipes
+			}
+		}
+		for _, s := range stored {
+			if duplicate(r, s) {
+				if merged, ok := mergeGenerated(s, r); ok {
+					plan = append(plan, write{recipe: merged, update: true})
+				}
+				continue recipes
+			}
+		}
+		if rs.ReviewGenerations {
+			r.Status = model.StatusPendingReview
+		}
+		plan = append(plan, write{recipe: r})
+	}
+	rs.Metrics.nearDuplicates(skipped)
+	if len(plan) == 0 {
+		return
+	}
+
+	err := Atomically(ctx, rs.Store, func(w Writer) error {
+		for _, p := range plan {
+			if p.update && w.Update(p.recipe) {
+				continue
+			}
+			if err := w.Add(p.recipe); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		rs.Logger.Printf("Resolver: Could not persist generation for query %q: %v", query, err)
+		return
+	}
+	rs.Logger.Printf("Resolver: Persisted %d generated recipes for query %q", len(plan), query)
+}
+
+// duplicateCandidates is how many of a Searcher store's best matches for the title of a
+// generated recipe persist compares it with.
+const duplicateCandidates = 20
+
+// storedLike returns the unarchived stored recipes persist compares r with. For a store that
+// is both a Getter and a Searcher, they are the recipe with r's ID and the best search matches
+// for r's title, among which a near-duplicate ranks high as it shares most of the title's
+// words; for other stores, or if the search fails, every recipe.
+func (rs *Resolver) storedLike(ctx context.Context, r model.Recipe) []model.Recipe {
+	unarchived := Filter{Unarchived: true}
+	keep := func(recipes []model.Recipe) []model.Recipe {
+		var kept []model.Recipe
+		for _, s := range recipes {
+			if unarchived.Match(s) {
+				kept = append(kept, s)
+			}
+		}
+		return kept
+	}
+	g, getter := rs.Store.(Getter)
+	s, searcher := rs.Store.(Searcher)
+	if !getter || !searcher {
+		return keep(rs.Store.All())
+	}
+	var found []model.Recipe
+	var err error
+	if fs, ok := s.(FilteredSearcher); ok {
+		found, err = fs.SearchFiltered(ctx, r.Title, duplicateCandidates, unarchived)
+	} else {
+		found, err = s.Search(ctx, r.Title, duplicateCandidates)
+	}
+	if err != nil {
+		rs.Logger.Printf("Resolver: Store search failed; comparing %q with all recipes: %v", r.Title, err)
+		return keep(rs.Store.All())
+	}
+	if same, ok := g.Get(r.ID); ok {
+		found = append([]model.Recipe{same}, found...)
+	}
+	return keep(found)
+}
+
+// mergeGenerated fills in what the generated recipe stored lacks from its near-duplicate r:
+// the nutrition, total time and image, and the tags r adds. It reports whether stored changed,
+// which it never does unless stored was generated.
+func mergeGenerated(stored, r model.Recipe) (model.Recipe, bool) {
+	if stored.Provenance == nil || stored.Provenance.Origin != model.OriginGenerated {
+		return stored, false
+	}
+	missing, has := completeness.Of(stored).Missing, completeness.Of(r).Missing
+	fills := func(aspect string) bool {
+		return slices.Contains(missing, aspect) && !slices.Contains(has, aspect)
+	}
+	changed := false
+	if fills(completeness.Nutrition) {
+		stored.NutritionalInfo, changed = r.NutritionalInfo, true
+	}
+	if fills(completeness.Time) {
+		stored.TotalTime, changed = r.TotalTime, true
+	}
+	if fills(completeness.Image) {
+		stored.ImageURL, changed = r.ImageURL, true
+	}
+	for _, tag := range r.Tags {
+		if !stored.HasTag(tag) {
+			stored.Tags, changed = append(slices.Clip(stored.Tags), tag), true
+		}
+	}
+	if changed {
+		stored.UpdatedAt = time.Now().UTC()
+	}
+	return stored, changed
+}
@@ -0,0 +1,41 @@
+package resolver
+
+import (
+	"math"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// Recency configures the ranking of newly added curated recipes, e.g. new seasonal content,
+// above older ones. A curated recipe's similarity score is multiplied by 1 + Boost×2^(-age/
+// HalfLife), where age is the time since it was created: a new recipe gets the full boost,
+// one HalfLife old half of it.
+type Recency struct {
+	Boost    float64
+	HalfLife time.Duration
+	// Now returns the request time; it defaults to time.Now.
+	Now func() time.Time
+}
+
+// NewRecency returns a configuration with the given boost and half-life.
+func NewRecency(boost float64, halfLife time.Duration) *Recency {
+	return &Recency{Boost: boost, HalfLife: halfLife, Now: time.Now}
+}
+
+// boost returns the ranking boost for r; it is 0 when c is nil and for recipes that are not
+// curated, such as persisted generations.
+func (c *Recency) boost(r model.Recipe) float64 {
+	if c == nil || c.Boost <= 0 || c.HalfLife <= 0 || r.CreatedAt.IsZero() {
+		return 0
+	}
+	if r.ProvenanceOrCurated().Origin != model.OriginCurated {
+		return 0
+	}
+	now := time.Now()
+	if c.Now != nil {
+		now = c.Now()
+	}
+	age := max(now.Sub(r.CreatedAt), 0)
+	return c.Boost * math.Exp2(-float64(age)/float64(c.HalfLife))
+}
@@ -0,0 +1,82 @@
+package resolver
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// DefaultRecentGenerations is how many generated recipes New keeps for review.
+const DefaultRecentGenerations = 100
+
+// Generation is a recipe produced by the generator, along with the query that prompted it.
+type Generation struct {
+	Query       string
+	Recipe      model.Recipe
+	GeneratedAt time.Time
+
+	hash string // Recipe.ContentHash(), set by Record
+}
+
+// RecentGenerations is a bounded, concurrency-safe log of the most recently generated primary
+// recipes, so that new content can be reviewed before anyone decides to promote it.
+type RecentGenerations struct {
+	mu      sync.Mutex
+	entries []Generation // oldest first
+	limit   int
+}
+
+// NewRecentGenerations returns an empty log holding at most limit generations.
+func NewRecentGenerations(limit int) *RecentGenerations {
+	return &RecentGenerations{limit: limit}
+}
+
+// Record appends g, dropping the oldest entry once the log is full, and returns it with true.
+// If the log already holds a generation of a recipe with the same content, e.g. because a
+// client retried its request or identical jobs ran in parallel, Record returns that one with
+// false instead and leaves the log unchanged. Recipes without an ID are always appended.
+func (l *RecentGenerations) Record(g Generation) (Generation, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if g.Recipe.ID != "" {
+		g.hash = g.Recipe.ContentHash()
+		for _, e := range l.entries {
+			if e.hash == g.hash {
+				return e, false
+			}
+		}
+	}
+	l.entries = append(l.entries, g)
+	if over := len(l.entries) - l.limit; over > 0 {
+		l.entries = append(l.entries[:0], l.entries[over:]...)
+	}
+	return g, true
+}
+
+// List returns the recorded generations, newest first.
+func (l *RecentGenerations) List() []Generation {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]Generation, len(l.entries))
+	for i, g := range l.entries {
+		out[len(out)-1-i] = g
+	}
+	return out
+}
+
+// DeleteFunc removes the generations for which del returns true and returns how many were
+// removed.
+func (l *RecentGenerations) DeleteFunc(del func(Generation) bool) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	kept := l.entries[:0]
+	for _, g := range l.entries {
+		if !del(g) {
+			kept = append(kept, g)
+		}
+	}
+	n := len(l.entries) - len(kept)
+	l.entries = kept
+	return n
+}
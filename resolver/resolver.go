@@ -0,0 +1,1053 @@
+// Package resolver implements the recipe resolution pipeline: exact matching, similarity
+// matching and LLM generation as a last resort. It has no HTTP dependencies, so a monolith
+// can embed it directly:
+//
+//	r := resolver.New(resolver.NewMemoryStore(recipes), resolver.LLMGenerator{})
+//	res, err := r.Resolve(ctx, resolver.Query{Text: "chicken salad"})
+//
+// All dependencies are carried by the Resolver value rather than package-level globals.
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/audit"
+	"github.com/pageza/recipe-resolver-ms/completeness"
+	"github.com/pageza/recipe-resolver-ms/difficulty"
+	"github.com/pageza/recipe-resolver-ms/experiment"
+	"github.com/pageza/recipe-resolver-ms/generation"
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/occasion"
+	"github.com/pageza/recipe-resolver-ms/qos"
+	"github.com/pageza/recipe-resolver-ms/safety"
+	"github.com/pageza/recipe-resolver-ms/taxonomy"
+	"github.com/pageza/recipe-resolver-ms/tenant"
+	"github.com/pageza/recipe-resolver-ms/titles"
+)
+
+// DefaultThreshold is the minimum similarity score for a stored recipe to count as a close match.
+const DefaultThreshold = 0.3
+
+// MatchKind describes how the primary recipe of a Result was obtained.
+type MatchKind string
+
+const (
+	// MatchExact means a stored recipe title equals the query (case-insensitive).
+	MatchExact MatchKind = "exact"
+	// MatchClose means a stored recipe scored at or above the similarity threshold.
+	MatchClose MatchKind = "close"
+	// MatchGenerated means the recipe was produced by the generator.
+	MatchGenerated MatchKind = "generated"
+	// MatchSemantic means a cached generation for a near-identical query was returned (see
+	// SemanticCache).
+	MatchSemantic MatchKind = "semantic"
+	// MatchFallback means generation failed and an empty recipe titled after the query was returned.
+	MatchFallback MatchKind = "fallback"
+	// MatchBestEffort means the resolver was in degraded mode and returned the most similar
+	// stored recipe although it fell short of the threshold.
+	MatchBestEffort MatchKind = "best_effort"
+)
+
+// Query is a single resolution request.
+type Query struct {
+	// Text is the free-text recipe query, e.g. "chicken salad".
+	Text string
+	// MaxDifficulty, if set, excludes stored recipes and generated alternatives that are
+	// harder than it. A generated primary recipe is returned regardless.
+	MaxDifficulty model.Difficulty
+	// SeasonalOnly restricts matching to stored recipes whose key ingredients are in season
+	// (see Seasonality).
+	SeasonalOnly bool
+	// SkillLevel, if set, writes generated recipes for that audience. SkillBeginner also
+	// restricts stored matches to easy recipes.
+	SkillLevel generation.SkillLevel
+	// KidFriendly asks for generated recipes suitable for children and restricts stored
+	// matches to recipes tagged model.TagKidFriendly.
+	KidFriendly bool
+	// Fusion lists cuisines to blend, e.g. "korean" and "mexican". Generation is asked to fuse
+	// them and its recipes are tagged with each cuisine (see model.CuisineTag); stored matches
+	// must already carry every one of those tags.
+	Fusion []string
+	// Exclude lists ingredients to leave out, e.g. "pecorino". An excluded group such as
+	// "dairy" also excludes everything under it in the Resolver's Taxonomy (butter, cream,
+	// pecorino). Stored recipes and generated alternatives containing any of them are dropped,
+	// and generation is asked to avoid them.
+	Exclude []string
+	// Nutrition bounds calories and macronutrients per serving. Stored recipes must meet it
+	// with their structured nutrition, so recipes without numbers are left out; generation is
+	// asked to meet it and its recipes are checked afterwards (see CompleteNutrition).
+	Nutrition []model.NutritionConstraint
+	// MaxTotalTime limits preparation and cooking time, in minutes. Stored recipes without a
+	// total time are left out; generated ones are checked like Nutrition.
+	MaxTotalTime int
+	// MinCompleteness, from 0 to 1, leaves out stored recipes whose completeness score is
+	// lower (see completeness.Of), e.g. for premium callers. Generated recipes are returned
+	// regardless.
+	MinCompleteness float64
+	// Count, if above one, asks for that many distinct recipes, the best as Primary and the
+	// others as Alternatives. Such queries skip exact and close matches, which are single
+	// recipes, and are generated unless the resolver is degraded.
+	Count int
+	// Diversity, from 0 to 1, trades the relevance of alternatives for their difference from
+	// Primary and each other (see diversify). At 0, the default, alternatives are returned as
+	// found; higher values reorder them and drop near-identical variants.
+	Diversity float64
+	// Course, if set, restricts stored matches to recipes tagged for that course of a menu (see
+	// model.CourseTag), and generation writes for that course, served with the dishes titled
+	// Companions. ResolveMenu sets both.
+	Course     string
+	Companions []string
+	// Occasions are the holidays or festivals the query is for. Stored recipes tagged for any
+	// of them rank higher, generation follows their guidance and tags its recipes with them.
+	// When nil, they are detected in Text by the Resolver's Occasions.
+	Occasions []occasion.Occasion
+	// Style sets the tone, measurement system and verbosity of generated recipes. Unset fields
+	// take the Resolver's Style; the audience fields are ignored in favor of SkillLevel and
+	// KidFriendly.
+	Style generation.Options
+	// Experiments are the experiment variants the query was assigned to. Their threshold,
+	// scorer weights and prompt template override the Resolver's, later assignments winning,
+	// and their match quality is recorded per variant in Metrics.
+	Experiments []experiment.Assignment
+	// Tenant is the profile of the tenant the query comes from, if any. Its threshold, scorer
+	// weights and prompt template override the Resolver's and the experiment variants', and
+	// generations with its prompt are cached apart and not persisted to the shared store.
+	Tenant *tenant.Profile
+	// Route, if set, names the generation route to generate with instead of the one
+	// generation.Routing selects, e.g. a premium model chosen by a paying caller (see
+	// generation.Router.Override). Its generations are cached separately.
+	Route string
+	// RecipeID, if set, skips matching and generation: Resolve returns the stored recipe with
+	// that ID, e.g. one from an earlier response, or the one it was merged into, and the stored
+	// recipes most like it as alternatives (see resolveID), or ErrRecipeNotFound. Text is
+	// ignored.
+	RecipeID string
+}
+
+// threshold returns the similarity threshold for q.
+func (rs *Resolver) threshold(q Query) float64 {
+	t := rs.Threshold
+	if tu := rs.tuning.Load(); tu != nil && tu.Threshold > 0 {
+		t = tu.Threshold
+	}
+	for _, a := range q.Experiments {
+		if a.Variant.Threshold > 0 {
+			t = a.Variant.Threshold
+		}
+	}
+	if q.Tenant != nil && q.Tenant.Threshold > 0 {
+		t = q.Tenant.Threshold
+	}
+	return t
+}
+
+// scorers returns rs.Scorers with the weights overridden by the tuning, then by q's experiment
+// variants, then by its tenant.
+func (rs *Resolver) scorers(q Query) []WeightedScorer {
+	scorers := rs.Scorers
+	overrides := make([]map[string]float64, 0, len(q.Experiments)+2)
+	if tu := rs.tuning.Load(); tu != nil {
+		overrides = append(overrides, tu.Weights)
+	}
+	for _, a := range q.Experiments {
+		overrides = append(overrides, a.Variant.Weights)
+	}
+	if q.Tenant != nil {
+		overrides = append(overrides, q.Tenant.Weights)
+	}
+	for _, weights := range overrides {
+		if len(weights) == 0 {
+			continue
+		}
+		scorers = append([]WeightedScorer(nil), scorers...)
+		for i := range scorers {
+			if w, ok := weights[scorers[i].Name]; ok {
+				scorers[i].Weight = w
+			}
+		}
+	}
+	return scorers
+}
+
+// prompt returns the prompt template of q's tenant, or else of its experiment variants, or else
+// of the tuning, or its canary for the share of queries it is served to, or nil for the
+// default.
+func (rs *Resolver) prompt(q Query) *template.Template {
+	var t *template.Template
+	if tu := rs.tuning.Load(); tu != nil {
+		t = tu.Prompt
+		if tu.Canary != nil && canary(q.Text, tu.CanaryPercent) {
+			t = tu.Canary
+		}
+	}
+	for _, a := range q.Experiments {
+		if p := a.Variant.Prompt(); p != nil {
+			t = p
+		}
+	}
+	if p := q.Tenant.Prompt(); p != nil {
+		t = p
+	}
+	return t
+}
+
+// cacheStyle identifies what besides the query text shapes a generation for q: the generation
+// options, any prompt experiment variants, a tenant's prompt and a forced route.
+func cacheStyle(q Query, opts generation.Options) string {
+	style := opts.String()
+	add := func(s string) {
+		if style != "" {
+			style += ","
+		}
+		style += s
+	}
+	for _, a := range q.Experiments {
+		if a.Variant.Prompt() != nil {
+			add(a.String())
+		}
+	}
+	if q.Tenant.Prompt() != nil {
+		add("tenant=" + q.Tenant.Name)
+	}
+	if q.Route != "" {
+		add("route=" + q.Route)
+	}
+	return style
+}
+
+// options returns the generation options requested by q, with rs.Style as the default style.
+func (rs *Resolver) options(q Query) generation.Options {
+	o := q.Style.WithDefaults(rs.Style)
+	o.SkillLevel, o.KidFriendly = q.SkillLevel, q.KidFriendly
+	seen := make(map[string]bool)
+	for _, name := range q.Exclude {
+		for _, n := range append([]string{name}, rs.Taxonomy.Descendants(name)...) {
+			if !seen[n] {
+				seen[n] = true
+				o.Exclude = append(o.Exclude, n)
+			}
+		}
+	}
+	o.Fusion = q.Fusion
+	o.Nutrition = q.Nutrition
+	o.MaxTotalTime = q.MaxTotalTime
+	o.Count = q.Count
+	o.Course, o.Companions = q.Course, q.Companions
+	o.Occasions = q.Occasions
+	return o
+}
+
+// Result is the outcome of resolving a single query.
+type Result struct {
+	Primary      model.Recipe
+	Alternatives []model.Recipe
+	// Match reports which stage of the pipeline produced Primary.
+	Match MatchKind
+	// Score is the similarity of Primary to the query; 1 for exact matches and 0 for
+	// generated or fallback recipes. For semantic cache hits it is the similarity of the query
+	// to the one the recipe was generated for.
+	Score float64
+	// GeneratedAt is when a generated result was produced; zero for stored recipes.
+	GeneratedAt time.Time
+	// Degraded is set when the resolver was in degraded mode and did not call the generator.
+	Degraded bool
+	// UnmetNutrition lists the query's nutrition constraints that a generated Primary still
+	// misses, when no generated recipe met them all.
+	UnmetNutrition []model.NutritionConstraint
+	// OverTime is set when a generated Primary takes longer than the query's MaxTotalTime, or
+	// does not say, because no generated recipe was within it.
+	OverTime bool
+}
+
+// Resolver resolves free-text queries to recipes. It holds every dependency of the
+// resolution pipeline; fields may be replaced after construction (e.g. in tests).
+type Resolver struct {
+	Store     RecipeStore
+	Scorers   []WeightedScorer
+	Generator Generator
+	Cache     Cache
+	Logger    *log.Logger
+	Threshold float64
+	// Recent, if non-nil, records every successful generation.
+	Recent *RecentGenerations
+	// ScrubQuery, if non-nil, removes personal details from queries before they are recorded
+	// in Recent (see privacy.Scrub).
+	ScrubQuery func(string) string
+	// Seasonality, if non-nil, boosts recipes with in-season ingredients when ranking.
+	Seasonality *Seasonality
+	// Recency, if non-nil, boosts recently added curated recipes when ranking.
+	Recency *Recency
+	// SearchLimit is the number of a Searcher store's results meeting a query's constraints that
+	// are scored; 0 means DefaultSearchLimit.
+	SearchLimit int
+	// Occasions, if non-nil, recognises occasions such as Thanksgiving in queries (see
+	// Query.Occasions).
+	Occasions *Occasions
+	// Titles, if non-nil, cleans up the titles of generated recipes, e.g. replacing brand
+	// names, before they are cached or returned.
+	Titles *titles.Filter
+	// Generations, if non-nil, caps the generations in progress. Queries that would generate
+	// beyond it fail with qos.ErrBusy rather than wait, or fall back, so that callers can
+	// shed load.
+	Generations *qos.Gate
+	// StrictTimestamps fails generations whose created_at or updated_at cannot be parsed (see
+	// model.ParseTimestamp), like a provider error. By default such timestamps are replaced by
+	// the server time and counted in Metrics.
+	StrictTimestamps bool
+	// Safety selects how generated recipes with unsafe food-handling guidance are treated:
+	// safety.Warn attaches the warnings, safety.Block discards the recipe.
+	Safety safety.Mode
+	// Style is the deployment's default tone, measurement system and verbosity of generated
+	// recipes; queries may override each field.
+	Style generation.Options
+	// Semantic, if non-nil, serves cached generations to near-identical queries.
+	Semantic *SemanticCache
+	// Metrics, if non-nil, records the match quality of resolved queries.
+	Metrics *MatchMetrics
+	// ServePending makes stored recipes pending review eligible for matching; their status
+	// flags them in responses. By default only published recipes are matched.
+	ServePending bool
+	// Degradation, if non-nil, can put the resolver in degraded mode, in which queries without
+	// a close match get the best stored match instead of a generation.
+	Degradation *Degradation
+	// StaleAfter, if positive, is the age after which a cached generation is stale: it is still
+	// returned, but regenerated in the background so that the cache picks up improvements in
+	// the generator.
+	StaleAfter time.Duration
+	// Taxonomy, if non-nil, lists the allergens detected in the ingredients of every resolved
+	// recipe.
+	Taxonomy *taxonomy.Taxonomy
+	// CompleteNutrition, if non-nil, fills in the nutrition of generated recipes that lack
+	// structured numbers before they are checked against a query's nutrition constraints (see
+	// nutrition.Complete).
+	CompleteNutrition func(ctx context.Context, r model.Recipe) (model.Recipe, error)
+	// GroundingExamples is the number of stored recipes most similar to a query that are sent to
+	// the generator as examples of the expected output. The generator drops those that do not
+	// fit the model's context window (see generation.WithExamples).
+	GroundingExamples int
+	// Bus, if non-nil, shares invalidations with the other replicas (see Invalidate and Listen).
+	Bus InvalidationBus
+	// Audit, if non-nil, records every generation as a "recipe.generate" entry whose target is
+	// the trace ID in the provenance of the recipes generated, with the query, model, prompt
+	// and raw response, so that a generated recipe can be traced to what the model produced.
+	Audit audit.Log
+	// PersistGenerations adds every successful generation to Store, if it is writable, so that
+	// later queries are answered from the store instead of the generator: the primary recipe
+	// remembers the query it answered (see model.Provenance.Query) and is an exact match for
+	// it, and the alternatives become stored recipes like any other.
+	PersistGenerations bool
+	// ReviewGenerations stores persisted generations as pending review, so that they enter the
+	// curators' review queue and are only matched with ServePending until approved.
+	ReviewGenerations bool
+	// DuplicateThreshold is the similarity of titles, leaving out words such as "recipe" or
+	// "easy", at which a persisted generation is a near-duplicate of a stored recipe and is not
+	// added; 0 means DefaultDuplicateThreshold, and a value above 1 persists every generation.
+	DuplicateThreshold float64
+
+	tuning     atomic.Pointer[Tuning] // set by Tune
+	mu         sync.Mutex
+	refreshing map[string]bool // cache keys being revalidated
+}
+
+// New returns a Resolver backed by the given store and generator, using Jaccard
+// scoring with weight 1, an in-memory cache, the standard logger, a log of the
+// DefaultRecentGenerations most recent generations and safety warnings on generated recipes.
+func New(store RecipeStore, generator Generator) *Resolver {
+	return &Resolver{
+		Store:       store,
+		Scorers:     []WeightedScorer{{Name: "jaccard", Scorer: JaccardScorer{}, Weight: 1}},
+		Generator:   generator,
+		Cache:       NewMemoryCache(),
+		Logger:      log.Default(),
+		Threshold:   DefaultThreshold,
+		Recent:      NewRecentGenerations(DefaultRecentGenerations),
+		Safety:      safety.Warn,
+		Metrics:     NewMatchMetrics(),
+		Degradation: NewDegradation(),
+	}
+}
+
+// Resolve processes the incoming query and determines the best matching recipe.
+// The function follows three logical steps:
+//
+// 1. Exact Match:
+//   - It iterates over all recipes and checks for an exact match (case-insensitive)
+//     between the recipe title, or the query a persisted generation answered, and the
+//     queried string.
+//   - If found, that recipe is returned with no alternatives.
+//
+// 2. Close Match:
+//   - If no exact match is found, every recipe title is scored against the query with
+//     the configured scorers.
+//   - If the best score meets the threshold, that recipe is chosen as the primary recipe
+//     and " (Close Match)" is appended to its title.
+//
+// 3. No Match Found:
+//   - If neither an exact nor a close match is identified, the generator is asked for a new
+//     recipe. Successful generations are cached by normalized query. With a SemanticCache,
+//     a generation for a near-identical earlier query is reused instead.
+//   - If generation fails, a new recipe using the query as its title and empty fields is returned.
+//     In safety.Block mode, a generated recipe that fails the safety checks counts as a failure.
+//   - In degraded mode (see Degradation), cached generations are still served but the generator
+//     is not called; the most similar stored recipe is returned instead.
+//
+// A query for a RecipeID skips all of this and returns the stored recipe with similar ones.
+//
+// An error is returned only when ctx is done before resolution completes, with qos.ErrBusy
+// when the query needs a generation and Generations is full, or with ErrRecipeNotFound for an
+// unknown RecipeID. The match quality of every resolved query, other than by ID, is recorded in
+// Metrics.
+func (rs *Resolver) Resolve(ctx context.Context, q Query) (Result, error) {
+	if q.RecipeID != "" {
+		res, err := rs.resolveID(ctx, q)
+		if err == nil {
+			res = rs.withAllergens(res)
+		}
+		return res, err
+	}
+	res, bestSim, err := rs.resolve(ctx, q)
+	if err == nil {
+		rs.Metrics.observe(res.Match, bestSim, rs.threshold(q), q.Experiments)
+		res = rs.withAllergens(res)
+	}
+	return res, err
+}
+
+// withAllergens fills in the allergens of res's recipes from rs.Taxonomy, leaving recipes that
+// already list theirs alone.
+func (rs *Resolver) withAllergens(res Result) Result {
+	if rs.Taxonomy == nil {
+		return res
+	}
+	detect := func(r model.Recipe) model.Recipe {
+		if len(r.Allergens) == 0 {
+			r.Allergens = rs.Taxonomy.Allergens(r.Ingredients)
+		}
+		return r
+	}
+	res.Primary = detect(res.Primary)
+	if len(res.Alternatives) > 0 {
+		// Alternatives may be shared with the cache.
+		alternatives := make([]model.Recipe, len(res.Alternatives))
+		for i, r := range res.Alternatives {
+			alternatives[i] = detect(r)
+		}
+		res.Alternatives = alternatives
+	}
+	return res
+}
+
+// DefaultSearchLimit is the number of candidates considered from a Searcher store's results,
+// after filtering, when Resolver.SearchLimit is not set.
+const DefaultSearchLimit = 200
+
+// searchOverfetch is how many times its SearchLimit the resolver asks a Searcher store for, as
+// the results failing a query's constraints are dropped before that limit.
+const searchOverfetch = 4
+
+// stored returns the stored recipes to consider for q: the store's best search results if it is
+// a Searcher and every scorer of q is lexical, or else all of them, reporting which. A
+// FilteredSearcher only returns servable recipes. A failed search falls back to all recipes.
+func (rs *Resolver) stored(ctx context.Context, q Query) ([]model.Recipe, bool) {
+	s, ok := rs.Store.(Searcher)
+	if !ok || !lexical(rs.scorers(q)) {
+		return rs.Store.All(), false
+	}
+	var recipes []model.Recipe
+	var err error
+	limit := rs.searchLimit() * searchOverfetch
+	if fs, ok := s.(FilteredSearcher); ok {
+		recipes, err = fs.SearchFiltered(ctx, q.Text, limit, rs.ServableFilter())
+	} else {
+		recipes, err = s.Search(ctx, q.Text, limit)
+	}
+	if err != nil {
+		rs.Logger.Printf("Resolver: Store search failed; scanning all recipes: %v", err)
+		return rs.Store.All(), false
+	}
+	return recipes, true
+}
+
+// candidates returns the stored recipes that may answer q: those servable and meeting its
+// difficulty, season, audience, cuisine, ingredient, nutrition, time and completeness
+// constraints. Of a Searcher's results, the first SearchLimit meeting them are kept; fewer if
+// the constraints drop most of the results the store returned.
+func (rs *Resolver) candidates(ctx context.Context, q Query) []model.Recipe {
+	stored, searched := rs.stored(ctx, q)
+	recipes := rs.servable(stored)
+	if q.MaxDifficulty != "" {
+		recipes = withinDifficulty(recipes, q.MaxDifficulty)
+	}
+	if q.SeasonalOnly {
+		recipes = rs.seasonality().picks(recipes)
+	}
+	if q.SkillLevel == generation.SkillBeginner {
+		recipes = withinDifficulty(recipes, model.DifficultyEasy)
+	}
+	if q.KidFriendly {
+		recipes = withTag(recipes, model.TagKidFriendly)
+	}
+	for _, c := range q.Fusion {
+		recipes = withTag(recipes, model.CuisineTag(c))
+	}
+	if q.Course != "" {
+		recipes = withTag(recipes, model.CourseTag(q.Course))
+	}
+	if len(q.Exclude) > 0 {
+		recipes = rs.without(recipes, q.Exclude)
+	}
+	if len(q.Nutrition) > 0 || q.MaxTotalTime > 0 {
+		recipes = meeting(recipes, q)
+	}
+	if q.MinCompleteness > 0 {
+		recipes = complete(recipes, q.MinCompleteness)
+	}
+	if limit := rs.searchLimit(); searched && len(recipes) > limit {
+		recipes = recipes[:limit]
+	}
+	return recipes
+}
+
+// searchLimit returns rs.SearchLimit, or DefaultSearchLimit if it is not set.
+func (rs *Resolver) searchLimit() int {
+	if rs.SearchLimit <= 0 {
+		return DefaultSearchLimit
+	}
+	return rs.SearchLimit
+}
+
+// complete returns the recipes whose completeness score is at least min.
+func complete(recipes []model.Recipe, min float64) []model.Recipe {
+	var out []model.Recipe
+	for _, r := range recipes {
+		if completeness.Of(r).Value >= min {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// resolve implements Resolve, additionally returning the best similarity of the query to the
+// stored recipes.
+func (rs *Resolver) resolve(ctx context.Context, q Query) (Result, float64, error) {
+	query := q.Text
+	rs.Logger.Printf("Resolver: Starting resolution for query: %q", query)
+	if q.Occasions == nil {
+		q.Occasions = rs.Occasions.detect(query)
+	}
+
+	scorers, threshold := rs.scorers(q), rs.threshold(q)
+	recipes := rs.candidates(ctx, q)
+
+	// Exact match check. A query for several recipes is not answered by a single one.
+	single := q.Count <= 1
+	for _, r := range recipes {
+		if single && (strings.EqualFold(r.Title, query) || r.GeneratedFor(query)) {
+			rs.Logger.Printf("Resolver: Exact match found for recipe: %+v", r)
+			return Result{Primary: difficulty.Fill(r), Match: MatchExact, Score: 1}, 1, nil
+		}
+	}
+	rs.Logger.Println("Resolver: No exact match found; proceeding with similarity search")
+
+	// The best candidate is chosen by boosted score; the threshold applies to similarity alone,
+	// so a boost reorders matches but never turns a non-match into one.
+	bestSim, bestRank := 0.0, 0.0
+	var best model.Recipe
+	for _, r := range recipes {
+		sim := score(scorers, query, r.Title)
+		rank := sim * (1 + rs.boost(r, q))
+		rs.Logger.Printf("Resolver: Compared recipe %q with similarity %f", r.Title, sim)
+		if rank > bestRank {
+			bestSim, bestRank = sim, rank
+			best = r
+		}
+	}
+	rs.Logger.Printf("Resolver: Best similarity found: %f for recipe: %+v", bestSim, best)
+
+	if single && bestSim >= threshold {
+		best.Title = best.Title + " (Close Match)"
+		rs.Logger.Printf("Resolver: Close match meets threshold; returning modified recipe: %+v", best)
+		return Result{Primary: difficulty.Fill(best), Match: MatchClose, Score: bestSim}, bestSim, nil
+	}
+
+	// Generations for a particular audience, style, prompt variant or route are cached
+	// separately from general ones.
+	opts := rs.options(q)
+	style := cacheStyle(q, opts)
+	key := cacheKey(query)
+	if style != "" {
+		key += "|" + style
+	}
+	if rs.Cache != nil {
+		if cached, ok := rs.Cache.Get(key); ok {
+			rs.Logger.Printf("Resolver: Returning cached generation for query: %q", query)
+			if rs.StaleAfter > 0 && time.Since(cached.GeneratedAt) > rs.StaleAfter && !rs.Degradation.Active() {
+				rs.revalidate(key, q, opts)
+			}
+			return rs.limitAlternatives(cached, q), bestSim, nil
+		}
+	}
+	if rs.Semantic != nil {
+		if cached, sim, ok := rs.Semantic.Lookup(ctx, query, style); ok {
+			rs.Logger.Printf("Resolver: Returning semantically cached generation (similarity %f) for query: %q", sim, query)
+			cached.Match, cached.Score = MatchSemantic, sim
+			return rs.limitAlternatives(cached, q), bestSim, nil
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return Result{}, 0, err
+	}
+	if rs.Degradation.Active() {
+		if bestSim == 0 {
+			res := rs.fallback(query)
+			res.Degraded = true
+			return res, 0, nil
+		}
+		rs.Logger.Printf("Resolver: Degraded mode; returning best stored match %q (similarity %f)", best.Title, bestSim)
+		return Result{Primary: difficulty.Fill(best), Match: MatchBestEffort, Score: bestSim, Degraded: true}, bestSim, nil
+	}
+	rs.Logger.Println("Resolver: No close match found; invoking generator")
+	result, err := rs.generate(ctx, key, q, opts)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return Result{}, 0, ctxErr
+		}
+		if errors.Is(err, qos.ErrBusy) {
+			return Result{}, 0, err
+		}
+		return rs.fallback(query), bestSim, nil
+	}
+	return rs.limitAlternatives(result, q), bestSim, nil
+}
+
+// examples returns up to GroundingExamples stored recipes resembling query, most similar first,
+// as grounding examples for its generation.
+func (rs *Resolver) examples(query string) []generation.Recipe {
+	if rs.GroundingExamples <= 0 {
+		return nil
+	}
+	var examples []generation.Recipe
+	for _, c := range rs.Rank(query) {
+		if len(examples) >= rs.GroundingExamples || c.Score == 0 {
+			break
+		}
+		examples = append(examples, generation.Recipe{Title: c.Recipe.Title, Ingredients: c.Recipe.Ingredients, Steps: c.Recipe.Steps})
+	}
+	return examples
+}
+
+// distinct returns up to n of alternatives, leaving out those whose title repeats primary's or
+// an earlier alternative's.
+func distinct(primary model.Recipe, alternatives []model.Recipe, n int) []model.Recipe {
+	seen := map[string]bool{strings.ToLower(strings.TrimSpace(primary.Title)): true}
+	kept := alternatives[:0]
+	for _, alt := range alternatives {
+		title := strings.ToLower(strings.TrimSpace(alt.Title))
+		if len(kept) == n || seen[title] {
+			continue
+		}
+		seen[title] = true
+		kept = append(kept, alt)
+	}
+	return kept
+}
+
+// errUnsafe is returned by generate when safety checks reject the generated recipe.
+var errUnsafe = errors.New("generated recipe failed safety checks")
+
+// generate asks the generator for a recipe for q and, if it succeeds and passes the safety
+// checks, caches the result under key and records it in Recent. It returns qos.ErrBusy when
+// rs.Generations is full.
+func (rs *Resolver) generate(ctx context.Context, key string, q Query, opts generation.Options) (Result, error) {
+	release, ok := rs.Generations.TryAcquire()
+	if !ok {
+		rs.Logger.Printf("Resolver: Too many generations in progress; turning away query %q", q.Text)
+		return Result{}, qos.ErrBusy
+	}
+	defer release()
+	query := q.Text
+	genCtx := generation.WithOptions(ctx, opts)
+	if t := rs.prompt(q); t != nil {
+		genCtx = generation.WithTemplate(genCtx, t)
+	}
+	if q.Route != "" {
+		genCtx = generation.WithRoute(genCtx, q.Route)
+	}
+	if examples := rs.examples(query); len(examples) > 0 {
+		genCtx = generation.WithExamples(genCtx, examples)
+	}
+	var source generation.Source
+	genCtx = generation.WithSource(genCtx, &source)
+	generated, alternatives, err := rs.Generator.Generate(genCtx, query)
+	if ctx.Err() == nil {
+		rs.Degradation.observe(err)
+	}
+	if err != nil {
+		rs.Logger.Printf("Resolver: Generator returned error: %v", err)
+		return Result{}, err
+	}
+	rs.Logger.Printf("Resolver: Generation successful; primary recipe: %+v, alternative recipes: %+v", generated, alternatives)
+	trace := source.TraceID()
+	rs.trace(ctx, trace, query, source)
+
+	now := time.Now().UTC()
+	prepare := func(r model.Recipe) model.Recipe {
+		r.Provenance = model.Generated(source.Model, source.PromptVersion, now)
+		r.Provenance.TraceID = trace
+		return difficulty.Fill(q.tag(rs.retitle(r, query)))
+	}
+	converted, err := rs.convertGenRecipe(generated, now)
+	if err != nil {
+		rs.Logger.Printf("Resolver: Generated recipe %q rejected: %v", generated.Title, err)
+		return Result{}, err
+	}
+	primary, ok := rs.checkSafety(prepare(converted))
+	if !ok {
+		rs.Logger.Printf("Resolver: Generated recipe %q failed safety checks: %+v", primary.Title, primary.SafetyWarnings)
+		return Result{}, errUnsafe
+	}
+	result := Result{
+		Primary:      primary,
+		Alternatives: []model.Recipe{},
+		Match:        MatchGenerated,
+		GeneratedAt:  now,
+	}
+	for _, generated := range alternatives {
+		alt, err := rs.convertGenRecipe(generated, now)
+		if err != nil {
+			rs.Logger.Printf("Resolver: Generated alternative %q rejected: %v", generated.Title, err)
+			continue
+		}
+		if alt, ok := rs.checkSafety(prepare(alt)); ok {
+			result.Alternatives = append(result.Alternatives, alt)
+		}
+	}
+	if q.Count > 1 {
+		result.Alternatives = distinct(result.Primary, result.Alternatives, q.Count-1)
+	}
+	if len(q.Nutrition) > 0 || q.MaxTotalTime > 0 {
+		result = rs.verify(ctx, result, q)
+	}
+	recorded := query
+	if rs.ScrubQuery != nil {
+		recorded = rs.ScrubQuery(query)
+	}
+	if rs.Recent != nil {
+		existing, added := rs.Recent.Record(Generation{Query: recorded, Recipe: result.Primary, GeneratedAt: now})
+		if !added {
+			// The same recipe was generated before, e.g. for a retry: answer with its ID rather
+			// than a new one.
+			rs.Logger.Printf("Resolver: Generated recipe %q duplicates %s", result.Primary.Title, existing.Recipe.ID)
+			result.Primary.ID = existing.Recipe.ID
+			rs.Metrics.duplicate()
+		}
+	}
+	if rs.PersistGenerations && q.Tenant.Prompt() == nil {
+		rs.persist(ctx, recorded, result)
+	}
+	if rs.Cache != nil {
+		rs.Cache.Set(key, result)
+	}
+	if rs.Semantic != nil {
+		if err := rs.Semantic.Add(ctx, key, query, cacheStyle(q, opts), result); err != nil {
+			rs.Logger.Printf("Resolver: Could not add generation to the semantic cache: %v", err)
+		}
+	}
+	return result, nil
+}
+
+// revalidateTimeout bounds a background regeneration of a stale cache entry.
+const revalidateTimeout = 2 * time.Minute
+
+// revalidate regenerates the stale cache entry under key in the background, unless a
+// regeneration of it is already running. The stale entry stays in place if generation fails.
+func (rs *Resolver) revalidate(key string, q Query, opts generation.Options) {
+	rs.mu.Lock()
+	if rs.refreshing[key] {
+		rs.mu.Unlock()
+		return
+	}
+	if rs.refreshing == nil {
+		rs.refreshing = make(map[string]bool)
+	}
+	rs.refreshing[key] = true
+	rs.mu.Unlock()
+
+	rs.Logger.Printf("Resolver: Cached generation for query %q is stale; regenerating in the background", q.Text)
+	go func() {
+		defer func() {
+			rs.mu.Lock()
+			delete(rs.refreshing, key)
+			rs.mu.Unlock()
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), revalidateTimeout)
+		defer cancel()
+		if _, err := rs.generate(ctx, key, q, opts); err != nil {
+			rs.Logger.Printf("Resolver: Keeping stale generation for query %q: %v", q.Text, err)
+		}
+	}()
+}
+
+// fallback returns the empty recipe titled after query used when generation fails.
+func (rs *Resolver) fallback(query string) Result {
+	fallback := model.NewRecipe(query, []string{}, []string{}, map[string]int{}, "", []string{})
+	fallback.Provenance = &model.Provenance{Origin: model.OriginPlaceholder}
+	rs.Logger.Printf("Resolver: Returning fallback recipe: %+v", fallback)
+	return Result{Primary: fallback, Match: MatchFallback}
+}
+
+// Servable reports whether r may be served: it is not archived, not rejected in review and,
+// unless rs.ServePending is set, not pending review.
+func (rs *Resolver) Servable(r model.Recipe) bool {
+	return rs.ServableFilter().Match(r)
+}
+
+// ServableFilter returns the Filter keeping the recipes rs serves.
+func (rs *Resolver) ServableFilter() Filter {
+	f := Filter{Statuses: []model.Status{model.StatusPublished}, Unarchived: true}
+	if rs.ServePending {
+		f.Statuses = append(f.Statuses, model.StatusPendingReview)
+	}
+	return f
+}
+
+// servable returns the recipes that may be matched, those rs.Servable accepts. It reuses
+// recipes if all of them are.
+func (rs *Resolver) servable(recipes []model.Recipe) []model.Recipe {
+	ok := rs.ServableFilter().Match
+	for i, r := range recipes {
+		if ok(r) {
+			continue
+		}
+		kept := append([]model.Recipe(nil), recipes[:i]...)
+		for _, r := range recipes[i+1:] {
+			if ok(r) {
+				kept = append(kept, r)
+			}
+		}
+		return kept
+	}
+	return recipes
+}
+
+// checkSafety runs the safety checks on a generated recipe according to rs.Safety. It returns
+// the recipe with any warnings attached and whether it may be served.
+func (rs *Resolver) checkSafety(r model.Recipe) (model.Recipe, bool) {
+	if rs.Safety == safety.Off {
+		return r, true
+	}
+	r.SafetyWarnings = safety.Check(r)
+	return r, rs.Safety != safety.Block || len(r.SafetyWarnings) == 0
+}
+
+// withinDifficulty returns the recipes no harder than max, estimating missing difficulties.
+func withinDifficulty(recipes []model.Recipe, max model.Difficulty) []model.Recipe {
+	var out []model.Recipe
+	for _, r := range recipes {
+		if difficulty.Fill(r).Difficulty.Level() <= max.Level() {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// withTag returns the recipes carrying tag.
+func withTag(recipes []model.Recipe, tag string) []model.Recipe {
+	var out []model.Recipe
+	for _, r := range recipes {
+		if r.HasTag(tag) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// without returns the recipes none of whose ingredients are mentioned by exclude (see
+// taxonomy.Taxonomy.Mentions).
+func (rs *Resolver) without(recipes []model.Recipe, exclude []string) []model.Recipe {
+	var out []model.Recipe
+	for _, r := range recipes {
+		if !rs.contains(r, exclude) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// contains reports whether any ingredient of r is mentioned by one of names.
+func (rs *Resolver) contains(r model.Recipe, names []string) bool {
+	for _, line := range r.Ingredients {
+		for _, name := range names {
+			if rs.Taxonomy.Mentions(line, name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// meets reports whether r meets q's nutrition constraints and time limit.
+func meets(r model.Recipe, q Query) bool {
+	return model.UnmetNutrition(r.NutritionalInfo, q.Nutrition) == nil && withinTime(r, q.MaxTotalTime)
+}
+
+// withinTime reports whether r is known to take at most limit minutes, or limit is zero.
+func withinTime(r model.Recipe, limit int) bool {
+	return limit <= 0 || (r.TotalTime > 0 && r.TotalTime <= limit)
+}
+
+// meeting returns the recipes that meet q's nutrition constraints and time limit.
+func meeting(recipes []model.Recipe, q Query) []model.Recipe {
+	var out []model.Recipe
+	for _, r := range recipes {
+		if meets(r, q) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// verify checks generated recipes against q's nutrition constraints and time limit, completing
+// their nutrition with rs.CompleteNutrition first when q has constraints. Alternatives that miss
+// are dropped. A primary recipe that misses is replaced by the first alternative that meets
+// them, or else kept and flagged with UnmetNutrition and OverTime.
+func (rs *Resolver) verify(ctx context.Context, res Result, q Query) Result {
+	complete := func(r model.Recipe) model.Recipe {
+		if rs.CompleteNutrition == nil || len(q.Nutrition) == 0 {
+			return r
+		}
+		completed, err := rs.CompleteNutrition(ctx, r)
+		if err != nil {
+			rs.Logger.Printf("Resolver: Could not complete the nutrition of %q: %v", r.Title, err)
+			return r
+		}
+		return completed
+	}
+	primary := complete(res.Primary)
+	alternatives := []model.Recipe{}
+	for _, alt := range res.Alternatives {
+		if alt = complete(alt); meets(alt, q) {
+			alternatives = append(alternatives, alt)
+		}
+	}
+	if !meets(primary, q) && len(alternatives) > 0 {
+		rs.Logger.Printf("Resolver: Generated recipe %q misses the query's constraints; promoting alternative %q", primary.Title, alternatives[0].Title)
+		primary, alternatives = alternatives[0], alternatives[1:]
+	}
+	res.Primary, res.Alternatives = primary, alternatives
+	res.UnmetNutrition = model.UnmetNutrition(primary.NutritionalInfo, q.Nutrition)
+	res.OverTime = !withinTime(primary, q.MaxTotalTime)
+	return res
+}
+
+// retitle cleans up the title of a generated recipe with rs.Titles. A title with nothing left
+// is replaced by the cleaned-up query.
+func (rs *Resolver) retitle(r model.Recipe, query string) model.Recipe {
+	if rs.Titles == nil {
+		return r
+	}
+	title := rs.Titles.Apply(r.Title)
+	if title == "" {
+		title = rs.Titles.Apply(query)
+	}
+	if title != r.Title {
+		rs.Logger.Printf("Resolver: Retitled generated recipe %q as %q", r.Title, title)
+		r.Title = title
+	}
+	return r
+}
+
+// tag labels a recipe generated for q, e.g. as kid-friendly when q asked for it and with the
+// cuisines it fuses and the occasions it is for.
+func (q Query) tag(r model.Recipe) model.Recipe {
+	tags := make([]string, 0, 2+len(q.Fusion)+len(q.Occasions))
+	if q.KidFriendly {
+		tags = append(tags, model.TagKidFriendly)
+	}
+	if q.Course != "" {
+		tags = append(tags, model.CourseTag(q.Course))
+	}
+	for _, c := range q.Fusion {
+		tags = append(tags, model.CuisineTag(c))
+	}
+	for _, o := range q.Occasions {
+		tags = append(tags, o.Tag())
+	}
+	for _, t := range tags {
+		if !r.HasTag(t) {
+			r.Tags = append(r.Tags, t)
+		}
+	}
+	return r
+}
+
+// limitAlternatives drops the alternatives of res that are harder than q.MaxDifficulty, if set,
+// or contain an ingredient q excludes, and diversifies the rest by q.Diversity.
+func (rs *Resolver) limitAlternatives(res Result, q Query) Result {
+	if q.MaxDifficulty == "" && len(q.Exclude) == 0 && q.Diversity <= 0 || res.Alternatives == nil {
+		return res
+	}
+	if q.MaxDifficulty != "" {
+		res.Alternatives = withinDifficulty(res.Alternatives, q.MaxDifficulty)
+	}
+	if len(q.Exclude) > 0 {
+		res.Alternatives = rs.without(res.Alternatives, q.Exclude)
+	}
+	if q.Diversity > 0 {
+		res.Alternatives = diversify(rs.scorers(q), q.Text, res.Primary, res.Alternatives, q.Diversity)
+	}
+	if res.Alternatives == nil {
+		res.Alternatives = []model.Recipe{}
+	}
+	return res
+}
+
+// convertGenRecipe converts a generation.Recipe, whose timestamps are strings as returned by
+// the LLM, into a model.Recipe. Timestamps are parsed by rs.timestamp.
+func (rs *Resolver) convertGenRecipe(r generation.Recipe, now time.Time) (model.Recipe, error) {
+	createdAt, err := rs.timestamp(r.CreatedAt, now)
+	if err != nil {
+		return model.Recipe{}, fmt.Errorf("created_at: %w", err)
+	}
+	updatedAt, err := rs.timestamp(r.UpdatedAt, now)
+	if err != nil {
+		return model.Recipe{}, fmt.Errorf("updated_at: %w", err)
+	}
+
+	return model.Recipe{
+		ID:                r.ID,
+		Title:             r.Title,
+		Ingredients:       r.Ingredients,
+		Steps:             r.Steps,
+		NutritionalInfo:   r.NutritionalInfo,
+		AllergyDisclaimer: r.AllergyDisclaimer,
+		Appliances:        r.Appliances,
+		Difficulty:        model.Difficulty(strings.ToLower(r.Difficulty)),
+		TotalTime:         r.TotalTime,
+		CreatedAt:         createdAt,
+		UpdatedAt:         updatedAt,
+	}, nil
+}
+
+// timestamp parses a generated timestamp with model.ParseTimestamp. A missing or unparseable
+// value is an error with StrictTimestamps, and otherwise replaced by now and counted in Metrics.
+func (rs *Resolver) timestamp(s string, now time.Time) (time.Time, error) {
+	t, err := model.ParseTimestamp(s)
+	if err == nil {
+		return t, nil
+	}
+	if rs.StrictTimestamps {
+		return time.Time{}, err
+	}
+	rs.Metrics.coerced()
+	return now, nil
+}
@@ -0,0 +1,1731 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/audit"
+	"github.com/pageza/recipe-resolver-ms/auth"
+	"github.com/pageza/recipe-resolver-ms/experiment"
+	"github.com/pageza/recipe-resolver-ms/generation"
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/safety"
+	"github.com/pageza/recipe-resolver-ms/season"
+	"github.com/pageza/recipe-resolver-ms/taxonomy"
+	"github.com/pageza/recipe-resolver-ms/tenant"
+	"github.com/pageza/recipe-resolver-ms/titles"
+)
+
+// stubGenerator is a Generator returning canned results and counting its invocations.
+type stubGenerator struct {
+	primary      generation.Recipe
+	alternatives []generation.Recipe
+	err          error
+	calls        int
+	// options and examples record the generation options and grounding examples of the last
+	// call.
+	options  generation.Options
+	examples []generation.Recipe
+}
+
+func (g *stubGenerator) Generate(ctx context.Context, query string) (generation.Recipe, []generation.Recipe, error) {
+	g.calls++
+	g.options = generation.OptionsFrom(ctx)
+	g.examples = generation.ExamplesFrom(ctx)
+	return g.primary, g.alternatives, g.err
+}
+
+// newTestResolver returns a Resolver over the sample recipes with a silent logger.
+func newTestResolver(gen Generator) *Resolver {
+	rs := New(NewMemoryStore(SampleRecipes()), gen)
+	rs.Logger = log.New(io.Discard, "", 0)
+	return rs
+}
+
+// TestResolveRecipeExact verifies that an exact query returns the expected recipe.
+func TestResolveRecipeExact(t *testing.T) {
+	// Query exactly matches "Spaghetti Bolognese" in the sample recipes.
+	res, err := newTestResolver(&stubGenerator{}).Resolve(context.Background(), Query{Text: "Spaghetti Bolognese"})
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	primary, alternatives := res.Primary, res.Alternatives
+	if !strings.EqualFold(primary.Title, "Spaghetti Bolognese") {
+		t.Errorf("Expected primary title 'Spaghetti Bolognese', got '%s'", primary.Title)
+	}
+	if len(alternatives) != 0 {
+		t.Errorf("Expected no alternatives, got %d", len(alternatives))
+	}
+}
+
+// TestResolveRecipeNoMatch verifies that a query with low similarity falls back to a new recipe
+// when generation fails.
+func TestResolveRecipeNoMatch(t *testing.T) {
+	// "chicken noodle soup" does not sufficiently match any sample recipe.
+	res, err := newTestResolver(&stubGenerator{err: errors.New("unavailable")}).Resolve(context.Background(), Query{Text: "chicken noodle soup"})
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	primary, alternatives := res.Primary, res.Alternatives
+	if primary.Title != "chicken noodle soup" {
+		t.Errorf("Expected new generated recipe with title 'chicken noodle soup', got '%s'", primary.Title)
+	}
+	if len(alternatives) != 0 {
+		t.Errorf("Expected no alternatives for a new generated recipe, got %d", len(alternatives))
+	}
+}
+
+// TestResolveRecipeNLP verifies that a loosely matching query returns a close match.
+func TestResolveRecipeNLP(t *testing.T) {
+	// "Salad with chicken" should closely match "Chicken Salad" in the sample recipes.
+	res, err := newTestResolver(&stubGenerator{}).Resolve(context.Background(), Query{Text: "Salad with chicken"})
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+	primary, alternatives := res.Primary, res.Alternatives
+	if !strings.Contains(primary.Title, "Chicken Salad") || !strings.Contains(primary.Title, "(Close Match)") {
+		t.Errorf("Expected primary title to contain 'Chicken Salad (Close Match)', got '%s'", primary.Title)
+	}
+	// There should be no alternative recipes in this simple test scenario.
+	if len(alternatives) != 0 {
+		t.Errorf("Expected no alternatives, got %d", len(alternatives))
+	}
+}
+
+// TestResolveRecipeGeneratedIsCached verifies that a successful generation is served from the
+// cache for a repeated query instead of calling the generator again.
+func TestResolveRecipeGeneratedIsCached(t *testing.T) {
+	gen := &stubGenerator{
+		primary:      generation.Recipe{ID: "gen-1", Title: "Mushroom Risotto", CreatedAt: "2024-01-02"},
+		alternatives: []generation.Recipe{{ID: "gen-2", Title: "Mushroom Pilaf"}},
+	}
+	rs := newTestResolver(gen)
+
+	for i := 0; i < 2; i++ {
+		res, err := rs.Resolve(context.Background(), Query{Text: "Mushroom  risotto"})
+		if err != nil {
+			t.Fatalf("Resolve returned error: %v", err)
+		}
+		primary, alternatives := res.Primary, res.Alternatives
+		if primary.ID != "gen-1" {
+			t.Errorf("Expected generated primary ID 'gen-1', got '%s'", primary.ID)
+		}
+		if primary.CreatedAt.IsZero() {
+			t.Errorf("Expected created_at to be parsed from the generated recipe")
+		}
+		if len(alternatives) != 1 {
+			t.Errorf("Expected 1 alternative, got %d", len(alternatives))
+		}
+	}
+	if gen.calls != 1 {
+		t.Errorf("Expected generator to be called once, got %d", gen.calls)
+	}
+}
+
+// TestResolveStaleWhileRevalidate verifies that a stale cached generation is returned at once
+// and replaced by a background regeneration.
+func TestResolveStaleWhileRevalidate(t *testing.T) {
+	rs := newTestResolver(&stubGenerator{primary: generation.Recipe{ID: "gen-1", Title: "Mushroom Risotto"}})
+	if _, err := rs.Resolve(context.Background(), Query{Text: "Mushroom risotto"}); err != nil {
+		t.Fatal(err)
+	}
+
+	rs.StaleAfter = time.Nanosecond
+	regen := &stubGenerator{primary: generation.Recipe{ID: "gen-2", Title: "Mushroom Risotto", Steps: []string{"Toast the rice", "Stir in stock"}}}
+	rs.Generator = regen
+	res, err := rs.Resolve(context.Background(), Query{Text: "Mushroom risotto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Primary.ID != "gen-1" {
+		t.Errorf("Expected the stale generation to be returned, got %q", res.Primary.ID)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if cached, _ := rs.Cache.Get(cacheKey("Mushroom risotto")); cached.Primary.ID == "gen-2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the cache to be refreshed in the background")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if regen.calls != 1 {
+		t.Errorf("Expected one background regeneration, got %d", regen.calls)
+	}
+}
+
+// TestResolveCanceledContext verifies that a done context aborts resolution before generation.
+func TestResolveCanceledContext(t *testing.T) {
+	gen := &stubGenerator{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := newTestResolver(gen).Resolve(ctx, Query{Text: "chicken noodle soup"})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if gen.calls != 0 {
+		t.Errorf("Expected generator not to be called, got %d calls", gen.calls)
+	}
+}
+
+// TestRankWeights verifies the per-scorer breakdown and that weights change the combined score.
+func TestRankWeights(t *testing.T) {
+	rs := newTestResolver(&stubGenerator{})
+	rs.Scorers = []WeightedScorer{
+		{Name: "jaccard", Scorer: JaccardScorer{}, Weight: 1},
+		{Name: "overlap", Scorer: OverlapScorer{}, Weight: 0},
+	}
+
+	candidates := rs.Rank("bolognese")
+	if len(candidates) != 2 || candidates[0].Recipe.Title != "Spaghetti Bolognese" {
+		t.Fatalf("Expected 'Spaghetti Bolognese' to rank first, got %+v", candidates)
+	}
+	best := candidates[0]
+	if best.Scores["jaccard"] != 0.5 || best.Scores["overlap"] != 1 {
+		t.Errorf("Expected jaccard 0.5 and overlap 1, got %v", best.Scores)
+	}
+	if best.Score != 0.5 {
+		t.Errorf("Expected combined score 0.5 with overlap weight 0, got %f", best.Score)
+	}
+
+	rs.Scorers[1].Weight = 1
+	if got := rs.Rank("bolognese")[0].Score; got != 0.75 {
+		t.Errorf("Expected combined score 0.75 with equal weights, got %f", got)
+	}
+}
+
+func TestRecentGenerationsBounded(t *testing.T) {
+	l := NewRecentGenerations(2)
+	for _, q := range []string{"a", "b", "c"} {
+		l.Record(Generation{Query: q})
+	}
+	got := l.List()
+	if len(got) != 2 || got[0].Query != "c" || got[1].Query != "b" {
+		t.Errorf("Expected [c b], got %+v", got)
+	}
+}
+
+// TestResolveDuplicateGeneration verifies that regenerating a recipe already in Recent, as a
+// retried request does, answers with the recorded recipe's ID instead of recording it again.
+func TestResolveDuplicateGeneration(t *testing.T) {
+	gen := &stubGenerator{primary: generation.Recipe{ID: "gen-1", Title: "Lemon Tart", Steps: []string{"Bake"}}}
+	rs := newTestResolver(gen)
+	rs.Cache = nil
+	rs.Metrics = &MatchMetrics{}
+	first, err := rs.Resolve(context.Background(), Query{Text: "lemon tart"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen.primary.ID = "gen-2"
+	retried, err := rs.Resolve(context.Background(), Query{Text: "lemon tart"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gen.calls != 2 || retried.Primary.ID != first.Primary.ID {
+		t.Errorf("Expected the retry to answer with %q, got %q after %d calls", first.Primary.ID, retried.Primary.ID, gen.calls)
+	}
+	if got := rs.Recent.List(); len(got) != 1 {
+		t.Errorf("Expected one recorded generation, got %d", len(got))
+	}
+	if n := rs.Metrics.Stats().DuplicateGenerations; n != 1 {
+		t.Errorf("Expected 1 duplicate generation, got %d", n)
+	}
+
+	gen.primary.Steps = []string{"Blind-bake the crust", "Fill and bake"}
+	if res, _ := rs.Resolve(context.Background(), Query{Text: "lemon tart"}); res.Primary.ID != "gen-2" || len(rs.Recent.List()) != 2 {
+		t.Errorf("Expected a changed recipe to be recorded under its own ID, got %q", res.Primary.ID)
+	}
+}
+
+// TestResolvePersistGenerations verifies that persisted generations are added to the store, so
+// that the same query is then answered by an exact match without calling the generator again.
+func TestResolvePersistGenerations(t *testing.T) {
+	gen := &stubGenerator{
+		primary:      generation.Recipe{ID: "gen-1", Title: "Classic Lemon Tart", Ingredients: []string{"lemons"}, Steps: []string{"Bake"}},
+		alternatives: []generation.Recipe{{ID: "gen-2", Title: "Lemon Meringue Pie", Ingredients: []string{"lemons"}, Steps: []string{"Bake"}}},
+	}
+	rs := newTestResolver(gen)
+	rs.Cache = nil
+	rs.PersistGenerations = true
+	store := rs.Store.(*MemoryStore)
+	before := len(store.All())
+
+	first, err := rs.Resolve(context.Background(), Query{Text: "lemon tart"})
+	if err != nil || first.Match != MatchGenerated {
+		t.Fatalf("Expected a generation, got %v %v", first.Match, err)
+	}
+	if n := len(store.All()); n != before+2 {
+		t.Fatalf("Expected the primary recipe and its alternative to be stored, got %d recipes", n-before)
+	}
+	stored, _ := store.Get("gen-1")
+	if stored.Provenance == nil || stored.Provenance.Origin != model.OriginGenerated || stored.Provenance.Query != "lemon tart" {
+		t.Errorf("Expected the stored recipe to remember its query, got %+v", stored.Provenance)
+	}
+	if first.Primary.Provenance.Query != "" {
+		t.Errorf("Expected the response not to carry the query, got %q", first.Primary.Provenance.Query)
+	}
+
+	again, err := rs.Resolve(context.Background(), Query{Text: "Lemon Tart"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gen.calls != 1 || again.Match != MatchExact || again.Primary.ID != "gen-1" {
+		t.Errorf("Expected an exact match on the stored generation, got %v %q after %d calls", again.Match, again.Primary.ID, gen.calls)
+	}
+
+	// A read-only store leaves the generation unpersisted but still served.
+	rs = newTestResolver(gen)
+	rs.Store = struct{ RecipeStore }{NewMemoryStore(SampleRecipes())}
+	rs.PersistGenerations = true
+	if res, err := rs.Resolve(context.Background(), Query{Text: "lemon tart"}); err != nil || res.Match != MatchGenerated {
+		t.Errorf("Expected a generation with a read-only store, got %v %v", res.Match, err)
+	}
+}
+
+// TestResolvePersistForReview verifies that with ReviewGenerations persisted generations are
+// pending review, and only match queries with ServePending.
+func TestResolvePersistForReview(t *testing.T) {
+	gen := &stubGenerator{primary: generation.Recipe{ID: "gen-1", Title: "Classic Lemon Tart", Ingredients: []string{"lemons"}, Steps: []string{"Bake"}}}
+	rs := newTestResolver(gen)
+	rs.Cache = nil
+	rs.PersistGenerations, rs.ReviewGenerations = true, true
+	store := rs.Store.(*MemoryStore)
+
+	if _, err := rs.Resolve(context.Background(), Query{Text: "lemon tart"}); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := store.Get("gen-1"); stored.Status != model.StatusPendingReview {
+		t.Fatalf("Expected the persisted generation to be pending review, got %q", stored.Status)
+	}
+	if res, _ := rs.Resolve(context.Background(), Query{Text: "lemon tart"}); res.Match != MatchGenerated || gen.calls != 2 {
+		t.Errorf("Expected a pending generation not to match, got %s after %d calls", res.Match, gen.calls)
+	}
+	if stored, _ := store.Get("gen-1"); stored.Status != model.StatusPendingReview {
+		t.Errorf("Expected a repeated generation to stay pending review, got %q", stored.Status)
+	}
+
+	rs.ServePending = true
+	res, _ := rs.Resolve(context.Background(), Query{Text: "lemon tart"})
+	if res.Match != MatchExact || res.Primary.Status != model.StatusPendingReview || gen.calls != 2 {
+		t.Errorf("Expected an exact match flagged pending with ServePending, got %s %q after %d calls", res.Match, res.Primary.Status, gen.calls)
+	}
+}
+
+// TestPersistNearDuplicates verifies that generated recipes duplicating stored ones are not
+// persisted, and that a duplicate of a generated recipe fills in what it lacked.
+func TestPersistNearDuplicates(t *testing.T) {
+	gen := &stubGenerator{
+		primary: generation.Recipe{ID: "gen-1", Title: "Easy Chicken Salad Recipe", Ingredients: []string{"chicken"}, Steps: []string{"Toss"}},
+		alternatives: []generation.Recipe{
+			{ID: "gen-2", Title: "Lemon Tart", Ingredients: []string{"lemons"}, Steps: []string{"Bake"}},
+			{ID: "gen-3", Title: "Classic Lemon Tart", Ingredients: []string{"lemons"}, Steps: []string{"Bake"}},
+		},
+	}
+	rs := newTestResolver(gen)
+	rs.Cache = nil
+	rs.PersistGenerations = true
+	store := rs.Store.(*MemoryStore)
+	before := len(store.All())
+
+	if _, err := rs.Resolve(context.Background(), Query{Text: "picnic ideas"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Get("gen-1"); ok {
+		t.Error("Expected the duplicate of the curated Chicken Salad not to be persisted")
+	}
+	if _, ok := store.Get("gen-3"); ok {
+		t.Error("Expected the duplicate of an alternative of the same generation not to be persisted")
+	}
+	if n := len(store.All()); n != before+1 {
+		t.Errorf("Expected only Lemon Tart to be persisted, got %d new recipes", n-before)
+	}
+	if n := rs.Metrics.Stats().NearDuplicateGenerations; n != 2 {
+		t.Errorf("Expected 2 near-duplicates, got %d", n)
+	}
+
+	gen.primary = generation.Recipe{ID: "gen-4", Title: "lemon tart recipe", Ingredients: []string{"lemons"}, Steps: []string{"Bake"}, TotalTime: 50}
+	gen.alternatives = nil
+	if _, err := rs.Resolve(context.Background(), Query{Text: "dessert for friday"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Get("gen-4"); ok {
+		t.Error("Expected the duplicate of the generated Lemon Tart not to be persisted")
+	}
+	if tart, _ := store.Get("gen-2"); tart.TotalTime != 50 {
+		t.Errorf("Expected the stored Lemon Tart to gain the total time of its duplicate, got %d", tart.TotalTime)
+	}
+
+	if got := dishSimilarity("Chicken Salad", "chicken salad recipe"); got != 1 {
+		t.Errorf("Expected filler words to be ignored, got similarity %f", got)
+	}
+	if got := dishSimilarity("Chicken Salad", "Chicken Caesar Salad"); got >= DefaultDuplicateThreshold {
+		t.Errorf("Expected different dishes to stay apart, got similarity %f", got)
+	}
+}
+
+// TestPersistSearchesStore verifies that near-duplicates are looked for among a Searcher
+// store's matches for the generated title, without listing every recipe.
+func TestPersistSearchesStore(t *testing.T) {
+	salad := SampleRecipes()[1]
+	gen := &stubGenerator{primary: generation.Recipe{ID: "gen-1", Title: "Chicken Salad Recipe", Steps: []string{"Toss"}}}
+	rs := newTestResolver(gen)
+	rs.Cache = nil
+	rs.PersistGenerations = true
+	store := &searchStore{MemoryStore: NewMemoryStore(SampleRecipes()), found: []model.Recipe{salad}}
+	rs.Store = store
+
+	if _, err := rs.Resolve(context.Background(), Query{Text: "picnic ideas"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.Get("gen-1"); ok {
+		t.Error("Expected the duplicate of the searched Chicken Salad not to be persisted")
+	}
+	if store.scans != 0 || store.limit != duplicateCandidates || !store.filter.Unarchived {
+		t.Errorf("Expected a bounded search of unarchived recipes, got %d scans (limit %d, filter %+v)", store.scans, store.limit, store.filter)
+	}
+}
+
+// TestResolveRoute verifies that generations forced onto a route are cached apart from others.
+func TestResolveRoute(t *testing.T) {
+	gen := &stubGenerator{primary: generation.Recipe{ID: "gen-1", Title: "Lemon Tart"}}
+	rs := newTestResolver(gen)
+	for _, q := range []Query{{Text: "lemon tart"}, {Text: "lemon tart", Route: "premium"}, {Text: "lemon tart", Route: "premium"}} {
+		if _, err := rs.Resolve(context.Background(), q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if gen.calls != 2 {
+		t.Errorf("Expected one generation per route, got %d", gen.calls)
+	}
+}
+
+func TestResolveScrubsRecordedQuery(t *testing.T) {
+	rs := newTestResolver(&stubGenerator{})
+	rs.ScrubQuery = func(q string) string { return strings.ReplaceAll(q, "Emma", "[name]") }
+	if _, err := rs.Resolve(context.Background(), Query{Text: "cake for Emma"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := rs.Recent.List(); len(got) != 1 || got[0].Query != "cake for [name]" {
+		t.Errorf("Expected the scrubbed query to be recorded, got %+v", got)
+	}
+}
+
+func TestResolveMaxDifficulty(t *testing.T) {
+	hard := model.NewRecipe("Beef Wellington", []string{"beef", "pastry"}, []string{"Sear the beef.", "Wrap in laminated pastry.", "Rest 30 minutes, then bake for 2 hours."}, nil, "", nil)
+	easy := model.NewRecipe("Beef Tacos", []string{"beef", "tortillas"}, []string{"Brown the beef.", "Fill the tortillas."}, nil, "", nil)
+	rs := newTestResolver(&stubGenerator{})
+	rs.Store = NewMemoryStore([]model.Recipe{hard, easy})
+
+	res, err := rs.Resolve(context.Background(), Query{Text: "Beef Wellington"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Match != MatchExact || res.Primary.Difficulty != model.DifficultyHard {
+		t.Errorf("Expected an exact match rated hard, got %s / %s", res.Match, res.Primary.Difficulty)
+	}
+
+	res, err = rs.Resolve(context.Background(), Query{Text: "Beef Wellington", MaxDifficulty: model.DifficultyEasy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Match != MatchClose || res.Primary.ID != easy.ID || res.Primary.Difficulty != model.DifficultyEasy {
+		t.Errorf("Expected the easy recipe as a close match, got %s %+v", res.Match, res.Primary)
+	}
+}
+
+func TestResolveSeasonality(t *testing.T) {
+	summer := model.NewRecipe("Tomato Tart", []string{"tomatoes", "puff pastry"}, []string{"Bake."}, nil, "", nil)
+	winter := model.NewRecipe("Leek Tart", []string{"leeks", "puff pastry"}, []string{"Bake."}, nil, "", nil)
+	rs := newTestResolver(&stubGenerator{err: errors.New("unavailable")})
+	rs.Store = NewMemoryStore([]model.Recipe{summer, winter})
+	rs.Seasonality = NewSeasonality(season.North, 0.5)
+	rs.Seasonality.Now = func() time.Time { return time.Date(2025, time.January, 15, 0, 0, 0, 0, time.UTC) }
+
+	// Both titles are equally similar to the query; the in-season leeks win in January.
+	res, err := rs.Resolve(context.Background(), Query{Text: "savory tart"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Primary.ID != winter.ID {
+		t.Errorf("Expected the leek tart in January, got %q", res.Primary.Title)
+	}
+	if ranked := rs.Rank("savory tart"); ranked[0].Scores["season"] != 0.5 {
+		t.Errorf("Expected the season boost in the score breakdown, got %+v", ranked[0].Scores)
+	}
+
+	rs.Seasonality.Now = func() time.Time { return time.Date(2025, time.July, 15, 0, 0, 0, 0, time.UTC) }
+	res, err = rs.Resolve(context.Background(), Query{Text: "leek tart", SeasonalOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Primary.ID != summer.ID {
+		t.Errorf("Expected only the tomato tart to be a seasonal pick in July, got %q", res.Primary.Title)
+	}
+}
+
+func TestResolveRecency(t *testing.T) {
+	now := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	old := model.NewRecipe("Apple Pie", []string{"apples"}, []string{"Bake."}, nil, "", nil)
+	old.CreatedAt = now.AddDate(-1, 0, 0)
+	fresh := model.NewRecipe("Pear Pie", []string{"pears"}, []string{"Bake."}, nil, "", nil)
+	fresh.CreatedAt = now.AddDate(0, 0, -7)
+	generated := model.NewRecipe("Plum Pie", []string{"plums"}, []string{"Bake."}, nil, "", nil)
+	generated.CreatedAt = now
+	generated.Provenance = model.Generated("test-model", "v1", now)
+	rs := newTestResolver(&stubGenerator{err: errors.New("unavailable")})
+	rs.Store = NewMemoryStore([]model.Recipe{old, generated, fresh})
+	rs.Recency = NewRecency(0.4, 7*24*time.Hour)
+	rs.Recency.Now = func() time.Time { return now }
+
+	// The titles are equally similar to the query; the week-old curated pie wins, while the
+	// generated one gets no boost.
+	res, err := rs.Resolve(context.Background(), Query{Text: "fruit pie"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Primary.ID != fresh.ID {
+		t.Errorf("Expected the recently added pie, got %q", res.Primary.Title)
+	}
+	ranked := rs.Rank("fruit pie")
+	if ranked[0].Recipe.ID != fresh.ID || math.Abs(ranked[0].Scores["recency"]-0.2) > 1e-9 {
+		t.Errorf("Expected half the recency boost after one half-life, got %+v", ranked[0].Scores)
+	}
+	for _, c := range ranked[1:] {
+		if _, ok := c.Scores["recency"]; ok && c.Recipe.ID == generated.ID {
+			t.Errorf("Expected no recency boost for a generated recipe, got %+v", c.Scores)
+		}
+	}
+}
+
+func TestResolveOccasions(t *testing.T) {
+	turkey := model.NewRecipe("Roast Turkey", []string{"turkey"}, []string{"Roast."}, nil, "", nil)
+	turkey.Tags = []string{model.OccasionTag("thanksgiving")}
+	chicken := model.NewRecipe("Roast Chicken", []string{"chicken"}, []string{"Roast."}, nil, "", nil)
+	gen := &stubGenerator{primary: generation.Recipe{Title: "Harira", Ingredients: []string{"lentils"}, Steps: []string{"Simmer."}}}
+	rs := newTestResolver(gen)
+	rs.Store = NewMemoryStore([]model.Recipe{chicken, turkey})
+	rs.Occasions = NewOccasions(0.5)
+
+	// Both titles are equally similar to the query; the one tagged for Thanksgiving wins.
+	res, err := rs.Resolve(context.Background(), Query{Text: "Thanksgiving roast"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Primary.ID != turkey.ID {
+		t.Errorf("Expected the Thanksgiving turkey, got %q", res.Primary.Title)
+	}
+	if ranked := rs.Rank("thanksgiving roast"); ranked[0].Scores["occasion"] != 0.5 {
+		t.Errorf("Expected the occasion boost in the score breakdown, got %+v", ranked[0].Scores)
+	}
+
+	res, err = rs.Resolve(context.Background(), Query{Text: "soup for iftar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gen.options.Occasions) != 1 || gen.options.Occasions[0].Name != "ramadan" {
+		t.Fatalf("Expected generation for Ramadan, got %+v", gen.options.Occasions)
+	}
+	if !strings.Contains(gen.options.Guidance(), "halal") {
+		t.Errorf("Expected the Ramadan guidance in the prompt, got %q", gen.options.Guidance())
+	}
+	if !res.Primary.HasTag(model.OccasionTag("ramadan")) {
+		t.Errorf("Expected the generated recipe to be tagged for Ramadan, got %v", res.Primary.Tags)
+	}
+}
+
+func TestResolveTitles(t *testing.T) {
+	gen := &stubGenerator{
+		primary:      generation.Recipe{Title: "Nutella® crêpes", Ingredients: []string{"flour"}, Steps: []string{"Cook."}},
+		alternatives: []generation.Recipe{{Title: "Damn!", Ingredients: []string{"flour"}, Steps: []string{"Cook."}}},
+	}
+	rs := newTestResolver(gen)
+	rs.Titles = titles.Default()
+	res, err := rs.Resolve(context.Background(), Query{Text: "sweet crepes"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Primary.Title != "Hazelnut Spread Crêpes" {
+		t.Errorf("Expected the brand name replaced, got %q", res.Primary.Title)
+	}
+	if len(res.Alternatives) != 1 || res.Alternatives[0].Title != "Sweet Crepes" {
+		t.Errorf("Expected a title with nothing left to fall back to the query, got %+v", res.Alternatives)
+	}
+}
+
+func TestResolveProvenance(t *testing.T) {
+	gen := &stubGenerator{
+		primary:      generation.Recipe{Title: "Miso Soup", Ingredients: []string{"miso"}, Steps: []string{"Stir."}},
+		alternatives: []generation.Recipe{{Title: "Dashi", Ingredients: []string{"kombu"}, Steps: []string{"Steep."}}},
+	}
+	rs := newTestResolver(gen)
+	res, err := rs.Resolve(context.Background(), Query{Text: "miso soup"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range append([]model.Recipe{res.Primary}, res.Alternatives...) {
+		if p := r.Provenance; p == nil || p.Origin != model.OriginGenerated || p.GeneratedAt == nil || !p.GeneratedAt.Equal(res.GeneratedAt) {
+			t.Errorf("Expected %q labeled as generated at %v, got %+v", r.Title, res.GeneratedAt, p)
+		}
+	}
+
+	gen.err = errors.New("unavailable")
+	res, err = rs.Resolve(context.Background(), Query{Text: "natto"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := res.Primary.Provenance; res.Match != MatchFallback || p == nil || p.Origin != model.OriginPlaceholder {
+		t.Errorf("Expected a placeholder fallback, got %s %+v", res.Match, p)
+	}
+}
+
+func TestResolveTimestamps(t *testing.T) {
+	gen := &stubGenerator{
+		primary: generation.Recipe{Title: "Miso Soup", Ingredients: []string{"miso"}, Steps: []string{"Stir."},
+			CreatedAt: "1714521600", UpdatedAt: "last tuesday"},
+		alternatives: []generation.Recipe{{Title: "Dashi", Ingredients: []string{"kombu"}, Steps: []string{"Steep."},
+			CreatedAt: "2024-05-01", UpdatedAt: "2024-05-01T08:00:00+02:00"}},
+	}
+	rs := newTestResolver(gen)
+	res, err := rs.Resolve(context.Background(), Query{Text: "miso soup"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC); !res.Primary.CreatedAt.Equal(want) {
+		t.Errorf("Expected epoch seconds parsed as %v, got %v", want, res.Primary.CreatedAt)
+	}
+	if !res.Primary.UpdatedAt.Equal(res.GeneratedAt) {
+		t.Errorf("Expected an unparseable timestamp replaced by the server time %v, got %v", res.GeneratedAt, res.Primary.UpdatedAt)
+	}
+	if len(res.Alternatives) != 1 || !res.Alternatives[0].UpdatedAt.Equal(time.Date(2024, 5, 1, 6, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the alternative's timestamps parsed, got %+v", res.Alternatives)
+	}
+	if n := rs.Metrics.Stats().CoercedTimestamps; n != 1 {
+		t.Errorf("Expected 1 coerced timestamp, got %d", n)
+	}
+
+	rs = newTestResolver(gen)
+	rs.StrictTimestamps = true
+	res, err = rs.Resolve(context.Background(), Query{Text: "miso soup"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Match != MatchFallback {
+		t.Errorf("Expected strict mode to reject the generated recipe, got %s", res.Match)
+	}
+}
+
+// searchStore is a FilteredSearcher returning its found recipes, or err, recording the limit
+// and filter it was asked for and counting full scans.
+type searchStore struct {
+	*MemoryStore
+	found  []model.Recipe
+	err    error
+	limit  int
+	filter Filter
+	scans  int
+}
+
+func (s *searchStore) All() []model.Recipe {
+	s.scans++
+	return s.MemoryStore.All()
+}
+
+func (s *searchStore) Search(ctx context.Context, query string, limit int) ([]model.Recipe, error) {
+	return s.SearchFiltered(ctx, query, limit, Filter{})
+}
+
+func (s *searchStore) SearchFiltered(ctx context.Context, query string, limit int, f Filter) ([]model.Recipe, error) {
+	s.limit, s.filter = limit, f
+	return s.found, s.err
+}
+
+func TestResolveSearcher(t *testing.T) {
+	soup := model.NewRecipe("Tomato Soup", []string{"tomatoes"}, []string{"Simmer."}, nil, "", nil)
+	salad := model.NewRecipe("Tomato Salad", []string{"tomatoes"}, []string{"Toss."}, nil, "", nil)
+	store := &searchStore{MemoryStore: NewMemoryStore([]model.Recipe{soup, salad}), found: []model.Recipe{salad}}
+	rs := newTestResolver(&stubGenerator{err: errors.New("unavailable")})
+	rs.Store, rs.SearchLimit = store, 10
+
+	res, err := rs.Resolve(context.Background(), Query{Text: "tomato soup"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Primary.ID != salad.ID || store.scans != 0 || store.limit != 10*searchOverfetch {
+		t.Errorf("Expected the search result without a full scan, got %q after %d scans (limit %d)", res.Primary.Title, store.scans, store.limit)
+	}
+	if want := (Filter{Statuses: []model.Status{model.StatusPublished}, Unarchived: true}); fmt.Sprint(store.filter) != fmt.Sprint(want) {
+		t.Errorf("Expected the search filtered to servable recipes, got %+v", store.filter)
+	}
+
+	// The limit applies to the results meeting the query's constraints.
+	hard := salad
+	hard.ID, hard.Difficulty = "hard-salad", model.DifficultyHard
+	store.found, rs.SearchLimit = []model.Recipe{hard, salad}, 1
+	if res, _ = rs.Resolve(context.Background(), Query{Text: "tomato soup", MaxDifficulty: model.DifficultyEasy}); res.Primary.ID != salad.ID {
+		t.Errorf("Expected the search result meeting the constraints, got %s %q", res.Match, res.Primary.ID)
+	}
+
+	store.err = errors.New("connection refused")
+	if res, err = rs.Resolve(context.Background(), Query{Text: "tomato soup"}); err != nil {
+		t.Fatal(err)
+	}
+	if res.Match != MatchExact || res.Primary.ID != soup.ID || store.scans != 1 {
+		t.Errorf("Expected a failed search to fall back to a full scan, got %s %q after %d scans", res.Match, res.Primary.Title, store.scans)
+	}
+}
+
+func TestResolveSafety(t *testing.T) {
+	gen := &stubGenerator{
+		primary: generation.Recipe{Title: "Grilled Duck", Ingredients: []string{"duck breast"}, Steps: []string{"Grill to 120°F."}},
+		alternatives: []generation.Recipe{
+			{Title: "Roast Duck", Ingredients: []string{"whole duck"}, Steps: []string{"Roast until the thigh reads 170°F."}},
+		},
+	}
+	rs := newTestResolver(gen)
+	res, err := rs.Resolve(context.Background(), Query{Text: "grilled duck"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Primary.SafetyWarnings) != 1 || res.Primary.SafetyWarnings[0].Code != "poultry_undercooked" {
+		t.Errorf("Expected a poultry warning in warn mode, got %+v", res.Primary.SafetyWarnings)
+	}
+
+	rs = newTestResolver(gen)
+	rs.Safety = safety.Block
+	res, err = rs.Resolve(context.Background(), Query{Text: "grilled duck"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Match != MatchFallback {
+		t.Errorf("Expected an unsafe recipe to be blocked, got %v", res.Match)
+	}
+	if _, ok := rs.Cache.Get(cacheKey("grilled duck")); ok {
+		t.Error("Expected a blocked recipe not to be cached")
+	}
+}
+
+func TestResolveKidFriendly(t *testing.T) {
+	nuggets := model.NewRecipe("Chicken Nuggets", []string{"chicken"}, []string{"Bake."}, nil, "", nil)
+	nuggets.Tags = []string{model.TagKidFriendly}
+	curry := model.NewRecipe("Chicken Curry", []string{"chicken", "chili"}, []string{"Simmer."}, nil, "", nil)
+	gen := &stubGenerator{primary: generation.Recipe{Title: "Fish Pie", Ingredients: []string{"fish"}, Steps: []string{"Bake."}}}
+	rs := newTestResolver(gen)
+	rs.Store = NewMemoryStore([]model.Recipe{nuggets, curry})
+
+	// The exact but untagged match is skipped in favor of the kid-friendly one.
+	res, err := rs.Resolve(context.Background(), Query{Text: "chicken curry", KidFriendly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Primary.ID != nuggets.ID {
+		t.Errorf("Expected the kid-friendly nuggets, got %q", res.Primary.Title)
+	}
+
+	res, err = rs.Resolve(context.Background(), Query{Text: "fish pie", KidFriendly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gen.options.KidFriendly || !res.Primary.HasTag(model.TagKidFriendly) {
+		t.Errorf("Expected a kid-friendly generation, got options %+v and tags %v", gen.options, res.Primary.Tags)
+	}
+
+	// Other audiences do not share the kid-friendly cache entry.
+	if _, err := rs.Resolve(context.Background(), Query{Text: "fish pie", SkillLevel: generation.SkillBeginner}); err != nil {
+		t.Fatal(err)
+	}
+	if gen.calls != 2 || gen.options.KidFriendly || gen.options.SkillLevel != generation.SkillBeginner {
+		t.Errorf("Expected a separate beginner generation, got %d calls with options %+v", gen.calls, gen.options)
+	}
+}
+
+func TestResolveMinCompleteness(t *testing.T) {
+	stew := model.NewRecipe("Beef Stew", []string{"500 g beef", "3 carrots"}, []string{"Simmer."}, map[string]int{"calories": 520, "protein": 40, "carbohydrates": 20, "fat": 28}, "", nil)
+	stew.TotalTime, stew.ImageURL, stew.Tags = 120, "https://cdn.example.com/stew.jpg", []string{model.CuisineTag("french")}
+	sparse := model.NewRecipe("Beef Curry", []string{"beef", "curry paste"}, []string{"Simmer."}, nil, "", nil)
+	gen := &stubGenerator{primary: generation.Recipe{Title: "Beef Pie", Ingredients: []string{"beef"}, Steps: []string{"Bake."}}}
+	rs := newTestResolver(gen)
+	rs.Store = NewMemoryStore([]model.Recipe{stew, sparse})
+
+	res, err := rs.Resolve(context.Background(), Query{Text: "beef curry"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Primary.ID != sparse.ID {
+		t.Errorf("Expected the exact match without a minimum, got %q", res.Primary.Title)
+	}
+
+	// The exact but sparse match is skipped in favor of the complete one.
+	res, err = rs.Resolve(context.Background(), Query{Text: "beef curry", MinCompleteness: 0.8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Primary.ID != stew.ID {
+		t.Errorf("Expected the complete stew, got %q", res.Primary.Title)
+	}
+}
+
+func TestResolveStyle(t *testing.T) {
+	gen := &stubGenerator{primary: generation.Recipe{Title: "Fish Pie", Ingredients: []string{"fish"}, Steps: []string{"Bake."}}}
+	rs := newTestResolver(gen)
+	rs.Style = generation.Options{Tone: generation.ToneConcise, Measurement: generation.MeasurementMetric}
+
+	if _, err := rs.Resolve(context.Background(), Query{Text: "fish pie", Style: generation.Options{Tone: generation.ToneChatty}}); err != nil {
+		t.Fatal(err)
+	}
+	want := generation.Options{Tone: generation.ToneChatty, Measurement: generation.MeasurementMetric}
+	if !reflect.DeepEqual(gen.options, want) {
+		t.Errorf("Expected the query tone with the default measurement system, got %+v", gen.options)
+	}
+	if _, err := rs.Resolve(context.Background(), Query{Text: "fish pie"}); err != nil {
+		t.Fatal(err)
+	}
+	if gen.calls != 2 {
+		t.Errorf("Expected differently styled generations to be cached separately, got %d calls", gen.calls)
+	}
+}
+
+func TestResolveSemanticCache(t *testing.T) {
+	gen := &stubGenerator{primary: generation.Recipe{Title: "Chicken Noodle Soup", Ingredients: []string{"chicken"}, Steps: []string{"Simmer."}}}
+	rs := newTestResolver(gen)
+	rs.Store = NewMemoryStore(nil)
+	rs.Semantic = NewSemanticCache(DefaultSemanticThreshold)
+
+	if _, err := rs.Resolve(context.Background(), Query{Text: "chicken noodle soup"}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := rs.Resolve(context.Background(), Query{Text: "Chicken noodle soups"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gen.calls != 1 || res.Match != MatchSemantic || res.Score < DefaultSemanticThreshold {
+		t.Errorf("Expected a semantic cache hit, got %d calls and %s match with score %f", gen.calls, res.Match, res.Score)
+	}
+
+	// A different dish, or the same one in another style, is generated afresh.
+	rs.Resolve(context.Background(), Query{Text: "beef noodle soup"})
+	rs.Resolve(context.Background(), Query{Text: "chicken noodle soups", KidFriendly: true})
+	if gen.calls != 3 {
+		t.Errorf("Expected two more generations, got %d calls in total", gen.calls)
+	}
+
+	if n := rs.Semantic.DeleteFunc(func(key string) bool { return strings.Contains(key, "beef") }); n != 1 {
+		t.Errorf("Expected one entry to be deleted, got %d", n)
+	}
+}
+
+// TestResolveTuning verifies that a tuning overrides the threshold until removed, and that an
+// invalid one is rejected without replacing the current one.
+func TestResolveTuning(t *testing.T) {
+	gen := &stubGenerator{primary: generation.Recipe{Title: "Generated"}}
+	rs := newTestResolver(gen)
+	if err := rs.Tune(&Tuning{Threshold: 0.99}); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := rs.Resolve(context.Background(), Query{Text: "chicken salads"}); res.Match != MatchGenerated {
+		t.Errorf("Expected the tuned threshold to force generation, got %s", res.Match)
+	}
+	if err := rs.Tune(&Tuning{Weights: map[string]float64{"cosine": 1}}); err == nil {
+		t.Error("Expected a weight for an unknown scorer to be rejected")
+	}
+	if rs.CurrentThreshold() != 0.99 {
+		t.Errorf("Expected the rejected tuning to keep the current one, got threshold %v", rs.CurrentThreshold())
+	}
+	rs.Tune(nil)
+	if res, _ := rs.Resolve(context.Background(), Query{Text: "chicken salads"}); res.Match != MatchClose {
+		t.Errorf("Expected the default threshold once the tuning is removed, got %s", res.Match)
+	}
+}
+
+// TestResolveTrace verifies that generated recipes carry the trace ID of the audit entry
+// recording their generation.
+func TestResolveTrace(t *testing.T) {
+	gen := &stubGenerator{primary: generation.Recipe{Title: "Lemon Tart", Ingredients: []string{"lemons"}, Steps: []string{"Bake."}}}
+	rs := newTestResolver(gen)
+	trail := audit.NewMemoryLog(0)
+	rs.Audit = trail
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{Name: "support"})
+
+	res, err := rs.Resolve(ctx, Query{Text: "lemon tart"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := res.Primary.Provenance
+	if p == nil || len(p.TraceID) != 16 {
+		t.Fatalf("Expected a trace ID in the provenance, got %+v", p)
+	}
+	entries := trail.List(audit.Filter{Action: "recipe.generate", Target: p.TraceID})
+	if len(entries) != 1 || entries[0].Actor != "support" || !strings.Contains(string(entries[0].After), `"query":"lemon tart"`) {
+		t.Errorf("Expected one generation entry for the trace ID, got %+v", entries)
+	}
+}
+
+// TestResolveCanary verifies that a canary template is served to its share of queries, the same
+// template always to the same query, and that prompt experiments take precedence.
+func TestResolveCanary(t *testing.T) {
+	rs := newTestResolver(&stubGenerator{})
+	current := template.Must(template.New("current").Parse("Recipe for {{.Query}}"))
+	next := template.Must(template.New("next").Parse("A recipe for {{.Query}}, as JSON"))
+	if err := rs.Tune(&Tuning{Prompt: current, Canary: next, CanaryPercent: 150}); err == nil {
+		t.Error("Expected a canary percent above 100 to be rejected")
+	}
+
+	rs.Tune(&Tuning{Prompt: current, Canary: next, CanaryPercent: 20})
+	canaries := 0
+	for i := 0; i < 1000; i++ {
+		q := Query{Text: fmt.Sprintf("dish %d", i)}
+		t1 := rs.prompt(q)
+		if t1 != rs.prompt(q) {
+			t.Fatalf("Expected %q to keep its template", q.Text)
+		}
+		if t1 == next {
+			canaries++
+		}
+	}
+	if canaries < 150 || canaries > 250 {
+		t.Errorf("Expected about 20%% of queries on the canary, got %d of 1000", canaries)
+	}
+
+	rs.Tune(&Tuning{Prompt: current, Canary: next, CanaryPercent: 100})
+	if rs.prompt(Query{Text: "lemon tart"}) != next {
+		t.Error("Expected every query on a 100% canary")
+	}
+	cfg := &experiment.Config{Experiments: []experiment.Experiment{
+		{Name: "prompt", Variants: []experiment.Variant{{Name: "terse", Percent: 100, PromptTemplate: "{{.Query}}"}}},
+	}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if p := rs.prompt(Query{Text: "lemon tart", Experiments: cfg.Assign("session-1")}); p == next || p == current {
+		t.Error("Expected the experiment's prompt to take precedence over the canary")
+	}
+}
+
+// TestResolveExperiments verifies that experiment variants override the threshold and prompt,
+// get their own cache entries and are recorded in the metrics.
+func TestResolveExperiments(t *testing.T) {
+	cfg := &experiment.Config{Experiments: []experiment.Experiment{
+		{Name: "strict", Variants: []experiment.Variant{{Name: "on", Percent: 100, Threshold: 0.99}}},
+		{Name: "prompt", Variants: []experiment.Variant{{Name: "terse", Percent: 100, PromptTemplate: "{{.Query}}"}}},
+	}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	gen := &stubGenerator{primary: generation.Recipe{Title: "Generated"}}
+	rs := newTestResolver(gen)
+
+	res, _ := rs.Resolve(context.Background(), Query{Text: "chicken salads"})
+	if res.Match != MatchClose {
+		t.Fatalf("Expected a close match outside the experiments, got %s", res.Match)
+	}
+	assigned := cfg.Assign("session-1")
+	res, _ = rs.Resolve(context.Background(), Query{Text: "chicken salads", Experiments: assigned[1:]})
+	if res.Match != MatchGenerated {
+		t.Errorf("Expected the strict threshold to force generation, got %s", res.Match)
+	}
+	rs.Resolve(context.Background(), Query{Text: "chicken salads", Experiments: assigned})
+	if gen.calls != 2 {
+		t.Errorf("Expected the prompt variant to be generated separately, got %d calls", gen.calls)
+	}
+
+	stats := rs.Metrics.Stats()
+	if v := stats.Variants["strict=on"]; v.Requests != 2 || v.ByBand[BandBelow]+v.ByBand[BandNear] != 2 {
+		t.Errorf("Unexpected metrics for strict=on: %+v", v)
+	}
+	if v := stats.Variants["prompt=terse"]; v.Requests != 1 {
+		t.Errorf("Expected one request for prompt=terse, got %d", v.Requests)
+	}
+}
+
+// TestResolveTenant verifies that a tenant's threshold and prompt apply to its queries only,
+// and that generations with its prompt are cached apart and not persisted.
+func TestResolveTenant(t *testing.T) {
+	cfg := &tenant.Config{Tenants: []tenant.Profile{{Name: "keto", Principals: []string{"keto-app"}, Threshold: 0.99, PromptTemplate: "Keto {{.Query}}"}}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	gen := &stubGenerator{primary: generation.Recipe{Title: "Generated"}}
+	rs := newTestResolver(gen)
+	rs.PersistGenerations = true
+	before := len(rs.Store.All())
+
+	if res, _ := rs.Resolve(context.Background(), Query{Text: "chicken salads"}); res.Match != MatchClose {
+		t.Fatalf("Expected a close match for other callers, got %s", res.Match)
+	}
+	keto := cfg.For("keto-app")
+	if res, _ := rs.Resolve(context.Background(), Query{Text: "chicken salads", Tenant: keto}); res.Match != MatchGenerated {
+		t.Errorf("Expected the tenant's threshold to force generation, got %s", res.Match)
+	}
+	if rs.prompt(Query{Text: "chicken salads", Tenant: keto}) != keto.Prompt() {
+		t.Error("Expected the tenant's prompt template")
+	}
+	if key := cacheStyle(Query{Tenant: keto}, generation.Options{}); key != "tenant=keto" {
+		t.Errorf("Expected the tenant's generations to be cached apart, got style %q", key)
+	}
+	if n := len(rs.Store.All()); n != before {
+		t.Errorf("Expected the tenant's generation not to be persisted, got %d new recipes", n-before)
+	}
+}
+
+// TestResolveDegraded verifies that degraded mode, switched on manually or after consecutive
+// generator failures, answers with the best stored match without calling the generator.
+func TestResolveDegraded(t *testing.T) {
+	gen := &stubGenerator{err: errors.New("provider down")}
+	rs := newTestResolver(gen)
+
+	rs.Degradation.Set(true)
+	res, err := rs.Resolve(context.Background(), Query{Text: "chicken soup with rice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Degraded || res.Match != MatchBestEffort || res.Primary.Title == "" || gen.calls != 0 {
+		t.Errorf("Expected a best-effort stored match without generation, got %s match %q after %d calls", res.Match, res.Primary.Title, gen.calls)
+	}
+	if res, _ := rs.Resolve(context.Background(), Query{Text: "zzz"}); !res.Degraded || res.Match != MatchFallback {
+		t.Errorf("Expected a degraded fallback without any similar recipe, got %+v", res)
+	}
+	rs.Degradation.Set(false)
+
+	rs.Degradation.FailureThreshold = 2
+	for i := 0; i < 3; i++ {
+		rs.Resolve(context.Background(), Query{Text: "zzz"})
+	}
+	if gen.calls != 2 || !rs.Degradation.Active() {
+		t.Errorf("Expected degraded mode after 2 failures, got %d calls and status %+v", gen.calls, rs.Degradation.Status())
+	}
+	if status := rs.Degradation.Status(); status.Manual || status.Until == nil {
+		t.Errorf("Expected an automatic degradation, got %+v", status)
+	}
+}
+
+// TestResolveDegradedOutages verifies that only provider outages count toward degraded mode.
+func TestResolveDegradedOutages(t *testing.T) {
+	gen := &stubGenerator{err: fmt.Errorf("%w: bad json", generation.ErrUnparseableResponse)}
+	rs := newTestResolver(gen)
+	rs.Degradation.FailureThreshold = 2
+	for i := 0; i < 3; i++ {
+		rs.Resolve(context.Background(), Query{Text: "zzz"})
+	}
+	if gen.calls != 3 || rs.Degradation.Active() {
+		t.Errorf("Expected unparseable responses not to degrade, got %d calls and status %+v", gen.calls, rs.Degradation.Status())
+	}
+
+	gen.err = &generation.ErrProviderStatus{Code: http.StatusBadRequest}
+	for i := 0; i < 3; i++ {
+		rs.Resolve(context.Background(), Query{Text: "zzz"})
+	}
+	if rs.Degradation.Active() {
+		t.Errorf("Expected a non-temporary status not to degrade, got %+v", rs.Degradation.Status())
+	}
+
+	gen.err = &generation.ErrProviderStatus{Code: http.StatusServiceUnavailable}
+	for i := 0; i < 2; i++ {
+		rs.Resolve(context.Background(), Query{Text: "zzz"})
+	}
+	if !rs.Degradation.Active() {
+		t.Errorf("Expected degraded mode after 2 provider outages, got %+v", rs.Degradation.Status())
+	}
+}
+
+func TestWarmUp(t *testing.T) {
+	rs := newTestResolver(&stubGenerator{})
+	rs.Semantic = NewSemanticCache(DefaultSemanticThreshold)
+	n, err := rs.WarmUp(context.Background())
+	if err != nil || n != len(SampleRecipes()) {
+		t.Errorf("WarmUp() = %d, %v; want %d recipes", n, err, len(SampleRecipes()))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := rs.WarmUp(ctx); err == nil {
+		t.Error("Expected an error for a done context")
+	}
+}
+
+// TestMemoryStoreConcurrent verifies that resolution races neither with writes to the store nor
+// sees them half-applied. Run with -race.
+func TestMemoryStoreConcurrent(t *testing.T) {
+	store := NewMemoryStore(SampleRecipes())
+	rs := newTestResolver(&stubGenerator{primary: generation.Recipe{Title: "Generated"}})
+	rs.Store = store
+	before := store.All()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			r := model.NewRecipe("Tomato Soup", nil, nil, nil, "", nil)
+			store.Add(r)
+			r.Title = "Roasted Tomato Soup"
+			store.Update(r)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if _, err := rs.Resolve(context.Background(), Query{Text: "tomato soup"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+
+	if len(before) != len(SampleRecipes()) {
+		t.Errorf("Expected an earlier snapshot to be unaffected by writes, got %d recipes", len(before))
+	}
+	if got, want := len(store.All()), len(before)+100; got != want {
+		t.Errorf("Expected %d recipes, got %d", want, got)
+	}
+	if v := store.Version(); v != 200 {
+		t.Errorf("Expected version 200 after 200 writes, got %d", v)
+	}
+	if store.Update(model.Recipe{ID: "missing"}) || store.Version() != 200 {
+		t.Error("Expected an update of a missing recipe to change nothing")
+	}
+}
+
+// TestRecipesFile verifies that recipes saved with SaveRecipesFile load back unchanged.
+func TestRecipesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recipes.json")
+	saved := NewMemoryStore(SampleRecipes()).All()
+	if err := SaveRecipesFile(path, saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadRecipesFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != len(saved) || loaded[0].ID != saved[0].ID || loaded[1].Slug != saved[1].Slug {
+		t.Errorf("Expected the saved recipes back, got %+v", loaded)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Expected no temporary file left behind, got %v", err)
+	}
+}
+
+// TestMemoryStoreSlugs verifies that slugs are unique, stable across title changes and
+// usable for lookups, like IDs.
+func TestMemoryStoreSlugs(t *testing.T) {
+	store := NewMemoryStore(SampleRecipes())
+	first := model.NewRecipe("Pancakes", nil, nil, nil, "", nil)
+	second := model.NewRecipe("Pancakes!", nil, nil, nil, "", nil)
+	store.Add(first, second)
+	if err := store.Add(first); !errors.Is(err, ErrExists) || len(store.All()) != 4 {
+		t.Errorf("Expected adding a stored ID to be skipped with ErrExists, got %v", err)
+	}
+
+	got, ok := store.BySlug("pancakes-2")
+	if !ok || got.ID != second.ID {
+		t.Errorf("Expected the second pancakes recipe at pancakes-2, got %+v", got)
+	}
+	if got, ok := store.Get(second.ID); !ok || got.Slug != "pancakes-2" {
+		t.Errorf("Expected the second pancakes recipe by ID, got %+v", got)
+	}
+	if got, ok := store.BySlug("chicken-salad"); !ok || got.Title != "Chicken Salad" {
+		t.Errorf("Expected the sample recipes to get slugs, got %+v", got)
+	}
+
+	first.Title = "Fluffy Pancakes"
+	store.Update(first)
+	if got, ok := store.BySlug("pancakes"); !ok || got.Title != "Fluffy Pancakes" {
+		t.Errorf("Expected the slug to survive a title change, got %+v", got)
+	}
+	first.Slug = "pancakes-2"
+	store.Update(first)
+	if got, _ := store.BySlug("pancakes-2"); got.ID != second.ID {
+		t.Error("Expected an update not to take another recipe's slug")
+	}
+}
+
+// TestMemoryStoreAtomically verifies that a unit of work is applied at once or not at all, and
+// that stores without a Transactor get best-effort writes.
+func TestMemoryStoreAtomically(t *testing.T) {
+	store := NewMemoryStore(SampleRecipes())
+	var changed []string
+	store.OnChange = func(ids []string) { changed = append(changed, ids...) }
+	before := len(store.All())
+	target := store.All()[0]
+
+	failure := errors.New("outbox unavailable")
+	err := Atomically(context.Background(), store, func(w Writer) error {
+		w.Add(model.NewRecipe("Lemon Tart", nil, nil, nil, "", nil))
+		w.Delete(target.ID)
+		if len(store.All()) != before {
+			t.Error("Expected readers not to see the unit of work in progress")
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) || len(store.All()) != before || len(changed) != 0 {
+		t.Errorf("Expected the unit of work discarded, got %v with %d recipes and changes %v", err, len(store.All()), changed)
+	}
+
+	err = store.Atomically(context.Background(), func(w Writer) error {
+		w.Add(model.NewRecipe("Lemon Tart", nil, nil, nil, "", nil))
+		w.Delete(target.ID)
+		return nil
+	})
+	if err != nil || len(store.All()) != before || store.All()[before-1].Title != "Lemon Tart" || fmt.Sprint(changed) != fmt.Sprint([]string{target.ID}) {
+		t.Errorf("Expected the unit of work applied, got %v with changes %v", err, changed)
+	}
+
+	if err := Atomically(context.Background(), struct{ RecipeStore }{store}, func(Writer) error { return nil }); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly for a store without writes, got %v", err)
+	}
+}
+
+// TestMemoryStoreDeleteArchive verifies bulk deletion and that archived recipes are no longer
+// matched.
+func TestMemoryStoreDeleteArchive(t *testing.T) {
+	store := NewMemoryStore(SampleRecipes())
+	rs := newTestResolver(&stubGenerator{primary: generation.Recipe{Title: "Generated"}})
+	rs.Store = store
+	salad := store.All()[1]
+
+	if n := store.Archive(true, salad.ID, "missing"); n != 1 {
+		t.Errorf("Expected one recipe to be archived, got %d", n)
+	}
+	if res, _ := rs.Resolve(context.Background(), Query{Text: "Chicken Salad"}); res.Match != MatchGenerated {
+		t.Errorf("Expected an archived recipe not to match, got %s match", res.Match)
+	}
+	if n := store.Archive(true, salad.ID); n != 0 {
+		t.Errorf("Expected archiving twice to change nothing, got %d", n)
+	}
+
+	if n := store.Delete(salad.ID, "missing"); n != 1 || len(store.All()) != 1 {
+		t.Errorf("Expected one recipe to be deleted, got %d with %d left", n, len(store.All()))
+	}
+}
+
+// TestResolveRecipeID verifies that a query for a recipe ID skips matching and generation and
+// returns the recipe, or the one it was merged into, with similar ones as alternatives.
+func TestResolveRecipeID(t *testing.T) {
+	gen := &stubGenerator{primary: generation.Recipe{Title: "Generated"}}
+	rs := newTestResolver(gen)
+	salad := rs.Store.All()[1]
+	caesar := model.NewRecipe("Chicken Caesar Salad", []string{"chicken", "lettuce"}, []string{"Toss."}, nil, "", nil)
+	wrap := model.NewRecipe("Chicken Wrap", []string{"chicken", "tortilla"}, []string{"Roll."}, nil, "", nil)
+	wrap.Difficulty = model.DifficultyHard
+	rs.Store.(*MemoryStore).Add(caesar, wrap)
+
+	res, err := rs.Resolve(context.Background(), Query{RecipeID: salad.ID, Text: "anything"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Match != MatchExact || res.Primary.ID != salad.ID || len(res.Alternatives) != 2 || res.Alternatives[0].ID != caesar.ID || gen.calls != 0 {
+		t.Errorf("Expected the recipe with its most similar alternatives first, got %s %+v %+v", res.Match, res.Primary, res.Alternatives)
+	}
+	res, _ = rs.Resolve(context.Background(), Query{RecipeID: salad.ID, Count: 2, MaxDifficulty: model.DifficultyMedium})
+	if len(res.Alternatives) != 1 || res.Alternatives[0].ID != caesar.ID {
+		t.Errorf("Expected the alternatives constrained and limited by the query, got %+v", res.Alternatives)
+	}
+
+	// A recipe merged into another resolves to the one it was merged into.
+	merged := salad
+	merged.MergedFrom = []model.MergedRecipe{{ID: "old-salad", Slug: "old-salad", Title: "Old Salad"}}
+	rs.Store.(*MemoryStore).Update(merged)
+	if res, err := rs.Resolve(context.Background(), Query{RecipeID: "old-salad"}); err != nil || res.Primary.ID != salad.ID {
+		t.Errorf("Expected the merged recipe to resolve to %s, got %s (err %v)", salad.ID, res.Primary.ID, err)
+	}
+
+	rs.Store.(*MemoryStore).Archive(true, salad.ID)
+	for _, id := range []string{salad.ID, "old-salad", "unknown"} {
+		if _, err := rs.Resolve(context.Background(), Query{RecipeID: id}); !errors.Is(err, ErrRecipeNotFound) {
+			t.Errorf("Resolve(%q) error = %v, want ErrRecipeNotFound", id, err)
+		}
+	}
+}
+
+// TestMemoryStoreListSave verifies that the store is a complete Storage, listing the recipes a filter
+// keeps in order and saving new and stored recipes.
+func TestMemoryStoreListSave(t *testing.T) {
+	s := NewMemoryStore(nil)
+	var st Storage = s
+	ctx := context.Background()
+	soup := model.NewRecipe("Tomato Soup", nil, nil, nil, "", nil)
+	pie := model.NewRecipe("Chicken Pie", nil, nil, nil, "", nil)
+	pie.Status = model.StatusPendingReview
+	stew := model.NewRecipe("Beef Stew", nil, nil, nil, "", nil)
+	for _, r := range []model.Recipe{soup, pie, stew} {
+		if err := st.Save(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stew.Archived = true
+	if err := st.Save(ctx, stew); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := st.Get(stew.ID); !ok || !got.Archived || got.Slug != "beef-stew" {
+		t.Errorf("Expected the stew saved over, got %+v", got)
+	}
+
+	titles := func(f Filter) string {
+		recipes, err := st.List(ctx, f)
+		var out []string
+		for _, r := range recipes {
+			out = append(out, r.Title)
+		}
+		return fmt.Sprint(out, err)
+	}
+	if got := titles(Filter{}); got != "[Tomato Soup Chicken Pie Beef Stew] <nil>" {
+		t.Errorf("Expected every recipe in order, got %s", got)
+	}
+	if got := titles(Filter{Statuses: []model.Status{model.StatusPublished}, Unarchived: true}); got != "[Tomato Soup] <nil>" {
+		t.Errorf("Expected the published unarchived recipes, got %s", got)
+	}
+	if got := titles(Filter{Statuses: []model.Status{model.StatusPendingReview}}); got != "[Chicken Pie] <nil>" {
+		t.Errorf("Expected the recipes pending review, got %s", got)
+	}
+}
+
+// TestMemoryStoreSearch verifies that the inverted index finds the recipes sharing a token
+// with the query, title matches first, and follows writes.
+func TestMemoryStoreSearch(t *testing.T) {
+	store := NewMemoryStore(SampleRecipes())
+	titles := func(query string, limit int) string {
+		recipes, err := store.Search(context.Background(), query, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for _, r := range recipes {
+			out = append(out, r.Title)
+		}
+		return strings.Join(out, ",")
+	}
+
+	if got := titles("Chicken Salad!", 0); got != "Chicken Salad" {
+		t.Errorf("Search(chicken salad) = %q", got)
+	}
+	if got := titles("garlic chicken", 0); got != "Chicken Salad,Spaghetti Bolognese" {
+		t.Errorf("Expected the title match before the ingredient match, got %q", got)
+	}
+	if got := titles("garlic chicken", 1); got != "Chicken Salad" {
+		t.Errorf("Expected the limit applied, got %q", got)
+	}
+	if got := titles("sushi", 0); got != "" {
+		t.Errorf("Expected no candidates, got %q", got)
+	}
+
+	stew := model.NewRecipe("Beef Stew", []string{"beef"}, []string{"Simmer."}, nil, "", nil)
+	stew.Provenance = &model.Provenance{Origin: model.OriginGenerated, Query: "something warming"}
+	store.Add(stew)
+	if got := titles("something warming", 0); got != "Beef Stew" {
+		t.Errorf("Expected an added generation found by its query, got %q", got)
+	}
+	store.Delete(stew.ID)
+	if got := titles("beef stew", 0); got != "Spaghetti Bolognese" {
+		t.Errorf("Expected a deleted recipe gone from the index, got %q", got)
+	}
+
+	pending := model.NewRecipe("Chicken Pie", nil, nil, nil, "", nil)
+	pending.Status = model.StatusPendingReview
+	store.Add(pending)
+	servable := Filter{Statuses: []model.Status{model.StatusPublished}, Unarchived: true}
+	if got, _ := store.SearchFiltered(context.Background(), "chicken pie", 1, servable); len(got) != 1 || got[0].Title != "Chicken Salad" {
+		t.Errorf("Expected the filtered recipe not to count towards the limit, got %+v", got)
+	}
+}
+
+// TestResolvePendingReview verifies that recipes pending review are only matched with
+// ServePending, and rejected ones never.
+func TestResolvePendingReview(t *testing.T) {
+	pending := model.NewRecipe("Miso Soup", nil, nil, nil, "", nil)
+	pending.Status = model.StatusPendingReview
+	rejected := model.NewRecipe("Tofu Stir Fry", nil, nil, nil, "", nil)
+	rejected.Status = model.StatusRejected
+	rs := newTestResolver(&stubGenerator{primary: generation.Recipe{Title: "Generated"}})
+	rs.Store = NewMemoryStore([]model.Recipe{pending, rejected})
+
+	for _, serve := range []bool{false, true} {
+		rs.ServePending = serve
+		rs.Cache = NewMemoryCache()
+		res, _ := rs.Resolve(context.Background(), Query{Text: "Miso Soup"})
+		if got := res.Primary.Status == model.StatusPendingReview; got != serve {
+			t.Errorf("ServePending=%t: got %s match %+v", serve, res.Match, res.Primary)
+		}
+		if res, _ := rs.Resolve(context.Background(), Query{Text: "Tofu Stir Fry"}); res.Match != MatchGenerated {
+			t.Errorf("ServePending=%t: expected a rejected recipe not to match, got %s", serve, res.Match)
+		}
+	}
+}
+
+// TestResolveTaxonomy verifies that the taxonomy scores related ingredients as close matches and
+// lists the allergens of resolved recipes.
+func TestResolveTaxonomy(t *testing.T) {
+	stored := model.NewRecipe("Parmesan Pasta", []string{"200 g penne", "50 g parmesan", "salt"}, nil, nil, "", nil)
+	rs := newTestResolver(&stubGenerator{primary: generation.Recipe{Title: "Generated", Ingredients: []string{"2 eggs"}}})
+	rs.Store = NewMemoryStore([]model.Recipe{stored})
+	rs.Taxonomy = taxonomy.Default()
+	rs.Threshold = 0.6
+
+	res, _ := rs.Resolve(context.Background(), Query{Text: "pecorino pasta"})
+	if res.Match != MatchGenerated || !reflect.DeepEqual(res.Primary.Allergens, []string{"egg"}) {
+		t.Errorf("Expected a generated recipe with egg, got %s %+v", res.Match, res.Primary)
+	}
+
+	rs.Scorers = append(rs.Scorers, WeightedScorer{Name: "taxonomy", Scorer: rs.Taxonomy, Weight: 3})
+	rs.Cache = NewMemoryCache()
+	res, _ = rs.Resolve(context.Background(), Query{Text: "pecorino pasta"})
+	if res.Match != MatchClose || res.Primary.ID != stored.ID {
+		t.Fatalf("Expected the sibling cheese to match closely, got %s %+v", res.Match, res.Primary)
+	}
+	if want := []string{"gluten", "milk"}; !reflect.DeepEqual(res.Primary.Allergens, want) {
+		t.Errorf("Expected allergens %v, got %v", want, res.Primary.Allergens)
+	}
+	if got := rs.Store.All()[0].Allergens; got != nil {
+		t.Errorf("Expected the stored recipe to be left alone, got %v", got)
+	}
+}
+
+// TestResolveTaxonomySearch verifies that with the taxonomy scorer a Searcher store is scanned
+// in full, so that recipes sharing no word with the query still match.
+func TestResolveTaxonomySearch(t *testing.T) {
+	crisps := model.NewRecipe("Parmesan Crisps", []string{"100 g parmesan"}, []string{"Bake."}, nil, "", nil)
+	rs := newTestResolver(&stubGenerator{primary: generation.Recipe{Title: "Generated"}})
+	rs.Store = NewMemoryStore([]model.Recipe{crisps})
+	rs.Taxonomy = taxonomy.Default()
+	rs.Scorers = append(rs.Scorers, WeightedScorer{Name: "taxonomy", Scorer: rs.Taxonomy, Weight: 3})
+	rs.Threshold = 0.2
+
+	res, _ := rs.Resolve(context.Background(), Query{Text: "pecorino"})
+	if res.Match != MatchClose || res.Primary.ID != crisps.ID {
+		t.Errorf("Expected the related cheese to match closely, got %s %q", res.Match, res.Primary.Title)
+	}
+}
+
+// TestResolveExclude verifies that excluding a group excludes its descendants from stored
+// matches, generated alternatives and the generation prompt.
+func TestResolveExclude(t *testing.T) {
+	cacio := model.NewRecipe("Cacio e Pepe", []string{"200 g spaghetti", "80 g pecorino romano", "black pepper"}, nil, nil, "", nil)
+	gen := &stubGenerator{
+		primary: generation.Recipe{Title: "Vegan Cacio e Pepe", Ingredients: []string{"spaghetti", "nutritional yeast"}},
+		alternatives: []generation.Recipe{
+			{Title: "Buttered Noodles", Ingredients: []string{"noodles", "2 tbsp butter"}},
+			{Title: "Peanut Noodles", Ingredients: []string{"noodles", "peanut butter"}},
+		},
+	}
+	rs := newTestResolver(gen)
+	rs.Store = NewMemoryStore([]model.Recipe{cacio})
+	rs.Taxonomy = taxonomy.Default()
+
+	if res, _ := rs.Resolve(context.Background(), Query{Text: "cacio e pepe", Exclude: []string{"cheese"}}); res.Match != MatchGenerated {
+		t.Fatalf("Expected the cheese recipe to be excluded, got %s %q", res.Match, res.Primary.Title)
+	}
+	res, _ := rs.Resolve(context.Background(), Query{Text: "cacio e pepe", Exclude: []string{"dairy"}})
+	if len(res.Alternatives) != 1 || res.Alternatives[0].Title != "Peanut Noodles" {
+		t.Errorf("Expected only the dairy-free alternative, got %+v", res.Alternatives)
+	}
+	guidance := gen.options.Guidance()
+	for _, want := range []string{"dairy", "butter", "pecorino"} {
+		if !strings.Contains(guidance, want) {
+			t.Errorf("Expected %q to be excluded in the prompt, got %q", want, guidance)
+		}
+	}
+	if gen.calls != 2 {
+		t.Errorf("Expected different exclusions not to share a cache entry, got %d generations", gen.calls)
+	}
+	if res, _ := rs.Resolve(context.Background(), Query{Text: "cacio e pepe", Exclude: []string{"nuts"}}); res.Match != MatchExact {
+		t.Errorf("Expected the recipe to match when excluding something else, got %s", res.Match)
+	}
+}
+
+func TestResolveNutrition(t *testing.T) {
+	hearty := model.NewRecipe("Chicken Salad", []string{"chicken", "mayonnaise"}, nil, map[string]interface{}{"calories": 700.0, "protein": 40.0}, "", nil)
+	gen := &stubGenerator{
+		primary: generation.Recipe{Title: "Creamy Chicken Salad", NutritionalInfo: map[string]interface{}{"calories": 800.0}},
+		alternatives: []generation.Recipe{
+			{Title: "Chicken Caesar", NutritionalInfo: map[string]interface{}{"calories": 900.0}},
+			{Title: "Light Chicken Salad", NutritionalInfo: "About 450 kcal"},
+		},
+	}
+	rs := newTestResolver(gen)
+	rs.Store = NewMemoryStore([]model.Recipe{hearty})
+	rs.CompleteNutrition = func(ctx context.Context, r model.Recipe) (model.Recipe, error) {
+		if r.Title == "Light Chicken Salad" {
+			r.NutritionalInfo = map[string]interface{}{"calories": 450.0}
+		}
+		return r, nil
+	}
+
+	light := []model.NutritionConstraint{{Nutrient: "calories", Max: 500}}
+	res, _ := rs.Resolve(context.Background(), Query{Text: "chicken salad", Nutrition: light})
+	if res.Match != MatchGenerated {
+		t.Fatalf("Expected the stored recipe over the limit to be skipped, got %s %q", res.Match, res.Primary.Title)
+	}
+	if res.Primary.Title != "Light Chicken Salad" || len(res.Alternatives) != 0 || res.UnmetNutrition != nil {
+		t.Errorf("Expected the only recipe within the limit to be promoted, got %q %+v unmet %v", res.Primary.Title, res.Alternatives, res.UnmetNutrition)
+	}
+	if guidance := gen.options.Guidance(); !strings.Contains(guidance, "calories at most 500 kcal") {
+		t.Errorf("Expected the limit in the prompt, got %q", guidance)
+	}
+
+	strict := []model.NutritionConstraint{{Nutrient: "calories", Max: 300}}
+	res, _ = rs.Resolve(context.Background(), Query{Text: "chicken salad", Nutrition: strict})
+	if res.Primary.Title != "Creamy Chicken Salad" || !reflect.DeepEqual(res.UnmetNutrition, strict) {
+		t.Errorf("Expected the primary recipe to be flagged, got %q unmet %v", res.Primary.Title, res.UnmetNutrition)
+	}
+
+	protein := []model.NutritionConstraint{{Nutrient: "protein", Min: 30}}
+	if res, _ := rs.Resolve(context.Background(), Query{Text: "chicken salad", Nutrition: protein}); res.Match != MatchExact {
+		t.Errorf("Expected the stored recipe to meet the protein bound, got %s", res.Match)
+	}
+}
+
+func TestResolveTotalTime(t *testing.T) {
+	quick := model.NewRecipe("Chicken Salad", []string{"chicken", "lettuce"}, nil, nil, "", nil)
+	quick.TotalTime = 15
+	undated := model.NewRecipe("Chicken Salad", []string{"chicken", "lettuce"}, nil, nil, "", nil)
+	gen := &stubGenerator{
+		primary: generation.Recipe{Title: "Roast Chicken Salad", TotalTime: 75},
+		alternatives: []generation.Recipe{
+			{Title: "Chicken Caesar"},
+			{Title: "Chopped Chicken Salad", TotalTime: 20},
+		},
+	}
+	rs := newTestResolver(gen)
+
+	rs.Store = NewMemoryStore([]model.Recipe{quick})
+	if res, _ := rs.Resolve(context.Background(), Query{Text: "chicken salad", MaxTotalTime: 20}); res.Match != MatchExact {
+		t.Errorf("Expected the stored recipe within the limit to match, got %s", res.Match)
+	}
+
+	rs.Store = NewMemoryStore([]model.Recipe{undated})
+	res, _ := rs.Resolve(context.Background(), Query{Text: "chicken salad", MaxTotalTime: 30})
+	if res.Match != MatchGenerated {
+		t.Fatalf("Expected a stored recipe without a total time to be skipped, got %s", res.Match)
+	}
+	if res.Primary.Title != "Chopped Chicken Salad" || len(res.Alternatives) != 0 || res.OverTime {
+		t.Errorf("Expected the only recipe within the limit to be promoted, got %q %+v over time %t", res.Primary.Title, res.Alternatives, res.OverTime)
+	}
+	if guidance := gen.options.Guidance(); !strings.Contains(guidance, "at most 30 minutes") {
+		t.Errorf("Expected the limit in the prompt, got %q", guidance)
+	}
+
+	res, _ = rs.Resolve(context.Background(), Query{Text: "chicken salad", MaxTotalTime: 10})
+	if res.Primary.Title != "Roast Chicken Salad" || !res.OverTime {
+		t.Errorf("Expected the primary recipe to be flagged, got %q over time %t", res.Primary.Title, res.OverTime)
+	}
+}
+
+func TestResolveFusion(t *testing.T) {
+	tacos := model.NewRecipe("Beef Tacos", []string{"tortillas", "beef"}, nil, nil, "", nil)
+	gen := &stubGenerator{
+		primary:      generation.Recipe{Title: "Bulgogi Tacos", Ingredients: []string{"tortillas", "bulgogi beef", "kimchi"}},
+		alternatives: []generation.Recipe{{Title: "Kimchi Quesadilla", Ingredients: []string{"tortillas", "kimchi", "cheese"}}},
+	}
+	rs := newTestResolver(gen)
+	rs.Store = NewMemoryStore([]model.Recipe{tacos})
+
+	res, _ := rs.Resolve(context.Background(), Query{Text: "beef tacos", Fusion: []string{"korean", "mexican"}})
+	if res.Match != MatchGenerated {
+		t.Fatalf("Expected the untagged stored recipe to be skipped, got %s", res.Match)
+	}
+	for _, r := range append([]model.Recipe{res.Primary}, res.Alternatives...) {
+		if !r.HasTag("cuisine:korean") || !r.HasTag("cuisine:mexican") {
+			t.Errorf("Expected %q to be tagged with both cuisines, got %v", r.Title, r.Tags)
+		}
+	}
+	if guidance := gen.options.Guidance(); !strings.Contains(guidance, "Fuse korean and mexican cuisines") {
+		t.Errorf("Expected the fusion in the prompt, got %q", guidance)
+	}
+	if res, _ := rs.Resolve(context.Background(), Query{Text: "beef tacos"}); res.Match != MatchExact {
+		t.Errorf("Expected the fusion not to affect plain queries, got %s", res.Match)
+	}
+}
+
+// recordingBus is an InvalidationBus recording what is published and delivering remote
+// announcements to subscribers.
+type recordingBus struct {
+	published [][]string
+	remote    [][]string
+}
+
+func (b *recordingBus) Publish(ctx context.Context, ids []string) error {
+	b.published = append(b.published, ids)
+	return nil
+}
+
+func (b *recordingBus) Subscribe(ctx context.Context, handle func(ids []string)) error {
+	for _, ids := range b.remote {
+		handle(ids)
+	}
+	return nil
+}
+
+// TestInvalidate verifies that changing a stored recipe drops the cached results serving it,
+// locally and on announcements from other replicas.
+func TestInvalidate(t *testing.T) {
+	gen := &stubGenerator{
+		primary:      generation.Recipe{ID: "gen-1", Title: "Mushroom Risotto"},
+		alternatives: []generation.Recipe{{ID: "gen-2", Title: "Mushroom Pilaf"}},
+	}
+	rs := newTestResolver(gen)
+	rs.Semantic = NewSemanticCache(DefaultSemanticThreshold)
+	bus := &recordingBus{remote: [][]string{{"gen-1"}}}
+	rs.Bus = bus
+	store := rs.Store.(*MemoryStore)
+	store.OnChange = func(ids []string) { rs.Invalidate(context.Background(), ids...) }
+	resolve := func() {
+		if _, err := rs.Resolve(context.Background(), Query{Text: "mushroom risotto"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resolve()
+	if n := rs.Invalidate(context.Background(), "unrelated"); n != 0 {
+		t.Errorf("Expected no entries dropped for an unrelated recipe, got %d", n)
+	}
+	// An alternative counts as much as the primary recipe.
+	if n := rs.Invalidate(context.Background(), "gen-2"); n != 2 {
+		t.Errorf("Expected the exact and semantic entries to be dropped, got %d", n)
+	}
+	resolve()
+	if gen.calls != 2 {
+		t.Errorf("Expected a regeneration after the invalidation, got %d calls", gen.calls)
+	}
+	if !reflect.DeepEqual(bus.published, [][]string{{"unrelated"}, {"gen-2"}}) {
+		t.Errorf("Unexpected announcements: %v", bus.published)
+	}
+
+	if err := rs.Listen(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	resolve()
+	if gen.calls != 3 || len(bus.published) != 2 {
+		t.Errorf("Expected a remote invalidation to be applied without being announced again, got %d calls and %d announcements", gen.calls, len(bus.published))
+	}
+
+	store.Add(model.Recipe{ID: "stored", Title: "Stored Stew"})
+	store.Archive(true, "stored")
+	store.Delete("stored", "missing")
+	if !reflect.DeepEqual(bus.published[2:], [][]string{{"stored"}, {"stored"}}) {
+		t.Errorf("Expected archiving and deleting a stored recipe to invalidate it, got %v", bus.published[2:])
+	}
+}
+
+// TestResolveGroundingExamples verifies that the stored recipes most similar to a query are
+// sent to the generator as examples.
+func TestResolveGroundingExamples(t *testing.T) {
+	gen := &stubGenerator{primary: generation.Recipe{Title: "Spaghetti Carbonara"}}
+	rs := newTestResolver(gen)
+	if _, err := rs.Resolve(context.Background(), Query{Text: "spaghetti carbonara"}); err != nil {
+		t.Fatal(err)
+	}
+	if gen.examples != nil {
+		t.Errorf("Expected no examples by default, got %+v", gen.examples)
+	}
+
+	rs.GroundingExamples = 2
+	if _, err := rs.Resolve(context.Background(), Query{Text: "spaghetti alla puttanesca"}); err != nil {
+		t.Fatal(err)
+	}
+	// Only one sample recipe resembles the query at all.
+	if len(gen.examples) != 1 || gen.examples[0].Title != "Spaghetti Bolognese" || len(gen.examples[0].Steps) == 0 {
+		t.Errorf("Expected Spaghetti Bolognese as the only example, got %+v", gen.examples)
+	}
+}
+
+// TestResolveCount verifies that a query for several recipes is generated rather than matched,
+// and that repeated or surplus alternatives are dropped.
+func TestResolveCount(t *testing.T) {
+	gen := &stubGenerator{
+		primary: generation.Recipe{ID: "gen-1", Title: "Chicken Salad"},
+		alternatives: []generation.Recipe{
+			{ID: "gen-2", Title: "chicken salad"},
+			{ID: "gen-3", Title: "Chicken Caesar Wrap"},
+			{ID: "gen-4", Title: "Chicken Caesar Wrap"},
+			{ID: "gen-5", Title: "Chicken Noodle Soup"},
+			{ID: "gen-6", Title: "Chicken Tikka"},
+		},
+	}
+	rs := newTestResolver(gen)
+	res, err := rs.Resolve(context.Background(), Query{Text: "chicken salad", Count: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Match != MatchGenerated || gen.options.Count != 3 {
+		t.Fatalf("Expected a generation of 3 recipes despite the stored match, got a %s match with options %+v", res.Match, gen.options)
+	}
+	var ids []string
+	for _, r := range res.Alternatives {
+		ids = append(ids, r.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"gen-3", "gen-5"}) {
+		t.Errorf("Expected two distinct alternatives, got %v", ids)
+	}
+}
+
+// TestResolveDiversity verifies that a diversity above zero drops alternatives that are variants
+// of the primary recipe and ranks the rest by maximal marginal relevance.
+func TestResolveDiversity(t *testing.T) {
+	gen := &stubGenerator{
+		primary: generation.Recipe{ID: "gen-1", Title: "Chicken Salad", Ingredients: []string{"chicken", "lettuce", "tomato"}},
+		alternatives: []generation.Recipe{
+			{ID: "gen-2", Title: "Grilled Chicken Salad", Ingredients: []string{"chicken", "lettuce", "tomato", "oil"}},
+			{ID: "gen-4", Title: "Chicken Salad Wrap", Ingredients: []string{"chicken", "lettuce", "tortilla"}},
+			{ID: "gen-3", Title: "Thai Beef Salad", Ingredients: []string{"beef", "lime", "mint"}},
+		},
+	}
+	rs := newTestResolver(gen)
+	for _, tt := range []struct {
+		diversity float64
+		want      []string
+	}{
+		{0, []string{"gen-2", "gen-4", "gen-3"}},
+		{0.35, []string{"gen-3", "gen-4"}},
+		{0.8, []string{"gen-3"}},
+	} {
+		res, err := rs.Resolve(context.Background(), Query{Text: "tossed salad bowl", Diversity: tt.diversity})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, r := range res.Alternatives {
+			ids = append(ids, r.ID)
+		}
+		if !reflect.DeepEqual(ids, tt.want) {
+			t.Errorf("Diversity %v: expected alternatives %v, got %v", tt.diversity, tt.want, ids)
+		}
+	}
+	if gen.calls != 1 {
+		t.Errorf("Expected diversity to apply to the cached generation, got %d generations", gen.calls)
+	}
+}
+
+// TestResolveMenu verifies that a menu reuses fitting stored recipes and generates the other
+// courses to go with them.
+func TestResolveMenu(t *testing.T) {
+	gen := &stubGenerator{primary: generation.Recipe{Title: "Chicken Cacciatore", Ingredients: []string{"chicken"}}}
+	rs := newTestResolver(gen)
+	starter := model.NewRecipe("Italian Bruschetta", []string{"bread", "tomato"}, []string{"Toast."}, nil, "", nil)
+	starter.Tags = []string{model.CourseTag(model.CourseStarter)}
+	rs.Store.(*MemoryStore).Add(starter)
+
+	menu, err := rs.ResolveMenu(context.Background(), Query{Text: "italian dinner"}, model.Courses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(menu) != 3 {
+		t.Fatalf("Expected three courses, got %d", len(menu))
+	}
+	if menu[0].Course != model.CourseStarter || menu[0].Result.Primary.Title != "Italian Bruschetta" || menu[0].Result.Match != MatchClose {
+		t.Errorf("Expected the stored starter, got %+v", menu[0])
+	}
+	for _, c := range menu[1:] {
+		if c.Result.Match != MatchGenerated || !c.Result.Primary.HasTag(model.CourseTag(c.Course)) {
+			t.Errorf("Expected a generated %s tagged for its course, got %+v", c.Course, c.Result)
+		}
+	}
+	if gen.calls != 2 || gen.options.Course != model.CourseDessert || !reflect.DeepEqual(gen.options.Companions, []string{"Italian Bruschetta", "Chicken Cacciatore"}) {
+		t.Errorf("Expected the dessert to be generated knowing the other courses, got %d calls with options %+v", gen.calls, gen.options)
+	}
+}
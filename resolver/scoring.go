@@ -0,0 +1,97 @@
+package resolver
+
+import (
+	"sort"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// WeightedScorer is a Scorer together with its name and its weight in the combined score.
+type WeightedScorer struct {
+	Name   string
+	Scorer Scorer
+	Weight float64
+}
+
+// Candidate is a stored recipe scored against a query.
+type Candidate struct {
+	Recipe model.Recipe
+	// Scores holds the individual score of each scorer, keyed by scorer name.
+	Scores map[string]float64
+	// Score is the weighted combination of Scores used for matching, including any seasonal,
+	// occasion and recency boosts (reported in Scores as "season", "occasion" and "recency").
+	Score float64
+}
+
+// score combines the results of rs.Scorers, with tuned weights, into a weighted average (see
+// score).
+func (rs *Resolver) score(query, title string) float64 {
+	return score(rs.scorers(Query{}), query, title)
+}
+
+// lexical reports whether every scorer with a positive weight is lexical: it scores 0 for titles
+// sharing no word with the query, so that a Searcher's results hold every recipe they can
+// match. Other scorers, such as the taxonomy's, also match related words ("pecorino" and
+// "Parmesan"), and need every stored recipe.
+func lexical(scorers []WeightedScorer) bool {
+	for _, s := range scorers {
+		if s.Weight <= 0 {
+			continue
+		}
+		switch s.Scorer.(type) {
+		case JaccardScorer, OverlapScorer:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// score combines the individual scorer results into a weighted average. Scorers with a
+// non-positive weight are ignored; with no positive weights the score is 0.
+func score(scorers []WeightedScorer, query, title string) float64 {
+	total, weights := 0.0, 0.0
+	for _, s := range scorers {
+		if s.Weight <= 0 {
+			continue
+		}
+		total += s.Weight * s.Scorer.Score(query, title)
+		weights += s.Weight
+	}
+	if weights == 0 {
+		return 0
+	}
+	return total / weights
+}
+
+// Rank scores every stored recipe against query and returns the candidates ordered from best
+// to worst, with the per-scorer breakdown. It is meant for tuning and evaluation tools; Resolve
+// uses the same combined score.
+func (rs *Resolver) Rank(query string) []Candidate {
+	recipes := rs.servable(rs.Store.All())
+	candidates := make([]Candidate, 0, len(recipes))
+	found := rs.Occasions.detect(query)
+	for _, r := range recipes {
+		scores := make(map[string]float64, len(rs.Scorers))
+		for _, s := range rs.Scorers {
+			scores[s.Name] = s.Scorer.Score(query, r.Title)
+		}
+		score := rs.score(query, r.Title)
+		season, occasions, recency := rs.Seasonality.boost(r), rs.Occasions.boost(r, found), rs.Recency.boost(r)
+		if season > 0 {
+			scores["season"] = season
+		}
+		if occasions > 0 {
+			scores["occasion"] = occasions
+		}
+		if recency > 0 {
+			scores["recency"] = recency
+		}
+		score *= 1 + season + occasions + recency
+		candidates = append(candidates, Candidate{Recipe: r, Scores: scores, Score: score})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates
+}
@@ -0,0 +1,59 @@
+package resolver
+
+import (
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/season"
+)
+
+// Seasonality configures seasonal ranking. A recipe's similarity score is multiplied by
+// 1 + Boost×f, where f is the share of its seasonal ingredients that are in season now.
+type Seasonality struct {
+	Table      season.Table
+	Hemisphere season.Hemisphere
+	Boost      float64
+	// Now returns the request time; it defaults to time.Now.
+	Now func() time.Time
+}
+
+// NewSeasonality returns a configuration using the bundled table with the given boost.
+func NewSeasonality(h season.Hemisphere, boost float64) *Seasonality {
+	return &Seasonality{Table: season.Default(), Hemisphere: h, Boost: boost, Now: time.Now}
+}
+
+func (s *Seasonality) month() time.Month {
+	if s.Now == nil {
+		return time.Now().Month()
+	}
+	return s.Now().Month()
+}
+
+// boost returns the ranking boost for r; it is 0 when s is nil.
+func (s *Seasonality) boost(r model.Recipe) float64 {
+	if s == nil || s.Boost <= 0 {
+		return 0
+	}
+	return s.Boost * s.Table.Fraction(r, s.month(), s.Hemisphere)
+}
+
+// picks returns the recipes that are seasonal picks this month.
+func (s *Seasonality) picks(recipes []model.Recipe) []model.Recipe {
+	var out []model.Recipe
+	month := s.month()
+	for _, r := range recipes {
+		if s.Table.Seasonal(r, month, s.Hemisphere) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// seasonality returns the configured Seasonality, or a northern-hemisphere default without a
+// boost for seasonal filtering when none is configured.
+func (rs *Resolver) seasonality() *Seasonality {
+	if rs.Seasonality != nil {
+		return rs.Seasonality
+	}
+	return NewSeasonality(season.North, 0)
+}
@@ -0,0 +1,125 @@
+package resolver
+
+import (
+	"context"
+
+	"github.com/pageza/recipe-resolver-ms/cache"
+	"github.com/pageza/recipe-resolver-ms/nlp"
+)
+
+// DefaultSemanticThreshold is the default minimum similarity for a SemanticCache hit. It is
+// deliberately tight: plurals and reordered words match, different ingredients do not.
+const DefaultSemanticThreshold = 0.9
+
+// DefaultSemanticEntries is how many generations a SemanticCache keeps by default.
+const DefaultSemanticEntries = 1000
+
+// Embedder turns a query into a vector whose cosine similarity to another query's vector
+// reflects how close their meanings are.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float64, error)
+}
+
+// TrigramEmbedder is an Embedder based on character trigrams (see nlp.TrigramVector). It needs
+// no provider, but only captures surface similarity; plug in a model-backed Embedder for
+// paraphrases.
+type TrigramEmbedder struct{}
+
+// Embed implements Embedder.
+func (TrigramEmbedder) Embed(_ context.Context, text string) ([]float64, error) {
+	return nlp.TrigramVector(text), nil
+}
+
+// semanticEntry is a cached generation with the embedding of the query that prompted it.
+type semanticEntry struct {
+	style  string // generation options the result was produced with
+	vector []float64
+	result Result
+}
+
+// SemanticCache serves a cached generation to a query that is near-identical, by embedding
+// similarity, to one generated before, where the exact Cache only matches equal normalized
+// queries. Entries are kept under their exact cache key, which is used for flushing and
+// erasure. It is bounded (see cache.Cache) and safe for concurrent use.
+type SemanticCache struct {
+	Embedder Embedder
+	// Threshold is the minimum cosine similarity for a hit.
+	Threshold float64
+
+	entries *cache.Cache[semanticEntry]
+}
+
+// NewSemanticCache returns an empty cache of at most DefaultSemanticEntries generations and
+// DefaultCacheBytes, using TrigramEmbedder and the given threshold.
+func NewSemanticCache(threshold float64) *SemanticCache {
+	return NewBoundedSemanticCache(threshold, cache.Options{MaxEntries: DefaultSemanticEntries, MaxBytes: DefaultCacheBytes})
+}
+
+// NewBoundedSemanticCache returns an empty cache bounded by opts, using TrigramEmbedder and the
+// given threshold.
+func NewBoundedSemanticCache(threshold float64, opts cache.Options) *SemanticCache {
+	size := func(e semanticEntry) int64 { return resultSize(e.result) + int64(len(e.style)+8*len(e.vector)) }
+	return &SemanticCache{Embedder: TrigramEmbedder{}, Threshold: threshold, entries: cache.New(opts, size)}
+}
+
+// Lookup returns the cached result whose query is most similar to query among those generated
+// with the same style, along with the similarity, if it meets the threshold.
+func (c *SemanticCache) Lookup(ctx context.Context, query, style string) (Result, float64, bool) {
+	v, err := c.Embedder.Embed(ctx, query)
+	if err != nil || v == nil {
+		return Result{}, 0, false
+	}
+	best, bestSim, found := "", 0.0, false
+	c.entries.Range(func(key string, e semanticEntry) bool {
+		if e.style != style {
+			return true
+		}
+		if sim := nlp.CosineSimilarity(v, e.vector); sim >= c.Threshold && (sim > bestSim || sim == bestSim && key < best) {
+			best, bestSim, found = key, sim, true
+		}
+		return true
+	})
+	if !found {
+		c.entries.Miss()
+		return Result{}, 0, false
+	}
+	// Get counts the hit and marks the entry used; it may have been evicted meanwhile.
+	e, ok := c.entries.Get(best)
+	if !ok {
+		return Result{}, 0, false
+	}
+	return e.result, bestSim, true
+}
+
+// Add caches result, generated for query with the given style under the exact cache key. A
+// regeneration replaces the earlier result.
+func (c *SemanticCache) Add(ctx context.Context, key, query, style string, result Result) error {
+	v, err := c.Embedder.Embed(ctx, query)
+	if err != nil || v == nil {
+		return err
+	}
+	c.entries.Set(key, semanticEntry{style: style, vector: v, result: result})
+	return nil
+}
+
+// Flush removes every entry and returns how many were removed.
+func (c *SemanticCache) Flush() int {
+	return c.entries.Flush()
+}
+
+// Stats returns the cache's size and hit, miss and eviction counts.
+func (c *SemanticCache) Stats() cache.Stats {
+	return c.entries.Stats()
+}
+
+// DeleteFunc removes the entries whose exact cache key del returns true for and returns how
+// many were removed.
+func (c *SemanticCache) DeleteFunc(del func(key string) bool) int {
+	return c.entries.DeleteFunc(func(key string, _ semanticEntry) bool { return del(key) })
+}
+
+// DeleteResultFunc removes the entries, with their query embeddings, whose result del returns
+// true for and returns how many were removed.
+func (c *SemanticCache) DeleteResultFunc(del func(Result) bool) int {
+	return c.entries.DeleteFunc(func(_ string, e semanticEntry) bool { return del(e.result) })
+}
@@ -0,0 +1,38 @@
+package resolver
+
+import (
+	"context"
+
+	"github.com/pageza/recipe-resolver-ms/audit"
+	"github.com/pageza/recipe-resolver-ms/auth"
+	"github.com/pageza/recipe-resolver-ms/generation"
+)
+
+// GenerationTrace is the audit record of a generation (see Resolver.Audit).
+type GenerationTrace struct {
+	Query         string `json:"query"`
+	Model         string `json:"model,omitempty"`
+	PromptVersion string `json:"prompt_version,omitempty"`
+	Prompt        string `json:"prompt"`
+	// Response is the provider's response as received, before parsing.
+	Response string `json:"response"`
+}
+
+// trace records the generation for query described by source in rs.Audit under the trace ID
+// id, on behalf of the principal of ctx.
+func (rs *Resolver) trace(ctx context.Context, id, query string, source generation.Source) {
+	if rs.Audit == nil {
+		return
+	}
+	actor := "anonymous"
+	if p, ok := auth.PrincipalFrom(ctx); ok {
+		actor = p.Name
+	}
+	rs.Audit.Record(audit.NewEntry(actor, "recipe.generate", id, nil, GenerationTrace{
+		Query:         query,
+		Model:         source.Model,
+		PromptVersion: source.PromptVersion,
+		Prompt:        source.Prompt,
+		Response:      string(source.Response),
+	}))
+}
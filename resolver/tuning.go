@@ -0,0 +1,86 @@
+package resolver
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"text/template"
+)
+
+// Tuning overrides settings of a Resolver while it serves queries, e.g. after an operator edits
+// a configuration file (see Tune). Zero fields leave the Resolver's own settings in force;
+// experiment variants still override the tuned ones.
+type Tuning struct {
+	// Threshold replaces Threshold.
+	Threshold float64
+	// Weights replaces the weights of the named Scorers, e.g. {"jaccard": 0.5}.
+	Weights map[string]float64
+	// Prompt replaces generation.PromptTemplate.
+	Prompt *template.Template
+	// Canary, if set, is a new prompt template served instead of the current one to
+	// CanaryPercent percent of the generations outside prompt experiments. Queries are
+	// assigned by a hash of their text, so a query keeps its template and its cached
+	// generation; the outcomes are compared in generation.Templates.
+	Canary        *template.Template
+	CanaryPercent float64
+}
+
+// Tune checks t against rs and, if it is valid, replaces the previous tuning at once, so that
+// each query sees either the old or the new settings in full. A nil t removes the tuning.
+func (rs *Resolver) Tune(t *Tuning) error {
+	if t != nil {
+		if t.Threshold < 0 || t.Threshold > 1 {
+			return fmt.Errorf("threshold %v must be between 0 and 1", t.Threshold)
+		}
+		if err := rs.CheckWeights(t.Weights); err != nil {
+			return err
+		}
+		if t.CanaryPercent < 0 || t.CanaryPercent > 100 {
+			return fmt.Errorf("canary percent %v must be between 0 and 100", t.CanaryPercent)
+		}
+	}
+	rs.tuning.Store(t)
+	return nil
+}
+
+// Tuning returns the tuning in force, or nil.
+func (rs *Resolver) Tuning() *Tuning {
+	return rs.tuning.Load()
+}
+
+// CurrentThreshold returns the similarity threshold in force for queries outside experiments:
+// the tuned one, if any, or Threshold.
+func (rs *Resolver) CurrentThreshold() float64 {
+	return rs.threshold(Query{})
+}
+
+// CheckWeights checks that weights names scorers of rs and that none is negative, e.g. before
+// they are set by a tuning or a tenant.
+func (rs *Resolver) CheckWeights(weights map[string]float64) error {
+	for name, w := range weights {
+		if w < 0 {
+			return fmt.Errorf("weight of scorer %q is negative", name)
+		}
+		if !rs.hasScorer(name) {
+			return fmt.Errorf("unknown scorer %q", name)
+		}
+	}
+	return nil
+}
+
+// hasScorer reports whether rs has a scorer with the given name.
+func (rs *Resolver) hasScorer(name string) bool {
+	for _, s := range rs.Scorers {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// canary reports whether a generation for query falls within percent percent of traffic.
+func canary(query string, percent float64) bool {
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(strings.TrimSpace(query))))
+	return float64(h.Sum32()%10000) < percent*100
+}
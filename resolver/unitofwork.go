@@ -0,0 +1,87 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// Writer is the write side of a recipe store, with the methods of MemoryStore. Add stores what
+// it can and returns why the other recipes could not be stored, wrapping ErrExists for those
+// whose ID is already stored.
+type Writer interface {
+	Add(recipes ...model.Recipe) error
+	Update(r model.Recipe) bool
+	Delete(ids ...string) int
+	Archive(archived bool, ids ...string) int
+}
+
+// Transactor is implemented by recipe stores that apply a unit of work atomically, such as
+// MemoryStore and SQL stores.
+type Transactor interface {
+	// Atomically calls fn with a Writer whose writes are seen by others only once fn returns
+	// nil, all at once; if fn fails they are discarded and its error returned. fn must write
+	// through the Writer only.
+	Atomically(ctx context.Context, fn func(w Writer) error) error
+}
+
+// ErrExists is returned by Writer.Add for a recipe whose ID is already stored.
+var ErrExists = errors.New("a recipe with this ID is already stored")
+
+// OnlyExists reports whether err, returned by Writer.Add, is only about recipes whose ID is
+// already stored, which is no failure when adding recipes that may have been added before.
+func OnlyExists(err error) bool {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			if !OnlyExists(e) {
+				return false
+			}
+		}
+		return true
+	}
+	return errors.Is(err, ErrExists)
+}
+
+// ErrReadOnly is returned by Atomically for a store without write methods.
+var ErrReadOnly = errors.New("recipe store is read-only")
+
+// Atomically runs fn as a unit of work on store, e.g. to add a generated recipe with its
+// alternatives: atomically if store is a Transactor, and otherwise best effort, fn writing to
+// store directly, so that the writes made before a failure are kept. Effects outside the store,
+// such as audit entries, belong after Atomically returns nil: they are not rolled back.
+func Atomically(ctx context.Context, store RecipeStore, fn func(w Writer) error) error {
+	if t, ok := store.(Transactor); ok {
+		return t.Atomically(ctx, fn)
+	}
+	w, ok := store.(Writer)
+	if !ok {
+		return ErrReadOnly
+	}
+	return fn(w)
+}
+
+// Atomically implements Transactor. fn writes to a copy of the store's recipes, which replaces
+// them if it succeeds; other writers wait until it returns, and readers see the recipes as they
+// were until then. OnChange is called once for all the recipes changed.
+func (s *MemoryStore) Atomically(ctx context.Context, fn func(w Writer) error) error {
+	s.mu.Lock()
+	old := s.load()
+	staged := &MemoryStore{}
+	staged.snapshot.Store(&storeSnapshot{recipes: old.recipes})
+	var changed []string
+	staged.OnChange = func(ids []string) { changed = append(changed, ids...) }
+	err := fn(staged)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil && staged.load().version > 0 {
+		s.snapshot.Store(&storeSnapshot{recipes: staged.load().recipes, version: old.version + 1})
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	s.changed(changed)
+	return nil
+}
@@ -0,0 +1,29 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+)
+
+// warmUpQuery is scored against the corpus during WarmUp. Its content is irrelevant.
+const warmUpQuery = "warm up"
+
+// WarmUp prepares rs for its first requests: it loads the corpus from the store, scores it
+// once so that every scorer and the seasonality table have been exercised, has a store that is
+// a Searcher build its index, e.g. MemoryStore's inverted index, and embeds a query
+// with the semantic cache's embedder, which for a model-backed embedder opens the connection to
+// its provider. It returns the number of recipes loaded.
+func (rs *Resolver) WarmUp(ctx context.Context) (int, error) {
+	recipes := rs.Store.All()
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	rs.Rank(warmUpQuery)
+	rs.stored(ctx, Query{Text: warmUpQuery})
+	if rs.Semantic != nil {
+		if _, err := rs.Semantic.Embedder.Embed(ctx, warmUpQuery); err != nil {
+			return len(recipes), fmt.Errorf("warming up the semantic cache embedder: %w", err)
+		}
+	}
+	return len(recipes), ctx.Err()
+}
@@ -0,0 +1,111 @@
+// Package safety checks recipes, generated ones in particular, for unsafe food-handling
+// guidance.
+package safety
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/units"
+)
+
+// Warning codes.
+const (
+	CodePoultryUndercooked = "poultry_undercooked"
+	CodeRawFlour           = "raw_flour"
+	CodeRawEgg             = "raw_egg"
+	CodeUnsafeCanning      = "unsafe_canning"
+)
+
+// Mode selects what happens to recipes that fail the checks.
+type Mode int
+
+const (
+	// Off skips the checks.
+	Off Mode = iota
+	// Warn attaches the warnings to the recipe.
+	Warn
+	// Block rejects the recipe.
+	Block
+)
+
+// ParseMode parses "off", "warn" or "block".
+func ParseMode(s string) (Mode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "off":
+		return Off, nil
+	case "warn":
+		return Warn, nil
+	case "block":
+		return Block, nil
+	}
+	return Off, fmt.Errorf("unknown safety mode %q (want off, warn or block)", s)
+}
+
+// poultrySafeCelsius is the minimum safe internal temperature for poultry (165 °F).
+const poultrySafeCelsius = 74
+
+var (
+	poultryWords = regexp.MustCompile(`(?i)\b(chicken|turkey|duck|goose|poultry|hen)s?\b`)
+	// cookingWords indicate that a recipe applies heat.
+	cookingWords = regexp.MustCompile(`(?i)\b(bak|cook|boil|fry|fried|fries|frying|heat|microwav|roast|simmer|toast|grill|saut|steam|poach|sear|broil|caramel)`)
+	rawFlour     = regexp.MustCompile(`(?i)\bflour\b`)
+	rawEgg       = regexp.MustCompile(`(?i)\b(egg|eggs|egg yolks?|egg whites?)\b`)
+	treated      = regexp.MustCompile(`(?i)\b(heat[- ]treated|pasteuri[sz]ed|toasted)\b`)
+	canningWords = regexp.MustCompile(`(?i)\b(canning|home[- ]can|jars?)\b.*\b(seal|sealed|process|preserve|shelf[- ]stable|store)`)
+	// unsafeCanning lists methods that do not reliably destroy spores or seal jars.
+	unsafeCanning = regexp.MustCompile(`(?i)\b(oven[- ]canning|open[- ]kettle|upside[- ]down|invert(ed|ing)? the jars?|dishwasher|microwave canning)\b`)
+	safeCanning   = regexp.MustCompile(`(?i)\b(water[- ]bath|pressure[- ]can(ner|ning)?)\b`)
+)
+
+// Check returns the food-safety warnings for r. It looks for poultry cooked to a stated
+// temperature below 165 °F, raw flour or eggs in recipes that are never heated, and home
+// canning without a water-bath or pressure-canning step or with a known unsafe method.
+func Check(r model.Recipe) []model.SafetyWarning {
+	var warnings []model.SafetyWarning
+	all := strings.Join(append(append([]string{r.Title}, r.Ingredients...), r.Steps...), "\n")
+
+	if poultryWords.MatchString(r.Title + "\n" + strings.Join(r.Ingredients, "\n")) {
+		for i, step := range r.Steps {
+			for _, c := range units.Temperatures(step) {
+				// Oven and oil temperatures are far above the threshold; only internal
+				// temperatures can fall below it.
+				if c < poultrySafeCelsius {
+					warnings = append(warnings, model.SafetyWarning{
+						Code:    CodePoultryUndercooked,
+						Message: fmt.Sprintf("Poultry should reach an internal temperature of at least 165 °F (74 °C); step gives %.0f °C.", c),
+						Step:    i,
+					})
+				}
+			}
+		}
+	}
+
+	if !cookingWords.MatchString(strings.Join(r.Steps, "\n")) {
+		for _, ing := range r.Ingredients {
+			if treated.MatchString(ing) {
+				continue
+			}
+			if rawFlour.MatchString(ing) {
+				warnings = append(warnings, model.SafetyWarning{Code: CodeRawFlour, Message: "Raw flour can carry E. coli; use heat-treated flour in no-cook recipes.", Step: -1})
+			}
+			if rawEgg.MatchString(ing) {
+				warnings = append(warnings, model.SafetyWarning{Code: CodeRawEgg, Message: "Raw eggs can carry Salmonella; use pasteurized eggs in no-cook recipes.", Step: -1})
+			}
+		}
+	}
+
+	if canningWords.MatchString(all) {
+		for i, step := range r.Steps {
+			if unsafeCanning.MatchString(step) {
+				warnings = append(warnings, model.SafetyWarning{Code: CodeUnsafeCanning, Message: "This canning method does not reliably seal jars or destroy botulism spores; use a water-bath or pressure canner.", Step: i})
+			}
+		}
+		if !safeCanning.MatchString(all) {
+			warnings = append(warnings, model.SafetyWarning{Code: CodeUnsafeCanning, Message: "Home-canned food must be processed in a water-bath or pressure canner to be shelf-stable.", Step: -1})
+		}
+	}
+	return warnings
+}
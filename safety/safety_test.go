@@ -0,0 +1,95 @@
+package safety
+
+import (
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+func codes(ws []model.SafetyWarning) []string {
+	var out []string
+	for _, w := range ws {
+		out = append(out, w.Code)
+	}
+	return out
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		recipe model.Recipe
+		want   []string
+	}{
+		{
+			name: "undercooked chicken",
+			recipe: model.Recipe{
+				Title:       "Roast Chicken",
+				Ingredients: []string{"1 whole chicken"},
+				Steps:       []string{"Roast at 425°F.", "Remove when the thigh reads 150°F."},
+			},
+			want: []string{CodePoultryUndercooked},
+		},
+		{
+			name: "safe chicken",
+			recipe: model.Recipe{
+				Title:       "Roast Chicken",
+				Ingredients: []string{"1 whole chicken"},
+				Steps:       []string{"Roast at 220 °C until the thigh reads 75 °C."},
+			},
+		},
+		{
+			name: "no-bake cookie dough",
+			recipe: model.Recipe{
+				Title:       "Edible Cookie Dough",
+				Ingredients: []string{"1 cup flour", "1 egg yolk", "chocolate chips"},
+				Steps:       []string{"Mix everything and chill."},
+			},
+			want: []string{CodeRawFlour, CodeRawEgg},
+		},
+		{
+			name: "no-bake with treated ingredients",
+			recipe: model.Recipe{
+				Title:       "Edible Cookie Dough",
+				Ingredients: []string{"1 cup heat-treated flour", "1 pasteurized egg yolk"},
+				Steps:       []string{"Mix everything and chill."},
+			},
+		},
+		{
+			name: "baked flour",
+			recipe: model.Recipe{
+				Ingredients: []string{"2 cups flour", "2 eggs"},
+				Steps:       []string{"Bake for 30 minutes."},
+			},
+		},
+		{
+			name: "inversion canning",
+			recipe: model.Recipe{
+				Title:       "Strawberry Jam",
+				Ingredients: []string{"strawberries", "sugar"},
+				Steps:       []string{"Boil the fruit with sugar.", "Fill the jars and turn them upside down to seal."},
+			},
+			want: []string{CodeUnsafeCanning, CodeUnsafeCanning},
+		},
+		{
+			name: "water-bath canning",
+			recipe: model.Recipe{
+				Title:       "Strawberry Jam",
+				Ingredients: []string{"strawberries", "sugar"},
+				Steps:       []string{"Boil the fruit with sugar.", "Fill the jars and process in a water bath for 10 minutes to seal."},
+			},
+		},
+	}
+	for _, tt := range tests {
+		got := codes(Check(tt.recipe))
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/lease"
+)
+
+func TestParse(t *testing.T) {
+	start := time.Date(2025, 3, 14, 10, 17, 30, 0, time.UTC) // a Friday
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"@every 90m", time.Date(2025, 3, 14, 10, 30, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2025, 3, 14, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 3, 14, 10, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2025, 3, 15, 3, 0, 0, 0, time.UTC)},
+		{"30 9 * * 1-5", time.Date(2025, 3, 17, 9, 30, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2025, 3, 16, 12, 0, 0, 0, time.UTC)},
+		// Either restricted day field matches: the 20th, or the next Monday.
+		{"0 0 20 * 1", time.Date(2025, 3, 17, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := s.Next(start); !got.Equal(tt.want) {
+			t.Errorf("Parse(%q).Next = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+
+	for _, spec := range []string{"", "@every soon", "@every -1h", "* * * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "@yearly"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Expected Parse(%q) to fail", spec)
+		}
+	}
+}
+
+// TestScheduler verifies that triggered runs are recorded, including failures and panics, and
+// that a running job cannot be triggered again.
+func TestScheduler(t *testing.T) {
+	s := New()
+	release := make(chan struct{})
+	calls := 0
+	if err := s.Add("flaky", "@daily", func(ctx context.Context) error {
+		calls++
+		switch calls {
+		case 1:
+			return errors.New("upstream unavailable")
+		case 2:
+			panic("boom")
+		}
+		<-release
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("flaky", "@hourly", nil); err == nil {
+		t.Error("Expected a duplicate job name to be rejected")
+	}
+	if err := s.Trigger("missing"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("Expected ErrUnknownJob, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+	waitFor := func(cond func(Status) bool) Status {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+			if st := s.Status()[0]; cond(st) {
+				return st
+			}
+		}
+		t.Fatalf("Timed out; status %+v", s.Status()[0])
+		return Status{}
+	}
+
+	for i := 1; i <= 2; i++ {
+		if err := s.Trigger("flaky"); err != nil {
+			t.Fatal(err)
+		}
+		waitFor(func(st Status) bool { return st.Runs == i && !st.Running })
+	}
+	if st := s.Status()[0]; st.Failures != 2 || st.LastError != "panic: boom" || st.NextRun == nil {
+		t.Errorf("Expected two recorded failures, got %+v", st)
+	}
+
+	if err := s.Trigger("flaky"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(func(st Status) bool { return st.Running })
+	if err := s.Trigger("flaky"); !errors.Is(err, ErrRunning) {
+		t.Errorf("Expected ErrRunning while the job runs, got %v", err)
+	}
+	close(release)
+	if st := waitFor(func(st Status) bool { return st.Runs == 3 && !st.Running }); st.Failures != 2 {
+		t.Errorf("Expected the third run to succeed, got %+v", st)
+	}
+}
+
+// TestSchedulerLeases verifies that replicas sharing a Locker run each scheduled slot once.
+func TestSchedulerLeases(t *testing.T) {
+	locker := lease.NewMemory()
+	var mu sync.Mutex
+	slots := make(map[time.Time]int)
+	replicas := make([]*Scheduler, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := range replicas {
+		s := New()
+		s.Locker = locker
+		if err := s.Add("sync", "@every 40ms", func(ctx context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			slots[time.Now().Truncate(40*time.Millisecond)]++
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		s.Start(ctx)
+		replicas[i] = s
+	}
+	time.Sleep(300 * time.Millisecond)
+	cancel()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(slots) < 3 {
+		t.Fatalf("Expected several slots to run, got %d", len(slots))
+	}
+	for slot, n := range slots {
+		if n != 1 {
+			t.Errorf("Expected the slot at %v to run once, got %d runs", slot, n)
+		}
+	}
+	skipped := 0
+	for _, s := range replicas {
+		skipped += s.Status()[0].Skipped
+	}
+	if skipped == 0 {
+		t.Error("Expected replicas to skip slots taken by others")
+	}
+}
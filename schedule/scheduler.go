@@ -0,0 +1,233 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/lease"
+)
+
+// Errors returned by Scheduler.Trigger.
+var (
+	ErrUnknownJob = errors.New("unknown job")
+	ErrRunning    = errors.New("job is already running")
+)
+
+// Status reports a job's schedule and how its runs have fared.
+type Status struct {
+	Name string `json:"name"`
+	// Schedule is the spec the job was added with, e.g. "@hourly".
+	Schedule string `json:"schedule"`
+	Running  bool   `json:"running"`
+	Runs     int    `json:"runs"`
+	Failures int    `json:"failures"`
+	// Skipped counts scheduled runs left to another replica that held their lease.
+	Skipped int `json:"skipped,omitempty"`
+	// LastError describes the most recent failure, if any.
+	LastError           string     `json:"last_error,omitempty"`
+	LastRun             *time.Time `json:"last_run,omitempty"`
+	LastDurationSeconds float64    `json:"last_duration_seconds,omitempty"`
+	// NextRun is when the job runs next; it is absent until the Scheduler starts.
+	NextRun *time.Time `json:"next_run,omitempty"`
+}
+
+// job is a job added to a Scheduler.
+type job struct {
+	schedule Schedule
+	run      func(ctx context.Context) error
+	trigger  chan struct{}
+	status   Status
+}
+
+// DefaultLeaseTTL is the default lifetime of the lease on a scheduled run.
+const DefaultLeaseTTL = 5 * time.Minute
+
+// Scheduler runs jobs on their schedules, one run of a job at a time. Jobs are added before
+// Start; Status and Trigger are safe for concurrent use.
+type Scheduler struct {
+	Logger *log.Logger
+	// Locker, if non-nil, is shared with the other replicas running the same jobs. A scheduled
+	// run first takes a lease on its job and time, and is skipped if another replica has it, so
+	// that each run happens once across replicas. Triggered runs are not leased.
+	Locker lease.Locker
+	// LeaseTTL is how long the lease on a scheduled run lasts. It is extended while the run
+	// goes on and left to expire after it ends, so that replicas whose clocks lag behind still
+	// find it taken.
+	LeaseTTL time.Duration
+
+	mu   sync.Mutex
+	jobs []*job
+}
+
+// New returns an empty Scheduler logging to the standard logger, without a Locker.
+func New() *Scheduler {
+	return &Scheduler{Logger: log.Default(), LeaseTTL: DefaultLeaseTTL}
+}
+
+// Add adds a job named name that calls run on the schedule spec (see Parse).
+func (s *Scheduler) Add(name, spec string, run func(ctx context.Context) error) error {
+	sched, err := Parse(spec)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", name, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.status.Name == name {
+			return fmt.Errorf("job %s is already scheduled", name)
+		}
+	}
+	s.jobs = append(s.jobs, &job{schedule: sched, run: run, trigger: make(chan struct{}, 1), status: Status{Name: name, Schedule: spec}})
+	return nil
+}
+
+// Start runs every job on its schedule in the background until ctx is done.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		go s.loop(ctx, j)
+	}
+}
+
+// Status returns the status of every job, in the order they were added.
+func (s *Scheduler) Status() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]Status, len(s.jobs))
+	for i, j := range s.jobs {
+		statuses[i] = j.status
+	}
+	return statuses
+}
+
+// Trigger runs the job named name now, outside its schedule. It returns ErrUnknownJob or
+// ErrRunning if there is no such job or it is already running or about to.
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.status.Name != name {
+			continue
+		}
+		if j.status.Running {
+			return ErrRunning
+		}
+		select {
+		case j.trigger <- struct{}{}:
+			return nil
+		default:
+			return ErrRunning
+		}
+	}
+	return ErrUnknownJob
+}
+
+// loop runs j on its schedule, or when triggered, until ctx is done.
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	for {
+		next := j.schedule.Next(time.Now())
+		var wait <-chan time.Time
+		var timer *time.Timer
+		if !next.IsZero() {
+			s.mu.Lock()
+			j.status.NextRun = &next
+			s.mu.Unlock()
+			timer = time.NewTimer(time.Until(next))
+			wait = timer.C
+		}
+		scheduled := false
+		select {
+		case <-ctx.Done():
+		case <-wait:
+			scheduled = true
+		case <-j.trigger:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if scheduled && s.Locker != nil {
+			s.runLeased(ctx, j, next)
+		} else {
+			s.runOnce(ctx, j)
+		}
+	}
+}
+
+// runLeased runs j for its scheduled time slot if it can take the slot's lease, extending the
+// lease while the run goes on.
+func (s *Scheduler) runLeased(ctx context.Context, j *job, slot time.Time) {
+	ttl := s.LeaseTTL
+	if ttl <= 0 {
+		ttl = DefaultLeaseTTL
+	}
+	l, err := s.Locker.Acquire(ctx, j.status.Name+"@"+slot.UTC().Format(time.RFC3339Nano), ttl)
+	if errors.Is(err, lease.ErrHeld) {
+		s.mu.Lock()
+		j.status.Skipped++
+		s.mu.Unlock()
+		return
+	}
+	if err != nil {
+		s.record(j, time.Now(), fmt.Errorf("taking the lease: %w", err))
+		return
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := l.Extend(ctx, ttl); err != nil {
+					s.Logger.Printf("Scheduler: Could not extend the lease of job %s: %v", j.status.Name, err)
+				}
+			}
+		}
+	}()
+	s.runOnce(ctx, j)
+}
+
+// runOnce runs j and records the outcome. A panicking job counts as a failure.
+func (s *Scheduler) runOnce(ctx context.Context, j *job) {
+	start := time.Now()
+	s.mu.Lock()
+	j.status.Running = true
+	j.status.LastRun = &start
+	s.mu.Unlock()
+
+	err := func() (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("panic: %v", p)
+			}
+		}()
+		return j.run(ctx)
+	}()
+
+	s.record(j, start, err)
+}
+
+// record records the outcome of a run of j that started at start.
+func (s *Scheduler) record(j *job, start time.Time, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.status.Running = false
+	j.status.Runs++
+	j.status.LastDurationSeconds = time.Since(start).Seconds()
+	if err != nil {
+		j.status.Failures++
+		j.status.LastError = err.Error()
+		s.Logger.Printf("Scheduler: Job %s failed: %v", j.status.Name, err)
+	}
+}
@@ -0,0 +1,153 @@
+// Package season knows when produce is in season, so that recipes built around seasonal
+// ingredients can be preferred.
+package season
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/nlp"
+)
+
+// Hemisphere selects which half of the year a table's months refer to.
+type Hemisphere int
+
+const (
+	North Hemisphere = iota
+	South
+)
+
+// ParseHemisphere parses "north" or "south".
+func ParseHemisphere(s string) (Hemisphere, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "north", "northern":
+		return North, nil
+	case "south", "southern":
+		return South, nil
+	}
+	return North, fmt.Errorf("unknown hemisphere %q (want north or south)", s)
+}
+
+// Table maps an ingredient name to the months it is in season in the northern hemisphere.
+// Seasons in the southern hemisphere are six months apart.
+type Table map[string][]time.Month
+
+//go:embed seasonality.json
+var defaultTable []byte
+
+// Default returns the bundled table, which reflects temperate Europe and North America.
+func Default() Table {
+	t, err := parse(defaultTable)
+	if err != nil {
+		panic("season: bundled table is invalid: " + err.Error())
+	}
+	return t
+}
+
+// LoadTable reads a table from a JSON file of the form {"asparagus": [4, 5, 6]}.
+func LoadTable(path string) (Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing seasonality table %s: %w", path, err)
+	}
+	return t, nil
+}
+
+func parse(data []byte) (Table, error) {
+	var t Table
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	for name, months := range t {
+		for _, m := range months {
+			if m < time.January || m > time.December {
+				return nil, fmt.Errorf("%s: invalid month %d", name, m)
+			}
+		}
+	}
+	return t, nil
+}
+
+// lookup returns the table entry an ingredient line refers to, e.g. "tomato" for "2 ripe
+// tomatoes, diced". Multi-word entries ("sweet potato") win over shorter ones ("potato").
+func (t Table) lookup(ingredient string) (string, bool) {
+	words := make(map[string]bool)
+	for _, w := range nlp.Tokenize(ingredient) {
+		words[singular(w)] = true
+	}
+	best := ""
+	for name := range t {
+		all := true
+		for _, w := range strings.Fields(name) {
+			if !words[w] {
+				all = false
+				break
+			}
+		}
+		if all && (len(name) > len(best) || len(name) == len(best) && name < best) {
+			best = name
+		}
+	}
+	return best, best != ""
+}
+
+// singular strips common English plural endings: "tomatoes" -> "tomato", "cherries" -> "cherry".
+func singular(w string) string {
+	switch {
+	case strings.HasSuffix(w, "ies") && len(w) > 4:
+		return w[:len(w)-3] + "y"
+	case strings.HasSuffix(w, "oes"):
+		return w[:len(w)-2]
+	case strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") && len(w) > 3:
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+// inSeason reports whether the entry name is in season in month.
+func (t Table) inSeason(name string, month time.Month, h Hemisphere) bool {
+	if h == South {
+		month = (month+5)%12 + 1
+	}
+	for _, m := range t[name] {
+		if m == month {
+			return true
+		}
+	}
+	return false
+}
+
+// Fraction returns the share of a recipe's seasonal ingredients (those listed in the table)
+// that are in season in month. Recipes without seasonal ingredients score 0.
+func (t Table) Fraction(r model.Recipe, month time.Month, h Hemisphere) float64 {
+	listed, in := 0, 0
+	for _, ing := range r.Ingredients {
+		name, ok := t.lookup(ing)
+		if !ok {
+			continue
+		}
+		listed++
+		if t.inSeason(name, month, h) {
+			in++
+		}
+	}
+	if listed == 0 {
+		return 0
+	}
+	return float64(in) / float64(listed)
+}
+
+// Seasonal reports whether a recipe is a seasonal pick in month: it has seasonal ingredients
+// and at least half of them are in season.
+func (t Table) Seasonal(r model.Recipe, month time.Month, h Hemisphere) bool {
+	return t.Fraction(r, month, h) >= 0.5
+}
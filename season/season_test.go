@@ -0,0 +1,45 @@
+package season
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+func TestFraction(t *testing.T) {
+	table := Default()
+	salad := model.Recipe{Ingredients: []string{"4 ripe tomatoes", "1 cucumber", "2 cups kale", "olive oil"}}
+
+	if got := table.Fraction(salad, time.July, North); got != 2.0/3 {
+		t.Errorf("Expected 2 of 3 seasonal ingredients in season in July, got %v", got)
+	}
+	if got := table.Fraction(salad, time.January, South); got != 2.0/3 {
+		t.Errorf("Expected the southern January to match the northern July, got %v", got)
+	}
+	if got := table.Fraction(salad, time.January, North); got != 1.0/3 {
+		t.Errorf("Expected only kale in season in January, got %v", got)
+	}
+	if got := table.Fraction(model.Recipe{Ingredients: []string{"rice", "salt"}}, time.July, North); got != 0 {
+		t.Errorf("Expected 0 for a recipe without seasonal ingredients, got %v", got)
+	}
+	if !table.Seasonal(salad, time.August, North) || table.Seasonal(salad, time.February, North) {
+		t.Error("Expected the salad to be a seasonal pick in August but not in February")
+	}
+}
+
+func TestLookupPrefersLongestEntry(t *testing.T) {
+	table := Table{"potato": {9}, "sweet potato": {10}}
+	if name, _ := table.lookup("2 sweet potatoes, cubed"); name != "sweet potato" {
+		t.Errorf("Expected sweet potato, got %q", name)
+	}
+	if name, _ := table.lookup("fresh cherries"); name != "" {
+		t.Errorf("Expected no entry, got %q", name)
+	}
+}
+
+func TestParseRejectsInvalidMonths(t *testing.T) {
+	if _, err := parse([]byte(`{"kale": [13]}`)); err == nil {
+		t.Error("Expected an error for month 13")
+	}
+}
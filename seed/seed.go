@@ -0,0 +1,100 @@
+// Package seed ships the default recipe corpus: a few hundred public-domain recipes embedded
+// in the binary, so that matching is demonstrable out of the box. Deployments may seed from a
+// directory of JSON fixtures instead (see LoadDir), shipping new seed data without a rebuild.
+package seed
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+//go:embed recipes.json
+var recipesJSON []byte
+
+// Recipes returns a fresh copy of the embedded corpus. Recipe IDs are stable across releases,
+// derived from the recipe title.
+func Recipes() ([]model.Recipe, error) {
+	var recipes []model.Recipe
+	if err := json.Unmarshal(recipesJSON, &recipes); err != nil {
+		return nil, fmt.Errorf("parsing embedded seed corpus: %w", err)
+	}
+	return recipes, nil
+}
+
+// LoadDir reads the recipes of every .json file in dir, each a JSON array of recipes as
+// written by resolver.SaveRecipesFile, in file name order. Every recipe needs a title,
+// ingredients and an ID unique across the files, so that reseeding a store is repeatable.
+func LoadDir(dir string) ([]model.Recipe, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.EqualFold(filepath.Ext(e.Name()), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	var recipes []model.Recipe
+	files := make(map[string]string)
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		loaded, err := resolver.LoadRecipesFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for i, r := range loaded {
+			switch {
+			case r.ID == "":
+				return nil, fmt.Errorf("seed file %s: recipe %d has no ID", path, i)
+			case files[r.ID] != "":
+				return nil, fmt.Errorf("seed file %s: recipe ID %q is already used in %s", path, r.ID, files[r.ID])
+			case strings.TrimSpace(r.Title) == "" || len(r.Ingredients) == 0:
+				return nil, fmt.Errorf("seed file %s: recipe %q needs a title and ingredients", path, r.ID)
+			}
+			files[r.ID] = name
+		}
+		recipes = append(recipes, loaded...)
+	}
+	if len(recipes) == 0 {
+		return nil, fmt.Errorf("seed directory %s holds no recipes", dir)
+	}
+	return recipes, nil
+}
+
+// Store is the subset of a recipe store needed to seed it.
+type Store interface {
+	All() []model.Recipe
+	Add(recipes ...model.Recipe) error
+}
+
+// PopulateIfEmpty adds the recipes of the fixtures in dir (see LoadDir), or the embedded
+// corpus if dir is empty, to store if it holds no recipes yet, as on the first boot of a new
+// deployment. It returns the number of recipes added.
+func PopulateIfEmpty(store Store, dir string) (int, error) {
+	if len(store.All()) > 0 {
+		return 0, nil
+	}
+	load := Recipes
+	if dir != "" {
+		load = func() ([]model.Recipe, error) { return LoadDir(dir) }
+	}
+	recipes, err := load()
+	if err != nil {
+		return 0, err
+	}
+	// Recipes already stored were added by another replica seeding at the same time.
+	if err := store.Add(recipes...); err != nil && !resolver.OnlyExists(err) {
+		return 0, err
+	}
+	return len(recipes), nil
+}
@@ -0,0 +1,94 @@
+package seed
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+// TestRecipes verifies that the embedded corpus is large and well-formed.
+func TestRecipes(t *testing.T) {
+	recipes, err := Recipes()
+	if err != nil {
+		t.Fatalf("Recipes returned error: %v", err)
+	}
+	if len(recipes) < 300 {
+		t.Errorf("Expected at least 300 seed recipes, got %d", len(recipes))
+	}
+
+	ids := make(map[string]bool)
+	titles := make(map[string]bool)
+	for _, r := range recipes {
+		if r.ID == "" || ids[r.ID] {
+			t.Errorf("Recipe %q has an empty or duplicate ID %q", r.Title, r.ID)
+		}
+		ids[r.ID] = true
+		key := strings.ToLower(r.Title)
+		if titles[key] {
+			t.Errorf("Duplicate seed recipe title %q", r.Title)
+		}
+		titles[key] = true
+		if len(r.Ingredients) == 0 || len(r.Steps) == 0 || r.CreatedAt.IsZero() {
+			t.Errorf("Recipe %q is incomplete: %+v", r.Title, r)
+		}
+	}
+}
+
+// TestPopulateIfEmpty verifies that only an empty store is seeded.
+func TestPopulateIfEmpty(t *testing.T) {
+	empty := resolver.NewMemoryStore(nil)
+	n, err := PopulateIfEmpty(empty, "")
+	if err != nil {
+		t.Fatalf("PopulateIfEmpty returned error: %v", err)
+	}
+	if n == 0 || len(empty.All()) != n {
+		t.Errorf("Expected the empty store to receive %d recipes, got %d", n, len(empty.All()))
+	}
+
+	existing := resolver.NewMemoryStore([]model.Recipe{model.NewRecipe("House Special", nil, nil, nil, "", nil)})
+	if n, _ := PopulateIfEmpty(existing, ""); n != 0 || len(existing.All()) != 1 {
+		t.Errorf("Expected a non-empty store to be left untouched, added %d", n)
+	}
+}
+
+// TestLoadDir verifies that fixtures are read in file name order and checked.
+func TestLoadDir(t *testing.T) {
+	write := func(dir, name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dir := t.TempDir()
+	write(dir, "b.json", `[{"id":"soup","title":"Soup","ingredients":["water"],"steps":["Boil."]}]`)
+	write(dir, "a.json", `[{"id":"toast","title":"Toast","ingredients":["bread"],"steps":["Toast."]}]`)
+	write(dir, "README.md", "not a fixture")
+
+	recipes, err := LoadDir(dir)
+	if err != nil || len(recipes) != 2 || recipes[0].ID != "toast" || recipes[1].ID != "soup" {
+		t.Fatalf("LoadDir() = %+v, %v", recipes, err)
+	}
+	store := resolver.NewMemoryStore(nil)
+	if n, err := PopulateIfEmpty(store, dir); err != nil || n != 2 {
+		t.Errorf("PopulateIfEmpty() = %d, %v; want the 2 fixtures", n, err)
+	}
+
+	for name, content := range map[string]string{
+		"duplicate.json": `[{"id":"soup","title":"Other Soup","ingredients":["stock"]}]`,
+		"noid.json":      `[{"title":"Stew","ingredients":["beef"]}]`,
+		"invalid.json":   `{"title":`,
+	} {
+		bad := t.TempDir()
+		write(bad, "b.json", `[{"id":"soup","title":"Soup","ingredients":["water"],"steps":["Boil."]}]`)
+		write(bad, name, content)
+		if _, err := LoadDir(bad); err == nil {
+			t.Errorf("Expected %s to be rejected", name)
+		}
+	}
+	if _, err := LoadDir(t.TempDir()); err == nil {
+		t.Error("Expected an empty directory to be rejected")
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/audit"
+	"github.com/pageza/recipe-resolver-ms/auth"
+)
+
+// audit records an operation performed by the caller of r. before and after are snapshots of
+// the target around the operation; either may be nil.
+func (s *Server) audit(r *http.Request, action, target string, before, after interface{}) {
+	if s.Audit == nil {
+		return
+	}
+	actor := "anonymous"
+	if p, ok := auth.PrincipalFrom(r.Context()); ok {
+		actor = p.Name
+	}
+	s.Audit.Record(audit.NewEntry(actor, action, target, before, after))
+}
+
+// AuditResponse is the JSON response of the /admin/audit endpoint.
+type AuditResponse struct {
+	Entries []audit.Entry `json:"entries"`
+}
+
+// auditHandler handles GET /admin/audit, listing audit entries newest first. The actor,
+// action, target, since (RFC 3339) and limit query parameters filter the result.
+func (s *Server) auditHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	q := r.URL.Query()
+	f := audit.Filter{Actor: q.Get("actor"), Action: q.Get("action"), Target: q.Get("target"), Limit: 100}
+	if v := q.Get("since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid 'since' parameter; expected an RFC 3339 timestamp.")
+			return
+		}
+		f.Since = since
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			writeError(w, http.StatusBadRequest, "Invalid 'limit' parameter; expected a positive integer.")
+			return
+		}
+		f.Limit = limit
+	}
+
+	resp := AuditResponse{Entries: []audit.Entry{}}
+	if s.Audit != nil {
+		resp.Entries = s.Audit.List(f)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := writeStream(w, resp); err != nil {
+		s.Logger.Printf("Error encoding response: %v", err)
+	}
+}
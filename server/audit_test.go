@@ -0,0 +1,48 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/auth"
+)
+
+// TestAuditHandler verifies that admin actions are recorded with the acting principal and can
+// be listed through /admin/audit.
+func TestAuditHandler(t *testing.T) {
+	srv := newTestServer()
+	srv.Auth = auth.APIKeys{"admin-key": {Name: "ops", Role: auth.RoleAdmin}}
+	h := srv.Handler()
+
+	do := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("X-API-Key", "admin-key")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do(http.MethodPost, "/admin/cache/flush"); rr.Code != http.StatusOK {
+		t.Fatalf("Expected HTTP status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	rr := do(http.MethodGet, "/admin/audit?action=cache.flush")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected HTTP status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var resp AuditResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Entries) != 1 || resp.Entries[0].Actor != "ops" || string(resp.Entries[0].After) != `{"flushed":0}` {
+		t.Errorf("Expected one cache.flush entry by ops, got %+v", resp.Entries)
+	}
+
+	for _, bad := range []string{"/admin/audit?since=yesterday", "/admin/audit?limit=0"} {
+		if rr := do(http.MethodGet, bad); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected HTTP status %d, got %d", bad, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
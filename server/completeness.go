@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/pageza/recipe-resolver-ms/completeness"
+)
+
+// defaultCompletenessBelow is the score under which /admin/recipes/completeness reports a
+// recipe unless the request says otherwise.
+const defaultCompletenessBelow = 0.6
+
+// CompletenessResponse is the JSON response of the /admin/recipes/completeness endpoint.
+type CompletenessResponse struct {
+	// Below is the score under which recipes are reported.
+	Below float64 `json:"below"`
+	// Total is the number of recipes scoring under Below, of which Recipes lists the lowest
+	// scoring first, up to the requested limit.
+	Total   int                 `json:"total"`
+	Recipes []CompletenessEntry `json:"recipes"`
+	// Missing counts the recipes of the whole store lacking each aspect of completeness.Aspects.
+	Missing map[string]int `json:"missing"`
+}
+
+// CompletenessEntry is a recipe of a CompletenessResponse.
+type CompletenessEntry struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	Slug  string `json:"slug,omitempty"`
+	completeness.Score
+}
+
+// completenessHandler handles GET /admin/recipes/completeness, reporting the recipes whose
+// completeness score is under the below query parameter (0.6 by default), lowest first, so
+// curators know which to fill in. The limit query parameter caps the recipes listed.
+func (s *Server) completenessHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	resp := CompletenessResponse{Below: defaultCompletenessBelow, Recipes: []CompletenessEntry{}, Missing: map[string]int{}}
+	if v := q.Get("below"); v != "" {
+		below, err := strconv.ParseFloat(v, 64)
+		if err != nil || below <= 0 || below > 1 {
+			writeError(w, http.StatusBadRequest, "Invalid 'below' parameter; expected a number above 0 and up to 1.")
+			return
+		}
+		resp.Below = below
+	}
+	limit := 100
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "Invalid 'limit' parameter; expected a positive integer.")
+			return
+		}
+		limit = n
+	}
+
+	for _, a := range completeness.Aspects {
+		resp.Missing[a] = 0
+	}
+	for _, recipe := range s.Resolver.Store.All() {
+		score := completeness.Of(recipe)
+		for _, a := range score.Missing {
+			resp.Missing[a]++
+		}
+		if score.Value < resp.Below {
+			resp.Recipes = append(resp.Recipes, CompletenessEntry{ID: recipe.ID, Title: recipe.Title, Slug: recipe.Slug, Score: score})
+		}
+	}
+	sort.SliceStable(resp.Recipes, func(i, j int) bool { return resp.Recipes[i].Value < resp.Recipes[j].Value })
+	resp.Total = len(resp.Recipes)
+	if len(resp.Recipes) > limit {
+		resp.Recipes = resp.Recipes[:limit]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
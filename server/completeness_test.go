@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/auth"
+	"github.com/pageza/recipe-resolver-ms/completeness"
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+// TestCompletenessHandler verifies that /admin/recipes/completeness lists the recipes under the
+// threshold, least complete first, and counts the gaps of the whole store.
+func TestCompletenessHandler(t *testing.T) {
+	srv := newTestServer()
+	soup := model.NewRecipe("Tomato Soup", []string{"800 g tomatoes", "1 onion"}, []string{"Simmer."}, map[string]int{"calories": 180, "protein": 4, "carbohydrates": 22, "fat": 8}, "", nil)
+	soup.TotalTime, soup.Tags = 40, []string{"vegan"}
+	srv.Resolver.Store.(*resolver.MemoryStore).Add(soup)
+	handler := srv.Handler()
+
+	get := func(target string) (*httptest.ResponseRecorder, CompletenessResponse) {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		var resp CompletenessResponse
+		json.NewDecoder(rr.Body).Decode(&resp)
+		return rr, resp
+	}
+	rr, resp := get("/admin/recipes/completeness")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected HTTP status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if resp.Below != 0.6 || resp.Total != 2 || len(resp.Recipes) != 2 {
+		t.Fatalf("Expected the two sample recipes, got %+v", resp)
+	}
+	if resp.Missing[completeness.Image] != 3 || resp.Missing[completeness.Tags] != 2 || resp.Missing[completeness.Time] != 2 {
+		t.Errorf("Unexpected gaps %v", resp.Missing)
+	}
+
+	rr, resp = get("/admin/recipes/completeness?below=1&limit=1")
+	if rr.Code != http.StatusOK || resp.Total != 3 || len(resp.Recipes) != 1 || resp.Recipes[0].Value != 0 {
+		t.Errorf("Expected the least complete of three recipes, got %+v", resp)
+	}
+	rr, resp = get("/admin/recipes/completeness?below=0.9")
+	if last := resp.Recipes[len(resp.Recipes)-1]; last.ID != soup.ID || last.Value != 0.8 || len(last.Missing) != 1 || last.Missing[0] != completeness.Image {
+		t.Errorf("Expected the soup last, missing only an image, got %+v", last)
+	}
+	for _, target := range []string{"/admin/recipes/completeness?below=0", "/admin/recipes/completeness?limit=none"} {
+		if rr, _ := get(target); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected HTTP status %d, got %d", target, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+// TestResolveHandlerMinCompleteness verifies that only trusted callers may require a minimum
+// completeness, which leaves sparse stored recipes out of their matches.
+func TestResolveHandlerMinCompleteness(t *testing.T) {
+	srv := newTestServer()
+	srv.Auth = auth.APIKeys{
+		"reader-key":  {Name: "app", Role: auth.RoleReader},
+		"premium-key": {Name: "premium-app", Role: auth.RoleReader},
+	}
+	srv.TrustedPrincipals = map[string]bool{"premium-app": true}
+	tests := []struct {
+		key, body string
+		want      int
+		// stored is whether the stored recipe, rather than a placeholder, is expected.
+		stored bool
+	}{
+		{"reader-key", `{"query":"Chicken Salad","min_completeness":0.5}`, http.StatusForbidden, false},
+		{"premium-key", `{"query":"Chicken Salad","min_completeness":1.5}`, http.StatusBadRequest, false},
+		{"premium-key", `{"query":"Chicken Salad"}`, http.StatusOK, true},
+		{"premium-key", `{"query":"Chicken Salad","min_completeness":0.5}`, http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(tt.body))
+		req.Header.Set("Authorization", "Bearer "+tt.key)
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s %s: expected HTTP status %d, got %d", tt.key, tt.body, tt.want, rr.Code)
+			continue
+		}
+		var resp ResolveResponse
+		json.NewDecoder(rr.Body).Decode(&resp)
+		if stored := resp.PrimaryRecipe.ProvenanceOrCurated().Origin == model.OriginCurated; rr.Code == http.StatusOK && stored != tt.stored {
+			t.Errorf("%s %s: expected stored %v, got %+v", tt.key, tt.body, tt.stored, resp.PrimaryRecipe)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// DegradedRequest is the JSON payload of POST /admin/degraded.
+type DegradedRequest struct {
+	Degraded bool `json:"degraded"`
+}
+
+// degradedHandler handles /admin/degraded. GET reports whether the resolver is in degraded mode;
+// POST switches manual degraded mode on or off and reports the new state.
+func (s *Server) degradedHandler(w http.ResponseWriter, r *http.Request) {
+	d := s.Resolver.Degradation
+	if d == nil {
+		writeError(w, http.StatusNotImplemented, "Degraded mode is not available")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var req DegradedRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request. Expected {\"degraded\": true|false}.")
+			return
+		}
+		before := d.Status()
+		d.Set(req.Degraded)
+		s.Logger.Printf("Admin: Set degraded mode to %t", req.Degraded)
+		s.audit(r, "degraded.set", "", before, d.Status())
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d.Status())
+}
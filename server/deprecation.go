@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/auth"
+)
+
+// Deprecation marks an endpoint as deprecated, e.g. a /v1 endpoint superseded by its /v2
+// counterpart.
+type Deprecation struct {
+	// Since is when the endpoint was deprecated, sent in the Deprecation header (RFC 9745).
+	Since time.Time `json:"since"`
+	// Sunset is when the endpoint is expected to stop answering, sent in the Sunset header
+	// (RFC 8594). It may be zero if no date has been set yet.
+	Sunset time.Time `json:"sunset,omitzero"`
+}
+
+// ParseDeprecations parses comma-separated path=since[/sunset] pairs with dates in YYYY-MM-DD
+// form, e.g. "/resolve=2026-11-01/2027-05-01,/stats=2026-10-01", into a Deprecation per route
+// path.
+func ParseDeprecations(s string) (map[string]Deprecation, error) {
+	deprecations := make(map[string]Deprecation)
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		path, dates, ok := strings.Cut(pair, "=")
+		since, sunset, hasSunset := strings.Cut(strings.TrimSpace(dates), "/")
+		var d Deprecation
+		var err error
+		if path = strings.TrimSpace(path); !ok || !strings.HasPrefix(path, "/") {
+			err = fmt.Errorf("missing path")
+		} else if d.Since, err = time.Parse(time.DateOnly, since); err == nil && hasSunset {
+			if d.Sunset, err = time.Parse(time.DateOnly, sunset); err == nil && !d.Sunset.After(d.Since) {
+				err = fmt.Errorf("sunset before deprecation")
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid deprecation %q (want path=YYYY-MM-DD[/YYYY-MM-DD]): %v", pair, err)
+		}
+		deprecations[path] = d
+	}
+	return deprecations, nil
+}
+
+// deprecationCounter counts the calls to deprecated endpoints by route path and principal.
+// The zero value is ready to use.
+type deprecationCounter struct {
+	mu    sync.Mutex
+	calls map[string]map[string]int64
+}
+
+func (c *deprecationCounter) add(path, principal string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.calls == nil {
+		c.calls = make(map[string]map[string]int64)
+	}
+	if c.calls[path] == nil {
+		c.calls[path] = make(map[string]int64)
+	}
+	c.calls[path][principal]++
+}
+
+// snapshot returns a copy of the calls to path by principal.
+func (c *deprecationCounter) snapshot(path string) map[string]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]int64, len(c.calls[path]))
+	for p, n := range c.calls[path] {
+		out[p] = n
+	}
+	return out
+}
+
+// deprecated wraps h so that, on the routes in s.Deprecations, responses carry the Deprecation
+// and Sunset headers and each call is counted against the caller's principal, or "anonymous"
+// without authentication. It must run after authentication, as require arranges.
+func (s *Server) deprecated(h http.HandlerFunc) http.HandlerFunc {
+	if len(s.Deprecations) == 0 {
+		return h
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := r.Pattern
+		if _, p, ok := strings.Cut(path, " "); ok {
+			path = p
+		}
+		if d, ok := s.Deprecations[path]; ok {
+			w.Header().Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+			if !d.Sunset.IsZero() {
+				w.Header().Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+			}
+			principal := "anonymous"
+			if p, ok := auth.PrincipalFrom(r.Context()); ok {
+				principal = p.Name
+			}
+			s.deprecatedCalls.add(path, principal)
+		}
+		h(w, r)
+	}
+}
+
+// DeprecatedEndpoint reports the use of a deprecated endpoint.
+type DeprecatedEndpoint struct {
+	Path string `json:"path"`
+	Deprecation
+	// Calls counts the calls since the service started by principal, e.g. the name of an API
+	// key, or "anonymous".
+	Calls map[string]int64 `json:"calls"`
+}
+
+// DeprecationMetricsResponse is the JSON response of the /admin/metrics/deprecations endpoint.
+type DeprecationMetricsResponse struct {
+	Endpoints []DeprecatedEndpoint `json:"endpoints"`
+}
+
+// deprecationMetricsHandler handles GET /admin/metrics/deprecations, reporting who still calls
+// each deprecated endpoint, by path.
+func (s *Server) deprecationMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	resp := DeprecationMetricsResponse{Endpoints: []DeprecatedEndpoint{}}
+	for path, d := range s.Deprecations {
+		resp.Endpoints = append(resp.Endpoints, DeprecatedEndpoint{Path: path, Deprecation: d, Calls: s.deprecatedCalls.snapshot(path)})
+	}
+	sort.Slice(resp.Endpoints, func(i, j int) bool { return resp.Endpoints[i].Path < resp.Endpoints[j].Path })
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
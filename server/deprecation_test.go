@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/auth"
+)
+
+func TestParseDeprecations(t *testing.T) {
+	got, err := ParseDeprecations("/resolve=2026-11-01/2027-05-01, /stats=2026-10-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := got["/resolve"]; d.Since.Format(time.DateOnly) != "2026-11-01" || d.Sunset.Format(time.DateOnly) != "2027-05-01" {
+		t.Errorf("Unexpected /resolve deprecation %+v", d)
+	}
+	if d := got["/stats"]; d.Since.Format(time.DateOnly) != "2026-10-01" || !d.Sunset.IsZero() {
+		t.Errorf("Unexpected /stats deprecation %+v", d)
+	}
+	for _, bad := range []string{"/resolve", "resolve=2026-11-01", "/resolve=next year", "/resolve=2026-11-01/2026-10-01"} {
+		if _, err := ParseDeprecations(bad); err == nil {
+			t.Errorf("ParseDeprecations(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestDeprecatedEndpoints(t *testing.T) {
+	srv := newTestServer()
+	srv.Auth = auth.APIKeys{
+		"old-key":   {Name: "legacy-app", Role: auth.RoleReader},
+		"admin-key": {Name: "ops", Role: auth.RoleAdmin},
+	}
+	srv.Deprecations, _ = ParseDeprecations("/recipes/slug/{slug}=2026-10-01/2027-04-01")
+	h := srv.Handler()
+	get := func(path, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-API-Key", key)
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for range 2 {
+		rr := get("/recipes/slug/chicken-salad", "old-key")
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected the deprecated endpoint to keep answering, got %d", rr.Code)
+		}
+		if got := rr.Header().Get("Deprecation"); got != "@1790812800" {
+			t.Errorf("Unexpected Deprecation header %q", got)
+		}
+		if got := rr.Header().Get("Sunset"); got != "Thu, 01 Apr 2027 00:00:00 GMT" {
+			t.Errorf("Unexpected Sunset header %q", got)
+		}
+	}
+	if rr := get("/recipes", "old-key"); rr.Header().Get("Deprecation") != "" {
+		t.Errorf("Expected no Deprecation header on /recipes, got %q", rr.Header().Get("Deprecation"))
+	}
+
+	rr := get("/admin/metrics/deprecations", "admin-key")
+	var resp DeprecationMetricsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Endpoints) != 1 || resp.Endpoints[0].Path != "/recipes/slug/{slug}" || resp.Endpoints[0].Calls["legacy-app"] != 2 {
+		t.Errorf("Expected 2 calls from legacy-app, got %+v", resp.Endpoints)
+	}
+}
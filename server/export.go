@@ -0,0 +1,107 @@
+package server
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// exportColumns are the columns of a CSV export, in order. List and nutrition columns hold
+// JSON, so that the export keeps ingredients and steps containing commas intact.
+var exportColumns = []string{
+	"id", "title", "slug", "ingredients", "steps", "nutritional_info", "allergy_disclaimer",
+	"appliances", "difficulty", "total_time", "tags", "image_url", "status", "archived",
+	"origin", "source", "author", "source_url", "license", "created_at", "updated_at",
+}
+
+// exportHandler handles GET /recipes/export, writing every stored recipe, archived and
+// unpublished ones included, for backups and analytics pipelines. The format query parameter
+// selects newline-delimited JSON ("ndjson", the default), a recipe per line as GET
+// /recipes/{id} returns it, or CSV with a header row (see exportColumns). Recipes are encoded
+// one at a time and the response is flushed every streamFlushEvery recipes, so a large corpus
+// is not buffered whole.
+func (s *Server) exportHandler(w http.ResponseWriter, r *http.Request) {
+	bw := bufio.NewWriter(w)
+	var write func(model.Recipe) error
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", "ndjson":
+		format = "ndjson"
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(bw)
+		write = func(rec model.Recipe) error {
+			// Encode ends each recipe with a newline.
+			return enc.Encode(rec)
+		}
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		cw := csv.NewWriter(bw)
+		cw.Write(exportColumns)
+		cw.Flush()
+		write = func(rec model.Recipe) error {
+			cw.Write(exportRow(rec))
+			cw.Flush()
+			return cw.Error()
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid 'format' parameter; expected 'ndjson' or 'csv'.")
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="recipes.`+format+`"`)
+
+	flusher, _ := w.(http.Flusher)
+	for i, rec := range s.Resolver.Store.All() {
+		if err := write(rec); err != nil {
+			s.Logger.Printf("Error exporting recipes: %v", err)
+			return
+		}
+		if (i+1)%streamFlushEvery == 0 {
+			if err := bw.Flush(); err != nil {
+				s.Logger.Printf("Error exporting recipes: %v", err)
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		s.Logger.Printf("Error exporting recipes: %v", err)
+	}
+}
+
+// exportRow returns the CSV columns of rec.
+func exportRow(rec model.Recipe) []string {
+	list := func(v interface{}) string {
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+	var origin, source string
+	if p := rec.Provenance; p != nil {
+		origin, source = string(p.Origin), p.Source
+	}
+	var a model.Attribution
+	if rec.Attribution != nil {
+		a = *rec.Attribution
+	}
+	return []string{
+		rec.ID, rec.Title, rec.Slug, list(nonNilStrings(rec.Ingredients)), list(nonNilStrings(rec.Steps)),
+		list(rec.NutritionalInfo), rec.AllergyDisclaimer, list(nonNilStrings(rec.Appliances)),
+		string(rec.Difficulty), strconv.Itoa(rec.TotalTime), list(nonNilStrings(rec.Tags)), rec.ImageURL,
+		string(rec.Status), strconv.FormatBool(rec.Archived), origin, source, a.Author, a.SourceURL,
+		a.License, rec.CreatedAt.Format(time.RFC3339), rec.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// nonNilStrings returns list, or an empty list for nil so that it encodes as [].
+func nonNilStrings(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+// atomFeed and atomEntry are the subset of the Atom syndication format (RFC 4287) used by the
+// generated-recipes feed.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Summary string   `xml:"summary"`
+	Content atomText `xml:"content"`
+}
+
+// generatedFeedHandler handles GET /feeds/generated.atom, listing recently generated recipes
+// newest first so that they can be reviewed in a feed reader.
+func (s *Server) generatedFeedHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var generations []resolver.Generation
+	if s.Resolver.Recent != nil {
+		generations = s.Resolver.Recent.List()
+	}
+
+	feed := atomFeed{
+		ID:     "urn:recipe-resolver:feeds:generated",
+		Title:  "Recently generated recipes",
+		Author: atomPerson{Name: "recipe-resolver"},
+		Link:   atomLink{Rel: "self", Href: "/feeds/generated.atom"},
+	}
+	// An empty feed still needs an updated timestamp; the Unix epoch keeps it stable.
+	feed.Updated = time.Unix(0, 0).UTC().Format(time.RFC3339)
+	if len(generations) > 0 {
+		feed.Updated = generations[0].GeneratedAt.Format(time.RFC3339)
+	}
+	for _, g := range generations {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      "urn:recipe-resolver:recipes:" + g.Recipe.ID,
+			Title:   g.Recipe.Title,
+			Updated: g.GeneratedAt.Format(time.RFC3339),
+			Summary: fmt.Sprintf("Generated for %q: %d ingredients, %d steps.", g.Query, len(g.Recipe.Ingredients), len(g.Recipe.Steps)),
+			Content: atomText{Type: "text", Body: recipeText(g)},
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(feed); err != nil {
+		s.Logger.Printf("Error encoding feed: %v", err)
+	}
+}
+
+// recipeText renders a generated recipe as plain text for a feed entry.
+func recipeText(g resolver.Generation) string {
+	var b strings.Builder
+	b.WriteString("Ingredients:\n")
+	for _, ing := range g.Recipe.Ingredients {
+		b.WriteString("- " + ing + "\n")
+	}
+	b.WriteString("\nSteps:\n")
+	for i, step := range g.Recipe.Steps {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, step)
+	}
+	if g.Recipe.AllergyDisclaimer != "" {
+		b.WriteString("\nAllergy disclaimer: " + g.Recipe.AllergyDisclaimer + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/generation"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+type fixedGenerator struct{}
+
+func (fixedGenerator) Generate(ctx context.Context, query string) (generation.Recipe, []generation.Recipe, error) {
+	return generation.Recipe{
+		ID:          "gen-" + strings.ReplaceAll(query, " ", "-"),
+		Title:       strings.ToUpper(query[:1]) + query[1:],
+		Ingredients: []string{"tofu", "soy sauce"},
+		Steps:       []string{"Press tofu", "Fry"},
+	}, nil, nil
+}
+
+// TestGeneratedFeedHandler verifies that generated recipes appear in the Atom feed, newest first.
+func TestGeneratedFeedHandler(t *testing.T) {
+	r := resolver.New(resolver.NewMemoryStore(resolver.SampleRecipes()), fixedGenerator{})
+	r.Logger = log.New(io.Discard, "", 0)
+	srv := New(r)
+	for _, q := range []string{"crispy tofu", "mapo tofu"} {
+		if _, err := r.Resolve(context.Background(), resolver.Query{Text: q}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/feeds/generated.atom", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected HTTP status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/atom+xml") {
+		t.Errorf("Expected an Atom content type, got %q", ct)
+	}
+
+	var feed atomFeed
+	if err := xml.Unmarshal(rr.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("Failed to parse feed: %v", err)
+	}
+	if len(feed.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(feed.Entries))
+	}
+	if feed.Entries[0].Title != "Mapo tofu" || feed.Entries[0].ID != "urn:recipe-resolver:recipes:gen-mapo-tofu" {
+		t.Errorf("Expected the newest generation first, got %+v", feed.Entries[0])
+	}
+	if !strings.Contains(feed.Entries[0].Summary, `"mapo tofu"`) || !strings.Contains(feed.Entries[0].Content.Body, "2. Fry") {
+		t.Errorf("Expected the entry to describe the recipe, got %+v", feed.Entries[0])
+	}
+}
+
+func TestGeneratedFeedHandlerRejectsPost(t *testing.T) {
+	rr := httptest.NewRecorder()
+	newTestServer().Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/feeds/generated.atom", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected HTTP status %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+}
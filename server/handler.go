@@ -0,0 +1,663 @@
+// Package server exposes the resolution pipeline over HTTP.
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pageza/recipe-resolver-ms/audit"
+	"github.com/pageza/recipe-resolver-ms/auth"
+	"github.com/pageza/recipe-resolver-ms/experiment"
+	"github.com/pageza/recipe-resolver-ms/generation"
+	"github.com/pageza/recipe-resolver-ms/glossary"
+	"github.com/pageza/recipe-resolver-ms/intent"
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/nutrition"
+	"github.com/pageza/recipe-resolver-ms/qos"
+	"github.com/pageza/recipe-resolver-ms/renderer"
+	"github.com/pageza/recipe-resolver-ms/report"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+	"github.com/pageza/recipe-resolver-ms/schedule"
+	"github.com/pageza/recipe-resolver-ms/shopping"
+	"github.com/pageza/recipe-resolver-ms/taxonomy"
+	"github.com/pageza/recipe-resolver-ms/tenant"
+	"github.com/pageza/recipe-resolver-ms/tuning"
+	"github.com/pageza/recipe-resolver-ms/voice"
+)
+
+// Server serves the HTTP API in front of a resolver.Resolver.
+type Server struct {
+	Resolver *resolver.Resolver
+	Logger   *log.Logger
+	// Auth, if non-nil, must authenticate every request, and each endpoint requires a minimum
+	// role of the authenticated principal. If nil, every endpoint is open.
+	Auth auth.Authenticator
+	// Audit records write operations and admin actions. It may be nil.
+	Audit audit.Log
+	// Glossary explains technique terms in steps when a caller asks for annotations.
+	Glossary *glossary.Glossary
+	// Taxonomy answers ingredient lookups: ancestors, allergens and substitutes.
+	Taxonomy *taxonomy.Taxonomy
+	// Nutrition backfills structured nutrition on demand. It may be nil.
+	Nutrition *nutrition.Backfill
+	// Experiments, if non-nil, assigns each /resolve request to experiment variants by its
+	// X-Session-ID header or, failing that, its authenticated principal.
+	Experiments *experiment.Config
+	// Tenants, if non-nil, applies the prompt template and ranking overrides of a tenant to the
+	// resolve requests of its principals.
+	Tenants *tenant.Config
+	// Limiter, if non-nil, bounds the number of /resolve requests in progress, favoring
+	// interactive requests over batch ones.
+	Limiter *qos.Limiter
+	// Concurrency, if non-nil, caps the requests in progress per endpoint, keyed by route path
+	// such as "/resolve" or "/recipes/{id}/review". Requests beyond an endpoint's cap are
+	// rejected at once with 503, whether or not Limiter would queue them.
+	Concurrency map[string]*qos.Gate
+	// SessionBudget, if non-nil, bounds the provider calls and tokens each session, named by
+	// the X-Session-ID header, may spend on /resolve generations. Requests of a session that
+	// has run out are rejected with 429 until its budget refills.
+	SessionBudget *qos.SessionBudget
+	// Deprecations marks endpoints as deprecated, keyed by route path like Concurrency.
+	// Their responses carry the Deprecation and Sunset headers, and their callers are counted
+	// by principal for GET /admin/metrics/deprecations, so that an endpoint can be turned off
+	// once nobody uses it.
+	Deprecations map[string]Deprecation
+	// Renderers, if non-nil, replaces renderer.Default as the output formats of the endpoints
+	// returning recipes, chosen by their format query parameter or Accept header.
+	Renderers *renderer.Registry
+	// LoadTuning, if non-nil, reads the settings applied by ReloadTuning, e.g. on POST
+	// /admin/reload.
+	LoadTuning func() (*tuning.Config, error)
+	// BatchPrincipals names the principals whose requests always count as batch, e.g. the
+	// importer's API key.
+	BatchPrincipals map[string]bool
+	// TrustedPrincipals names the principals that may choose the provider and model of their
+	// /resolve requests, among the selectable routes of generation.Routing, and require a
+	// minimum recipe completeness, e.g. the API key of the premium tier.
+	TrustedPrincipals map[string]bool
+	// Reports holds user reports of bad recipes. It may be nil, in which case reporting is
+	// unavailable.
+	Reports *report.Log
+	// ReportThreshold is the number of open reports after which a published recipe is
+	// unpublished and sent back to the review queue; 0 disables unpublishing.
+	ReportThreshold int
+	// Jobs runs the service's recurring tasks. It may be nil, in which case the jobs endpoints
+	// answer 501.
+	Jobs *schedule.Scheduler
+	// DescribeImage names the dish in a food photo for POST /resolve/image. It may be nil, in
+	// which case the endpoint answers 501.
+	DescribeImage func(ctx context.Context, img generation.Image) (string, error)
+	// ExtractRecipe transcribes a photographed recipe for POST /recipes/import-image. It may be
+	// nil, in which case the endpoint answers 501.
+	ExtractRecipe func(ctx context.Context, img generation.Image) (generation.Recipe, error)
+
+	ready           atomic.Bool // set by WarmUp
+	deprecatedCalls deprecationCounter
+}
+
+// New returns a Server for the given resolver, logging through the resolver's logger,
+// auditing to an in-memory log, which also records the resolver's generations unless it has
+// its own, collecting reports with report.DefaultThreshold and annotating with the bundled
+// glossary. Ingredient lookups use the resolver's taxonomy, or the bundled one.
+// If the resolver's store supports updates, nutrition backfills estimate with the LLM provider.
+// Photos are described and transcribed with the LLM provider too.
+func New(r *resolver.Resolver) *Server {
+	s := &Server{Resolver: r, Logger: r.Logger, Audit: audit.NewMemoryLog(0), Glossary: glossary.Default()}
+	if r.Audit == nil {
+		r.Audit = s.Audit
+	}
+	s.DescribeImage, s.ExtractRecipe = generation.DescribeImage, generation.ExtractRecipe
+	s.Reports, s.ReportThreshold = report.NewLog(), report.DefaultThreshold
+	if s.Taxonomy = r.Taxonomy; s.Taxonomy == nil {
+		s.Taxonomy = taxonomy.Default()
+	}
+	if store, ok := r.Store.(nutrition.Store); ok {
+		s.Nutrition = nutrition.NewBackfill(store, nutrition.LLMEstimator)
+		s.Nutrition.Logger = r.Logger
+	}
+	return s
+}
+
+// maxFusion is the most cuisines a request may fuse.
+const maxFusion = 3
+
+// maxCount is the most recipes a request may ask for at once.
+const maxCount = 5
+
+// ResolveRequest defines the structure for the incoming JSON payload.
+// It represents the user's recipe query.
+type ResolveRequest struct {
+	Query string `json:"query"`
+	// RecipeID, which may replace Query, refreshes a recipe from an earlier response: matching
+	// is skipped and the stored recipe is returned with similar stored recipes as alternatives.
+	// The other fields constrain the alternatives.
+	RecipeID string `json:"recipe_id,omitempty"`
+	// Locale optionally overrides the Accept-Language header, e.g. "de-DE".
+	Locale string `json:"locale,omitempty"`
+	// MaxDifficulty optionally excludes recipes harder than "easy", "medium" or "hard".
+	MaxDifficulty string `json:"max_difficulty,omitempty"`
+	// Seasonal restricts matching to seasonal picks: recipes whose key ingredients are in
+	// season now.
+	Seasonal bool `json:"seasonal,omitempty"`
+	// SkillLevel optionally writes generated recipes for a "beginner" or "advanced" cook;
+	// "beginner" also limits stored matches to easy recipes.
+	SkillLevel string `json:"skill_level,omitempty"`
+	// KidFriendly asks for recipes suitable for children: stored matches must be tagged
+	// "kid-friendly" and generated ones avoid spice, alcohol and knife-heavy prep.
+	KidFriendly bool `json:"kid_friendly,omitempty"`
+	// Exclude lists ingredients to leave out, e.g. ["dairy", "pine nuts"]. Ingredient groups
+	// exclude everything under them in the ingredient taxonomy: "dairy" excludes butter, cream
+	// and pecorino.
+	Exclude []string `json:"exclude,omitempty"`
+	// Fusion asks for a dish blending two or three cuisines, e.g. ["korean", "mexican"].
+	Fusion []string `json:"fusion,omitempty"`
+	// MaxTotalTime limits preparation and cooking time, in minutes. A limit in the query
+	// itself, e.g. "ready in 20 minutes", also applies; the shorter one wins.
+	MaxTotalTime int `json:"max_total_time,omitempty"`
+	// Count asks for that many distinct recipes, up to five: the best as the primary recipe and
+	// the others as alternatives. A count in the query itself, e.g. "3 dinner ideas with
+	// salmon", applies when the request sets none.
+	Count int `json:"count,omitempty"`
+	// Diversity, from 0 to 1, favors alternatives that differ from the primary recipe and each
+	// other over those closest to the query, dropping near-identical variants. The default 0
+	// returns alternatives as found.
+	Diversity float64 `json:"diversity,omitempty"`
+	// Tone ("concise" or "chatty"), Measurement ("metric" or "us") and Verbosity ("brief" or
+	// "detailed") optionally override the deployment's style for generated recipes.
+	Tone        string `json:"tone,omitempty"`
+	Measurement string `json:"measurement,omitempty"`
+	Verbosity   string `json:"verbosity,omitempty"`
+	// Annotations requests explanations of the cooking techniques mentioned in steps.
+	Annotations bool `json:"annotations,omitempty"`
+	// Pantry lists the ingredients the caller has, e.g. ["eggs", "flour"], asking for how much
+	// of each recipe they cover.
+	Pantry []string `json:"pantry,omitempty"`
+	// Source tells how the query was entered: "text" (the default) or "voice" for a speech
+	// transcript, which is cleaned up first (see voice.Normalize).
+	Source string `json:"source,omitempty"`
+	// Provider and Model, which only TrustedPrincipals may set, force generation onto the
+	// first selectable model route matching them, e.g. a premium model for paying users (see
+	// generation.Router.Override). Either may be left empty to match any.
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	// MinCompleteness, from 0 to 1, which only TrustedPrincipals may set, leaves out stored
+	// recipes scoring lower for completeness (see completeness.Of), e.g. those without
+	// nutrition or an image.
+	MinCompleteness float64 `json:"min_completeness,omitempty"`
+}
+
+// ResolveResponse defines the structure for the JSON response.
+// It includes the primary matching recipe and any alternative suggestions.
+type ResolveResponse struct {
+	PrimaryRecipe      model.Recipe   `json:"primary_recipe"`
+	AlternativeRecipes []model.Recipe `json:"alternative_recipes"`
+	// Annotations holds the glossary terms found in each recipe's steps, keyed by recipe ID.
+	// It is only present when the request asked for annotations.
+	Annotations map[string][]glossary.Annotation `json:"annotations,omitempty"`
+	// Pantry holds, keyed by recipe ID, the pantry items each recipe uses, the ingredients it
+	// still needs and the share of its ingredients covered (see shopping.PantryCoverage). It is
+	// only present when the request listed a pantry.
+	Pantry map[string]shopping.Coverage `json:"pantry,omitempty"`
+	// SemanticCache is present when the recipes were generated for an earlier query that is
+	// near-identical to this one, rather than for this query.
+	SemanticCache *SemanticCacheHit `json:"semantic_cache,omitempty"`
+	// Experiments lists the experiment variants the request was assigned to, as
+	// "experiment=variant". They are also sent in the X-Experiments header.
+	Experiments []string `json:"experiments,omitempty"`
+	// Degraded is set when the service was in degraded mode: no recipe was generated and the
+	// primary recipe is the best stored match, however weak.
+	Degraded bool `json:"degraded,omitempty"`
+	// Nutrition lists the nutrition constraints read from the query, e.g. "calories<=500".
+	Nutrition []string `json:"nutrition,omitempty"`
+	// NutritionUnmet lists those the generated primary recipe still misses, when no generated
+	// recipe met them all.
+	NutritionUnmet []string `json:"nutrition_unmet,omitempty"`
+	// MaxTotalTime is the time limit applied, in minutes, from the request or its query.
+	MaxTotalTime int `json:"max_total_time,omitempty"`
+	// OverTime is set when the generated primary recipe exceeds MaxTotalTime, or does not give
+	// its total time, because no generated recipe was within it.
+	OverTime bool `json:"over_time,omitempty"`
+	// Count is the number of recipes asked for, from the request or its query, when above one.
+	// Fewer may be returned if the provider repeated itself.
+	Count int `json:"count,omitempty"`
+	// Description is the dish recognized in the photo of a POST /resolve/image request, which
+	// was resolved as the query.
+	Description string `json:"description,omitempty"`
+	// NormalizedQuery is the query as resolved after cleaning up a voice transcript, when it
+	// differs from the transcript.
+	NormalizedQuery string `json:"normalized_query,omitempty"`
+	// Budget is what remains of the session's generation budget after this request, when the
+	// request named a session and sessions are budgeted. It is also sent in the
+	// X-Budget-Remaining-Calls and X-Budget-Remaining-Tokens headers.
+	Budget *qos.Remaining `json:"budget,omitempty"`
+}
+
+// SemanticCacheHit describes a response served from the semantic cache.
+type SemanticCacheHit struct {
+	// Similarity is how close the query is to the one the recipes were generated for, from the
+	// cache threshold up to 1.
+	Similarity float64 `json:"similarity"`
+}
+
+// Handler returns an http.Handler serving the resolver's HTTP API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/resolve", s.require(auth.RoleReader, s.limit(s.resolveHandler)))
+	mux.Handle("POST /resolve/menu", s.require(auth.RoleReader, s.limit(s.menuHandler)))
+	mux.Handle("POST /resolve/image", s.require(auth.RoleReader, s.limit(s.imageResolveHandler)))
+	mux.Handle("GET /recipes/slug/{slug}", s.require(auth.RoleReader, s.recipeBySlugHandler))
+	mux.Handle("GET /ingredients/{name}", s.require(auth.RoleReader, s.ingredientHandler))
+	mux.Handle("GET /stats", s.require(auth.RoleReader, s.statsHandler))
+	mux.Handle("GET /recipes", s.require(auth.RoleReader, s.listRecipesHandler))
+	mux.Handle("GET /recipes/search", s.require(auth.RoleReader, s.searchRecipesHandler))
+	mux.Handle("GET /recipes/export", s.require(auth.RoleCurator, s.exportHandler))
+	mux.Handle("POST /recipes", s.require(auth.RoleCurator, s.createRecipeHandler))
+	mux.Handle("GET /recipes/{id}", s.require(auth.RoleReader, s.getRecipeHandler))
+	mux.Handle("PUT /recipes/{id}", s.require(auth.RoleCurator, s.updateRecipeHandler))
+	mux.Handle("DELETE /recipes/{id}", s.require(auth.RoleCurator, s.deleteRecipeHandler))
+	mux.Handle("POST /recipes/merge", s.require(auth.RoleCurator, s.mergeRecipesHandler))
+	mux.Handle("POST /recipes/batch-delete", s.require(auth.RoleCurator, s.batchDeleteHandler))
+	mux.Handle("POST /recipes/review", s.require(auth.RoleContributor, s.submitReviewHandler))
+	mux.Handle("POST /recipes/import-image", s.require(auth.RoleContributor, s.importImageHandler))
+	mux.Handle("GET /recipes/review", s.require(auth.RoleCurator, s.reviewQueueHandler))
+	mux.Handle("POST /recipes/{id}/review", s.require(auth.RoleCurator, s.reviewHandler))
+	mux.Handle("POST /recipes/{id}/report", s.require(auth.RoleReader, s.reportHandler))
+	mux.Handle("/admin/cache/flush", s.require(auth.RoleAdmin, s.flushCacheHandler))
+	mux.Handle("POST /admin/reindex", s.require(auth.RoleAdmin, s.reindexHandler))
+	mux.Handle("/admin/audit", s.require(auth.RoleAdmin, s.auditHandler))
+	mux.Handle("/admin/privacy/erase", s.require(auth.RoleAdmin, s.eraseHandler))
+	mux.Handle("/admin/nutrition/backfill", s.require(auth.RoleAdmin, s.nutritionBackfillHandler))
+	mux.Handle("GET /admin/recipes/completeness", s.require(auth.RoleAdmin, s.completenessHandler))
+	mux.Handle("/admin/generation/routes", s.require(auth.RoleAdmin, s.routesHandler))
+	mux.Handle("GET /admin/generation/templates", s.require(auth.RoleAdmin, s.templatesHandler))
+	mux.Handle("/admin/metrics/matches", s.require(auth.RoleAdmin, s.matchMetricsHandler))
+	mux.Handle("GET /admin/metrics/caches", s.require(auth.RoleAdmin, s.cacheMetricsHandler))
+	mux.Handle("GET /admin/metrics/deprecations", s.require(auth.RoleAdmin, s.deprecationMetricsHandler))
+	mux.Handle("POST /admin/reload", s.require(auth.RoleAdmin, s.reloadHandler))
+	mux.Handle("/admin/degraded", s.require(auth.RoleAdmin, s.degradedHandler))
+	mux.Handle("GET /admin/jobs", s.require(auth.RoleAdmin, s.jobsHandler))
+	mux.Handle("POST /admin/jobs/{name}/run", s.require(auth.RoleAdmin, s.runJobHandler))
+	mux.Handle("GET /admin/reports", s.require(auth.RoleAdmin, s.reportsHandler))
+	mux.Handle("POST /admin/reports/{id}", s.require(auth.RoleAdmin, s.triageHandler))
+	mux.Handle("/feeds/generated.atom", s.require(auth.RoleReader, s.generatedFeedHandler))
+	// Probes are unauthenticated.
+	mux.HandleFunc("/healthz", s.healthHandler)
+	mux.HandleFunc("/readyz", s.readyHandler)
+	if len(s.Concurrency) > 0 {
+		return s.capped(mux)
+	}
+	return mux
+}
+
+// require wraps h so that, when s.Auth is set, it is only reached by callers holding at least
+// role. Unauthenticated requests get 401, those whose body is too large to authenticate 413
+// and insufficiently privileged ones 403. The principal
+// is available to h through auth.PrincipalFrom. Calls to deprecated endpoints are marked and
+// counted (see Server.Deprecations).
+func (s *Server) require(role auth.Role, h http.HandlerFunc) http.Handler {
+	h = s.deprecated(h)
+	if s.Auth == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, err := s.Auth.Authenticate(r)
+		if errors.Is(err, auth.ErrBodyTooLarge) {
+			s.Logger.Printf("Rejected %s %s: %v", r.Method, r.URL.Path, err)
+			writeError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		}
+		if err != nil {
+			s.Logger.Printf("Rejected unauthenticated %s %s: %v", r.Method, r.URL.Path, err)
+			writeError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		if !p.Role.Allows(role) {
+			s.Logger.Printf("Rejected %s %s from %s: role %s, requires %s", r.Method, r.URL.Path, p.Name, p.Role, role)
+			writeError(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		h(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+	})
+}
+
+// writeError sends a JSON {"error": msg} response with the given status.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// resolveHandler handles POST requests to the /resolve endpoint.
+// It validates the request, decodes the JSON payload, applies the recipe resolution logic,
+// and returns the matching recipes in the structured JSON response.
+func (s *Server) resolveHandler(w http.ResponseWriter, r *http.Request) {
+	// Confirm that the request method is POST; otherwise, return a 405 error.
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	// Decode the JSON request into a ResolveRequest struct.
+	var req ResolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Query) == "" && strings.TrimSpace(req.RecipeID) == "" {
+		// If decoding fails or neither a query nor a recipe ID is given, respond with a 400 Bad
+		// Request.
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request. 'query' field is required and must be a non-empty string, unless 'recipe_id' is set."})
+		return
+	}
+	s.serveResolve(w, r, req, "")
+}
+
+// serveResolve validates the options of req, resolves its query and writes the response in
+// the format r asks for (see negotiate). A non-empty description is the dish recognized in a
+// photo, which req.Query holds.
+func (s *Server) serveResolve(w http.ResponseWriter, r *http.Request, req ResolveRequest, description string) {
+	loc, ok := requestLocale(r, req.Locale)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid 'locale' field; expected a language tag such as 'en-US'.")
+		return
+	}
+	rd, ok := s.negotiate(w, r)
+	if !ok {
+		return
+	}
+	var normalized string
+	switch req.Source {
+	case "", voice.SourceText:
+	case voice.SourceVoice:
+		if n := voice.Normalize(req.Query); n != req.Query {
+			s.Logger.Printf("Voice query %q normalized to %q", req.Query, n)
+			normalized, req.Query = n, n
+		}
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid 'source' field; expected 'text' or 'voice'.")
+		return
+	}
+
+	in := intent.Extract(req.Query)
+	query := resolver.Query{Text: in.Text, Nutrition: in.Nutrition, MaxTotalTime: in.MaxTotalTime, Count: min(in.Count, maxCount), SeasonalOnly: req.Seasonal, KidFriendly: req.KidFriendly}
+	if req.MaxDifficulty != "" {
+		d, err := model.ParseDifficulty(req.MaxDifficulty)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid 'max_difficulty' field; expected 'easy', 'medium' or 'hard'.")
+			return
+		}
+		query.MaxDifficulty = d
+	}
+	if req.SkillLevel != "" {
+		l, err := generation.ParseSkillLevel(req.SkillLevel)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid 'skill_level' field; expected 'beginner' or 'advanced'.")
+			return
+		}
+		query.SkillLevel = l
+	}
+	style, err := parseStyle(req.Tone, req.Measurement, req.Verbosity)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid style field: "+err.Error()+".")
+		return
+	}
+	query.Style = style
+	for _, c := range req.Fusion {
+		if c = strings.ToLower(strings.TrimSpace(c)); c != "" && !slices.Contains(query.Fusion, c) {
+			query.Fusion = append(query.Fusion, c)
+		}
+	}
+	if len(req.Fusion) > 0 && (len(query.Fusion) < 2 || len(query.Fusion) > maxFusion) {
+		writeError(w, http.StatusBadRequest, "Invalid 'fusion' field; expected two or three different cuisines.")
+		return
+	}
+	if req.MaxTotalTime < 0 {
+		writeError(w, http.StatusBadRequest, "Invalid 'max_total_time' field; expected a number of minutes.")
+		return
+	}
+	if req.MaxTotalTime > 0 && (query.MaxTotalTime == 0 || req.MaxTotalTime < query.MaxTotalTime) {
+		query.MaxTotalTime = req.MaxTotalTime
+	}
+	if req.Count < 0 || req.Count > maxCount {
+		writeError(w, http.StatusBadRequest, "Invalid 'count' field; expected a number of recipes up to 5.")
+		return
+	}
+	if req.Count > 0 {
+		query.Count = req.Count
+	}
+	if req.Diversity < 0 || req.Diversity > 1 {
+		writeError(w, http.StatusBadRequest, "Invalid 'diversity' field; expected a number from 0 to 1.")
+		return
+	}
+	query.Diversity = req.Diversity
+	for _, name := range req.Exclude {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			query.Exclude = append(query.Exclude, name)
+		}
+	}
+	if req.Provider != "" || req.Model != "" {
+		if p, ok := auth.PrincipalFrom(r.Context()); !ok || !s.TrustedPrincipals[p.Name] {
+			writeError(w, http.StatusForbidden, "Only trusted callers may choose the 'provider' or 'model'.")
+			return
+		}
+		route, err := generation.Routing.Override(req.Provider, req.Model)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid 'provider' or 'model' field: "+err.Error()+".")
+			return
+		}
+		query.Route = route.Name
+	}
+	if req.MinCompleteness != 0 {
+		if p, ok := auth.PrincipalFrom(r.Context()); !ok || !s.TrustedPrincipals[p.Name] {
+			writeError(w, http.StatusForbidden, "Only trusted callers may set 'min_completeness'.")
+			return
+		}
+		if req.MinCompleteness < 0 || req.MinCompleteness > 1 {
+			writeError(w, http.StatusBadRequest, "Invalid 'min_completeness' field; expected a number from 0 to 1.")
+			return
+		}
+		query.MinCompleteness = req.MinCompleteness
+	}
+	query.Experiments = s.Experiments.Assign(experimentUnit(r))
+	query.Tenant = s.tenantOf(r)
+	query.RecipeID = strings.TrimSpace(req.RecipeID)
+
+	ctx := r.Context()
+	session := strings.TrimSpace(r.Header.Get("X-Session-ID"))
+	var (
+		meter    generation.Meter
+		reserved qos.Reservation
+	)
+	if s.SessionBudget != nil && session != "" {
+		res, left, wait, ok := s.SessionBudget.Reserve(session)
+		if !ok {
+			s.Logger.Printf("Rejected /resolve for session %q: generation budget spent (%+v)", session, left)
+			writeBudgetSpent(w, left, wait)
+			return
+		}
+		reserved = res
+		ctx = generation.WithMeter(ctx, &meter)
+	}
+
+	// Use the resolver to find the best matching recipe(s) based on the query.
+	result, err := s.Resolver.Resolve(ctx, query)
+	var budget *qos.Remaining
+	if s.SessionBudget != nil && session != "" {
+		spent := meter.Usage()
+		left := s.SessionBudget.Settle(reserved, spent.Calls, spent.Total())
+		writeBudget(w, left)
+		budget = &left
+	}
+	if errors.Is(err, qos.ErrBusy) {
+		writeBusy(w)
+		return
+	}
+	if errors.Is(err, resolver.ErrRecipeNotFound) {
+		writeError(w, http.StatusNotFound, "Recipe not found")
+		return
+	}
+	if err != nil {
+		// The only other resolution error is the client going away; there is nobody left to
+		// answer.
+		s.Logger.Printf("Resolution aborted: %v", err)
+		return
+	}
+	response := ResolveResponse{
+		PrimaryRecipe:      render(result.Primary, loc),
+		AlternativeRecipes: renderAll(result.Alternatives, loc),
+		Degraded:           result.Degraded,
+		MaxTotalTime:       query.MaxTotalTime,
+		OverTime:           result.OverTime,
+		Description:        description,
+		NormalizedQuery:    normalized,
+		Budget:             budget,
+	}
+	if query.Count > 1 {
+		response.Count = query.Count
+	}
+	if result.Match == resolver.MatchSemantic {
+		response.SemanticCache = &SemanticCacheHit{Similarity: result.Score}
+	}
+	for _, c := range query.Nutrition {
+		response.Nutrition = append(response.Nutrition, c.String())
+	}
+	for _, c := range result.UnmetNutrition {
+		response.NutritionUnmet = append(response.NutritionUnmet, c.String())
+	}
+	for _, a := range query.Experiments {
+		response.Experiments = append(response.Experiments, a.String())
+	}
+	var pantry []string
+	for _, item := range req.Pantry {
+		if item = strings.TrimSpace(item); item != "" {
+			pantry = append(pantry, item)
+		}
+	}
+	if len(pantry) > 0 {
+		response.Pantry = make(map[string]shopping.Coverage)
+		for _, rec := range append([]model.Recipe{response.PrimaryRecipe}, response.AlternativeRecipes...) {
+			response.Pantry[rec.ID] = shopping.PantryCoverage(rec, pantry)
+		}
+	}
+	if req.Annotations && s.Glossary != nil {
+		response.Annotations = make(map[string][]glossary.Annotation)
+		for _, rec := range append([]model.Recipe{response.PrimaryRecipe}, response.AlternativeRecipes...) {
+			if a := s.Glossary.Annotate(rec.Steps); a != nil {
+				response.Annotations[rec.ID] = a
+			}
+		}
+	}
+
+	// Send back the response in the format asked for with a 200 OK status.
+	w.Header().Set("Content-Language", loc.Tag)
+	if tags := experiment.Tags(query.Experiments); tags != "" {
+		w.Header().Set("X-Experiments", tags)
+	}
+	s.respond(w, rd, renderer.Document{
+		Recipes: append([]model.Recipe{response.PrimaryRecipe}, response.AlternativeRecipes...),
+		Value:   response,
+	})
+}
+
+// experimentUnit returns the key r is assigned to experiment variants by: its X-Session-ID
+// header, or else the name of its authenticated principal. It is empty for anonymous requests
+// without a session, which stay in the control group.
+func experimentUnit(r *http.Request) string {
+	if id := strings.TrimSpace(r.Header.Get("X-Session-ID")); id != "" {
+		return "session:" + id
+	}
+	if p, ok := auth.PrincipalFrom(r.Context()); ok {
+		return "principal:" + p.Name
+	}
+	return ""
+}
+
+// tenantOf returns the profile of the tenant r's authenticated principal belongs to, or nil.
+func (s *Server) tenantOf(r *http.Request) *tenant.Profile {
+	if p, ok := auth.PrincipalFrom(r.Context()); ok {
+		return s.Tenants.For(p.Name)
+	}
+	return nil
+}
+
+// parseStyle parses the optional style fields of a request.
+func parseStyle(tone, measurement, verbosity string) (generation.Options, error) {
+	var o generation.Options
+	var err error
+	if tone != "" {
+		if o.Tone, err = generation.ParseTone(tone); err != nil {
+			return o, err
+		}
+	}
+	if measurement != "" {
+		if o.Measurement, err = generation.ParseMeasurement(measurement); err != nil {
+			return o, err
+		}
+	}
+	if verbosity != "" {
+		if o.Verbosity, err = generation.ParseVerbosity(verbosity); err != nil {
+			return o, err
+		}
+	}
+	return o, nil
+}
+
+// FlushCacheResponse is the JSON response of the /admin/cache/flush endpoint.
+type FlushCacheResponse struct {
+	Flushed int `json:"flushed"`
+}
+
+// flushCacheHandler handles POST requests to /admin/cache/flush by dropping every cached
+// generation, so that subsequent queries are generated afresh.
+func (s *Server) flushCacheHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		return
+	}
+
+	flushed := 0
+	if s.Resolver.Cache != nil {
+		flushed = s.Resolver.Cache.Flush()
+	}
+	if s.Resolver.Semantic != nil {
+		flushed += s.Resolver.Semantic.Flush()
+	}
+	s.Logger.Printf("Admin: Flushed %d cached generations", flushed)
+	s.audit(r, "cache.flush", "", nil, FlushCacheResponse{Flushed: flushed})
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(FlushCacheResponse{Flushed: flushed})
+}
+
+// ReindexResponse is the JSON response of the /admin/reindex endpoint.
+type ReindexResponse struct {
+	Reindexed int `json:"reindexed"`
+}
+
+// reindexHandler handles POST /admin/reindex, rebuilding the search index of a recipe store
+// that derives it itself (see resolver.Reindexer), and answers 501 for other stores. It is
+// audited as "store.reindex".
+func (s *Server) reindexHandler(w http.ResponseWriter, r *http.Request) {
+	store, ok := s.Resolver.Store.(resolver.Reindexer)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "Reindexing is not available for this recipe store")
+		return
+	}
+	n, err := store.Reindex(r.Context())
+	if err != nil {
+		s.Logger.Printf("Admin: Could not reindex recipes: %v", err)
+		writeError(w, http.StatusInternalServerError, "The recipes could not be reindexed; retry later.")
+		return
+	}
+	s.Logger.Printf("Admin: Reindexed %d recipes", n)
+	s.audit(r, "store.reindex", "", nil, ReindexResponse{Reindexed: n})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ReindexResponse{Reindexed: n})
+}
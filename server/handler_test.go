@@ -0,0 +1,771 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/audit"
+	"github.com/pageza/recipe-resolver-ms/auth"
+	"github.com/pageza/recipe-resolver-ms/experiment"
+	"github.com/pageza/recipe-resolver-ms/generation"
+	"github.com/pageza/recipe-resolver-ms/generation/providertest"
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/qos"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+	"github.com/pageza/recipe-resolver-ms/tuning"
+)
+
+// newTestServer returns a Server over the sample recipes whose generator always fails.
+func newTestServer() *Server {
+	r := resolver.New(resolver.NewMemoryStore(resolver.SampleRecipes()), failingGenerator{})
+	r.Logger = log.New(io.Discard, "", 0)
+	return New(r)
+}
+
+type failingGenerator struct{}
+
+func (failingGenerator) Generate(ctx context.Context, query string) (generation.Recipe, []generation.Recipe, error) {
+	return generation.Recipe{}, nil, errors.New("unavailable")
+}
+
+// TestResolveHandler verifies the behavior of the /resolve HTTP endpoint.
+func TestResolveHandler(t *testing.T) {
+	// Prepare a JSON payload with a valid query.
+	reqBody, err := json.Marshal(ResolveRequest{Query: "Spaghetti Bolognese"})
+	if err != nil {
+		t.Fatalf("Failed to marshal request body: %v", err)
+	}
+
+	// Create a new HTTP POST request to the /resolve endpoint.
+	req, err := http.NewRequest(http.MethodPost, "/resolve", bytes.NewReader(reqBody))
+	if err != nil {
+		t.Fatalf("Failed to create HTTP request: %v", err)
+	}
+
+	// Use httptest to record the response.
+	rr := httptest.NewRecorder()
+	newTestServer().Handler().ServeHTTP(rr, req)
+
+	// Verify the HTTP status code.
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Expected HTTP status %d, got %d", http.StatusOK, status)
+	}
+
+	// Verify the Content-Type header.
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected Content-Type 'application/json', got '%s'", ct)
+	}
+
+	// Decode the JSON response.
+	var res ResolveResponse
+	if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+		t.Fatalf("Failed to decode response body: %v", err)
+	}
+
+	// Verify that the primary recipe matches the expected title.
+	if !strings.EqualFold(res.PrimaryRecipe.Title, "Spaghetti Bolognese") {
+		t.Errorf("Expected primary recipe 'Spaghetti Bolognese', got '%s'", res.PrimaryRecipe.Title)
+	}
+	if p := res.PrimaryRecipe.Provenance; p == nil || p.Origin != model.OriginCurated {
+		t.Errorf("Expected a stored recipe labeled curated, got %+v", p)
+	}
+}
+
+// TestResolveHandlerRejectsInvalidRequests verifies the 405 and 400 responses of /resolve.
+func TestResolveHandlerRejectsInvalidRequests(t *testing.T) {
+	handler := newTestServer().Handler()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/resolve", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected HTTP status %d for GET, got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(`{"query":"  "}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status %d for empty query, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(`{"query":"soup","skill_level":"chef"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status %d for an unknown skill level, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(`{"query":"soup","tone":"sarcastic"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status %d for an unknown tone, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(`{"query":"tacos","fusion":["Korean","korean "]}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status %d for a fusion of one cuisine, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+// TestFlushCacheHandler verifies that /admin/cache/flush empties the resolver cache.
+func TestFlushCacheHandler(t *testing.T) {
+	srv := newTestServer()
+	srv.Resolver.Cache.Set("soup", resolver.Result{})
+
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/cache/flush", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected HTTP status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var res FlushCacheResponse
+	if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+		t.Fatalf("Failed to decode response body: %v", err)
+	}
+	if res.Flushed != 1 {
+		t.Errorf("Expected 1 flushed entry, got %d", res.Flushed)
+	}
+	if _, ok := srv.Resolver.Cache.Get("soup"); ok {
+		t.Errorf("Expected cache to be empty after flush")
+	}
+}
+
+func TestHandlerRequiresAuth(t *testing.T) {
+	secret := []byte("gateway-secret")
+	srv := newTestServer()
+	srv.Auth = auth.NewHMACVerifier(secret)
+
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(`{"query":"Chicken Salad"}`)))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected HTTP status %d for an unsigned request, got %d", http.StatusUnauthorized, rr.Code)
+	}
+
+	body := `{"query":"Chicken Salad"}`
+	req := httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(body))
+	auth.Sign(req, []byte(body), secret, time.Now())
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected HTTP status %d for a signed request, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+	}
+
+	srv.Auth.(*auth.HMACVerifier).MaxBody = int64(len(body)) - 1
+	req = httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(body))
+	auth.Sign(req, []byte(body), secret, time.Now())
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected HTTP status %d for a body too large to verify, got %d", http.StatusRequestEntityTooLarge, rr.Code)
+	}
+}
+
+func TestHandlerEnforcesRoles(t *testing.T) {
+	srv := newTestServer()
+	srv.Auth = auth.APIKeys{
+		"reader-key": {Name: "dashboard", Role: auth.RoleReader},
+		"admin-key":  {Name: "ops", Role: auth.RoleAdmin},
+	}
+	tests := []struct {
+		key, path string
+		want      int
+	}{
+		{"reader-key", "/resolve", http.StatusOK},
+		{"reader-key", "/admin/cache/flush", http.StatusForbidden},
+		{"admin-key", "/admin/cache/flush", http.StatusOK},
+		{"unknown-key", "/resolve", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(`{"query":"Chicken Salad"}`))
+		req.Header.Set("Authorization", "Bearer "+tt.key)
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s %s: expected HTTP status %d, got %d", tt.key, tt.path, tt.want, rr.Code)
+		}
+	}
+}
+
+// TestRoutesHandler verifies that the model routes and their metrics are reported.
+func TestRoutesHandler(t *testing.T) {
+	generation.Routing = generation.NewRouter(generation.Route{Name: "cheap", MaxWords: 3, Model: "deepseek-chat"})
+	defer func() { generation.Routing = nil }()
+
+	rr := httptest.NewRecorder()
+	newTestServer().Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/generation/routes", nil))
+	var resp RoutesResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Routes) != 1 || resp.Routes[0].Name != "cheap" || resp.Stats == nil {
+		t.Errorf("Unexpected routes response %+v", resp)
+	}
+}
+
+// TestTemplatesHandler verifies that the current and canary template versions are reported.
+func TestTemplatesHandler(t *testing.T) {
+	srv := newTestServer()
+	config := &tuning.Config{CanaryTemplate: "A recipe for {{.Query}}", CanaryPercent: 5}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.ApplyTuning(config); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/generation/templates", nil))
+	var resp TemplatesResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Current != generation.TemplateVersion(generation.PromptTemplate) || resp.Canary != generation.TemplateVersion(config.Canary()) || resp.CanaryPercent != 5 || resp.Stats == nil {
+		t.Errorf("Unexpected templates response %+v", resp)
+	}
+}
+
+// TestMatchMetricsHandler verifies that the match quality of resolved queries is reported.
+func TestMatchMetricsHandler(t *testing.T) {
+	srv := newTestServer()
+	srv.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(`{"query":"Chicken Salad"}`)))
+
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/metrics/matches", nil))
+	var resp MatchMetricsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Requests != 1 || resp.ByMatch[resolver.MatchExact] != 1 || resp.Threshold != srv.Resolver.Threshold {
+		t.Errorf("Unexpected match metrics %+v", resp)
+	}
+}
+
+// TestCacheMetricsHandler verifies that cache statistics are reported by cache name.
+func TestCacheMetricsHandler(t *testing.T) {
+	srv := newTestServer()
+	srv.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(`{"query":"lemon tart"}`)))
+
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/metrics/caches", nil))
+	var resp CacheMetricsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := resp.Caches["results"]; !ok || s.Misses != 1 || s.MaxBytes != resolver.DefaultCacheBytes || s.Policy != "lru" {
+		t.Errorf("Unexpected cache metrics %+v", resp)
+	}
+}
+
+// TestResolveHandlerExperiments verifies that sessions are assigned to experiment variants and
+// that responses are tagged with them.
+func TestResolveHandlerExperiments(t *testing.T) {
+	srv := newTestServer()
+	srv.Experiments = &experiment.Config{Experiments: []experiment.Experiment{
+		{Name: "threshold", Variants: []experiment.Variant{{Name: "strict", Percent: 100, Threshold: 0.9}}},
+	}}
+
+	for _, session := range []string{"", "abc"} {
+		req := httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(`{"query":"Chicken Salad"}`))
+		if session != "" {
+			req.Header.Set("X-Session-ID", session)
+		}
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		var resp ResolveResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		want := ""
+		if session != "" {
+			want = "threshold=strict"
+		}
+		if got := rr.Header().Get("X-Experiments"); got != want {
+			t.Errorf("Session %q: expected X-Experiments %q, got %q", session, want, got)
+		}
+		if got := strings.Join(resp.Experiments, ","); got != want {
+			t.Errorf("Session %q: expected experiments %q in the response, got %q", session, want, got)
+		}
+	}
+}
+
+// TestResolveHandlerNutrition verifies that nutrition constraints are read from the query and
+// listed in the response.
+func TestResolveHandlerNutrition(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(`{"query":"Spaghetti Bolognese under 500 kcal"}`))
+	rr := httptest.NewRecorder()
+	newTestServer().Handler().ServeHTTP(rr, req)
+	var resp ResolveResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.PrimaryRecipe.Title != "Spaghetti Bolognese" {
+		t.Errorf("Expected the constraint to be matched apart from the title, got %q", resp.PrimaryRecipe.Title)
+	}
+	if got := strings.Join(resp.Nutrition, ","); got != "calories<=500" {
+		t.Errorf("Expected the calorie limit in the response, got %q", got)
+	}
+}
+
+// TestResolveHandlerPantry verifies that a pantry in the request gets the coverage of each
+// returned recipe, and that none is reported without one.
+func TestResolveHandlerPantry(t *testing.T) {
+	handler := newTestServer().Handler()
+	req := httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(`{"query":"Spaghetti Bolognese","pantry":["Spaghetti"," garlic ",""]}`))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp ResolveResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	c, ok := resp.Pantry[resp.PrimaryRecipe.ID]
+	if !ok {
+		t.Fatalf("Expected the coverage of the primary recipe, got %+v", resp.Pantry)
+	}
+	if got := strings.Join(c.Have, ","); got != "Spaghetti,garlic" {
+		t.Errorf("Expected the pantry items the recipe uses, got %q", got)
+	}
+	if len(c.Need) != 3 || c.Percent != 40 {
+		t.Errorf("Expected 3 ingredients to buy and 40%% coverage, got %+v", c)
+	}
+	for _, alt := range resp.AlternativeRecipes {
+		if _, ok := resp.Pantry[alt.ID]; !ok {
+			t.Errorf("Expected the coverage of alternative %q", alt.Title)
+		}
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(`{"query":"Spaghetti Bolognese"}`)))
+	if strings.Contains(rr.Body.String(), `"pantry"`) {
+		t.Errorf("Expected no coverage without a pantry, got %s", rr.Body.String())
+	}
+}
+
+// TestResolveHandlerTotalTime verifies that the shorter of the requested and the parsed time
+// limits applies, and that negative limits are rejected.
+func TestResolveHandlerTotalTime(t *testing.T) {
+	handler := newTestServer().Handler()
+	tests := []struct {
+		body   string
+		status int
+		limit  int
+	}{
+		{`{"query":"Chicken Salad ready in 20 minutes"}`, http.StatusOK, 20},
+		{`{"query":"Chicken Salad ready in 20 minutes","max_total_time":15}`, http.StatusOK, 15},
+		{`{"query":"Chicken Salad under an hour","max_total_time":90}`, http.StatusOK, 60},
+		{`{"query":"Chicken Salad","max_total_time":-1}`, http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(tt.body)))
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.body, tt.status, rr.Code)
+			continue
+		}
+		var resp ResolveResponse
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if resp.MaxTotalTime != tt.limit {
+			t.Errorf("%s: expected a limit of %d minutes, got %d", tt.body, tt.limit, resp.MaxTotalTime)
+		}
+	}
+}
+
+// TestResolveHandlerPriority verifies that batch requests are throttled with 429 once the
+// batch share of the limiter is in use, and that invalid priorities are rejected.
+func TestResolveHandlerPriority(t *testing.T) {
+	srv := newTestServer()
+	srv.Limiter = qos.NewLimiter(2, 1)
+	srv.Limiter.MaxWait = 10 * time.Millisecond
+	release, err := srv.Limiter.Acquire(context.Background(), qos.Batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	tests := []struct {
+		priority string
+		want     int
+	}{
+		{"", http.StatusOK},
+		{"interactive", http.StatusOK},
+		{"batch", http.StatusTooManyRequests},
+		{"urgent", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(`{"query":"Chicken Salad"}`))
+		if tt.priority != "" {
+			req.Header.Set("X-Priority", tt.priority)
+		}
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("Priority %q: expected HTTP status %d, got %d", tt.priority, tt.want, rr.Code)
+		}
+	}
+}
+
+// TestResolveHandlerConcurrency verifies that requests beyond an endpoint's cap, or needing a
+// generation beyond the generation cap, are rejected at once with 503.
+func TestResolveHandlerConcurrency(t *testing.T) {
+	srv := newTestServer()
+	srv.Concurrency = map[string]*qos.Gate{"/resolve": qos.NewGate(1)}
+	srv.Resolver.Generations = qos.NewGate(1)
+	handler := srv.Handler()
+	resolve := func(query string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(`{"query":"`+query+`"}`)))
+		return rr
+	}
+
+	release, _ := srv.Concurrency["/resolve"].TryAcquire()
+	if rr := resolve("Chicken Salad"); rr.Code != http.StatusServiceUnavailable || rr.Header().Get("Retry-After") == "" {
+		t.Errorf("Expected 503 with Retry-After while /resolve is at its cap, got %d", rr.Code)
+	}
+	release()
+
+	release, _ = srv.Resolver.Generations.TryAcquire()
+	if rr := resolve("Chicken Salad"); rr.Code != http.StatusOK {
+		t.Errorf("Expected a stored match to be served while generations are at their cap, got %d", rr.Code)
+	}
+	if rr := resolve("lemon tart"); rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for a query needing a generation at the cap, got %d", rr.Code)
+	}
+	release()
+	if rr := resolve("lemon tart"); rr.Code != http.StatusOK {
+		t.Errorf("Expected 200 once the generation cap has room, got %d", rr.Code)
+	}
+}
+
+// TestReloadHandler verifies that POST /admin/reload applies a valid tuning and keeps the
+// current one when the new one is invalid.
+func TestReloadHandler(t *testing.T) {
+	srv := newTestServer()
+	handler := srv.Handler()
+	reload := func() int {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+		return rr.Code
+	}
+	if code := reload(); code != http.StatusNotImplemented {
+		t.Errorf("Expected 501 without a tuning file, got %d", code)
+	}
+
+	srv.Limiter = qos.NewLimiter(4, 2)
+	config := &tuning.Config{Threshold: 0.9, MaxConcurrent: 8}
+	srv.LoadTuning = func() (*tuning.Config, error) { return config, nil }
+	if code := reload(); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if srv.Resolver.CurrentThreshold() != 0.9 || srv.Limiter.Capacity != 8 || srv.Limiter.BatchLimit != 4 {
+		t.Errorf("Expected the tuning applied, got threshold %v and limiter %d/%d", srv.Resolver.CurrentThreshold(), srv.Limiter.Capacity, srv.Limiter.BatchLimit)
+	}
+
+	config = &tuning.Config{Threshold: 0.5, Concurrency: map[string]int{"/resolve": 10}}
+	if code := reload(); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a cap that was not configured at startup, got %d", code)
+	}
+	if srv.Resolver.CurrentThreshold() != 0.9 {
+		t.Errorf("Expected the invalid tuning to change nothing, got threshold %v", srv.Resolver.CurrentThreshold())
+	}
+}
+
+// reindexStore is a MemoryStore that counts its reindexing.
+type reindexStore struct {
+	*resolver.MemoryStore
+	runs *int
+}
+
+func (s reindexStore) Reindex(ctx context.Context) (int, error) {
+	*s.runs++
+	return len(s.All()), nil
+}
+
+// TestReindexHandler verifies that POST /admin/reindex reindexes a store that supports it and
+// answers 501 otherwise.
+func TestReindexHandler(t *testing.T) {
+	srv := newTestServer()
+	reindex := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/reindex", nil))
+		return rr
+	}
+	if rr := reindex(); rr.Code != http.StatusNotImplemented {
+		t.Errorf("Expected 501 for a store without reindexing, got %d", rr.Code)
+	}
+
+	runs := 0
+	srv.Resolver.Store = reindexStore{resolver.NewMemoryStore(resolver.SampleRecipes()), &runs}
+	rr := reindex()
+	var resp ReindexResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil || rr.Code != http.StatusOK || resp.Reindexed != 2 || runs != 1 {
+		t.Fatalf("Expected the two recipes reindexed once, got %d %+v after %d runs", rr.Code, resp, runs)
+	}
+	if entries := srv.Audit.List(audit.Filter{Action: "store.reindex"}); len(entries) != 1 {
+		t.Errorf("Expected the reindex audited, got %+v", entries)
+	}
+}
+
+// TestDegradedHandler verifies that degraded mode can be switched on and is reported in
+// responses.
+func TestDegradedHandler(t *testing.T) {
+	srv := newTestServer()
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/degraded", strings.NewReader(`{"degraded":true}`)))
+	var status resolver.DegradationStatus
+	if err := json.NewDecoder(rr.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if !status.Degraded || !status.Manual {
+		t.Errorf("Expected manual degraded mode, got %+v", status)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(`{"query":"chicken soup with rice"}`)))
+	var resp ResolveResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Degraded {
+		t.Errorf("Expected a degraded response, got %+v", resp)
+	}
+	if entries := srv.Audit.List(audit.Filter{Action: "degraded.set"}); len(entries) != 1 {
+		t.Errorf("Expected the switch to be audited, got %d entries", len(entries))
+	}
+}
+
+// TestResolveHandlerCount verifies that the number of recipes asked for is read from the request
+// or its query and bounded.
+func TestResolveHandlerCount(t *testing.T) {
+	handler := newTestServer().Handler()
+	tests := []struct {
+		body   string
+		status int
+		count  int
+	}{
+		{`{"query":"3 dinner ideas with chicken"}`, http.StatusOK, 3},
+		{`{"query":"3 dinner ideas with chicken","count":2}`, http.StatusOK, 2},
+		{`{"query":"ten ideas for chicken salad"}`, http.StatusOK, maxCount},
+		{`{"query":"Chicken Salad","count":6}`, http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(tt.body)))
+		if rr.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.body, tt.status, rr.Code)
+			continue
+		}
+		var resp ResolveResponse
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if resp.Count != tt.count {
+			t.Errorf("%s: expected a count of %d, got %d", tt.body, tt.count, resp.Count)
+		}
+	}
+}
+
+// TestResolveHandlerRecipeID verifies that a request for a recipe ID returns the stored recipe
+// with similar ones, and 404 for an unknown ID.
+func TestResolveHandlerRecipeID(t *testing.T) {
+	srv := newTestServer()
+	store := srv.Resolver.Store.(*resolver.MemoryStore)
+	caesar := model.NewRecipe("Chicken Caesar Salad", []string{"chicken", "lettuce", "parmesan"}, []string{"Toss."}, nil, "", nil)
+	store.Add(caesar)
+	salad := store.All()[1]
+
+	for body, status := range map[string]int{
+		`{"recipe_id":"` + salad.ID + `"}`:                   http.StatusOK,
+		`{"recipe_id":"` + salad.ID + `","query":"ignored"}`: http.StatusOK,
+		`{"recipe_id":"unknown"}`:                            http.StatusNotFound,
+		`{"recipe_id":" "}`:                                  http.StatusBadRequest,
+	} {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(body)))
+		if rr.Code != status {
+			t.Errorf("%s: expected status %d, got %d %s", body, status, rr.Code, rr.Body)
+			continue
+		}
+		if status != http.StatusOK {
+			continue
+		}
+		var resp ResolveResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.PrimaryRecipe.ID != salad.ID || resp.PrimaryRecipe.Title != "Chicken Salad" {
+			t.Errorf("%s: expected the stored recipe, got %+v", body, resp.PrimaryRecipe)
+		}
+		if len(resp.AlternativeRecipes) != 1 || resp.AlternativeRecipes[0].ID != caesar.ID {
+			t.Errorf("%s: expected the similar recipe as alternative, got %+v", body, resp.AlternativeRecipes)
+		}
+	}
+}
+
+// TestResolveHandlerDiversity verifies that a diversity outside 0 to 1 is rejected.
+func TestResolveHandlerDiversity(t *testing.T) {
+	handler := newTestServer().Handler()
+	for body, status := range map[string]int{
+		`{"query":"Chicken Salad","diversity":0.5}`: http.StatusOK,
+		`{"query":"Chicken Salad","diversity":1.5}`: http.StatusBadRequest,
+		`{"query":"Chicken Salad","diversity":-1}`:  http.StatusBadRequest,
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(body)))
+		if rr.Code != status {
+			t.Errorf("%s: expected status %d, got %d", body, status, rr.Code)
+		}
+	}
+}
+
+// TestResolveHandlerVoice verifies that voice transcripts are cleaned up before resolution.
+func TestResolveHandlerVoice(t *testing.T) {
+	handler := newTestServer().Handler()
+	post := func(body string) (*httptest.ResponseRecorder, ResolveResponse) {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(body)))
+		var resp ResolveResponse
+		json.NewDecoder(rr.Body).Decode(&resp)
+		return rr, resp
+	}
+
+	rr, resp := post(`{"query":"um can you find me a recipe for spaghetti bolognese please","source":"voice"}`)
+	if rr.Code != http.StatusOK || resp.PrimaryRecipe.Title != "Spaghetti Bolognese" || resp.NormalizedQuery != "spaghetti bolognese" {
+		t.Errorf("Expected the transcript to match Spaghetti Bolognese, got %d %q for %q", rr.Code, resp.PrimaryRecipe.Title, resp.NormalizedQuery)
+	}
+	if _, resp := post(`{"query":"uh pasta ready in twenty minutes","source":"voice"}`); resp.MaxTotalTime != 20 || resp.NormalizedQuery != "pasta ready in 20 minutes" {
+		t.Errorf("Expected a 20 minute limit from the spoken number, got %d for %q", resp.MaxTotalTime, resp.NormalizedQuery)
+	}
+	if _, resp := post(`{"query":"Chicken Salad","source":"text"}`); resp.NormalizedQuery != "" {
+		t.Errorf("Expected typed queries to be used as they are, got %q", resp.NormalizedQuery)
+	}
+	if rr, _ := post(`{"query":"Chicken Salad","source":"telepathy"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown source to be rejected, got %d", rr.Code)
+	}
+}
+
+// TestResolveHandlerModelOverride verifies that only trusted callers may choose the model, and
+// only among selectable routes.
+func TestResolveHandlerModelOverride(t *testing.T) {
+	generation.Routing = generation.NewRouter(
+		generation.Route{Name: "cheap", Model: "deepseek-chat"},
+		generation.Route{Name: "premium", Provider: "deepseek", Model: "deepseek-reasoner", Selectable: true},
+	)
+	defer func() { generation.Routing = nil }()
+	srv := newTestServer()
+	srv.Auth = auth.APIKeys{
+		"reader-key":  {Name: "app", Role: auth.RoleReader},
+		"premium-key": {Name: "premium-app", Role: auth.RoleReader},
+	}
+	srv.TrustedPrincipals = map[string]bool{"premium-app": true}
+	tests := []struct {
+		key, body string
+		want      int
+	}{
+		{"reader-key", `{"query":"Chicken Salad","model":"deepseek-reasoner"}`, http.StatusForbidden},
+		{"premium-key", `{"query":"Chicken Salad","model":"deepseek-chat"}`, http.StatusBadRequest},
+		{"premium-key", `{"query":"Chicken Salad","provider":"DeepSeek","model":"deepseek-reasoner"}`, http.StatusOK},
+		{"premium-key", `{"query":"Chicken Salad"}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(tt.body))
+		req.Header.Set("Authorization", "Bearer "+tt.key)
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s %s: expected HTTP status %d, got %d", tt.key, tt.body, tt.want, rr.Code)
+		}
+	}
+}
+
+// TestMenuHandler verifies that /resolve/menu returns the requested courses with a shopping
+// list and rejects unknown courses.
+func TestMenuHandler(t *testing.T) {
+	srv := newTestServer()
+	dessert := model.NewRecipe("Chocolate Mousse", []string{"200 g chocolate", "3 eggs"}, []string{"Whisk."}, nil, "", nil)
+	dessert.Tags = []string{model.CourseTag(model.CourseDessert)}
+	srv.Resolver.Store.(*resolver.MemoryStore).Add(dessert)
+	handler := srv.Handler()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/resolve/menu", strings.NewReader(`{"query":"chocolate lovers dinner","courses":["main","dessert"]}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected HTTP status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+	}
+	var resp MenuResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Courses) != 2 || resp.Courses[0].Match != resolver.MatchFallback || resp.Courses[1].Recipe.Title != "Chocolate Mousse" {
+		t.Errorf("Expected a fallback main and the stored dessert, got %+v", resp.Courses)
+	}
+	if len(resp.ShoppingList) != 2 || resp.ShoppingList[0].Name != "chocolate" {
+		t.Errorf("Expected the dessert's ingredients on the shopping list, got %+v", resp.ShoppingList)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/resolve/menu", strings.NewReader(`{"query":"dinner","courses":["soup"]}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status %d for an unknown course, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+// TestResolveHandlerSessionBudget verifies that each session's generations are charged to its
+// own budget, reported in the response, and rejected with 429 once it is spent.
+func TestResolveHandlerSessionBudget(t *testing.T) {
+	provider := providertest.NewServer(generation.LLMResponse{PrimaryRecipe: generation.Recipe{Title: "Mushroom Risotto"}}, false)
+	defer provider.Close()
+	t.Setenv("LLM_ENDPOINT", provider.URL)
+	t.Setenv("DEEPSEEK_API_KEY", "")
+	r := resolver.New(resolver.NewMemoryStore(resolver.SampleRecipes()), resolver.LLMGenerator{})
+	r.Logger = log.New(io.Discard, "", 0)
+	srv := New(r)
+	srv.SessionBudget = qos.NewSessionBudget(2, 100000, time.Hour)
+
+	resolve := func(query, session string) (*httptest.ResponseRecorder, ResolveResponse) {
+		req := httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(`{"query":"`+query+`"}`))
+		if session != "" {
+			req.Header.Set("X-Session-ID", session)
+		}
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		var resp ResolveResponse
+		json.NewDecoder(rr.Body).Decode(&resp)
+		return rr, resp
+	}
+	rr, resp := resolve("mushroom risotto", "s1")
+	if rr.Code != http.StatusOK || resp.Budget == nil || resp.Budget.Calls != 1 || resp.Budget.Tokens >= 100000 || rr.Header().Get("X-Budget-Remaining-Calls") != "1" {
+		t.Fatalf("Expected one call left after a generation, got %d %+v", rr.Code, resp.Budget)
+	}
+	// Stored matches cost nothing.
+	if rr, resp := resolve("Chicken Salad", "s1"); rr.Code != http.StatusOK || resp.Budget.Calls != 1 {
+		t.Errorf("Expected a stored match to be free, got %d %+v", rr.Code, resp.Budget)
+	}
+	resolve("mushroom soup", "s1")
+	if rr, _ := resolve("mushroom pie", "s1"); rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" || rr.Header().Get("X-Budget-Remaining-Calls") != "0" {
+		t.Errorf("Expected 429 once the budget is spent, got %d %v", rr.Code, rr.Header())
+	}
+	if provider.Requests() != 2 {
+		t.Errorf("Expected 2 provider requests, got %d", provider.Requests())
+	}
+	if rr, resp := resolve("mushroom pie", "s2"); rr.Code != http.StatusOK || resp.Budget.Calls != 1 {
+		t.Errorf("Expected another session to have its own budget, got %d %+v", rr.Code, resp.Budget)
+	}
+	if rr, resp := resolve("mushroom tart", ""); rr.Code != http.StatusOK || resp.Budget != nil {
+		t.Errorf("Expected requests without a session to be unbudgeted, got %d %+v", rr.Code, resp.Budget)
+	}
+}
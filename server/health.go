@@ -0,0 +1,44 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/pageza/recipe-resolver-ms/generation"
+)
+
+// WarmUp prepares the service for traffic and then marks it ready: it warms up the resolver
+// (see resolver.Resolver.WarmUp) and pings the LLM providers. An unreachable provider is only
+// logged, since queries can still be answered from the store; any other failure leaves the
+// service unready.
+func (s *Server) WarmUp(ctx context.Context) error {
+	n, err := s.Resolver.WarmUp(ctx)
+	if err != nil {
+		return err
+	}
+	if err := generation.Ping(ctx); err != nil {
+		s.Logger.Printf("Warm-up: LLM provider not reachable: %v", err)
+	}
+	s.ready.Store(true)
+	s.Logger.Printf("Warm-up complete with %d recipes; ready for traffic", n)
+	return nil
+}
+
+// healthHandler handles GET /healthz, the liveness probe: it succeeds while the process serves
+// HTTP at all.
+func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// readyHandler handles GET /readyz, the readiness probe: it fails with 503 until WarmUp has
+// completed.
+func (s *Server) readyHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.ready.Load() {
+		writeError(w, http.StatusServiceUnavailable, "Warming up")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ready"})
+}
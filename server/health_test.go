@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestReadiness verifies that /readyz fails until warm-up completes while /healthz always
+// succeeds.
+func TestReadiness(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	defer mockServer.Close()
+	t.Setenv("LLM_ENDPOINT", mockServer.URL)
+
+	srv := newTestServer()
+	probe := func(path string) int {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr.Code
+	}
+	if code := probe("/healthz"); code != http.StatusOK {
+		t.Errorf("Expected /healthz to succeed, got %d", code)
+	}
+	if code := probe("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected /readyz to fail before warm-up, got %d", code)
+	}
+	if err := srv.WarmUp(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if code := probe("/readyz"); code != http.StatusOK {
+		t.Errorf("Expected /readyz to succeed after warm-up, got %d", code)
+	}
+}
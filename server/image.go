@@ -0,0 +1,154 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/pageza/recipe-resolver-ms/generation"
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// maxImageBytes is the largest photo accepted, before base64 encoding.
+const maxImageBytes = 8 << 20
+
+// imageTypes are the photo formats accepted, as sniffed by http.DetectContentType.
+var imageTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+// ImageResolveRequest is the JSON payload of POST /resolve/image.
+type ImageResolveRequest struct {
+	// Image is the base64-encoded photo of a dish: a JPEG, PNG, GIF or WebP of up to 8 MiB.
+	Image []byte `json:"image"`
+	// The options of ResolveRequest apply to the resolution of the dish recognized in the
+	// photo; its Query and RecipeID are ignored.
+	ResolveRequest
+}
+
+// imageResolveHandler handles POST /resolve/image: the dish in the photo is described by a
+// vision model, and the description resolved like the query of a /resolve request. The
+// response is a ResolveResponse with the description. Photos showing no dish get 422.
+func (s *Server) imageResolveHandler(w http.ResponseWriter, r *http.Request) {
+	if s.DescribeImage == nil {
+		writeError(w, http.StatusNotImplemented, "Resolving photos is not available")
+		return
+	}
+	var req ImageResolveRequest
+	if !decodeImageRequest(w, r, &req, &req.Image) {
+		return
+	}
+	img, ok := checkImage(w, req.Image)
+	if !ok {
+		return
+	}
+
+	description, err := s.DescribeImage(r.Context(), img)
+	if errors.Is(err, generation.ErrNoDish) {
+		writeError(w, http.StatusUnprocessableEntity, "No dish was recognized in the photo.")
+		return
+	}
+	if err != nil {
+		if r.Context().Err() != nil {
+			// The client went away; there is nobody left to answer.
+			return
+		}
+		s.Logger.Printf("Could not describe photo: %v", err)
+		writeError(w, http.StatusBadGateway, "The photo could not be described; retry later.")
+		return
+	}
+	s.Logger.Printf("Resolving photo described as %q", description)
+	req.Query, req.RecipeID = description, ""
+	s.serveResolve(w, r, req.ResolveRequest, description)
+}
+
+// decodeImageRequest decodes the JSON body of r, of up to about maxImageBytes, into req and
+// reports whether it holds an image, answering 400 if not.
+func decodeImageRequest(w http.ResponseWriter, r *http.Request, req interface{}, image *[]byte) bool {
+	// Base64 takes four bytes for every three of the photo; allow some more for the options.
+	r.Body = http.MaxBytesReader(w, r.Body, maxImageBytes/3*4+64<<10)
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil || len(*image) == 0 {
+		writeError(w, http.StatusBadRequest, "Invalid request. 'image' field is required and must be a base64-encoded photo of up to 8 MiB.")
+		return false
+	}
+	return true
+}
+
+// checkImage returns data as an image for a vision model if it is in one of imageTypes, and
+// answers 415 if not.
+func checkImage(w http.ResponseWriter, data []byte) (generation.Image, bool) {
+	img := generation.Image{MIMEType: http.DetectContentType(data), Data: data}
+	if !slices.Contains(imageTypes, img.MIMEType) {
+		writeError(w, http.StatusUnsupportedMediaType, "Unsupported image format; expected JPEG, PNG, GIF or WebP.")
+		return generation.Image{}, false
+	}
+	return img, true
+}
+
+// ImageImportRequest is the JSON payload of POST /recipes/import-image.
+type ImageImportRequest struct {
+	// Image is the base64-encoded photo or scan of a recipe card or cookbook page: a JPEG, PNG,
+	// GIF or WebP of up to 8 MiB.
+	Image []byte `json:"image"`
+	// Attribution credits the recipe's source. As for any imported recipe, it needs a license
+	// and a source URL or author (see model.Recipe.CheckAttribution).
+	Attribution *model.Attribution `json:"attribution"`
+}
+
+// importImageHandler handles POST /recipes/import-image: the recipe in a photographed recipe
+// card or cookbook page is transcribed by a vision model, cleaned up and checked for a title,
+// ingredients and steps, and added to the store pending review, as by POST /recipes/review. It
+// answers 201 with the stored recipe, and 422 if the photo holds no complete recipe. Imports
+// are audited as "recipe.import".
+func (s *Server) importImageHandler(w http.ResponseWriter, r *http.Request) {
+	store, ok := s.reviewStoreFor(w)
+	if !ok {
+		return
+	}
+	if s.ExtractRecipe == nil {
+		writeError(w, http.StatusNotImplemented, "Importing photos is not available")
+		return
+	}
+	var req ImageImportRequest
+	if !decodeImageRequest(w, r, &req, &req.Image) {
+		return
+	}
+	// The attribution is checked before the photo is sent anywhere.
+	credit := model.Recipe{Provenance: &model.Provenance{Origin: model.OriginImported}, Attribution: req.Attribution}
+	if err := credit.CheckAttribution(); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid 'attribution': "+err.Error()+".")
+		return
+	}
+	img, ok := checkImage(w, req.Image)
+	if !ok {
+		return
+	}
+
+	var source generation.Source
+	extracted, err := s.ExtractRecipe(generation.WithSource(r.Context(), &source), img)
+	if errors.Is(err, generation.ErrNoRecipe) {
+		writeError(w, http.StatusUnprocessableEntity, "No complete recipe was found in the photo: "+err.Error()+".")
+		return
+	}
+	if err != nil {
+		if r.Context().Err() != nil {
+			return
+		}
+		s.Logger.Printf("Could not transcribe photo: %v", err)
+		writeError(w, http.StatusBadGateway, "The photo could not be transcribed; retry later.")
+		return
+	}
+
+	rec := model.NewRecipe(extracted.Title, extracted.Ingredients, extracted.Steps, extracted.NutritionalInfo, extracted.AllergyDisclaimer, extracted.Appliances)
+	if d, err := model.ParseDifficulty(extracted.Difficulty); err == nil {
+		rec.Difficulty = d
+	}
+	rec.TotalTime, rec.Status, rec.Attribution = extracted.TotalTime, model.StatusPendingReview, req.Attribution
+	rec.Provenance = &model.Provenance{Origin: model.OriginImported, Source: "photo", Model: source.Model}
+	if rec, ok = s.addRecipe(w, store, rec); !ok {
+		return
+	}
+	s.audit(r, "recipe.import", rec.ID, nil, rec)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rec)
+}
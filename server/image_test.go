@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/generation"
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// TestImageResolveHandler verifies that the dish described from a photo is resolved with the
+// request's options, and that unusable photos are rejected.
+func TestImageResolveHandler(t *testing.T) {
+	srv := newTestServer()
+	png := []byte("\x89PNG\r\n\x1a\n not really a png")
+	var described generation.Image
+	srv.DescribeImage = func(ctx context.Context, img generation.Image) (string, error) {
+		described = img
+		if strings.Contains(string(img.Data), "empty plate") {
+			return "", generation.ErrNoDish
+		}
+		return "spaghetti bolognese", nil
+	}
+	post := func(req ImageResolveRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/resolve/image", strings.NewReader(string(body))))
+		return rr
+	}
+
+	rr := post(ImageResolveRequest{Image: png, ResolveRequest: ResolveRequest{Query: "ignored", Locale: "de-DE"}})
+	var resp ResolveResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %v", rr.Code, err)
+	}
+	if resp.Description != "spaghetti bolognese" || resp.PrimaryRecipe.Title != "Spaghetti Bolognese" {
+		t.Errorf("Expected the described dish to be resolved, got %q for %q", resp.PrimaryRecipe.Title, resp.Description)
+	}
+	if described.MIMEType != "image/png" || rr.Header().Get("Content-Language") != "de-DE" {
+		t.Errorf("Expected a PNG resolved for de-DE, got %q and %q", described.MIMEType, rr.Header().Get("Content-Language"))
+	}
+
+	tests := []struct {
+		name string
+		req  ImageResolveRequest
+		want int
+	}{
+		{"no image", ImageResolveRequest{ResolveRequest: ResolveRequest{Query: "pasta"}}, http.StatusBadRequest},
+		{"not an image", ImageResolveRequest{Image: []byte("just some text")}, http.StatusUnsupportedMediaType},
+		{"no dish", ImageResolveRequest{Image: append(png, "empty plate"...)}, http.StatusUnprocessableEntity},
+		{"bad option", ImageResolveRequest{Image: png, ResolveRequest: ResolveRequest{Count: 9}}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rr := post(tt.req); rr.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.name, tt.want, rr.Code)
+		}
+	}
+
+	srv.DescribeImage = func(ctx context.Context, img generation.Image) (string, error) {
+		return "", errors.New("provider down")
+	}
+	if rr := post(ImageResolveRequest{Image: png}); rr.Code != http.StatusBadGateway {
+		t.Errorf("Expected 502 when the photo cannot be described, got %d", rr.Code)
+	}
+}
+
+// TestImportImageHandler verifies that a transcribed recipe card is stored pending review with
+// its attribution, and that unattributed or incomplete imports are refused.
+func TestImportImageHandler(t *testing.T) {
+	srv := newTestServer()
+	jpeg := []byte("\xff\xd8\xff\xe0 not really a jpeg")
+	calls := 0
+	srv.ExtractRecipe = func(ctx context.Context, img generation.Image) (generation.Recipe, error) {
+		calls++
+		if strings.Contains(string(img.Data), "blurry") {
+			return generation.Recipe{}, fmt.Errorf("%w: no steps", generation.ErrNoRecipe)
+		}
+		return generation.Recipe{Title: "Grandma's Scones", Ingredients: []string{"flour", "butter"}, Steps: []string{"Bake."}, Difficulty: "Easy", TotalTime: 25}, nil
+	}
+	credit := &model.Attribution{Author: "Grandma", License: "family permission"}
+	post := func(req ImageImportRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/recipes/import-image", strings.NewReader(string(body))))
+		return rr
+	}
+
+	if rr := post(ImageImportRequest{Image: jpeg}); rr.Code != http.StatusBadRequest || calls != 0 {
+		t.Errorf("Expected an unattributed import to be refused before transcription, got %d after %d calls", rr.Code, calls)
+	}
+	if rr := post(ImageImportRequest{Image: append(jpeg, "blurry"...), Attribution: credit}); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for an incomplete recipe, got %d", rr.Code)
+	}
+
+	rr := post(ImageImportRequest{Image: jpeg, Attribution: credit})
+	var rec model.Recipe
+	if err := json.NewDecoder(rr.Body).Decode(&rec); err != nil || rr.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %v", rr.Code, err)
+	}
+	stored, ok := findRecipe(srv.Resolver.Store, rec.ID)
+	if !ok || stored.Status != model.StatusPendingReview || stored.Slug != "grandma-s-scones" || stored.Difficulty != model.DifficultyEasy {
+		t.Errorf("Expected the recipe stored pending review, got %+v", stored)
+	}
+	if p := stored.Provenance; p == nil || p.Origin != model.OriginImported || p.Source != "photo" || stored.Attribution.Author != "Grandma" {
+		t.Errorf("Expected imported provenance with attribution, got %+v %+v", p, stored.Attribution)
+	}
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// IngredientResponse is the JSON response of GET /ingredients/{name}.
+type IngredientResponse struct {
+	// Name is the taxonomy's name for the ingredient, e.g. "parmesan" for "Parmigiano Reggiano".
+	Name string `json:"name"`
+	// Ancestors are the groups enclosing the ingredient, nearest first.
+	Ancestors []string `json:"ancestors"`
+	Allergens []string `json:"allergens"`
+	// Substitutes are suggested replacements, best first, without the allergens listed in the
+	// request's avoid parameter.
+	Substitutes []string `json:"substitutes"`
+}
+
+// ingredientHandler handles GET /ingredients/{name}, describing an ingredient from the
+// taxonomy. The optional avoid query parameter is a comma-separated list of allergens to keep
+// out of the substitutes, e.g. "milk,tree nuts".
+func (s *Server) ingredientHandler(w http.ResponseWriter, r *http.Request) {
+	name, ok := s.Taxonomy.Lookup(r.PathValue("name"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "Unknown ingredient")
+		return
+	}
+	var avoid []string
+	for _, a := range strings.Split(r.URL.Query().Get("avoid"), ",") {
+		if a = strings.ToLower(strings.TrimSpace(a)); a != "" {
+			avoid = append(avoid, a)
+		}
+	}
+	resp := IngredientResponse{
+		Name:        name,
+		Ancestors:   append([]string{}, s.Taxonomy.Ancestors(name)...),
+		Allergens:   append([]string{}, s.Taxonomy.Allergens([]string{name})...),
+		Substitutes: append([]string{}, s.Taxonomy.Substitutes(name, avoid...)...),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/pageza/recipe-resolver-ms/schedule"
+)
+
+// JobsResponse is the JSON response of GET /admin/jobs.
+type JobsResponse struct {
+	Jobs []schedule.Status `json:"jobs"`
+}
+
+// jobsHandler handles GET /admin/jobs, reporting every scheduled job's schedule, run and failure
+// counts, last run and next run.
+func (s *Server) jobsHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Jobs == nil {
+		writeError(w, http.StatusNotImplemented, "No jobs are scheduled")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(JobsResponse{Jobs: s.Jobs.Status()})
+}
+
+// runJobHandler handles POST /admin/jobs/{name}/run, running a job now, outside its schedule.
+// It answers 202 Accepted once the run is queued; GET /admin/jobs reports how it went.
+func (s *Server) runJobHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Jobs == nil {
+		writeError(w, http.StatusNotImplemented, "No jobs are scheduled")
+		return
+	}
+	name := r.PathValue("name")
+	switch err := s.Jobs.Trigger(name); {
+	case errors.Is(err, schedule.ErrUnknownJob):
+		writeError(w, http.StatusNotFound, "No job named "+name)
+		return
+	case errors.Is(err, schedule.ErrRunning):
+		writeError(w, http.StatusConflict, "Job "+name+" is already running")
+		return
+	}
+	s.Logger.Printf("Admin: Triggered job %s", name)
+	s.audit(r, "job.run", name, nil, nil)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"job": name})
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/schedule"
+)
+
+// TestJobsHandlers verifies that scheduled jobs are listed and can be run on demand.
+func TestJobsHandlers(t *testing.T) {
+	srv := newTestServer()
+	do := func(method, path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+		return rr
+	}
+	if rr := do(http.MethodGet, "/admin/jobs"); rr.Code != http.StatusNotImplemented {
+		t.Errorf("Expected 501 without a scheduler, got %d", rr.Code)
+	}
+
+	ran := make(chan struct{}, 1)
+	srv.Jobs = schedule.New()
+	if err := srv.Jobs.Add("cleanup", "@daily", func(ctx context.Context) error {
+		ran <- struct{}{}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv.Jobs.Start(ctx)
+
+	if rr := do(http.MethodPost, "/admin/jobs/missing/run"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown job, got %d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/admin/jobs/cleanup/run"); rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", rr.Code)
+	}
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the job to run")
+	}
+
+	var resp JobsResponse
+	if err := json.NewDecoder(do(http.MethodGet, "/admin/jobs").Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Jobs) != 1 || resp.Jobs[0].Name != "cleanup" || resp.Jobs[0].Schedule != "@daily" {
+		t.Errorf("Expected the cleanup job to be listed, got %+v", resp.Jobs)
+	}
+}
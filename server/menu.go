@@ -0,0 +1,109 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/pageza/recipe-resolver-ms/intent"
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/qos"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+	"github.com/pageza/recipe-resolver-ms/shopping"
+)
+
+// MenuRequest is the JSON body of POST /resolve/menu.
+type MenuRequest struct {
+	// Query describes the occasion and its constraints, e.g. "vegetarian summer dinner party
+	// under 700 kcal". Nutrition and time limits in it apply to every course.
+	Query string `json:"query"`
+	// Courses lists the courses to serve, in order, from "starter", "main" and "dessert"; all
+	// three by default.
+	Courses []string `json:"courses,omitempty"`
+	// Locale optionally overrides the Accept-Language header, e.g. "de-DE".
+	Locale string `json:"locale,omitempty"`
+	// KidFriendly and Exclude apply to every course as they do in ResolveRequest.
+	KidFriendly bool     `json:"kid_friendly,omitempty"`
+	Exclude     []string `json:"exclude,omitempty"`
+}
+
+// MenuResponse is the JSON response of POST /resolve/menu.
+type MenuResponse struct {
+	Courses []MenuCourse `json:"courses"`
+	// ShoppingList combines the ingredients of every course.
+	ShoppingList []shopping.Item `json:"shopping_list"`
+	// Degraded is set when the service was in degraded mode and courses without a stored
+	// recipe got placeholders.
+	Degraded bool `json:"degraded,omitempty"`
+}
+
+// MenuCourse is a course of a MenuResponse.
+type MenuCourse struct {
+	Course string       `json:"course"`
+	Recipe model.Recipe `json:"recipe"`
+	// Match is "close" for a stored recipe, "generated" or "semantic" for a generated one, and
+	// "fallback" when generation failed.
+	Match resolver.MatchKind `json:"match"`
+}
+
+// menuHandler handles POST /resolve/menu, resolving a multi-course menu for an occasion: each
+// course is a fitting stored recipe or, failing that, generated to go with the others.
+func (s *Server) menuHandler(w http.ResponseWriter, r *http.Request) {
+	var req MenuRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Query) == "" {
+		writeError(w, http.StatusBadRequest, "Invalid request. 'query' field is required and must be a non-empty string.")
+		return
+	}
+	loc, ok := requestLocale(r, req.Locale)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid 'locale' field; expected a language tag such as 'en-US'.")
+		return
+	}
+	var courses []string
+	for _, c := range req.Courses {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if !slices.Contains(model.Courses, c) || slices.Contains(courses, c) {
+			writeError(w, http.StatusBadRequest, "Invalid 'courses' field; expected distinct courses from 'starter', 'main' and 'dessert'.")
+			return
+		}
+		courses = append(courses, c)
+	}
+	if len(courses) == 0 {
+		courses = model.Courses
+	}
+
+	in := intent.Extract(req.Query)
+	query := resolver.Query{Text: in.Text, Nutrition: in.Nutrition, MaxTotalTime: in.MaxTotalTime, KidFriendly: req.KidFriendly}
+	for _, name := range req.Exclude {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			query.Exclude = append(query.Exclude, name)
+		}
+	}
+	query.Tenant = s.tenantOf(r)
+	menu, err := s.Resolver.ResolveMenu(r.Context(), query, courses)
+	if errors.Is(err, qos.ErrBusy) {
+		writeBusy(w)
+		return
+	}
+	if err != nil {
+		s.Logger.Printf("Menu resolution aborted: %v", err)
+		return
+	}
+	resp := MenuResponse{Courses: make([]MenuCourse, len(menu))}
+	recipes := make([]model.Recipe, len(menu))
+	for i, c := range menu {
+		recipes[i] = render(c.Result.Primary, loc)
+		resp.Courses[i] = MenuCourse{Course: c.Course, Recipe: recipes[i], Match: c.Result.Match}
+		resp.Degraded = resp.Degraded || c.Result.Degraded
+	}
+	resp.ShoppingList = shopping.List(recipes)
+	if resp.ShoppingList == nil {
+		resp.ShoppingList = []shopping.Item{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := writeStream(w, resp); err != nil {
+		s.Logger.Printf("Error encoding response: %v", err)
+	}
+}
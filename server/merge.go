@@ -0,0 +1,148 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/completeness"
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+// MergeRequest is the JSON payload of POST /recipes/merge.
+type MergeRequest struct {
+	// Target is the ID of the recipe that is kept.
+	Target string `json:"target"`
+	// Source is the ID of the recipe merged into the target and removed.
+	Source string `json:"source"`
+	// Title, if set, replaces the target's title, e.g. with the source's.
+	Title string `json:"title,omitempty"`
+}
+
+// mergeRecipesHandler handles POST /recipes/merge, combining two near-duplicate recipes into
+// the target (see mergeRecipes) and removing the source as one unit of work. The source's ID
+// and slug keep resolving: GET /recipes/{id} and GET /recipes/slug/{slug} redirect to the
+// target. The merge is audited as "recipe.merge" with both recipes before and the merged one
+// after, and answers the merged recipe.
+func (s *Server) mergeRecipesHandler(w http.ResponseWriter, r *http.Request) {
+	store, ok := s.crudStoreFor(w)
+	if !ok {
+		return
+	}
+	var req MergeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	if req.Target == "" || req.Source == "" || req.Target == req.Source {
+		writeError(w, http.StatusBadRequest, "Two different recipes, 'target' and 'source', are required.")
+		return
+	}
+	target, ok := findRecipe(store, req.Target)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Target recipe not found")
+		return
+	}
+	source, ok := findRecipe(store, req.Source)
+	if !ok {
+		writeError(w, http.StatusNotFound, "Source recipe not found")
+		return
+	}
+
+	merged := mergeRecipes(target, source, strings.TrimSpace(req.Title), time.Now().UTC())
+	err := resolver.Atomically(r.Context(), store, func(tx resolver.Writer) error {
+		if !tx.Update(merged) || tx.Delete(source.ID) == 0 {
+			return errMergeConflict
+		}
+		return nil
+	})
+	if errors.Is(err, errMergeConflict) {
+		// One of them was deleted since it was read.
+		writeError(w, http.StatusNotFound, "Recipe not found")
+		return
+	} else if err != nil {
+		s.Logger.Printf("Error merging recipe %s into %s: %v", source.ID, target.ID, err)
+		writeError(w, http.StatusInternalServerError, "Could not merge the recipes")
+		return
+	}
+	merged, _ = findRecipe(store, merged.ID)
+	s.Logger.Printf("Merged recipe %s into %s", source.ID, merged.ID)
+	s.audit(r, "recipe.merge", merged.ID, []model.Recipe{target, source}, merged)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(merged)
+}
+
+// errMergeConflict aborts a merge whose recipes changed under it.
+var errMergeConflict = errors.New("recipe not found")
+
+// mergeRecipes returns target with source merged into it. The title is title if set, and the
+// target's otherwise. Tags, appliances and allergens are unioned. The target's nutrition, total
+// time, image, difficulty, disclaimer and attribution are kept unless it lacks them and the
+// source has them, and its ingredients and steps unless only the source's ingredients are
+// measured. The merged recipe keeps the earlier creation time, and records the source, and
+// whatever was merged into the source, in MergedFrom.
+func mergeRecipes(target, source model.Recipe, title string, now time.Time) model.Recipe {
+	merged := target
+	if title != "" {
+		merged.Title = title
+	}
+	missing, has := completeness.Of(target).Missing, completeness.Of(source).Missing
+	fills := func(aspect string) bool {
+		return slices.Contains(missing, aspect) && !slices.Contains(has, aspect)
+	}
+	if fills(completeness.Nutrition) {
+		merged.NutritionalInfo = source.NutritionalInfo
+	}
+	if fills(completeness.Time) {
+		merged.TotalTime = source.TotalTime
+	}
+	if fills(completeness.Image) {
+		merged.ImageURL = source.ImageURL
+	}
+	if fills(completeness.Ingredients) {
+		merged.Ingredients, merged.Steps, merged.SafetyWarnings = source.Ingredients, source.Steps, source.SafetyWarnings
+	}
+	if merged.Difficulty == "" {
+		merged.Difficulty = source.Difficulty
+	}
+	if merged.AllergyDisclaimer == "" {
+		merged.AllergyDisclaimer = source.AllergyDisclaimer
+	}
+	if merged.Attribution == nil {
+		merged.Attribution = source.Attribution
+	}
+	union := func(list, more []string) []string {
+		for _, v := range more {
+			if !slices.Contains(list, v) {
+				list = append(slices.Clip(list), v)
+			}
+		}
+		return list
+	}
+	merged.Tags = union(target.Tags, source.Tags)
+	merged.Appliances = union(target.Appliances, source.Appliances)
+	merged.Allergens = union(target.Allergens, source.Allergens)
+
+	if source.CreatedAt.Before(target.CreatedAt) {
+		merged.CreatedAt = source.CreatedAt
+	}
+	merged.UpdatedAt = now
+	merged.MergedFrom = append(slices.Clip(target.MergedFrom), model.MergedRecipe{
+		ID: source.ID, Slug: source.Slug, Title: source.Title, Provenance: source.ProvenanceOrCurated(), MergedAt: now,
+	})
+	merged.MergedFrom = append(merged.MergedFrom, source.MergedFrom...)
+	return merged
+}
+
+// redirectMerged redirects to url, keeping the request's query, since the recipe asked for was
+// merged into another.
+func redirectMerged(w http.ResponseWriter, r *http.Request, url string) {
+	if r.URL.RawQuery != "" {
+		url += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, url, http.StatusMovedPermanently)
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pageza/recipe-resolver-ms/cache"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+// MatchMetricsResponse is the JSON response of the /admin/metrics/matches endpoint.
+type MatchMetricsResponse struct {
+	// Threshold and NearMargin define the bands the queries were counted in.
+	Threshold  float64 `json:"threshold"`
+	NearMargin float64 `json:"near_margin"`
+	resolver.MatchStats
+}
+
+// matchMetricsHandler handles GET /admin/metrics/matches, reporting the distribution of best
+// similarity scores and the counts by match kind and threshold band.
+func (s *Server) matchMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	m := s.Resolver.Metrics
+	if m == nil {
+		writeError(w, http.StatusNotImplemented, "Match metrics are not enabled")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MatchMetricsResponse{Threshold: s.Resolver.CurrentThreshold(), NearMargin: m.NearMargin, MatchStats: m.Stats()})
+}
+
+// CacheMetricsResponse is the JSON response of the /admin/metrics/caches endpoint.
+type CacheMetricsResponse struct {
+	// Caches holds the statistics of each in-process cache by name, e.g. "results".
+	Caches map[string]cache.Stats `json:"caches"`
+}
+
+// cacheMetricsHandler handles GET /admin/metrics/caches, reporting the size, hits, misses and
+// evictions of the resolver's caches.
+func (s *Server) cacheMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CacheMetricsResponse{Caches: s.Resolver.CacheStats()})
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// nutritionBackfillHandler handles /admin/nutrition/backfill. POST starts a background run that
+// estimates nutrition for every stored recipe lacking structured nutritional info and answers
+// 202 with its initial progress, or 409 if a run is already in progress. GET reports the progress
+// of the current or most recent run.
+func (s *Server) nutritionBackfillHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Nutrition == nil {
+		writeError(w, http.StatusNotImplemented, "Nutrition backfill is not available for this recipe store")
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Nutrition.Progress())
+	case http.MethodPost:
+		// The run outlives the request, so it is not tied to the request's context.
+		progress, ok := s.Nutrition.Start(context.Background())
+		if !ok {
+			writeError(w, http.StatusConflict, "A nutrition backfill is already running")
+			return
+		}
+		s.Logger.Printf("Admin: Started nutrition backfill of %d recipes", progress.Total)
+		s.audit(r, "nutrition.backfill", "", nil, progress)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(progress)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/audit"
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/nutrition"
+)
+
+// TestNutritionBackfillHandler verifies that a backfill can be started and its progress polled.
+func TestNutritionBackfillHandler(t *testing.T) {
+	srv := newTestServer()
+	srv.Nutrition.Interval = 0
+	srv.Nutrition.Estimator = nutrition.EstimatorFunc(func(ctx context.Context, r model.Recipe) (map[string]float64, error) {
+		return map[string]float64{"calories": 300, "protein": 10, "carbohydrates": 40, "fat": 12}, nil
+	})
+
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/nutrition/backfill", nil))
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected HTTP status %d, got %d", http.StatusAccepted, rr.Code)
+	}
+
+	var progress nutrition.Progress
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		rr = httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/nutrition/backfill", nil))
+		if err := json.NewDecoder(rr.Body).Decode(&progress); err != nil {
+			t.Fatal(err)
+		}
+		if !progress.Running || time.Now().After(deadline) {
+			break
+		}
+	}
+	if progress.Running || progress.Total != 2 || progress.Updated != 2 {
+		t.Errorf("Expected both sample recipes to be backfilled, got %+v", progress)
+	}
+	for _, r := range srv.Resolver.Store.All() {
+		if !nutrition.Structured(r.NutritionalInfo) {
+			t.Errorf("Expected %q to have structured nutrition, got %v", r.Title, r.NutritionalInfo)
+		}
+	}
+	if entries := srv.Audit.List(audit.Filter{Action: "nutrition.backfill"}); len(entries) != 1 {
+		t.Errorf("Expected the backfill to be audited, got %+v", entries)
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/audit"
+	"github.com/pageza/recipe-resolver-ms/nlp"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+// Purge applies a retention policy: it removes recorded generations and audit entries older
+// than before, and returns how many of each were removed.
+func (s *Server) Purge(before time.Time) (generations, auditEntries int) {
+	if s.Resolver.Recent != nil {
+		generations = s.Resolver.Recent.DeleteFunc(func(g resolver.Generation) bool {
+			return g.GeneratedAt.Before(before)
+		})
+	}
+	if s.Audit != nil {
+		auditEntries = s.Audit.DeleteFunc(func(e audit.Entry) bool {
+			return e.Time.Before(before)
+		})
+	}
+	return generations, auditEntries
+}
+
+// EraseRequest is the JSON payload of the /admin/privacy/erase endpoint.
+type EraseRequest struct {
+	// Term is the personal detail to erase, e.g. a name or an e-mail address.
+	Term string `json:"term"`
+}
+
+// EraseResponse reports how many stored items an erasure removed.
+type EraseResponse struct {
+	Generations  int `json:"generations"`
+	CacheEntries int `json:"cache_entries"`
+	AuditEntries int `json:"audit_entries"`
+}
+
+// eraseHandler handles POST /admin/privacy/erase for data-subject deletion requests. Every
+// stored query, cached generation and audit entry mentioning the term (case-insensitively) is
+// removed. The erasure itself is audited without the term.
+func (s *Server) eraseHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	var req EraseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(strings.TrimSpace(req.Term)) < 2 {
+		writeError(w, http.StatusBadRequest, "Invalid request. 'term' field is required and must be at least 2 characters.")
+		return
+	}
+	term := strings.ToLower(strings.TrimSpace(req.Term))
+	mentions := func(s string) bool { return strings.Contains(strings.ToLower(s), term) }
+
+	var resp EraseResponse
+	if s.Resolver.Recent != nil {
+		resp.Generations = s.Resolver.Recent.DeleteFunc(func(g resolver.Generation) bool {
+			return mentions(g.Query)
+		})
+	}
+	// Cache keys are normalized queries, so the term is normalized the same way.
+	if key := strings.Join(nlp.Tokenize(term), " "); key != "" {
+		matches := func(k string) bool { return strings.Contains(k, key) }
+		if c, ok := s.Resolver.Cache.(interface {
+			DeleteFunc(func(key string) bool) int
+		}); ok {
+			resp.CacheEntries = c.DeleteFunc(matches)
+		}
+		if s.Resolver.Semantic != nil {
+			resp.CacheEntries += s.Resolver.Semantic.DeleteFunc(matches)
+		}
+	}
+	if s.Audit != nil {
+		resp.AuditEntries = s.Audit.DeleteFunc(func(e audit.Entry) bool {
+			return mentions(e.Actor) || mentions(e.Target) || mentions(string(e.Before)) || mentions(string(e.After))
+		})
+	}
+	s.Logger.Printf("Admin: Erased %d generations, %d cache entries and %d audit entries", resp.Generations, resp.CacheEntries, resp.AuditEntries)
+	s.audit(r, "privacy.erase", "", nil, resp)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(resp)
+}
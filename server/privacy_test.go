@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/audit"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+func newGeneratingServer(t *testing.T, queries ...string) *Server {
+	t.Helper()
+	r := resolver.New(resolver.NewMemoryStore(resolver.SampleRecipes()), fixedGenerator{})
+	r.Logger = log.New(io.Discard, "", 0)
+	for _, q := range queries {
+		if _, err := r.Resolve(context.Background(), resolver.Query{Text: q}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return New(r)
+}
+
+// TestEraseHandler verifies that an erasure removes every stored mention of the term.
+func TestEraseHandler(t *testing.T) {
+	srv := newGeneratingServer(t, "cake for my daughter Emma", "vegan brownies")
+	srv.Audit.Record(audit.NewEntry("ops", "recipe.update", "emma-cake", nil, nil))
+
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/privacy/erase", strings.NewReader(`{"term":"Emma"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected HTTP status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var resp EraseResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp != (EraseResponse{Generations: 1, CacheEntries: 1, AuditEntries: 1}) {
+		t.Errorf("Expected one of each to be erased, got %+v", resp)
+	}
+	if got := srv.Resolver.Recent.List(); len(got) != 1 || got[0].Query != "vegan brownies" {
+		t.Errorf("Expected only the unrelated generation to remain, got %+v", got)
+	}
+	// The erasure is audited, but without the erased term.
+	entries := srv.Audit.List(audit.Filter{Action: "privacy.erase"})
+	if len(entries) != 1 || strings.Contains(strings.ToLower(string(entries[0].After)), "emma") {
+		t.Errorf("Expected a privacy.erase entry without the term, got %+v", entries)
+	}
+
+	rr = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/privacy/erase", strings.NewReader(`{"term":" "}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status %d for a blank term, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	srv := newGeneratingServer(t, "mapo tofu")
+	old := audit.NewEntry("ops", "cache.flush", "", nil, nil)
+	old.Time = time.Now().Add(-48 * time.Hour)
+	srv.Audit.Record(old)
+	srv.Audit.Record(audit.NewEntry("ops", "cache.flush", "", nil, nil))
+
+	generations, entries := srv.Purge(time.Now().Add(-24 * time.Hour))
+	if generations != 0 || entries != 1 {
+		t.Errorf("Expected only the old audit entry to be purged, got %d generations and %d entries", generations, entries)
+	}
+	if generations, _ = srv.Purge(time.Now().Add(time.Minute)); generations != 1 {
+		t.Errorf("Expected the generation to be purged, got %d", generations)
+	}
+}
+
+// TestSemanticCache verifies that semantic cache hits are flagged in the response and that the
+// semantic cache is flushed and erased along with the exact one.
+func TestSemanticCache(t *testing.T) {
+	srv := newGeneratingServer(t)
+	srv.Resolver.Semantic = resolver.NewSemanticCache(resolver.DefaultSemanticThreshold)
+
+	var responses []ResolveResponse
+	for _, q := range []string{"vegan brownies", "Brownies, vegan"} {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(`{"query":"`+q+`"}`)))
+		var resp ResolveResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		responses = append(responses, resp)
+	}
+	if responses[0].SemanticCache != nil || responses[1].SemanticCache == nil || responses[1].PrimaryRecipe.ID != responses[0].PrimaryRecipe.ID {
+		t.Errorf("Expected only the second response to be a semantic cache hit, got %+v", responses)
+	}
+
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/privacy/erase", strings.NewReader(`{"term":"brownies"}`)))
+	var erased EraseResponse
+	if err := json.NewDecoder(rr.Body).Decode(&erased); err != nil {
+		t.Fatal(err)
+	}
+	if erased.CacheEntries != 2 {
+		t.Errorf("Expected the exact and semantic cache entries to be erased, got %+v", erased)
+	}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/auth"
+	"github.com/pageza/recipe-resolver-ms/qos"
+)
+
+// priority returns the class of r: batch if its principal is one of s.BatchPrincipals or its
+// X-Priority header says "batch", interactive otherwise. The header can only lower a
+// request's priority.
+func (s *Server) priority(r *http.Request) (qos.Priority, error) {
+	if p, ok := auth.PrincipalFrom(r.Context()); ok && s.BatchPrincipals[p.Name] {
+		return qos.Batch, nil
+	}
+	if v := r.Header.Get("X-Priority"); v != "" {
+		return qos.ParsePriority(v)
+	}
+	return qos.Interactive, nil
+}
+
+// limit wraps h so that, when s.Limiter is set, it only runs once the limiter admits the
+// request at its priority. Requests that wait too long, or batch requests finding the batch
+// queue full, are rejected with 429 if batch, so that importers back off, and 503 if
+// interactive.
+func (s *Server) limit(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.Limiter == nil {
+			h(w, r)
+			return
+		}
+		p, err := s.priority(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid X-Priority header; expected 'interactive' or 'batch'.")
+			return
+		}
+		release, err := s.Limiter.Acquire(r.Context(), p)
+		if err != nil {
+			if !errors.Is(err, qos.ErrOverloaded) {
+				// The client went away while queued.
+				return
+			}
+			s.Logger.Printf("Rejected %s %s request %s: %v", p, r.Method, r.URL.Path, err)
+			if p == qos.Batch {
+				w.Header().Set("Retry-After", "1")
+				writeError(w, http.StatusTooManyRequests, "Too many requests in progress; retry later.")
+			} else {
+				writeBusy(w)
+			}
+			return
+		}
+		defer release()
+		h(w, r)
+	}
+}
+
+// capped wraps mux so that requests to an endpoint with a gate in s.Concurrency are rejected
+// with 503 while the gate is full.
+func (s *Server) capped(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		if _, path, ok := strings.Cut(pattern, " "); ok {
+			pattern = path
+		}
+		release, ok := s.Concurrency[pattern].TryAcquire()
+		if !ok {
+			s.Logger.Printf("Rejected %s %s: %d requests to %s in progress", r.Method, r.URL.Path, s.Concurrency[pattern].InFlight(), pattern)
+			writeBusy(w)
+			return
+		}
+		defer release()
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// writeBusy rejects a request with 503 and asks the client to retry shortly.
+func writeBusy(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", "1")
+	writeError(w, http.StatusServiceUnavailable, "Too many requests in progress; retry later.")
+}
+
+// writeBudget sends what remains of a session's generation budget in response headers.
+func writeBudget(w http.ResponseWriter, left qos.Remaining) {
+	w.Header().Set("X-Budget-Remaining-Calls", strconv.Itoa(left.Calls))
+	w.Header().Set("X-Budget-Remaining-Tokens", strconv.Itoa(left.Tokens))
+}
+
+// writeBudgetSpent rejects a request of a session that spent its generation budget with 429,
+// asking the client to retry once it has refilled enough, after wait.
+func writeBudgetSpent(w http.ResponseWriter, left qos.Remaining, wait time.Duration) {
+	writeBudget(w, left)
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	writeError(w, http.StatusTooManyRequests, "This session's generation budget is spent; retry later.")
+}
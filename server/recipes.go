@@ -0,0 +1,471 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pageza/recipe-resolver-ms/auth"
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/renderer"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+// slugStore is implemented by recipe stores that support lookups by slug, such as
+// resolver.MemoryStore.
+type slugStore interface {
+	BySlug(slug string) (model.Recipe, bool)
+}
+
+// crudStore is implemented by recipe stores that can be managed through the /recipes
+// endpoints, such as resolver.MemoryStore.
+type crudStore interface {
+	All() []model.Recipe
+	resolver.Writer
+}
+
+// crudStoreFor returns the resolver's store if it supports writes, or answers 501.
+func (s *Server) crudStoreFor(w http.ResponseWriter) (crudStore, bool) {
+	store, ok := s.Resolver.Store.(crudStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "Managing recipes is not available for this recipe store")
+	}
+	return store, ok
+}
+
+// Page sizes of GET /recipes.
+const (
+	defaultRecipesLimit = 20
+	maxRecipesLimit     = 100
+)
+
+// RecipesResponse is the JSON response of GET /recipes.
+type RecipesResponse struct {
+	Recipes []model.Recipe `json:"recipes"`
+	// Total is the number of recipes matching the filters, across all pages.
+	Total int `json:"total"`
+	// Limit and Offset are those of the page returned.
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// validateRecipe returns why rec cannot be stored, or "" if it can: it needs a title,
+// ingredients and steps without blank entries, a known difficulty if any, a total time that is
+// not negative, and the attribution its provenance requires.
+func validateRecipe(rec model.Recipe) string {
+	blank := func(list []string) bool {
+		for _, item := range list {
+			if strings.TrimSpace(item) == "" {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case strings.TrimSpace(rec.Title) == "":
+		return "A non-empty 'title' is required."
+	case len(rec.Ingredients) == 0 || blank(rec.Ingredients):
+		return "Invalid 'ingredients' field; expected a list of non-empty ingredients."
+	case len(rec.Steps) == 0 || blank(rec.Steps):
+		return "Invalid 'steps' field; expected a list of non-empty steps."
+	case rec.Difficulty != "" && rec.Difficulty.Level() == 0:
+		return fmt.Sprintf("Invalid 'difficulty' field %q; expected 'easy', 'medium' or 'hard'.", rec.Difficulty)
+	case rec.TotalTime < 0:
+		return "Invalid 'total_time' field; expected a number of minutes."
+	}
+	if err := rec.CheckAttribution(); err != nil {
+		return "Invalid 'attribution': " + err.Error() + "."
+	}
+	return ""
+}
+
+// decodeRecipe reads and validates the recipe in the request body, or answers 400.
+func decodeRecipe(w http.ResponseWriter, r *http.Request) (model.Recipe, bool) {
+	var rec model.Recipe
+	if err := json.NewDecoder(r.Body).Decode(&rec); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return rec, false
+	}
+	if msg := validateRecipe(rec); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return rec, false
+	}
+	return rec, true
+}
+
+// curator reports whether the caller of r is authenticated as a curator or above, and so may see
+// recipes that are not served: pending review, rejected or archived.
+func curator(r *http.Request) bool {
+	p, ok := auth.PrincipalFrom(r.Context())
+	return ok && p.Role.Allows(auth.RoleCurator)
+}
+
+// listRecipesHandler handles GET /recipes, listing a page of the stored recipes in the order
+// they were added. The limit (20 by default, up to 100) and offset query parameters select the
+// page; appliance and tag, which may be repeated, keep the recipes using every appliance and
+// carrying every tag given, ignoring case. status, which may be repeated, keeps the recipes in
+// one of the review statuses given, and archived ("true" or "false") those archived or not.
+// Without either, curators list every recipe. Other callers only ever see the published,
+// unarchived ones; asking for any other status or for archived recipes is forbidden to them.
+func (s *Server) listRecipesHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	resp := RecipesResponse{Recipes: []model.Recipe{}, Limit: defaultRecipesLimit}
+	statuses := make(map[model.Status]bool)
+	for _, v := range q["status"] {
+		switch st := model.Status(strings.TrimSpace(v)); st {
+		case model.StatusPublished, model.StatusPendingReview, model.StatusRejected:
+			statuses[st] = true
+		default:
+			writeError(w, http.StatusBadRequest, "Invalid 'status' parameter; expected 'published', 'pending_review' or 'rejected'.")
+			return
+		}
+	}
+	archived := q.Get("archived")
+	if archived != "" && archived != "true" && archived != "false" {
+		writeError(w, http.StatusBadRequest, "Invalid 'archived' parameter; expected 'true' or 'false'.")
+		return
+	}
+	if !curator(r) {
+		if statuses[model.StatusPendingReview] || statuses[model.StatusRejected] || archived == "true" {
+			writeError(w, http.StatusForbidden, "Only curators may list unpublished or archived recipes.")
+			return
+		}
+		statuses[model.StatusPublished], archived = true, "false"
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxRecipesLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid 'limit' parameter; expected a number from 1 to %d.", maxRecipesLimit))
+			return
+		}
+		resp.Limit = limit
+	}
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			writeError(w, http.StatusBadRequest, "Invalid 'offset' parameter; expected a non-negative integer.")
+			return
+		}
+		resp.Offset = offset
+	}
+
+	stored, err := s.listStored(r, statuses, archived)
+	if err != nil {
+		s.Logger.Printf("Error listing recipes: %v", err)
+		writeError(w, http.StatusInternalServerError, "The recipes could not be listed; retry later.")
+		return
+	}
+	var matching []model.Recipe
+recipes:
+	for _, rec := range stored {
+		status := rec.Status
+		if rec.Published() {
+			status = model.StatusPublished
+		}
+		if len(statuses) > 0 && !statuses[status] || archived != "" && rec.Archived != (archived == "true") {
+			continue
+		}
+		for _, a := range q["appliance"] {
+			if !rec.UsesAppliance(a) {
+				continue recipes
+			}
+		}
+		for _, tag := range q["tag"] {
+			if !rec.HasTag(strings.TrimSpace(tag)) {
+				continue recipes
+			}
+		}
+		matching = append(matching, rec)
+	}
+	resp.Total = len(matching)
+	if resp.Offset < len(matching) {
+		resp.Recipes = append(resp.Recipes, matching[resp.Offset:min(resp.Offset+resp.Limit, len(matching))]...)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := writeStream(w, resp); err != nil {
+		s.Logger.Printf("Error encoding response: %v", err)
+	}
+}
+
+// listStored returns the stored recipes, narrowed down by the store to those in one of statuses
+// (all if empty) and, if archived is "false", unarchived if it is a resolver.Lister.
+func (s *Server) listStored(r *http.Request, statuses map[model.Status]bool, archived string) ([]model.Recipe, error) {
+	l, ok := s.Resolver.Store.(resolver.Lister)
+	if !ok {
+		return s.Resolver.Store.All(), nil
+	}
+	return l.List(r.Context(), resolver.Filter{Statuses: slices.Sorted(maps.Keys(statuses)), Unarchived: archived == "false"})
+}
+
+// SearchResponse is the JSON response of GET /recipes/search.
+type SearchResponse struct {
+	Recipes []model.Recipe `json:"recipes"`
+}
+
+// searchRecipesHandler handles GET /recipes/search, answering the stored recipes matching the
+// words of the q parameter, best first, as the store ranks them (see resolver.Searcher). limit
+// (20 by default, up to 100) caps their number. Curators search every recipe; other callers only
+// those the resolver serves. It answers 501 for stores that cannot search.
+func (s *Server) searchRecipesHandler(w http.ResponseWriter, r *http.Request) {
+	store, ok := s.Resolver.Store.(resolver.Searcher)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "Search is not available for this recipe store")
+		return
+	}
+	q := r.URL.Query()
+	text := strings.TrimSpace(q.Get("q"))
+	if text == "" {
+		writeError(w, http.StatusBadRequest, "Missing 'q' parameter.")
+		return
+	}
+	limit := defaultRecipesLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxRecipesLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid 'limit' parameter; expected a number from 1 to %d.", maxRecipesLimit))
+			return
+		}
+		limit = n
+	}
+
+	var recipes []model.Recipe
+	var err error
+	fs, filtered := store.(resolver.FilteredSearcher)
+	switch {
+	case curator(r):
+		recipes, err = store.Search(r.Context(), text, limit)
+	case filtered:
+		recipes, err = fs.SearchFiltered(r.Context(), text, limit, s.Resolver.ServableFilter())
+	default:
+		// Without the filter in the query, overfetch so that dropping the unservable recipes
+		// rarely leaves fewer than limit.
+		recipes, err = store.Search(r.Context(), text, 4*limit)
+		recipes = slices.DeleteFunc(recipes, func(rec model.Recipe) bool { return !s.Resolver.Servable(rec) })
+		recipes = recipes[:min(limit, len(recipes))]
+	}
+	if err != nil {
+		s.Logger.Printf("Error searching recipes: %v", err)
+		writeError(w, http.StatusInternalServerError, "The recipes could not be searched; retry later.")
+		return
+	}
+	if recipes == nil {
+		recipes = []model.Recipe{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := writeStream(w, SearchResponse{Recipes: recipes}); err != nil {
+		s.Logger.Printf("Error encoding response: %v", err)
+	}
+}
+
+// createRecipeHandler handles POST /recipes, storing a published recipe and answering 201 with
+// it, under a new ID and a slug derived from its title unless it sets an untaken one. Recipes
+// without provenance are curated. It answers 409 or 500 if the store did not add the recipe
+// (see addRecipe). It is audited as "recipe.create".
+func (s *Server) createRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	store, ok := s.crudStoreFor(w)
+	if !ok {
+		return
+	}
+	rec, ok := decodeRecipe(w, r)
+	if !ok {
+		return
+	}
+	now := time.Now().UTC()
+	rec.ID, rec.Status, rec.ReviewNote = uuid.New().String(), model.StatusPublished, ""
+	rec.CreatedAt, rec.UpdatedAt = now, now
+	if rec, ok = s.addRecipe(w, store, rec); !ok {
+		return
+	}
+	s.Logger.Printf("Created recipe %s", rec.ID)
+	s.audit(r, "recipe.create", rec.ID, nil, rec)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/recipes/"+rec.ID)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rec)
+}
+
+// getRecipeHandler handles GET /recipes/{id}, returning the stored recipe formatted for the
+// Accept-Language header, in the format asked for (see negotiate). The ID of a recipe merged
+// into another redirects to it. Recipes the resolver would not serve, such as archived ones or
+// those pending review, are only found by curators.
+func (s *Server) getRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	rec, ok := findRecipe(s.Resolver.Store, r.PathValue("id"))
+	if !ok || !curator(r) && !s.Resolver.Servable(rec) {
+		if merged, ok := resolver.MergedInto(s.Resolver.Store, r.PathValue("id"), ""); ok {
+			redirectMerged(w, r, "/recipes/"+merged.ID)
+			return
+		}
+		writeError(w, http.StatusNotFound, "Recipe not found")
+		return
+	}
+	rd, ok := s.negotiate(w, r)
+	if !ok {
+		return
+	}
+	loc, _ := requestLocale(r, "")
+	rec = render(rec, loc)
+	w.Header().Set("Content-Language", loc.Tag)
+	s.respond(w, rd, renderer.Document{Recipes: []model.Recipe{rec}, Value: rec})
+}
+
+// updateRecipeHandler handles PUT /recipes/{id}, replacing the content of a stored recipe. Its
+// ID, creation time, review status, archiving and, unless the body sets them, provenance and
+// slug are kept. It is audited as "recipe.update" with the recipe before and after.
+func (s *Server) updateRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	store, ok := s.crudStoreFor(w)
+	if !ok {
+		return
+	}
+	before, ok := findRecipe(store, r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "Recipe not found")
+		return
+	}
+	after, ok := decodeRecipe(w, r)
+	if !ok {
+		return
+	}
+	after.ID, after.CreatedAt, after.UpdatedAt = before.ID, before.CreatedAt, time.Now().UTC()
+	after.Status, after.ReviewNote, after.Archived = before.Status, before.ReviewNote, before.Archived
+	if after.Provenance == nil {
+		after.Provenance = before.Provenance
+	}
+	if after.Slug == "" {
+		after.Slug = before.Slug
+	}
+	if !store.Update(after) {
+		// Deleted since it was read.
+		writeError(w, http.StatusNotFound, "Recipe not found")
+		return
+	}
+	after, _ = findRecipe(store, after.ID)
+	s.Logger.Printf("Updated recipe %s", after.ID)
+	s.audit(r, "recipe.update", after.ID, before, after)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(after)
+}
+
+// deleteRecipeHandler handles DELETE /recipes/{id}, answering 204 once the recipe is removed.
+// It is audited as "recipe.delete" with the recipe removed.
+func (s *Server) deleteRecipeHandler(w http.ResponseWriter, r *http.Request) {
+	store, ok := s.crudStoreFor(w)
+	if !ok {
+		return
+	}
+	before, ok := findRecipe(store, r.PathValue("id"))
+	if !ok || store.Delete(before.ID) == 0 {
+		writeError(w, http.StatusNotFound, "Recipe not found")
+		return
+	}
+	s.Logger.Printf("Deleted recipe %s", before.ID)
+	s.audit(r, "recipe.delete", before.ID, before, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// batchStore is implemented by recipe stores that support bulk deletion and archiving, such as
+// resolver.MemoryStore.
+type batchStore interface {
+	All() []model.Recipe
+	Delete(ids ...string) int
+	Archive(archived bool, ids ...string) int
+}
+
+// BatchDeleteRequest is the JSON payload of POST /recipes/batch-delete.
+type BatchDeleteRequest struct {
+	// Filter selects the recipes; it must have at least one criterion.
+	Filter model.Filter `json:"filter"`
+	// Archive archives the recipes instead of deleting them.
+	Archive bool `json:"archive,omitempty"`
+	// DryRun only reports the recipes that would be affected.
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// BatchDeleteResponse is the JSON response of POST /recipes/batch-delete.
+type BatchDeleteResponse struct {
+	// Action is "delete" or "archive".
+	Action string `json:"action"`
+	DryRun bool   `json:"dry_run"`
+	// Matched lists the IDs of the recipes matching the filter.
+	Matched []string `json:"matched"`
+	// Affected is the number of recipes deleted or newly archived; 0 for dry runs.
+	Affected int `json:"affected"`
+}
+
+// batchDeleteHandler handles POST /recipes/batch-delete, deleting or archiving every stored
+// recipe matching a filter. A filter without criteria is rejected, so the corpus cannot be
+// wiped by accident; dry runs preview the matches. Changes are audited as
+// "recipe.batch_delete" or "recipe.batch_archive".
+func (s *Server) batchDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	store, ok := s.Resolver.Store.(batchStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "Bulk operations are not available for this recipe store")
+		return
+	}
+	var req BatchDeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	if req.Filter.IsZero() {
+		writeError(w, http.StatusBadRequest, "A filter with at least one criterion, such as 'ids', is required.")
+		return
+	}
+
+	resp := BatchDeleteResponse{Action: "delete", DryRun: req.DryRun, Matched: []string{}}
+	if req.Archive {
+		resp.Action = "archive"
+	}
+	for _, rec := range store.All() {
+		if req.Filter.Match(rec) {
+			resp.Matched = append(resp.Matched, rec.ID)
+		}
+	}
+	if !req.DryRun && len(resp.Matched) > 0 {
+		if req.Archive {
+			resp.Affected = store.Archive(true, resp.Matched...)
+		} else {
+			resp.Affected = store.Delete(resp.Matched...)
+		}
+		s.Logger.Printf("Bulk %s of %d recipes", resp.Action, resp.Affected)
+		s.audit(r, "recipe.batch_"+resp.Action, "", req.Filter, resp)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := writeStream(w, resp); err != nil {
+		s.Logger.Printf("Error encoding response: %v", err)
+	}
+}
+
+// recipeBySlugHandler handles GET /recipes/slug/{slug}, returning the stored recipe with that
+// slug formatted for the Accept-Language header, in the format asked for (see negotiate). The
+// slug of a recipe merged into another redirects to its slug. As with getRecipeHandler, only
+// curators find recipes the resolver would not serve.
+func (s *Server) recipeBySlugHandler(w http.ResponseWriter, r *http.Request) {
+	store, ok := s.Resolver.Store.(slugStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "Slug lookups are not available for this recipe store")
+		return
+	}
+	rec, ok := store.BySlug(r.PathValue("slug"))
+	if !ok || !curator(r) && !s.Resolver.Servable(rec) {
+		if merged, ok := resolver.MergedInto(s.Resolver.Store, "", r.PathValue("slug")); ok && merged.Slug != "" {
+			redirectMerged(w, r, "/recipes/slug/"+merged.Slug)
+			return
+		}
+		writeError(w, http.StatusNotFound, "Recipe not found")
+		return
+	}
+	rd, ok := s.negotiate(w, r)
+	if !ok {
+		return
+	}
+	loc, _ := requestLocale(r, "")
+	rec = render(rec, loc)
+	w.Header().Set("Content-Language", loc.Tag)
+	s.respond(w, rd, renderer.Document{Recipes: []model.Recipe{rec}, Value: rec})
+}
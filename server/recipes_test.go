@@ -0,0 +1,498 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/audit"
+	"github.com/pageza/recipe-resolver-ms/auth"
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+func TestRecipeBySlug(t *testing.T) {
+	srv := newTestServer()
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"/recipes/slug/chicken-salad", http.StatusOK},
+		{"/recipes/slug/unknown", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rr.Code != tt.want {
+			t.Errorf("GET %s: expected HTTP status %d, got %d", tt.path, tt.want, rr.Code)
+			continue
+		}
+		if tt.want != http.StatusOK {
+			continue
+		}
+		var rec model.Recipe
+		if err := json.NewDecoder(rr.Body).Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Title != "Chicken Salad" || rec.Slug != "chicken-salad" {
+			t.Errorf("Unexpected recipe %+v", rec)
+		}
+	}
+}
+
+// TestGetRecipeNotServable verifies that recipes the resolver would not serve are found by ID
+// or slug only by curators.
+func TestGetRecipeNotServable(t *testing.T) {
+	srv := newTestServer()
+	store := srv.Resolver.Store.(*resolver.MemoryStore)
+	pending := model.NewRecipe("Pending Pie", nil, nil, nil, "", nil)
+	pending.Status = model.StatusPendingReview
+	archived := model.NewRecipe("Archived Aspic", nil, nil, nil, "", nil)
+	archived.Archived = true
+	store.Add(pending, archived)
+	srv.Auth = auth.APIKeys{
+		"reader-key":  {Name: "web", Role: auth.RoleReader},
+		"curator-key": {Name: "editor", Role: auth.RoleCurator},
+	}
+
+	tests := []struct {
+		key, path string
+		want      int
+	}{
+		{"reader-key", "/recipes/" + pending.ID, http.StatusNotFound},
+		{"reader-key", "/recipes/slug/pending-pie", http.StatusNotFound},
+		{"reader-key", "/recipes/" + archived.ID, http.StatusNotFound},
+		{"reader-key", "/recipes/slug/archived-aspic", http.StatusNotFound},
+		{"reader-key", "/recipes/slug/chicken-salad", http.StatusOK},
+		{"curator-key", "/recipes/" + pending.ID, http.StatusOK},
+		{"curator-key", "/recipes/slug/archived-aspic", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tt.key)
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s GET %s: expected HTTP status %d, got %d", tt.key, tt.path, tt.want, rr.Code)
+		}
+	}
+}
+
+// TestSearchRecipes verifies that GET /recipes/search answers the matching recipes, only the
+// servable ones unless the caller is a curator, and validates its parameters.
+func TestSearchRecipes(t *testing.T) {
+	srv := newTestServer()
+	pending := model.NewRecipe("Chicken Pie", []string{"chicken"}, nil, nil, "", nil)
+	pending.Status = model.StatusPendingReview
+	srv.Resolver.Store.(*resolver.MemoryStore).Add(pending)
+	srv.Auth = auth.APIKeys{
+		"reader-key":  {Name: "web", Role: auth.RoleReader},
+		"curator-key": {Name: "editor", Role: auth.RoleCurator},
+	}
+	search := func(key, query string) (int, []string) {
+		req := httptest.NewRequest(http.MethodGet, "/recipes/search?"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		var resp SearchResponse
+		json.NewDecoder(rr.Body).Decode(&resp)
+		var got []string
+		for _, rec := range resp.Recipes {
+			got = append(got, rec.Title)
+		}
+		return rr.Code, got
+	}
+
+	if code, got := search("reader-key", "q=chicken"); code != http.StatusOK || strings.Join(got, ",") != "Chicken Salad" {
+		t.Errorf("Expected only the published chicken recipe, got %d %v", code, got)
+	}
+	if code, got := search("curator-key", "q=chicken"); code != http.StatusOK || len(got) != 2 {
+		t.Errorf("Expected curators to find both chicken recipes, got %d %v", code, got)
+	}
+	if code, got := search("curator-key", "q=chicken&limit=1"); code != http.StatusOK || len(got) != 1 {
+		t.Errorf("Expected one recipe, got %d %v", code, got)
+	}
+	for _, query := range []string{"", "q=chicken&limit=0", "q=chicken&limit=101"} {
+		if code, _ := search("reader-key", query); code != http.StatusBadRequest {
+			t.Errorf("%q: expected HTTP status 400, got %d", query, code)
+		}
+	}
+}
+
+func TestIngredient(t *testing.T) {
+	srv := newTestServer()
+	get := func(path string) (int, IngredientResponse) {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		var resp IngredientResponse
+		json.NewDecoder(rr.Body).Decode(&resp)
+		return rr.Code, resp
+	}
+
+	code, resp := get("/ingredients/Pecorino%20Romano")
+	if code != http.StatusOK || resp.Name != "pecorino" || len(resp.Ancestors) != 3 || len(resp.Allergens) != 1 {
+		t.Errorf("Unexpected response %d %+v", code, resp)
+	}
+	if _, resp := get("/ingredients/milk?avoid=soy,%20tree%20nuts"); len(resp.Substitutes) != 1 || resp.Substitutes[0] != "oat milk" {
+		t.Errorf("Expected only oat milk without soy and tree nuts, got %v", resp.Substitutes)
+	}
+	if code, _ := get("/ingredients/salt"); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown ingredient, got %d", code)
+	}
+}
+
+// TestBatchDelete verifies dry runs, archiving, deletion and the required filter.
+func TestBatchDelete(t *testing.T) {
+	srv := newTestServer()
+	post := func(body string) (int, BatchDeleteResponse) {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/recipes/batch-delete", strings.NewReader(body)))
+		var resp BatchDeleteResponse
+		json.NewDecoder(rr.Body).Decode(&resp)
+		return rr.Code, resp
+	}
+
+	if code, _ := post(`{"filter":{}}`); code != http.StatusBadRequest {
+		t.Errorf("Expected an empty filter to be rejected, got %d", code)
+	}
+	code, resp := post(`{"filter":{"title_contains":"salad"},"dry_run":true}`)
+	if code != http.StatusOK || len(resp.Matched) != 1 || resp.Affected != 0 || len(srv.Resolver.Store.All()) != 2 {
+		t.Errorf("Expected a dry run to match one recipe and change nothing, got %d %+v", code, resp)
+	}
+	if _, resp := post(`{"filter":{"title_contains":"salad"},"archive":true}`); resp.Action != "archive" || resp.Affected != 1 {
+		t.Errorf("Expected one recipe to be archived, got %+v", resp)
+	}
+	if _, resp := post(`{"filter":{"title_contains":"bolognese"}}`); resp.Action != "delete" || resp.Affected != 1 || len(srv.Resolver.Store.All()) != 1 {
+		t.Errorf("Expected one recipe to be deleted, got %+v", resp)
+	}
+	if entries := srv.Audit.List(audit.Filter{}); len(entries) != 2 || entries[0].Action != "recipe.batch_delete" {
+		t.Errorf("Expected the archive and the deletion to be audited, got %+v", entries)
+	}
+}
+
+// TestRecipeCRUD verifies creating, reading, replacing and deleting recipes through /recipes,
+// with validation, 404s and auditing.
+func TestRecipeCRUD(t *testing.T) {
+	srv := newTestServer()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+
+	for _, body := range []string{
+		`{"title":"","ingredients":["eggs"],"steps":["Boil."]}`,
+		`{"title":"Eggs","ingredients":[" "],"steps":["Boil."]}`,
+		`{"title":"Eggs","ingredients":["eggs"]}`,
+		`{"title":"Eggs","ingredients":["eggs"],"steps":["Boil."],"difficulty":"trivial"}`,
+		`{"title":"Eggs","ingredients":["eggs"],"steps":["Boil."],"provenance":{"origin":"imported"}}`,
+		`{"title":`,
+	} {
+		if rr := do(http.MethodPost, "/recipes", body); rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), `"error"`) {
+			t.Errorf("Expected %s to be rejected with a JSON error, got %d %s", body, rr.Code, rr.Body)
+		}
+	}
+
+	rr := do(http.MethodPost, "/recipes", `{"id":"mine","title":"Boiled Eggs","ingredients":["eggs"],"steps":["Boil for 8 minutes."],"status":"rejected"}`)
+	var created model.Recipe
+	json.NewDecoder(rr.Body).Decode(&created)
+	if rr.Code != http.StatusCreated || created.ID == "mine" || created.Slug != "boiled-eggs" || !created.Published() || rr.Header().Get("Location") != "/recipes/"+created.ID {
+		t.Fatalf("Expected the recipe created under a new ID, got %d %+v", rr.Code, created)
+	}
+	if rr := do(http.MethodGet, "/recipes", ""); !strings.Contains(rr.Body.String(), created.ID) {
+		t.Errorf("Expected the list to include the new recipe, got %s", rr.Body)
+	}
+	if rr := do(http.MethodGet, "/recipes/"+created.ID, ""); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Boiled Eggs") {
+		t.Errorf("Expected the new recipe, got %d %s", rr.Code, rr.Body)
+	}
+
+	rr = do(http.MethodPut, "/recipes/"+created.ID, `{"title":"Soft Boiled Eggs","ingredients":["eggs","salt"],"steps":["Boil for 6 minutes."]}`)
+	var updated model.Recipe
+	json.NewDecoder(rr.Body).Decode(&updated)
+	if rr.Code != http.StatusOK || updated.Title != "Soft Boiled Eggs" || updated.Slug != "boiled-eggs" || !updated.CreatedAt.Equal(created.CreatedAt) {
+		t.Errorf("Expected the recipe replaced under its slug, got %d %+v", rr.Code, updated)
+	}
+	if rr := do(http.MethodPut, "/recipes/"+created.ID, `{"title":"Eggs"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid replacement to be rejected, got %d", rr.Code)
+	}
+
+	if rr := do(http.MethodDelete, "/recipes/"+created.ID, ""); rr.Code != http.StatusNoContent {
+		t.Errorf("Expected the recipe deleted, got %d", rr.Code)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+		if rr := do(method, "/recipes/"+created.ID, `{"title":"Eggs","ingredients":["eggs"],"steps":["Boil."]}`); rr.Code != http.StatusNotFound {
+			t.Errorf("Expected %s of a deleted recipe to answer 404, got %d", method, rr.Code)
+		}
+	}
+	entries := srv.Audit.List(audit.Filter{Target: created.ID})
+	if len(entries) != 3 || entries[0].Action != "recipe.delete" || entries[2].Action != "recipe.create" {
+		t.Errorf("Expected the creation, update and deletion audited, got %+v", entries)
+	}
+}
+
+// failingAddStore is a MemoryStore whose Add fails with err.
+type failingAddStore struct {
+	*resolver.MemoryStore
+	err error
+}
+
+func (s failingAddStore) Add(recipes ...model.Recipe) error { return s.err }
+
+// TestCreateRecipeNotStored verifies that POST /recipes answers 409 or 500 rather than 201 when
+// the store did not add the recipe.
+func TestCreateRecipeNotStored(t *testing.T) {
+	body := `{"title":"Boiled Eggs","ingredients":["eggs"],"steps":["Boil for 8 minutes."]}`
+	for _, tc := range []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("adding recipe x: %w", resolver.ErrExists), http.StatusConflict},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	} {
+		srv := newTestServer()
+		srv.Resolver.Store = failingAddStore{resolver.NewMemoryStore(nil), tc.err}
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/recipes", strings.NewReader(body)))
+		if rr.Code != tc.want || !strings.Contains(rr.Body.String(), `"error"`) {
+			t.Errorf("Expected %d for %v, got %d %s", tc.want, tc.err, rr.Code, rr.Body)
+		}
+		if entries := srv.Audit.List(audit.Filter{Action: "recipe.create"}); len(entries) != 0 {
+			t.Errorf("Expected nothing audited for %v, got %+v", tc.err, entries)
+		}
+	}
+}
+
+// TestListRecipes verifies that GET /recipes pages through the recipes matching its filters,
+// reporting how many there are in all.
+func TestListRecipes(t *testing.T) {
+	srv := newTestServer()
+	store := srv.Resolver.Store.(*resolver.MemoryStore)
+	for _, title := range []string{"Grilled Corn", "Grilled Peaches", "Grilled Halloumi"} {
+		rec := model.NewRecipe(title, nil, nil, nil, "", []string{"Grill"})
+		rec.Tags = []string{"summer"}
+		store.Add(rec)
+	}
+	list := func(target string) (*httptest.ResponseRecorder, RecipesResponse) {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		var resp RecipesResponse
+		json.NewDecoder(rr.Body).Decode(&resp)
+		return rr, resp
+	}
+
+	if _, resp := list("/recipes"); resp.Total != 5 || len(resp.Recipes) != 5 || resp.Limit != 20 || resp.Offset != 0 {
+		t.Errorf("Expected all five recipes on one page, got %+v", resp)
+	}
+	_, resp := list("/recipes?appliance=grill&limit=2&offset=1")
+	if resp.Total != 4 || len(resp.Recipes) != 2 || resp.Recipes[0].Title != "Grilled Corn" || resp.Recipes[1].Title != "Grilled Peaches" {
+		t.Errorf("Expected the second page of four grill recipes, got %+v", resp)
+	}
+	if _, resp := list("/recipes?appliance=grill&tag=SUMMER&offset=3"); resp.Total != 3 || len(resp.Recipes) != 0 {
+		t.Errorf("Expected an empty page past the three summer grill recipes, got %+v", resp)
+	}
+	for _, target := range []string{"/recipes?limit=0", "/recipes?limit=101", "/recipes?offset=-1", "/recipes?status=draft", "/recipes?archived=yes"} {
+		if rr, _ := list(target); rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected HTTP status %d, got %d", target, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
+
+// TestListRecipesVisibility verifies that GET /recipes lists only the published, unarchived
+// recipes unless the caller is a curator, and that other callers may not filter their way to
+// the rest.
+func TestListRecipesVisibility(t *testing.T) {
+	srv := newTestServer()
+	store := srv.Resolver.Store.(*resolver.MemoryStore)
+	pending := model.NewRecipe("Pending Pie", nil, nil, nil, "", nil)
+	pending.Status = model.StatusPendingReview
+	archived := model.NewRecipe("Archived Aspic", nil, nil, nil, "", nil)
+	archived.Archived = true
+	store.Add(pending, archived)
+	srv.Auth = auth.APIKeys{
+		"reader-key":  {Name: "web", Role: auth.RoleReader},
+		"curator-key": {Name: "editor", Role: auth.RoleCurator},
+	}
+	list := func(key, target string) []string {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK {
+			return []string{strconv.Itoa(rr.Code)}
+		}
+		var resp RecipesResponse
+		json.NewDecoder(rr.Body).Decode(&resp)
+		var titles []string
+		for _, rec := range resp.Recipes {
+			titles = append(titles, rec.Title)
+		}
+		return titles
+	}
+
+	tests := []struct {
+		key, target string
+		want        []string
+	}{
+		{"reader-key", "/recipes", []string{"Spaghetti Bolognese", "Chicken Salad"}},
+		{"curator-key", "/recipes", []string{"Spaghetti Bolognese", "Chicken Salad", "Pending Pie", "Archived Aspic"}},
+		{"reader-key", "/recipes?status=pending_review", []string{"403"}},
+		{"reader-key", "/recipes?status=published&status=rejected", []string{"403"}},
+		{"reader-key", "/recipes?archived=true", []string{"403"}},
+		{"reader-key", "/recipes?archived=false", []string{"Spaghetti Bolognese", "Chicken Salad"}},
+		{"curator-key", "/recipes?status=pending_review", []string{"Pending Pie"}},
+		{"curator-key", "/recipes?archived=true", []string{"Archived Aspic"}},
+		{"curator-key", "/recipes?status=published&archived=false", []string{"Spaghetti Bolognese", "Chicken Salad"}},
+	}
+	for _, tt := range tests {
+		if got := list(tt.key, tt.target); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s %s: expected %q, got %q", tt.key, tt.target, tt.want, got)
+		}
+	}
+}
+
+func TestExportRecipes(t *testing.T) {
+	srv := newTestServer()
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr
+	}
+
+	rr := get("/recipes/export")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("Expected NDJSON, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	dec := json.NewDecoder(rr.Body)
+	var titles []string
+	for dec.More() {
+		var rec model.Recipe
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		titles = append(titles, rec.Title)
+	}
+	if strings.Join(titles, "|") != "Spaghetti Bolognese|Chicken Salad" {
+		t.Errorf("Expected every recipe on its own line, got %v", titles)
+	}
+
+	rr = get("/recipes/export?format=csv")
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("Expected CSV, got %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	rows, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || strings.Join(rows[0], ",") != strings.Join(exportColumns, ",") {
+		t.Fatalf("Expected a header and 2 rows, got %v", rows)
+	}
+	var ingredients []string
+	if err := json.Unmarshal([]byte(rows[2][3]), &ingredients); err != nil || len(ingredients) == 0 {
+		t.Errorf("Expected the ingredients as a JSON list, got %q", rows[2][3])
+	}
+	if rows[2][1] != "Chicken Salad" {
+		t.Errorf("Unexpected title %q", rows[2][1])
+	}
+
+	if rr := get("/recipes/export?format=xml"); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown format, got %d", rr.Code)
+	}
+}
+
+// TestMergeRecipes verifies that POST /recipes/merge combines two recipes into the target and
+// that the source's ID and slug redirect to it.
+func TestMergeRecipes(t *testing.T) {
+	srv := newTestServer()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+	create := func(body string) model.Recipe {
+		var rec model.Recipe
+		if rr := do(http.MethodPost, "/recipes", body); rr.Code != http.StatusCreated {
+			t.Fatalf("Expected the recipe created, got %d %s", rr.Code, rr.Body)
+		} else {
+			json.NewDecoder(rr.Body).Decode(&rec)
+		}
+		return rec
+	}
+	target := create(`{"title":"Boiled Eggs","ingredients":["eggs"],"steps":["Boil."],"tags":["breakfast"]}`)
+	source := create(`{"title":"Easy Boiled Eggs","ingredients":["2 eggs"],"steps":["Boil for 8 minutes."],"total_time":10,"tags":["quick","breakfast"]}`)
+
+	for body, want := range map[string]int{
+		`{"target":"` + target.ID + `","source":"` + target.ID + `"}`: http.StatusBadRequest,
+		`{"target":"` + target.ID + `"}`:                              http.StatusBadRequest,
+		`{"target":"` + target.ID + `","source":"unknown"}`:           http.StatusNotFound,
+	} {
+		if rr := do(http.MethodPost, "/recipes/merge", body); rr.Code != want {
+			t.Errorf("Expected %s to answer %d, got %d", body, want, rr.Code)
+		}
+	}
+
+	rr := do(http.MethodPost, "/recipes/merge", `{"target":"`+target.ID+`","source":"`+source.ID+`"}`)
+	var merged model.Recipe
+	json.NewDecoder(rr.Body).Decode(&merged)
+	if rr.Code != http.StatusOK || merged.ID != target.ID || merged.Title != "Boiled Eggs" || merged.Slug != "boiled-eggs" {
+		t.Fatalf("Expected the target kept, got %d %+v", rr.Code, merged)
+	}
+	if merged.Ingredients[0] != "2 eggs" || merged.TotalTime != 10 || strings.Join(merged.Tags, ",") != "breakfast,quick" {
+		t.Errorf("Expected the structured fields and tags merged, got %+v", merged)
+	}
+	if len(merged.MergedFrom) != 1 || merged.MergedFrom[0].ID != source.ID || merged.MergedFrom[0].Provenance == nil {
+		t.Errorf("Expected the source recorded with its provenance, got %+v", merged.MergedFrom)
+	}
+
+	for path, want := range map[string]string{
+		"/recipes/" + source.ID + "?lang=en": "/recipes/" + target.ID + "?lang=en",
+		"/recipes/slug/" + source.Slug:       "/recipes/slug/boiled-eggs",
+	} {
+		if rr := do(http.MethodGet, path, ""); rr.Code != http.StatusMovedPermanently || rr.Header().Get("Location") != want {
+			t.Errorf("Expected GET %s to redirect to %s, got %d %q", path, want, rr.Code, rr.Header().Get("Location"))
+		}
+	}
+	if rr := do(http.MethodPost, "/recipes/merge", `{"target":"`+target.ID+`","source":"`+source.ID+`"}`); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected merging a merged recipe again to answer 404, got %d", rr.Code)
+	}
+	if entries := srv.Audit.List(audit.Filter{Action: "recipe.merge"}); len(entries) != 1 || entries[0].Target != target.ID {
+		t.Errorf("Expected the merge audited, got %+v", entries)
+	}
+}
+
+// failingUnits is a MemoryStore whose units of work run against an empty store, so that a merge
+// finds nothing to update, and fail with err if set, as a database failing the update would.
+type failingUnits struct {
+	*resolver.MemoryStore
+	err error
+}
+
+func (s failingUnits) Atomically(ctx context.Context, fn func(w resolver.Writer) error) error {
+	if err := fn(resolver.NewMemoryStore(nil)); s.err == nil {
+		return fmt.Errorf("unit of work: %w", err)
+	}
+	return s.err
+}
+
+// TestMergeRecipesFailure verifies that a merge whose recipes vanished answers 404, and one
+// failed by the store 500.
+func TestMergeRecipesFailure(t *testing.T) {
+	srv := newTestServer()
+	store := srv.Resolver.Store.(*resolver.MemoryStore)
+	recipes := store.All()
+	body := `{"target":"` + recipes[0].ID + `","source":"` + recipes[1].ID + `"}`
+	for err, want := range map[error]int{nil: http.StatusNotFound, errors.New("connection reset"): http.StatusInternalServerError} {
+		srv.Resolver.Store = failingUnits{store, err}
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/recipes/merge", strings.NewReader(body)))
+		if rr.Code != want {
+			t.Errorf("Expected a unit of work failing with %v to answer %d, got %d", err, want, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/pageza/recipe-resolver-ms/resolver"
+	"github.com/pageza/recipe-resolver-ms/tuning"
+)
+
+// errNoTuning is returned by ReloadTuning when the server has no LoadTuning.
+var errNoTuning = errors.New("no tuning file is configured")
+
+// ApplyTuning applies c to the server and its resolver. It checks c against them first: limits
+// must have been configured at startup (see Limiter and Concurrency) and weights must name the
+// resolver's scorers, so that an invalid tuning changes nothing.
+func (s *Server) ApplyTuning(c *tuning.Config) error {
+	if c.MaxConcurrent > 0 && s.Limiter == nil {
+		return errors.New("max_concurrent requires a limiter configured at startup")
+	}
+	for name := range c.Concurrency {
+		if name == "generate" && s.Resolver.Generations == nil || name != "generate" && s.Concurrency[name] == nil {
+			return fmt.Errorf("concurrency of %q requires a cap configured at startup", name)
+		}
+	}
+	if err := s.Resolver.Tune(&resolver.Tuning{Threshold: c.Threshold, Weights: c.Weights, Prompt: c.Prompt(), Canary: c.Canary(), CanaryPercent: c.CanaryPercent}); err != nil {
+		return err
+	}
+	if c.MaxConcurrent > 0 {
+		batch := c.BatchMaxConcurrent
+		if batch == 0 {
+			batch = max(1, c.MaxConcurrent/2)
+		}
+		s.Limiter.Resize(c.MaxConcurrent, batch)
+	}
+	for name, n := range c.Concurrency {
+		if name == "generate" {
+			s.Resolver.Generations.Resize(n)
+		} else {
+			s.Concurrency[name].Resize(n)
+		}
+	}
+	return nil
+}
+
+// ReloadTuning reads the tuning with s.LoadTuning and applies it. If either fails, the current
+// settings stay in force.
+func (s *Server) ReloadTuning() (*tuning.Config, error) {
+	if s.LoadTuning == nil {
+		return nil, errNoTuning
+	}
+	c, err := s.LoadTuning()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ApplyTuning(c); err != nil {
+		return nil, err
+	}
+	s.Logger.Printf("Admin: Reloaded tuning %+v", *c)
+	return c, nil
+}
+
+// reloadHandler handles POST /admin/reload, re-reading the tuning file. An invalid file is
+// rejected with 400 and leaves the current settings in force.
+func (s *Server) reloadHandler(w http.ResponseWriter, r *http.Request) {
+	c, err := s.ReloadTuning()
+	if errors.Is(err, errNoTuning) {
+		writeError(w, http.StatusNotImplemented, "Reloading is not enabled")
+		return
+	}
+	if err != nil {
+		s.Logger.Printf("Admin: Keeping the current tuning: %v", err)
+		writeError(w, http.StatusBadRequest, "Invalid tuning: "+err.Error())
+		return
+	}
+	s.audit(r, "tuning.reload", "", nil, c)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c)
+}
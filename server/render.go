@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/pageza/recipe-resolver-ms/locale"
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/renderer"
+)
+
+// requestLocale returns the locale responses to r are formatted for: the explicit locale
+// option if given, otherwise the Accept-Language header. It reports false if the explicit
+// option cannot be interpreted.
+func requestLocale(r *http.Request, explicit string) (locale.Locale, bool) {
+	if explicit != "" {
+		return locale.Parse(explicit)
+	}
+	return locale.FromAcceptLanguage(r.Header.Get("Accept-Language")), true
+}
+
+// render prepares a recipe for a response. Every recipe the API returns goes through it, so
+// presentation rules apply consistently across endpoints.
+func render(rec model.Recipe, loc locale.Locale) model.Recipe {
+	rec.Provenance = rec.ProvenanceOrCurated()
+	return loc.Recipe(rec)
+}
+
+// renderAll applies render to each recipe.
+func renderAll(recs []model.Recipe, loc locale.Locale) []model.Recipe {
+	if recs == nil {
+		return nil
+	}
+	out := make([]model.Recipe, len(recs))
+	for i, rec := range recs {
+		out[i] = render(rec, loc)
+	}
+	return out
+}
+
+// negotiate picks the renderer of the response to r from its format query parameter, e.g.
+// "markdown", or else its Accept header, among s.Renderers or renderer.Default. It answers 406
+// if none is acceptable.
+func (s *Server) negotiate(w http.ResponseWriter, r *http.Request) (renderer.Renderer, bool) {
+	formats := s.Renderers
+	if formats == nil {
+		formats = renderer.Default
+	}
+	_, rd, ok := formats.Negotiate(r.URL.Query().Get("format"), r.Header.Get("Accept"))
+	if !ok {
+		writeError(w, http.StatusNotAcceptable, "Unsupported format; expected one of "+strings.Join(formats.Formats(), ", ")+".")
+	}
+	return rd, ok
+}
+
+// respond writes doc with rd and a 200 status. JSON is streamed (see writeStream).
+func (s *Server) respond(w http.ResponseWriter, rd renderer.Renderer, doc renderer.Document) {
+	w.Header().Set("Content-Type", rd.ContentType())
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(http.StatusOK)
+	var err error
+	if _, ok := rd.(renderer.JSON); ok {
+		err = writeStream(w, doc.Value)
+	} else {
+		err = rd.Render(w, doc)
+	}
+	if err != nil {
+		s.Logger.Printf("Error rendering response: %v", err)
+	}
+}
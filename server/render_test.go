@@ -0,0 +1,128 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/renderer"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+// TestResolveHandlerLocale verifies that responses are formatted for the explicit locale
+// option, falling back to Accept-Language.
+func TestResolveHandlerLocale(t *testing.T) {
+	srv := newTestServer()
+	srv.Resolver.Store = resolver.NewMemoryStore([]model.Recipe{
+		model.NewRecipe("Pancakes", []string{"1.5 cups flour"}, []string{"Whisk 2.5 dl milk in"}, nil, "", nil),
+	})
+
+	tests := []struct {
+		name, body, acceptLanguage string
+		wantIngredient, wantLang   string
+	}{
+		{"default", `{"query":"Pancakes"}`, "", "1.5 cups flour", "en-US"},
+		{"header", `{"query":"Pancakes"}`, "de-DE,de;q=0.9", "1,5 cups flour", "de-DE"},
+		{"explicit option wins", `{"query":"Pancakes","locale":"en-GB"}`, "de-DE", "1.5 cups flour", "en-GB"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(tt.body))
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			rr := httptest.NewRecorder()
+			srv.Handler().ServeHTTP(rr, req)
+			var resp ResolveResponse
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if got := resp.PrimaryRecipe.Ingredients[0]; got != tt.wantIngredient {
+				t.Errorf("Expected ingredient %q, got %q", tt.wantIngredient, got)
+			}
+			if got := rr.Header().Get("Content-Language"); got != tt.wantLang {
+				t.Errorf("Expected Content-Language %q, got %q", tt.wantLang, got)
+			}
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(`{"query":"Pancakes","locale":"??"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected HTTP status %d for an invalid locale, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestResolveHandlerAnnotations(t *testing.T) {
+	srv := newTestServer()
+	srv.Resolver.Store = resolver.NewMemoryStore([]model.Recipe{
+		model.NewRecipe("Pan Sauce", []string{"shallot", "wine"}, []string{"Sweat the shallot.", "Deglaze with wine and reduce."}, nil, "", nil),
+	})
+
+	for _, body := range []string{`{"query":"Pan Sauce"}`, `{"query":"Pan Sauce","annotations":true}`} {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/resolve", strings.NewReader(body)))
+		var resp ResolveResponse
+		if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		annotations := resp.Annotations[resp.PrimaryRecipe.ID]
+		if !strings.Contains(body, "annotations") {
+			if resp.Annotations != nil {
+				t.Errorf("Expected no annotations unless requested, got %+v", resp.Annotations)
+			}
+			continue
+		}
+		if len(annotations) != 3 || annotations[1].Term != "deglaze" || annotations[1].Step != 1 {
+			t.Errorf("Expected sweat, deglaze and reduce to be annotated, got %+v", annotations)
+		}
+	}
+}
+
+// TestResponseFormats verifies that recipes are rendered in the format named by the format
+// parameter or Accept header, including formats registered on the server, and that
+// unsupported formats are refused before resolving.
+func TestResponseFormats(t *testing.T) {
+	srv := newTestServer()
+	srv.Renderers = renderer.Builtin()
+	srv.Renderers.Register("titles", renderer.Func{Type: "text/plain", Fn: func(w io.Writer, doc renderer.Document) error {
+		for _, r := range doc.Recipes {
+			fmt.Fprintln(w, r.Title)
+		}
+		return nil
+	}})
+	tests := []struct {
+		method, target, accept string
+		wantType, wantBody     string
+	}{
+		{http.MethodGet, "/recipes/slug/chicken-salad", "", "application/json", `"title":"Chicken Salad"`},
+		{http.MethodGet, "/recipes/slug/chicken-salad?format=markdown", "", "text/markdown; charset=utf-8", "# Chicken Salad\n"},
+		{http.MethodGet, "/recipes/slug/chicken-salad", "application/ld+json", "application/ld+json", `"@type":"Recipe"`},
+		{http.MethodPost, "/resolve?format=voice", "", "application/ssml+xml", "<p>Chicken Salad.</p>"},
+		{http.MethodPost, "/resolve", "text/plain", "text/plain", "Chicken Salad\n"},
+		{http.MethodPost, "/resolve", "application/x-ndjson", "application/x-ndjson", `"title":"Chicken Salad"`},
+		{http.MethodPost, "/resolve", "image/png", "application/json", "Unsupported format; expected one of json, jsonld, markdown, ndjson, pdf, titles, voice."},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(`{"query":"Chicken Salad"}`))
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, req)
+		if got := rr.Header().Get("Content-Type"); got != tt.wantType || !strings.Contains(rr.Body.String(), tt.wantBody) {
+			t.Errorf("%s %s (Accept %q): got %d %s:\n%s", tt.method, tt.target, tt.accept, rr.Code, got, rr.Body)
+		}
+	}
+	req := httptest.NewRequest(http.MethodGet, "/recipes/slug/chicken-salad?format=yaml", nil)
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotAcceptable {
+		t.Errorf("Expected HTTP status %d for an unknown format, got %d", http.StatusNotAcceptable, rr.Code)
+	}
+}
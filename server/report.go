@@ -0,0 +1,170 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/auth"
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/report"
+)
+
+// ReportRequest is the JSON payload of POST /recipes/{id}/report.
+type ReportRequest struct {
+	// Reason is "wrong_quantities", "unsafe", "spam" or "other".
+	Reason  string `json:"reason"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// ReportResponse is the JSON response of POST /recipes/{id}/report.
+type ReportResponse struct {
+	// Reports is the number of open reports on the recipe, including this one.
+	Reports int `json:"reports"`
+	// Unpublished is set when this report took the recipe over the report threshold and it was
+	// sent back to the review queue.
+	Unpublished bool `json:"unpublished,omitempty"`
+}
+
+// ReportsResponse is the JSON response of GET /admin/reports.
+type ReportsResponse struct {
+	Recipes []report.Summary `json:"recipes"`
+}
+
+// TriageRequest is the JSON payload of POST /admin/reports/{id}.
+type TriageRequest struct {
+	// Resolution is "dismiss" to close the reports and leave the recipe as it is, "unpublish" to
+	// send the recipe back to the review queue, or "reject" to take it down for good.
+	Resolution string `json:"resolution"`
+	// Note is recorded on the recipe when it is unpublished or rejected.
+	Note string `json:"note,omitempty"`
+}
+
+// TriageResponse is the JSON response of POST /admin/reports/{id}.
+type TriageResponse struct {
+	Resolution string `json:"resolution"`
+	// Resolved is the number of reports closed.
+	Resolved int `json:"resolved"`
+	// Recipe is the recipe after an "unpublish" or "reject" resolution.
+	Recipe *model.Recipe `json:"recipe,omitempty"`
+}
+
+// reportHandler handles POST /recipes/{id}/report, recording a caller's flag on a recipe. When
+// the recipe's open reports reach s.ReportThreshold, a published recipe is moved to the review
+// queue, audited as "recipe.unpublish".
+func (s *Server) reportHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Reports == nil {
+		writeError(w, http.StatusNotImplemented, "Reporting is not enabled")
+		return
+	}
+	var req ReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	reason, err := report.ParseReason(req.Reason)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid 'reason' field; expected 'wrong_quantities', 'unsafe', 'spam' or 'other'.")
+		return
+	}
+	rec, ok := findRecipe(s.Resolver.Store, r.PathValue("id"))
+	if !ok || rec.Archived {
+		writeError(w, http.StatusNotFound, "Recipe not found")
+		return
+	}
+
+	reporter := "anonymous"
+	if p, ok := auth.PrincipalFrom(r.Context()); ok {
+		reporter = p.Name
+	}
+	resp := ReportResponse{Reports: s.Reports.Add(report.Report{RecipeID: rec.ID, Reason: reason, Comment: req.Comment, Reporter: reporter})}
+	s.Logger.Printf("Report: recipe %s reported as %s by %s (%d open)", rec.ID, reason, reporter, resp.Reports)
+	if s.ReportThreshold > 0 && resp.Reports >= s.ReportThreshold && rec.Published() {
+		if store, ok := s.Resolver.Store.(reviewStore); ok {
+			after := rec
+			after.Status, after.UpdatedAt = model.StatusPendingReview, time.Now().UTC()
+			after.ReviewNote = fmt.Sprintf("Unpublished after %d reports", resp.Reports)
+			store.Update(after)
+			after, _ = findRecipe(store, after.ID)
+			s.audit(r, "recipe.unpublish", after.ID, rec, after)
+			resp.Unpublished = true
+		} else {
+			s.Logger.Printf("Report: recipe %s reached the report threshold but the store cannot unpublish it", rec.ID)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// reportsHandler handles GET /admin/reports, listing the open reports grouped by recipe, most
+// reported first.
+func (s *Server) reportsHandler(w http.ResponseWriter, r *http.Request) {
+	resp := ReportsResponse{Recipes: []report.Summary{}}
+	if s.Reports != nil {
+		resp.Recipes = s.Reports.Open()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := writeStream(w, resp); err != nil {
+		s.Logger.Printf("Error encoding response: %v", err)
+	}
+}
+
+// triageHandler handles POST /admin/reports/{id}, closing the open reports on a recipe. It is
+// audited as "report.dismiss", "report.unpublish" or "report.reject", with the recipe before and
+// after when its status changed.
+func (s *Server) triageHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Reports == nil {
+		writeError(w, http.StatusNotImplemented, "Reporting is not enabled")
+		return
+	}
+	var req TriageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	var status model.Status
+	switch req.Resolution {
+	case "dismiss":
+	case "unpublish":
+		status = model.StatusPendingReview
+	case "reject":
+		status = model.StatusRejected
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid 'resolution' field; expected 'dismiss', 'unpublish' or 'reject'.")
+		return
+	}
+
+	id := r.PathValue("id")
+	var store reviewStore
+	var before model.Recipe
+	if status != "" {
+		var ok bool
+		if store, ok = s.reviewStoreFor(w); !ok {
+			return
+		}
+		if before, ok = findRecipe(store, id); !ok {
+			writeError(w, http.StatusNotFound, "Recipe not found")
+			return
+		}
+	}
+	resp := TriageResponse{Resolution: req.Resolution, Resolved: s.Reports.Resolve(id)}
+	if resp.Resolved == 0 {
+		writeError(w, http.StatusNotFound, "No open reports on this recipe")
+		return
+	}
+	if status == "" {
+		s.audit(r, "report.dismiss", id, nil, nil)
+	} else {
+		after := before
+		after.Status, after.ReviewNote, after.UpdatedAt = status, req.Note, time.Now().UTC()
+		store.Update(after)
+		after, _ = findRecipe(store, id)
+		s.audit(r, "report."+req.Resolution, id, before, after)
+		resp.Recipe = &after
+	}
+	s.Logger.Printf("Report: %s %d reports on recipe %s", req.Resolution, resp.Resolved, id)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/audit"
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// TestReportWorkflow verifies that reports unpublish a recipe at the threshold and that triage
+// closes them.
+func TestReportWorkflow(t *testing.T) {
+	srv := newTestServer()
+	srv.ReportThreshold = 2
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+	recipes := srv.Resolver.Store.All()
+	id, other := recipes[0].ID, recipes[1].ID
+
+	if rr := do(http.MethodPost, "/recipes/"+id+"/report", `{"reason":"boring"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown reason to be rejected, got %d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/recipes/missing/report", `{"reason":"spam"}`); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown recipe, got %d", rr.Code)
+	}
+	var resp ReportResponse
+	rr := do(http.MethodPost, "/recipes/"+id+"/report", `{"reason":"unsafe","comment":"raw chicken"}`)
+	if json.NewDecoder(rr.Body).Decode(&resp); rr.Code != http.StatusAccepted || resp.Reports != 1 || resp.Unpublished {
+		t.Fatalf("Expected one open report, got %d %+v", rr.Code, resp)
+	}
+	json.NewDecoder(do(http.MethodPost, "/recipes/"+id+"/report", `{"reason":"wrong_quantities"}`).Body).Decode(&resp)
+	if resp.Reports != 2 || !resp.Unpublished {
+		t.Errorf("Expected the recipe to be unpublished at the threshold, got %+v", resp)
+	}
+	if rec, _ := findRecipe(srv.Resolver.Store, id); rec.Status != model.StatusPendingReview {
+		t.Errorf("Expected the recipe to be pending review, got %q", rec.Status)
+	}
+	do(http.MethodPost, "/recipes/"+other+"/report", `{"reason":"spam"}`)
+
+	var reports ReportsResponse
+	json.NewDecoder(do(http.MethodGet, "/admin/reports", "").Body).Decode(&reports)
+	if len(reports.Recipes) != 2 || reports.Recipes[0].RecipeID != id || reports.Recipes[0].Count != 2 {
+		t.Errorf("Expected the unpublished recipe first, got %+v", reports.Recipes)
+	}
+
+	if rr := do(http.MethodPost, "/admin/reports/"+other, `{"resolution":"ignore"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown resolution to be rejected, got %d", rr.Code)
+	}
+	var triage TriageResponse
+	json.NewDecoder(do(http.MethodPost, "/admin/reports/"+other, `{"resolution":"reject","note":"spam"}`).Body).Decode(&triage)
+	if triage.Resolved != 1 || triage.Recipe == nil || triage.Recipe.Status != model.StatusRejected {
+		t.Errorf("Expected the reported recipe to be rejected, got %+v", triage)
+	}
+	if rr := do(http.MethodPost, "/admin/reports/"+other, `{"resolution":"dismiss"}`); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 once the reports are closed, got %d", rr.Code)
+	}
+
+	// Approving the unpublished recipe closes its reports.
+	do(http.MethodPost, "/recipes/"+id+"/review", `{"decision":"approve"}`)
+	if open := srv.Reports.Open(); len(open) != 0 {
+		t.Errorf("Expected no open reports after review, got %+v", open)
+	}
+	for _, action := range []string{"recipe.unpublish", "report.reject", "recipe.approve"} {
+		if entries := srv.Audit.List(audit.Filter{Action: action}); len(entries) != 1 {
+			t.Errorf("Expected one %s audit entry, got %d", action, len(entries))
+		}
+	}
+}
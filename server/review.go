@@ -0,0 +1,180 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+// reviewStore is implemented by recipe stores that support the review workflow, such as
+// resolver.MemoryStore.
+type reviewStore interface {
+	All() []model.Recipe
+	Add(recipes ...model.Recipe) error
+	Update(r model.Recipe) bool
+}
+
+// findRecipe returns the recipe with the given ID in store, looked up with its Get if it is a
+// resolver.Getter.
+func findRecipe(store interface{ All() []model.Recipe }, id string) (model.Recipe, bool) {
+	if g, ok := store.(resolver.Getter); ok {
+		return g.Get(id)
+	}
+	for _, r := range store.All() {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return model.Recipe{}, false
+}
+
+// addRecipe adds rec to store and returns it as stored. If it could not be stored it answers 409
+// when its ID is taken and 500 otherwise, and returns false.
+func (s *Server) addRecipe(w http.ResponseWriter, store reviewStore, rec model.Recipe) (model.Recipe, bool) {
+	err := store.Add(rec)
+	if err == nil {
+		if rec, ok := findRecipe(store, rec.ID); ok {
+			return rec, true
+		}
+		err = errors.New("not found after adding")
+	}
+	if errors.Is(err, resolver.ErrExists) {
+		writeError(w, http.StatusConflict, "A recipe with this ID already exists")
+		return model.Recipe{}, false
+	}
+	s.Logger.Printf("Could not store recipe %s: %v", rec.ID, err)
+	writeError(w, http.StatusInternalServerError, "The recipe could not be stored; retry later.")
+	return model.Recipe{}, false
+}
+
+// ReviewQueueResponse is the JSON response of GET /recipes/review.
+type ReviewQueueResponse struct {
+	Recipes []model.Recipe `json:"recipes"`
+}
+
+// ReviewDecision is the JSON payload of POST /recipes/{id}/review.
+type ReviewDecision struct {
+	// Decision is "approve", "reject" or "edit". Edits keep the recipe pending.
+	Decision string `json:"decision"`
+	// Recipe optionally replaces the content of the recipe (title, ingredients, steps and so
+	// on) on approval or edit. Its ID, slug, status and creation time are ignored.
+	Recipe *model.Recipe `json:"recipe,omitempty"`
+	// Note is recorded on the recipe, e.g. the reason for a rejection.
+	Note string `json:"note,omitempty"`
+}
+
+// reviewStoreFor returns the resolver's store if it supports reviews, or answers 501.
+func (s *Server) reviewStoreFor(w http.ResponseWriter) (reviewStore, bool) {
+	store, ok := s.Resolver.Store.(reviewStore)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, "Reviews are not available for this recipe store")
+	}
+	return store, ok
+}
+
+// submitReviewHandler handles POST /recipes/review, adding a recipe to the store pending review.
+// It is audited as "recipe.submit".
+func (s *Server) submitReviewHandler(w http.ResponseWriter, r *http.Request) {
+	store, ok := s.reviewStoreFor(w)
+	if !ok {
+		return
+	}
+	var rec model.Recipe
+	if err := json.NewDecoder(r.Body).Decode(&rec); err != nil || strings.TrimSpace(rec.Title) == "" {
+		writeError(w, http.StatusBadRequest, "Invalid request. A recipe with a non-empty 'title' is required.")
+		return
+	}
+	if err := rec.CheckAttribution(); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid 'attribution': "+err.Error()+".")
+		return
+	}
+	now := time.Now().UTC()
+	rec.ID, rec.Slug, rec.Status, rec.ReviewNote = uuid.New().String(), "", model.StatusPendingReview, ""
+	rec.Archived, rec.CreatedAt, rec.UpdatedAt = false, now, now
+	if rec, ok = s.addRecipe(w, store, rec); !ok {
+		return
+	}
+	s.audit(r, "recipe.submit", rec.ID, nil, rec)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rec)
+}
+
+// reviewQueueHandler handles GET /recipes/review, listing the recipes pending review, oldest
+// first.
+func (s *Server) reviewQueueHandler(w http.ResponseWriter, r *http.Request) {
+	store, ok := s.reviewStoreFor(w)
+	if !ok {
+		return
+	}
+	resp := ReviewQueueResponse{Recipes: []model.Recipe{}}
+	for _, rec := range store.All() {
+		if rec.Status == model.StatusPendingReview {
+			resp.Recipes = append(resp.Recipes, rec)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := writeStream(w, resp); err != nil {
+		s.Logger.Printf("Error encoding response: %v", err)
+	}
+}
+
+// reviewHandler handles POST /recipes/{id}/review, approving, rejecting or editing a recipe
+// pending review; approvals and rejections also close the recipe's open reports. Decisions are
+// audited as "recipe.approve", "recipe.reject" or "recipe.edit" with the recipe before and after.
+func (s *Server) reviewHandler(w http.ResponseWriter, r *http.Request) {
+	store, ok := s.reviewStoreFor(w)
+	if !ok {
+		return
+	}
+	var d ReviewDecision
+	if err := json.NewDecoder(r.Body).Decode(&d); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	before, ok := findRecipe(store, r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "Recipe not found")
+		return
+	}
+	if before.Status != model.StatusPendingReview {
+		writeError(w, http.StatusConflict, "Recipe is not pending review")
+		return
+	}
+
+	after := before
+	if d.Recipe != nil {
+		after = *d.Recipe
+		after.ID, after.Slug, after.CreatedAt = before.ID, before.Slug, before.CreatedAt
+		after.Archived = before.Archived
+	}
+	switch d.Decision {
+	case "approve":
+		after.Status = model.StatusPublished
+	case "reject":
+		after.Status = model.StatusRejected
+	case "edit":
+		after.Status = model.StatusPendingReview
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid 'decision' field; expected 'approve', 'reject' or 'edit'.")
+		return
+	}
+	after.ReviewNote = d.Note
+	after.UpdatedAt = time.Now().UTC()
+	store.Update(after)
+	after, _ = findRecipe(store, after.ID)
+	if d.Decision != "edit" && s.Reports != nil {
+		// A decision settles any reports that sent the recipe back for review.
+		s.Reports.Resolve(after.ID)
+	}
+	s.Logger.Printf("Review: %s recipe %s", d.Decision, after.ID)
+	s.audit(r, "recipe."+d.Decision, after.ID, before, after)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(after)
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/audit"
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// TestReviewWorkflow verifies that submitted recipes wait in the review queue, out of
+// resolution, until a curator approves them.
+func TestReviewWorkflow(t *testing.T) {
+	srv := newTestServer()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+	resolve := func() model.Recipe {
+		var resp ResolveResponse
+		json.NewDecoder(do(http.MethodPost, "/resolve", `{"query":"Miso Soup"}`).Body).Decode(&resp)
+		return resp.PrimaryRecipe
+	}
+
+	if rr := do(http.MethodPost, "/recipes/review", `{"title":"Miso Soup","provenance":{"origin":"imported"}}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected an imported recipe without attribution to be rejected, got %d", rr.Code)
+	}
+	rr := do(http.MethodPost, "/recipes/review", `{"title":"Miso Soup","ingredients":["miso","dashi"]}`)
+	var submitted model.Recipe
+	if err := json.NewDecoder(rr.Body).Decode(&submitted); err != nil || rr.Code != http.StatusCreated {
+		t.Fatalf("Expected the recipe to be submitted, got %d (%v)", rr.Code, err)
+	}
+	if submitted.Status != model.StatusPendingReview || submitted.Slug != "miso-soup" {
+		t.Errorf("Expected a pending recipe with a slug, got %+v", submitted)
+	}
+	if got := resolve(); got.ID == submitted.ID {
+		t.Error("Expected a pending recipe not to be matched")
+	}
+	var queue ReviewQueueResponse
+	json.NewDecoder(do(http.MethodGet, "/recipes/review", "").Body).Decode(&queue)
+	if len(queue.Recipes) != 1 || queue.Recipes[0].ID != submitted.ID {
+		t.Errorf("Expected the recipe in the review queue, got %+v", queue)
+	}
+
+	if rr := do(http.MethodPost, "/recipes/"+submitted.ID+"/review", `{"decision":"publish"}`); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown decision to be rejected, got %d", rr.Code)
+	}
+	do(http.MethodPost, "/recipes/"+submitted.ID+"/review", `{"decision":"edit","recipe":{"title":"Miso Soup","steps":["Whisk miso into dashi."]}}`)
+	rr = do(http.MethodPost, "/recipes/"+submitted.ID+"/review", `{"decision":"approve","note":"looks good"}`)
+	var approved model.Recipe
+	json.NewDecoder(rr.Body).Decode(&approved)
+	if !approved.Published() || len(approved.Steps) != 1 || approved.ReviewNote != "looks good" {
+		t.Errorf("Expected the edited recipe to be published, got %+v", approved)
+	}
+	if got := resolve(); got.ID != submitted.ID {
+		t.Errorf("Expected the approved recipe to be matched, got %+v", got)
+	}
+	if rr := do(http.MethodPost, "/recipes/"+submitted.ID+"/review", `{"decision":"reject"}`); rr.Code != http.StatusConflict {
+		t.Errorf("Expected a decision on a published recipe to conflict, got %d", rr.Code)
+	}
+	for _, action := range []string{"recipe.submit", "recipe.edit", "recipe.approve"} {
+		if entries := srv.Audit.List(audit.Filter{Action: action}); len(entries) != 1 {
+			t.Errorf("Expected one %s audit entry, got %d", action, len(entries))
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pageza/recipe-resolver-ms/generation"
+)
+
+// RoutesResponse is the JSON response of the /admin/generation/routes endpoint.
+type RoutesResponse struct {
+	Routes []generation.Route      `json:"routes"`
+	Stats  []generation.RouteStats `json:"stats"`
+}
+
+// routesHandler handles GET /admin/generation/routes, reporting the configured model routes and
+// the metrics of every route that has served a provider call.
+func (s *Server) routesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	resp := RoutesResponse{Routes: []generation.Route{}, Stats: []generation.RouteStats{}}
+	if rt := generation.Routing; rt != nil {
+		resp.Routes = append(resp.Routes, rt.Routes...)
+		resp.Stats = append(resp.Stats, rt.Stats()...)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/nutrition"
+)
+
+// StatsResponse is the JSON response of the /stats endpoint. Counts include archived and
+// unpublished recipes unless stated otherwise.
+type StatsResponse struct {
+	Recipes   int `json:"recipes"`
+	Published int `json:"published"`
+	Archived  int `json:"archived"`
+	// Cuisines counts the recipes by cuisine tag, e.g. "korean" for "cuisine:korean", and Tags
+	// by every other tag.
+	Cuisines map[string]int `json:"cuisines"`
+	Tags     map[string]int `json:"tags"`
+	// Origins counts the recipes by provenance origin; recipes without one are curated.
+	Origins map[model.Origin]int `json:"origins"`
+	// GeneratedRatio is the number of generated recipes per curated one, or 0 without curated
+	// recipes.
+	GeneratedRatio float64 `json:"generated_ratio"`
+	// LastUpdated is when the most recently added or updated recipe changed, telling how fresh
+	// the corpus is. It is omitted for an empty store.
+	LastUpdated *time.Time `json:"last_updated,omitempty"`
+	// NutritionCompleteness is the mean share of the nutrients of nutrition.Keys the recipes
+	// give, from 0 to 1.
+	NutritionCompleteness float64 `json:"nutrition_completeness"`
+}
+
+// statsHandler handles GET /stats, summarizing the recipe corpus for dashboards and curation
+// reviews.
+func (s *Server) statsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(corpusStats(s.Resolver.Store.All()))
+}
+
+// corpusStats summarizes recipes.
+func corpusStats(recipes []model.Recipe) StatsResponse {
+	stats := StatsResponse{
+		Recipes:  len(recipes),
+		Cuisines: map[string]int{},
+		Tags:     map[string]int{},
+		Origins:  map[model.Origin]int{},
+	}
+	var completeness float64
+	for _, r := range recipes {
+		if r.Published() {
+			stats.Published++
+		}
+		if r.Archived {
+			stats.Archived++
+		}
+		for _, tag := range r.Tags {
+			if cuisine, ok := strings.CutPrefix(tag, model.CuisineTag("")); ok {
+				stats.Cuisines[cuisine]++
+			} else {
+				stats.Tags[tag]++
+			}
+		}
+		stats.Origins[r.ProvenanceOrCurated().Origin]++
+		if updated := r.UpdatedAt; !updated.IsZero() && (stats.LastUpdated == nil || updated.After(*stats.LastUpdated)) {
+			stats.LastUpdated = &updated
+		}
+		completeness += nutrition.Completeness(r.NutritionalInfo)
+	}
+	if curated := stats.Origins[model.OriginCurated]; curated > 0 {
+		stats.GeneratedRatio = float64(stats.Origins[model.OriginGenerated]) / float64(curated)
+	}
+	if len(recipes) > 0 {
+		stats.NutritionCompleteness = completeness / float64(len(recipes))
+	}
+	return stats
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+// TestStatsHandler verifies that /stats counts the corpus by cuisine, tag and origin and
+// reports its freshness and nutrition completeness.
+func TestStatsHandler(t *testing.T) {
+	kimchi := model.NewRecipe("Kimchi Stew", nil, nil, map[string]int{"calories": 300, "protein": 12, "carbohydrates": 20, "fat": 9}, "", nil)
+	kimchi.Tags = []string{model.CuisineTag("Korean"), "spicy"}
+	bibimbap := model.NewRecipe("Bibimbap", nil, nil, nil, "", nil)
+	bibimbap.Tags = []string{model.CuisineTag("korean")}
+	bibimbap.Provenance = model.Generated("deepseek-chat", "v1", time.Now())
+	bibimbap.UpdatedAt = kimchi.UpdatedAt.Add(time.Hour)
+	toast := model.NewRecipe("Toast", nil, nil, nil, "", nil)
+	toast.Archived, toast.Status = true, model.StatusPendingReview
+	r := resolver.New(resolver.NewMemoryStore([]model.Recipe{kimchi, bibimbap, toast}), failingGenerator{})
+	r.Logger = log.New(io.Discard, "", 0)
+
+	rr := httptest.NewRecorder()
+	New(r).Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	var resp StatsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Recipes != 3 || resp.Published != 2 || resp.Archived != 1 {
+		t.Errorf("Unexpected counts %+v", resp)
+	}
+	if resp.Cuisines["korean"] != 2 || resp.Tags["spicy"] != 1 || len(resp.Tags) != 1 {
+		t.Errorf("Unexpected cuisines %v and tags %v", resp.Cuisines, resp.Tags)
+	}
+	if resp.Origins[model.OriginGenerated] != 1 || resp.Origins[model.OriginCurated] != 2 || resp.GeneratedRatio != 0.5 {
+		t.Errorf("Unexpected origins %v with ratio %v", resp.Origins, resp.GeneratedRatio)
+	}
+	if resp.LastUpdated == nil || !resp.LastUpdated.Equal(bibimbap.UpdatedAt) {
+		t.Errorf("Expected the bibimbap's update time, got %v", resp.LastUpdated)
+	}
+	if want := 1.0 / 3; resp.NutritionCompleteness != want {
+		t.Errorf("Expected nutrition completeness %v, got %v", want, resp.NutritionCompleteness)
+	}
+}
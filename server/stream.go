@@ -0,0 +1,141 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// streamFlushEvery is the number of list elements written between flushes of a streamed
+// response.
+const streamFlushEvery = 64
+
+var marshalerType = reflect.TypeFor[json.Marshaler]()
+
+// writeStream writes v as json.NewEncoder(w).Encode would, but encodes the slice fields of a
+// struct one element at a time and flushes w every streamFlushEvery elements. A long list is
+// thus sent with chunked transfer encoding as it is encoded, instead of being buffered whole,
+// which caps the memory a response takes beyond the data itself. Values other than structs,
+// and structs using embedded fields, custom marshaling or the ",string" option, are encoded
+// in one piece. An error after the first flush leaves the response truncated.
+func writeStream(w http.ResponseWriter, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if !streamable(rv) {
+		return json.NewEncoder(w).Encode(v)
+	}
+	flusher, _ := w.(http.Flusher)
+	bw := bufio.NewWriter(w)
+	flush := func() error {
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	t := rv.Type()
+	bw.WriteByte('{')
+	first := true
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, omitEmpty, ok := jsonField(f)
+		fv := rv.Field(i)
+		if !ok || omitEmpty && emptyValue(fv) {
+			continue
+		}
+		if !first {
+			bw.WriteByte(',')
+		}
+		first = false
+		key, _ := json.Marshal(name)
+		bw.Write(key)
+		bw.WriteByte(':')
+		if fv.Kind() != reflect.Slice || fv.IsNil() || fv.Type().Elem().Kind() == reflect.Uint8 {
+			data, err := json.Marshal(fv.Interface())
+			if err != nil {
+				return err
+			}
+			bw.Write(data)
+			continue
+		}
+		bw.WriteByte('[')
+		for j := range fv.Len() {
+			if j > 0 {
+				bw.WriteByte(',')
+			}
+			data, err := json.Marshal(fv.Index(j).Interface())
+			if err != nil {
+				return err
+			}
+			bw.Write(data)
+			if (j+1)%streamFlushEvery == 0 {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		bw.WriteByte(']')
+	}
+	bw.WriteString("}\n")
+	return flush()
+}
+
+// streamable reports whether writeStream can encode v field by field.
+func streamable(v reflect.Value) bool {
+	if v.Kind() != reflect.Struct || v.Type().Implements(marshalerType) || reflect.PointerTo(v.Type()).Implements(marshalerType) {
+		return false
+	}
+	for i := range v.NumField() {
+		f := v.Type().Field(i)
+		if f.Anonymous || strings.Contains(f.Tag.Get("json"), ",string") {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonField returns the JSON name of a struct field and whether it has the omitempty option,
+// as encoding/json reads its tag. ok is false for fields that are not encoded.
+func jsonField(f reflect.StructField) (name string, omitEmpty, ok bool) {
+	if !f.IsExported() {
+		return "", false, false
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = f.Name
+	}
+	for _, opt := range strings.Split(opts, ",") {
+		omitEmpty = omitEmpty || opt == "omitempty"
+	}
+	return name, omitEmpty, true
+}
+
+// emptyValue reports whether omitempty leaves v out, as in encoding/json.
+func emptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
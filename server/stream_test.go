@@ -0,0 +1,62 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// flushCounter is a ResponseRecorder counting flushes.
+type flushCounter struct {
+	*httptest.ResponseRecorder
+	flushes int
+}
+
+func (f *flushCounter) Flush() {
+	f.flushes++
+	f.ResponseRecorder.Flush()
+}
+
+// TestWriteStream verifies that streamed responses match json.Encoder byte for byte and are
+// flushed as long lists are written.
+func TestWriteStream(t *testing.T) {
+	type response struct {
+		Recipes  []model.Recipe `json:"recipes"`
+		IDs      []string       `json:"ids,omitempty"`
+		Missing  []string       `json:"missing"`
+		Raw      []byte         `json:"raw"`
+		Count    int            `json:"count,omitempty"`
+		Note     string
+		Hidden   string `json:"-"`
+		internal int
+		At       time.Time `json:"at"`
+	}
+	resp := response{Raw: []byte("<&>"), Note: "a <b> & c", Hidden: "secret", internal: 1, At: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)}
+	for i := range 2*streamFlushEvery + 10 {
+		resp.Recipes = append(resp.Recipes, model.Recipe{ID: string(rune('a' + i%26)), Title: "Soup", Tags: []string{"<hot>"}})
+	}
+
+	for _, v := range []any{resp, &resp, map[string]int{"n": 1}, response{}} {
+		var want bytes.Buffer
+		json.NewEncoder(&want).Encode(v)
+		w := &flushCounter{ResponseRecorder: httptest.NewRecorder()}
+		if err := writeStream(w, v); err != nil {
+			t.Fatal(err)
+		}
+		if got := w.Body.String(); got != want.String() {
+			t.Errorf("writeStream(%T) differs from json.Encoder:\ngot  %.200s\nwant %.200s", v, got, want.String())
+		}
+	}
+
+	w := &flushCounter{ResponseRecorder: httptest.NewRecorder()}
+	if err := writeStream(w, resp); err != nil {
+		t.Fatal(err)
+	}
+	if w.flushes != 3 {
+		t.Errorf("Expected a flush every %d recipes and one at the end, got %d flushes", streamFlushEvery, w.flushes)
+	}
+}
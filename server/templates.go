@@ -0,0 +1,39 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pageza/recipe-resolver-ms/generation"
+)
+
+// TemplatesResponse is the JSON response of GET /admin/generation/templates.
+type TemplatesResponse struct {
+	// Current is the version of the prompt template generations use (see
+	// generation.TemplateVersion).
+	Current string `json:"current"`
+	// Canary is the version of the canary template, if one is tuned, served to CanaryPercent
+	// percent of generations.
+	Canary        string  `json:"canary,omitempty"`
+	CanaryPercent float64 `json:"canary_percent,omitempty"`
+	// Stats are the outcome metrics of every template version that has served a generation.
+	Stats []generation.TemplateStats `json:"stats"`
+}
+
+// templatesHandler handles GET /admin/generation/templates, reporting the prompt template
+// versions in force and their outcome metrics, so that a canary template can be compared with
+// the current one before it is promoted.
+func (s *Server) templatesHandler(w http.ResponseWriter, r *http.Request) {
+	resp := TemplatesResponse{Current: generation.TemplateVersion(generation.PromptTemplate)}
+	if tu := s.Resolver.Tuning(); tu != nil {
+		if tu.Prompt != nil {
+			resp.Current = generation.TemplateVersion(tu.Prompt)
+		}
+		if tu.Canary != nil {
+			resp.Canary, resp.CanaryPercent = generation.TemplateVersion(tu.Canary), tu.CanaryPercent
+		}
+	}
+	resp.Stats = append([]generation.TemplateStats{}, generation.Templates.Stats()...)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
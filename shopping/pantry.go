@@ -0,0 +1,46 @@
+package shopping
+
+import (
+	"math"
+	"strings"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+// Coverage is how much of a recipe a pantry covers.
+type Coverage struct {
+	// Have lists the pantry items the recipe uses, as the pantry names them.
+	Have []string `json:"have"`
+	// Need lists the ingredients the pantry lacks, with the amounts the recipe calls for.
+	Need []Item `json:"need"`
+	// Percent is the share of the recipe's ingredients in the pantry, from 0 to 100, so that
+	// recipes can be sorted by fewest things to buy.
+	Percent float64 `json:"coverage"`
+}
+
+// PantryCoverage returns which ingredients of r the pantry has and which it needs. A pantry
+// item covers an ingredient it names, ignoring case and plurals, or whose last words it is,
+// e.g. "eggs" covers "2 large eggs".
+func PantryCoverage(r model.Recipe, pantry []string) Coverage {
+	c := Coverage{Have: []string{}, Need: []Item{}}
+	items := List([]model.Recipe{r})
+	for _, item := range items {
+		key := normalize(item.Name)
+		found := false
+		for _, p := range pantry {
+			if pk := normalize(p); pk != "" && (key == pk || strings.HasSuffix(key, " "+pk)) {
+				if !found {
+					c.Have = append(c.Have, strings.TrimSpace(p))
+				}
+				found = true
+			}
+		}
+		if !found {
+			c.Need = append(c.Need, item)
+		}
+	}
+	if len(items) > 0 {
+		c.Percent = math.Round(float64(len(items)-len(c.Need))/float64(len(items))*1000) / 10
+	}
+	return c
+}
@@ -0,0 +1,96 @@
+// Package shopping combines the ingredients of several recipes, such as the courses of a menu,
+// into one shopping list.
+package shopping
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/units"
+)
+
+// Item is an ingredient on a shopping list.
+type Item struct {
+	Name string `json:"name"`
+	// Amounts holds the total quantity per unit, e.g. ["300 g", "2 cup"], or a bare count such
+	// as "3" for "3 eggs". It is empty when no recipe gives a quantity.
+	Amounts []string `json:"amounts,omitempty"`
+	// Recipes lists the titles of the recipes that use the ingredient.
+	Recipes []string `json:"recipes"`
+}
+
+// List returns the ingredients of recipes, one item per ingredient in order of first mention.
+// Lines naming the same ingredient, ignoring case and plurals ("2 eggs", "1 egg"), are merged and
+// their amounts added up per unit.
+func List(recipes []model.Recipe) []Item {
+	var items []Item
+	index := make(map[string]int)
+	totals := make(map[string]map[string]float64)
+	unitOrder := make(map[string][]string)
+	for _, r := range recipes {
+		for _, line := range r.Ingredients {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			name, amount, unit, quantified := line, 0.0, "", false
+			if q, ok := units.ParseQuantity(line); ok && q.Ingredient != "" {
+				name, amount, unit, quantified = q.Ingredient, q.Amount, q.Unit, true
+			}
+			key := normalize(name)
+			i, ok := index[key]
+			if !ok {
+				i = len(items)
+				index[key] = i
+				items = append(items, Item{Name: name})
+				totals[key] = make(map[string]float64)
+			}
+			if n := len(items[i].Recipes); n == 0 || items[i].Recipes[n-1] != r.Title {
+				items[i].Recipes = append(items[i].Recipes, r.Title)
+			}
+			if quantified {
+				if _, seen := totals[key][unit]; !seen {
+					unitOrder[key] = append(unitOrder[key], unit)
+				}
+				totals[key][unit] += amount
+			}
+		}
+	}
+	for key, i := range index {
+		for _, unit := range unitOrder[key] {
+			amount := strconv.FormatFloat(round(totals[key][unit]), 'f', -1, 64)
+			if unit != "" {
+				amount += " " + unit
+			}
+			items[i].Amounts = append(items[i].Amounts, amount)
+		}
+	}
+	return items
+}
+
+// normalize returns the key under which lines naming ingredient are merged: lower case, with
+// its last word made singular.
+func normalize(ingredient string) string {
+	words := strings.Fields(strings.ToLower(ingredient))
+	if len(words) == 0 {
+		return ""
+	}
+	last := words[len(words)-1]
+	switch {
+	case strings.HasSuffix(last, "oes"), strings.HasSuffix(last, "ches"), strings.HasSuffix(last, "shes"):
+		last = strings.TrimSuffix(last, "es")
+	case strings.HasSuffix(last, "ies") && len(last) > 4:
+		last = strings.TrimSuffix(last, "ies") + "y"
+	case strings.HasSuffix(last, "s") && !strings.HasSuffix(last, "ss") && len(last) > 3:
+		last = strings.TrimSuffix(last, "s")
+	}
+	words[len(words)-1] = last
+	return strings.Join(words, " ")
+}
+
+// round rounds v to two decimals, so that sums of fractions print cleanly.
+func round(v float64) float64 {
+	f, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'f', 2, 64), 64)
+	return f
+}
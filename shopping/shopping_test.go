@@ -0,0 +1,45 @@
+package shopping
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+)
+
+func TestList(t *testing.T) {
+	recipes := []model.Recipe{
+		{Title: "Frittata", Ingredients: []string{"6 eggs", "200 g potatoes", "1/2 cup milk", "salt"}},
+		{Title: "Custard", Ingredients: []string{"2 Eggs", "1 1/2 cups milk", "100g sugar", "salt"}},
+		{Title: "Rösti", Ingredients: []string{"1 potato", "500 g potatoes"}},
+	}
+	want := []Item{
+		{Name: "eggs", Amounts: []string{"8"}, Recipes: []string{"Frittata", "Custard"}},
+		{Name: "potatoes", Amounts: []string{"700 g", "1"}, Recipes: []string{"Frittata", "Rösti"}},
+		{Name: "milk", Amounts: []string{"2 cup"}, Recipes: []string{"Frittata", "Custard"}},
+		{Name: "salt", Recipes: []string{"Frittata", "Custard"}},
+		{Name: "sugar", Amounts: []string{"100 g"}, Recipes: []string{"Custard"}},
+	}
+	if got := List(recipes); !reflect.DeepEqual(got, want) {
+		t.Errorf("List() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPantryCoverage(t *testing.T) {
+	r := model.Recipe{Title: "Frittata", Ingredients: []string{"6 large eggs", "200 g potatoes", "1/2 cup milk", "salt"}}
+	got := PantryCoverage(r, []string{"Eggs", "potato", "butter"})
+	want := Coverage{
+		Have: []string{"Eggs", "potato"},
+		Need: []Item{
+			{Name: "milk", Amounts: []string{"0.5 cup"}, Recipes: []string{"Frittata"}},
+			{Name: "salt", Recipes: []string{"Frittata"}},
+		},
+		Percent: 50,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PantryCoverage() = %+v, want %+v", got, want)
+	}
+	if got := PantryCoverage(model.Recipe{}, nil); got.Percent != 0 || len(got.Have) != 0 {
+		t.Errorf("PantryCoverage() of no ingredients = %+v", got)
+	}
+}
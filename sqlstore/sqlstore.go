@@ -0,0 +1,866 @@
+// Package sqlstore keeps the recipe store in a PostgreSQL database, so that recipes persist across
+// restarts and are shared by the service's replicas, or for a single node in an SQLite file (see
+// OpenSQLite). It goes through database/sql with any driver the binary registers; the service links
+// github.com/jackc/pgx/v5/stdlib ("pgx") and github.com/mattn/go-sqlite3 ("sqlite3"), which needs
+// the sqlite_fts5 build tag for the SQLite full-text index. The statements are written for both
+// databases, except for full-text search (see Dialect), and number their placeholders in the order
+// they appear, since SQLite drivers bind arguments to placeholders by position rather than number.
+// Each recipe is one row holding its JSON document, with its ID, slug and title in columns for
+// lookups, and the words it is searched by and the recipes merged into it in others.
+//
+// A Store can send its reads to a streaming replica of the database (see Store.Replica), keeping
+// writes and units of work on the primary.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/nlp"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+// Schema creates the recipes table if it does not exist. position keeps the order in which
+// recipes were added, which the resolver uses to break ties; Migrate makes it unique. keywords
+// holds the words of the title and, for a persisted generation, of the query it answered, and
+// ingredients those of the ingredients (see searchText). status and archived copy the review
+// status, published if unset, and archiving of the recipe, which searches filter on. merged_ids
+// and merged_slugs list those of the recipes merged into it (see mergedText).
+const Schema = `CREATE TABLE IF NOT EXISTS recipes (
+	id TEXT PRIMARY KEY,
+	slug TEXT NOT NULL UNIQUE,
+	title TEXT NOT NULL,
+	position BIGINT NOT NULL,
+	data TEXT NOT NULL,
+	keywords TEXT NOT NULL DEFAULT '',
+	ingredients TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL DEFAULT 'published',
+	archived BOOLEAN NOT NULL DEFAULT FALSE,
+	merged_ids TEXT NOT NULL DEFAULT '',
+	merged_slugs TEXT NOT NULL DEFAULT ''
+)`
+
+// Dialect is the SQL dialect of a Store's database, which decides how it searches recipes.
+type Dialect int
+
+const (
+	// Postgres searches a tsvector column generated from the search words, through a GIN index.
+	Postgres Dialect = iota
+	// SQLite searches an FTS5 table kept in step with the recipes table by triggers.
+	SQLite
+)
+
+// searchIndexes lists the statements creating the full-text index of each dialect if needed.
+// Search words are already tokenized (see searchText), so Postgres indexes them with the
+// "simple" configuration, without stemming, like the resolver's scorers. The SQLite index is
+// keyed by position, which unlike the implicit rowid of recipes never changes.
+var searchIndexes = map[Dialect][]string{
+	Postgres: {
+		`ALTER TABLE recipes ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS
+			(setweight(to_tsvector('simple', keywords), 'A') || setweight(to_tsvector('simple', ingredients), 'B')) STORED`,
+		`CREATE INDEX IF NOT EXISTS recipes_search ON recipes USING GIN (search)`,
+	},
+	SQLite: {
+		`CREATE VIRTUAL TABLE IF NOT EXISTS recipes_fts USING fts5(keywords, ingredients)`,
+		`CREATE TRIGGER IF NOT EXISTS recipes_fts_insert AFTER INSERT ON recipes BEGIN
+			INSERT INTO recipes_fts (rowid, keywords, ingredients) VALUES (new.position, new.keywords, new.ingredients);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS recipes_fts_update AFTER UPDATE ON recipes BEGIN
+			DELETE FROM recipes_fts WHERE rowid = old.position;
+			INSERT INTO recipes_fts (rowid, keywords, ingredients) VALUES (new.position, new.keywords, new.ingredients);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS recipes_fts_delete AFTER DELETE ON recipes BEGIN
+			DELETE FROM recipes_fts WHERE rowid = old.position;
+		END`,
+	},
+}
+
+// addedColumns lists the columns added to Schema since its first version, with the statements
+// adding them to a table created before.
+var addedColumns = []struct{ name, add string }{
+	{"keywords", `ALTER TABLE recipes ADD COLUMN keywords TEXT NOT NULL DEFAULT ''`},
+	{"ingredients", `ALTER TABLE recipes ADD COLUMN ingredients TEXT NOT NULL DEFAULT ''`},
+	{"status", `ALTER TABLE recipes ADD COLUMN status TEXT NOT NULL DEFAULT 'published'`},
+	{"archived", `ALTER TABLE recipes ADD COLUMN archived BOOLEAN NOT NULL DEFAULT FALSE`},
+	{"merged_ids", `ALTER TABLE recipes ADD COLUMN merged_ids TEXT NOT NULL DEFAULT ''`},
+	{"merged_slugs", `ALTER TABLE recipes ADD COLUMN merged_slugs TEXT NOT NULL DEFAULT ''`},
+}
+
+// DefaultTimeout bounds each database call of a Store whose Timeout is not set.
+const DefaultTimeout = 5 * time.Second
+
+// Defaults for a Store whose MaxLag or LagCheck is not set.
+const (
+	DefaultMaxLag   = 5 * time.Second
+	DefaultLagCheck = 10 * time.Second
+)
+
+// LagQuery returns how many seconds a PostgreSQL replica is behind its primary: 0 when it has
+// replayed all it received, which keeps an idle primary from making it look stale, and on a
+// primary.
+const LagQuery = `SELECT CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+	ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) END`
+
+// Store is a recipe store backed by a SQL database, with the methods of resolver.MemoryStore.
+// Those methods have no error results, so database errors are logged and the call reports
+// nothing found or changed. It is safe for concurrent use.
+type Store struct {
+	DB *sql.DB
+	// Logger receives database errors.
+	Logger *log.Logger
+	// Timeout bounds each database call.
+	Timeout time.Duration
+	// Dialect is the database's SQL dialect, Postgres unless opened by OpenSQLite.
+	Dialect Dialect
+	// OnChange, if non-nil, is called with the IDs of the recipes changed by Update, Delete or
+	// Archive, after the change, e.g. to invalidate cached results (see Resolver.Invalidate).
+	OnChange func(ids []string)
+
+	// Replica, if non-nil, is a read-only replica of DB serving All, Get, BySlug, MergedInto
+	// and Search.
+	// Reads go to DB instead while the replica lags more than MaxLag or fails, which is checked
+	// every LagCheck, and for MaxLag after a write through the Store, so that it reads its own
+	// writes.
+	Replica *sql.DB
+	// MaxLag is the replication lag beyond which reads go to DB.
+	MaxLag time.Duration
+	// LagCheck is how often the replica's lag is checked.
+	LagCheck time.Duration
+
+	mu        sync.Mutex
+	checked   time.Time // when the replica was last checked
+	replicaOK bool
+	wrote     time.Time // when the Store last wrote
+
+	// Within a unit of work (see Atomically), the Store writes through tx, records its first
+	// error in txErr and collects the changed recipes until the commit.
+	tx      *sql.Tx
+	txErr   error
+	changes []string
+}
+
+// conn is what a Store runs statements on: its database or a transaction.
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// conn returns the transaction of a unit of work, or else the database.
+func (s *Store) conn() conn {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.DB
+}
+
+// New returns a Store over db, logging through the standard logger.
+func New(db *sql.DB) *Store {
+	return &Store{DB: db, Logger: log.Default(), Timeout: DefaultTimeout}
+}
+
+// Open connects to the database at dsn through the named driver, checks that it answers and
+// creates the schema.
+func Open(ctx context.Context, driver, dsn string) (*Store, error) {
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	s := New(db)
+	if err := s.Migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// OpenSQLite opens the SQLite database file at path through the named driver, creating it and
+// the schema if needed. The file is used in write-ahead-log mode through a single connection,
+// since SQLite serializes writes anyway and concurrent connections would fail with
+// SQLITE_BUSY.
+func OpenSQLite(ctx context.Context, driver, path string) (*Store, error) {
+	db, err := sql.Open(driver, path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	s := New(db)
+	s.Dialect = SQLite
+	ctx, cancel := s.bound(ctx)
+	defer cancel()
+	for _, pragma := range []string{"PRAGMA journal_mode = WAL", "PRAGMA busy_timeout = 5000"} {
+		if _, err := db.ExecContext(ctx, pragma); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("configuring %s: %w", path, err)
+		}
+	}
+	if err := s.Migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Migrate creates the schema and indexes if needed, or upgrades a table created by an earlier
+// version: it adds the missing columns, moves recipes sharing a position to the end, fills in
+// the search columns of the recipes lacking them, or of all recipes if columns were added, and
+// indexes them for full-text search.
+func (s *Store) Migrate(ctx context.Context) error {
+	ctx, cancel := s.bound(ctx)
+	defer cancel()
+	if _, err := s.DB.ExecContext(ctx, Schema); err != nil {
+		return fmt.Errorf("creating recipes table: %w", err)
+	}
+	moved, err := s.uniquePositions(ctx)
+	if err != nil {
+		return err
+	}
+	stale := `SELECT data FROM recipes WHERE keywords = '' ORDER BY position`
+	for _, c := range addedColumns {
+		rows, err := s.DB.QueryContext(ctx, `SELECT `+c.name+` FROM recipes WHERE 1 = 0`)
+		if err == nil {
+			rows.Close()
+			continue
+		}
+		if _, err := s.DB.ExecContext(ctx, c.add); err != nil {
+			return fmt.Errorf("adding column %s: %w", c.name, err)
+		}
+		stale = `SELECT data FROM recipes ORDER BY position`
+	}
+	if _, err := s.index(ctx, stale); err != nil {
+		return err
+	}
+	return s.migrateSearch(ctx, moved > 0)
+}
+
+// uniquePositions gives each recipe sharing its position with another added before it (or with
+// a lower ID) the next free position, then indexes positions as unique, replacing the plain
+// index of earlier versions. Concurrent Adds before that could pick the same position. It
+// returns how many recipes were moved.
+func (s *Store) uniquePositions(ctx context.Context) (int, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT id FROM recipes WHERE EXISTS
+		(SELECT 1 FROM recipes other WHERE other.position = recipes.position AND other.id < recipes.id)`)
+	if err != nil {
+		return 0, fmt.Errorf("listing recipes sharing a position: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("listing recipes sharing a position: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("listing recipes sharing a position: %w", err)
+	}
+	for _, id := range ids {
+		if _, err := s.DB.ExecContext(ctx, `UPDATE recipes SET position = (SELECT MAX(position) FROM recipes) + 1 WHERE id = $1`, id); err != nil {
+			return 0, fmt.Errorf("moving recipe %s: %w", id, err)
+		}
+	}
+	for _, stmt := range []string{
+		`DROP INDEX IF EXISTS recipes_position`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS recipes_position_key ON recipes (position)`,
+	} {
+		if _, err := s.DB.ExecContext(ctx, stmt); err != nil {
+			return 0, fmt.Errorf("creating recipes position index: %w", err)
+		}
+	}
+	return len(ids), nil
+}
+
+// Reindex implements resolver.Reindexer: it recomputes the search columns of every stored
+// recipe, which the full-text index follows.
+func (s *Store) Reindex(ctx context.Context) (int, error) {
+	ctx, cancel := s.bound(ctx)
+	defer cancel()
+	return s.index(ctx, `SELECT data FROM recipes ORDER BY position`)
+}
+
+// index stores the search words, status, archiving and merged recipes of the recipes selected
+// by stmt and returns how many there were.
+func (s *Store) index(ctx context.Context, stmt string) (int, error) {
+	recipes, err := s.query(ctx, s.DB, stmt)
+	if err != nil {
+		return 0, fmt.Errorf("listing recipes to index: %w", err)
+	}
+	for _, r := range recipes {
+		keywords, ingredients := searchText(r)
+		mergedIDs, mergedSlugs := mergedText(r)
+		if _, err := s.DB.ExecContext(ctx, `UPDATE recipes SET keywords = $1, ingredients = $2, status = $3, archived = $4, merged_ids = $5, merged_slugs = $6
+			WHERE id = $7`, keywords, ingredients, reviewStatus(r), r.Archived, mergedIDs, mergedSlugs, r.ID); err != nil {
+			return 0, fmt.Errorf("indexing recipe %s: %w", r.ID, err)
+		}
+	}
+	return len(recipes), nil
+}
+
+// migrateSearch creates the full-text index of the store's dialect if needed. A new SQLite
+// index is filled with the recipes already stored, and an existing one refilled if positions
+// moved, since they key it; the triggers keep it up to date after that.
+func (s *Store) migrateSearch(ctx context.Context, moved bool) error {
+	fill := false
+	if s.Dialect == SQLite {
+		rows, err := s.DB.QueryContext(ctx, `SELECT rowid FROM recipes_fts WHERE 1 = 0`)
+		if err == nil {
+			rows.Close()
+		}
+		fill = err != nil
+		if !fill && moved {
+			if _, err := s.DB.ExecContext(ctx, `DELETE FROM recipes_fts`); err != nil {
+				return fmt.Errorf("clearing search index: %w", err)
+			}
+			fill = true
+		}
+	}
+	for _, stmt := range searchIndexes[s.Dialect] {
+		if _, err := s.DB.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("creating search index: %w", err)
+		}
+	}
+	if fill {
+		if _, err := s.DB.ExecContext(ctx, `INSERT INTO recipes_fts (rowid, keywords, ingredients)
+			SELECT position, keywords, ingredients FROM recipes`); err != nil {
+			return fmt.Errorf("filling search index: %w", err)
+		}
+	}
+	return nil
+}
+
+// searchText returns the words r is searched by, lowercased and separated by spaces: those of
+// its title and, for a persisted generation, of the query it answered (see
+// model.Recipe.GeneratedFor), and those of its ingredients.
+func searchText(r model.Recipe) (keywords, ingredients string) {
+	text := r.Title
+	if r.Provenance != nil && r.Provenance.Query != "" {
+		text += " " + r.Provenance.Query
+	}
+	return strings.Join(nlp.Tokenize(text), " "), strings.Join(nlp.Tokenize(strings.Join(r.Ingredients, " ")), " ")
+}
+
+// mergedText returns the IDs and slugs of the recipes merged into r, as stored in the merged_ids
+// and merged_slugs columns: each followed and preceded by a space, so that LIKE '% id %' finds
+// one.
+func mergedText(r model.Recipe) (ids, slugs string) {
+	for _, m := range r.MergedFrom {
+		ids += " " + m.ID + " "
+		if m.Slug != "" {
+			slugs += " " + m.Slug + " "
+		}
+	}
+	return ids, slugs
+}
+
+// reviewStatus returns the review status of r stored in the status column, published if unset.
+func reviewStatus(r model.Recipe) string {
+	if r.Published() {
+		return string(model.StatusPublished)
+	}
+	return string(r.Status)
+}
+
+// bound returns ctx limited to the store's timeout.
+func (s *Store) bound(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// fail reports a database error, failing the unit of work if there is one.
+func (s *Store) fail(err error, format string, args ...interface{}) {
+	if s.tx != nil && s.txErr == nil {
+		s.txErr = err
+	}
+	if s.Logger != nil {
+		s.Logger.Printf("sqlstore: "+format+": %v", append(args, err)...)
+	}
+}
+
+// Atomically implements resolver.Transactor: fn writes through a transaction, which is
+// committed if fn and all its writes succeed, and rolled back otherwise. The error of a failed
+// write is returned rather than fn's, which may only be what fn made of the write reporting
+// nothing changed. OnChange is called once for all the recipes changed, after the commit.
+func (s *Store) Atomically(ctx context.Context, fn func(w resolver.Writer) error) error {
+	if s.tx != nil {
+		// Already in a unit of work: join it.
+		return fn(s)
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	unit := &Store{DB: s.DB, Logger: s.Logger, Timeout: s.Timeout, Dialect: s.Dialect, tx: tx}
+	if err = fn(unit); unit.txErr != nil {
+		err = unit.txErr
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.written()
+	s.changed(unit.changes)
+	return nil
+}
+
+// replica returns the replica if reads should go to it.
+func (s *Store) replica(ctx context.Context) conn {
+	if s.Replica == nil || s.tx != nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.wrote) < s.maxLag() {
+		return nil
+	}
+	interval := s.LagCheck
+	if interval <= 0 {
+		interval = DefaultLagCheck
+	}
+	if time.Since(s.checked) >= interval {
+		err := s.checkReplica(ctx)
+		if err != nil && s.replicaOK && s.Logger != nil {
+			s.Logger.Printf("sqlstore: reading from the primary: %v", err)
+		}
+		s.replicaOK, s.checked = err == nil, time.Now()
+	}
+	if !s.replicaOK {
+		return nil
+	}
+	return s.Replica
+}
+
+// checkReplica returns an error if the replica does not answer or lags more than MaxLag.
+func (s *Store) checkReplica(ctx context.Context) error {
+	ctx, cancel := s.bound(ctx)
+	defer cancel()
+	var seconds float64
+	if err := s.Replica.QueryRowContext(ctx, LagQuery).Scan(&seconds); err != nil {
+		return fmt.Errorf("checking replica: %w", err)
+	}
+	if lag := time.Duration(seconds * float64(time.Second)); lag > s.maxLag() {
+		return fmt.Errorf("replica lags %s behind", lag.Round(time.Millisecond))
+	}
+	return nil
+}
+
+func (s *Store) maxLag() time.Duration {
+	if s.MaxLag <= 0 {
+		return DefaultMaxLag
+	}
+	return s.MaxLag
+}
+
+// written records a write through the Store, sending its reads to the primary for MaxLag.
+func (s *Store) written() {
+	if s.Replica == nil {
+		return
+	}
+	s.mu.Lock()
+	s.wrote = time.Now()
+	s.mu.Unlock()
+}
+
+// read returns the recipes selected by a statement from the replica if it is usable, or else
+// from the primary. A replica failing the statement is not used until its next check.
+func (s *Store) read(ctx context.Context, query string, args ...interface{}) ([]model.Recipe, error) {
+	if replica := s.replica(ctx); replica != nil {
+		recipes, err := s.query(ctx, replica, query, args...)
+		if err == nil || ctx.Err() != nil {
+			return recipes, err
+		}
+		s.mu.Lock()
+		s.replicaOK, s.checked = false, time.Now()
+		s.mu.Unlock()
+		if s.Logger != nil {
+			s.Logger.Printf("sqlstore: reading from the primary: replica failed: %v", err)
+		}
+	}
+	return s.query(ctx, s.conn(), query, args...)
+}
+
+// primary returns the recipes selected by a statement from the primary, or within a unit of
+// work its transaction.
+func (s *Store) primary(ctx context.Context, query string, args ...interface{}) ([]model.Recipe, error) {
+	return s.query(ctx, s.conn(), query, args...)
+}
+
+// query returns the recipes of the rows selected on c by a statement whose only column is data.
+func (s *Store) query(ctx context.Context, c conn, query string, args ...interface{}) ([]model.Recipe, error) {
+	ctx, cancel := s.bound(ctx)
+	defer cancel()
+	rows, err := c.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var recipes []model.Recipe
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var r model.Recipe
+		if err := json.Unmarshal([]byte(data), &r); err != nil {
+			return nil, fmt.Errorf("decoding stored recipe: %w", err)
+		}
+		recipes = append(recipes, r)
+	}
+	return recipes, rows.Err()
+}
+
+// Statements selecting a recipe by ID or slug.
+const (
+	byID   = `SELECT data FROM recipes WHERE id = $1`
+	bySlug = `SELECT data FROM recipes WHERE slug = $1`
+)
+
+// one returns the recipe of the single row selected through fetch (read or primary) by a
+// statement, if any.
+func (s *Store) one(fetch func(context.Context, string, ...interface{}) ([]model.Recipe, error), query string, args ...interface{}) (model.Recipe, bool) {
+	recipes, err := fetch(context.Background(), query, args...)
+	if err != nil {
+		s.fail(err, "reading recipe")
+		return model.Recipe{}, false
+	}
+	if len(recipes) == 0 {
+		return model.Recipe{}, false
+	}
+	return recipes[0], true
+}
+
+// All returns every recipe in the store, in the order they were added.
+func (s *Store) All() []model.Recipe {
+	recipes, err := s.read(context.Background(), `SELECT data FROM recipes ORDER BY position`)
+	if err != nil {
+		s.fail(err, "listing recipes")
+		return nil
+	}
+	return recipes
+}
+
+// Get returns the recipe with the given ID.
+func (s *Store) Get(id string) (model.Recipe, bool) {
+	return s.one(s.read, byID, id)
+}
+
+// BySlug returns the recipe with the given slug.
+func (s *Store) BySlug(slug string) (model.Recipe, bool) {
+	return s.one(s.read, bySlug, slug)
+}
+
+// likeEscaper escapes the LIKE wildcards of a value, for patterns with ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// MergedInto implements resolver.MergeFinder, selecting the row whose merged_ids or, if id is
+// empty, merged_slugs contain the one given.
+func (s *Store) MergedInto(id, slug string) (model.Recipe, bool) {
+	column, value := "merged_ids", id
+	if id == "" {
+		column, value = "merged_slugs", slug
+	}
+	if value == "" {
+		return model.Recipe{}, false
+	}
+	return s.one(s.read, `SELECT data FROM recipes WHERE `+column+` LIKE $1 ESCAPE '\' ORDER BY position`,
+		"% "+likeEscaper.Replace(value)+" %")
+}
+
+// Search implements resolver.Searcher: it returns up to limit recipes, or all if limit is 0,
+// whose title, generation query or ingredients contain words of query, through the full-text
+// index. The best matches, ranked by the database with title and query words weighing more,
+// come first, then the recipes in the order they were added, so that matching runs against the
+// rows selected by the database.
+func (s *Store) Search(ctx context.Context, query string, limit int) ([]model.Recipe, error) {
+	return s.SearchFiltered(ctx, query, limit, resolver.Filter{})
+}
+
+// SearchFiltered implements resolver.FilteredSearcher like Search, selecting only the rows
+// whose status and archived columns f keeps.
+func (s *Store) SearchFiltered(ctx context.Context, query string, limit int, f resolver.Filter) ([]model.Recipe, error) {
+	stmt, args := s.searchQuery(nlp.Tokenize(query), limit, f)
+	if stmt == "" {
+		return nil, nil
+	}
+	return s.read(ctx, stmt, args...)
+}
+
+// searchQuery returns the statement and arguments selecting up to limit recipes kept by f
+// whose search words include at least one of words, or all of them if limit is 0, or ""
+// without words.
+func (s *Store) searchQuery(words []string, limit int, f resolver.Filter) (string, []interface{}) {
+	if len(words) == 0 {
+		return "", nil
+	}
+	terms := make([]string, len(words))
+	var stmt, order, or, table string
+	switch s.Dialect {
+	case SQLite:
+		for i, w := range words {
+			terms[i] = `"` + w + `"`
+		}
+		stmt = `SELECT recipes.data FROM recipes_fts JOIN recipes ON recipes.position = recipes_fts.rowid
+			WHERE recipes_fts MATCH $1`
+		order, or, table = ` ORDER BY bm25(recipes_fts, 2.0, 1.0), recipes.position`, " OR ", "recipes."
+	default:
+		for i, w := range words {
+			terms[i] = "'" + w + "'"
+		}
+		stmt = `SELECT data FROM recipes WHERE search @@ to_tsquery('simple', $1)`
+		order, or = ` ORDER BY ts_rank(search, to_tsquery('simple', $1)) DESC, position`, " | "
+	}
+	stmt, args := filtered(stmt, []interface{}{strings.Join(terms, or)}, f, table)
+	stmt += order
+	if limit > 0 {
+		args = append(args, limit)
+		stmt += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	return stmt, args
+}
+
+// filtered returns stmt, ending with a WHERE clause, and its args extended with the conditions
+// selecting the rows f keeps, on the status and archived columns of table ("" or a prefix such as
+// "recipes.").
+func filtered(stmt string, args []interface{}, f resolver.Filter, table string) (string, []interface{}) {
+	if len(f.Statuses) > 0 {
+		placeholders := make([]string, len(f.Statuses))
+		for i, st := range f.Statuses {
+			args = append(args, string(st))
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		stmt += " AND " + table + "status IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+	if f.Unarchived {
+		stmt += " AND NOT " + table + "archived"
+	}
+	return stmt, args
+}
+
+// List implements resolver.Lister, selecting the rows whose status and archived columns f
+// keeps.
+func (s *Store) List(ctx context.Context, f resolver.Filter) ([]model.Recipe, error) {
+	stmt, args := filtered(`SELECT data FROM recipes WHERE TRUE`, nil, f, "")
+	return s.read(ctx, stmt+" ORDER BY position", args...)
+}
+
+// Save implements resolver.Saver, updating or adding r in a unit of work so that database
+// errors are reported.
+func (s *Store) Save(ctx context.Context, r model.Recipe) error {
+	return s.Atomically(ctx, func(w resolver.Writer) error {
+		if w.Update(r) {
+			return nil
+		}
+		return w.Add(r)
+	})
+}
+
+// Add inserts recipes into the store, each at the next position. Recipes without a slug get one
+// derived from their title, and slugs already taken get a numeric suffix, e.g. "pancakes-2".
+// Recipes whose ID is already stored are skipped and reported with resolver.ErrExists.
+func (s *Store) Add(recipes ...model.Recipe) error {
+	if len(recipes) == 0 {
+		return nil
+	}
+	ctx, cancel := s.bound(context.Background())
+	defer cancel()
+	defer s.written()
+	taken, err := s.slugs(ctx)
+	if err != nil {
+		s.fail(err, "adding recipes")
+		return err
+	}
+	var errs []error
+	for _, r := range recipes {
+		if err := s.insert(ctx, r, taken); err != nil {
+			if !errors.Is(err, resolver.ErrExists) {
+				s.fail(err, "adding recipe %s", r.ID)
+			}
+			errs = append(errs, fmt.Errorf("adding recipe %s: %w", r.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// insertAttempts bounds how many times insert tries a recipe whose slug or position a
+// concurrent writer keeps taking.
+const insertAttempts = 5
+
+// insert stores r at the position after the last one, under its slug or one derived from its
+// title that is not in taken, and adds the slug to taken. It fails with resolver.ErrExists if
+// r's ID is stored. When another writer takes the slug or position first, the unique indexes
+// make the insert do nothing and it is tried again with the slugs stored since.
+func (s *Store) insert(ctx context.Context, r model.Recipe, taken map[string]bool) error {
+	keywords, ingredients := searchText(r)
+	mergedIDs, mergedSlugs := mergedText(r)
+	for attempt := 1; ; attempt++ {
+		r.Slug = uniqueSlug(r, taken)
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		res, err := s.conn().ExecContext(ctx, `INSERT INTO recipes (id, slug, title, position, data, keywords, ingredients, status, archived, merged_ids, merged_slugs)
+			VALUES ($1, $2, $3, (SELECT COALESCE(MAX(position), 0) + 1 FROM recipes), $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT DO NOTHING`, r.ID, r.Slug, r.Title, string(data), keywords, ingredients, reviewStatus(r), r.Archived, mergedIDs, mergedSlugs)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n > 0 {
+			taken[r.Slug] = true
+			return nil
+		}
+		stored, err := s.query(ctx, s.conn(), byID, r.ID)
+		if err != nil {
+			return err
+		}
+		if len(stored) > 0 {
+			return resolver.ErrExists
+		}
+		if attempt == insertAttempts {
+			return fmt.Errorf("slug %q or position kept being taken by concurrent writers", r.Slug)
+		}
+		current, err := s.slugs(ctx)
+		if err != nil {
+			return err
+		}
+		maps.Copy(taken, current)
+	}
+}
+
+// slugs returns the set of slugs in use.
+func (s *Store) slugs(ctx context.Context) (map[string]bool, error) {
+	rows, err := s.conn().QueryContext(ctx, `SELECT slug FROM recipes`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	taken := make(map[string]bool)
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil {
+			return nil, err
+		}
+		taken[slug] = true
+	}
+	return taken, rows.Err()
+}
+
+// uniqueSlug returns r's slug, or one derived from its title, with a numeric suffix if it is
+// taken.
+func uniqueSlug(r model.Recipe, taken map[string]bool) string {
+	base := r.Slug
+	if base == "" {
+		base = model.Slugify(r.Title)
+	}
+	slug := base
+	for n := 2; taken[slug]; n++ {
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+	return slug
+}
+
+// Update replaces the stored recipe with the same ID as r and reports whether one was found.
+// The recipe keeps its slug unless r sets a different one that is not taken, so that links to
+// it stay valid when its title changes.
+func (s *Store) Update(r model.Recipe) bool {
+	old, ok := s.one(s.primary, byID, r.ID)
+	if !ok {
+		return false
+	}
+	if r.Slug != old.Slug {
+		if _, taken := s.one(s.primary, bySlug, r.Slug); r.Slug == "" || taken {
+			r.Slug = old.Slug
+		}
+	}
+	if !s.save(r) {
+		return false
+	}
+	s.changed([]string{r.ID})
+	return true
+}
+
+// save writes r over the stored recipe with its ID and reports whether there was one.
+func (s *Store) save(r model.Recipe) bool {
+	data, err := json.Marshal(r)
+	if err != nil {
+		s.fail(err, "updating recipe %s", r.ID)
+		return false
+	}
+	ctx, cancel := s.bound(context.Background())
+	defer cancel()
+	defer s.written()
+	keywords, ingredients := searchText(r)
+	mergedIDs, mergedSlugs := mergedText(r)
+	res, err := s.conn().ExecContext(ctx, `UPDATE recipes SET slug = $1, title = $2, data = $3, keywords = $4, ingredients = $5, status = $6, archived = $7,
+		merged_ids = $8, merged_slugs = $9 WHERE id = $10`, r.Slug, r.Title, string(data), keywords, ingredients, reviewStatus(r), r.Archived, mergedIDs, mergedSlugs, r.ID)
+	if err != nil {
+		s.fail(err, "updating recipe %s", r.ID)
+		return false
+	}
+	n, err := res.RowsAffected()
+	return err == nil && n > 0
+}
+
+// Delete removes the recipes with the given IDs and returns how many were removed.
+func (s *Store) Delete(ids ...string) int {
+	ctx, cancel := s.bound(context.Background())
+	defer cancel()
+	defer s.written()
+	var removed []string
+	for _, id := range ids {
+		res, err := s.conn().ExecContext(ctx, `DELETE FROM recipes WHERE id = $1`, id)
+		if err != nil {
+			s.fail(err, "deleting recipe %s", id)
+			continue
+		}
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			removed = append(removed, id)
+		}
+	}
+	s.changed(removed)
+	return len(removed)
+}
+
+// Archive sets the Archived flag of the recipes with the given IDs and returns how many
+// changed.
+func (s *Store) Archive(archived bool, ids ...string) int {
+	var changed []string
+	for _, id := range ids {
+		r, ok := s.one(s.primary, byID, id)
+		if !ok || r.Archived == archived {
+			continue
+		}
+		r.Archived = archived
+		if s.save(r) {
+			changed = append(changed, id)
+		}
+	}
+	s.changed(changed)
+	return len(changed)
+}
+
+// changed reports the changed recipes ids to OnChange, or within a unit of work collects them
+// until the commit.
+func (s *Store) changed(ids []string) {
+	if s.tx != nil {
+		s.changes = append(s.changes, ids...)
+		return
+	}
+	if len(ids) > 0 && s.OnChange != nil {
+		s.OnChange(ids)
+	}
+}
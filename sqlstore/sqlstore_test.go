@@ -0,0 +1,634 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pageza/recipe-resolver-ms/model"
+	"github.com/pageza/recipe-resolver-ms/resolver"
+)
+
+// fakeDB is an in-memory stand-in for the database, understanding only the statements Store
+// issues.
+type fakeDB struct {
+	mu   sync.Mutex
+	rows []fakeRow
+	// fail, if set, fails every statement.
+	fail error
+	// lag is the replication lag reported by LagQuery, in seconds.
+	lag float64
+	// statements lists the statements other than queries run, in order.
+	statements []string
+	// missing lists the columns of a recipes table created by an earlier version that it lacks.
+	missing map[string]bool
+	// fts is set once the SQLite full-text table is created.
+	fts bool
+}
+
+// fakeDBs holds the fake databases opened through the "fakesql" driver, by name.
+var fakeDBs sync.Map
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	db, _ := fakeDBs.LoadOrStore(name, &fakeDB{})
+	return fakeConn{db.(*fakeDB)}, nil
+}
+
+func init() { sql.Register("fakesql", fakeDriver{}) }
+
+type fakeRow struct {
+	id, slug, title, data  string
+	keywords, ingredients  string
+	status                 string
+	archived               bool
+	mergedIDs, mergedSlugs string
+	position               int64
+}
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{db}, nil }
+func (db *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c fakeConn) Close() error                        { return nil }
+
+func (c fakeConn) Begin() (driver.Tx, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	return &fakeTx{db: c.db, saved: append([]fakeRow(nil), c.db.rows...)}, nil
+}
+
+// fakeTx restores the rows as they were at its start on rollback. It does not isolate
+// concurrent transactions.
+type fakeTx struct {
+	db    *fakeDB
+	saved []fakeRow
+}
+
+func (tx *fakeTx) Commit() error { return nil }
+func (tx *fakeTx) Rollback() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.db.rows = tx.saved
+	return nil
+}
+
+func (c fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	db := c.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.fail != nil {
+		return nil, db.fail
+	}
+	arg := func(i int) string { return fmt.Sprint(args[i].Value) }
+	db.statements = append(db.statements, strings.SplitN(strings.SplitN(query, " (", 2)[0], "\n", 2)[0])
+	switch {
+	case strings.HasPrefix(query, "PRAGMA"):
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(query, "CREATE TABLE"), strings.HasPrefix(query, "CREATE INDEX"),
+		strings.HasPrefix(query, "CREATE UNIQUE INDEX"), strings.HasPrefix(query, "DROP INDEX"),
+		strings.HasPrefix(query, "CREATE TRIGGER"), strings.HasPrefix(query, "INSERT INTO recipes_fts"),
+		strings.HasPrefix(query, "DELETE FROM recipes_fts"):
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(query, "CREATE VIRTUAL TABLE"):
+		db.fts = true
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(query, "ALTER TABLE recipes ADD COLUMN"):
+		delete(db.missing, strings.Fields(query)[5])
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(query, "INSERT INTO recipes"):
+		var position int64
+		for _, r := range db.rows {
+			if r.id == arg(0) {
+				return driver.RowsAffected(0), nil
+			}
+			if r.slug == arg(1) {
+				return driver.RowsAffected(0), nil
+			}
+			position = max(position, r.position)
+		}
+		db.rows = append(db.rows, fakeRow{id: arg(0), slug: arg(1), title: arg(2), data: arg(3), keywords: arg(4), ingredients: arg(5),
+			status: arg(6), archived: args[7].Value == true, mergedIDs: arg(8), mergedSlugs: arg(9), position: position + 1})
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(query, "UPDATE recipes SET keywords"):
+		for i, r := range db.rows {
+			if r.id == arg(6) {
+				row := &db.rows[i]
+				row.keywords, row.ingredients, row.status, row.archived = arg(0), arg(1), arg(2), args[3].Value == true
+				row.mergedIDs, row.mergedSlugs = arg(4), arg(5)
+				return driver.RowsAffected(1), nil
+			}
+		}
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(query, "UPDATE recipes SET position"):
+		var position int64
+		for _, r := range db.rows {
+			position = max(position, r.position)
+		}
+		for i, r := range db.rows {
+			if r.id == arg(0) {
+				db.rows[i].position = position + 1
+				return driver.RowsAffected(1), nil
+			}
+		}
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(query, "UPDATE recipes"):
+		for i, r := range db.rows {
+			if r.id == arg(9) {
+				row := &db.rows[i]
+				row.slug, row.title, row.data, row.keywords, row.ingredients = arg(0), arg(1), arg(2), arg(3), arg(4)
+				row.status, row.archived, row.mergedIDs, row.mergedSlugs = arg(5), args[6].Value == true, arg(7), arg(8)
+				return driver.RowsAffected(1), nil
+			}
+		}
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(query, "DELETE FROM recipes"):
+		for i, r := range db.rows {
+			if r.id == arg(0) {
+				db.rows = append(db.rows[:i], db.rows[i+1:]...)
+				return driver.RowsAffected(1), nil
+			}
+		}
+		return driver.RowsAffected(0), nil
+	}
+	return nil, fmt.Errorf("unexpected statement %q", query)
+}
+
+func (c fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	db := c.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.fail != nil {
+		return nil, db.fail
+	}
+	rows := append([]fakeRow(nil), db.rows...)
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].position < rows[j].position })
+	var out []string
+	switch {
+	case query == LagQuery:
+		out = append(out, fmt.Sprint(db.lag))
+	case query == `SELECT rowid FROM recipes_fts WHERE 1 = 0`:
+		if !db.fts {
+			return nil, errors.New("no such table")
+		}
+	case strings.HasSuffix(query, "FROM recipes WHERE 1 = 0"):
+		if db.missing[strings.Fields(query)[1]] {
+			return nil, errors.New("no such column")
+		}
+	case strings.Contains(query, "other.position = recipes.position"):
+		for _, r := range rows {
+			for _, other := range rows {
+				if other.position == r.position && other.id < r.id {
+					out = append(out, r.id)
+					break
+				}
+			}
+		}
+	case strings.Contains(query, "WHERE keywords = ''"):
+		for _, r := range rows {
+			if r.keywords == "" {
+				out = append(out, r.data)
+			}
+		}
+	case query == `SELECT slug FROM recipes`:
+		for _, r := range rows {
+			out = append(out, r.slug)
+		}
+	case strings.HasSuffix(query, "ORDER BY position") && !strings.Contains(query, "WHERE"):
+		for _, r := range rows {
+			out = append(out, r.data)
+		}
+	case strings.HasSuffix(query, "WHERE id = $1"), strings.HasSuffix(query, "WHERE slug = $1"):
+		for _, r := range rows {
+			if strings.HasSuffix(query, "id = $1") && r.id == args[0].Value || strings.HasSuffix(query, "slug = $1") && r.slug == args[0].Value {
+				out = append(out, r.data)
+			}
+		}
+	case strings.HasPrefix(query, "SELECT data FROM recipes WHERE TRUE"):
+		statuses := make(map[any]bool)
+		for _, a := range args {
+			statuses[a.Value] = true
+		}
+		for _, r := range rows {
+			if (len(statuses) == 0 || statuses[r.status]) && !(strings.Contains(query, "archived") && r.archived) {
+				out = append(out, r.data)
+			}
+		}
+	case strings.Contains(query, "LIKE $1"):
+		// The pattern is a value between "% " and " %", without wildcards to escape.
+		value := " " + strings.Trim(args[0].Value.(string), "% ") + " "
+		for _, r := range rows {
+			if strings.Contains(query, "merged_ids") && strings.Contains(r.mergedIDs, value) ||
+				strings.Contains(query, "merged_slugs") && strings.Contains(r.mergedSlugs, value) {
+				out = append(out, r.data)
+			}
+		}
+	case strings.Contains(query, "to_tsquery"), strings.Contains(query, "MATCH"):
+		// Both dialects are searched by quoted words, separated by operators, then filtered by
+		// the statuses following them and archiving.
+		limit := int64(len(rows))
+		if strings.Contains(query, "LIMIT") {
+			limit, args = args[len(args)-1].Value.(int64), args[:len(args)-1]
+		}
+		statuses := make(map[any]bool)
+		for _, a := range args[1:] {
+			statuses[a.Value] = true
+		}
+		rows = slices.DeleteFunc(rows, func(r fakeRow) bool {
+			return len(statuses) > 0 && !statuses[r.status] || strings.Contains(query, "archived") && r.archived
+		})
+		var words []string
+		for _, term := range strings.Fields(args[0].Value.(string)) {
+			if term != "|" && term != "OR" {
+				words = append(words, strings.Trim(term, `'"`))
+			}
+		}
+		hits := func(r fakeRow) int {
+			n := 0
+			for _, w := range words {
+				if slices.Contains(strings.Fields(r.keywords), w) {
+					n += 2
+				}
+				if slices.Contains(strings.Fields(r.ingredients), w) {
+					n++
+				}
+			}
+			return n
+		}
+		sort.SliceStable(rows, func(i, j int) bool { return hits(rows[i]) > hits(rows[j]) })
+		for _, r := range rows {
+			if hits(r) > 0 && int64(len(out)) < limit {
+				out = append(out, r.data)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unexpected query %q", query)
+	}
+	return &fakeRows{values: out}, nil
+}
+
+type fakeRows struct{ values []string }
+
+func (r *fakeRows) Columns() []string { return []string{"value"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
+
+// newTestStore returns a Store over an empty fake database, with a silent logger.
+func newTestStore(t *testing.T) (*Store, *fakeDB) {
+	db := &fakeDB{}
+	s := New(sql.OpenDB(db))
+	s.Logger = log.New(io.Discard, "", 0)
+	if err := s.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return s, db
+}
+
+// TestStore verifies that recipes round-trip through the database with unique, stable slugs,
+// and that updates, archiving and deletions are reported to OnChange.
+func TestStore(t *testing.T) {
+	s, _ := newTestStore(t)
+	var changed []string
+	s.OnChange = func(ids []string) { changed = append(changed, ids...) }
+	first := model.NewRecipe("Pancakes", []string{"flour"}, []string{"Fry."}, nil, "", nil)
+	second := model.NewRecipe("Pancakes!", nil, nil, nil, "", nil)
+	soup := model.NewRecipe("Tomato Soup", nil, nil, nil, "", nil)
+	if err := s.Add(first, second, soup); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(first); !errors.Is(err, resolver.ErrExists) {
+		t.Errorf("Expected adding a stored ID to fail with ErrExists, got %v", err)
+	}
+
+	all := s.All()
+	if len(all) != 3 || all[0].ID != first.ID || all[0].Ingredients[0] != "flour" {
+		t.Fatalf("Expected the three recipes in order, got %+v", all)
+	}
+	if got, ok := s.BySlug("pancakes-2"); !ok || got.ID != second.ID {
+		t.Errorf("Expected the second pancakes recipe at pancakes-2, got %+v", got)
+	}
+
+	first.Title, first.Slug = "Fluffy Pancakes", "pancakes-2"
+	if !s.Update(first) {
+		t.Fatal("Expected the update to find the recipe")
+	}
+	if got, _ := s.Get(first.ID); got.Title != "Fluffy Pancakes" || got.Slug != "pancakes" {
+		t.Errorf("Expected the new title under the old slug, got %+v", got)
+	}
+	if s.Update(model.NewRecipe("Missing", nil, nil, nil, "", nil)) {
+		t.Error("Expected no update for an unknown recipe")
+	}
+	if n := s.Archive(true, soup.ID, "missing"); n != 1 {
+		t.Errorf("Expected one recipe archived, got %d", n)
+	}
+	if got, _ := s.Get(soup.ID); !got.Archived {
+		t.Error("Expected the soup to be archived")
+	}
+	if n := s.Delete(second.ID, second.ID); n != 1 || len(s.All()) != 2 {
+		t.Errorf("Expected one recipe deleted, got %d", n)
+	}
+	if want := []string{first.ID, soup.ID, second.ID}; fmt.Sprint(changed) != fmt.Sprint(want) {
+		t.Errorf("Expected changes %v, got %v", want, changed)
+	}
+}
+
+// TestStoreListSave verifies that the store is a complete Storage, listing the recipes a filter
+// keeps in order and saving new and stored recipes.
+func TestStoreListSave(t *testing.T) {
+	s, _ := newTestStore(t)
+	var st resolver.Storage = s
+	ctx := context.Background()
+	soup := model.NewRecipe("Tomato Soup", nil, nil, nil, "", nil)
+	pie := model.NewRecipe("Chicken Pie", nil, nil, nil, "", nil)
+	pie.Status = model.StatusPendingReview
+	stew := model.NewRecipe("Beef Stew", nil, nil, nil, "", nil)
+	for _, r := range []model.Recipe{soup, pie, stew} {
+		if err := st.Save(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stew.Archived = true
+	if err := st.Save(ctx, stew); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := st.Get(stew.ID); !ok || !got.Archived || got.Slug != "beef-stew" {
+		t.Errorf("Expected the stew saved over, got %+v", got)
+	}
+
+	titles := func(f resolver.Filter) string {
+		recipes, err := st.List(ctx, f)
+		var out []string
+		for _, r := range recipes {
+			out = append(out, r.Title)
+		}
+		return fmt.Sprint(out, err)
+	}
+	if got := titles(resolver.Filter{}); got != "[Tomato Soup Chicken Pie Beef Stew] <nil>" {
+		t.Errorf("Expected every recipe in order, got %s", got)
+	}
+	if got := titles(resolver.Filter{Statuses: []model.Status{model.StatusPublished}, Unarchived: true}); got != "[Tomato Soup] <nil>" {
+		t.Errorf("Expected the published unarchived recipes, got %s", got)
+	}
+	if got := titles(resolver.Filter{Statuses: []model.Status{model.StatusPendingReview}}); got != "[Chicken Pie] <nil>" {
+		t.Errorf("Expected the recipes pending review, got %s", got)
+	}
+}
+
+// TestStoreMergedInto verifies that the recipe another was merged into is found by the merged
+// recipe's ID or slug.
+func TestStoreMergedInto(t *testing.T) {
+	s, _ := newTestStore(t)
+	soup := model.NewRecipe("Tomato Soup", nil, nil, nil, "", nil)
+	soup.MergedFrom = []model.MergedRecipe{{ID: "r1", Slug: "tomato-bisque"}}
+	s.Add(soup)
+	if got, ok := s.MergedInto("r1", ""); !ok || got.ID != soup.ID {
+		t.Errorf("Expected r1 merged into the soup, got %+v", got)
+	}
+	soup.MergedFrom = append(soup.MergedFrom, model.MergedRecipe{ID: "r2", Slug: "gazpacho"})
+	s.Update(soup)
+	if got, ok := s.MergedInto("", "gazpacho"); !ok || got.ID != soup.ID {
+		t.Errorf("Expected gazpacho merged into the soup, got %+v", got)
+	}
+	for _, tt := range [][2]string{{"r", ""}, {"", "tomato"}, {"", ""}, {"tomato-bisque", ""}} {
+		if got, ok := s.MergedInto(tt[0], tt[1]); ok {
+			t.Errorf("MergedInto(%q, %q) = %+v, want none", tt[0], tt[1], got)
+		}
+	}
+}
+
+// TestStoreSearch verifies that searches return the recipes sharing the most words first, title
+// words counting more than ingredients, and find persisted generations by their query.
+func TestStoreSearch(t *testing.T) {
+	s, db := newTestStore(t)
+	s.Add(
+		model.NewRecipe("Chicken Soup", nil, nil, nil, "", nil),
+		model.NewRecipe("Tomato Soup", nil, nil, nil, "", nil),
+		model.NewRecipe("Chicken Tomato Stew", nil, nil, nil, "", nil),
+		model.NewRecipe("Pancakes", nil, nil, nil, "", nil),
+	)
+	got, err := s.Search(context.Background(), "Tomato soup!", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Title != "Tomato Soup" || got[1].Title != "Chicken Soup" {
+		t.Errorf("Expected Tomato Soup then Chicken Soup, got %+v", got)
+	}
+	if got, err := s.Search(context.Background(), "!!", 2); err != nil || len(got) != 0 {
+		t.Errorf("Expected nothing for a query without words, got %+v, %v", got, err)
+	}
+
+	stew := model.NewRecipe("Beef Stew", []string{"500 g beef", "2 carrots"}, nil, nil, "", nil)
+	stew.Provenance = &model.Provenance{Origin: model.OriginGenerated, Query: "something warming"}
+	s.Add(stew)
+	if got, _ := s.Search(context.Background(), "something warming", 0); len(got) != 1 || got[0].ID != stew.ID {
+		t.Errorf("Expected a persisted generation found by its query, got %+v", got)
+	}
+	if got, _ := s.Search(context.Background(), "carrots", 0); len(got) != 1 || got[0].ID != stew.ID {
+		t.Errorf("Expected a recipe found by its ingredients, got %+v", got)
+	}
+	s.Archive(true, stew.ID)
+	servable := resolver.Filter{Statuses: []model.Status{model.StatusPublished}, Unarchived: true}
+	if got, _ := s.SearchFiltered(context.Background(), "carrots", 0, servable); len(got) != 0 {
+		t.Errorf("Expected an archived recipe filtered out, got %+v", got)
+	}
+	if got, _ := s.SearchFiltered(context.Background(), "soup", 1, servable); len(got) != 1 || got[0].Title != "Chicken Soup" {
+		t.Errorf("Expected published recipes kept, got %+v", got)
+	}
+
+	db.fail = errors.New("connection refused")
+	if _, err := s.Search(context.Background(), "soup", 2); err == nil {
+		t.Error("Expected search errors to be returned")
+	}
+	if s.All() != nil {
+		t.Error("Expected no recipes when the database fails")
+	}
+}
+
+// TestMigrate verifies that a table created before the search columns is upgraded, its recipes
+// becoming searchable through the full-text index.
+func TestMigrate(t *testing.T) {
+	soup := model.NewRecipe("Tomato Soup", []string{"tomatoes"}, nil, nil, "", nil)
+	data, _ := json.Marshal(soup)
+	db := &fakeDB{missing: map[string]bool{"keywords": true, "ingredients": true}, rows: []fakeRow{{id: soup.ID, slug: "tomato-soup", title: soup.Title, data: string(data), position: 1}}}
+	s := New(sql.OpenDB(db))
+	if err := s.Migrate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"CREATE TABLE IF NOT EXISTS recipes",
+		"DROP INDEX IF EXISTS recipes_position",
+		"CREATE UNIQUE INDEX IF NOT EXISTS recipes_position_key ON recipes",
+		"ALTER TABLE recipes ADD COLUMN keywords TEXT NOT NULL DEFAULT ''",
+		"ALTER TABLE recipes ADD COLUMN ingredients TEXT NOT NULL DEFAULT ''",
+		"UPDATE recipes SET keywords = $1, ingredients = $2, status = $3, archived = $4, merged_ids = $5, merged_slugs = $6",
+		"ALTER TABLE recipes ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS",
+		"CREATE INDEX IF NOT EXISTS recipes_search ON recipes USING GIN",
+	}
+	if fmt.Sprint(db.statements) != fmt.Sprint(want) {
+		t.Errorf("Expected statements %q, got %q", want, db.statements)
+	}
+	if got, _ := s.Search(context.Background(), "tomatoes", 0); len(got) != 1 {
+		t.Errorf("Expected the migrated recipe to be searchable, got %+v", got)
+	}
+}
+
+// TestStoreReindex verifies that Reindex recomputes the search words of every recipe, stale
+// ones included.
+func TestStoreReindex(t *testing.T) {
+	soup := model.NewRecipe("Tomato Soup", []string{"tomatoes"}, nil, nil, "", nil)
+	data, _ := json.Marshal(soup)
+	db := &fakeDB{rows: []fakeRow{{id: soup.ID, slug: "tomato-soup", title: soup.Title, data: string(data), position: 1, keywords: "tomato"}}}
+	s := New(sql.OpenDB(db))
+	if got, _ := s.Search(context.Background(), "soup", 0); len(got) != 0 {
+		t.Fatalf("Expected the stale search words to miss, got %+v", got)
+	}
+	if n, err := s.Reindex(context.Background()); err != nil || n != 1 {
+		t.Fatalf("Expected one recipe reindexed, got %d (err %v)", n, err)
+	}
+	if got, _ := s.Search(context.Background(), "soup", 0); len(got) != 1 {
+		t.Errorf("Expected the reindexed recipe to be searchable, got %+v", got)
+	}
+}
+
+// TestStoreAtomically verifies that a unit of work is committed as a whole, rolled back on
+// failure, and reported to OnChange once committed.
+func TestStoreAtomically(t *testing.T) {
+	s, db := newTestStore(t)
+	var changed []string
+	s.OnChange = func(ids []string) { changed = append(changed, ids...) }
+	soup := model.NewRecipe("Tomato Soup", nil, nil, nil, "", nil)
+	s.Add(soup)
+
+	stew := model.NewRecipe("Beef Stew", nil, nil, nil, "", nil)
+	err := resolver.Atomically(context.Background(), s, func(w resolver.Writer) error {
+		w.Add(stew)
+		if w.Archive(true, soup.ID) != 1 {
+			return errors.New("soup not archived")
+		}
+		if len(changed) != 0 {
+			t.Error("Expected no change reported before the commit")
+		}
+		return nil
+	})
+	if err != nil || len(s.All()) != 2 || fmt.Sprint(changed) != fmt.Sprint([]string{soup.ID}) {
+		t.Fatalf("Expected the unit of work committed, got %v with changes %v", err, changed)
+	}
+
+	failure := errors.New("audit log unavailable")
+	err = s.Atomically(context.Background(), func(w resolver.Writer) error {
+		w.Delete(soup.ID, stew.ID)
+		return failure
+	})
+	if !errors.Is(err, failure) || len(s.All()) != 2 || len(changed) != 1 {
+		t.Errorf("Expected the unit of work rolled back, got %v with %d recipes", err, len(s.All()))
+	}
+
+	diskFull := errors.New("disk full")
+	err = s.Atomically(context.Background(), func(w resolver.Writer) error {
+		w.Delete(stew.ID)
+		db.fail = diskFull
+		updated := w.Update(soup)
+		db.fail = nil
+		if !updated {
+			return errors.New("soup not found")
+		}
+		return nil
+	})
+	if !errors.Is(err, diskFull) || len(s.All()) != 2 {
+		t.Errorf("Expected a failed write to roll the unit of work back with its error, got %v with %d recipes", err, len(s.All()))
+	}
+}
+
+// TestStoreReplica verifies that reads go to the replica, except right after a write and while
+// the replica lags or fails.
+func TestStoreReplica(t *testing.T) {
+	s, primary := newTestStore(t)
+	replica := &fakeDB{}
+	s.Replica = sql.OpenDB(replica)
+	s.MaxLag, s.LagCheck = 20*time.Millisecond, time.Nanosecond
+	soup := model.NewRecipe("Tomato Soup", nil, nil, nil, "", nil)
+	s.Add(soup)
+	replica.rows = append([]fakeRow(nil), primary.rows...)
+	// A recipe only on the replica tells which database served a read.
+	stew := model.NewRecipe("Beef Stew", nil, nil, nil, "", nil)
+	data, _ := json.Marshal(stew)
+	replica.rows = append(replica.rows, fakeRow{id: stew.ID, slug: "beef-stew", title: stew.Title, data: string(data), keywords: "beef stew", position: 2})
+
+	if got := s.All(); len(got) != 1 {
+		t.Errorf("Expected reads from the primary right after a write, got %+v", got)
+	}
+	time.Sleep(s.MaxLag)
+	if got := s.All(); len(got) != 2 {
+		t.Errorf("Expected reads from the replica, got %+v", got)
+	}
+	if got, _ := s.Search(context.Background(), "stew", 5); len(got) != 1 {
+		t.Errorf("Expected searches on the replica, got %+v", got)
+	}
+
+	replica.lag = 1
+	if got := s.All(); len(got) != 1 {
+		t.Errorf("Expected reads from the primary while the replica lags, got %+v", got)
+	}
+	replica.lag, replica.fail = 0, errors.New("connection refused")
+	if got := s.All(); len(got) != 1 {
+		t.Errorf("Expected reads from the primary while the replica fails, got %+v", got)
+	}
+	replica.fail = nil
+	if s.Update(stew) || s.Archive(true, stew.ID) != 0 {
+		t.Error("Expected writes to check the primary, which lacks the stew")
+	}
+}
+
+// TestOpenSQLite verifies that SQLite databases are opened in WAL mode through a single
+// connection, with the schema and full-text index created.
+func TestOpenSQLite(t *testing.T) {
+	s, err := OpenSQLite(context.Background(), "fakesql", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.DB.Close()
+	if n := s.DB.Stats().MaxOpenConnections; n != 1 {
+		t.Errorf("Expected a single connection, got %d", n)
+	}
+	db, _ := fakeDBs.Load(t.Name())
+	want := []string{
+		"PRAGMA journal_mode = WAL",
+		"PRAGMA busy_timeout = 5000",
+		"CREATE TABLE IF NOT EXISTS recipes",
+		"DROP INDEX IF EXISTS recipes_position",
+		"CREATE UNIQUE INDEX IF NOT EXISTS recipes_position_key ON recipes",
+		"CREATE VIRTUAL TABLE IF NOT EXISTS recipes_fts USING fts5(keywords, ingredients)",
+		"CREATE TRIGGER IF NOT EXISTS recipes_fts_insert AFTER INSERT ON recipes BEGIN",
+		"CREATE TRIGGER IF NOT EXISTS recipes_fts_update AFTER UPDATE ON recipes BEGIN",
+		"CREATE TRIGGER IF NOT EXISTS recipes_fts_delete AFTER DELETE ON recipes BEGIN",
+		"INSERT INTO recipes_fts",
+	}
+	if got := db.(*fakeDB).statements; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Expected statements %q, got %q", want, got)
+	}
+
+	s.Add(model.NewRecipe("Tomato Soup", []string{"tomatoes"}, nil, nil, "", nil))
+	if got, err := s.Search(context.Background(), "tomatoes", 0); err != nil || len(got) != 1 {
+		t.Errorf("Expected a full-text search match, got %+v, %v", got, err)
+	}
+}
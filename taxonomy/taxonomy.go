@@ -0,0 +1,382 @@
+// Package taxonomy arranges ingredients in a hierarchy, e.g. "pecorino" → "hard cheese" →
+// "cheese" → "dairy", so that related ingredients can match partially, allergens can be
+// detected from ingredient lines and substitutes suggested.
+package taxonomy
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pageza/recipe-resolver-ms/nlp"
+)
+
+//go:embed taxonomy.json
+var bundled []byte
+
+// ParentDecay is the similarity lost per step up the hierarchy: siblings such as "pecorino" and
+// "parmesan" are ParentDecay similar, cousins ParentDecay².
+const ParentDecay = 0.5
+
+// maxTermWords bounds the length of the names and aliases looked up in text.
+const maxTermWords = 4
+
+// Entry describes an ingredient or ingredient group. Names and aliases are lowercase and
+// singular.
+type Entry struct {
+	// Parent is the name of the enclosing group; empty for top-level groups.
+	Parent string `json:"parent,omitempty"`
+	// Aliases are other names for the ingredient, e.g. "parmigiano" for "parmesan".
+	Aliases []string `json:"aliases,omitempty"`
+	// Allergens are the major allergens the ingredient contains, in addition to those of its
+	// ancestors.
+	Allergens []string `json:"allergens,omitempty"`
+	// Substitutes are ingredients that can stand in for this one, preferred over its siblings.
+	Substitutes []string `json:"substitutes,omitempty"`
+}
+
+// Taxonomy is an ingredient hierarchy. It is immutable and safe for concurrent use.
+type Taxonomy struct {
+	entries map[string]Entry
+	names   map[string]string // name or alias -> name
+}
+
+// Default returns the bundled taxonomy.
+func Default() *Taxonomy {
+	entries, err := parse(bundled)
+	if err == nil {
+		var t *Taxonomy
+		if t, err = New(entries); err == nil {
+			return t
+		}
+	}
+	panic("taxonomy: bundled dataset is invalid: " + err.Error())
+}
+
+// Load reads a JSON file of entries keyed by name, in the format of the bundled dataset, and
+// returns the bundled taxonomy with those entries added or replaced.
+func Load(path string) (*Taxonomy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing taxonomy %s: %w", path, err)
+	}
+	entries, _ := parse(bundled)
+	for name, e := range overrides {
+		entries[name] = e
+	}
+	t, err := New(entries)
+	if err != nil {
+		return nil, fmt.Errorf("parsing taxonomy %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// parse decodes entries, normalizing their names and aliases.
+func parse(data []byte) (map[string]Entry, error) {
+	var raw map[string]Entry
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	entries := make(map[string]Entry, len(raw))
+	for name, e := range raw {
+		e.Parent = normalize(e.Parent)
+		for i, a := range e.Aliases {
+			e.Aliases[i] = normalize(a)
+		}
+		entries[normalize(name)] = e
+	}
+	return entries, nil
+}
+
+// New returns a taxonomy of the given entries. Every parent must be an entry, the hierarchy
+// must be acyclic and no name or alias may be used twice.
+func New(entries map[string]Entry) (*Taxonomy, error) {
+	t := &Taxonomy{entries: entries, names: make(map[string]string)}
+	for name, e := range entries {
+		if name == "" {
+			return nil, fmt.Errorf("entry with an empty name")
+		}
+		if e.Parent != "" {
+			if _, ok := entries[e.Parent]; !ok {
+				return nil, fmt.Errorf("%s: unknown parent %q", name, e.Parent)
+			}
+		}
+		for _, alias := range append([]string{name}, e.Aliases...) {
+			if other, ok := t.names[alias]; ok && other != name {
+				return nil, fmt.Errorf("%q names both %s and %s", alias, other, name)
+			}
+			t.names[alias] = name
+		}
+	}
+	for name := range entries {
+		seen := map[string]bool{}
+		for n := name; n != ""; n = entries[n].Parent {
+			if seen[n] {
+				return nil, fmt.Errorf("%s: cycle through %s", name, n)
+			}
+			seen[n] = true
+		}
+	}
+	return t, nil
+}
+
+// normalize lowercases s and singularizes its words: "Pine Nuts" -> "pine nut".
+func normalize(s string) string {
+	words := nlp.Tokenize(s)
+	for i, w := range words {
+		words[i] = singular(w)
+	}
+	return strings.Join(words, " ")
+}
+
+// singular strips common English plural endings: "tomatoes" -> "tomato", "cherries" -> "cherry".
+func singular(w string) string {
+	switch {
+	case strings.HasSuffix(w, "ies") && len(w) > 4:
+		return w[:len(w)-3] + "y"
+	case strings.HasSuffix(w, "oes"):
+		return w[:len(w)-2]
+	case strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") && len(w) > 3:
+		return w[:len(w)-1]
+	}
+	return w
+}
+
+// terms splits text into taxonomy names and the remaining words, longest names first:
+// "grated pecorino romano" -> "grated", "pecorino". Known reports whether each is a name.
+func (t *Taxonomy) terms(text string) (terms []string, known []bool) {
+	words := strings.Fields(normalize(text))
+	for i := 0; i < len(words); {
+		n := min(maxTermWords, len(words)-i)
+		for ; n > 0; n-- {
+			if name, ok := t.names[strings.Join(words[i:i+n], " ")]; ok {
+				terms, known = append(terms, name), append(known, true)
+				break
+			}
+		}
+		if n == 0 {
+			terms, known = append(terms, words[i]), append(known, false)
+			n = 1
+		}
+		i += n
+	}
+	return terms, known
+}
+
+// Lookup returns the name of the ingredient an ingredient line refers to, e.g. "pecorino" for
+// "50 g grated Pecorino Romano". When a line names several, the last one wins.
+func (t *Taxonomy) Lookup(line string) (string, bool) {
+	terms, known := t.terms(line)
+	for i := len(terms) - 1; i >= 0; i-- {
+		if known[i] {
+			return terms[i], true
+		}
+	}
+	return "", false
+}
+
+// Ancestors returns the groups enclosing name, nearest first: "pecorino" -> "hard cheese",
+// "cheese", "dairy". It is empty for unknown names.
+func (t *Taxonomy) Ancestors(name string) []string {
+	var out []string
+	for n := t.entries[name].Parent; n != ""; n = t.entries[n].Parent {
+		out = append(out, n)
+	}
+	return out
+}
+
+// Descendants returns the ingredients and groups under name, which may be an alias, in
+// alphabetical order: "hard cheese" -> "cheddar", "gruyere", "parmesan", "pecorino". It is
+// empty for a nil taxonomy.
+func (t *Taxonomy) Descendants(name string) []string {
+	if t == nil {
+		return nil
+	}
+	if canonical, ok := t.names[normalize(name)]; ok {
+		name = canonical
+	}
+	var out []string
+	for n := range t.entries {
+		if n == name {
+			continue
+		}
+		for _, a := range t.Ancestors(n) {
+			if a == name {
+				out = append(out, n)
+				break
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Mentions reports whether an ingredient line names name or, for a group, one of its
+// descendants: "2 tbsp butter" mentions "dairy", but "1 tbsp peanut butter" does not. Names
+// missing from the taxonomy, or from a nil one, are matched as words of the line.
+func (t *Taxonomy) Mentions(line, name string) bool {
+	name = normalize(name)
+	if name == "" {
+		return false
+	}
+	if t != nil {
+		if canonical, ok := t.names[name]; ok {
+			terms, known := t.terms(line)
+			for i, term := range terms {
+				if known[i] && (term == canonical || t.depths(term)[canonical] > 0) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	return strings.Contains(" "+normalize(line)+" ", " "+name+" ")
+}
+
+// depths returns the distance from name to itself and each of its ancestors.
+func (t *Taxonomy) depths(name string) map[string]int {
+	d := map[string]int{name: 0}
+	for i, a := range t.Ancestors(name) {
+		d[a] = i + 1
+	}
+	return d
+}
+
+// Similarity rates how related two ingredient names are: 1 for the same ingredient,
+// ParentDecay raised to the number of steps from the farther one to their nearest common
+// group, and 0 for unrelated or unknown ingredients. An ingredient and its group count as one
+// step apart.
+func (t *Taxonomy) Similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	if _, ok := t.entries[a]; !ok {
+		return 0
+	}
+	da, best := t.depths(a), -1
+	for n, db := range t.depths(b) {
+		if d, ok := da[n]; ok && (best < 0 || max(d, db) < best) {
+			best = max(d, db)
+		}
+	}
+	if best < 0 {
+		return 0
+	}
+	return math.Pow(ParentDecay, float64(best))
+}
+
+// Score rates how similar a query is to a recipe title, from 0 to 1, giving partial credit to
+// related ingredients: "pecorino pasta" is closer to "Parmesan Pasta" than to "Beef Stew". Each
+// word or ingredient of either text scores its best match in the other, and the two sides are
+// averaged. Taxonomy implements resolver.Scorer.
+func (t *Taxonomy) Score(query, title string) float64 {
+	q, qKnown := t.terms(query)
+	r, rKnown := t.terms(title)
+	if len(q) == 0 || len(r) == 0 {
+		return 0
+	}
+	return (t.coverage(q, qKnown, r, rKnown) + t.coverage(r, rKnown, q, qKnown)) / 2
+}
+
+// coverage returns the mean best similarity of each of a's terms to b's.
+func (t *Taxonomy) coverage(a []string, aKnown []bool, b []string, bKnown []bool) float64 {
+	total := 0.0
+	for i, x := range a {
+		best := 0.0
+		for j, y := range b {
+			sim := 0.0
+			switch {
+			case x == y:
+				sim = 1
+			case aKnown[i] && bKnown[j]:
+				sim = t.Similarity(x, y)
+			}
+			best = max(best, sim)
+		}
+		total += best
+	}
+	return total / float64(len(a))
+}
+
+// allergens returns the allergens of name and its ancestors.
+func (t *Taxonomy) allergens(name string) []string {
+	out := append([]string{}, t.entries[name].Allergens...)
+	for _, a := range t.Ancestors(name) {
+		out = append(out, t.entries[a].Allergens...)
+	}
+	return out
+}
+
+// Allergens returns the major allergens found in ingredient lines, sorted and without
+// duplicates, e.g. ["gluten", "milk"] for spaghetti with parmesan.
+func (t *Taxonomy) Allergens(ingredients []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, line := range ingredients {
+		terms, known := t.terms(line)
+		for i, name := range terms {
+			if !known[i] {
+				continue
+			}
+			for _, a := range t.allergens(name) {
+				if !seen[a] {
+					seen[a] = true
+					out = append(out, a)
+				}
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Substitutes suggests ingredients to use instead of name: its listed substitutes first, then
+// the other ingredients of its group in alphabetical order. Siblings are only suggested within
+// specific groups such as "hard cheese", not top-level ones such as "dairy". Suggestions
+// containing any of the avoid allergens are left out.
+func (t *Taxonomy) Substitutes(name string, avoid ...string) []string {
+	e, ok := t.entries[name]
+	if !ok {
+		return nil
+	}
+	excluded := func(s string) bool {
+		for _, a := range t.Allergens([]string{s}) {
+			for _, x := range avoid {
+				if a == x {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	seen := map[string]bool{name: true}
+	var out []string
+	for _, s := range e.Substitutes {
+		if !seen[s] && !excluded(s) {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	var siblings []string
+	if t.entries[e.Parent].Parent != "" {
+		groups := make(map[string]bool)
+		for _, other := range t.entries {
+			groups[other.Parent] = true
+		}
+		for n, other := range t.entries {
+			if other.Parent == e.Parent && !groups[n] && !seen[n] && !excluded(n) {
+				siblings = append(siblings, n)
+			}
+		}
+	}
+	sort.Strings(siblings)
+	return append(out, siblings...)
+}
@@ -0,0 +1,149 @@
+package taxonomy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	tax := Default()
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"50 g grated Pecorino Romano", "pecorino"},
+		{"2 tbsp extra-virgin olive oil", "olive oil"},
+		{"3 ripe Tomatoes, diced", "tomato"},
+		{"1 flax egg", "flax egg"},
+		{"a pinch of salt", ""},
+	}
+	for _, tt := range tests {
+		if got, _ := tax.Lookup(tt.line); got != tt.want {
+			t.Errorf("Lookup(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+	if got, want := tax.Ancestors("pecorino"), []string{"hard cheese", "cheese", "dairy"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Ancestors(pecorino) = %v, want %v", got, want)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tax := Default()
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"pecorino", "pecorino", 1},
+		{"pecorino", "parmesan", 0.5},
+		{"pecorino", "hard cheese", 0.5},
+		{"pecorino", "mozzarella", 0.25},
+		{"pecorino", "beef", 0},
+		{"pecorino", "unknown", 0},
+	}
+	for _, tt := range tests {
+		if got := tax.Similarity(tt.a, tt.b); got != tt.want {
+			t.Errorf("Similarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// TestScore verifies that related ingredients match partially.
+func TestScore(t *testing.T) {
+	tax := Default()
+	if got := tax.Score("Spaghetti Carbonara", "spaghetti carbonara"); got != 1 {
+		t.Errorf("Expected identical titles to score 1, got %v", got)
+	}
+	related, unrelated := tax.Score("pecorino pasta", "Parmesan Pasta"), tax.Score("pecorino pasta", "Beef Stew")
+	if related <= unrelated || related >= 1 {
+		t.Errorf("Expected a partial match for a sibling cheese, got %v (unrelated %v)", related, unrelated)
+	}
+}
+
+func TestAllergens(t *testing.T) {
+	got := Default().Allergens([]string{"200 g spaghetti", "2 eggs", "50 g parmesan", "1 tbsp soy sauce", "salt"})
+	if want := []string{"egg", "gluten", "milk", "soy"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Allergens = %v, want %v", got, want)
+	}
+}
+
+func TestSubstitutes(t *testing.T) {
+	tax := Default()
+	tests := []struct {
+		name  string
+		avoid []string
+		want  []string
+	}{
+		{"pecorino", nil, []string{"cheddar", "gruyere", "parmesan"}},
+		{"pancetta", nil, []string{"guanciale", "bacon", "ham"}},
+		{"milk", []string{"tree nuts"}, []string{"oat milk", "soy milk"}},
+		{"butter", []string{"milk"}, []string{"olive oil", "coconut oil"}},
+		{"unknown", nil, nil},
+	}
+	for _, tt := range tests {
+		if got := tax.Substitutes(tt.name, tt.avoid...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Substitutes(%q, %v) = %v, want %v", tt.name, tt.avoid, got, tt.want)
+		}
+	}
+}
+
+// TestLoad verifies that a file adds to and overrides the bundled taxonomy, and is validated.
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tax, err := Load(write("ok.json", `{"Manchego": {"parent": "hard cheese"}, "tofu": {"parent": "legume"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tax.Similarity("manchego", "pecorino"); got != 0.5 {
+		t.Errorf("Expected the added cheese to be a sibling of pecorino, got %v", got)
+	}
+	if got := tax.Ancestors("tofu"); !reflect.DeepEqual(got, []string{"legume"}) {
+		t.Errorf("Expected the override to move tofu, got %v", got)
+	}
+
+	for name, data := range map[string]string{
+		"parent.json": `{"manchego": {"parent": "cheeses of spain"}}`,
+		"cycle.json":  `{"dairy": {"parent": "cheese"}}`,
+		"alias.json":  `{"manchego": {"aliases": ["parmesan"]}}`,
+	} {
+		if _, err := Load(write(name, data)); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("Expected %s to be rejected, got %v", name, err)
+		}
+	}
+}
+
+func TestMentions(t *testing.T) {
+	tax := Default()
+	tests := []struct {
+		line, name string
+		want       bool
+	}{
+		{"2 tbsp butter", "dairy", true},
+		{"50 g grated Pecorino Romano", "cheese", true},
+		{"50 g grated Pecorino Romano", "pecorino", true},
+		{"1 tbsp peanut butter", "butter", false},
+		{"1 tbsp peanut butter", "dairy", false},
+		{"1 aubergine", "eggplant", true},
+		{"1 eggplant", "egg", false},
+		{"a pinch of sea salt", "salt", true},
+		{"a pinch of sea salt", "pepper", false},
+	}
+	for _, tt := range tests {
+		if got := tax.Mentions(tt.line, tt.name); got != tt.want {
+			t.Errorf("Mentions(%q, %q) = %t, want %t", tt.line, tt.name, got, tt.want)
+		}
+	}
+	if got, want := tax.Descendants("hard cheese"), []string{"cheddar", "gruyere", "parmesan", "pecorino"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Descendants(hard cheese) = %v, want %v", got, want)
+	}
+}
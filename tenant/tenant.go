@@ -0,0 +1,132 @@
+// Package tenant customizes matching and generation for the tenants of a shared deployment,
+// such as a white-label app focused on one diet, without forking the service: each tenant may
+// bring its own prompt template and scorer weights, applied to the requests of its API keys.
+package tenant
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/template"
+
+	"github.com/pageza/recipe-resolver-ms/generation"
+)
+
+// Limits on what a tenant may supply, so that one tenant's configuration cannot make prompts
+// expensive for the provider or matching slow.
+const (
+	// MaxPromptTemplate is the longest prompt template, in bytes.
+	MaxPromptTemplate = 8 << 10
+	// MaxWeights is the most scorer weights a tenant may set.
+	MaxWeights = 16
+)
+
+// Profile is a tenant's customization. Its zero-valued fields leave the deployment's setting in
+// force.
+type Profile struct {
+	Name string `json:"name"`
+	// Principals names the authenticated principals, e.g. API keys, whose requests are the
+	// tenant's.
+	Principals []string `json:"principals"`
+	// Threshold overrides the similarity threshold for a close match.
+	Threshold float64 `json:"threshold,omitempty"`
+	// Weights overrides the weights of the named scorers, e.g. {"jaccard": 0.5}.
+	Weights map[string]float64 `json:"weights,omitempty"`
+	// PromptTemplate replaces the generation prompt template (see generation.PromptTemplate).
+	// It must include the query.
+	PromptTemplate string `json:"prompt_template,omitempty"`
+
+	prompt *template.Template
+}
+
+// Prompt returns the parsed PromptTemplate, or nil if the tenant keeps the default prompt. It
+// is nil-safe.
+func (p *Profile) Prompt() *template.Template {
+	if p == nil {
+		return nil
+	}
+	return p.prompt
+}
+
+// Config is the set of tenants.
+type Config struct {
+	Tenants []Profile `json:"tenants"`
+
+	byPrincipal map[string]*Profile
+}
+
+// For returns the profile of the tenant principal belongs to, or nil if it belongs to none. It
+// is nil-safe.
+func (c *Config) For(principal string) *Profile {
+	if c == nil {
+		return nil
+	}
+	return c.byPrincipal[principal]
+}
+
+// Validate checks that tenants are named uniquely, that each principal belongs to at most one,
+// and that their settings are in range and within the limits, and parses and checks their
+// prompt templates (see generation.CheckTemplate). Scorer names are checked by the resolver.
+func (c *Config) Validate() error {
+	names := make(map[string]bool)
+	c.byPrincipal = make(map[string]*Profile)
+	for i := range c.Tenants {
+		p := &c.Tenants[i]
+		if p.Name == "" || names[p.Name] {
+			return fmt.Errorf("tenant %d needs a unique name", i)
+		}
+		names[p.Name] = true
+		for _, principal := range p.Principals {
+			if other, ok := c.byPrincipal[principal]; ok {
+				return fmt.Errorf("tenant %q: principal %q already belongs to tenant %q", p.Name, principal, other.Name)
+			}
+			c.byPrincipal[principal] = p
+		}
+		if p.Threshold < 0 || p.Threshold > 1 {
+			return fmt.Errorf("tenant %q: threshold must be between 0 and 1", p.Name)
+		}
+		if len(p.Weights) > MaxWeights {
+			return fmt.Errorf("tenant %q: at most %d weights may be set", p.Name, MaxWeights)
+		}
+		positive := len(p.Weights) == 0
+		for name, w := range p.Weights {
+			if w < 0 {
+				return fmt.Errorf("tenant %q: weight of scorer %q is negative", p.Name, name)
+			}
+			positive = positive || w > 0
+		}
+		if !positive {
+			return fmt.Errorf("tenant %q: weights need at least one positive weight", p.Name)
+		}
+		if len(p.PromptTemplate) > MaxPromptTemplate {
+			return fmt.Errorf("tenant %q: prompt template exceeds %d bytes", p.Name, MaxPromptTemplate)
+		}
+		if p.PromptTemplate != "" {
+			t, err := template.New(p.Name).Parse(p.PromptTemplate)
+			if err == nil {
+				err = generation.CheckTemplate(t)
+			}
+			if err != nil {
+				return fmt.Errorf("tenant %q: %w", p.Name, err)
+			}
+			p.prompt = t
+		}
+	}
+	return nil
+}
+
+// Load reads and validates a JSON Config file.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parsing tenants file %s: %w", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("parsing tenants file %s: %w", path, err)
+	}
+	return &c, nil
+}
@@ -0,0 +1,45 @@
+package tenant
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tenants.json")
+	os.WriteFile(path, []byte(`{"tenants": [{
+		"name": "keto", "principals": ["keto-app"], "weights": {"jaccard": 2},
+		"prompt_template": "Generate a low-carb, keto-friendly recipe for \"{{.Query}}\" as JSON."
+	}]}`), 0o644)
+	c, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := c.For("keto-app")
+	if p == nil || p.Name != "keto" || p.Prompt() == nil {
+		t.Fatalf("Expected the keto profile with its prompt for keto-app, got %+v", p)
+	}
+	if c.For("other-app") != nil || (*Config)(nil).For("keto-app") != nil || (*Profile)(nil).Prompt() != nil {
+		t.Error("Expected no profile for other principals or without a config")
+	}
+
+	for name, bad := range map[string]string{
+		"duplicate tenant":    `{"tenants": [{"name": "a"}, {"name": "a"}]}`,
+		"shared principal":    `{"tenants": [{"name": "a", "principals": ["k"]}, {"name": "b", "principals": ["k"]}]}`,
+		"bad threshold":       `{"tenants": [{"name": "a", "threshold": 1.5}]}`,
+		"negative weight":     `{"tenants": [{"name": "a", "weights": {"jaccard": -1}}]}`,
+		"zero weights":        `{"tenants": [{"name": "a", "weights": {"jaccard": 0}}]}`,
+		"bad template":        `{"tenants": [{"name": "a", "prompt_template": "{{.Query"}]}`,
+		"template error":      `{"tenants": [{"name": "a", "prompt_template": "{{.Query}} {{.Budget}}"}]}`,
+		"template sans query": `{"tenants": [{"name": "a", "prompt_template": "Generate a keto recipe."}]}`,
+		"oversized template":  `{"tenants": [{"name": "a", "prompt_template": "{{.Query}}` + strings.Repeat(" keto", MaxPromptTemplate/5) + `"}]}`,
+	} {
+		os.WriteFile(path, []byte(bad), 0o644)
+		if _, err := Load(path); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
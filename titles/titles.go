@@ -0,0 +1,244 @@
+// Package titles cleans up the titles of generated recipes: it drops denied words such as
+// profanity, replaces trademarked product names with generic ones, and enforces a maximum
+// length and a casing style.
+package titles
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Casing is the letter case a Filter gives titles.
+type Casing string
+
+const (
+	// CasingKeep leaves the case of titles alone.
+	CasingKeep Casing = ""
+	// CasingTitle capitalizes every word but short function words: "Pasta with Peas".
+	CasingTitle Casing = "title"
+	// CasingSentence capitalizes the first word only: "Pasta with peas".
+	CasingSentence Casing = "sentence"
+)
+
+// Config is the JSON form of a Filter.
+type Config struct {
+	// Deny lists words and phrases removed from titles, e.g. profanity.
+	Deny []string `json:"deny"`
+	// Replace maps words and phrases, e.g. brand names, to what replaces them; an empty
+	// replacement removes them.
+	Replace map[string]string `json:"replace"`
+	// MaxLength is the maximum length of a title in characters; longer titles are cut at a
+	// word boundary. 0 means no limit.
+	MaxLength int `json:"max_length"`
+	// Casing is "title", "sentence" or "keep".
+	Casing string `json:"casing"`
+}
+
+// Filter rewrites titles. Denied and replaced phrases match whole words, ignoring case.
+type Filter struct {
+	deny      *regexp.Regexp
+	replace   *regexp.Regexp
+	with      map[string]string
+	maxLength int
+	casing    Casing
+}
+
+//go:embed filter.json
+var defaultConfig []byte
+
+// Default returns the bundled filter, which removes common profanity, replaces well-known
+// brand names and title-cases titles of up to 80 characters.
+func Default() *Filter {
+	f, err := parse(defaultConfig)
+	if err != nil {
+		panic("titles: bundled filter is invalid: " + err.Error())
+	}
+	return f
+}
+
+// Load reads a filter from a JSON file in the form of Config.
+func Load(path string) (*Filter, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing title filter %s: %w", path, err)
+	}
+	return f, nil
+}
+
+func parse(data []byte) (*Filter, error) {
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return New(c)
+}
+
+// New returns the filter described by c.
+func New(c Config) (*Filter, error) {
+	f := &Filter{maxLength: c.MaxLength, with: make(map[string]string)}
+	switch casing := Casing(strings.ToLower(strings.TrimSpace(c.Casing))); casing {
+	case "keep":
+		f.casing = CasingKeep
+	case CasingKeep, CasingTitle, CasingSentence:
+		f.casing = casing
+	default:
+		return nil, fmt.Errorf("unknown casing %q (want title, sentence or keep)", c.Casing)
+	}
+	if c.MaxLength < 0 {
+		return nil, fmt.Errorf("invalid max_length %d", c.MaxLength)
+	}
+	f.deny = phrases(c.Deny)
+	keys := make([]string, 0, len(c.Replace))
+	for k, v := range c.Replace {
+		k = strings.ToLower(strings.TrimSpace(k))
+		keys = append(keys, k)
+		f.with[k] = strings.TrimSpace(v)
+	}
+	f.replace = phrases(keys)
+	return f, nil
+}
+
+// phrases returns a pattern matching any of list as whole words, ignoring case, or nil if list
+// is empty. Longer phrases are tried first, so "oreos" wins over "oreo".
+func phrases(list []string) *regexp.Regexp {
+	var alts []string
+	for _, p := range list {
+		if p = strings.TrimSpace(p); p != "" {
+			alts = append(alts, regexp.QuoteMeta(strings.ToLower(p)))
+		}
+	}
+	if len(alts) == 0 {
+		return nil
+	}
+	sort.Slice(alts, func(i, j int) bool {
+		if len(alts[i]) != len(alts[j]) {
+			return len(alts[i]) > len(alts[j])
+		}
+		return alts[i] < alts[j]
+	})
+	return regexp.MustCompile(`(?i)(^|[^\pL\pN])(` + strings.Join(alts, "|") + `)($|[^\pL\pN])`)
+}
+
+var (
+	// label matches a leading "Recipe:" or "Title:" some providers put before the title.
+	label = regexp.MustCompile(`(?i)^\s*(recipe|title)\s*:\s*`)
+	// marks matches trademark signs.
+	marks = regexp.MustCompile(`[™®©]`)
+	// empties matches brackets left empty by removals.
+	empties = regexp.MustCompile(`\(\s*\)|\[\s*\]`)
+	spaces  = regexp.MustCompile(`\s+`)
+)
+
+// untidy reports whether r may not begin or end a title.
+func untidy(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune(`"'*_-–—,:;&/|`+"`", r)
+}
+
+// smallWords are not capitalized in title case unless they begin the title.
+var smallWords = map[string]bool{
+	"a": true, "an": true, "and": true, "as": true, "at": true, "but": true, "by": true, "de": true,
+	"for": true, "from": true, "in": true, "into": true, "of": true, "on": true, "or": true,
+	"the": true, "to": true, "with": true,
+}
+
+// Apply returns title rewritten by f. It strips a leading label, enclosing quotes and trademark
+// signs, removes denied phrases, replaces listed ones, collapses whitespace, applies the casing
+// and shortens the title to the maximum length. The result is empty if nothing is left.
+func (f *Filter) Apply(title string) string {
+	title = label.ReplaceAllString(title, "")
+	title = marks.ReplaceAllString(title, "")
+	if f.deny != nil {
+		title = replaceAll(f.deny, title, func(string) string { return "" })
+	}
+	if f.replace != nil {
+		title = replaceAll(f.replace, title, func(m string) string { return f.with[strings.ToLower(m)] })
+	}
+	title = empties.ReplaceAllString(title, "")
+	title = strings.TrimFunc(spaces.ReplaceAllString(title, " "), untidy)
+	if !strings.ContainsFunc(title, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) {
+		return ""
+	}
+	title = f.applyCasing(title)
+	if f.maxLength > 0 && utf8.RuneCountInString(title) > f.maxLength {
+		title = shorten(title, f.maxLength)
+	}
+	return title
+}
+
+// replaceAll replaces every match of the phrase in re's second group by repl, keeping the
+// separators around it. Matching resumes after each replacement, so adjacent phrases sharing a
+// separator are all replaced and a replacement is never matched again.
+func replaceAll(re *regexp.Regexp, s string, repl func(string) string) string {
+	var b strings.Builder
+	for {
+		loc := re.FindStringSubmatchIndex(s)
+		if loc == nil {
+			b.WriteString(s)
+			return b.String()
+		}
+		b.WriteString(s[:loc[4]])
+		b.WriteString(repl(s[loc[4]:loc[5]]))
+		s = s[loc[5]:]
+	}
+}
+
+func (f *Filter) applyCasing(title string) string {
+	if f.casing == CasingKeep {
+		return title
+	}
+	words := strings.Split(title, " ")
+	for i, w := range words {
+		if len(w) > 4 && strings.ToUpper(w) == w && strings.ToLower(w) != w {
+			// Shouting; short all-caps words are kept as acronyms ("BBQ").
+			w = strings.ToLower(w)
+		}
+		switch {
+		case i == 0:
+			w = capitalize(w)
+		case f.casing == CasingTitle && smallWords[strings.ToLower(w)]:
+			w = strings.ToLower(w)
+		case f.casing == CasingTitle:
+			w = capitalize(w)
+		}
+		words[i] = w
+	}
+	return strings.Join(words, " ")
+}
+
+// capitalize upper-cases the first letter of w, after any opening punctuation such as "(".
+func capitalize(w string) string {
+	i := strings.IndexFunc(w, unicode.IsLetter)
+	if i < 0 {
+		return w
+	}
+	r, size := utf8.DecodeRuneInString(w[i:])
+	return w[:i] + string(unicode.ToUpper(r)) + w[i+size:]
+}
+
+// shorten cuts title to at most n characters at a word boundary, dropping trailing small
+// words and punctuation so that it does not end mid-phrase ("Pasta with").
+func shorten(title string, n int) string {
+	runes := []rune(title)
+	cut := string(runes[:n])
+	if runes[n] != ' ' {
+		if i := strings.LastIndex(cut, " "); i > 0 {
+			cut = cut[:i]
+		}
+	}
+	words := strings.Fields(cut)
+	for len(words) > 1 && smallWords[strings.ToLower(strings.TrimFunc(words[len(words)-1], untidy))] {
+		words = words[:len(words)-1]
+	}
+	return strings.TrimFunc(strings.Join(words, " "), untidy)
+}
@@ -0,0 +1,61 @@
+package titles
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	f := Default()
+	for _, tc := range []struct {
+		title, want string
+	}{
+		{"Nutella Brownies", "Hazelnut Spread Brownies"},
+		{"Recipe: \"Oreo® Cheesecake Bars\"", "Chocolate Sandwich Cookie Cheesecake Bars"},
+		{"damn good chili with kraft cheese", "Good Chili with Cheese"},
+		{"CROCKPOT pulled pork (crock-pot friendly)", "Slow Cooker Pulled Pork (Slow Cooker Friendly)"},
+		{"SUPER CRISPY BBQ Wings", "Super Crispy BBQ Wings"},
+		{"Peanut Butter and Jelly", "Peanut Butter and Jelly"},
+		{"The Classassic Sandwich", "The Classassic Sandwich"},
+		{"Damn", ""},
+	} {
+		if got := f.Apply(tc.title); got != tc.want {
+			t.Errorf("Apply(%q) = %q, want %q", tc.title, got, tc.want)
+		}
+	}
+}
+
+func TestApplyLength(t *testing.T) {
+	f, err := New(Config{MaxLength: 30, Casing: "sentence"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.Apply("roasted butternut squash soup with crispy sage and brown butter"); got != "Roasted butternut squash soup" {
+		t.Errorf("Expected the title cut at a word boundary without a trailing 'with', got %q", got)
+	}
+	if got := f.Apply("lemon tart"); got != "Lemon tart" {
+		t.Errorf("Expected a short title only capitalized, got %q", got)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filter.json")
+	if err := os.WriteFile(path, []byte(`{"replace": {"spam": "ham"}, "casing": "keep"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.Apply("fried spam rice"); got != "fried ham rice" {
+		t.Errorf("Expected the configured replacement without recasing, got %q", got)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"casing": "shouty"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Expected an error for an unknown casing")
+	}
+}
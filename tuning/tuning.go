@@ -0,0 +1,106 @@
+// Package tuning reads the settings an operator may change while the service runs: the match
+// threshold, scorer weights, generation prompt and its canary, and concurrency limits. The service reloads them
+// on SIGHUP or POST /admin/reload, keeping its current settings if the file is invalid.
+package tuning
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/template"
+)
+
+// Config is a tuning file. Its zero-valued fields leave the threshold, weights and prompt
+// configured at startup in force, and the concurrency limits as they are.
+type Config struct {
+	// Threshold is the similarity threshold for a close match, between 0 and 1.
+	Threshold float64 `json:"threshold,omitempty"`
+	// Weights sets the weights of the named scorers, e.g. {"jaccard": 0.5}.
+	Weights map[string]float64 `json:"weights,omitempty"`
+	// PromptTemplate replaces the generation prompt template (see generation.PromptTemplate).
+	PromptTemplate string `json:"prompt_template,omitempty"`
+	// CanaryTemplate is a new prompt template served to CanaryPercent percent of generations,
+	// so that its outcome metrics can be compared with the current template's before it
+	// replaces PromptTemplate.
+	CanaryTemplate string  `json:"canary_template,omitempty"`
+	CanaryPercent  float64 `json:"canary_percent,omitempty"`
+	// MaxConcurrent and BatchMaxConcurrent resize the /resolve limiter (see qos.Limiter).
+	// BatchMaxConcurrent defaults to half of MaxConcurrent.
+	MaxConcurrent      int `json:"max_concurrent,omitempty"`
+	BatchMaxConcurrent int `json:"batch_max_concurrent,omitempty"`
+	// Concurrency resizes the concurrency caps by endpoint, or "generate" for generations (see
+	// qos.Gate).
+	Concurrency map[string]int `json:"concurrency,omitempty"`
+
+	prompt, canary *template.Template
+}
+
+// Prompt returns the parsed PromptTemplate, or nil if the file keeps the current prompt.
+func (c *Config) Prompt() *template.Template {
+	return c.prompt
+}
+
+// Canary returns the parsed CanaryTemplate, or nil if the file has none.
+func (c *Config) Canary() *template.Template {
+	return c.canary
+}
+
+// Validate checks that the values are in range and parses the prompt templates.
+func (c *Config) Validate() error {
+	if c.Threshold < 0 || c.Threshold > 1 {
+		return fmt.Errorf("threshold %v must be between 0 and 1", c.Threshold)
+	}
+	for name, w := range c.Weights {
+		if w < 0 {
+			return fmt.Errorf("weight of scorer %q is negative", name)
+		}
+	}
+	if c.MaxConcurrent < 0 || c.BatchMaxConcurrent < 0 {
+		return fmt.Errorf("max_concurrent and batch_max_concurrent must not be negative")
+	}
+	if c.BatchMaxConcurrent > 0 && c.MaxConcurrent == 0 {
+		return fmt.Errorf("batch_max_concurrent requires max_concurrent")
+	}
+	for name, n := range c.Concurrency {
+		if n < 1 {
+			return fmt.Errorf("concurrency of %q must be positive", name)
+		}
+	}
+	if c.PromptTemplate != "" {
+		t, err := template.New("tuning").Parse(c.PromptTemplate)
+		if err != nil {
+			return err
+		}
+		c.prompt = t
+	}
+	if c.CanaryPercent < 0 || c.CanaryPercent > 100 {
+		return fmt.Errorf("canary_percent %v must be between 0 and 100", c.CanaryPercent)
+	}
+	if c.CanaryPercent > 0 && c.CanaryTemplate == "" {
+		return fmt.Errorf("canary_percent requires canary_template")
+	}
+	if c.CanaryTemplate != "" {
+		t, err := template.New("canary").Parse(c.CanaryTemplate)
+		if err != nil {
+			return err
+		}
+		c.canary = t
+	}
+	return nil
+}
+
+// Load reads and validates a JSON Config file.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parsing tuning file %s: %w", path, err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("parsing tuning file %s: %w", path, err)
+	}
+	return &c, nil
+}
@@ -0,0 +1,42 @@
+package tuning
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "tuning.json")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	c, err := Load(write(`{"threshold":0.6,"weights":{"jaccard":0.5},"prompt_template":"Recipe for {{.Query}}","canary_template":"A recipe for {{.Query}}","canary_percent":5,"concurrency":{"generate":4}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Threshold != 0.6 || c.Weights["jaccard"] != 0.5 || c.Prompt() == nil || c.Canary() == nil || c.Concurrency["generate"] != 4 {
+		t.Errorf("Unexpected config %+v", c)
+	}
+
+	for content, want := range map[string]string{
+		`{"threshold":1.5}`:                                     "threshold",
+		`{"weights":{"jaccard":-1}}`:                            "negative",
+		`{"batch_max_concurrent":2}`:                            "requires max_concurrent",
+		`{"concurrency":{"/resolve":0}}`:                        "positive",
+		`{"prompt_template":"{{.Query"}`:                        "unclosed action",
+		`{"threshold":`:                                         "parsing",
+		`{"canary_percent":10}`:                                 "requires canary_template",
+		`{"canary_template":"{{.Query}}","canary_percent":150}`: "between 0 and 100",
+	} {
+		if _, err := Load(write(content)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error mentioning %q, got %v", content, want, err)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package units
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// WaterDensity is the density, in g/ml, assumed for ingredients missing from a DensityTable.
+const WaterDensity = 1.0
+
+// DensityTable maps an ingredient name to its density in grams per
+// millilitre as measured in a kitchen: 1 cup of flour weighs about 125 g, so flour is 0.53.
+type DensityTable map[string]float64
+
+//go:embed densities.json
+var defaultDensities []byte
+
+// DefaultDensities returns the bundled table of common baking and cooking ingredients.
+func DefaultDensities() DensityTable {
+	t, err := parseDensities(defaultDensities)
+	if err != nil {
+		panic("units: bundled density table is invalid: " + err.Error())
+	}
+	return t
+}
+
+// Densities is the table used by Grams. Deployments may extend or replace it at startup, e.g.
+// with LoadDensities.
+var Densities = DefaultDensities()
+
+// LoadDensities reads a JSON file of the form {"almond flour": 0.41} and returns the bundled
+// table with the file's entries added or overridden.
+func LoadDensities(path string) (DensityTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := parseDensities(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing density table %s: %w", path, err)
+	}
+	t := DefaultDensities()
+	for name, d := range overrides {
+		t[name] = d
+	}
+	return t, nil
+}
+
+func parseDensities(data []byte) (DensityTable, error) {
+	var t DensityTable
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	for name, d := range t {
+		if d <= 0 {
+			return nil, fmt.Errorf("%s: density must be positive, got %g", name, d)
+		}
+	}
+	return t, nil
+}
+
+// Density returns the density of the ingredient an ingredient name or line refers to, and
+// whether the table lists it; otherwise it returns WaterDensity. Entries with more words win
+// ("brown sugar" over "sugar"), then the entry ending nearest the end of the name, since English
+// puts the head noun last ("almond milk" is milk).
+func (t DensityTable) Density(ingredient string) (float64, bool) {
+	words := strings.FieldsFunc(strings.ToLower(ingredient), func(r rune) bool { return !unicode.IsLetter(r) })
+	pos := make(map[string]int, len(words))
+	for i, w := range words {
+		pos[singular(w)] = i
+	}
+	best, bestWords, bestEnd := "", 0, -1
+	for name := range t {
+		fields := strings.Fields(name)
+		end, all := -1, true
+		for _, f := range fields {
+			i, ok := pos[singular(f)]
+			if !ok {
+				all = false
+				break
+			}
+			if i > end {
+				end = i
+			}
+		}
+		if !all {
+			continue
+		}
+		if len(fields) > bestWords || len(fields) == bestWords && (end > bestEnd || end == bestEnd && name < best) {
+			best, bestWords, bestEnd = name, len(fields), end
+		}
+	}
+	if best == "" {
+		return WaterDensity, false
+	}
+	return t[best], true
+}
+
+// Grams returns the weight in grams of an ingredient line such as "1 cup flour" or "200 g
+// sugar", using t to convert volumes. It reports false for lines without an amount and a
+// volume or weight unit, such as "2 eggs".
+func (t DensityTable) Grams(line string) (float64, bool) {
+	q, ok := ParseQuantity(line)
+	if !ok {
+		return 0, false
+	}
+	if g, ok := q.Grams(); ok {
+		return g, true
+	}
+	ml, ok := q.Millilitres()
+	if !ok {
+		return 0, false
+	}
+	d, _ := t.Density(q.Ingredient)
+	return ml * d, true
+}
+
+// Grams is Densities.Grams.
+func Grams(line string) (float64, bool) {
+	return Densities.Grams(line)
+}
+
+// singular strips common English plural endings: "walnuts" -> "walnut", "berries" -> "berry".
+func singular(w string) string {
+	switch {
+	case strings.HasSuffix(w, "ies") && len(w) > 4:
+		return w[:len(w)-3] + "y"
+	case strings.HasSuffix(w, "oes"):
+		return w[:len(w)-2]
+	case strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") && len(w) > 3:
+		return w[:len(w)-1]
+	}
+	return w
+}
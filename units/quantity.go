@@ -0,0 +1,150 @@
+package units
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Quantity is the amount at the start of an ingredient line: "1 1/2 cups flour" is 1.5 "cup"
+// of "flour".
+type Quantity struct {
+	Amount float64
+	// Unit is the canonical unit name, e.g. "cup" or "g", or "" for counts such as "2 eggs".
+	Unit       string
+	Ingredient string
+}
+
+// unit is a unit of volume or weight with its size in millilitres or grams.
+type unit struct {
+	name  string
+	ml    float64
+	grams float64
+}
+
+// unitsBySpelling lists the recognized units by spelling. Abbreviations are matched case-insensitively
+// except that "T" is a tablespoon and "t" a teaspoon, as in many older recipes.
+var unitsBySpelling = func() map[string]unit {
+	m := make(map[string]unit)
+	add := func(u unit, spellings ...string) {
+		for _, s := range spellings {
+			m[s] = u
+		}
+	}
+	add(unit{name: "ml", ml: 1}, "ml", "milliliter", "milliliters", "millilitre", "millilitres")
+	add(unit{name: "cl", ml: 10}, "cl", "centiliter", "centiliters", "centilitre", "centilitres")
+	add(unit{name: "dl", ml: 100}, "dl", "deciliter", "deciliters", "decilitre", "decilitres")
+	add(unit{name: "l", ml: 1000}, "l", "liter", "liters", "litre", "litres")
+	add(unit{name: "tsp", ml: 4.92892}, "tsp", "tsps", "teaspoon", "teaspoons", "t")
+	add(unit{name: "tbsp", ml: 14.7868}, "tbsp", "tbsps", "tbs", "tablespoon", "tablespoons", "T")
+	add(unit{name: "fl oz", ml: 29.5735}, "fl oz", "fluid ounce", "fluid ounces")
+	add(unit{name: "cup", ml: 236.588}, "cup", "cups", "c")
+	add(unit{name: "pint", ml: 473.176}, "pint", "pints", "pt")
+	add(unit{name: "quart", ml: 946.353}, "quart", "quarts", "qt")
+	add(unit{name: "gallon", ml: 3785.41}, "gallon", "gallons", "gal")
+	add(unit{name: "mg", grams: 0.001}, "mg", "milligram", "milligrams")
+	add(unit{name: "g", grams: 1}, "g", "gram", "grams", "gramme", "grammes")
+	add(unit{name: "kg", grams: 1000}, "kg", "kilogram", "kilograms")
+	add(unit{name: "oz", grams: 28.3495}, "oz", "ounce", "ounces")
+	add(unit{name: "lb", grams: 453.592}, "lb", "lbs", "pound", "pounds")
+	return m
+}()
+
+// vulgarFractions maps the Unicode fraction characters recipes use to their values.
+var vulgarFractions = map[rune]float64{
+	'½': 0.5, '⅓': 1.0 / 3, '⅔': 2.0 / 3, '¼': 0.25, '¾': 0.75, '⅛': 0.125, '⅜': 0.375, '⅝': 0.625, '⅞': 0.875,
+}
+
+// ParseQuantity parses the amount and unit at the start of an ingredient line. Amounts may be
+// decimals ("1.5"), fractions ("1/2", "½") or mixed numbers ("1 1/2", "1½"). It reports false
+// if the line does not start with an amount. A unit may follow the amount directly ("200g").
+func ParseQuantity(line string) (Quantity, bool) {
+	fields := strings.Fields(line)
+	if len(fields) > 0 {
+		if i := strings.IndexFunc(fields[0], unicode.IsLetter); i > 0 {
+			fields = append([]string{fields[0][:i], fields[0][i:]}, fields[1:]...)
+		}
+	}
+	amount, n := 0.0, 0
+	for n < len(fields) && n < 2 {
+		v, ok := parseAmount(fields[n])
+		if !ok || (n == 1 && v >= 1) {
+			break
+		}
+		amount += v
+		n++
+	}
+	if n == 0 {
+		return Quantity{}, false
+	}
+	rest := fields[n:]
+
+	q := Quantity{Amount: amount}
+	// Two-word units first, so that "fl oz" is not read as a count of "fl".
+	for _, size := range []int{2, 1} {
+		if len(rest) < size {
+			continue
+		}
+		spelling := strings.TrimSuffix(strings.Join(rest[:size], " "), ".")
+		u, ok := unitsBySpelling[spelling]
+		if !ok && spelling != "T" && spelling != "t" {
+			u, ok = unitsBySpelling[strings.ToLower(spelling)]
+		}
+		if ok {
+			q.Unit, rest = u.name, rest[size:]
+			break
+		}
+	}
+	if len(rest) > 0 && rest[0] == "of" {
+		rest = rest[1:]
+	}
+	q.Ingredient = strings.Join(rest, " ")
+	return q, true
+}
+
+// parseAmount parses a single amount token such as "2", "1.5", "3/4", "½" or "1½".
+func parseAmount(s string) (float64, bool) {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return 0, false
+	}
+	if v, ok := vulgarFractions[runes[len(runes)-1]]; ok {
+		if len(runes) == 1 {
+			return v, true
+		}
+		whole, err := strconv.Atoi(string(runes[:len(runes)-1]))
+		return float64(whole) + v, err == nil
+	}
+	if !unicode.IsDigit(runes[0]) {
+		return 0, false
+	}
+	if num, den, ok := strings.Cut(s, "/"); ok {
+		n, err1 := strconv.Atoi(num)
+		d, err2 := strconv.Atoi(den)
+		if err1 != nil || err2 != nil || d == 0 {
+			return 0, false
+		}
+		return float64(n) / float64(d), true
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil
+}
+
+// Millilitres returns the volume of q, or false if its unit is not a volume.
+func (q Quantity) Millilitres() (float64, bool) {
+	u, ok := unitsBySpelling[q.Unit]
+	if !ok || u.ml == 0 {
+		return 0, false
+	}
+	return q.Amount * u.ml, true
+}
+
+// Grams returns the weight of q, or false if its unit is not a weight. Use DensityTable.Grams to
+// convert volumes as well.
+func (q Quantity) Grams() (float64, bool) {
+	u, ok := unitsBySpelling[q.Unit]
+	if !ok || u.grams == 0 {
+		return 0, false
+	}
+	return q.Amount * u.grams, true
+}
@@ -0,0 +1,125 @@
+// Package units recognizes measurements in recipe text and converts them between systems of
+// measurement.
+package units
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// System is a system of measurement.
+type System int
+
+const (
+	// Metric uses grams, millilitres and degrees Celsius.
+	Metric System = iota
+	// USCustomary uses ounces, cups and degrees Fahrenheit.
+	USCustomary
+)
+
+func (s System) String() string {
+	if s == USCustomary {
+		return "us"
+	}
+	return "metric"
+}
+
+// CelsiusToFahrenheit converts a temperature from °C to °F.
+func CelsiusToFahrenheit(c float64) float64 { return c*9/5 + 32 }
+
+// FahrenheitToCelsius converts a temperature from °F to °C.
+func FahrenheitToCelsius(f float64) float64 { return (f - 32) * 5 / 9 }
+
+// Temperature patterns. A degree sign or word makes the unit unambiguous ("180 °C",
+// "350 degrees F"); a bare capital C or F is only accepted directly after a number of at least
+// three digits ("400F"), since "2 c" usually means cups. Either may be preceded by the lower
+// end of a range ("350-375°F").
+var (
+	tempMarked = regexp.MustCompile(`(?i)(?:(\d+(?:\.\d+)?)\s*(?:-|–|to)\s*)?(\d+(?:\.\d+)?)\s*(?:°|º|degrees?\b|deg\b\.?)\s*(celsius|centigrade|fahrenheit|c|f)\b`)
+	tempBare   = regexp.MustCompile(`(?:(\d{3})\s*(?:-|–|to)\s*)?(\d{3})\s?([CF])\b`)
+)
+
+// AnnotateTemperatures finds temperatures in text and, for those written in the other system,
+// prefixes the converted value: with Metric, "bake at 400°F" becomes "bake at 200°C/400°F".
+// Temperatures already in the requested system are left alone.
+//
+// Oven temperatures (150–260 °C) are rounded the way recipes print them, to 10 °C and 25 °F;
+// others, such as sugar stages, are rounded to a whole degree.
+func AnnotateTemperatures(text string, to System) string {
+	for _, pattern := range []*regexp.Regexp{tempMarked, tempBare} {
+		text = pattern.ReplaceAllStringFunc(text, func(match string) string {
+			m := pattern.FindStringSubmatch(match)
+			fahrenheit := strings.HasPrefix(strings.ToLower(m[3]), "f")
+			if fahrenheit == (to == USCustomary) {
+				return match
+			}
+			converted := convertTemperature(m[2], fahrenheit)
+			if m[1] != "" {
+				converted = strings.TrimSuffix(convertTemperature(m[1], fahrenheit), unitSuffix(!fahrenheit)) + "–" + converted
+			}
+			return converted + "/" + strings.TrimSpace(match)
+		})
+	}
+	return text
+}
+
+// Temperatures returns the temperatures mentioned in text, in degrees Celsius, in order of
+// appearance. Ranges contribute their lower end.
+func Temperatures(text string) []float64 {
+	type found struct {
+		pos     int
+		celsius float64
+	}
+	var all []found
+	for _, pattern := range []*regexp.Regexp{tempMarked, tempBare} {
+		for _, m := range pattern.FindAllStringSubmatchIndex(text, -1) {
+			value := text[m[4]:m[5]]
+			if m[2] >= 0 {
+				value = text[m[2]:m[3]]
+			}
+			v, _ := strconv.ParseFloat(value, 64)
+			if strings.HasPrefix(strings.ToLower(text[m[6]:m[7]]), "f") {
+				v = FahrenheitToCelsius(v)
+			}
+			all = append(all, found{m[0], v})
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].pos < all[j].pos })
+	out := make([]float64, len(all))
+	for i, f := range all {
+		out[i] = f.celsius
+	}
+	return out
+}
+
+// convertTemperature converts a number written in °F (or °C if !fahrenheit) into the other
+// scale, formatted with its unit.
+func convertTemperature(s string, fahrenheit bool) string {
+	v, _ := strconv.ParseFloat(s, 64)
+	celsius := v
+	if fahrenheit {
+		celsius = FahrenheitToCelsius(v)
+	}
+	oven := celsius >= 145 && celsius <= 265
+	if fahrenheit {
+		if oven {
+			celsius = math.Round(celsius/10) * 10
+		}
+		return strconv.FormatFloat(math.Round(celsius), 'f', 0, 64) + unitSuffix(false)
+	}
+	f := CelsiusToFahrenheit(celsius)
+	if oven {
+		f = math.Round(f/25) * 25
+	}
+	return strconv.FormatFloat(math.Round(f), 'f', 0, 64) + unitSuffix(true)
+}
+
+func unitSuffix(fahrenheit bool) string {
+	if fahrenheit {
+		return "°F"
+	}
+	return "°C"
+}
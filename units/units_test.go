@@ -0,0 +1,130 @@
+package units
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAnnotateTemperatures(t *testing.T) {
+	tests := []struct {
+		in   string
+		to   System
+		want string
+	}{
+		{"Bake at 400°F for 20 minutes.", Metric, "Bake at 200°C/400°F for 20 minutes."},
+		{"Preheat the oven to 350 degrees Fahrenheit.", Metric, "Preheat the oven to 180°C/350 degrees Fahrenheit."},
+		{"Roast at 220 °C until golden.", USCustomary, "Roast at 425°F/220 °C until golden."},
+		{"Heat oil to 350-375°F.", Metric, "Heat oil to 180–190°C/350-375°F."},
+		{"Bake at 425F.", Metric, "Bake at 220°C/425F."},
+		// Sugar stages need precision rather than oven rounding.
+		{"Boil to 240°F (soft ball).", Metric, "Boil to 116°C/240°F (soft ball)."},
+		// Already in the requested system.
+		{"Bake at 400°F.", USCustomary, "Bake at 400°F."},
+		{"Bake at 200°C.", Metric, "Bake at 200°C."},
+		// Not temperatures.
+		{"Add 2 c flour and 1 C sugar.", Metric, "Add 2 c flour and 1 C sugar."},
+		{"Use 2 eggs and the 100 Club recipe.", Metric, "Use 2 eggs and the 100 Club recipe."},
+	}
+	for _, tt := range tests {
+		if got := AnnotateTemperatures(tt.in, tt.to); got != tt.want {
+			t.Errorf("AnnotateTemperatures(%q, %s) = %q, want %q", tt.in, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestConversions(t *testing.T) {
+	if got := CelsiusToFahrenheit(100); got != 212 {
+		t.Errorf("CelsiusToFahrenheit(100) = %v, want 212", got)
+	}
+	if got := FahrenheitToCelsius(32); got != 0 {
+		t.Errorf("FahrenheitToCelsius(32) = %v, want 0", got)
+	}
+}
+
+func TestTemperatures(t *testing.T) {
+	got := Temperatures("Roast at 425F until the thigh reaches 165 °F; rest. Fry at 170-180°C.")
+	want := []float64{FahrenheitToCelsius(425), FahrenheitToCelsius(165), 170}
+	if len(got) != len(want) {
+		t.Fatalf("Temperatures = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Temperatures[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		line string
+		want Quantity
+		ok   bool
+	}{
+		{"1 cup flour", Quantity{1, "cup", "flour"}, true},
+		{"1 1/2 cups sugar", Quantity{1.5, "cup", "sugar"}, true},
+		{"½ tsp salt", Quantity{0.5, "tsp", "salt"}, true},
+		{"2 T butter", Quantity{2, "tbsp", "butter"}, true},
+		{"1 t vanilla", Quantity{1, "tsp", "vanilla"}, true},
+		{"200g dark chocolate", Quantity{200, "g", "dark chocolate"}, true},
+		{"8 fl oz of milk", Quantity{8, "fl oz", "milk"}, true},
+		{"2 large eggs", Quantity{2, "", "large eggs"}, true},
+		{"salt to taste", Quantity{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseQuantity(tt.line)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseQuantity(%q) = %+v, %v; want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestGrams(t *testing.T) {
+	tests := []struct {
+		line string
+		want float64
+		ok   bool
+	}{
+		{"1 cup flour", 125, true},
+		{"1 cup all-purpose flour, sifted", 125, true},
+		{"1 cup packed brown sugar", 213, true},
+		{"1 cup almond milk", 244, true},
+		{"2 tbsp honey", 42, true},
+		{"1 cup mystery liquid", 237, true},
+		{"1 lb ground beef", 454, true},
+		{"2 eggs", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := Grams(tt.line)
+		if ok != tt.ok || math.Abs(got-tt.want) > 2 {
+			t.Errorf("Grams(%q) = %.1f, %v; want about %.0f, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLoadDensities(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "densities.json")
+	if err := os.WriteFile(path, []byte(`{"flour": 0.6, "tahini": 1.02}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	table, err := LoadDensities(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := table.Density("flour"); d != 0.6 {
+		t.Errorf("Expected the override for flour, got %g", d)
+	}
+	if d, ok := table.Density("tahini"); !ok || d != 1.02 {
+		t.Errorf("Expected the added tahini entry, got %g, %v", d, ok)
+	}
+	if _, ok := table.Density("honey"); !ok {
+		t.Error("Expected bundled entries to be kept")
+	}
+	if err := os.WriteFile(path, []byte(`{"flour": 0}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadDensities(path); err == nil {
+		t.Error("Expected a zero density to be rejected")
+	}
+}
@@ -0,0 +1,203 @@
+// Package voice cleans up recipe queries transcribed from speech, such as "um can you find me a
+// recipe for keen wah salad ready in twenty five minutes", into what the same user would have
+// typed: "quinoa salad ready in 25 minutes". Speech recognition output has filler words, spells
+// numbers out and mishears dish names, all of which hurt matching against recipe titles.
+package voice
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Source values of a request, telling how its query was entered.
+const (
+	// SourceText queries were typed; they are used as they are.
+	SourceText = "text"
+	// SourceVoice queries were transcribed from speech and go through Normalize.
+	SourceVoice = "voice"
+)
+
+// Confusion is a phrase speech recognition commonly produces in place of a food term.
+type Confusion struct {
+	Heard, Meant string
+}
+
+// Confusions are the mishearings Normalize corrects, applied in order to whole words regardless
+// of case. Only phrases unlikely to be meant literally in a recipe query belong here: "stake" is
+// always "steak", but "time" may well not be "thyme".
+var Confusions = []Confusion{
+	{"keen wah", "quinoa"},
+	{"keen what", "quinoa"},
+	{"new key", "gnocchi"},
+	{"nokey", "gnocchi"},
+	{"bruce getta", "bruschetta"},
+	{"bruce ketta", "bruschetta"},
+	{"pad tie", "pad thai"},
+	{"tie curry", "thai curry"},
+	{"tie green curry", "thai green curry"},
+	{"tikka mossala", "tikka masala"},
+	{"tick a masala", "tikka masala"},
+	{"cheese stake", "cheesesteak"},
+	{"stake", "steak"},
+	{"stakes", "steaks"},
+	{"muscles", "mussels"},
+	{"moose", "mousse"},
+	{"serial", "cereal"},
+	{"keesh", "quiche"},
+	{"chilly", "chili"},
+	{"desert", "dessert"},
+	{"deserts", "desserts"},
+	{"leaks", "leeks"},
+	{"sorbay", "sorbet"},
+	{"fuh", "pho"},
+}
+
+var (
+	// disfluencies matches hesitations and verbal tics anywhere in a transcript.
+	disfluencies = regexp.MustCompile(`(?i)\b(?:u+m+|u+h+|e+r+m*|a+h+|h+m+|m+h*m+|you know|i mean)\b[,.]?`)
+	// leadIn matches the way a spoken request opens, up to the dish: "hey so can you please find
+	// me a recipe for", "I'd like to make", "how do I cook". "give me" and "show me" before a
+	// number stay, since "give me 3 ideas" asks for several recipes.
+	leadIn = regexp.MustCompile(`(?i)^(?:(?:hey|hi|ok(?:ay)?|so|well|alright|please)\b[,.]?\s*)*` +
+		`(?:(?:can|could|would|will) you\s+)?(?:please\s+)?` +
+		`(?:(?:find|get|tell|give|show) me\s+(?:(?:an?|some|the)\s+)?(?:recipes?\s+(?:for|with)\s+|how to (?:make|cook)\s+)|` +
+		`(?:find|get)(?: me)?\s+|` +
+		`i(?:'d| would)? (?:like|want|need)(?: to (?:make|cook|have))?\s+|` +
+		`how (?:do i|to|can i|should i) (?:make|cook)\s+|` +
+		`what(?:'s| is) (?:an?|the) (?:good )?recipe (?:for|with)\s+)?` +
+		`(?:(?:an?|some)\s+)?(?:(?:good|nice|easy)\s+)?(?:recipes?\s+(?:for|with)\s+)?`)
+	// trailer matches courtesies at the end of a transcript.
+	trailer = regexp.MustCompile(`(?i)[,.]?\s*(?:please|thanks|thank you)[.!]?$`)
+	// punctuation matches sentence punctuation speech recognition adds.
+	punctuation = regexp.MustCompile(`[.?!]+$`)
+)
+
+// units are the words after which a spelled-out number is a quantity to write in digits. Other
+// number words are left alone, since they belong to dish names: "one pot pasta", "five spice
+// chicken", "three bean chili".
+var units = map[string]bool{
+	"minute": true, "minutes": true, "min": true, "mins": true, "hour": true, "hours": true,
+	"calorie": true, "calories": true, "kcal": true, "cal": true, "cals": true,
+	"gram": true, "grams": true, "g": true,
+	"serving": true, "servings": true, "people": true, "persons": true, "portions": true,
+	"ideas": true, "options": true, "recipes": true, "suggestions": true, "ways": true,
+	"variations": true, "dishes": true, "meals": true,
+}
+
+var (
+	ones = map[string]int{
+		"zero": 0, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6, "seven": 7,
+		"eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12, "thirteen": 13,
+		"fourteen": 14, "fifteen": 15, "sixteen": 16, "seventeen": 17, "eighteen": 18,
+		"nineteen": 19,
+	}
+	tens = map[string]int{
+		"twenty": 20, "thirty": 30, "forty": 40, "fifty": 50, "sixty": 60, "seventy": 70,
+		"eighty": 80, "ninety": 90,
+	}
+)
+
+// Normalize rewrites a speech transcript as a typed query: it drops filler words and the
+// opening and closing of a spoken request, corrects Confusions, and writes numbers of minutes,
+// calories, grams, servings and recipes in digits ("an hour and a half" stays for the intent
+// parser, "one and a half hours" becomes "1.5 hours"). If nothing is left, the transcript is
+// returned trimmed.
+func Normalize(transcript string) string {
+	text := disfluencies.ReplaceAllString(transcript, " ")
+	text = strings.Join(strings.Fields(text), " ")
+	for {
+		trimmed := strings.TrimSpace(trailer.ReplaceAllString(punctuation.ReplaceAllString(text, ""), ""))
+		if trimmed == text {
+			break
+		}
+		text = trimmed
+	}
+	text = strings.TrimSpace(leadIn.ReplaceAllString(text, ""))
+	text = corrected(numerals(text))
+	if text == "" {
+		return strings.TrimSpace(transcript)
+	}
+	return text
+}
+
+// corrected replaces the Confusions heard in text, matching whole words regardless of case.
+func corrected(text string) string {
+	for _, c := range Confusions {
+		heard := strings.Fields(c.Heard)
+		words := strings.Fields(text)
+		var out []string
+		for i := 0; i < len(words); {
+			if i+len(heard) <= len(words) && equalWords(words[i:i+len(heard)], heard) {
+				out = append(out, c.Meant)
+				i += len(heard)
+				continue
+			}
+			out = append(out, words[i])
+			i++
+		}
+		text = strings.Join(out, " ")
+	}
+	return text
+}
+
+// equalWords reports whether words and heard are the same words regardless of case, ignoring
+// commas after words.
+func equalWords(words, heard []string) bool {
+	for i := range heard {
+		if !strings.EqualFold(strings.TrimRight(words[i], ","), heard[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// numerals replaces the runs of number words in text that precede a unit with digits.
+func numerals(text string) string {
+	words := strings.Fields(text)
+	var out []string
+	for i := 0; i < len(words); {
+		n, frac, next := number(words[i:])
+		if next == 0 || i+next >= len(words) || !units[strings.ToLower(strings.Trim(words[i+next], ",."))] {
+			out = append(out, words[i])
+			i++
+			continue
+		}
+		digits := strconv.Itoa(n)
+		if frac {
+			digits += ".5"
+		}
+		out = append(out, digits)
+		i += next
+	}
+	return strings.Join(out, " ")
+}
+
+// number parses the spelled-out number at the start of words, such as "two hundred fifty" or
+// "one and a half", and returns its value, whether it has a half, and how many words it took;
+// zero words if it does not start with a number word.
+func number(words []string) (n int, half bool, used int) {
+	var current int
+	for used < len(words) {
+		w := strings.ToLower(words[used])
+		switch {
+		case ones[w] > 0 || w == "zero":
+			current += ones[w]
+		case tens[w] > 0:
+			current += tens[w]
+		case w == "hundred" && used > 0:
+			current *= 100
+		case w == "thousand" && used > 0:
+			n += current * 1000
+			current = 0
+		case w == "and" && used > 0 && used+2 < len(words) && strings.EqualFold(words[used+1], "a") && strings.EqualFold(words[used+2], "half"):
+			return n + current, true, used + 3
+		case w == "and" && used > 0 && used+1 < len(words) && (ones[strings.ToLower(words[used+1])] > 0 || tens[strings.ToLower(words[used+1])] > 0):
+			// "two hundred and fifty"
+		default:
+			return n + current, false, used
+		}
+		used++
+	}
+	return n + current, false, used
+}
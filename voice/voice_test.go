@@ -0,0 +1,25 @@
+package voice
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct{ transcript, want string }{
+		{"chicken salad", "chicken salad"},
+		{"Um, can you find me a recipe for keen wah salad ready in twenty five minutes?", "quinoa salad ready in 25 minutes"},
+		{"hey so uh I'd like to make pad tie please", "pad thai"},
+		{"give me three dinner ideas with salmon thanks", "give me three dinner ideas with salmon"},
+		{"how do I cook a cheese stake", "cheesesteak"},
+		{"high protein lunch under five hundred calories", "high protein lunch under 500 calories"},
+		{"pasta for two hundred and fifty people", "pasta for 250 people"},
+		{"beef stew in one and a half hours", "beef stew in 1.5 hours"},
+		{"one pot pasta with five spice chicken", "one pot pasta with five spice chicken"},
+		{"chocolate moose for desert", "chocolate mousse for dessert"},
+		{"show me how to make new key", "gnocchi"},
+		{"Um.", "Um."},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.transcript); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.transcript, got, tt.want)
+		}
+	}
+}